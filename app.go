@@ -20,6 +20,7 @@ import (
 	"time"
 
 	"cc-forwarder/config"
+	"cc-forwarder/internal/balance"
 	"cc-forwarder/internal/endpoint"
 	"cc-forwarder/internal/events"
 	"cc-forwarder/internal/logging"
@@ -31,6 +32,7 @@ import (
 	"cc-forwarder/internal/transport"
 	"cc-forwarder/internal/tray"
 	"cc-forwarder/internal/utils"
+	"cc-forwarder/internal/webhook"
 
 	"github.com/wailsapp/wails/v2/pkg/runtime"
 )
@@ -53,6 +55,7 @@ type App struct {
 	loggingMiddleware    *middleware.LoggingMiddleware
 	monitoringMiddleware *middleware.MonitoringMiddleware
 	authMiddleware       *middleware.AuthMiddleware
+	generatedAuthToken   string // 监听非本机地址时自动生成的访问 Token
 
 	// v5.0+ 端点存储 (SQLite)
 	endpointStore   store.EndpointStore      // 端点数据持久化
@@ -62,10 +65,34 @@ type App struct {
 	channelStore   store.ChannelStore      // 渠道数据持久化
 	channelService *service.ChannelService // 渠道业务服务
 
+	// 端点模型目录 (SQLite)
+	endpointModelStore   store.EndpointModelStore      // 端点模型持久化
+	endpointModelService *service.EndpointModelService // 端点模型业务服务
+
 	// v5.0+ 模型定价存储 (SQLite)
 	modelPricingStore   store.ModelPricingStore      // 模型定价数据持久化
 	modelPricingService *service.ModelPricingService // 模型定价业务服务
 
+	// Message Batches 记录 (SQLite)
+	messageBatchStore store.MessageBatchStore // 批处理所属端点及用量记录状态
+
+	// 汇率存储 (SQLite)
+	exchangeRateStore store.ExchangeRateStore // 多币种成本换算汇率
+
+	// 价目表 (SQLite)
+	priceBookService *service.PriceBookService // 渠道/端点价目表（优先于模型定价）
+
+	// 花费预算 (SQLite)
+	budgetService *service.BudgetService // 预算告警与硬限制
+
+	// Webhook 通知 (SQLite)
+	webhookService    *service.WebhookService // Webhook 目标与投递记录
+	webhookDispatcher *webhook.Dispatcher     // 订阅 EventBus 的运维事件出站投递
+
+	// 渠道余额探测 (SQLite)
+	channelBalanceService *service.ChannelBalanceService // 余额探测配置与余额时间序列
+	balancePoller         *balance.Poller                // 中转渠道余额轮询（低余额告警/排除）
+
 	// v5.1+ 系统设置存储 (SQLite)
 	settingsStore   store.SettingsStore      // 设置数据持久化
 	settingsService *service.SettingsService // 设置业务服务
@@ -165,6 +192,26 @@ func (a *App) startup(ctx context.Context) {
 	// 7.6 同步端点倍率到 UsageTracker（用于成本计算）
 	a.syncEndpointMultipliersToTracker(ctx)
 
+	// 7.7 同步渠道结算币种与汇率到 UsageTracker（用于多币种记账）
+	a.setupExchangeRateStore()
+	a.syncCurrencyToTracker(ctx)
+
+	// 7.8 同步渠道/端点价目表到 UsageTracker（优先于模型定价）
+	a.setupPriceBookService()
+	a.syncPriceBooksToTracker(ctx)
+
+	// 7.9 同步花费预算到 UsageTracker，并接入路由（超限渠道/端点不参与选择与故障转移）
+	a.setupBudgetService()
+	a.syncBudgetsToTracker(ctx)
+
+	// 7.10 初始化 Webhook 通知（订阅 EventBus 运维事件）
+	a.setupWebhookService()
+	a.syncWebhooksToDispatcher(ctx)
+
+	// 7.11 初始化渠道余额探测（低余额告警，并可将渠道排除出故障转移候选）
+	a.setupBalancePoller()
+	a.syncBalanceProbesToPoller(ctx)
+
 	// 8. 启动端点管理器（此时端点已从数据库加载完成）
 	a.endpointManager.Start()
 
@@ -203,6 +250,8 @@ func (a *App) shutdown(ctx context.Context) {
 	storeDB := a.storeDB
 	endpointManager := a.endpointManager
 	eventBus := a.eventBus
+	webhookDispatcher := a.webhookDispatcher
+	balancePoller := a.balancePoller
 	configWatcher := a.configWatcher
 	logEmitter := a.logEmitter
 	trayController := a.trayController
@@ -230,6 +279,14 @@ func (a *App) shutdown(ctx context.Context) {
 		}
 	}
 
+	// 1.5 停止 Webhook 投递与余额探测（结果写入管理 DB，需在 DB 关闭前停止）
+	if webhookDispatcher != nil {
+		webhookDispatcher.Stop()
+	}
+	if balancePoller != nil {
+		balancePoller.Stop()
+	}
+
 	// 2. 关闭使用追踪 (flush 数据库)
 	if usageTracker != nil {
 		done := make(chan struct{})
@@ -507,6 +564,11 @@ func (a *App) setupEndpointStore() {
 	a.channelStore = store.NewSQLiteChannelStore(db)
 	a.channelService = service.NewChannelService(a.channelStore)
 
+	// 创建 EndpointModelStore / EndpointModelService
+	a.endpointModelStore = store.NewSQLiteEndpointModelStore(db)
+	a.endpointModelService = service.NewEndpointModelService(a.endpointModelStore, a.endpointManager)
+	a.endpointManager.SetOnModelsDiscovered(a.endpointModelService.SaveDiscovered)
+
 	// 从数据库同步端点到内存
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
@@ -527,6 +589,10 @@ func (a *App) setupEndpointStore() {
 		a.syncChannelPrioritiesToEndpointManager(ctx)
 		// v6.2+: 同步渠道“参与故障转移”开关（暂停/恢复持久化）
 		a.syncChannelFailoverEnabledToEndpointManager(ctx)
+		// 加载端点模型目录（用于按模型筛选端点）
+		if err := a.endpointModelService.LoadIntoManager(ctx); err != nil {
+			a.logger.Warn("⚠️ 加载端点模型目录失败", "error", err)
+		}
 		a.logger.Info("✅ 端点存储已启用 (SQLite)")
 	}
 }
@@ -561,6 +627,11 @@ func (a *App) setupModelPricingStore() {
 		a.logger.Warn("⚠️ 加载模型定价缓存失败", "error", err)
 	}
 
+	// 为尚无历史版本的模型补录初始版本（升级前的数据按当前价格计价）
+	if _, err := a.modelPricingService.EnsureHistory(ctx); err != nil {
+		a.logger.Warn("⚠️ 补录模型定价历史版本失败", "error", err)
+	}
+
 	// 同步定价到 UsageTracker（用于成本计算）
 	a.syncPricingToTracker(ctx)
 
@@ -587,6 +658,7 @@ func (a *App) ensureModelPricingService() {
 
 	modelPricingStore := store.NewSQLiteModelPricingStore(db)
 	modelPricingService := service.NewModelPricingService(modelPricingStore)
+	modelPricingService.SetHistoryStore(store.NewSQLiteModelPricingHistoryStore(db))
 
 	a.mu.Lock()
 	if a.modelPricingService == nil {
@@ -596,6 +668,20 @@ func (a *App) ensureModelPricingService() {
 	a.mu.Unlock()
 }
 
+// sonnetLongContextTiers Sonnet 4/4.5 1M 上下文 beta 定价：提示超过 200K tokens 时输入 2x、输出 1.5x
+func sonnetLongContextTiers() []store.PricingTierRecord {
+	return []store.PricingTierRecord{
+		{
+			Threshold:            200_000,
+			InputPrice:           6.0,
+			OutputPrice:          22.5,
+			CacheCreationPrice5m: 7.5,
+			CacheCreationPrice1h: 12.0,
+			CacheReadPrice:       0.60,
+		},
+	}
+}
+
 // initDefaultModelPricing 初始化默认模型定价数据
 func (a *App) initDefaultModelPricing(ctx context.Context) {
 	// Claude 官方定价 (2025年最新)
@@ -623,6 +709,7 @@ func (a *App) initDefaultModelPricing(ctx context.Context) {
 			CacheCreationPrice5m: 3.75,
 			CacheCreationPrice1h: 6.0,
 			CacheReadPrice:       0.30,
+			Tiers:                sonnetLongContextTiers(),
 		},
 		// Claude 3.5 Sonnet
 		{
@@ -668,6 +755,7 @@ func (a *App) initDefaultModelPricing(ctx context.Context) {
 			CacheCreationPrice5m: 3.75, // 3.0 * 1.25
 			CacheCreationPrice1h: 6.0,  // 3.0 * 2.0
 			CacheReadPrice:       0.30, // 3.0 * 0.1
+			Tiers:                sonnetLongContextTiers(),
 		},
 		// Claude Haiku 4.5
 		{
@@ -724,6 +812,12 @@ func (a *App) initDefaultModelPricing(ctx context.Context) {
 		},
 	}
 
+	// 服务端工具价格：web search 官方统一 $10/1K 次，web fetch 仅收取 token 费用
+	for _, pricing := range defaultPricings {
+		pricing.WebSearchPrice = defaultWebSearchPrice
+		pricing.WebFetchPrice = defaultWebFetchPrice
+	}
+
 	if err := a.modelPricingStore.BatchUpsert(ctx, defaultPricings); err != nil {
 		a.logger.Error("❌ 初始化默认模型定价失败", "error", err)
 		return
@@ -753,6 +847,14 @@ func (a *App) syncPricingToTracker(ctx context.Context) {
 	// 更新 UsageTracker 的定价缓存
 	a.usageTracker.UpdatePricing(pricing)
 	a.logger.Debug("已同步模型定价到 UsageTracker", "count", len(pricing))
+
+	// 同步定价历史版本（成本按请求开始时生效的版本计算）
+	versions, err := a.modelPricingService.ListHistory(ctx, "")
+	if err != nil {
+		a.logger.Warn("⚠️ 获取模型定价历史失败", "error", err)
+		return
+	}
+	a.usageTracker.UpdatePricingHistory(a.modelPricingService.ToTrackingVersions(versions))
 }
 
 // syncEndpointMultipliersToTracker 同步端点倍率到 UsageTracker
@@ -787,6 +889,420 @@ func (a *App) syncEndpointMultipliersToTracker(ctx context.Context) {
 	a.logger.Debug("已同步端点倍率到 UsageTracker", "count", len(multipliers))
 }
 
+// setupExchangeRateStore 初始化汇率存储
+func (a *App) setupExchangeRateStore() {
+	db := a.storeDB
+	if db == nil && a.usageTracker != nil {
+		db = a.usageTracker.GetDB()
+	}
+	if db == nil {
+		return
+	}
+	a.exchangeRateStore = store.NewSQLiteExchangeRateStore(db)
+}
+
+// syncCurrencyToTracker 同步渠道结算币种与汇率到 UsageTracker
+// 成本换算：USD 成本 * 请求时生效的汇率（1 USD 可兑换的目标币种数量）
+func (a *App) syncCurrencyToTracker(ctx context.Context) {
+	a.mu.RLock()
+	usageTracker := a.usageTracker
+	channelStore := a.channelStore
+	exchangeRateStore := a.exchangeRateStore
+	logger := a.logger
+	a.mu.RUnlock()
+
+	if usageTracker == nil {
+		return
+	}
+
+	if channelStore != nil {
+		channels, err := channelStore.List(ctx)
+		if err != nil {
+			logger.Warn("⚠️ 获取渠道列表失败", "error", err)
+		} else {
+			currencies := make(map[string]string, len(channels))
+			for _, c := range channels {
+				if c == nil || c.Name == "" {
+					continue
+				}
+				currencies[c.Name] = c.BillingCurrency
+			}
+			usageTracker.UpdateChannelCurrencies(currencies)
+		}
+	}
+
+	if exchangeRateStore != nil {
+		records, err := exchangeRateStore.List(ctx, "")
+		if err != nil {
+			logger.Warn("⚠️ 获取汇率列表失败", "error", err)
+		} else {
+			rates := make([]tracking.ExchangeRate, 0, len(records))
+			for _, r := range records {
+				rates = append(rates, tracking.ExchangeRate{
+					Channel:       r.Channel,
+					Currency:      r.Currency,
+					Rate:          r.Rate,
+					EffectiveFrom: r.EffectiveFrom,
+				})
+			}
+			usageTracker.UpdateExchangeRates(rates)
+		}
+	}
+	logger.Debug("已同步渠道结算币种与汇率到 UsageTracker")
+}
+
+// setupPriceBookService 初始化价目表存储与服务
+func (a *App) setupPriceBookService() {
+	db := a.storeDB
+	if db == nil && a.usageTracker != nil {
+		db = a.usageTracker.GetDB()
+	}
+	if db == nil {
+		return
+	}
+	a.priceBookService = service.NewPriceBookService(store.NewSQLitePriceBookStore(db))
+}
+
+// syncPriceBooksToTracker 同步已启用的价目表到 UsageTracker
+// 价格来源优先级：端点价目表 > 渠道价目表 > 模型定价 × 端点倍率
+func (a *App) syncPriceBooksToTracker(ctx context.Context) {
+	a.mu.RLock()
+	usageTracker := a.usageTracker
+	priceBookService := a.priceBookService
+	logger := a.logger
+	a.mu.RUnlock()
+
+	if usageTracker == nil || priceBookService == nil {
+		return
+	}
+
+	records, err := priceBookService.ListPriceBooks(ctx)
+	if err != nil {
+		logger.Warn("⚠️ 获取价目表列表失败", "error", err)
+		return
+	}
+	books := priceBookService.ToTrackingPriceBooks(records)
+	usageTracker.UpdatePriceBooks(books)
+	logger.Debug("已同步价目表到 UsageTracker", "count", len(books))
+}
+
+// setupBudgetService 初始化预算存储与服务，并注册预算事件处理与路由守卫
+func (a *App) setupBudgetService() {
+	db := a.storeDB
+	if db == nil && a.usageTracker != nil {
+		db = a.usageTracker.GetDB()
+	}
+	if db == nil || a.usageTracker == nil {
+		return
+	}
+	a.budgetService = service.NewBudgetService(store.NewSQLiteBudgetStore(db))
+
+	a.usageTracker.SetBudgetEventHandler(a.handleBudgetEvent)
+	if a.endpointManager != nil {
+		a.endpointManager.SetBudgetGuard(a.usageTracker)
+	}
+}
+
+// syncBudgetsToTracker 同步已启用的预算到 UsageTracker
+func (a *App) syncBudgetsToTracker(ctx context.Context) {
+	a.mu.RLock()
+	usageTracker := a.usageTracker
+	budgetService := a.budgetService
+	logger := a.logger
+	a.mu.RUnlock()
+
+	if usageTracker == nil || budgetService == nil {
+		return
+	}
+
+	records, err := budgetService.ListBudgets(ctx)
+	if err != nil {
+		logger.Warn("⚠️ 获取预算列表失败", "error", err)
+		return
+	}
+	budgets := budgetService.ToTrackingBudgets(records)
+	usageTracker.UpdateBudgets(budgets)
+	logger.Debug("已同步预算到 UsageTracker", "count", len(budgets))
+}
+
+// handleBudgetEvent 处理预算告警/超限事件：manual 阻断持久化，divert 切换渠道，并通过事件总线通知
+func (a *App) handleBudgetEvent(evt tracking.BudgetEvent) {
+	a.mu.RLock()
+	budgetService := a.budgetService
+	endpointManager := a.endpointManager
+	usageTracker := a.usageTracker
+	eventBus := a.eventBus
+	a.mu.RUnlock()
+
+	st := evt.Status
+	if evt.Type == tracking.BudgetEventTripped && st.Action == tracking.BudgetActionManual && budgetService != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		if err := budgetService.MarkTripped(ctx, st.Name, st.TrippedAt); err != nil {
+			slog.Warn(fmt.Sprintf("⚠️ [预算] 持久化超限状态失败: %s - %v", st.Name, err))
+		}
+		cancel()
+	}
+
+	divertedTo := ""
+	if evt.Type == tracking.BudgetEventTripped && st.Action == tracking.BudgetActionDivert && endpointManager != nil {
+		divertedTo = divertBudgetChannel(endpointManager, usageTracker, st)
+	}
+
+	if eventBus == nil {
+		return
+	}
+
+	eventType := events.EventBudgetAlert
+	priority := events.PriorityHigh
+	if evt.Type == tracking.BudgetEventTripped {
+		eventType = events.EventBudgetExceeded
+		priority = events.PriorityCritical
+	}
+	eventBus.Publish(events.Event{
+		Type:     eventType,
+		Source:   "budget",
+		Priority: priority,
+		Data: map[string]interface{}{
+			"name":           st.Name,
+			"scope":          st.Scope,
+			"channel":        st.Channel,
+			"endpoint_name":  st.EndpointName,
+			"model":          st.Model,
+			"window":         st.Window,
+			"action":         st.Action,
+			"divert_channel": st.DivertChannel,
+			"diverted_to":    divertedTo,
+			"threshold":      evt.Threshold,
+			"limit_usd":      st.LimitUSD,
+			"spent_usd":      st.SpentUSD,
+			"percent":        st.Percent,
+			"blocked":        st.Blocked,
+			"window_end":     st.WindowEnd.Format("2006-01-02 15:04:05"),
+			"timestamp":      time.Now().Format("2006-01-02 15:04:05"),
+		},
+	})
+}
+
+// webhookDeliveryRetentionDays Webhook 投递记录保留天数
+const webhookDeliveryRetentionDays = 30
+
+// setupWebhookService 初始化 Webhook 存储、服务与投递器，并订阅 EventBus
+func (a *App) setupWebhookService() {
+	db := a.storeDB
+	if db == nil && a.usageTracker != nil {
+		db = a.usageTracker.GetDB()
+	}
+	if db == nil || a.eventBus == nil {
+		return
+	}
+	webhookService := service.NewWebhookService(store.NewSQLiteWebhookStore(db))
+
+	// 复用全局代理配置（团队 IM 机器人通常需要经代理访问）
+	client := &http.Client{}
+	if t, err := transport.CreateTransport(a.config); err == nil {
+		client.Transport = t
+	} else {
+		a.logger.Warn("⚠️ Webhook 代理传输创建失败，使用默认传输", "error", err)
+	}
+
+	dispatcher := webhook.NewDispatcher(client)
+	dispatcher.SetDeliveryRecorder(func(d webhook.Delivery) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := webhookService.RecordDelivery(ctx, d); err != nil {
+			slog.Warn(fmt.Sprintf("⚠️ [Webhook] 记录投递结果失败: %s - %v", d.Webhook, err))
+		}
+	})
+	dispatcher.Start()
+	a.eventBus.Subscribe(dispatcher.HandleEvent)
+
+	a.webhookService = webhookService
+	a.webhookDispatcher = dispatcher
+
+	// 清理过期投递记录
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if removed, err := webhookService.PruneDeliveries(ctx, webhookDeliveryRetentionDays); err != nil {
+			slog.Warn(fmt.Sprintf("⚠️ [Webhook] 清理投递记录失败: %v", err))
+		} else if removed > 0 {
+			slog.Info(fmt.Sprintf("🧹 [Webhook] 已清理 %d 条过期投递记录", removed))
+		}
+	}()
+}
+
+// syncWebhooksToDispatcher 同步已启用的 Webhook 目标到投递器
+func (a *App) syncWebhooksToDispatcher(ctx context.Context) {
+	a.mu.RLock()
+	webhookService := a.webhookService
+	dispatcher := a.webhookDispatcher
+	logger := a.logger
+	a.mu.RUnlock()
+
+	if webhookService == nil || dispatcher == nil {
+		return
+	}
+
+	records, err := webhookService.ListWebhooks(ctx)
+	if err != nil {
+		logger.Warn("⚠️ 获取 Webhook 列表失败", "error", err)
+		return
+	}
+	dispatcher.UpdateTargets(webhookService.ToTargets(records))
+}
+
+// balanceHistoryRetentionDays 渠道余额时间序列保留天数
+const balanceHistoryRetentionDays = 90
+
+// setupBalancePoller 初始化渠道余额存储、服务与轮询器，并接入故障转移候选过滤
+func (a *App) setupBalancePoller() {
+	db := a.storeDB
+	if db == nil && a.usageTracker != nil {
+		db = a.usageTracker.GetDB()
+	}
+	if db == nil {
+		return
+	}
+	balanceService := service.NewChannelBalanceService(store.NewSQLiteChannelBalanceStore(db))
+
+	// 中转余额接口与上游同源，复用全局代理配置
+	client := &http.Client{}
+	if t, err := transport.CreateTransport(a.config); err == nil {
+		client.Transport = t
+	} else {
+		a.logger.Warn("⚠️ 余额探测代理传输创建失败，使用默认传输", "error", err)
+	}
+
+	poller := balance.NewPoller(client)
+	poller.SetResultRecorder(func(r balance.Result) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := balanceService.RecordResult(ctx, r); err != nil {
+			slog.Warn(fmt.Sprintf("⚠️ [余额探测] 记录余额失败: %s - %v", r.Channel, err))
+		}
+	})
+	poller.SetAlertHandler(a.handleBalanceAlert)
+	poller.Start()
+	if a.endpointManager != nil {
+		a.endpointManager.SetBalanceGuard(poller)
+	}
+
+	a.channelBalanceService = balanceService
+	a.balancePoller = poller
+
+	// 清理过期余额记录
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if removed, err := balanceService.PruneHistory(ctx, balanceHistoryRetentionDays); err != nil {
+			slog.Warn(fmt.Sprintf("⚠️ [余额探测] 清理余额记录失败: %v", err))
+		} else if removed > 0 {
+			slog.Info(fmt.Sprintf("🧹 [余额探测] 已清理 %d 条过期余额记录", removed))
+		}
+	}()
+}
+
+// syncBalanceProbesToPoller 同步已启用的余额探测配置到轮询器
+func (a *App) syncBalanceProbesToPoller(ctx context.Context) {
+	a.mu.RLock()
+	balanceService := a.channelBalanceService
+	poller := a.balancePoller
+	logger := a.logger
+	a.mu.RUnlock()
+
+	if balanceService == nil || poller == nil {
+		return
+	}
+
+	records, err := balanceService.ListProbes(ctx)
+	if err != nil {
+		logger.Warn("⚠️ 获取余额探测配置失败", "error", err)
+		return
+	}
+	poller.UpdateProbes(balanceService.ToProbes(records))
+}
+
+// handleBalanceAlert 处理低余额/恢复告警：低余额且开启排除时将流量切出活跃渠道，并通过事件总线通知
+func (a *App) handleBalanceAlert(alert balance.Alert) {
+	a.mu.RLock()
+	endpointManager := a.endpointManager
+	eventBus := a.eventBus
+	a.mu.RUnlock()
+
+	r := alert.Result
+	divertedTo := ""
+	if alert.Type == balance.AlertLow && alert.Probe.ExcludeWhenLow && endpointManager != nil {
+		reason := fmt.Sprintf("余额 %.4f %s < %.4f", r.Balance, r.Unit, alert.Probe.LowThreshold)
+		target, err := endpointManager.DivertLowBalanceChannel(r.Channel, reason)
+		if err != nil {
+			slog.Warn(fmt.Sprintf("⚠️ [余额探测] 渠道 %s 余额不足，切换渠道失败: %v", r.Channel, err))
+		}
+		divertedTo = target
+	}
+
+	if eventBus == nil {
+		return
+	}
+
+	eventType := events.EventBalanceLow
+	priority := events.PriorityHigh
+	if alert.Type == balance.AlertRecovered {
+		eventType = events.EventBalanceRecovered
+		priority = events.PriorityNormal
+	}
+	eventBus.Publish(events.Event{
+		Type:     eventType,
+		Source:   "balance",
+		Priority: priority,
+		Data: map[string]interface{}{
+			"channel":          r.Channel,
+			"balance":          r.Balance,
+			"unit":             r.Unit,
+			"threshold":        alert.Probe.LowThreshold,
+			"exclude_when_low": alert.Probe.ExcludeWhenLow,
+			"diverted_to":      divertedTo,
+			"timestamp":        r.CheckedAt.Format("2006-01-02 15:04:05"),
+		},
+	})
+}
+
+// divertBudgetChannel 预算超限（divert 动作）时将流量切出活跃渠道，返回切换到的渠道（未切换时为空）
+// 渠道/端点预算仅在其渠道活跃时切换（端点预算需渠道内所有端点均被阻断）；全局/模型预算从当前活跃渠道切换
+func divertBudgetChannel(endpointManager *endpoint.Manager, usageTracker *tracking.UsageTracker, st tracking.BudgetStatus) string {
+	fromChannel := st.Channel
+	switch st.Scope {
+	case tracking.BudgetScopeGlobal, tracking.BudgetScopeModel:
+		fromChannel = ""
+		if gm := endpointManager.GetGroupManager(); gm != nil {
+			if groups := gm.GetActiveGroups(); len(groups) > 0 {
+				fromChannel = groups[0].Name
+			}
+		}
+	case tracking.BudgetScopeEndpoint:
+		if !endpointManager.ChannelEndpointsBlockedByBudget(st.Channel) {
+			return ""
+		}
+	}
+	if fromChannel == "" {
+		return ""
+	}
+
+	model := st.Model
+	if model == "" && st.DivertChannel == "" && usageTracker != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		model = usageTracker.BudgetTopModel(ctx, st)
+		cancel()
+	}
+
+	target, err := endpointManager.DivertChannel(fromChannel, st.DivertChannel, model, st.Name)
+	if err != nil {
+		slog.Warn(fmt.Sprintf("⚠️ [预算分流] 预算 %s 分流失败，超限期间请求将被拒绝: %v", st.Name, err))
+	}
+	// 记录实际分流到的渠道，全局/模型预算仍覆盖该渠道，需由 CheckBudget 放行
+	usageTracker.RecordBudgetDivert(st.Name, target)
+	return target
+}
+
 // getEffectiveUsageDBPath returns the single SQLite database path used by:
 // - usage tracker (request_logs / usage_summary / ...)
 // - management stores (channels/endpoints/settings/model_pricing)
@@ -832,6 +1348,8 @@ func (a *App) setupUsageTracker() {
 		CleanupInterval: a.config.UsageTracking.CleanupInterval,
 		ModelPricing:    nil,                     // v5.0+: 定价从 SQLite model_pricing 表加载
 		DefaultPricing:  tracking.ModelPricing{}, // v5.0+: 默认定价从 SQLite 加载
+
+		ReportingCurrency: a.config.UsageTracking.ReportingCurrency,
 	}
 
 	var err error
@@ -1279,6 +1797,16 @@ func (a *App) setupProxyHandler() {
 			retryHandler.SetUsageTracker(a.usageTracker)
 		}
 	}
+
+	// Message Batches：记录批处理所属端点，后续请求固定转发并按批处理价格计费
+	db := a.storeDB
+	if db == nil && a.usageTracker != nil {
+		db = a.usageTracker.GetDB()
+	}
+	if db != nil {
+		a.messageBatchStore = store.NewSQLiteMessageBatchStore(db)
+		a.proxyHandler.SetMessageBatchStore(a.messageBatchStore)
+	}
 }
 
 // startProxyServer 启动 HTTP 代理服务器
@@ -1308,6 +1836,14 @@ func (a *App) startProxyServer() {
 	// 注册代理处理器
 	mux.Handle("/", a.loggingMiddleware.Wrap(a.authMiddleware.Wrap(a.proxyHandler)))
 
+	// 监听非本机地址时确保已启用鉴权
+	if err := a.enforceInboundAuth(a.config); err != nil {
+		a.logger.Error("❌ 代理服务器拒绝启动", "error", err)
+		a.emitError("代理服务器启动失败", err.Error())
+		return
+	}
+	a.authMiddleware.UpdateConfig(a.config.Auth)
+
 	// v5.1+ 端口探测：自动寻找可用端口
 	var actualPort int
 	var listener net.Listener
@@ -1315,7 +1851,7 @@ func (a *App) startProxyServer() {
 
 	if a.portManager != nil {
 		// 使用 PortManager 进行端口探测
-		listener, actualPort, err = utils.FindAndBind(a.portManager.GetPreferredPort(), 10)
+		listener, actualPort, err = utils.FindAndBind(a.config.Server.Host, a.portManager.GetPreferredPort(), 10)
 		if err != nil {
 			a.logger.Error("❌ 无法找到可用端口", "error", err)
 			a.emitError("代理服务器启动失败", "无法找到可用端口: "+err.Error())
@@ -1338,7 +1874,7 @@ func (a *App) startProxyServer() {
 	a.config.Server.Port = actualPort
 
 	a.proxyServer = &http.Server{
-		Handler:      mux,
+		Handler:      a.authMiddleware.FilterIP(mux),
 		ReadTimeout:  60 * time.Second,
 		WriteTimeout: 0, // 流式请求禁用写入超时
 		IdleTimeout:  120 * time.Second,
@@ -1371,12 +1907,48 @@ func (a *App) startProxyServer() {
 				portInfo.PreferredPort, portInfo.ActualPort))
 		}
 	}
+}
 
-	// 安全警告
-	if a.config.Server.Host != "127.0.0.1" && a.config.Server.Host != "localhost" && a.config.Server.Host != "::1" {
-		if !a.config.Auth.Enabled {
-			a.logger.Warn("⚠️  安全警告：服务器绑定到非本地地址但未启用鉴权！")
-		}
+// enforceInboundAuth 监听非本机地址时确保入站鉴权已启用
+// 按 auth.non_loopback_policy 拒绝启动或自动生成 Token（重载与重启时复用已保存的 Token）
+// 生成的 Token 保存到鉴权设置，不写入日志，前端通过 GetInboundAuthToken 查看
+func (a *App) enforceInboundAuth(cfg *config.Config) error {
+	previousToken := a.generatedAuthToken
+	if previousToken == "" && a.settingsService != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		previousToken = a.getSettingString(ctx, service.CategoryAuth, "token", "")
+		cancel()
+	}
+
+	token, err := middleware.EnforceListenerAuth(cfg.Server.Host, &cfg.Auth, previousToken)
+	if err != nil {
+		return err
+	}
+	if token != "" && token != a.generatedAuthToken {
+		a.generatedAuthToken = token
+		a.persistGeneratedAuthToken(token)
+		a.logger.Warn(fmt.Sprintf("🔐 服务器监听非本机地址 %s 但未启用鉴权，已自动启用鉴权并生成访问 Token（在「设置 → 访问鉴权」中查看）", cfg.Server.Host))
+	}
+	return nil
+}
+
+// persistGeneratedAuthToken 将自动生成的 Token 写入鉴权设置，重启后继续使用同一 Token
+func (a *App) persistGeneratedAuthToken(token string) {
+	if a.settingsStore == nil {
+		a.logger.Warn("⚠️ 设置存储未启用，自动生成的访问 Token 仅在本次运行有效")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// 直接写存储，不触发设置热更新回调（调用方正在应用配置）
+	records := []*store.SettingRecord{
+		{Category: service.CategoryAuth, Key: "enabled", Value: "true"},
+		{Category: service.CategoryAuth, Key: "token", Value: token},
+	}
+	if err := a.settingsStore.BatchUpdateValues(ctx, records); err != nil {
+		a.logger.Warn("⚠️ 保存自动生成的访问 Token 失败，重启后将重新生成", "error", err)
 	}
 }
 
@@ -1405,7 +1977,12 @@ func (a *App) setupConfigReload() {
 		a.configWatcher.UpdateLogger(newLogger)
 		a.endpointManager.UpdateConfig(newCfg)
 		a.proxyHandler.UpdateConfig(newCfg)
-		a.authMiddleware.UpdateConfig(newCfg.Auth)
+		if err := a.enforceInboundAuth(newCfg); err != nil {
+			// 服务已在运行，保留原鉴权配置，避免热重载关闭鉴权
+			a.logger.Error("❌ 新配置未启用鉴权，保留原鉴权配置", "error", err)
+		} else {
+			a.authMiddleware.UpdateConfig(newCfg.Auth)
+		}
 
 		// v5.0+ 注意：模型定价不再从 config.yaml 热重载
 		// 定价配置通过前端「定价」页面管理，存储在 SQLite model_pricing 表中
@@ -1422,6 +1999,23 @@ func (a *App) setupConfigReload() {
 // setupEventBridges 设置事件桥接
 // 将内部 EventBus 事件转发到 Wails 前端
 func (a *App) setupEventBridges() {
+	// 使用记录写入数据库失败 → EventBus（Webhook 告警）
+	if a.usageTracker != nil && a.eventBus != nil {
+		eventBus := a.eventBus
+		a.usageTracker.SetDatabaseErrorHandler(func(operation string, err error) {
+			eventBus.Publish(events.Event{
+				Type:     events.EventDatabaseError,
+				Source:   "usage_tracker",
+				Priority: events.PriorityCritical,
+				Data: map[string]interface{}{
+					"operation": operation,
+					"error":     err.Error(),
+					"timestamp": time.Now().Format("2006-01-02 15:04:05"),
+				},
+			})
+		})
+	}
+
 	// 注意：当前 EventBus 实现不支持订阅回调
 	// 我们使用定时轮询来更新前端状态
 	go func() {
@@ -1568,6 +2162,8 @@ func (a *App) applySettingsToConfig() {
 	a.config.Health.CheckInterval = a.settingsService.GetDuration(ctx, service.CategoryHealth, "check_interval", a.config.Health.CheckInterval)
 	a.config.Health.Timeout = a.settingsService.GetDuration(ctx, service.CategoryHealth, "timeout", a.config.Health.Timeout)
 	a.config.Health.HealthPath = a.getSettingString(ctx, service.CategoryHealth, "health_path", a.config.Health.HealthPath)
+	a.config.ModelDiscovery.Enabled = a.settingsService.GetBool(ctx, service.CategoryHealth, "model_discovery_enabled", a.config.ModelDiscovery.Enabled)
+	a.config.ModelDiscovery.Interval = a.settingsService.GetDuration(ctx, service.CategoryHealth, "model_discovery_interval", a.config.ModelDiscovery.Interval)
 
 	// 故障转移配置
 	a.config.Failover.Enabled = a.settingsService.GetBool(ctx, service.CategoryFailover, "enabled", a.config.Failover.Enabled)
@@ -1589,6 +2185,9 @@ func (a *App) applySettingsToConfig() {
 	// 访问控制配置
 	a.config.Auth.Enabled = a.settingsService.GetBool(ctx, service.CategoryAuth, "enabled", a.config.Auth.Enabled)
 	a.config.Auth.Token = a.getSettingString(ctx, service.CategoryAuth, "token", a.config.Auth.Token)
+	a.config.Auth.Schemes = splitSettingList(a.getSettingString(ctx, service.CategoryAuth, "schemes", strings.Join(a.config.Auth.Schemes, ",")))
+	a.config.Auth.AllowCIDRs = splitSettingList(a.getSettingString(ctx, service.CategoryAuth, "allow_cidrs", strings.Join(a.config.Auth.AllowCIDRs, ",")))
+	a.config.Auth.DenyCIDRs = splitSettingList(a.getSettingString(ctx, service.CategoryAuth, "deny_cidrs", strings.Join(a.config.Auth.DenyCIDRs, ",")))
 
 	// Token 计数配置
 	a.config.TokenCounting.Enabled = a.settingsService.GetBool(ctx, service.CategoryTokenCounting, "enabled", a.config.TokenCounting.Enabled)
@@ -1608,8 +2207,23 @@ func (a *App) applySettingsToConfig() {
 		a.proxyHandler.UpdateConfig(a.config)
 	}
 	if a.authMiddleware != nil {
-		a.authMiddleware.UpdateConfig(a.config.Auth)
+		if err := a.enforceInboundAuth(a.config); err != nil {
+			a.logger.Error("❌ 当前设置未启用鉴权，保留原鉴权配置", "error", err)
+		} else {
+			a.authMiddleware.UpdateConfig(a.config.Auth)
+		}
+	}
+}
+
+// splitSettingList 解析逗号分隔的设置值
+func splitSettingList(value string) []string {
+	var result []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
 	}
+	return result
 }
 
 // getSettingString 获取字符串设置值（带默认值）
@@ -16,6 +16,8 @@ import (
 	"net"
 	"sync"
 	"time"
+
+	"cc-forwarder/internal/tracking"
 )
 
 // 端口检测缓存（避免频繁 TCP 连接）
@@ -47,6 +49,12 @@ type SystemStatus struct {
 	ActiveGroup   string `json:"active_group"`
 	ConfigPath    string `json:"config_path"`
 	AuthEnabled   bool   `json:"auth_enabled"`
+
+	// 监听非本机地址且未配置鉴权时自动生成了访问 Token（通过 GetInboundAuthToken 查看）
+	AuthTokenGenerated bool `json:"auth_token_generated"`
+
+	// 花费预算状态（按占用百分比降序）
+	Budgets []tracking.BudgetStatus `json:"budgets,omitempty"`
 }
 
 // GetSystemStatus 获取系统状态
@@ -69,6 +77,7 @@ func (a *App) GetSystemStatus() SystemStatus {
 		status.ProxyPort = a.config.Server.Port
 		status.ProxyHost = a.config.Server.Host
 		status.AuthEnabled = a.config.Auth.Enabled
+		status.AuthTokenGenerated = a.generatedAuthToken != "" && a.config.Auth.Token == a.generatedAuthToken
 
 		// 真正检测端口是否在监听
 		if status.ProxyRunning {
@@ -86,9 +95,25 @@ func (a *App) GetSystemStatus() SystemStatus {
 		}
 	}
 
+	if a.usageTracker != nil {
+		status.Budgets = a.usageTracker.BudgetStatuses()
+	}
+
 	return status
 }
 
+// GetInboundAuthToken 获取当前生效的入站访问 Token（含自动生成的 Token）
+// Token 不写入日志与状态推送，仅通过此接口按需获取
+func (a *App) GetInboundAuthToken() (string, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	if a.config == nil || !a.config.Auth.Enabled || a.config.Auth.Token == "" {
+		return "", fmt.Errorf("入站鉴权未启用")
+	}
+	return a.config.Auth.Token, nil
+}
+
 // checkPortListening 检测端口是否在监听（带缓存，避免频繁 TCP 连接）
 func (a *App) checkPortListening(host string, port int) bool {
 	// 使用 net.JoinHostPort 正确处理 IPv6 地址
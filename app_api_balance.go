@@ -0,0 +1,245 @@
+// app_api_balance.go - 渠道余额探测 API (Wails Bindings)
+// 中转渠道余额/额度定时探测：余额时间序列、低余额告警，可选排除出故障转移候选
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"cc-forwarder/internal/balance"
+	"cc-forwarder/internal/store"
+)
+
+// ChannelBalanceProbeInfo 渠道余额探测配置（配置 + 当前状态，给前端用的结构体）
+type ChannelBalanceProbeInfo struct {
+	ID              int64   `json:"id"`
+	Channel         string  `json:"channel"`
+	URL             string  `json:"url"`
+	AuthHeader      string  `json:"auth_header"`
+	AuthToken       string  `json:"auth_token"`        // 本地桌面应用，直接返回原始值
+	AuthTokenMasked string  `json:"auth_token_masked"` // 脱敏后的认证值（列表展示用）
+	BalancePath     string  `json:"balance_path"`
+	Unit            string  `json:"unit"`
+	Scale           float64 `json:"scale"`
+	LowThreshold    float64 `json:"low_threshold"`
+	ExcludeWhenLow  bool    `json:"exclude_when_low"`
+	IntervalSeconds int     `json:"interval_seconds"`
+	Enabled         bool    `json:"enabled"`
+	UpdatedAt       string  `json:"updated_at"`
+
+	// 当前余额状态（未启用的探测为空）
+	Status *balance.Status `json:"status,omitempty"`
+}
+
+// SaveChannelBalanceProbeInput 保存渠道余额探测配置的输入参数
+type SaveChannelBalanceProbeInput struct {
+	Channel         string  `json:"channel"`
+	URL             string  `json:"url"`
+	AuthHeader      string  `json:"auth_header"`
+	AuthToken       string  `json:"auth_token"` // 更新时为空表示保留原有值
+	BalancePath     string  `json:"balance_path"`
+	Unit            string  `json:"unit"`
+	Scale           float64 `json:"scale"`
+	LowThreshold    float64 `json:"low_threshold"`
+	ExcludeWhenLow  bool    `json:"exclude_when_low"`
+	IntervalSeconds int     `json:"interval_seconds"`
+	Enabled         bool    `json:"enabled"`
+}
+
+// ChannelBalancePoint 渠道余额时间序列中的一个点
+type ChannelBalancePoint struct {
+	Channel   string  `json:"channel"`
+	Balance   float64 `json:"balance"`
+	RawValue  float64 `json:"raw_value"`
+	Unit      string  `json:"unit"`
+	Low       bool    `json:"low"`
+	Error     string  `json:"error"`
+	CheckedAt string  `json:"checked_at"`
+}
+
+// GetChannelBalanceProbes 获取所有渠道余额探测配置及当前余额
+func (a *App) GetChannelBalanceProbes() ([]ChannelBalanceProbeInfo, error) {
+	a.mu.RLock()
+	balanceService := a.channelBalanceService
+	poller := a.balancePoller
+	a.mu.RUnlock()
+
+	if balanceService == nil {
+		return nil, fmt.Errorf("余额探测存储未就绪")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	records, err := balanceService.ListProbes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make(map[string]balance.Status)
+	if poller != nil {
+		for _, st := range poller.Statuses() {
+			statuses[st.Channel] = st
+		}
+	}
+
+	result := make([]ChannelBalanceProbeInfo, 0, len(records))
+	for _, r := range records {
+		info := ChannelBalanceProbeInfo{
+			ID:              r.ID,
+			Channel:         r.Channel,
+			URL:             r.URL,
+			AuthHeader:      r.AuthHeader,
+			AuthToken:       r.AuthToken,
+			AuthTokenMasked: maskToken(r.AuthToken),
+			BalancePath:     r.BalancePath,
+			Unit:            r.Unit,
+			Scale:           r.Scale,
+			LowThreshold:    r.LowThreshold,
+			ExcludeWhenLow:  r.ExcludeWhenLow,
+			IntervalSeconds: r.IntervalSeconds,
+			Enabled:         r.Enabled,
+			UpdatedAt:       r.UpdatedAt.Format("2006-01-02 15:04:05"),
+		}
+		if st, ok := statuses[r.Channel]; ok {
+			st := st
+			info.Status = &st
+		}
+		result = append(result, info)
+	}
+	return result, nil
+}
+
+// SaveChannelBalanceProbe 保存渠道余额探测配置（不存在时创建）
+func (a *App) SaveChannelBalanceProbe(input SaveChannelBalanceProbeInput) error {
+	a.mu.RLock()
+	balanceService := a.channelBalanceService
+	logger := a.logger
+	a.mu.RUnlock()
+
+	if balanceService == nil {
+		return fmt.Errorf("余额探测存储未就绪")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	record := input.toRecord()
+	// 前端传空值时保留原有认证值（防止误删）
+	if record.AuthToken == "" {
+		existing, err := balanceService.GetProbe(ctx, strings.TrimSpace(input.Channel))
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			record.AuthToken = existing.AuthToken
+		}
+	}
+	if err := balanceService.SaveProbe(ctx, record); err != nil {
+		return err
+	}
+
+	a.syncBalanceProbesToPoller(ctx)
+	if logger != nil {
+		logger.Info("✅ 余额探测配置已保存", "channel", record.Channel)
+	}
+	return nil
+}
+
+// DeleteChannelBalanceProbe 删除渠道余额探测配置（余额历史保留）
+func (a *App) DeleteChannelBalanceProbe(channel string) error {
+	a.mu.RLock()
+	balanceService := a.channelBalanceService
+	logger := a.logger
+	a.mu.RUnlock()
+
+	if balanceService == nil {
+		return fmt.Errorf("余额探测存储未就绪")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := balanceService.DeleteProbe(ctx, channel); err != nil {
+		return err
+	}
+
+	a.syncBalanceProbesToPoller(ctx)
+	if logger != nil {
+		logger.Info("🗑️ 余额探测配置已删除", "channel", channel)
+	}
+	return nil
+}
+
+// RefreshChannelBalance 立即探测渠道余额（结果写入余额历史，并按阈值更新告警/排除状态）
+func (a *App) RefreshChannelBalance(channel string) (*balance.Result, error) {
+	a.mu.RLock()
+	poller := a.balancePoller
+	a.mu.RUnlock()
+
+	if poller == nil {
+		return nil, fmt.Errorf("余额探测未就绪")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	result, err := poller.Refresh(ctx, channel)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// GetChannelBalanceHistory 获取渠道余额时间序列（channel 为空时返回所有渠道，days <= 0 时不限时间）
+func (a *App) GetChannelBalanceHistory(channel string, days int) ([]ChannelBalancePoint, error) {
+	a.mu.RLock()
+	balanceService := a.channelBalanceService
+	a.mu.RUnlock()
+
+	if balanceService == nil {
+		return nil, fmt.Errorf("余额探测存储未就绪")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	records, err := balanceService.ListHistory(ctx, channel, days, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]ChannelBalancePoint, 0, len(records))
+	for _, r := range records {
+		result = append(result, ChannelBalancePoint{
+			Channel:   r.Channel,
+			Balance:   r.Balance,
+			RawValue:  r.RawValue,
+			Unit:      r.Unit,
+			Low:       r.Low,
+			Error:     r.Error,
+			CheckedAt: r.CheckedAt.Format("2006-01-02 15:04:05"),
+		})
+	}
+	return result, nil
+}
+
+// toRecord 转换为存储记录
+func (in SaveChannelBalanceProbeInput) toRecord() *store.ChannelBalanceProbeRecord {
+	return &store.ChannelBalanceProbeRecord{
+		Channel:         in.Channel,
+		URL:             in.URL,
+		AuthHeader:      in.AuthHeader,
+		AuthToken:       in.AuthToken,
+		BalancePath:     in.BalancePath,
+		Unit:            in.Unit,
+		Scale:           in.Scale,
+		LowThreshold:    in.LowThreshold,
+		ExcludeWhenLow:  in.ExcludeWhenLow,
+		IntervalSeconds: in.IntervalSeconds,
+		Enabled:         in.Enabled,
+	}
+}
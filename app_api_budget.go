@@ -0,0 +1,284 @@
+// app_api_budget.go - 花费预算管理 API (Wails Bindings)
+// 全局/渠道/端点/模型级别的日/周/月预算：阈值告警，超限后拒绝、分流或等待手动放行
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"cc-forwarder/internal/store"
+	"cc-forwarder/internal/tracking"
+)
+
+// BudgetInfo 预算信息（配置 + 当前状态，给前端用的结构体）
+type BudgetInfo struct {
+	ID              int64     `json:"id"`
+	Name            string    `json:"name"`
+	Scope           string    `json:"scope"`
+	Channel         string    `json:"channel"`
+	EndpointName    string    `json:"endpoint_name"`
+	ModelName       string    `json:"model_name"`
+	Window          string    `json:"window"`
+	LimitUSD        float64   `json:"limit_usd"`
+	AlertThresholds []float64 `json:"alert_thresholds"`
+	Action          string    `json:"action"`
+	DivertChannel   string    `json:"divert_channel"`
+	TrippedAt       string    `json:"tripped_at"`
+	OverrideUntil   string    `json:"override_until"`
+	Enabled         bool      `json:"enabled"`
+	Description     string    `json:"description"`
+	UpdatedAt       string    `json:"updated_at"`
+
+	// 当前窗口状态（未启用的预算为空）
+	Status *tracking.BudgetStatus `json:"status,omitempty"`
+}
+
+// SaveBudgetInput 创建/更新预算的输入参数
+type SaveBudgetInput struct {
+	Name            string    `json:"name"`
+	Scope           string    `json:"scope"`
+	Channel         string    `json:"channel"`
+	EndpointName    string    `json:"endpoint_name"`
+	ModelName       string    `json:"model_name"`
+	Window          string    `json:"window"`
+	LimitUSD        float64   `json:"limit_usd"`
+	AlertThresholds []float64 `json:"alert_thresholds"`
+	Action          string    `json:"action"`
+	DivertChannel   string    `json:"divert_channel"`
+	Enabled         bool      `json:"enabled"`
+	Description     string    `json:"description"`
+}
+
+// GetBudgets 获取所有预算及其当前窗口的花费状态
+func (a *App) GetBudgets() ([]BudgetInfo, error) {
+	a.mu.RLock()
+	budgetService := a.budgetService
+	usageTracker := a.usageTracker
+	a.mu.RUnlock()
+
+	if budgetService == nil {
+		return nil, fmt.Errorf("预算存储未就绪")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	records, err := budgetService.ListBudgets(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make(map[string]tracking.BudgetStatus)
+	if usageTracker != nil {
+		for _, st := range usageTracker.BudgetStatuses() {
+			statuses[st.Name] = st
+		}
+	}
+
+	result := make([]BudgetInfo, 0, len(records))
+	for _, r := range records {
+		info := budgetRecordToInfo(r)
+		if st, ok := statuses[r.Name]; ok && r.Enabled {
+			info.Status = &st
+		}
+		result = append(result, info)
+	}
+	return result, nil
+}
+
+// CreateBudget 创建预算
+func (a *App) CreateBudget(input SaveBudgetInput) (*BudgetInfo, error) {
+	a.mu.RLock()
+	budgetService := a.budgetService
+	logger := a.logger
+	a.mu.RUnlock()
+
+	if budgetService == nil {
+		return nil, fmt.Errorf("预算存储未就绪")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	created, err := budgetService.CreateBudget(ctx, input.toRecord())
+	if err != nil {
+		return nil, err
+	}
+
+	a.syncBudgetsToTracker(ctx)
+	if logger != nil {
+		logger.Info("✅ 预算已创建", "name", created.Name, "scope", created.Scope, "limit_usd", created.LimitUSD)
+	}
+
+	info := budgetRecordToInfo(created)
+	return &info, nil
+}
+
+// UpdateBudget 更新预算（按名称定位，不影响超限/放行状态）
+func (a *App) UpdateBudget(name string, input SaveBudgetInput) error {
+	a.mu.RLock()
+	budgetService := a.budgetService
+	logger := a.logger
+	a.mu.RUnlock()
+
+	if budgetService == nil {
+		return fmt.Errorf("预算存储未就绪")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	record := input.toRecord()
+	record.Name = name
+	if err := budgetService.UpdateBudget(ctx, record); err != nil {
+		return err
+	}
+
+	a.syncBudgetsToTracker(ctx)
+	if logger != nil {
+		logger.Info("✅ 预算已更新", "name", name)
+	}
+	return nil
+}
+
+// DeleteBudget 删除预算
+func (a *App) DeleteBudget(name string) error {
+	a.mu.RLock()
+	budgetService := a.budgetService
+	logger := a.logger
+	a.mu.RUnlock()
+
+	if budgetService == nil {
+		return fmt.Errorf("预算存储未就绪")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := budgetService.DeleteBudget(ctx, name); err != nil {
+		return err
+	}
+
+	a.syncBudgetsToTracker(ctx)
+	if logger != nil {
+		logger.Info("🗑️ 预算已删除", "name", name)
+	}
+	return nil
+}
+
+// OverrideBudget 手动放行超出预算的请求，直到当前统计窗口结束
+func (a *App) OverrideBudget(name string) error {
+	a.mu.RLock()
+	budgetService := a.budgetService
+	usageTracker := a.usageTracker
+	logger := a.logger
+	a.mu.RUnlock()
+
+	if budgetService == nil {
+		return fmt.Errorf("预算存储未就绪")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	records, err := budgetService.ListBudgets(ctx)
+	if err != nil {
+		return err
+	}
+	var record *store.BudgetRecord
+	for _, r := range records {
+		if r.Name == name {
+			record = r
+			break
+		}
+	}
+	if record == nil {
+		return fmt.Errorf("预算不存在: %s", name)
+	}
+
+	_, until := tracking.BudgetWindow(record.Window, time.Now())
+	if usageTracker != nil {
+		_, until = usageTracker.CurrentBudgetWindow(record.Window)
+	}
+	if err := budgetService.OverrideBudget(ctx, name, until); err != nil {
+		return err
+	}
+
+	a.syncBudgetsToTracker(ctx)
+	if logger != nil {
+		logger.Info("🔓 预算已手动放行", "name", name, "until", until.Format("2006-01-02 15:04:05"))
+	}
+	return nil
+}
+
+// ClearBudgetOverride 取消预算的手动放行
+func (a *App) ClearBudgetOverride(name string) error {
+	a.mu.RLock()
+	budgetService := a.budgetService
+	a.mu.RUnlock()
+
+	if budgetService == nil {
+		return fmt.Errorf("预算存储未就绪")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := budgetService.ClearOverride(ctx, name); err != nil {
+		return err
+	}
+
+	a.syncBudgetsToTracker(ctx)
+	return nil
+}
+
+// toRecord 转换为存储记录
+func (in SaveBudgetInput) toRecord() *store.BudgetRecord {
+	return &store.BudgetRecord{
+		Name:            in.Name,
+		Scope:           in.Scope,
+		Channel:         in.Channel,
+		EndpointName:    in.EndpointName,
+		ModelName:       in.ModelName,
+		Window:          in.Window,
+		LimitUSD:        in.LimitUSD,
+		AlertThresholds: in.AlertThresholds,
+		Action:          in.Action,
+		DivertChannel:   in.DivertChannel,
+		Enabled:         in.Enabled,
+		Description:     in.Description,
+	}
+}
+
+// budgetRecordToInfo 转换预算记录为前端结构
+func budgetRecordToInfo(r *store.BudgetRecord) BudgetInfo {
+	thresholds := r.AlertThresholds
+	if thresholds == nil {
+		thresholds = []float64{}
+	}
+	info := BudgetInfo{
+		ID:              r.ID,
+		Name:            r.Name,
+		Scope:           r.Scope,
+		Channel:         r.Channel,
+		EndpointName:    r.EndpointName,
+		ModelName:       r.ModelName,
+		Window:          r.Window,
+		LimitUSD:        r.LimitUSD,
+		AlertThresholds: thresholds,
+		Action:          r.Action,
+		DivertChannel:   r.DivertChannel,
+		Enabled:         r.Enabled,
+		Description:     r.Description,
+		UpdatedAt:       r.UpdatedAt.Format("2006-01-02 15:04:05"),
+	}
+	if r.TrippedAt != nil {
+		info.TrippedAt = r.TrippedAt.Format("2006-01-02 15:04:05")
+	}
+	if r.OverrideUntil != nil {
+		info.OverrideUntil = r.OverrideUntil.Format("2006-01-02 15:04:05")
+	}
+	return info
+}
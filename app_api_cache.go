@@ -0,0 +1,184 @@
+// app_api_cache.go - 提示缓存效率分析 API (Wails Bindings)
+// 缓存命中率、相对未缓存价格的节省、未被读取的缓存写入，以及缓存抖动（路由切换导致反复写入）检测
+
+package main
+
+import (
+	"context"
+	"time"
+
+	"cc-forwarder/internal/tracking"
+)
+
+// cacheAnalyticsDefaultDays 缓存分析未指定时间范围时的默认回溯天数
+const cacheAnalyticsDefaultDays = 7
+
+// CacheAnalyticsQueryParams 缓存效率查询参数
+type CacheAnalyticsQueryParams struct {
+	GroupBy   string   `json:"group_by"`   // model（默认）/ endpoint / channel / session / hour
+	StartDate string   `json:"start_date"` // 格式：2025-12-05T00:00 或 2025-12-05T00:00:00+08:00，默认最近 7 天
+	EndDate   string   `json:"end_date"`   // 格式：2025-12-05T23:59 或 2025-12-05T23:59:59+08:00
+	Model     string   `json:"model"`      // 可选：模型名称
+	Channel   string   `json:"channel"`    // 可选：渠道名称
+	Endpoint  string   `json:"endpoint"`   // 可选：端点名称
+	SessionID string   `json:"session_id"` // 可选：会话标识
+	Tags      []string `json:"tags"`       // 可选：标签筛选，格式 key=value
+}
+
+// CacheEfficiencyResult 缓存效率分析结果
+type CacheEfficiencyResult struct {
+	GroupBy string                     `json:"group_by"`
+	Summary tracking.CacheEfficiency   `json:"summary"` // 整体汇总（Key 为空）
+	Items   []tracking.CacheEfficiency `json:"items"`
+}
+
+// CacheThrashQueryParams 缓存抖动检测参数
+type CacheThrashQueryParams struct {
+	StartDate         string  `json:"start_date"`          // 默认最近 7 天
+	EndDate           string  `json:"end_date"`            // 默认今天结束
+	Model             string  `json:"model"`               // 可选：模型名称
+	Channel           string  `json:"channel"`             // 可选：渠道名称
+	WindowMinutes     int     `json:"window_minutes"`      // 检测窗口（分钟），默认 60
+	MinCreationTokens int64   `json:"min_creation_tokens"` // 窗口内缓存写入 token 下限，默认 100000
+	CreationReadRatio float64 `json:"creation_read_ratio"` // 写入/读取比值阈值，默认 1
+}
+
+// CacheThrashInfo 缓存抖动时段（给前端用的结构体）
+type CacheThrashInfo struct {
+	StartTime           string   `json:"start_time"`
+	EndTime             string   `json:"end_time"`
+	RequestCount        int      `json:"request_count"`
+	CacheCreationTokens int64    `json:"cache_creation_tokens"`
+	CacheReadTokens     int64    `json:"cache_read_tokens"`
+	CreationReadRatio   float64  `json:"creation_read_ratio"`
+	CacheWriteCost      float64  `json:"cache_write_cost"`
+	UnreadWriteCost     float64  `json:"unread_write_cost"`
+	Endpoints           []string `json:"endpoints"`
+	EndpointSwitches    int      `json:"endpoint_switches"`
+}
+
+// GetCacheEfficiency 获取提示缓存效率（按模型/端点/渠道/会话/小时分组）
+func (a *App) GetCacheEfficiency(params CacheAnalyticsQueryParams) (CacheEfficiencyResult, error) {
+	a.mu.RLock()
+	usageTracker := a.usageTracker
+	cfg := a.config
+	a.mu.RUnlock()
+
+	groupBy := params.GroupBy
+	if groupBy == "" {
+		groupBy = tracking.CacheGroupByModel
+	}
+	if usageTracker == nil {
+		return CacheEfficiencyResult{GroupBy: groupBy, Items: []tracking.CacheEfficiency{}}, nil
+	}
+
+	loc := time.Local
+	if cfg != nil && cfg.Timezone != "" {
+		if l, err := time.LoadLocation(cfg.Timezone); err == nil {
+			loc = l
+		}
+	}
+	startTime, endTime := cacheAnalyticsRange(params.StartDate, params.EndDate, loc)
+
+	ctx, cancel := context.WithTimeout(context.Background(), usageDBQueryTimeout)
+	defer cancel()
+
+	items, err := usageTracker.QueryCacheEfficiency(ctx, &tracking.CacheAnalyticsOptions{
+		StartDate:    &startTime,
+		EndDate:      &endTime,
+		GroupBy:      groupBy,
+		ModelName:    params.Model,
+		Channel:      params.Channel,
+		EndpointName: params.Endpoint,
+		SessionID:    params.SessionID,
+		Tags:         parseTagFilters(params.Tags),
+	})
+	if err != nil {
+		return CacheEfficiencyResult{}, err
+	}
+	if items == nil {
+		items = []tracking.CacheEfficiency{}
+	}
+
+	return CacheEfficiencyResult{
+		GroupBy: groupBy,
+		Summary: tracking.SummarizeCacheEfficiency(items),
+		Items:   items,
+	}, nil
+}
+
+// GetCacheThrashPeriods 检测缓存抖动时段（缓存写入压过读取，通常意味着路由在端点间来回切换）
+func (a *App) GetCacheThrashPeriods(params CacheThrashQueryParams) ([]CacheThrashInfo, error) {
+	a.mu.RLock()
+	usageTracker := a.usageTracker
+	cfg := a.config
+	a.mu.RUnlock()
+
+	if usageTracker == nil {
+		return []CacheThrashInfo{}, nil
+	}
+
+	loc := time.Local
+	if cfg != nil && cfg.Timezone != "" {
+		if l, err := time.LoadLocation(cfg.Timezone); err == nil {
+			loc = l
+		}
+	}
+	startTime, endTime := cacheAnalyticsRange(params.StartDate, params.EndDate, loc)
+
+	ctx, cancel := context.WithTimeout(context.Background(), usageDBQueryTimeout)
+	defer cancel()
+
+	periods, err := usageTracker.DetectCacheThrash(ctx, &tracking.CacheThrashOptions{
+		StartDate:         &startTime,
+		EndDate:           &endTime,
+		ModelName:         params.Model,
+		Channel:           params.Channel,
+		Window:            time.Duration(params.WindowMinutes) * time.Minute,
+		MinCreationTokens: params.MinCreationTokens,
+		CreationReadRatio: params.CreationReadRatio,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]CacheThrashInfo, 0, len(periods))
+	for _, p := range periods {
+		result = append(result, CacheThrashInfo{
+			StartTime:           p.StartTime.Format("2006-01-02 15:04:05"),
+			EndTime:             p.EndTime.Format("2006-01-02 15:04:05"),
+			RequestCount:        p.RequestCount,
+			CacheCreationTokens: p.CacheCreationTokens,
+			CacheReadTokens:     p.CacheReadTokens,
+			CreationReadRatio:   p.CreationReadRatio,
+			CacheWriteCost:      p.CacheWriteCostUSD,
+			UnreadWriteCost:     p.UnreadWriteCostUSD,
+			Endpoints:           p.Endpoints,
+			EndpointSwitches:    p.EndpointSwitches,
+		})
+	}
+	return result, nil
+}
+
+// cacheAnalyticsRange 解析缓存分析时间范围（默认最近 7 天）
+func cacheAnalyticsRange(startDate, endDate string, loc *time.Location) (time.Time, time.Time) {
+	var startTime, endTime time.Time
+	if startDate != "" {
+		if t, err := parseTimeWithLocation(startDate, loc); err == nil {
+			startTime = t
+		}
+	}
+	if endDate != "" {
+		if t, err := parseTimeWithLocation(endDate, loc); err == nil {
+			endTime = t
+		}
+	}
+	now := time.Now().In(loc)
+	if startTime.IsZero() {
+		startTime = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc).AddDate(0, 0, -(cacheAnalyticsDefaultDays - 1))
+	}
+	if endTime.IsZero() {
+		endTime = time.Date(now.Year(), now.Month(), now.Day(), 23, 59, 59, 999999999, loc)
+	}
+	return startTime, endTime
+}
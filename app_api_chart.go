@@ -6,6 +6,8 @@ package main
 import (
 	"context"
 	"time"
+
+	"cc-forwarder/internal/tracking"
 )
 
 // ============================================================
@@ -225,13 +227,15 @@ func (a *App) GetEndpointHealthChart() EndpointHealthData {
 
 // EndpointCostItem 端点成本数据项（用于前端图表）
 type EndpointCostItem struct {
-	Name   string  `json:"name"`
-	Tokens int64   `json:"tokens"`
-	Cost   float64 `json:"cost"`
+	Name     string  `json:"name"`
+	Tokens   int64   `json:"tokens"`
+	Cost     float64 `json:"cost"`
+	Currency string  `json:"currency"` // 成本展示币种
 }
 
 // GetEndpointCosts 获取当日端点成本数据
-func (a *App) GetEndpointCosts() []EndpointCostItem {
+// currency 为展示币种（为空时使用报表币种）
+func (a *App) GetEndpointCosts(currency string) []EndpointCostItem {
 	a.mu.RLock()
 	usageTracker := a.usageTracker
 	logger := a.logger
@@ -248,7 +252,7 @@ func (a *App) GetEndpointCosts() []EndpointCostItem {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	costs, err := usageTracker.GetEndpointCostsForDate(ctx, date)
+	costs, err := usageTracker.GetEndpointCostsForDate(ctx, date, currency)
 	if err != nil {
 		if logger != nil {
 			logger.Error("获取端点成本数据失败", "error", err)
@@ -269,11 +273,154 @@ func (a *App) GetEndpointCosts() []EndpointCostItem {
 		totalTokens := cost.InputTokens + cost.OutputTokens + cost.CacheCreationTokens + cost.CacheReadTokens
 
 		result[i] = EndpointCostItem{
-			Name:   name,
-			Tokens: totalTokens,
-			Cost:   cost.TotalCostUSD,
+			Name:     name,
+			Tokens:   totalTokens,
+			Cost:     cost.TotalCost,
+			Currency: cost.Currency,
 		}
 	}
 
 	return result
 }
+
+// UsageTrendQueryParams 历史用量趋势查询参数
+type UsageTrendQueryParams struct {
+	Granularity string   `json:"granularity"` // hour（默认）/ day
+	StartDate   string   `json:"start_date"`  // 默认：hour 为最近 24 小时，day 为最近 30 天
+	EndDate     string   `json:"end_date"`    // 默认当前时间
+	Model       string   `json:"model"`       // 可选：模型名称
+	Channel     string   `json:"channel"`     // 可选：渠道名称
+	Endpoint    string   `json:"endpoint"`    // 可选：端点名称
+	Status      string   `json:"status"`      // 可选：状态（failed 表示全部失败状态）
+	Tags        []string `json:"tags"`        // 可选：标签筛选（有标签时改查明细表）
+}
+
+// GetUsageTrend 获取按小时/天聚合的历史用量趋势（长范围由用量汇总表回答）
+func (a *App) GetUsageTrend(params UsageTrendQueryParams) ([]tracking.UsageTrendPoint, error) {
+	a.mu.RLock()
+	usageTracker := a.usageTracker
+	cfg := a.config
+	a.mu.RUnlock()
+
+	if usageTracker == nil {
+		return []tracking.UsageTrendPoint{}, nil
+	}
+
+	granularity := params.Granularity
+	if granularity == "" {
+		granularity = tracking.UsageTrendHour
+	}
+
+	loc := time.Local
+	if cfg != nil && cfg.Timezone != "" {
+		if l, err := time.LoadLocation(cfg.Timezone); err == nil {
+			loc = l
+		}
+	}
+
+	var startTime, endTime time.Time
+	if params.StartDate != "" {
+		if t, err := parseTimeWithLocation(params.StartDate, loc); err == nil {
+			startTime = t
+		}
+	}
+	if params.EndDate != "" {
+		if t, err := parseTimeWithLocation(params.EndDate, loc); err == nil {
+			endTime = t
+		}
+	}
+	if endTime.IsZero() {
+		endTime = time.Now().In(loc)
+	}
+	if startTime.IsZero() {
+		if granularity == tracking.UsageTrendDay {
+			startTime = endTime.AddDate(0, 0, -29)
+		} else {
+			startTime = endTime.Add(-23 * time.Hour)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), usageDBQueryTimeout)
+	defer cancel()
+
+	points, err := usageTracker.QueryUsageTrend(ctx, &tracking.QueryOptions{
+		StartDate:    &startTime,
+		EndDate:      &endTime,
+		ModelName:    params.Model,
+		Channel:      params.Channel,
+		EndpointName: params.Endpoint,
+		Status:       params.Status,
+		Tags:         parseTagFilters(params.Tags),
+	}, granularity)
+	if err != nil {
+		return nil, err
+	}
+	if points == nil {
+		points = []tracking.UsageTrendPoint{}
+	}
+	return points, nil
+}
+
+// LatencyPercentilesQueryParams 响应时间分位数查询参数
+type LatencyPercentilesQueryParams struct {
+	GroupBy   string   `json:"group_by"`   // endpoint（默认）/ model
+	StartDate string   `json:"start_date"` // 默认最近 7 天
+	EndDate   string   `json:"end_date"`   // 默认当前时间
+	Model     string   `json:"model"`      // 可选：模型名称
+	Channel   string   `json:"channel"`    // 可选：渠道名称
+	Endpoint  string   `json:"endpoint"`   // 可选：端点名称
+	Tags      []string `json:"tags"`       // 可选：标签筛选
+}
+
+// GetLatencyPercentiles 获取按端点/模型分组的 TTFB、首 token、总耗时与输出速率分位数
+func (a *App) GetLatencyPercentiles(params LatencyPercentilesQueryParams) ([]tracking.LatencyPercentiles, error) {
+	a.mu.RLock()
+	usageTracker := a.usageTracker
+	cfg := a.config
+	a.mu.RUnlock()
+
+	if usageTracker == nil {
+		return []tracking.LatencyPercentiles{}, nil
+	}
+
+	loc := time.Local
+	if cfg != nil && cfg.Timezone != "" {
+		if l, err := time.LoadLocation(cfg.Timezone); err == nil {
+			loc = l
+		}
+	}
+
+	var startTime, endTime time.Time
+	if params.StartDate != "" {
+		if t, err := parseTimeWithLocation(params.StartDate, loc); err == nil {
+			startTime = t
+		}
+	}
+	if params.EndDate != "" {
+		if t, err := parseTimeWithLocation(params.EndDate, loc); err == nil {
+			endTime = t
+		}
+	}
+	if endTime.IsZero() {
+		endTime = time.Now().In(loc)
+	}
+	if startTime.IsZero() {
+		startTime = endTime.AddDate(0, 0, -7)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), usageDBQueryTimeout)
+	defer cancel()
+
+	result, err := usageTracker.QueryLatencyPercentiles(ctx, &tracking.QueryOptions{
+		StartDate:    &startTime,
+		EndDate:      &endTime,
+		ModelName:    params.Model,
+		Channel:      params.Channel,
+		EndpointName: params.Endpoint,
+		Tags:         parseTagFilters(params.Tags),
+	}, params.GroupBy)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"cc-forwarder/internal/tracking"
+)
+
+// RecalculateCostsInput 历史成本重算参数
+type RecalculateCostsInput struct {
+	StartDate    string `json:"start_date"`    // 格式：2025-12-05 或 2025-12-05T00:00
+	EndDate      string `json:"end_date"`      // 仅日期时包含当天整天
+	ModelName    string `json:"model_name"`    // 为空表示全部模型
+	EndpointName string `json:"endpoint_name"` // 为空表示全部端点
+	Channel      string `json:"channel"`       // 为空表示全部渠道
+	DryRun       bool   `json:"dry_run"`       // 仅预览差异，不写入
+}
+
+// RecalculateCosts 按当前定价版本重算历史请求成本（dry_run 时返回新旧成本差异预览）
+func (a *App) RecalculateCosts(input RecalculateCostsInput) (*tracking.CostRecalculationResult, error) {
+	a.mu.RLock()
+	usageTracker := a.usageTracker
+	cfg := a.config
+	logger := a.logger
+	a.mu.RUnlock()
+
+	if usageTracker == nil {
+		return nil, fmt.Errorf("使用跟踪未启用")
+	}
+
+	loc := time.Local
+	if cfg != nil && cfg.Timezone != "" {
+		if l, err := time.LoadLocation(cfg.Timezone); err == nil {
+			loc = l
+		}
+	}
+
+	startTime, err := parseTimeWithLocation(strings.TrimSpace(input.StartDate), loc)
+	if err != nil {
+		return nil, fmt.Errorf("开始时间格式无效: %s", input.StartDate)
+	}
+	endDate := strings.TrimSpace(input.EndDate)
+	endTime, err := parseTimeWithLocation(endDate, loc)
+	if err != nil {
+		return nil, fmt.Errorf("结束时间格式无效: %s", input.EndDate)
+	}
+	if len(endDate) == len("2006-01-02") {
+		// 仅日期时包含结束日整天
+		endTime = endTime.Add(24*time.Hour - time.Second)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	result, err := usageTracker.RecalculateCosts(ctx, tracking.CostRecalculationOptions{
+		StartTime:    startTime,
+		EndTime:      endTime,
+		ModelName:    strings.TrimSpace(input.ModelName),
+		EndpointName: strings.TrimSpace(input.EndpointName),
+		Channel:      strings.TrimSpace(input.Channel),
+		DryRun:       input.DryRun,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("重算历史成本失败: %w", err)
+	}
+
+	if logger != nil && !input.DryRun {
+		logger.Info("✅ 历史成本已重算",
+			"start", startTime.Format(time.RFC3339),
+			"end", endTime.Format(time.RFC3339),
+			"changed", result.Changed,
+			"delta_usd", result.Delta)
+	}
+	return result, nil
+}
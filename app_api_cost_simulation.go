@@ -0,0 +1,194 @@
+// app_api_cost_simulation.go - 成本模拟 API (Wails Bindings)
+// 按候选渠道/端点、模型定价、价目表或端点倍率重放历史用量，对比实际成本（按模型 / 按天），不修改任何记录
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"cc-forwarder/internal/service"
+	"cc-forwarder/internal/store"
+	"cc-forwarder/internal/tracking"
+)
+
+// CostSimulationInput 成本模拟参数
+type CostSimulationInput struct {
+	StartDate string              `json:"start_date"` // 格式：2025-12-05 或 2025-12-05T00:00
+	EndDate   string              `json:"end_date"`   // 仅日期时包含当天整天
+	Model     string              `json:"model"`      // 可选：模型名称
+	Channel   string              `json:"channel"`    // 可选：渠道名称
+	Endpoint  string              `json:"endpoint"`   // 可选：端点名称
+	Group     string              `json:"group"`      // 可选：组名
+	Status    string              `json:"status"`     // 可选：状态（failed 包含所有失败类状态）
+	Tags      []string            `json:"tags"`       // 可选：标签筛选，格式 key=value，多个为 AND 关系
+	Scenarios []CostScenarioInput `json:"scenarios"`  // 候选方案（1-10 个）
+}
+
+// CostScenarioInput 单个候选方案
+// 仅指定 channel/endpoint 时按该渠道/端点当前的价目表与倍率计价；指定 pricing/price_book/multiplier 时在该渠道/端点（或请求自身）的计价规则上替换对应项
+type CostScenarioInput struct {
+	Name       string                       `json:"name"`
+	Channel    string                       `json:"channel"`    // 假设路由到的渠道
+	Endpoint   string                       `json:"endpoint"`   // 假设路由到的端点
+	Pricing    []CostScenarioPricingInput   `json:"pricing"`    // 候选模型定价（未列出的模型沿用当前定价）
+	PriceBook  string                       `json:"price_book"` // 已保存的价目表名称（可为未启用的草稿）
+	Multiplier *CostScenarioMultiplierInput `json:"multiplier"` // 候选端点倍率（为空沿用请求或 channel/endpoint 的当前倍率）
+}
+
+// CostScenarioPricingInput 候选模型定价（USD / 1M tokens）
+type CostScenarioPricingInput struct {
+	ModelName            string  `json:"model_name"`
+	InputPrice           float64 `json:"input_price"`
+	OutputPrice          float64 `json:"output_price"`
+	CacheCreationPrice5m float64 `json:"cache_creation_price_5m"`
+	CacheCreationPrice1h float64 `json:"cache_creation_price_1h"`
+	CacheReadPrice       float64 `json:"cache_read_price"`
+}
+
+// CostScenarioMultiplierInput 候选端点倍率
+type CostScenarioMultiplierInput struct {
+	CostMultiplier                float64 `json:"cost_multiplier"`
+	InputCostMultiplier           float64 `json:"input_cost_multiplier"`
+	OutputCostMultiplier          float64 `json:"output_cost_multiplier"`
+	CacheCreationCostMultiplier   float64 `json:"cache_creation_cost_multiplier"`
+	CacheCreationCostMultiplier1h float64 `json:"cache_creation_cost_multiplier_1h"`
+	CacheReadCostMultiplier       float64 `json:"cache_read_cost_multiplier"`
+}
+
+// SimulateCosts 按候选方案重放历史请求成本，返回按模型与按天的对比
+func (a *App) SimulateCosts(input CostSimulationInput) (*tracking.CostSimulationResult, error) {
+	a.mu.RLock()
+	usageTracker := a.usageTracker
+	priceBookService := a.priceBookService
+	cfg := a.config
+	a.mu.RUnlock()
+
+	if usageTracker == nil {
+		return nil, fmt.Errorf("使用跟踪未启用")
+	}
+
+	loc := time.Local
+	if cfg != nil && cfg.Timezone != "" {
+		if l, err := time.LoadLocation(cfg.Timezone); err == nil {
+			loc = l
+		}
+	}
+
+	startTime, err := parseTimeWithLocation(strings.TrimSpace(input.StartDate), loc)
+	if err != nil {
+		return nil, fmt.Errorf("开始时间格式无效: %s", input.StartDate)
+	}
+	endDate := strings.TrimSpace(input.EndDate)
+	endTime, err := parseTimeWithLocation(endDate, loc)
+	if err != nil {
+		return nil, fmt.Errorf("结束时间格式无效: %s", input.EndDate)
+	}
+	if len(endDate) == len("2006-01-02") {
+		// 仅日期时包含结束日整天
+		endTime = endTime.Add(24*time.Hour - time.Second)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	var priceBooks []*store.PriceBookRecord
+	for _, s := range input.Scenarios {
+		if strings.TrimSpace(s.PriceBook) == "" {
+			continue
+		}
+		if priceBookService == nil {
+			return nil, fmt.Errorf("价目表存储未就绪")
+		}
+		if priceBooks, err = priceBookService.ListPriceBooks(ctx); err != nil {
+			return nil, fmt.Errorf("读取价目表失败: %w", err)
+		}
+		break
+	}
+
+	scenarios := make([]tracking.CostScenario, 0, len(input.Scenarios))
+	for _, s := range input.Scenarios {
+		scenario := tracking.CostScenario{
+			Name:         strings.TrimSpace(s.Name),
+			Channel:      strings.TrimSpace(s.Channel),
+			EndpointName: strings.TrimSpace(s.Endpoint),
+		}
+		if len(s.Pricing) > 0 {
+			scenario.Pricing = make(map[string]tracking.ModelPricing, len(s.Pricing))
+			for _, p := range s.Pricing {
+				scenario.Pricing[strings.TrimSpace(p.ModelName)] = tracking.ModelPricing{
+					Input:           p.InputPrice,
+					Output:          p.OutputPrice,
+					CacheCreation:   p.CacheCreationPrice5m,
+					CacheCreation1h: p.CacheCreationPrice1h,
+					CacheRead:       p.CacheReadPrice,
+				}
+			}
+		}
+		if name := strings.TrimSpace(s.PriceBook); name != "" {
+			book, err := findSimulationPriceBook(priceBookService, priceBooks, name)
+			if err != nil {
+				return nil, err
+			}
+			scenario.PriceBook = book
+		}
+		if m := s.Multiplier; m != nil {
+			scenario.Multiplier = &tracking.EndpointMultiplier{
+				CostMultiplier:                m.CostMultiplier,
+				InputCostMultiplier:           m.InputCostMultiplier,
+				OutputCostMultiplier:          m.OutputCostMultiplier,
+				CacheCreationCostMultiplier:   m.CacheCreationCostMultiplier,
+				CacheCreationCostMultiplier1h: m.CacheCreationCostMultiplier1h,
+				CacheReadCostMultiplier:       m.CacheReadCostMultiplier,
+			}
+		}
+		scenarios = append(scenarios, scenario)
+	}
+
+	opts := &tracking.QueryOptions{
+		StartDate:    &startTime,
+		EndDate:      &endTime,
+		ModelName:    strings.TrimSpace(input.Model),
+		Channel:      strings.TrimSpace(input.Channel),
+		EndpointName: strings.TrimSpace(input.Endpoint),
+		GroupName:    strings.TrimSpace(input.Group),
+		Status:       input.Status,
+		Tags:         parseTagFilters(input.Tags),
+	}
+	result, err := usageTracker.SimulateCosts(ctx, opts, scenarios)
+	if err != nil {
+		return nil, fmt.Errorf("成本模拟失败: %w", err)
+	}
+	return result, nil
+}
+
+// ExportCostSimulationCSV 执行成本模拟并导出 CSV（按模型、按天与合计，含各方案差额），返回文件内容
+func (a *App) ExportCostSimulationCSV(input CostSimulationInput) (string, error) {
+	result, err := a.SimulateCosts(input)
+	if err != nil {
+		return "", err
+	}
+	data, err := result.CSV()
+	if err != nil {
+		return "", fmt.Errorf("导出成本模拟失败: %w", err)
+	}
+	return string(data), nil
+}
+
+// findSimulationPriceBook 按名称查找价目表（模拟时忽略启用状态，便于评估草稿价目表）
+func findSimulationPriceBook(priceBookService *service.PriceBookService, records []*store.PriceBookRecord, name string) (*tracking.PriceBook, error) {
+	for _, r := range records {
+		if r == nil || r.Name != name {
+			continue
+		}
+		candidate := *r
+		candidate.Enabled = true
+		books := priceBookService.ToTrackingPriceBooks([]*store.PriceBookRecord{&candidate})
+		if len(books) == 1 {
+			return &books[0], nil
+		}
+	}
+	return nil, fmt.Errorf("价目表不存在: %s", name)
+}
@@ -0,0 +1,54 @@
+// app_api_endpoint_capability.go - 端点能力集 API (Wails Bindings)
+// 提供端点能力状态查询（配置声明 + 运行时探测）与探测结果重置
+
+package main
+
+import (
+	"fmt"
+
+	"cc-forwarder/internal/endpoint"
+)
+
+// EndpointCapabilityInfo 端点能力状态（给前端用的结构体）
+type EndpointCapabilityInfo struct {
+	Channel      string                      `json:"channel"`
+	EndpointName string                      `json:"endpoint_name"`
+	Capabilities []endpoint.CapabilityStatus `json:"capabilities"`
+}
+
+// GetEndpointCapabilities 获取所有端点的能力状态
+func (a *App) GetEndpointCapabilities() []EndpointCapabilityInfo {
+	a.mu.RLock()
+	manager := a.endpointManager
+	a.mu.RUnlock()
+
+	if manager == nil {
+		return []EndpointCapabilityInfo{}
+	}
+
+	endpoints := manager.GetAllEndpoints()
+	result := make([]EndpointCapabilityInfo, 0, len(endpoints))
+	for _, ep := range endpoints {
+		result = append(result, EndpointCapabilityInfo{
+			Channel:      ep.Config.Channel,
+			EndpointName: ep.Config.Name,
+			Capabilities: manager.GetEndpointCapabilities(ep),
+		})
+	}
+	return result
+}
+
+// ResetEndpointCapabilities 清除端点的能力探测结果（配置声明不受影响）
+func (a *App) ResetEndpointCapabilities(channel, endpointName string) error {
+	a.mu.RLock()
+	manager := a.endpointManager
+	a.mu.RUnlock()
+
+	if manager == nil {
+		return fmt.Errorf("端点管理器未就绪")
+	}
+
+	manager.ResetDetectedCapabilities(endpoint.EndpointKey(channel, endpointName))
+	a.emitEndpointUpdate()
+	return nil
+}
@@ -0,0 +1,87 @@
+// app_api_endpoint_model.go - 端点模型目录 API (Wails Bindings)
+// 提供端点 × 模型矩阵查询、手动刷新与手动覆盖
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"cc-forwarder/internal/service"
+)
+
+// EndpointModelRefreshResult 模型目录刷新结果
+type EndpointModelRefreshResult struct {
+	Succeeded int `json:"succeeded"`
+	Failed    int `json:"failed"`
+}
+
+// GetEndpointModelMatrix 获取端点 × 模型矩阵
+func (a *App) GetEndpointModelMatrix() (*service.EndpointModelMatrix, error) {
+	a.mu.RLock()
+	endpointModelService := a.endpointModelService
+	a.mu.RUnlock()
+
+	if endpointModelService == nil {
+		return nil, fmt.Errorf("端点模型服务未就绪（需要 SQLite 端点存储）")
+	}
+
+	return endpointModelService.GetModelMatrix(), nil
+}
+
+// RefreshEndpointModels 立即对所有端点执行 /v1/models 模型发现
+func (a *App) RefreshEndpointModels() (EndpointModelRefreshResult, error) {
+	a.mu.RLock()
+	endpointModelService := a.endpointModelService
+	a.mu.RUnlock()
+
+	if endpointModelService == nil {
+		return EndpointModelRefreshResult{}, fmt.Errorf("端点模型服务未就绪（需要 SQLite 端点存储）")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	succeeded, failed := endpointModelService.RefreshAll(ctx)
+	a.emitEndpointUpdate()
+	return EndpointModelRefreshResult{Succeeded: succeeded, Failed: failed}, nil
+}
+
+// SetEndpointModelOverride 设置端点模型手动覆盖（allowed=true 允许，false 禁止）
+func (a *App) SetEndpointModelOverride(channel, endpointName, model string, allowed bool) error {
+	a.mu.RLock()
+	endpointModelService := a.endpointModelService
+	a.mu.RUnlock()
+
+	if endpointModelService == nil {
+		return fmt.Errorf("端点模型服务未就绪（需要 SQLite 端点存储）")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := endpointModelService.SetOverride(ctx, channel, endpointName, model, allowed); err != nil {
+		return fmt.Errorf("设置模型覆盖失败: %w", err)
+	}
+	return nil
+}
+
+// DeleteEndpointModelOverride 删除端点模型手动覆盖
+func (a *App) DeleteEndpointModelOverride(channel, endpointName, model string) error {
+	a.mu.RLock()
+	endpointModelService := a.endpointModelService
+	a.mu.RUnlock()
+
+	if endpointModelService == nil {
+		return fmt.Errorf("端点模型服务未就绪（需要 SQLite 端点存储）")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := endpointModelService.DeleteOverride(ctx, channel, endpointName, model); err != nil {
+		return fmt.Errorf("删除模型覆盖失败: %w", err)
+	}
+	return nil
+}
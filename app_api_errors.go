@@ -0,0 +1,96 @@
+// app_api_errors.go - 上游错误分析 API (Wails Bindings)
+// 将上游错误消息归一化为指纹，按端点/渠道统计 Top N 错误、首末次出现时间、样例请求与趋势
+
+package main
+
+import (
+	"context"
+	"time"
+
+	"cc-forwarder/internal/tracking"
+)
+
+// ErrorAnalyticsQueryParams 错误分析查询参数
+type ErrorAnalyticsQueryParams struct {
+	Scope       string `json:"scope"`       // endpoint（默认，按端点取 Top N）/ channel（按渠道取 Top N）
+	Limit       int    `json:"limit"`       // 每个端点/渠道返回的错误数，默认 10
+	StartDate   string `json:"start_date"`  // 格式：2025-12-05T00:00 或 2025-12-05T00:00:00+08:00，默认最近 7 天
+	EndDate     string `json:"end_date"`    // 默认今天结束
+	Channel     string `json:"channel"`     // 可选：渠道名称
+	Endpoint    string `json:"endpoint"`    // 可选：端点名称
+	Granularity string `json:"granularity"` // 趋势粒度：hour（默认）/ day
+	Fingerprint string `json:"fingerprint"` // 趋势可选：只统计指定错误指纹
+}
+
+// GetTopErrors 获取按端点/渠道分组的高频上游错误（含首末次出现时间与样例请求）
+func (a *App) GetTopErrors(params ErrorAnalyticsQueryParams) ([]tracking.ErrorFingerprint, error) {
+	a.mu.RLock()
+	usageTracker := a.usageTracker
+	cfg := a.config
+	a.mu.RUnlock()
+
+	if usageTracker == nil {
+		return []tracking.ErrorFingerprint{}, nil
+	}
+
+	loc := time.Local
+	if cfg != nil && cfg.Timezone != "" {
+		if l, err := time.LoadLocation(cfg.Timezone); err == nil {
+			loc = l
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), usageDBQueryTimeout)
+	defer cancel()
+
+	items, err := usageTracker.QueryTopErrors(ctx, errorAnalyticsOptions(params, loc), params.Scope, params.Limit)
+	if err != nil {
+		return nil, err
+	}
+	if items == nil {
+		items = []tracking.ErrorFingerprint{}
+	}
+	return items, nil
+}
+
+// GetErrorTrend 获取上游错误出现次数趋势（按错误类型拆分，可限定错误指纹）
+func (a *App) GetErrorTrend(params ErrorAnalyticsQueryParams) ([]tracking.ErrorTrendPoint, error) {
+	a.mu.RLock()
+	usageTracker := a.usageTracker
+	cfg := a.config
+	a.mu.RUnlock()
+
+	if usageTracker == nil {
+		return []tracking.ErrorTrendPoint{}, nil
+	}
+
+	loc := time.Local
+	if cfg != nil && cfg.Timezone != "" {
+		if l, err := time.LoadLocation(cfg.Timezone); err == nil {
+			loc = l
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), usageDBQueryTimeout)
+	defer cancel()
+
+	points, err := usageTracker.QueryErrorTrend(ctx, errorAnalyticsOptions(params, loc), params.Granularity, params.Fingerprint)
+	if err != nil {
+		return nil, err
+	}
+	if points == nil {
+		points = []tracking.ErrorTrendPoint{}
+	}
+	return points, nil
+}
+
+// errorAnalyticsOptions 构建错误分析查询条件（时间范围默认与缓存分析一致：最近 7 天）
+func errorAnalyticsOptions(params ErrorAnalyticsQueryParams, loc *time.Location) *tracking.QueryOptions {
+	startTime, endTime := cacheAnalyticsRange(params.StartDate, params.EndDate, loc)
+	return &tracking.QueryOptions{
+		StartDate:    &startTime,
+		EndDate:      &endTime,
+		Channel:      params.Channel,
+		EndpointName: params.Endpoint,
+	}
+}
@@ -0,0 +1,177 @@
+// app_api_exchange_rate.go - 汇率管理 API (Wails Bindings)
+// 维护多币种成本换算使用的汇率（1 USD 可兑换的目标币种数量，按生效时间选取）
+// 渠道专属汇率优先于同币种的全局汇率
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"cc-forwarder/config"
+	"cc-forwarder/internal/store"
+	"cc-forwarder/internal/tracking"
+)
+
+// ExchangeRateInfo 汇率信息（给前端用的结构体）
+type ExchangeRateInfo struct {
+	ID            int64   `json:"id"`
+	Channel       string  `json:"channel"` // 渠道名（空表示全局汇率）
+	Currency      string  `json:"currency"`
+	Rate          float64 `json:"rate"`           // 1 USD 可兑换的该币种数量
+	EffectiveFrom string  `json:"effective_from"` // RFC3339
+	Note          string  `json:"note"`
+	UpdatedAt     string  `json:"updated_at"`
+}
+
+// SaveExchangeRateInput 保存汇率的输入参数
+type SaveExchangeRateInput struct {
+	Channel       string  `json:"channel,omitempty"` // 渠道名（为空表示全局汇率）
+	Currency      string  `json:"currency"`
+	Rate          float64 `json:"rate"`
+	EffectiveFrom string  `json:"effective_from"` // RFC3339 或 YYYY-MM-DD（按配置时区当日 0 点），为空表示立即生效
+	Note          string  `json:"note,omitempty"`
+}
+
+// GetExchangeRates 获取汇率列表（currency 为空时返回全部）
+func (a *App) GetExchangeRates(currency string) ([]ExchangeRateInfo, error) {
+	a.mu.RLock()
+	exchangeRateStore := a.exchangeRateStore
+	a.mu.RUnlock()
+
+	if exchangeRateStore == nil {
+		return nil, fmt.Errorf("汇率存储未就绪")
+	}
+	if currency != "" {
+		currency = tracking.NormalizeCurrency(currency)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	records, err := exchangeRateStore.List(ctx, currency)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]ExchangeRateInfo, 0, len(records))
+	for _, r := range records {
+		result = append(result, ExchangeRateInfo{
+			ID:            r.ID,
+			Channel:       r.Channel,
+			Currency:      r.Currency,
+			Rate:          r.Rate,
+			EffectiveFrom: r.EffectiveFrom.Format(time.RFC3339),
+			Note:          r.Note,
+			UpdatedAt:     r.UpdatedAt.Format("2006-01-02 15:04:05"),
+		})
+	}
+	return result, nil
+}
+
+// SaveExchangeRate 保存汇率（同一渠道/全局、同一币种、同一生效时间覆盖）
+func (a *App) SaveExchangeRate(input SaveExchangeRateInput) (*ExchangeRateInfo, error) {
+	a.mu.RLock()
+	exchangeRateStore := a.exchangeRateStore
+	cfg := a.config
+	logger := a.logger
+	a.mu.RUnlock()
+
+	if exchangeRateStore == nil {
+		return nil, fmt.Errorf("汇率存储未就绪")
+	}
+
+	currency := tracking.NormalizeCurrency(input.Currency)
+	if !tracking.IsCurrencyCode(currency) {
+		return nil, fmt.Errorf("币种无效: %s（需为 3 位 ISO 4217 代码，如 CNY、EUR）", currency)
+	}
+	if currency == tracking.BaseCurrency {
+		return nil, fmt.Errorf("USD 为基准币种，无需设置汇率")
+	}
+	if input.Rate <= 0 {
+		return nil, fmt.Errorf("汇率必须大于 0")
+	}
+
+	effectiveFrom, err := parseEffectiveFrom(input.EffectiveFrom, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	saved, err := exchangeRateStore.Upsert(ctx, &store.ExchangeRateRecord{
+		Channel:       strings.TrimSpace(input.Channel),
+		Currency:      currency,
+		Rate:          input.Rate,
+		EffectiveFrom: effectiveFrom,
+		Note:          strings.TrimSpace(input.Note),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	a.syncCurrencyToTracker(ctx)
+	if logger != nil {
+		logger.Info("✅ 汇率已保存", "channel", saved.Channel, "currency", currency, "rate", input.Rate, "effective_from", saved.EffectiveFrom)
+	}
+
+	return &ExchangeRateInfo{
+		ID:            saved.ID,
+		Channel:       saved.Channel,
+		Currency:      saved.Currency,
+		Rate:          saved.Rate,
+		EffectiveFrom: saved.EffectiveFrom.Format(time.RFC3339),
+		Note:          saved.Note,
+		UpdatedAt:     saved.UpdatedAt.Format("2006-01-02 15:04:05"),
+	}, nil
+}
+
+// DeleteExchangeRate 删除汇率
+func (a *App) DeleteExchangeRate(id int64) error {
+	a.mu.RLock()
+	exchangeRateStore := a.exchangeRateStore
+	logger := a.logger
+	a.mu.RUnlock()
+
+	if exchangeRateStore == nil {
+		return fmt.Errorf("汇率存储未就绪")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := exchangeRateStore.Delete(ctx, id); err != nil {
+		return err
+	}
+
+	a.syncCurrencyToTracker(ctx)
+	if logger != nil {
+		logger.Info("🗑️ 汇率已删除", "id", id)
+	}
+	return nil
+}
+
+// parseEffectiveFrom 解析汇率生效时间（日期按配置时区当日 0 点）
+func parseEffectiveFrom(value string, cfg *config.Config) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Now(), nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	loc := time.Local
+	if cfg != nil && cfg.Timezone != "" {
+		if parsed, err := time.LoadLocation(cfg.Timezone); err == nil {
+			loc = parsed
+		}
+	}
+	t, err := time.ParseInLocation("2006-01-02", value, loc)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("生效时间格式无效: %s（支持 RFC3339 或 YYYY-MM-DD）", value)
+	}
+	return t, nil
+}
@@ -0,0 +1,41 @@
+// app_api_message_batch.go - Message Batches API (Wails Bindings)
+// 提供经代理创建的批处理任务列表（所属端点、状态、用量是否已计费）
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"cc-forwarder/internal/store"
+)
+
+// defaultMessageBatchListLimit 批处理列表默认返回条数
+const defaultMessageBatchListLimit = 100
+
+// GetMessageBatches 获取批处理任务列表（按创建时间倒序，limit <= 0 时使用默认条数）
+func (a *App) GetMessageBatches(limit int) ([]*store.MessageBatchRecord, error) {
+	a.mu.RLock()
+	messageBatchStore := a.messageBatchStore
+	a.mu.RUnlock()
+
+	if messageBatchStore == nil {
+		return nil, fmt.Errorf("批处理存储未就绪")
+	}
+	if limit <= 0 {
+		limit = defaultMessageBatchListLimit
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	records, err := messageBatchStore.List(ctx, limit)
+	if err != nil {
+		return nil, err
+	}
+	if records == nil {
+		records = []*store.MessageBatchRecord{}
+	}
+	return records, nil
+}
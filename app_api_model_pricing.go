@@ -27,9 +27,14 @@ type ModelPricingInfo struct {
 	CacheCreationPrice5m float64 `json:"cache_creation_price_5m"` // 5分钟缓存创建 (USD per 1M tokens)
 	CacheCreationPrice1h float64 `json:"cache_creation_price_1h"` // 1小时缓存创建 (USD per 1M tokens)
 	CacheReadPrice       float64 `json:"cache_read_price"`        // USD per 1M tokens
+	WebSearchPrice       float64 `json:"web_search_price"`        // USD per 1K web search 请求
+	WebFetchPrice        float64 `json:"web_fetch_price"`         // USD per 1K web fetch 请求
 	IsDefault            bool    `json:"is_default"`
 	CreatedAt            string  `json:"created_at"`
 	UpdatedAt            string  `json:"updated_at"`
+
+	// 长上下文分档定价（提示总 token 超过阈值时适用）
+	Tiers []store.PricingTierRecord `json:"tiers"`
 }
 
 // CreateModelPricingInput 创建模型定价的输入参数
@@ -42,9 +47,38 @@ type CreateModelPricingInput struct {
 	CacheCreationPrice5m float64 `json:"cache_creation_price_5m"`
 	CacheCreationPrice1h float64 `json:"cache_creation_price_1h"`
 	CacheReadPrice       float64 `json:"cache_read_price"`
-	IsDefault            bool    `json:"is_default"`
+	// 服务端工具价格（nil 表示未提供：创建时使用默认值，更新时保持原值；0 表示免费）
+	WebSearchPrice *float64 `json:"web_search_price,omitempty"`
+	WebFetchPrice  *float64 `json:"web_fetch_price,omitempty"`
+	// 长上下文分档定价（nil 表示未提供：更新时保持原值；空数组表示清除）
+	Tiers     []store.PricingTierRecord `json:"tiers,omitempty"`
+	IsDefault bool                      `json:"is_default"`
+	// 价格版本生效时间（RFC3339 或 YYYY-MM-DD，为空表示立即生效；早于当前时间时用于修正历史价格）
+	EffectiveFrom string `json:"effective_from,omitempty"`
 }
 
+// ModelPricingVersionInfo 模型定价历史版本（给前端用的结构体）
+type ModelPricingVersionInfo struct {
+	ID                   int64                     `json:"id"`
+	ModelName            string                    `json:"model_name"`
+	InputPrice           float64                   `json:"input_price"`
+	OutputPrice          float64                   `json:"output_price"`
+	CacheCreationPrice5m float64                   `json:"cache_creation_price_5m"`
+	CacheCreationPrice1h float64                   `json:"cache_creation_price_1h"`
+	CacheReadPrice       float64                   `json:"cache_read_price"`
+	WebSearchPrice       float64                   `json:"web_search_price"`
+	WebFetchPrice        float64                   `json:"web_fetch_price"`
+	Tiers                []store.PricingTierRecord `json:"tiers"`
+	EffectiveFrom        string                    `json:"effective_from"` // RFC3339
+	Note                 string                    `json:"note"`
+}
+
+// 服务端工具默认价格 (USD per 1K requests)
+const (
+	defaultWebSearchPrice = 10.0
+	defaultWebFetchPrice  = 0.0
+)
+
 // ModelPricingStorageStatus 模型定价存储状态
 type ModelPricingStorageStatus struct {
 	Enabled    bool `json:"enabled"`     // 是否启用
@@ -172,16 +206,24 @@ func (a *App) CreateModelPricing(input CreateModelPricingInput) error {
 		CacheCreationPrice5m: input.CacheCreationPrice5m,
 		CacheCreationPrice1h: input.CacheCreationPrice1h,
 		CacheReadPrice:       input.CacheReadPrice,
+		WebSearchPrice:       floatOrDefault(input.WebSearchPrice, defaultWebSearchPrice),
+		WebFetchPrice:        floatOrDefault(input.WebFetchPrice, defaultWebFetchPrice),
+		Tiers:                input.Tiers,
 		IsDefault:            input.IsDefault,
 	}
 
+	effectiveFrom, err := parseEffectiveFrom(input.EffectiveFrom, a.config)
+	if err != nil {
+		return err
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	_, err := modelPricingService.CreatePricing(ctx, record)
-	if err != nil {
+	if _, err := modelPricingService.CreatePricing(ctx, record, effectiveFrom); err != nil {
 		return fmt.Errorf("创建模型定价失败: %w", err)
 	}
+	a.syncPricingToTracker(ctx)
 
 	if logger != nil {
 		logger.Info("✅ 模型定价已创建", "model", input.ModelName)
@@ -211,15 +253,43 @@ func (a *App) UpdateModelPricing(modelName string, input CreateModelPricingInput
 		CacheCreationPrice5m: input.CacheCreationPrice5m,
 		CacheCreationPrice1h: input.CacheCreationPrice1h,
 		CacheReadPrice:       input.CacheReadPrice,
+		Tiers:                input.Tiers,
 		IsDefault:            input.IsDefault,
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	if err := modelPricingService.UpdatePricing(ctx, record); err != nil {
+	// 未提供服务端工具价格或长上下文档位时保持原值
+	if input.WebSearchPrice == nil || input.WebFetchPrice == nil || input.Tiers == nil {
+		existing, err := modelPricingService.GetPricing(ctx, modelName)
+		if err != nil {
+			return fmt.Errorf("更新模型定价失败: %w", err)
+		}
+		if existing != nil {
+			record.WebSearchPrice = existing.WebSearchPrice
+			record.WebFetchPrice = existing.WebFetchPrice
+			if input.Tiers == nil {
+				record.Tiers = existing.Tiers
+			}
+		}
+	}
+	if input.WebSearchPrice != nil {
+		record.WebSearchPrice = *input.WebSearchPrice
+	}
+	if input.WebFetchPrice != nil {
+		record.WebFetchPrice = *input.WebFetchPrice
+	}
+
+	effectiveFrom, err := parseEffectiveFrom(input.EffectiveFrom, a.config)
+	if err != nil {
+		return err
+	}
+
+	if err := modelPricingService.UpdatePricing(ctx, record, effectiveFrom); err != nil {
 		return fmt.Errorf("更新模型定价失败: %w", err)
 	}
+	a.syncPricingToTracker(ctx)
 
 	if logger != nil {
 		logger.Info("✅ 模型定价已更新", "model", modelName)
@@ -246,6 +316,7 @@ func (a *App) DeleteModelPricing(modelName string) error {
 	if err := modelPricingService.DeletePricing(ctx, modelName); err != nil {
 		return fmt.Errorf("删除模型定价失败: %w", err)
 	}
+	a.syncPricingToTracker(ctx)
 
 	if logger != nil {
 		logger.Info("✅ 模型定价已删除", "model", modelName)
@@ -280,6 +351,49 @@ func (a *App) SetDefaultModelPricing(modelName string) error {
 	return nil
 }
 
+// GetModelPricingHistory 获取模型定价历史版本（modelName 为空时返回全部）
+func (a *App) GetModelPricingHistory(modelName string) ([]ModelPricingVersionInfo, error) {
+	a.ensureModelPricingService()
+	a.mu.RLock()
+	modelPricingService := a.modelPricingService
+	a.mu.RUnlock()
+
+	if modelPricingService == nil {
+		return nil, fmt.Errorf("模型定价服务未就绪，请稍后重试")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	versions, err := modelPricingService.ListHistory(ctx, modelName)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]ModelPricingVersionInfo, 0, len(versions))
+	for _, v := range versions {
+		tiers := v.Tiers
+		if tiers == nil {
+			tiers = []store.PricingTierRecord{}
+		}
+		result = append(result, ModelPricingVersionInfo{
+			ID:                   v.ID,
+			ModelName:            v.ModelName,
+			InputPrice:           v.InputPrice,
+			OutputPrice:          v.OutputPrice,
+			CacheCreationPrice5m: v.CacheCreationPrice5m,
+			CacheCreationPrice1h: v.CacheCreationPrice1h,
+			CacheReadPrice:       v.CacheReadPrice,
+			WebSearchPrice:       v.WebSearchPrice,
+			WebFetchPrice:        v.WebFetchPrice,
+			Tiers:                tiers,
+			EffectiveFrom:        v.EffectiveFrom.Format(time.RFC3339),
+			Note:                 v.Note,
+		})
+	}
+	return result, nil
+}
+
 // pricingRecordToInfo 将数据库记录转换为前端 Info 结构
 func (a *App) pricingRecordToInfo(r *store.ModelPricingRecord) ModelPricingInfo {
 	info := ModelPricingInfo{
@@ -292,6 +406,9 @@ func (a *App) pricingRecordToInfo(r *store.ModelPricingRecord) ModelPricingInfo
 		CacheCreationPrice5m: r.CacheCreationPrice5m,
 		CacheCreationPrice1h: r.CacheCreationPrice1h,
 		CacheReadPrice:       r.CacheReadPrice,
+		WebSearchPrice:       r.WebSearchPrice,
+		WebFetchPrice:        r.WebFetchPrice,
+		Tiers:                r.Tiers,
 		IsDefault:            r.IsDefault,
 	}
 
@@ -304,3 +421,11 @@ func (a *App) pricingRecordToInfo(r *store.ModelPricingRecord) ModelPricingInfo
 
 	return info
 }
+
+// floatOrDefault 返回指针指向的值，nil 时返回默认值
+func floatOrDefault(value *float64, fallback float64) float64 {
+	if value == nil {
+		return fallback
+	}
+	return *value
+}
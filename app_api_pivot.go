@@ -0,0 +1,72 @@
+// app_api_pivot.go - 透视分析 API (Wails Bindings)
+// 对请求日志按任意维度组合（模型/渠道/端点/状态/客户端/时间桶等）分组，返回请求数、成功率、Token、成本与耗时分位数
+
+package main
+
+import (
+	"context"
+	"time"
+
+	"cc-forwarder/internal/tracking"
+)
+
+// PivotQueryParams 透视分析查询参数
+type PivotQueryParams struct {
+	Dimensions []string            `json:"dimensions"` // 分组维度：model/channel/endpoint/group/status/failure_reason/client_ip/user_agent/is_streaming/session/stop_reason/hour/day/week
+	Metrics    []string            `json:"metrics"`    // 指标：count/success_rate/total_tokens/total_cost/avg_duration_ms/p95_duration_ms 等，默认 count/success_rate/total_tokens/total_cost
+	Filters    map[string][]string `json:"filters"`    // 维度过滤：维度名 -> 允许的取值
+	SortBy     string              `json:"sort_by"`    // 排序字段（已选维度或指标）
+	SortOrder  string              `json:"sort_order"` // asc / desc
+	Limit      int                 `json:"limit"`      // 分组数上限，默认 1000
+	StartDate  string              `json:"start_date"` // 格式：2025-12-05T00:00 或 2025-12-05T00:00:00+08:00，默认最近 7 天
+	EndDate    string              `json:"end_date"`   // 默认今天结束
+	Model      string              `json:"model"`      // 可选：模型名称
+	Channel    string              `json:"channel"`    // 可选：渠道名称
+	Endpoint   string              `json:"endpoint"`   // 可选：端点名称
+	Group      string              `json:"group"`      // 可选：组名
+	Status     string              `json:"status"`     // 可选：状态（failed 包含所有失败类状态）
+	Tags       []string            `json:"tags"`       // 可选：标签筛选，格式 key=value，多个为 AND 关系
+}
+
+// GetPivotAnalytics 按任意维度组合查询请求日志聚合指标（时间桶按配置时区划分）
+func (a *App) GetPivotAnalytics(params PivotQueryParams) (*tracking.PivotResult, error) {
+	a.mu.RLock()
+	usageTracker := a.usageTracker
+	cfg := a.config
+	a.mu.RUnlock()
+
+	if usageTracker == nil {
+		return &tracking.PivotResult{Rows: []tracking.PivotRow{}}, nil
+	}
+
+	loc := time.Local
+	if cfg != nil && cfg.Timezone != "" {
+		if l, err := time.LoadLocation(cfg.Timezone); err == nil {
+			loc = l
+		}
+	}
+
+	startTime, endTime := cacheAnalyticsRange(params.StartDate, params.EndDate, loc)
+	opts := &tracking.QueryOptions{
+		StartDate:    &startTime,
+		EndDate:      &endTime,
+		ModelName:    params.Model,
+		Channel:      params.Channel,
+		EndpointName: params.Endpoint,
+		GroupName:    params.Group,
+		Status:       params.Status,
+		Tags:         parseTagFilters(params.Tags),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), usageDBQueryTimeout)
+	defer cancel()
+
+	return usageTracker.QueryPivot(ctx, opts, tracking.PivotQuery{
+		Dimensions: params.Dimensions,
+		Metrics:    params.Metrics,
+		Filters:    params.Filters,
+		SortBy:     params.SortBy,
+		SortOrder:  params.SortOrder,
+		Limit:      params.Limit,
+	})
+}
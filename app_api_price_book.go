@@ -0,0 +1,185 @@
+// app_api_price_book.go - 价目表管理 API (Wails Bindings)
+// 渠道/端点级别的模型价格（绝对价格、倍率公式、按次费用），命中时优先于模型定价
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"cc-forwarder/internal/store"
+)
+
+// PriceBookInfo 价目表信息（给前端用的结构体）
+type PriceBookInfo struct {
+	ID             int64                        `json:"id"`
+	Name           string                       `json:"name"`
+	Channel        string                       `json:"channel"`
+	EndpointName   string                       `json:"endpoint_name"`    // 为空表示作用于整个渠道
+	Mode           string                       `json:"mode"`             // absolute / ratio
+	GroupRatio     float64                      `json:"group_ratio"`      // 分组倍率（ratio 模式）
+	RatioUnitPrice float64                      `json:"ratio_unit_price"` // 倍率为 1 时的输入单价 USD / 1M tokens
+	PerRequestFee  float64                      `json:"per_request_fee"`  // 每次请求固定费用（USD）
+	Entries        []store.PriceBookEntryRecord `json:"entries"`
+	Enabled        bool                         `json:"enabled"`
+	Description    string                       `json:"description"`
+	UpdatedAt      string                       `json:"updated_at"`
+}
+
+// SavePriceBookInput 创建/更新价目表的输入参数
+type SavePriceBookInput struct {
+	Name           string                       `json:"name"`
+	Channel        string                       `json:"channel"`
+	EndpointName   string                       `json:"endpoint_name"`
+	Mode           string                       `json:"mode"`
+	GroupRatio     float64                      `json:"group_ratio"`
+	RatioUnitPrice float64                      `json:"ratio_unit_price"`
+	PerRequestFee  float64                      `json:"per_request_fee"`
+	Entries        []store.PriceBookEntryRecord `json:"entries"`
+	Enabled        bool                         `json:"enabled"`
+	Description    string                       `json:"description"`
+}
+
+// GetPriceBooks 获取所有价目表
+func (a *App) GetPriceBooks() ([]PriceBookInfo, error) {
+	a.mu.RLock()
+	priceBookService := a.priceBookService
+	a.mu.RUnlock()
+
+	if priceBookService == nil {
+		return nil, fmt.Errorf("价目表存储未就绪")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	records, err := priceBookService.ListPriceBooks(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]PriceBookInfo, 0, len(records))
+	for _, r := range records {
+		result = append(result, priceBookRecordToInfo(r))
+	}
+	return result, nil
+}
+
+// CreatePriceBook 创建价目表
+func (a *App) CreatePriceBook(input SavePriceBookInput) (*PriceBookInfo, error) {
+	a.mu.RLock()
+	priceBookService := a.priceBookService
+	logger := a.logger
+	a.mu.RUnlock()
+
+	if priceBookService == nil {
+		return nil, fmt.Errorf("价目表存储未就绪")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	created, err := priceBookService.CreatePriceBook(ctx, input.toRecord())
+	if err != nil {
+		return nil, err
+	}
+
+	a.syncPriceBooksToTracker(ctx)
+	if logger != nil {
+		logger.Info("✅ 价目表已创建", "name", created.Name, "channel", created.Channel, "endpoint", created.EndpointName)
+	}
+
+	info := priceBookRecordToInfo(created)
+	return &info, nil
+}
+
+// UpdatePriceBook 更新价目表（按名称定位）
+func (a *App) UpdatePriceBook(name string, input SavePriceBookInput) error {
+	a.mu.RLock()
+	priceBookService := a.priceBookService
+	logger := a.logger
+	a.mu.RUnlock()
+
+	if priceBookService == nil {
+		return fmt.Errorf("价目表存储未就绪")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	record := input.toRecord()
+	record.Name = name
+	if err := priceBookService.UpdatePriceBook(ctx, record); err != nil {
+		return err
+	}
+
+	a.syncPriceBooksToTracker(ctx)
+	if logger != nil {
+		logger.Info("✅ 价目表已更新", "name", name)
+	}
+	return nil
+}
+
+// DeletePriceBook 删除价目表
+func (a *App) DeletePriceBook(name string) error {
+	a.mu.RLock()
+	priceBookService := a.priceBookService
+	logger := a.logger
+	a.mu.RUnlock()
+
+	if priceBookService == nil {
+		return fmt.Errorf("价目表存储未就绪")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := priceBookService.DeletePriceBook(ctx, name); err != nil {
+		return err
+	}
+
+	a.syncPriceBooksToTracker(ctx)
+	if logger != nil {
+		logger.Info("🗑️ 价目表已删除", "name", name)
+	}
+	return nil
+}
+
+// toRecord 转换为存储记录
+func (in SavePriceBookInput) toRecord() *store.PriceBookRecord {
+	return &store.PriceBookRecord{
+		Name:           in.Name,
+		Channel:        in.Channel,
+		EndpointName:   in.EndpointName,
+		Mode:           in.Mode,
+		GroupRatio:     in.GroupRatio,
+		RatioUnitPrice: in.RatioUnitPrice,
+		PerRequestFee:  in.PerRequestFee,
+		Entries:        in.Entries,
+		Enabled:        in.Enabled,
+		Description:    in.Description,
+	}
+}
+
+// priceBookRecordToInfo 转换价目表记录为前端结构
+func priceBookRecordToInfo(r *store.PriceBookRecord) PriceBookInfo {
+	entries := r.Entries
+	if entries == nil {
+		entries = []store.PriceBookEntryRecord{}
+	}
+	return PriceBookInfo{
+		ID:             r.ID,
+		Name:           r.Name,
+		Channel:        r.Channel,
+		EndpointName:   r.EndpointName,
+		Mode:           r.Mode,
+		GroupRatio:     r.GroupRatio,
+		RatioUnitPrice: r.RatioUnitPrice,
+		PerRequestFee:  r.PerRequestFee,
+		Entries:        entries,
+		Enabled:        r.Enabled,
+		Description:    r.Description,
+		UpdatedAt:      r.UpdatedAt.Format("2006-01-02 15:04:05"),
+	}
+}
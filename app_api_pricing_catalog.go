@@ -0,0 +1,119 @@
+// app_api_pricing_catalog.go - 模型定价目录导入 API (Wails Bindings)
+// 从本地 JSON/YAML 定价目录（含 LiteLLM 格式）导入模型定价，支持预览差异与 merge/overwrite 模式
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"cc-forwarder/internal/service"
+)
+
+// pricingCatalogUsageDays 统计“使用中模型”的回溯天数
+const pricingCatalogUsageDays = 90
+
+// ImportPricingCatalogInput 定价目录导入参数
+type ImportPricingCatalogInput struct {
+	FilePath      string   `json:"file_path"`      // 本地目录文件路径（与 content 二选一）
+	Content       string   `json:"content"`        // 目录文件内容（前端读取文件后直接传入）
+	Mode          string   `json:"mode"`           // merge（默认，仅新增）/ overwrite（新增并覆盖）
+	Providers     []string `json:"providers"`      // 仅导入指定 litellm_provider（如 anthropic）
+	Models        []string `json:"models"`         // 仅导入指定模型
+	EffectiveFrom string   `json:"effective_from"` // 价格版本生效时间（RFC3339 或 YYYY-MM-DD，默认立即生效）
+}
+
+// PreviewModelPricingCatalog 预览定价目录导入（不写入数据库）
+func (a *App) PreviewModelPricingCatalog(input ImportPricingCatalogInput) (*service.PricingCatalogReport, error) {
+	return a.importPricingCatalog(input, false)
+}
+
+// ImportModelPricingCatalog 导入定价目录（新增/覆盖的价格记录为新版本）
+func (a *App) ImportModelPricingCatalog(input ImportPricingCatalogInput) (*service.PricingCatalogReport, error) {
+	return a.importPricingCatalog(input, true)
+}
+
+// importPricingCatalog 预览或执行定价目录导入
+func (a *App) importPricingCatalog(input ImportPricingCatalogInput, apply bool) (*service.PricingCatalogReport, error) {
+	a.ensureModelPricingService()
+	a.mu.RLock()
+	modelPricingService := a.modelPricingService
+	db := a.storeDB
+	if db == nil && a.usageTracker != nil {
+		db = a.usageTracker.GetDB()
+	}
+	logger := a.logger
+	a.mu.RUnlock()
+
+	if modelPricingService == nil {
+		return nil, fmt.Errorf("模型定价服务未就绪，请稍后重试")
+	}
+
+	data := []byte(input.Content)
+	if path := strings.TrimSpace(input.FilePath); path != "" {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("读取定价目录失败: %w", err)
+		}
+		data = content
+	}
+
+	effectiveFrom, err := parseEffectiveFrom(input.EffectiveFrom, a.config)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	opts := service.PricingCatalogOptions{
+		Mode:          input.Mode,
+		Providers:     input.Providers,
+		Models:        input.Models,
+		UsedModels:    a.recentlyUsedModels(ctx),
+		EffectiveFrom: effectiveFrom,
+	}
+
+	if !apply {
+		return modelPricingService.PreviewCatalogImport(ctx, data, opts)
+	}
+
+	report, err := modelPricingService.ImportCatalog(ctx, db, data, opts)
+	if err != nil {
+		return nil, fmt.Errorf("导入定价目录失败: %w", err)
+	}
+	a.syncPricingToTracker(ctx)
+
+	if logger != nil {
+		logger.Info("✅ 定价目录已导入", "mode", report.Mode, "added", report.Added, "updated", report.Updated)
+	}
+	return report, nil
+}
+
+// recentlyUsedModels 获取最近使用过的模型（来自请求记录，查询失败时返回空）
+func (a *App) recentlyUsedModels(ctx context.Context) []string {
+	a.mu.RLock()
+	usageTracker := a.usageTracker
+	a.mu.RUnlock()
+
+	if usageTracker == nil {
+		return nil
+	}
+
+	now := time.Now()
+	stats, err := usageTracker.GetUsageStats(ctx, now.AddDate(0, 0, -pricingCatalogUsageDays), now)
+	if err != nil || stats == nil {
+		return nil
+	}
+
+	models := make([]string, 0, len(stats.ModelStats))
+	for model := range stats.ModelStats {
+		models = append(models, model)
+	}
+	sort.Strings(models)
+	return models
+}
@@ -0,0 +1,199 @@
+// app_api_session.go - 会话归属统计 API (Wails Bindings)
+// 按 Claude Code 会话聚合请求：成本、token、耗时、请求数、使用过的模型与命中的端点
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"cc-forwarder/internal/tracking"
+)
+
+// sessionDefaultDays 会话列表未指定时间范围时的默认回溯天数
+const sessionDefaultDays = 7
+
+// SessionQueryParams 会话查询参数
+type SessionQueryParams struct {
+	Page      int    `json:"page"`
+	PageSize  int    `json:"page_size"`
+	StartDate string `json:"start_date"` // 格式：2025-12-05T00:00 或 2025-12-05T00:00:00+08:00，默认最近 7 天
+	EndDate   string `json:"end_date"`   // 格式：2025-12-05T23:59 或 2025-12-05T23:59:59+08:00
+}
+
+// SessionInfo 会话汇总（给前端用的结构体）
+type SessionInfo struct {
+	SessionID       string   `json:"session_id"`
+	StartTime       string   `json:"start_time"`
+	EndTime         string   `json:"end_time"`
+	RequestCount    int      `json:"request_count"`
+	SuccessCount    int      `json:"success_count"`
+	FailedCount     int      `json:"failed_count"`
+	InputTokens     int64    `json:"input_tokens"`
+	OutputTokens    int64    `json:"output_tokens"`
+	CacheCreation   int64    `json:"cache_creation_tokens"`
+	CacheRead       int64    `json:"cache_read_tokens"`
+	TotalTokens     int64    `json:"total_tokens"`
+	Cost            float64  `json:"cost"`
+	WallClockMs     int64    `json:"wall_clock_ms"`     // 会话跨度（首个请求开始到最后一个请求结束）
+	TotalDurationMs int64    `json:"total_duration_ms"` // 各请求耗时合计
+	Models          []string `json:"models"`
+	Endpoints       []string `json:"endpoints"`
+}
+
+// SessionListResult 会话列表结果
+type SessionListResult struct {
+	Sessions []SessionInfo `json:"sessions"`
+	Total    int           `json:"total"`
+	Page     int           `json:"page"`
+	PageSize int           `json:"page_size"`
+}
+
+// SessionDetail 会话详情（汇总 + 请求明细）
+type SessionDetail struct {
+	Session  SessionInfo     `json:"session"`
+	Requests []RequestRecord `json:"requests"`
+}
+
+// GetSessions 获取会话列表（按最近活动时间倒序）
+func (a *App) GetSessions(params SessionQueryParams) (SessionListResult, error) {
+	a.mu.RLock()
+	usageTracker := a.usageTracker
+	cfg := a.config
+	a.mu.RUnlock()
+
+	if usageTracker == nil {
+		return SessionListResult{Sessions: []SessionInfo{}}, nil
+	}
+
+	page := params.Page
+	pageSize := params.PageSize
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	loc := time.Local
+	if cfg != nil && cfg.Timezone != "" {
+		if l, err := time.LoadLocation(cfg.Timezone); err == nil {
+			loc = l
+		}
+	}
+
+	var startTime, endTime time.Time
+	if params.StartDate != "" {
+		if t, err := parseTimeWithLocation(params.StartDate, loc); err == nil {
+			startTime = t
+		}
+	}
+	if params.EndDate != "" {
+		if t, err := parseTimeWithLocation(params.EndDate, loc); err == nil {
+			endTime = t
+		}
+	}
+	if startTime.IsZero() {
+		now := time.Now().In(loc)
+		startTime = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc).AddDate(0, 0, -(sessionDefaultDays - 1))
+	}
+	if endTime.IsZero() {
+		now := time.Now().In(loc)
+		endTime = time.Date(now.Year(), now.Month(), now.Day(), 23, 59, 59, 999999999, loc)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), usageDBQueryTimeout)
+	defer cancel()
+
+	opts := &tracking.SessionQueryOptions{
+		StartDate: &startTime,
+		EndDate:   &endTime,
+		Limit:     pageSize,
+		Offset:    (page - 1) * pageSize,
+	}
+
+	total, err := usageTracker.CountSessions(ctx, opts)
+	if err != nil {
+		return SessionListResult{}, err
+	}
+	sessions, err := usageTracker.QuerySessions(ctx, opts)
+	if err != nil {
+		return SessionListResult{}, err
+	}
+
+	result := SessionListResult{
+		Sessions: make([]SessionInfo, 0, len(sessions)),
+		Total:    total,
+		Page:     page,
+		PageSize: pageSize,
+	}
+	for _, s := range sessions {
+		result.Sessions = append(result.Sessions, sessionSummaryToInfo(s))
+	}
+	return result, nil
+}
+
+// GetSessionDetail 获取单个会话的汇总与请求明细（按时间倒序，最多 500 条）
+func (a *App) GetSessionDetail(sessionID string) (*SessionDetail, error) {
+	a.mu.RLock()
+	usageTracker := a.usageTracker
+	a.mu.RUnlock()
+
+	if usageTracker == nil {
+		return nil, fmt.Errorf("使用跟踪未启用")
+	}
+
+	sessionID = strings.TrimSpace(sessionID)
+	if sessionID == "" {
+		return nil, fmt.Errorf("会话标识不能为空")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), usageDBQueryTimeout)
+	defer cancel()
+
+	summary, err := usageTracker.GetSessionSummary(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if summary == nil {
+		return nil, fmt.Errorf("会话 %s 不存在", sessionID)
+	}
+
+	requests, _, err := usageTracker.QueryRequestDetailsWithHotPool(ctx, &tracking.QueryOptions{SessionID: sessionID, Limit: 500})
+	if err != nil {
+		return nil, err
+	}
+
+	detail := &SessionDetail{
+		Session:  sessionSummaryToInfo(*summary),
+		Requests: make([]RequestRecord, 0, len(requests)),
+	}
+	for _, r := range requests {
+		detail.Requests = append(detail.Requests, requestDetailToRecord(r))
+	}
+	return detail, nil
+}
+
+// sessionSummaryToInfo 转换会话汇总为前端结构
+func sessionSummaryToInfo(s tracking.SessionSummary) SessionInfo {
+	return SessionInfo{
+		SessionID:       s.SessionID,
+		StartTime:       s.StartTime.Format("2006-01-02 15:04:05"),
+		EndTime:         s.EndTime.Format("2006-01-02 15:04:05"),
+		RequestCount:    s.RequestCount,
+		SuccessCount:    s.SuccessCount,
+		FailedCount:     s.FailedCount,
+		InputTokens:     s.InputTokens,
+		OutputTokens:    s.OutputTokens,
+		CacheCreation:   s.CacheCreationTokens,
+		CacheRead:       s.CacheReadTokens,
+		TotalTokens:     s.TotalTokens,
+		Cost:            s.TotalCostUSD,
+		WallClockMs:     s.WallClockMs,
+		TotalDurationMs: s.TotalDurationMs,
+		Models:          s.Models,
+		Endpoints:       s.Endpoints,
+	}
+}
@@ -12,6 +12,7 @@ import (
 
 	"cc-forwarder/internal/endpoint"
 	"cc-forwarder/internal/store"
+	"cc-forwarder/internal/tracking"
 )
 
 // ============================================================
@@ -36,6 +37,7 @@ type EndpointRecordInfo struct {
 	CooldownSeconds             *int              `json:"cooldown_seconds"`
 	TimeoutSeconds              int               `json:"timeout_seconds"`
 	SupportsCountTokens         bool              `json:"supports_count_tokens"`
+	Capabilities                map[string]bool   `json:"capabilities"` // 能力声明（未声明的能力自动探测）
 	CostMultiplier              float64           `json:"cost_multiplier"`
 	InputCostMultiplier         float64           `json:"input_cost_multiplier"`
 	OutputCostMultiplier        float64           `json:"output_cost_multiplier"`
@@ -67,6 +69,7 @@ type CreateEndpointInput struct {
 	CooldownSeconds               *int              `json:"cooldown_seconds"`
 	TimeoutSeconds                int               `json:"timeout_seconds"`
 	SupportsCountTokens           bool              `json:"supports_count_tokens"`
+	Capabilities                  map[string]bool   `json:"capabilities"` // 为 nil 时更新保留原有声明
 	CostMultiplier                float64           `json:"cost_multiplier"`
 	InputCostMultiplier           float64           `json:"input_cost_multiplier"`
 	OutputCostMultiplier          float64           `json:"output_cost_multiplier"`
@@ -257,6 +260,8 @@ func (a *App) CreateEndpointRecord(input CreateEndpointInput) error {
 		input.CacheCreationCostMultiplier1h = 1.0
 	}
 
+	capabilities := input.Capabilities
+
 	record := &store.EndpointRecord{
 		Channel:                       input.Channel,
 		Name:                          input.Name,
@@ -269,6 +274,7 @@ func (a *App) CreateEndpointRecord(input CreateEndpointInput) error {
 		CooldownSeconds:               input.CooldownSeconds,
 		TimeoutSeconds:                input.TimeoutSeconds,
 		SupportsCountTokens:           input.SupportsCountTokens,
+		Capabilities:                  capabilities,
 		CostMultiplier:                input.CostMultiplier,
 		InputCostMultiplier:           input.InputCostMultiplier,
 		OutputCostMultiplier:          input.OutputCostMultiplier,
@@ -381,6 +387,12 @@ func (a *App) UpdateEndpointRecord(name string, input CreateEndpointInput) error
 		cacheCreationCostMultiplier1h = existingRecord.CacheCreationCostMultiplier1h
 	}
 
+	// 兼容：前端未传能力声明时，保留旧值
+	capabilities := input.Capabilities
+	if capabilities == nil {
+		capabilities = existingRecord.Capabilities
+	}
+
 	record := &store.EndpointRecord{
 		ID:                            existingRecord.ID,
 		Channel:                       channel,
@@ -394,6 +406,7 @@ func (a *App) UpdateEndpointRecord(name string, input CreateEndpointInput) error
 		CooldownSeconds:               input.CooldownSeconds,
 		TimeoutSeconds:                input.TimeoutSeconds,
 		SupportsCountTokens:           input.SupportsCountTokens,
+		Capabilities:                  capabilities,
 		CostMultiplier:                input.CostMultiplier,
 		InputCostMultiplier:           input.InputCostMultiplier,
 		OutputCostMultiplier:          input.OutputCostMultiplier,
@@ -435,6 +448,9 @@ func (a *App) UpdateEndpointRecord(name string, input CreateEndpointInput) error
 			"UPDATE usage_summary SET endpoint_name = ? WHERE endpoint_name = ? AND group_name = ?",
 			newName, existingRecord.Name, channel,
 		)
+		if usageTracker != nil {
+			_ = usageTracker.RebuildUsageRollups(ctx, time.Time{}, time.Time{})
+		}
 	}
 
 	// v5.0: 更新成功后，异步同步端点倍率到 UsageTracker
@@ -512,6 +528,12 @@ func (a *App) UpdateEndpointRecordByID(id int64, input CreateEndpointInput) erro
 		cacheCreationCostMultiplier1h = existingRecord.CacheCreationCostMultiplier1h
 	}
 
+	// 兼容：前端未传能力声明时，保留旧值
+	capabilities := input.Capabilities
+	if capabilities == nil {
+		capabilities = existingRecord.Capabilities
+	}
+
 	record := &store.EndpointRecord{
 		ID:                            existingRecord.ID,
 		Channel:                       channel,
@@ -525,6 +547,7 @@ func (a *App) UpdateEndpointRecordByID(id int64, input CreateEndpointInput) erro
 		CooldownSeconds:               input.CooldownSeconds,
 		TimeoutSeconds:                input.TimeoutSeconds,
 		SupportsCountTokens:           input.SupportsCountTokens,
+		Capabilities:                  capabilities,
 		CostMultiplier:                input.CostMultiplier,
 		InputCostMultiplier:           input.InputCostMultiplier,
 		OutputCostMultiplier:          input.OutputCostMultiplier,
@@ -564,6 +587,9 @@ func (a *App) UpdateEndpointRecordByID(id int64, input CreateEndpointInput) erro
 			"UPDATE usage_summary SET endpoint_name = ? WHERE endpoint_name = ? AND group_name = ?",
 			newName, existingRecord.Name, channel,
 		)
+		if usageTracker != nil {
+			_ = usageTracker.RebuildUsageRollups(ctx, time.Time{}, time.Time{})
+		}
 	}
 
 	go a.syncEndpointMultipliersToTracker(context.Background())
@@ -800,11 +826,12 @@ func (a *App) ToggleEndpointRecordByID(id int64, enabled bool) error {
 
 // ChannelInfo 渠道信息
 type ChannelInfo struct {
-	Name          string `json:"name"`
-	Website       string `json:"website,omitempty"`
-	Priority      int    `json:"priority"`
-	CreatedAt     string `json:"created_at"`
-	EndpointCount int    `json:"endpoint_count"`
+	Name            string `json:"name"`
+	Website         string `json:"website,omitempty"`
+	Priority        int    `json:"priority"`
+	BillingCurrency string `json:"billing_currency"` // 结算币种（ISO 4217）
+	CreatedAt       string `json:"created_at"`
+	EndpointCount   int    `json:"endpoint_count"`
 }
 
 // GetChannels 获取所有渠道
@@ -851,6 +878,7 @@ func (a *App) GetChannels() ([]ChannelInfo, error) {
 
 	channelWebsite := make(map[string]string)
 	channelPriority := make(map[string]int)
+	channelCurrency := make(map[string]string)
 	channelCreatedAt := make(map[string]string)
 	orderedChannels := make([]string, 0, 16)
 	if channelService != nil {
@@ -863,6 +891,7 @@ func (a *App) GetChannels() ([]ChannelInfo, error) {
 				continue
 			}
 			channelWebsite[c.Name] = c.Website
+			channelCurrency[c.Name] = c.BillingCurrency
 			if c.Priority <= 0 {
 				channelPriority[c.Name] = 1
 			} else {
@@ -885,11 +914,12 @@ func (a *App) GetChannels() ([]ChannelInfo, error) {
 	for _, name := range orderedChannels {
 		count := channelCount[name]
 		result = append(result, ChannelInfo{
-			Name:          name,
-			Website:       channelWebsite[name],
-			Priority:      channelPriority[name],
-			CreatedAt:     channelCreatedAt[name],
-			EndpointCount: count,
+			Name:            name,
+			Website:         channelWebsite[name],
+			Priority:        channelPriority[name],
+			BillingCurrency: channelCurrency[name],
+			CreatedAt:       channelCreatedAt[name],
+			EndpointCount:   count,
 		})
 		seen[name] = struct{}{}
 	}
@@ -900,11 +930,12 @@ func (a *App) GetChannels() ([]ChannelInfo, error) {
 			continue
 		}
 		result = append(result, ChannelInfo{
-			Name:          name,
-			Website:       channelWebsite[name],
-			Priority:      999,
-			CreatedAt:     "",
-			EndpointCount: count,
+			Name:            name,
+			Website:         channelWebsite[name],
+			Priority:        999,
+			BillingCurrency: tracking.BaseCurrency,
+			CreatedAt:       "",
+			EndpointCount:   count,
 		})
 	}
 
@@ -930,9 +961,10 @@ func (a *App) GetChannels() ([]ChannelInfo, error) {
 }
 
 type CreateChannelInput struct {
-	Name     string `json:"name"`
-	Website  string `json:"website,omitempty"`
-	Priority int    `json:"priority"`
+	Name            string `json:"name"`
+	Website         string `json:"website,omitempty"`
+	Priority        int    `json:"priority"`
+	BillingCurrency string `json:"billing_currency,omitempty"` // 结算币种（为空时使用 USD）
 }
 
 func (a *App) CreateChannel(input CreateChannelInput) error {
@@ -958,9 +990,10 @@ func (a *App) CreateChannel(input CreateChannelInput) error {
 	defer cancel()
 
 	_, err := channelService.CreateChannel(ctx, &store.ChannelRecord{
-		Name:     input.Name,
-		Website:  input.Website,
-		Priority: input.Priority,
+		Name:            input.Name,
+		Website:         input.Website,
+		Priority:        input.Priority,
+		BillingCurrency: input.BillingCurrency,
 	})
 	if err != nil {
 		return err
@@ -974,13 +1007,16 @@ func (a *App) CreateChannel(input CreateChannelInput) error {
 		a.syncChannelPrioritiesToEndpointManager(ctx)
 		a.syncChannelFailoverEnabledToEndpointManager(ctx)
 	}
+	// 同步渠道结算币种（用于多币种记账）
+	a.syncCurrencyToTracker(ctx)
 	return nil
 }
 
 type UpdateChannelInput struct {
-	Name     string `json:"name"`
-	Website  string `json:"website,omitempty"`
-	Priority int    `json:"priority"`
+	Name            string `json:"name"`
+	Website         string `json:"website,omitempty"`
+	Priority        int    `json:"priority"`
+	BillingCurrency string `json:"billing_currency,omitempty"` // 结算币种（为空时保持原值）
 }
 
 func (a *App) UpdateChannel(input UpdateChannelInput) error {
@@ -1007,9 +1043,10 @@ func (a *App) UpdateChannel(input UpdateChannelInput) error {
 	defer cancel()
 
 	if err := channelService.UpdateChannel(ctx, &store.ChannelRecord{
-		Name:     input.Name,
-		Website:  input.Website,
-		Priority: input.Priority,
+		Name:            input.Name,
+		Website:         input.Website,
+		Priority:        input.Priority,
+		BillingCurrency: input.BillingCurrency,
 	}); err != nil {
 		return err
 	}
@@ -1018,6 +1055,7 @@ func (a *App) UpdateChannel(input UpdateChannelInput) error {
 		a.syncChannelPrioritiesToEndpointManager(ctx)
 		a.syncChannelFailoverEnabledToEndpointManager(ctx)
 	}
+	a.syncCurrencyToTracker(ctx)
 
 	// 推送前端刷新（channelsMeta & groups）
 	a.emitEndpointUpdate()
@@ -1268,6 +1306,7 @@ func (a *App) recordToInfo(r *store.EndpointRecord) EndpointRecordInfo {
 		CooldownSeconds:             r.CooldownSeconds,
 		TimeoutSeconds:              r.TimeoutSeconds,
 		SupportsCountTokens:         r.SupportsCountTokens,
+		Capabilities:                r.Capabilities,
 		CostMultiplier:              r.CostMultiplier,
 		InputCostMultiplier:         r.InputCostMultiplier,
 		OutputCostMultiplier:        r.OutputCostMultiplier,
@@ -0,0 +1,239 @@
+// app_api_tags.go - 请求标签归属 API (Wails Bindings)
+// 按项目/成本中心等标签聚合用量，支持标签筛选与按条件导出请求记录
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"cc-forwarder/internal/tracking"
+)
+
+// tagUsageDefaultDays 标签用量未指定时间范围时的默认回溯天数
+const tagUsageDefaultDays = 30
+
+// TagUsageQueryParams 标签用量查询参数
+type TagUsageQueryParams struct {
+	TagKey    string   `json:"tag_key"`    // 分组标签键，如 project
+	StartDate string   `json:"start_date"` // 格式：2025-12-05T00:00 或 2025-12-05T00:00:00+08:00，默认最近 30 天
+	EndDate   string   `json:"end_date"`   // 格式：2025-12-05T23:59 或 2025-12-05T23:59:59+08:00
+	Model     string   `json:"model"`      // 可选：模型名称
+	Channel   string   `json:"channel"`    // 可选：渠道名称
+	Endpoint  string   `json:"endpoint"`   // 可选：端点名称
+	Tags      []string `json:"tags"`       // 可选：标签筛选，格式 key=value，多个为 AND 关系
+}
+
+// TagUsageItem 按标签值聚合的用量（给前端用的结构体）
+type TagUsageItem struct {
+	TagKey        string  `json:"tag_key"`
+	TagValue      string  `json:"tag_value"` // 空字符串表示未打该标签的请求
+	RequestCount  int     `json:"request_count"`
+	SuccessCount  int     `json:"success_count"`
+	FailedCount   int     `json:"failed_count"`
+	InputTokens   int64   `json:"input_tokens"`
+	OutputTokens  int64   `json:"output_tokens"`
+	CacheCreation int64   `json:"cache_creation_tokens"`
+	CacheRead     int64   `json:"cache_read_tokens"`
+	TotalTokens   int64   `json:"total_tokens"`
+	Cost          float64 `json:"cost"`
+}
+
+// RequestExportParams 请求记录导出参数
+type RequestExportParams struct {
+	Format          string   `json:"format"`           // csv（默认）或 json
+	StartDate       string   `json:"start_date"`       // 默认今天
+	EndDate         string   `json:"end_date"`         // 默认今天
+	Status          string   `json:"status"`           // 可选：状态
+	Model           string   `json:"model"`            // 可选：模型名称
+	Channel         string   `json:"channel"`          // 可选：渠道名称
+	Endpoint        string   `json:"endpoint"`         // 可选：端点名称
+	Group           string   `json:"group"`            // 可选：组名称
+	SessionID       string   `json:"session_id"`       // 可选：会话标识
+	Tags            []string `json:"tags"`             // 可选：标签筛选，格式 key=value
+	DisplayCurrency string   `json:"display_currency"` // 可选：展示币种（默认报表币种）
+}
+
+// GetTagUsage 按标签值聚合用量（按成本倒序）
+func (a *App) GetTagUsage(params TagUsageQueryParams) ([]TagUsageItem, error) {
+	a.mu.RLock()
+	usageTracker := a.usageTracker
+	cfg := a.config
+	a.mu.RUnlock()
+
+	if usageTracker == nil {
+		return []TagUsageItem{}, nil
+	}
+
+	tagKey := strings.ToLower(strings.TrimSpace(params.TagKey))
+	if tagKey == "" {
+		return nil, fmt.Errorf("标签键不能为空")
+	}
+
+	loc := time.Local
+	if cfg != nil && cfg.Timezone != "" {
+		if l, err := time.LoadLocation(cfg.Timezone); err == nil {
+			loc = l
+		}
+	}
+
+	var startTime, endTime time.Time
+	if params.StartDate != "" {
+		if t, err := parseTimeWithLocation(params.StartDate, loc); err == nil {
+			startTime = t
+		}
+	}
+	if params.EndDate != "" {
+		if t, err := parseTimeWithLocation(params.EndDate, loc); err == nil {
+			endTime = t
+		}
+	}
+	if startTime.IsZero() {
+		now := time.Now().In(loc)
+		startTime = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc).AddDate(0, 0, -(tagUsageDefaultDays - 1))
+	}
+	if endTime.IsZero() {
+		now := time.Now().In(loc)
+		endTime = time.Date(now.Year(), now.Month(), now.Day(), 23, 59, 59, 999999999, loc)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), usageDBQueryTimeout)
+	defer cancel()
+
+	summaries, err := usageTracker.QueryTagUsage(ctx, &tracking.QueryOptions{
+		StartDate:    &startTime,
+		EndDate:      &endTime,
+		ModelName:    params.Model,
+		Channel:      params.Channel,
+		EndpointName: params.Endpoint,
+		Tags:         parseTagFilters(params.Tags),
+	}, tagKey)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]TagUsageItem, 0, len(summaries))
+	for _, s := range summaries {
+		items = append(items, TagUsageItem{
+			TagKey:        s.TagKey,
+			TagValue:      s.TagValue,
+			RequestCount:  s.RequestCount,
+			SuccessCount:  s.SuccessCount,
+			FailedCount:   s.FailedCount,
+			InputTokens:   s.InputTokens,
+			OutputTokens:  s.OutputTokens,
+			CacheCreation: s.CacheCreationTokens,
+			CacheRead:     s.CacheReadTokens,
+			TotalTokens:   s.TotalTokens,
+			Cost:          s.TotalCostUSD,
+		})
+	}
+	return items, nil
+}
+
+// GetRequestTags 获取已记录的标签键及取值（用于筛选下拉框，days<=0 表示不限时间）
+func (a *App) GetRequestTags(days int) (map[string][]string, error) {
+	a.mu.RLock()
+	usageTracker := a.usageTracker
+	a.mu.RUnlock()
+
+	if usageTracker == nil {
+		return map[string][]string{}, nil
+	}
+
+	var since time.Time
+	if days > 0 {
+		since = time.Now().AddDate(0, 0, -days)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), usageDBQueryTimeout)
+	defer cancel()
+
+	return usageTracker.ListRequestTags(ctx, since)
+}
+
+// ExportRequests 按筛选条件导出请求记录（CSV 含 session_id 与 tags 列），返回文件内容
+func (a *App) ExportRequests(params RequestExportParams) (string, error) {
+	a.mu.RLock()
+	usageTracker := a.usageTracker
+	cfg := a.config
+	a.mu.RUnlock()
+
+	if usageTracker == nil {
+		return "", fmt.Errorf("使用跟踪未启用")
+	}
+
+	loc := time.Local
+	if cfg != nil && cfg.Timezone != "" {
+		if l, err := time.LoadLocation(cfg.Timezone); err == nil {
+			loc = l
+		}
+	}
+
+	var startTime, endTime time.Time
+	if params.StartDate != "" {
+		if t, err := parseTimeWithLocation(params.StartDate, loc); err == nil {
+			startTime = t
+		}
+	}
+	if params.EndDate != "" {
+		if t, err := parseTimeWithLocation(params.EndDate, loc); err == nil {
+			endTime = t
+		}
+	}
+	if startTime.IsZero() {
+		now := time.Now().In(loc)
+		startTime = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	}
+	if endTime.IsZero() {
+		now := time.Now().In(loc)
+		endTime = time.Date(now.Year(), now.Month(), now.Day(), 23, 59, 59, 999999999, loc)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	opts := &tracking.QueryOptions{
+		StartDate:    &startTime,
+		EndDate:      &endTime,
+		ModelName:    params.Model,
+		Channel:      params.Channel,
+		EndpointName: params.Endpoint,
+		GroupName:    params.Group,
+		Status:       params.Status,
+		SessionID:    params.SessionID,
+		Tags:         parseTagFilters(params.Tags),
+	}
+
+	var data []byte
+	var err error
+	switch strings.ToLower(params.Format) {
+	case "", "csv":
+		data, err = usageTracker.ExportToCSVWithOptions(ctx, opts, params.DisplayCurrency)
+	case "json":
+		data, err = usageTracker.ExportToJSONWithOptions(ctx, opts, params.DisplayCurrency)
+	default:
+		return "", fmt.Errorf("不支持的导出格式: %s", params.Format)
+	}
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// parseTagFilters 解析 key=value 形式的标签筛选条件（键统一小写，忽略格式错误的条目）
+func parseTagFilters(filters []string) []tracking.RequestTag {
+	var tags []tracking.RequestTag
+	for _, f := range filters {
+		key, value, ok := strings.Cut(f, "=")
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+		if !ok || key == "" || value == "" {
+			continue
+		}
+		tags = append(tags, tracking.RequestTag{Key: key, Value: value})
+	}
+	return tags
+}
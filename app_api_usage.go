@@ -31,12 +31,14 @@ type UsageSummary struct {
 	AllTimeTotalCost     float64 `json:"all_time_total_cost"`   // 全部历史成本（数据库）
 	TodayTokens          int64   `json:"today_tokens"`          // 今日 tokens（数据库）
 	AllTimeTotalTokens   int64   `json:"all_time_total_tokens"` // 全部历史 tokens（数据库）
+	Currency             string  `json:"currency"`              // 成本展示币种
 }
 
 // GetUsageSummary 获取使用统计摘要
 // 当没有传递时间参数时，返回运行时统计（从内存）+ 全部历史请求总数（从数据库）
 // 当传递时间参数时，返回历史数据（从数据库）
-func (a *App) GetUsageSummary(startTimeStr, endTimeStr string) (UsageSummary, error) {
+// currency 为成本展示币种（为空时使用报表币种，缺少汇率时回退为 USD，实际币种见返回值 Currency）
+func (a *App) GetUsageSummary(startTimeStr, endTimeStr, currency string) (UsageSummary, error) {
 	a.mu.RLock()
 	monitoringMiddleware := a.monitoringMiddleware
 	usageTracker := a.usageTracker
@@ -44,6 +46,22 @@ func (a *App) GetUsageSummary(startTimeStr, endTimeStr string) (UsageSummary, er
 	logger := a.logger
 	a.mu.RUnlock()
 
+	currency = usageTracker.ResolveDisplayCurrency(currency)
+	costExpr := "total_cost_usd"
+	var costArgs []interface{}
+	if usageTracker != nil {
+		expr, args, err := usageTracker.DisplayCostExpr(currency)
+		if err != nil {
+			// 缺少汇率时回退为 USD 展示，避免摘要（含推送给前端的实时更新）整体归零
+			if logger != nil {
+				logger.Warn("⚠️ 展示币种缺少汇率，使用统计回退为 USD", "currency", currency, "error", err)
+			}
+			currency = tracking.BaseCurrency
+		} else {
+			costExpr, costArgs = expr, args
+		}
+	}
+
 	// 如果没有传递时间参数，返回运行时统计（与 Web 版本 /api/v1/connections 一致）
 	if startTimeStr == "" && endTimeStr == "" {
 		if monitoringMiddleware == nil {
@@ -77,7 +95,7 @@ func (a *App) GetUsageSummary(startTimeStr, endTimeStr string) (UsageSummary, er
 
 			// 直接从 request_logs 表查询全部历史统计
 			ctx, cancel := context.WithTimeout(context.Background(), usageDBQueryTimeout)
-			allTimeTotalCost, allTimeTotalTokens, allTimeTotal = queryStatsFromDB(ctx, logger, usageTracker, time.Time{}, time.Now())
+			allTimeTotalCost, allTimeTotalTokens, allTimeTotal = queryStatsFromDB(ctx, logger, usageTracker, time.Time{}, time.Now(), costExpr, costArgs)
 			cancel()
 
 			// 查询今日统计（使用配置的时区）
@@ -85,7 +103,7 @@ func (a *App) GetUsageSummary(startTimeStr, endTimeStr string) (UsageSummary, er
 			todayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
 			todayEnd := todayStart.Add(24 * time.Hour)
 			ctx, cancel = context.WithTimeout(context.Background(), usageDBQueryTimeout)
-			todayCost, todayTokens, todayRequests = queryStatsFromDB(ctx, logger, usageTracker, todayStart, todayEnd)
+			todayCost, todayTokens, todayRequests = queryStatsFromDB(ctx, logger, usageTracker, todayStart, todayEnd, costExpr, costArgs)
 			cancel()
 		}
 
@@ -102,6 +120,7 @@ func (a *App) GetUsageSummary(startTimeStr, endTimeStr string) (UsageSummary, er
 			AllTimeTotalCost:     allTimeTotalCost,
 			TodayTokens:          todayTokens,
 			AllTimeTotalTokens:   allTimeTotalTokens,
+			Currency:             currency,
 		}, nil
 	}
 
@@ -134,45 +153,74 @@ func (a *App) GetUsageSummary(startTimeStr, endTimeStr string) (UsageSummary, er
 
 	ctx, cancel := context.WithTimeout(context.Background(), usageDBQueryTimeout)
 	defer cancel()
-	summaries, err := usageTracker.GetUsageSummary(ctx, startTime, endTime)
+	// 长范围由小时/日汇总表回答，首尾零碎部分查询 request_logs
+	totals, err := usageTracker.QueryUsageStatsTotals(ctx, &tracking.QueryOptions{
+		StartDate: &startTime,
+		EndDate:   &endTime,
+	})
 	if err != nil {
 		return UsageSummary{}, err
 	}
 
-	// 聚合所有摘要
-	result := UsageSummary{}
-	for _, s := range summaries {
-		result.TotalRequests += int64(s.RequestCount)
-		result.SuccessRequests += int64(s.SuccessCount)
-		result.FailedRequests += int64(s.ErrorCount)
-		result.TotalInputTokens += s.TotalInputTokens
-		result.TotalOutputTokens += s.TotalOutputTokens
-		result.TotalCost += s.TotalCostUSD
+	result := UsageSummary{
+		TotalRequests:     totals.TotalRequests,
+		SuccessRequests:   totals.SuccessRequests,
+		FailedRequests:    totals.FailedRequests,
+		TotalInputTokens:  totals.InputTokens,
+		TotalOutputTokens: totals.OutputTokens,
+		TotalCost:         totals.TotalCostUSD,
+		Currency:          currency,
+	}
+
+	// 汇总表仅记录 USD 成本，其他展示币种从 request_logs 按多币种金额汇总
+	if currency != tracking.BaseCurrency {
+		result.TotalCost, _, _ = queryStatsFromDB(ctx, logger, usageTracker, startTime, endTime, costExpr, costArgs)
 	}
 
 	return result, nil
 }
 
 // queryStatsFromDB 直接从 request_logs 表查询成本、tokens 和请求数
-func queryStatsFromDB(ctx context.Context, logger *slog.Logger, usageTracker *tracking.UsageTracker, startTime, endTime time.Time) (cost float64, tokens int64, requests int64) {
+// costExpr/costArgs 为展示币种成本表达式（见 UsageTracker.DisplayCostExpr）
+func queryStatsFromDB(ctx context.Context, logger *slog.Logger, usageTracker *tracking.UsageTracker, startTime, endTime time.Time, costExpr string, costArgs []interface{}) (cost float64, tokens int64, requests int64) {
 	if usageTracker == nil {
 		return 0, 0, 0
 	}
 
+	// USD 成本可由汇总表回答（按范围自动选择汇总表或 request_logs）
+	if len(costArgs) == 0 && costExpr == "total_cost_usd" {
+		opts := &tracking.QueryOptions{}
+		if !startTime.IsZero() {
+			opts.StartDate = &startTime
+		}
+		if !endTime.IsZero() {
+			end := endTime.Add(-time.Nanosecond)
+			opts.EndDate = &end
+		}
+		totals, err := usageTracker.QueryUsageStatsTotals(ctx, opts)
+		if err != nil {
+			if logger != nil {
+				logger.Debug("查询统计数据失败", "error", err)
+			}
+			return 0, 0, 0
+		}
+		return totals.TotalCostUSD, totals.TotalTokens, totals.TotalRequests
+	}
+
 	db := usageTracker.GetDB()
 	if db == nil {
 		return 0, 0, 0
 	}
 
 	var query string
-	var args []interface{}
+	args := append([]interface{}{}, costArgs...)
 
 	if startTime.IsZero() {
 		// 查询全部历史（包含所有 token 类型：输入、输出、缓存创建、缓存读取）
-		query = "SELECT COALESCE(SUM(total_cost_usd), 0), COALESCE(SUM(input_tokens + output_tokens + cache_creation_tokens + cache_read_tokens), 0), COUNT(*) FROM request_logs"
+		query = "SELECT COALESCE(SUM(" + costExpr + "), 0), COALESCE(SUM(input_tokens + output_tokens + cache_creation_tokens + cache_read_tokens), 0), COUNT(*) FROM request_logs"
 	} else {
 		// 查询指定时间范围（包含所有 token 类型）
-		query = "SELECT COALESCE(SUM(total_cost_usd), 0), COALESCE(SUM(input_tokens + output_tokens + cache_creation_tokens + cache_read_tokens), 0), COUNT(*) FROM request_logs WHERE start_time >= ? AND start_time < ?"
+		query = "SELECT COALESCE(SUM(" + costExpr + "), 0), COALESCE(SUM(input_tokens + output_tokens + cache_creation_tokens + cache_read_tokens), 0), COUNT(*) FROM request_logs WHERE start_time >= ? AND start_time < ?"
 		args = append(args, startTime, endTime)
 	}
 
@@ -201,15 +249,45 @@ type RequestRecord struct {
 	RetryCount            int     `json:"retry_count"`              // 重试次数
 	FailureReason         string  `json:"failure_reason,omitempty"` // 失败原因
 	CancelReason          string  `json:"cancel_reason,omitempty"`  // 取消原因
+	SessionID             string  `json:"session_id,omitempty"`     // 客户端会话标识（Claude Code 会话）
 	InputTokens           int64   `json:"input_tokens"`
 	OutputTokens          int64   `json:"output_tokens"`
 	CacheCreationTokens   int64   `json:"cache_creation_tokens"`    // 总缓存创建（向后兼容）
 	CacheCreation5mTokens int64   `json:"cache_creation_5m_tokens"` // v5.0.1: 5分钟缓存
 	CacheCreation1hTokens int64   `json:"cache_creation_1h_tokens"` // v5.0.1: 1小时缓存
 	CacheReadTokens       int64   `json:"cache_read_tokens"`
+	WebSearchRequests     int64   `json:"web_search_requests"` // 服务端 web search 次数
+	WebFetchRequests      int64   `json:"web_fetch_requests"`  // 服务端 web fetch 次数
+	ServerToolCost        float64 `json:"server_tool_cost"`    // 服务端工具成本
+	PricingTier           int64   `json:"pricing_tier"`        // 生效的长上下文定价档位阈值（0 表示基础价格）
+	IsBatch               bool    `json:"is_batch"`            // Message Batches 结果用量
+	PriceSource           string  `json:"price_source"`        // 价格来源（model_pricing / default_pricing / price_book:<名称>）
+	RequestFee            float64 `json:"request_fee"`         // 价目表按次固定费用
 	ResponseTime          int64   `json:"response_time"`
 	IsStreaming           bool    `json:"is_streaming"`
 	Cost                  float64 `json:"cost"`
+
+	Tags []tracking.RequestTag `json:"tags,omitempty"` // 请求标签（项目/成本中心归属）
+
+	// 响应时间线（毫秒，相对上游请求发出时刻；0 表示未测量）
+	TTFB               int64   `json:"ttfb"`                  // 上游响应头到达
+	FirstEventTime     int64   `json:"first_event_time"`      // 首个 SSE 事件到达
+	FirstTokenTime     int64   `json:"first_token_time"`      // 首个内容增量到达
+	OutputTokensPerSec float64 `json:"output_tokens_per_sec"` // 输出速率
+
+	// 响应元信息
+	StopReason        string `json:"stop_reason,omitempty"`         // 停止原因（max_tokens 表示被截断）
+	ResponseMessageID string `json:"response_message_id,omitempty"` // 上游响应消息ID
+	ToolUseCount      int    `json:"tool_use_count"`                // 工具调用数量
+	HasThinking       bool   `json:"has_thinking"`                  // 是否包含 thinking 内容块
+
+	// 请求参数（0 表示未指定或旧数据）
+	MaxTokens      int64    `json:"max_tokens"`
+	MessageCount   int      `json:"message_count"`
+	ToolCount      int      `json:"tool_count"`
+	ThinkingBudget int64    `json:"thinking_budget"`
+	Temperature    *float64 `json:"temperature,omitempty"`
+	RequestBytes   int64    `json:"request_bytes"`
 }
 
 // RequestListResult 请求列表结果
@@ -222,15 +300,22 @@ type RequestListResult struct {
 
 // RequestQueryParams 请求查询参数
 type RequestQueryParams struct {
-	Page      int    `json:"page"`
-	PageSize  int    `json:"page_size"`
-	StartDate string `json:"start_date"` // 格式：2025-12-05T00:00 或 2025-12-05T00:00:00+08:00
-	EndDate   string `json:"end_date"`   // 格式：2025-12-05T23:59 或 2025-12-05T23:59:59+08:00
-	Status    string `json:"status"`     // 可选：completed, failed, pending 等
-	Model     string `json:"model"`      // 可选：模型名称
-	Channel   string `json:"channel"`    // 可选：渠道名称（v5.0）
-	Endpoint  string `json:"endpoint"`   // 可选：端点名称
-	Group     string `json:"group"`      // 可选：组名称
+	Page      int      `json:"page"`
+	PageSize  int      `json:"page_size"`
+	StartDate string   `json:"start_date"` // 格式：2025-12-05T00:00 或 2025-12-05T00:00:00+08:00
+	EndDate   string   `json:"end_date"`   // 格式：2025-12-05T23:59 或 2025-12-05T23:59:59+08:00
+	Status    string   `json:"status"`     // 可选：completed, failed, pending 等
+	Model     string   `json:"model"`      // 可选：模型名称
+	Channel   string   `json:"channel"`    // 可选：渠道名称（v5.0）
+	Endpoint  string   `json:"endpoint"`   // 可选：端点名称
+	Group     string   `json:"group"`      // 可选：组名称
+	SessionID string   `json:"session_id"` // 可选：会话标识
+	Tags      []string `json:"tags"`       // 可选：标签筛选，格式 key=value，多个为 AND 关系
+
+	StopReason      string `json:"stop_reason"`       // 可选：停止原因（end_turn / max_tokens / tool_use 等）
+	HasToolUse      *bool  `json:"has_tool_use"`      // 可选：响应是否包含工具调用
+	HasThinking     *bool  `json:"has_thinking"`      // 可选：响应是否包含 thinking 内容块
+	MinRequestBytes int64  `json:"min_request_bytes"` // 可选：请求体最小字节数
 }
 
 // GetRequests 获取请求记录列表（热池+数据库双源查询）
@@ -301,8 +386,15 @@ func (a *App) GetRequests(params RequestQueryParams) (RequestListResult, error)
 		EndpointName: params.Endpoint,
 		GroupName:    params.Group,
 		Status:       params.Status,
+		SessionID:    params.SessionID,
+		Tags:         parseTagFilters(params.Tags),
 		Limit:        pageSize,
 		Offset:       offset,
+
+		StopReason:      params.StopReason,
+		HasToolUse:      params.HasToolUse,
+		HasThinking:     params.HasThinking,
+		MinRequestBytes: params.MinRequestBytes,
 	}
 
 	requests, total, err := usageTracker.QueryRequestDetailsWithHotPool(ctx, opts)
@@ -318,41 +410,102 @@ func (a *App) GetRequests(params RequestQueryParams) (RequestListResult, error)
 	}
 
 	for _, r := range requests {
-		// 使用统一的时间格式（2025-12-04 17:18:48）
-		// 数据库存储的就是配置时区的时间，直接格式化，不做时区转换
-		record := RequestRecord{
-			RequestID:             r.RequestID,
-			Timestamp:             r.StartTime.Format("2006-01-02 15:04:05"),
-			Channel:               r.Channel, // v5.0: 渠道标签
-			Endpoint:              r.EndpointName,
-			Group:                 r.GroupName,
-			Model:                 r.ModelName,
-			Status:                r.Status,
-			RetryCount:            r.RetryCount,
-			FailureReason:         r.FailureReason,
-			CancelReason:          r.CancelReason,
-			InputTokens:           r.InputTokens,
-			OutputTokens:          r.OutputTokens,
-			CacheCreationTokens:   r.CacheCreationTokens,
-			CacheCreation5mTokens: r.CacheCreation5mTokens, // v5.0.1+
-			CacheCreation1hTokens: r.CacheCreation1hTokens, // v5.0.1+
-			CacheReadTokens:       r.CacheReadTokens,
-			IsStreaming:           r.IsStreaming,
-			Cost:                  r.TotalCostUSD,
-		}
+		result.Requests = append(result.Requests, requestDetailToRecord(r))
+	}
 
-		// 处理指针字段
-		if r.HTTPStatusCode != nil {
-			record.HTTPStatus = *r.HTTPStatusCode
-		}
-		if r.DurationMs != nil {
-			record.ResponseTime = *r.DurationMs
-		}
+	return result, nil
+}
+
+// GetRequestAttempts 获取请求的上游尝试轨迹（每次重试/故障转移的端点、状态码、错误与决策）
+func (a *App) GetRequestAttempts(requestID string) ([]tracking.RequestAttempt, error) {
+	a.mu.RLock()
+	usageTracker := a.usageTracker
+	a.mu.RUnlock()
 
-		result.Requests = append(result.Requests, record)
+	if usageTracker == nil || requestID == "" {
+		return []tracking.RequestAttempt{}, nil
 	}
 
-	return result, nil
+	ctx, cancel := context.WithTimeout(context.Background(), usageDBQueryTimeout)
+	defer cancel()
+
+	attempts, err := usageTracker.QueryRequestAttempts(ctx, requestID)
+	if err != nil {
+		return nil, err
+	}
+	if attempts == nil {
+		attempts = []tracking.RequestAttempt{}
+	}
+	return attempts, nil
+}
+
+// requestDetailToRecord 转换请求明细为前端请求记录
+func requestDetailToRecord(r tracking.RequestDetail) RequestRecord {
+	// 使用统一的时间格式（2025-12-04 17:18:48）
+	// 数据库存储的就是配置时区的时间，直接格式化，不做时区转换
+	record := RequestRecord{
+		RequestID:             r.RequestID,
+		Timestamp:             r.StartTime.Format("2006-01-02 15:04:05"),
+		Channel:               r.Channel, // v5.0: 渠道标签
+		Endpoint:              r.EndpointName,
+		Group:                 r.GroupName,
+		Model:                 r.ModelName,
+		Status:                r.Status,
+		RetryCount:            r.RetryCount,
+		FailureReason:         r.FailureReason,
+		CancelReason:          r.CancelReason,
+		SessionID:             r.SessionID,
+		Tags:                  r.Tags,
+		InputTokens:           r.InputTokens,
+		OutputTokens:          r.OutputTokens,
+		CacheCreationTokens:   r.CacheCreationTokens,
+		CacheCreation5mTokens: r.CacheCreation5mTokens, // v5.0.1+
+		CacheCreation1hTokens: r.CacheCreation1hTokens, // v5.0.1+
+		CacheReadTokens:       r.CacheReadTokens,
+		WebSearchRequests:     r.WebSearchRequests,
+		WebFetchRequests:      r.WebFetchRequests,
+		ServerToolCost:        r.ServerToolCostUSD,
+		PricingTier:           r.PricingTier,
+		IsBatch:               r.IsBatch,
+		PriceSource:           r.PriceSource,
+		RequestFee:            r.RequestFeeUSD,
+		IsStreaming:           r.IsStreaming,
+		Cost:                  r.TotalCostUSD,
+
+		StopReason:        r.StopReason,
+		ResponseMessageID: r.ResponseMessageID,
+		ToolUseCount:      r.ToolUseCount,
+		HasThinking:       r.HasThinking,
+
+		MaxTokens:      r.MaxTokens,
+		MessageCount:   r.MessageCount,
+		ToolCount:      r.ToolCount,
+		ThinkingBudget: r.ThinkingBudget,
+		Temperature:    r.Temperature,
+		RequestBytes:   r.RequestBytes,
+	}
+
+	// 处理指针字段
+	if r.HTTPStatusCode != nil {
+		record.HTTPStatus = *r.HTTPStatusCode
+	}
+	if r.DurationMs != nil {
+		record.ResponseTime = *r.DurationMs
+	}
+	if r.TTFBMs != nil {
+		record.TTFB = *r.TTFBMs
+	}
+	if r.FirstEventMs != nil {
+		record.FirstEventTime = *r.FirstEventMs
+	}
+	if r.FirstTokenMs != nil {
+		record.FirstTokenTime = *r.FirstTokenMs
+	}
+	if r.OutputTokensPerSec != nil {
+		record.OutputTokensPerSec = *r.OutputTokensPerSec
+	}
+
+	return record
 }
 
 // ============================================================
@@ -372,14 +525,15 @@ type UsageStatsData struct {
 
 // UsageStatsQueryParams 使用统计查询参数
 type UsageStatsQueryParams struct {
-	Period    string `json:"period"`     // 时间周期: "1h", "1d", "7d", "30d", "90d"
-	StartDate string `json:"start_date"` // 开始时间（优先于 period）
-	EndDate   string `json:"end_date"`   // 结束时间（优先于 period）
-	Status    string `json:"status"`     // 可选：状态筛选
-	Model     string `json:"model"`      // 可选：模型筛选
-	Channel   string `json:"channel"`    // 可选：渠道筛选（v5.0）
-	Endpoint  string `json:"endpoint"`   // 可选：端点筛选
-	Group     string `json:"group"`      // 可选：组筛选
+	Period    string   `json:"period"`     // 时间周期: "1h", "1d", "7d", "30d", "90d"
+	StartDate string   `json:"start_date"` // 开始时间（优先于 period）
+	EndDate   string   `json:"end_date"`   // 结束时间（优先于 period）
+	Status    string   `json:"status"`     // 可选：状态筛选
+	Model     string   `json:"model"`      // 可选：模型筛选
+	Channel   string   `json:"channel"`    // 可选：渠道筛选（v5.0）
+	Endpoint  string   `json:"endpoint"`   // 可选：端点筛选
+	Group     string   `json:"group"`      // 可选：组筛选
+	Tags      []string `json:"tags"`       // 可选：标签筛选，格式 key=value
 }
 
 // GetUsageStats 获取使用统计（与 HTTP API 格式一致）
@@ -458,6 +612,7 @@ func (a *App) GetUsageStats(params UsageStatsQueryParams) (UsageStatsData, error
 			EndpointName: params.Endpoint,
 			GroupName:    params.Group,
 			Status:       params.Status,
+			Tags:         parseTagFilters(params.Tags),
 			Limit:        0,
 			Offset:       0,
 		}
@@ -0,0 +1,329 @@
+// app_api_webhook.go - Webhook 通知管理 API (Wails Bindings)
+// 故障转移、端点宕机/恢复、挂起超时、数据库错误与预算事件推送到通用 JSON / Slack / 飞书 / 钉钉 / 企业微信
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"cc-forwarder/internal/events"
+	"cc-forwarder/internal/service"
+	"cc-forwarder/internal/store"
+	"cc-forwarder/internal/webhook"
+)
+
+// WebhookInfo Webhook 目标信息（给前端用的结构体）
+type WebhookInfo struct {
+	ID                 int64    `json:"id"`
+	Name               string   `json:"name"`
+	Kind               string   `json:"kind"`
+	URL                string   `json:"url"`
+	Secret             string   `json:"secret"`        // 本地桌面应用，直接返回原始密钥
+	SecretMasked       string   `json:"secret_masked"` // 脱敏后的密钥（列表展示用）
+	EventTypes         []string `json:"event_types"`   // 为空表示订阅默认运维事件
+	DedupSeconds       int      `json:"dedup_seconds"`
+	RateLimitPerMinute int      `json:"rate_limit_per_minute"`
+	MaxRetries         int      `json:"max_retries"`
+	Enabled            bool     `json:"enabled"`
+	Description        string   `json:"description"`
+	UpdatedAt          string   `json:"updated_at"`
+}
+
+// SaveWebhookInput 创建/更新 Webhook 的输入参数
+type SaveWebhookInput struct {
+	Name               string   `json:"name"`
+	Kind               string   `json:"kind"`
+	URL                string   `json:"url"`
+	Secret             string   `json:"secret"` // 更新时为空表示保留原有密钥
+	EventTypes         []string `json:"event_types"`
+	DedupSeconds       int      `json:"dedup_seconds"`
+	RateLimitPerMinute int      `json:"rate_limit_per_minute"`
+	MaxRetries         int      `json:"max_retries"`
+	Enabled            bool     `json:"enabled"`
+	Description        string   `json:"description"`
+}
+
+// WebhookDeliveryInfo Webhook 投递记录
+type WebhookDeliveryInfo struct {
+	ID          int64  `json:"id"`
+	WebhookName string `json:"webhook_name"`
+	EventType   string `json:"event_type"`
+	DedupKey    string `json:"dedup_key"`
+	Status      string `json:"status"`
+	Attempts    int    `json:"attempts"`
+	HTTPStatus  int    `json:"http_status"`
+	Error       string `json:"error"`
+	Payload     string `json:"payload"`
+	CreatedAt   string `json:"created_at"`
+	DeliveredAt string `json:"delivered_at"`
+}
+
+// WebhookEventTypeInfo 可订阅的事件类型
+type WebhookEventTypeInfo struct {
+	Type    string `json:"type"`
+	Default bool   `json:"default"` // 未配置事件过滤时是否订阅
+}
+
+// GetWebhooks 获取所有 Webhook 目标
+func (a *App) GetWebhooks() ([]WebhookInfo, error) {
+	a.mu.RLock()
+	webhookService := a.webhookService
+	a.mu.RUnlock()
+
+	if webhookService == nil {
+		return nil, fmt.Errorf("Webhook 存储未就绪")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	records, err := webhookService.ListWebhooks(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]WebhookInfo, 0, len(records))
+	for _, r := range records {
+		result = append(result, webhookRecordToInfo(r))
+	}
+	return result, nil
+}
+
+// CreateWebhook 创建 Webhook 目标
+func (a *App) CreateWebhook(input SaveWebhookInput) (*WebhookInfo, error) {
+	a.mu.RLock()
+	webhookService := a.webhookService
+	logger := a.logger
+	a.mu.RUnlock()
+
+	if webhookService == nil {
+		return nil, fmt.Errorf("Webhook 存储未就绪")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	created, err := webhookService.CreateWebhook(ctx, input.toRecord())
+	if err != nil {
+		return nil, err
+	}
+
+	a.syncWebhooksToDispatcher(ctx)
+	if logger != nil {
+		logger.Info("✅ Webhook 已创建", "name", created.Name, "kind", created.Kind)
+	}
+
+	info := webhookRecordToInfo(created)
+	return &info, nil
+}
+
+// UpdateWebhook 更新 Webhook 目标（按名称定位）
+func (a *App) UpdateWebhook(name string, input SaveWebhookInput) error {
+	a.mu.RLock()
+	webhookService := a.webhookService
+	logger := a.logger
+	a.mu.RUnlock()
+
+	if webhookService == nil {
+		return fmt.Errorf("Webhook 存储未就绪")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	existing, err := webhookService.GetWebhook(ctx, name)
+	if err != nil {
+		return err
+	}
+	if existing == nil {
+		return fmt.Errorf("Webhook 不存在: %s", name)
+	}
+
+	record := input.toRecord()
+	record.Name = name
+	// 前端传空值时保留原有密钥（防止误删）
+	if record.Secret == "" {
+		record.Secret = existing.Secret
+	}
+	if err := webhookService.UpdateWebhook(ctx, record); err != nil {
+		return err
+	}
+
+	a.syncWebhooksToDispatcher(ctx)
+	if logger != nil {
+		logger.Info("✅ Webhook 已更新", "name", name)
+	}
+	return nil
+}
+
+// DeleteWebhook 删除 Webhook 目标
+func (a *App) DeleteWebhook(name string) error {
+	a.mu.RLock()
+	webhookService := a.webhookService
+	logger := a.logger
+	a.mu.RUnlock()
+
+	if webhookService == nil {
+		return fmt.Errorf("Webhook 存储未就绪")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := webhookService.DeleteWebhook(ctx, name); err != nil {
+		return err
+	}
+
+	a.syncWebhooksToDispatcher(ctx)
+	if logger != nil {
+		logger.Info("🗑️ Webhook 已删除", "name", name)
+	}
+	return nil
+}
+
+// TestWebhook 向 Webhook 目标发送测试消息（同步投递，含重试，结果写入投递记录）
+func (a *App) TestWebhook(name string) (*WebhookDeliveryInfo, error) {
+	a.mu.RLock()
+	webhookService := a.webhookService
+	dispatcher := a.webhookDispatcher
+	a.mu.RUnlock()
+
+	if webhookService == nil || dispatcher == nil {
+		return nil, fmt.Errorf("Webhook 存储未就绪")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	record, err := webhookService.GetWebhook(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if record == nil {
+		return nil, fmt.Errorf("Webhook 不存在: %s", name)
+	}
+
+	delivery := dispatcher.Send(ctx, service.ToWebhookTarget(record), events.Event{
+		Type:      webhook.EventTest,
+		Source:    "app",
+		Timestamp: time.Now(),
+		Priority:  events.PriorityNormal,
+		Data:      map[string]interface{}{"webhook": name},
+	})
+	if err := webhookService.RecordDelivery(ctx, delivery); err != nil {
+		a.logger.Warn("⚠️ 记录 Webhook 测试投递失败", "name", name, "error", err)
+	}
+
+	info := WebhookDeliveryInfo{
+		WebhookName: delivery.Webhook,
+		EventType:   delivery.EventType,
+		DedupKey:    delivery.DedupKey,
+		Status:      delivery.Status,
+		Attempts:    delivery.Attempts,
+		HTTPStatus:  delivery.HTTPStatus,
+		Error:       delivery.Error,
+		Payload:     delivery.Payload,
+		CreatedAt:   delivery.CreatedAt.Format("2006-01-02 15:04:05"),
+	}
+	if !delivery.DeliveredAt.IsZero() {
+		info.DeliveredAt = delivery.DeliveredAt.Format("2006-01-02 15:04:05")
+	}
+	return &info, nil
+}
+
+// GetWebhookDeliveries 获取最近的投递记录（name 为空时返回所有目标）
+func (a *App) GetWebhookDeliveries(name string, limit int) ([]WebhookDeliveryInfo, error) {
+	a.mu.RLock()
+	webhookService := a.webhookService
+	a.mu.RUnlock()
+
+	if webhookService == nil {
+		return nil, fmt.Errorf("Webhook 存储未就绪")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	records, err := webhookService.ListDeliveries(ctx, name, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]WebhookDeliveryInfo, 0, len(records))
+	for _, r := range records {
+		info := WebhookDeliveryInfo{
+			ID:          r.ID,
+			WebhookName: r.WebhookName,
+			EventType:   r.EventType,
+			DedupKey:    r.DedupKey,
+			Status:      r.Status,
+			Attempts:    r.Attempts,
+			HTTPStatus:  r.HTTPStatus,
+			Error:       r.Error,
+			Payload:     r.Payload,
+			CreatedAt:   r.CreatedAt.Format("2006-01-02 15:04:05"),
+		}
+		if r.DeliveredAt != nil {
+			info.DeliveredAt = r.DeliveredAt.Format("2006-01-02 15:04:05")
+		}
+		result = append(result, info)
+	}
+	return result, nil
+}
+
+// GetWebhookEventTypes 获取可订阅的事件类型
+func (a *App) GetWebhookEventTypes() []WebhookEventTypeInfo {
+	defaults := make(map[events.EventType]bool, len(webhook.DefaultEventTypes))
+	result := make([]WebhookEventTypeInfo, 0, len(events.EventTypeMapping))
+	for _, et := range webhook.DefaultEventTypes {
+		defaults[et] = true
+		result = append(result, WebhookEventTypeInfo{Type: string(et), Default: true})
+	}
+	for et := range events.EventTypeMapping {
+		if !defaults[et] {
+			result = append(result, WebhookEventTypeInfo{Type: string(et)})
+		}
+	}
+	return result
+}
+
+// toRecord 转换为存储记录
+func (in SaveWebhookInput) toRecord() *store.WebhookRecord {
+	return &store.WebhookRecord{
+		Name:               in.Name,
+		Kind:               in.Kind,
+		URL:                in.URL,
+		Secret:             in.Secret,
+		EventTypes:         in.EventTypes,
+		DedupSeconds:       in.DedupSeconds,
+		RateLimitPerMinute: in.RateLimitPerMinute,
+		MaxRetries:         in.MaxRetries,
+		Enabled:            in.Enabled,
+		Description:        in.Description,
+	}
+}
+
+// webhookRecordToInfo 转换 Webhook 记录为前端结构
+func webhookRecordToInfo(r *store.WebhookRecord) WebhookInfo {
+	eventTypes := r.EventTypes
+	if eventTypes == nil {
+		eventTypes = []string{}
+	}
+	return WebhookInfo{
+		ID:                 r.ID,
+		Name:               r.Name,
+		Kind:               r.Kind,
+		URL:                r.URL,
+		Secret:             r.Secret,
+		SecretMasked:       maskToken(r.Secret),
+		EventTypes:         eventTypes,
+		DedupSeconds:       r.DedupSeconds,
+		RateLimitPerMinute: r.RateLimitPerMinute,
+		MaxRetries:         r.MaxRetries,
+		Enabled:            r.Enabled,
+		Description:        r.Description,
+		UpdatedAt:          r.UpdatedAt.Format("2006-01-02 15:04:05"),
+	}
+}
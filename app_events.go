@@ -63,7 +63,7 @@ func (a *App) emitUsageUpdate() {
 		return
 	}
 
-	summary, _ := a.GetUsageSummary("", "")
+	summary, _ := a.GetUsageSummary("", "", "")
 	runtime.EventsEmit(a.ctx, EventUsageUpdate, summary)
 }
 
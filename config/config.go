@@ -3,6 +3,7 @@ package config
 import (
 	"fmt"
 	"log/slog"
+	"net"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -24,8 +25,11 @@ type Config struct {
 	Group            GroupConfig            `yaml:"group"`                   // Group configuration (DEPRECATED: use Failover instead)
 	Failover         FailoverConfig         `yaml:"failover"`                // Failover configuration (v4.0+)
 	RequestSuspend   RequestSuspendConfig   `yaml:"request_suspend"`         // Request suspension configuration
+	ResponseValidation ResponseValidationConfig `yaml:"response_validation"` // Relay fake-success detection
+	ModelDiscovery   ModelDiscoveryConfig   `yaml:"model_discovery"`         // Per-endpoint /v1/models discovery
 	UsageTracking    UsageTrackingConfig    `yaml:"usage_tracking"`          // Usage tracking configuration
 	TokenCounting    TokenCountingConfig    `yaml:"token_counting"`          // Token counting configuration
+	MessageBatches   MessageBatchesConfig   `yaml:"message_batches"`         // Message Batches passthrough and pricing
 	EndpointsStorage EndpointsStorageConfig `yaml:"endpoints_storage"`       // Endpoints storage configuration (v5.0+)
 	Proxy            ProxyConfig            `yaml:"proxy"`
 	Auth             AuthConfig             `yaml:"auth"`
@@ -64,6 +68,15 @@ type HealthConfig struct {
 	HealthPath    string        `yaml:"health_path"`
 }
 
+// ModelDiscoveryConfig 端点模型发现配置
+// 定期请求各端点的 /v1/models，记录每个端点支持的模型，用于按请求模型筛选端点
+type ModelDiscoveryConfig struct {
+	Enabled  bool          `yaml:"enabled"`  // 启用定期模型发现，默认: false
+	Interval time.Duration `yaml:"interval"` // 发现间隔，默认: 6h
+	Timeout  time.Duration `yaml:"timeout"`  // 单个端点的请求超时，默认: 30s
+	Path     string        `yaml:"path"`     // 模型列表路径，默认: /v1/models
+}
+
 type LoggingConfig struct {
 	Level              string           `yaml:"level"`
 	Format             string           `yaml:"format"`               // "json" or "text"
@@ -111,6 +124,14 @@ type RequestSuspendConfig struct {
 	EOFRetryHint       bool          `yaml:"eof_retry_hint"`        // Send retryable error format on EOF, default: false
 }
 
+// ResponseValidationConfig 响应校验配置
+// 部分中转站会以 200 返回错误 JSON、HTML 错误页、缺少 message_stop 的流或零输出的完成结果，
+// 启用后这些"假成功"响应会被判定为失败，参与重试/故障转移并冷却端点
+type ResponseValidationConfig struct {
+	Enabled    bool     `yaml:"enabled"`    // 启用响应校验，默认: false
+	Validators []string `yaml:"validators"` // 启用的校验器，默认: error_json, html_page, missing_message_stop, zero_output_tokens
+}
+
 // ModelPricing 模型定价配置
 // Deprecated: v5.0+ 定价配置已迁移到 SQLite model_pricing 表，通过前端「定价」页面管理
 // 保留此结构体仅为向后兼容，不再使用
@@ -137,6 +158,16 @@ type UsageTrackingConfig struct {
 	RetentionDays   int                      `yaml:"retention_days"`   // Data retention days (0=permanent), default: 90
 	CleanupInterval time.Duration            `yaml:"cleanup_interval"` // Cleanup task execution interval, default: 24h
 
+	// 统一报表币种（ISO 4217，成本额外按该币种换算存储并作为默认展示币种），默认: USD
+	ReportingCurrency string                 `yaml:"reporting_currency"`
+
+	// 会话标识请求头（请求体 metadata.user_id 中没有会话标识时使用），默认: X-Claude-Code-Session-Id
+	SessionHeader   string                   `yaml:"session_header"`
+
+	// 请求标签请求头（项目/成本中心归属，X-CC- 前缀去掉后作为标签键），默认: [X-CC-Project, X-CC-Tag]
+	// 配置为空列表 [] 可关闭请求头标签；/p/<project>/ 路径前缀始终生效
+	TagHeaders      []string                 `yaml:"tag_headers"`
+
 	// Deprecated: v5.0+ 以下定价配置已废弃，迁移到 SQLite model_pricing 表
 	// 通过前端「定价」页面管理，这些字段仅保留用于向后兼容解析
 	ModelPricing    map[string]ModelPricing  `yaml:"model_pricing,omitempty"`    // [废弃] Model pricing configuration
@@ -181,6 +212,17 @@ type ProxyConfig struct {
 type AuthConfig struct {
 	Enabled bool   `yaml:"enabled"`                   // Enable authentication, default: false
 	Token   string `yaml:"token,omitempty"`           // Bearer token for authentication
+
+	// 接受的鉴权方式: bearer / x-api-key / query，默认: [bearer, x-api-key]
+	Schemes    []string `yaml:"schemes,omitempty"`
+	QueryParam string   `yaml:"query_param,omitempty"` // query 方式的参数名，默认: token
+
+	// IP 访问控制（独立于 Token 鉴权），支持 CIDR 或单个 IP，拒绝列表优先
+	AllowCIDRs []string `yaml:"allow_cidrs,omitempty"`
+	DenyCIDRs  []string `yaml:"deny_cidrs,omitempty"`
+
+	// 监听非本机地址且未启用鉴权时的策略: refuse（拒绝启动）/ generate（自动生成 Token），默认: generate
+	NonLoopbackPolicy string `yaml:"non_loopback_policy,omitempty"`
 }
 
 // TUIConfig is DEPRECATED - TUI has been removed in v4.0
@@ -197,6 +239,12 @@ type TokenCountingConfig struct {
 	EstimationRatio float64 `yaml:"estimation_ratio"` // Token估算比例 (1 token ≈ N 字符)
 }
 
+// MessageBatchesConfig Message Batches 配置
+// 批处理结果用量按标准成本乘以计费倍率计入使用统计（Anthropic 批处理价格为标准价格的 50%）
+type MessageBatchesConfig struct {
+	CostMultiplier float64 `yaml:"cost_multiplier"` // 批处理计费倍率（相对标准价格），默认: 0.5
+}
+
 // EndpointsStorageConfig 端点存储配置 (v5.0+)
 // 支持从 YAML 文件或 SQLite 数据库加载端点配置
 type EndpointsStorageConfig struct {
@@ -219,6 +267,7 @@ type EndpointConfig struct {
 	Timeout             time.Duration     `yaml:"timeout"`
 	Headers             map[string]string `yaml:"headers,omitempty"`
 	SupportsCountTokens bool              `yaml:"supports_count_tokens,omitempty"` // 是否支持count_tokens端点
+	Capabilities        map[string]bool   `yaml:"capabilities,omitempty"`          // 能力声明: context_1m/thinking/batches/files/prompt_caching/web_search，未声明时自动探测
 	Enabled             *bool             `yaml:"enabled,omitempty"`               // v5.0: 是否激活为代理端点（SQLite模式），默认: true
 }
 
@@ -285,6 +334,12 @@ func (c *Config) setDefaults() {
 	if c.Server.Port == 0 {
 		c.Server.Port = 8080
 	}
+	if c.Auth.QueryParam == "" {
+		c.Auth.QueryParam = "token"
+	}
+	if c.Auth.NonLoopbackPolicy == "" {
+		c.Auth.NonLoopbackPolicy = "generate"
+	}
 	if c.Strategy.Type == "" {
 		c.Strategy.Type = "priority"
 	}
@@ -398,6 +453,24 @@ func (c *Config) setDefaults() {
 	}
 	// RequestSuspend.Enabled defaults to false (zero value) for backward compatibility
 
+	// Set model discovery defaults
+	if c.ModelDiscovery.Interval == 0 {
+		c.ModelDiscovery.Interval = 6 * time.Hour
+	}
+	if c.ModelDiscovery.Timeout == 0 {
+		c.ModelDiscovery.Timeout = 30 * time.Second
+	}
+	if c.ModelDiscovery.Path == "" {
+		c.ModelDiscovery.Path = "/v1/models"
+	}
+	// ModelDiscovery.Enabled defaults to false (zero value) for backward compatibility
+
+	// Set response validation defaults
+	if len(c.ResponseValidation.Validators) == 0 {
+		c.ResponseValidation.Validators = []string{"error_json", "html_page", "missing_message_stop", "zero_output_tokens"}
+	}
+	// ResponseValidation.Enabled defaults to false (zero value) for backward compatibility
+
 	// Set usage tracking defaults
 	// 兼容：若使用新版配置 usage_tracking.database.path，则统一写入 database_path 供内部使用。
 	if c.UsageTracking.Database != nil && strings.TrimSpace(c.UsageTracking.Database.Path) != "" {
@@ -428,6 +501,16 @@ func (c *Config) setDefaults() {
 	if c.UsageTracking.CleanupInterval == 0 {
 		c.UsageTracking.CleanupInterval = 24 * time.Hour // Default cleanup interval
 	}
+	if c.UsageTracking.ReportingCurrency == "" {
+		c.UsageTracking.ReportingCurrency = "USD" // Default reporting currency
+	}
+	c.UsageTracking.ReportingCurrency = strings.ToUpper(strings.TrimSpace(c.UsageTracking.ReportingCurrency))
+	if c.UsageTracking.SessionHeader == "" {
+		c.UsageTracking.SessionHeader = "X-Claude-Code-Session-Id" // Default session header (Claude Code)
+	}
+	if c.UsageTracking.TagHeaders == nil {
+		c.UsageTracking.TagHeaders = []string{"X-CC-Project", "X-CC-Tag"} // Default tag headers
+	}
 	// v5.0+ 注意：model_pricing 和 default_pricing 已废弃
 	// 定价配置现在从 SQLite model_pricing 表加载，通过前端「定价」页面管理
 	// 这里不再设置默认值，保留字段仅为向后兼容解析旧配置文件
@@ -442,6 +525,11 @@ func (c *Config) setDefaults() {
 	}
 	// TokenCounting.Enabled defaults to false (zero value) for backward compatibility
 
+	// Set Message Batches defaults
+	if c.MessageBatches.CostMultiplier == 0 {
+		c.MessageBatches.CostMultiplier = 0.5 // Default: 50% of standard pricing
+	}
+
 	// Set default timeouts for endpoints and handle parameter inheritance (except tokens)
 	var defaultEndpoint *EndpointConfig
 	if len(c.Endpoints) > 0 {
@@ -603,6 +691,28 @@ func (c *Config) validate() error {
 		}
 	}
 
+	// Validate inbound auth configuration
+	for _, scheme := range c.Auth.Schemes {
+		switch strings.ToLower(strings.TrimSpace(scheme)) {
+		case "bearer", "x-api-key", "query":
+		default:
+			return fmt.Errorf("unknown auth scheme '%s' (expected bearer, x-api-key or query)", scheme)
+		}
+	}
+	for _, cidr := range append(append([]string{}, c.Auth.AllowCIDRs...), c.Auth.DenyCIDRs...) {
+		value := strings.TrimSpace(cidr)
+		if strings.Contains(value, "/") {
+			if _, _, err := net.ParseCIDR(value); err != nil {
+				return fmt.Errorf("invalid auth CIDR '%s': %v", cidr, err)
+			}
+		} else if net.ParseIP(value) == nil {
+			return fmt.Errorf("invalid auth IP '%s'", cidr)
+		}
+	}
+	if c.Auth.NonLoopbackPolicy != "refuse" && c.Auth.NonLoopbackPolicy != "generate" {
+		return fmt.Errorf("auth non_loopback_policy must be 'refuse' or 'generate'")
+	}
+
 	// Validate request suspension configuration
 	if c.RequestSuspend.Enabled {
 		if c.RequestSuspend.Timeout <= 0 {
@@ -616,6 +726,20 @@ func (c *Config) validate() error {
 		}
 	}
 
+	// Validate response validation configuration
+	for _, name := range c.ResponseValidation.Validators {
+		switch name {
+		case "error_json", "html_page", "missing_message_stop", "zero_output_tokens":
+		default:
+			return fmt.Errorf("unknown response validator '%s'", name)
+		}
+	}
+
+	// Validate message batches configuration
+	if c.MessageBatches.CostMultiplier < 0 || c.MessageBatches.CostMultiplier > 1 {
+		return fmt.Errorf("message batches cost multiplier must be between 0 and 1")
+	}
+
 	// Validate usage tracking configuration
 	if c.UsageTracking.Enabled {
 		if c.UsageTracking.DatabasePath == "" {
@@ -643,6 +767,9 @@ func (c *Config) validate() error {
 			return fmt.Errorf("cleanup interval must be greater than 0 when retention is enabled")
 		}
 	}
+	if currency := c.UsageTracking.ReportingCurrency; currency != "" && !isCurrencyCode(currency) {
+		return fmt.Errorf("usage_tracking.reporting_currency must be a 3-letter ISO 4217 code, got %q", currency)
+	}
 
 	for i, endpoint := range c.Endpoints {
 		if endpoint.Name == "" {
@@ -651,6 +778,13 @@ func (c *Config) validate() error {
 		if endpoint.URL == "" {
 			return fmt.Errorf("endpoint %s: URL is required", endpoint.Name)
 		}
+		for capability := range endpoint.Capabilities {
+			switch capability {
+			case "context_1m", "thinking", "batches", "files", "prompt_caching", "web_search":
+			default:
+				return fmt.Errorf("endpoint %s: unknown capability '%s'", endpoint.Name, capability)
+			}
+		}
 		if endpoint.Priority < 0 {
 			return fmt.Errorf("endpoint %s: priority must be non-negative", endpoint.Name)
 		}
@@ -1052,3 +1186,16 @@ func getConfigAppDataDir() string {
 		return filepath.Join(homeDir, ".cc-forwarder")
 	}
 }
+
+// isCurrencyCode 判断是否为 3 位大写字母的币种代码（ISO 4217）
+func isCurrencyCode(code string) bool {
+	if len(code) != 3 {
+		return false
+	}
+	for _, r := range code {
+		if r < 'A' || r > 'Z' {
+			return false
+		}
+	}
+	return true
+}
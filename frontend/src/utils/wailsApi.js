@@ -158,10 +158,19 @@ export const getSystemStatus = async () => {
     proxy_host: status.proxy_host,
     active_group: status.active_group,
     config_path: status.config_path,
-    auth_enabled: status.auth_enabled
+    auth_enabled: status.auth_enabled,
+    auth_token_generated: status.auth_token_generated
   };
 };
 
+// 入站访问 Token 不随状态推送，需要时单独获取
+export const getInboundAuthToken = async () => {
+  await initWails();
+  if (!WailsApp) throw new Error('Wails not available');
+
+  return await WailsApp.GetInboundAuthToken();
+};
+
 export const getConfig = async () => {
   await initWails();
   if (!WailsApp) throw new Error('Wails not available');
@@ -467,11 +476,12 @@ export const getUsageStats = async (params = {}) => {
   };
 };
 
-export const getUsageSummary = async (startTime = '', endTime = '') => {
+export const getUsageSummary = async (startTime = '', endTime = '', currency = '') => {
   await initWails();
   if (!WailsApp) throw new Error('Wails not available');
 
-  const summary = await WailsApp.GetUsageSummary(startTime, endTime);
+  // currency 为空时使用后端配置的报表币种
+  const summary = await WailsApp.GetUsageSummary(startTime, endTime, currency);
 
   return {
     total_requests: summary.total_requests || 0,
@@ -486,6 +496,7 @@ export const getUsageSummary = async (startTime = '', endTime = '') => {
     all_time_total_cost: summary.all_time_total_cost || 0,  // 全部历史成本
     today_tokens: summary.today_tokens || 0,                // 今日 tokens
     all_time_total_tokens: summary.all_time_total_tokens || 0,  // 全部历史 tokens
+    currency: summary.currency || 'USD',                    // 成本展示币种
     total_tokens: (summary.total_input_tokens || 0) + (summary.total_output_tokens || 0)
   };
 };
@@ -670,14 +681,15 @@ export const getEndpointHealthChart = async () => {
 
 /**
  * 获取当日端点成本数据
- * @returns {Promise<Array>} - 端点成本数据 [{name, tokens, cost}]
+ * @param {string} currency - 展示币种（为空时使用报表币种）
+ * @returns {Promise<Array>} - 端点成本数据 [{name, tokens, cost, currency}]
  */
-export const getEndpointCosts = async () => {
+export const getEndpointCosts = async (currency = '') => {
   await initWails();
   if (!WailsApp) throw new Error('Wails not available');
 
-  const data = await WailsApp.GetEndpointCosts();
-  // 数据已经是 [{name, tokens, cost}] 格式
+  const data = await WailsApp.GetEndpointCosts(currency);
+  // 数据已经是 [{name, tokens, cost, currency}] 格式
   return data || [];
 };
 
@@ -33,7 +33,7 @@ export function GetConfig():Promise<main.ConfigInfo>;
 
 export function GetConnectionActivityChart(arg1:number):Promise<Array<main.ChartDataPoint>>;
 
-export function GetEndpointCosts():Promise<Array<main.EndpointCostItem>>;
+export function GetEndpointCosts(arg1:string):Promise<Array<main.EndpointCostItem>>;
 
 export function GetEndpointHealthChart():Promise<main.EndpointHealthData>;
 
@@ -49,6 +49,8 @@ export function GetEndpointsByChannel(arg1:string):Promise<Array<main.EndpointRe
 
 export function GetGroups():Promise<Array<main.GroupInfo>>;
 
+export function GetInboundAuthToken():Promise<string>;
+
 export function GetKeysOverview():Promise<main.KeysOverviewResult>;
 
 export function GetLogStreamStatus():Promise<boolean>;
@@ -85,7 +87,7 @@ export function GetTokenUsage():Promise<main.TokenUsageData>;
 
 export function GetUsageStats(arg1:main.UsageStatsQueryParams):Promise<main.UsageStatsData>;
 
-export function GetUsageSummary(arg1:string,arg2:string):Promise<main.UsageSummary>;
+export function GetUsageSummary(arg1:string,arg2:string,arg3:string):Promise<main.UsageSummary>;
 
 export function HideMainWindow():Promise<void>;
 
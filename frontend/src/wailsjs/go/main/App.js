@@ -62,8 +62,8 @@ export function GetConnectionActivityChart(arg1) {
   return window['go']['main']['App']['GetConnectionActivityChart'](arg1);
 }
 
-export function GetEndpointCosts() {
-  return window['go']['main']['App']['GetEndpointCosts']();
+export function GetEndpointCosts(arg1) {
+  return window['go']['main']['App']['GetEndpointCosts'](arg1);
 }
 
 export function GetEndpointHealthChart() {
@@ -94,6 +94,10 @@ export function GetGroups() {
   return window['go']['main']['App']['GetGroups']();
 }
 
+export function GetInboundAuthToken() {
+  return window['go']['main']['App']['GetInboundAuthToken']();
+}
+
 export function GetKeysOverview() {
   return window['go']['main']['App']['GetKeysOverview']();
 }
@@ -166,8 +170,8 @@ export function GetUsageStats(arg1) {
   return window['go']['main']['App']['GetUsageStats'](arg1);
 }
 
-export function GetUsageSummary(arg1, arg2) {
-  return window['go']['main']['App']['GetUsageSummary'](arg1, arg2);
+export function GetUsageSummary(arg1, arg2, arg3) {
+  return window['go']['main']['App']['GetUsageSummary'](arg1, arg2, arg3);
 }
 
 export function HideMainWindow() {
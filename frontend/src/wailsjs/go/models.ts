@@ -275,6 +275,7 @@ export namespace main {
 	    name: string;
 	    tokens: number;
 	    cost: number;
+	    currency: string;
 	
 	    static createFrom(source: any = {}) {
 	        return new EndpointCostItem(source);
@@ -285,6 +286,7 @@ export namespace main {
 	        this.name = source["name"];
 	        this.tokens = source["tokens"];
 	        this.cost = source["cost"];
+	        this.currency = source["currency"];
 	    }
 	}
 	export class EndpointHealthData {
@@ -806,6 +808,7 @@ export namespace main {
 	    active_group: string;
 	    config_path: string;
 	    auth_enabled: boolean;
+	    auth_token_generated: boolean;
 	
 	    static createFrom(source: any = {}) {
 	        return new SystemStatus(source);
@@ -823,6 +826,7 @@ export namespace main {
 	        this.active_group = source["active_group"];
 	        this.config_path = source["config_path"];
 	        this.auth_enabled = source["auth_enabled"];
+	        this.auth_token_generated = source["auth_token_generated"];
 	    }
 	}
 	export class TokenUsageData {
@@ -925,6 +929,7 @@ export namespace main {
 	    all_time_total_cost: number;
 	    today_tokens: number;
 	    all_time_total_tokens: number;
+	    currency: string;
 	
 	    static createFrom(source: any = {}) {
 	        return new UsageSummary(source);
@@ -944,6 +949,7 @@ export namespace main {
 	        this.all_time_total_cost = source["all_time_total_cost"];
 	        this.today_tokens = source["today_tokens"];
 	        this.all_time_total_tokens = source["all_time_total_tokens"];
+	        this.currency = source["currency"];
 	    }
 	}
 
@@ -0,0 +1,59 @@
+package balance
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ExtractNumber 按点分路径从 JSON 中提取数值
+// 路径段为对象键或数组下标，支持 data.quota、balance_infos.0.total_balance、items[0].amount；
+// 数值可以是 JSON 数字或数字字符串（如 DeepSeek 的 "total_balance": "12.34"）。
+func ExtractNumber(body []byte, path string) (float64, error) {
+	var root interface{}
+	if err := json.Unmarshal(body, &root); err != nil {
+		return 0, fmt.Errorf("响应不是合法 JSON: %w", err)
+	}
+
+	path = strings.TrimPrefix(strings.TrimSpace(path), "$.")
+	path = strings.NewReplacer("[", ".", "]", "").Replace(path)
+
+	current := root
+	if path != "" {
+		for _, seg := range strings.Split(path, ".") {
+			if seg == "" {
+				continue
+			}
+			switch node := current.(type) {
+			case map[string]interface{}:
+				v, ok := node[seg]
+				if !ok {
+					return 0, fmt.Errorf("余额路径 %s 不存在: 缺少字段 %s", path, seg)
+				}
+				current = v
+			case []interface{}:
+				idx, err := strconv.Atoi(seg)
+				if err != nil || idx < 0 || idx >= len(node) {
+					return 0, fmt.Errorf("余额路径 %s 无效: 数组下标 %s 越界", path, seg)
+				}
+				current = node[idx]
+			default:
+				return 0, fmt.Errorf("余额路径 %s 无效: %s 不是对象或数组", path, seg)
+			}
+		}
+	}
+
+	switch v := current.(type) {
+	case float64:
+		return v, nil
+	case string:
+		n, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return 0, fmt.Errorf("余额路径 %s 的值不是数字: %q", path, v)
+		}
+		return n, nil
+	default:
+		return 0, fmt.Errorf("余额路径 %s 的值不是数字: %v", path, v)
+	}
+}
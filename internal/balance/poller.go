@@ -0,0 +1,396 @@
+// Package balance 中转渠道余额/额度探测
+// 按渠道配置的余额接口（new-api / one-api 风格）定时轮询，解析 JSON 路径得到余额，
+// 低于阈值时告警，并可选地将渠道排除出故障转移候选（在请求开始报 401/402/403 之前切走）。
+package balance
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultInterval    = 10 * time.Minute
+	minInterval        = time.Minute
+	tickInterval       = 30 * time.Second
+	requestTimeout     = 15 * time.Second
+	maxResponseBodyLen = 1 << 20
+)
+
+// 告警类型
+const (
+	AlertLow       = "low"       // 余额低于阈值
+	AlertRecovered = "recovered" // 余额恢复到阈值以上
+)
+
+// Probe 渠道余额探测配置
+type Probe struct {
+	Channel     string
+	URL         string
+	AuthHeader  string  // 认证头名称（默认 Authorization）
+	AuthToken   string  // 认证头值（Authorization 头缺少认证方案时自动补 Bearer）
+	BalancePath string  // 余额在响应 JSON 中的路径，如 data.quota、balance_infos.0.total_balance
+	Unit        string  // 余额单位（USD / CNY / quota 等，仅用于展示）
+	Scale       float64 // 原始值换算系数：余额 = 原始值 / Scale（new-api quota 为 500000，默认 1）
+
+	LowThreshold   float64 // 低余额阈值（按换算后的余额，0 表示不告警）
+	ExcludeWhenLow bool    // 低余额时排除出故障转移候选，并将流量切出当前活跃渠道
+
+	Interval time.Duration // 轮询间隔（默认 10 分钟，最小 1 分钟）
+}
+
+// Result 一次余额探测的结果
+type Result struct {
+	Channel   string    `json:"channel"`
+	Balance   float64   `json:"balance"`
+	Raw       float64   `json:"raw"`
+	Unit      string    `json:"unit"`
+	Low       bool      `json:"low"`
+	Error     string    `json:"error,omitempty"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// Alert 低余额/恢复告警
+type Alert struct {
+	Type   string // low / recovered
+	Probe  Probe
+	Result Result
+}
+
+// Status 渠道余额运行状态
+type Status struct {
+	Channel        string    `json:"channel"`
+	Balance        float64   `json:"balance"`
+	Unit           string    `json:"unit"`
+	LowThreshold   float64   `json:"low_threshold"`
+	Low            bool      `json:"low"`
+	ExcludeWhenLow bool      `json:"exclude_when_low"`
+	Excluded       bool      `json:"excluded"` // 当前是否被排除出故障转移候选
+	HasBalance     bool      `json:"has_balance"`
+	LastError      string    `json:"last_error,omitempty"`
+	CheckedAt      time.Time `json:"checked_at"`
+	NextCheckAt    time.Time `json:"next_check_at"`
+}
+
+// probeState 单个渠道的探测状态
+type probeState struct {
+	probe      Probe
+	last       Result // 最近一次成功的探测结果
+	hasBalance bool
+	lastError  string
+	checkedAt  time.Time
+	nextDue    time.Time
+}
+
+// Poller 渠道余额轮询器
+type Poller struct {
+	client *http.Client
+
+	mu       sync.RWMutex
+	states   map[string]*probeState
+	recorder func(Result)
+	onAlert  func(Alert)
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	once   sync.Once
+}
+
+// NewPoller 创建余额轮询器（client 为 nil 时使用默认客户端）
+func NewPoller(client *http.Client) *Poller {
+	if client == nil {
+		client = &http.Client{}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Poller{
+		client: client,
+		states: make(map[string]*probeState),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// Start 启动轮询协程
+func (p *Poller) Start() {
+	p.once.Do(func() {
+		p.wg.Add(1)
+		go p.loop()
+	})
+}
+
+// Stop 停止轮询
+func (p *Poller) Stop() {
+	p.cancel()
+	p.wg.Wait()
+}
+
+// SetResultRecorder 设置探测结果记录器（用于落库形成余额时间序列）
+func (p *Poller) SetResultRecorder(recorder func(Result)) {
+	p.mu.Lock()
+	p.recorder = recorder
+	p.mu.Unlock()
+}
+
+// SetAlertHandler 设置低余额/恢复告警处理器
+func (p *Poller) SetAlertHandler(handler func(Alert)) {
+	p.mu.Lock()
+	p.onAlert = handler
+	p.mu.Unlock()
+}
+
+// UpdateProbes 更新探测配置（保留已有渠道的余额状态，新增渠道立即探测）
+func (p *Poller) UpdateProbes(probes []Probe) {
+	p.mu.Lock()
+	states := make(map[string]*probeState, len(probes))
+	for _, probe := range probes {
+		probe = normalizeProbe(probe)
+		if st, ok := p.states[probe.Channel]; ok {
+			if st.probe.URL != probe.URL || st.probe.BalancePath != probe.BalancePath || st.probe.Scale != probe.Scale {
+				// 探测方式变化：旧余额不再可信
+				st.hasBalance = false
+				st.last = Result{}
+				st.nextDue = time.Time{}
+			}
+			st.probe = probe
+			st.last.Low = st.hasBalance && isLow(probe, st.last.Balance)
+			states[probe.Channel] = st
+			continue
+		}
+		states[probe.Channel] = &probeState{probe: probe}
+	}
+	p.states = states
+	p.mu.Unlock()
+
+	slog.Info(fmt.Sprintf("💰 [余额探测] 已更新探测配置: %d 个渠道", len(probes)))
+}
+
+// ChannelLowBalance 渠道是否因低余额被排除出故障转移候选（实现 endpoint.BalanceGuard）
+func (p *Poller) ChannelLowBalance(channel string) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	st, ok := p.states[channel]
+	return ok && st.probe.ExcludeWhenLow && st.hasBalance && st.last.Low
+}
+
+// Statuses 获取所有渠道的余额状态（按渠道名排序）
+func (p *Poller) Statuses() []Status {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	result := make([]Status, 0, len(p.states))
+	for _, st := range p.states {
+		result = append(result, Status{
+			Channel:        st.probe.Channel,
+			Balance:        st.last.Balance,
+			Unit:           st.probe.Unit,
+			LowThreshold:   st.probe.LowThreshold,
+			Low:            st.hasBalance && st.last.Low,
+			ExcludeWhenLow: st.probe.ExcludeWhenLow,
+			Excluded:       st.probe.ExcludeWhenLow && st.hasBalance && st.last.Low,
+			HasBalance:     st.hasBalance,
+			LastError:      st.lastError,
+			CheckedAt:      st.checkedAt,
+			NextCheckAt:    st.nextDue,
+		})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Channel < result[j].Channel })
+	return result
+}
+
+// Refresh 立即探测指定渠道（用于手动刷新）
+func (p *Poller) Refresh(ctx context.Context, channel string) (Result, error) {
+	p.mu.RLock()
+	st, ok := p.states[channel]
+	var probe Probe
+	if ok {
+		probe = st.probe
+	}
+	p.mu.RUnlock()
+
+	if !ok {
+		return Result{}, fmt.Errorf("渠道 %s 未配置余额探测", channel)
+	}
+	result := p.check(ctx, probe)
+	if result.Error != "" {
+		return result, fmt.Errorf("%s", result.Error)
+	}
+	return result, nil
+}
+
+func (p *Poller) loop() {
+	defer p.wg.Done()
+
+	// 启动后立即探测一轮
+	p.pollDue()
+
+	ticker := time.NewTicker(tickInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-ticker.C:
+			p.pollDue()
+		}
+	}
+}
+
+// pollDue 探测所有到期的渠道
+func (p *Poller) pollDue() {
+	now := time.Now()
+	p.mu.RLock()
+	due := make([]Probe, 0, len(p.states))
+	for _, st := range p.states {
+		if !now.Before(st.nextDue) {
+			due = append(due, st.probe)
+		}
+	}
+	p.mu.RUnlock()
+
+	for _, probe := range due {
+		if p.ctx.Err() != nil {
+			return
+		}
+		p.check(p.ctx, probe)
+	}
+}
+
+// check 探测一次并更新状态，状态切换时触发告警
+func (p *Poller) check(ctx context.Context, probe Probe) Result {
+	result := probe.fetch(ctx, p.client)
+
+	p.mu.Lock()
+	st, ok := p.states[probe.Channel]
+	if !ok {
+		// 探测期间配置已删除
+		p.mu.Unlock()
+		return result
+	}
+	wasLow := st.hasBalance && st.last.Low
+	st.checkedAt = result.CheckedAt
+	st.nextDue = result.CheckedAt.Add(st.probe.Interval)
+	if result.Error != "" {
+		// 探测失败不改变低余额状态（避免接口抖动导致渠道反复进出候选）
+		st.lastError = result.Error
+	} else {
+		result.Low = isLow(st.probe, result.Balance)
+		st.last = result
+		st.hasBalance = true
+		st.lastError = ""
+	}
+	isLowNow := st.hasBalance && st.last.Low
+	current := st.probe
+	recorder := p.recorder
+	onAlert := p.onAlert
+	p.mu.Unlock()
+
+	if recorder != nil {
+		recorder(result)
+	}
+
+	if result.Error != "" {
+		slog.Warn(fmt.Sprintf("⚠️ [余额探测] 渠道 %s 探测失败: %s", probe.Channel, result.Error))
+		return result
+	}
+	slog.Debug(fmt.Sprintf("💰 [余额探测] 渠道 %s 余额: %.4f %s", probe.Channel, result.Balance, result.Unit))
+
+	if isLowNow != wasLow && onAlert != nil {
+		alertType := AlertRecovered
+		if isLowNow {
+			alertType = AlertLow
+			slog.Warn(fmt.Sprintf("🪫 [余额探测] 渠道 %s 余额不足: %.4f %s (阈值 %.4f)", probe.Channel, result.Balance, result.Unit, current.LowThreshold))
+		} else {
+			slog.Info(fmt.Sprintf("🔋 [余额探测] 渠道 %s 余额已恢复: %.4f %s", probe.Channel, result.Balance, result.Unit))
+		}
+		onAlert(Alert{Type: alertType, Probe: current, Result: result})
+	}
+	return result
+}
+
+// fetch 请求余额接口并解析余额
+func (probe Probe) fetch(ctx context.Context, client *http.Client) Result {
+	result := Result{Channel: probe.Channel, Unit: probe.Unit, CheckedAt: time.Now()}
+
+	reqCtx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(reqCtx, http.MethodGet, probe.URL, nil)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	req.Header.Set("Accept", "application/json")
+	if probe.AuthToken != "" {
+		req.Header.Set(probe.AuthHeader, authValue(probe.AuthHeader, probe.AuthToken))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBodyLen))
+	if err != nil {
+		result.Error = fmt.Sprintf("读取响应失败: %v", err)
+		return result
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		result.Error = fmt.Sprintf("HTTP %d: %s", resp.StatusCode, truncate(string(body), 200))
+		return result
+	}
+
+	raw, err := ExtractNumber(body, probe.BalancePath)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.Raw = raw
+	result.Balance = raw / probe.Scale
+	return result
+}
+
+// normalizeProbe 填充探测配置默认值
+func normalizeProbe(probe Probe) Probe {
+	if probe.AuthHeader == "" {
+		probe.AuthHeader = "Authorization"
+	}
+	if probe.Scale <= 0 {
+		probe.Scale = 1
+	}
+	if probe.Interval <= 0 {
+		probe.Interval = defaultInterval
+	} else if probe.Interval < minInterval {
+		probe.Interval = minInterval
+	}
+	return probe
+}
+
+// isLow 余额是否低于阈值
+func isLow(probe Probe, balance float64) bool {
+	return probe.LowThreshold > 0 && balance < probe.LowThreshold
+}
+
+// authValue Authorization 头缺少认证方案时补 Bearer
+func authValue(header, token string) string {
+	if strings.EqualFold(header, "Authorization") && !strings.Contains(token, " ") {
+		return "Bearer " + token
+	}
+	return token
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
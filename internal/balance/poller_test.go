@@ -0,0 +1,125 @@
+package balance
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// TestExtractNumber 测试 JSON 路径解析
+func TestExtractNumber(t *testing.T) {
+	body := []byte(`{"data":{"quota":2500000,"name":"u"},"balance_infos":[{"currency":"CNY","total_balance":"12.34"}],"total":5}`)
+
+	tests := []struct {
+		path    string
+		want    float64
+		wantErr bool
+	}{
+		{"data.quota", 2500000, false},
+		{"balance_infos.0.total_balance", 12.34, false},
+		{"balance_infos[0].total_balance", 12.34, false},
+		{"$.total", 5, false},
+		{"data.missing", 0, true},
+		{"balance_infos.3.total_balance", 0, true},
+		{"data.name", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ExtractNumber(body, tt.path)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ExtractNumber(%q) err = %v, wantErr %v", tt.path, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("ExtractNumber(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+// TestPollerLowBalanceTransitions 测试低余额告警、排除与恢复
+func TestPollerLowBalanceTransitions(t *testing.T) {
+	var mu sync.Mutex
+	quota := 2500000.0 // new-api quota：500000 = $1
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer sk-test" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if quota < 0 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte(`{"data":{"quota":` + strconv.FormatFloat(quota, 'f', -1, 64) + `}}`))
+	}))
+	defer server.Close()
+
+	setQuota := func(v float64) {
+		mu.Lock()
+		quota = v
+		mu.Unlock()
+	}
+
+	p := NewPoller(server.Client())
+	var alerts []Alert
+	var recorded int
+	p.SetAlertHandler(func(a Alert) { alerts = append(alerts, a) })
+	p.SetResultRecorder(func(Result) { recorded++ })
+	p.UpdateProbes([]Probe{{
+		Channel:        "relay",
+		URL:            server.URL,
+		AuthToken:      "sk-test",
+		BalancePath:    "data.quota",
+		Unit:           "USD",
+		Scale:          500000,
+		LowThreshold:   2,
+		ExcludeWhenLow: true,
+	}})
+	ctx := context.Background()
+
+	result, err := p.Refresh(ctx, "relay")
+	if err != nil || result.Balance != 5 || result.Low || p.ChannelLowBalance("relay") {
+		t.Fatalf("余额充足时结果不符: %+v, %v", result, err)
+	}
+
+	setQuota(500000)
+	if result, _ = p.Refresh(ctx, "relay"); !result.Low || !p.ChannelLowBalance("relay") {
+		t.Fatalf("余额不足时应排除渠道: %+v", result)
+	}
+	if len(alerts) != 1 || alerts[0].Type != AlertLow {
+		t.Fatalf("应触发一次低余额告警: %+v", alerts)
+	}
+
+	// 接口故障不改变低余额状态，也不重复告警
+	setQuota(-1)
+	if _, err := p.Refresh(ctx, "relay"); err == nil || !p.ChannelLowBalance("relay") || len(alerts) != 1 {
+		t.Fatalf("探测失败时应保持低余额状态: err=%v alerts=%d", err, len(alerts))
+	}
+
+	setQuota(5000000)
+	if _, err := p.Refresh(ctx, "relay"); err != nil || p.ChannelLowBalance("relay") {
+		t.Fatalf("余额恢复后应重新参与故障转移: %v", err)
+	}
+	if len(alerts) != 2 || alerts[1].Type != AlertRecovered || recorded != 4 {
+		t.Errorf("告警/记录次数不符: alerts=%+v recorded=%d", alerts, recorded)
+	}
+
+	statuses := p.Statuses()
+	if len(statuses) != 1 || statuses[0].Balance != 10 || statuses[0].Excluded {
+		t.Errorf("状态不符: %+v", statuses)
+	}
+
+	// 关闭排除后低余额只告警不排除
+	setQuota(100000)
+	p.UpdateProbes([]Probe{{Channel: "relay", URL: server.URL, AuthToken: "sk-test", BalancePath: "data.quota", Scale: 500000, LowThreshold: 2}})
+	if _, err := p.Refresh(ctx, "relay"); err != nil || p.ChannelLowBalance("relay") || len(alerts) != 3 {
+		t.Errorf("未开启排除时不应排除渠道: err=%v alerts=%d", err, len(alerts))
+	}
+
+	if _, err := p.Refresh(ctx, "unknown"); err == nil {
+		t.Error("未配置的渠道应返回错误")
+	}
+}
@@ -0,0 +1,64 @@
+// balance_guard.go - 余额守卫
+// 余额低于阈值（且开启排除）的中转渠道不参与故障转移，活跃时主动切出
+
+package endpoint
+
+import (
+	"fmt"
+	"log/slog"
+)
+
+// BalanceGuard 余额守卫（由 balance.Poller 实现）
+type BalanceGuard interface {
+	// ChannelLowBalance 渠道是否因低余额被排除出故障转移候选
+	ChannelLowBalance(channel string) bool
+}
+
+// SetBalanceGuard 设置余额守卫（nil 表示不限制）
+func (m *Manager) SetBalanceGuard(guard BalanceGuard) {
+	m.balanceGuardMu.Lock()
+	defer m.balanceGuardMu.Unlock()
+	m.balanceGuard = guard
+}
+
+func (m *Manager) getBalanceGuard() BalanceGuard {
+	m.balanceGuardMu.RLock()
+	defer m.balanceGuardMu.RUnlock()
+	return m.balanceGuard
+}
+
+// ChannelExcludedByBalance 渠道是否因余额不足被排除出故障转移候选
+func (m *Manager) ChannelExcludedByBalance(channel string) bool {
+	guard := m.getBalanceGuard()
+	return guard != nil && channel != "" && guard.ChannelLowBalance(channel)
+}
+
+// DivertLowBalanceChannel 余额不足的渠道正处于活跃状态时，按故障转移顺序切换到下一个可用渠道
+// 渠道未激活时不做任何操作，返回空字符串
+func (m *Manager) DivertLowBalanceChannel(channel string, reason string) (string, error) {
+	if m == nil || m.groupManager == nil {
+		return "", fmt.Errorf("端点管理器未初始化")
+	}
+
+	active := false
+	for _, g := range m.groupManager.GetActiveGroups() {
+		if g != nil && g.Name == channel {
+			active = true
+			break
+		}
+	}
+	if !active {
+		return "", nil
+	}
+
+	target, err := m.SelectNextAvailableChannel(channel)
+	if err != nil {
+		return "", err
+	}
+	if err := m.activateDivertTarget(channel, target, "low_balance: "+reason); err != nil {
+		return "", err
+	}
+
+	slog.Info(fmt.Sprintf("🪫 [余额分流] 渠道 %s 余额不足（%s），已切换到渠道: %s", channel, reason, target))
+	return target, nil
+}
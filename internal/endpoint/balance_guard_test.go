@@ -0,0 +1,60 @@
+package endpoint
+
+import (
+	"testing"
+	"time"
+
+	"cc-forwarder/config"
+)
+
+type stubBalanceGuard map[string]bool
+
+func (g stubBalanceGuard) ChannelLowBalance(channel string) bool { return g[channel] }
+
+func TestDivertLowBalanceChannel_SkipsLowBalanceCandidates(t *testing.T) {
+	cfg := &config.Config{
+		Strategy: config.StrategyConfig{Type: "priority"},
+		Health:   config.HealthConfig{Timeout: 5 * time.Second},
+		Endpoints: []config.EndpointConfig{
+			{Name: "a1", URL: "http://example.invalid", Channel: "A", Priority: 1, Timeout: 1 * time.Second},
+			{Name: "b1", URL: "http://example.invalid", Channel: "B", Priority: 2, Timeout: 1 * time.Second},
+			{Name: "c1", URL: "http://example.invalid", Channel: "C", Priority: 3, Timeout: 1 * time.Second},
+		},
+	}
+
+	m := NewManager(cfg)
+	for _, ep := range m.GetAllEndpoints() {
+		ep.mutex.Lock()
+		ep.Status.Healthy = true
+		ep.Status.NeverChecked = false
+		ep.mutex.Unlock()
+	}
+	if err := m.ManualActivateGroup("A"); err != nil {
+		t.Fatalf("ManualActivateGroup(A) error: %v", err)
+	}
+
+	m.SetBalanceGuard(stubBalanceGuard{"A": true, "B": true})
+
+	if next, err := m.SelectNextAvailableChannel("A"); err != nil || next != "C" {
+		t.Fatalf("SelectNextAvailableChannel(A) = %q, %v; want C (B excluded by low balance)", next, err)
+	}
+
+	// 非活跃渠道余额不足时不切换
+	if target, err := m.DivertLowBalanceChannel("B", "余额 0.5 USD"); err != nil || target != "" {
+		t.Fatalf("DivertLowBalanceChannel(B) = %q, %v; want no-op for inactive channel", target, err)
+	}
+
+	target, err := m.DivertLowBalanceChannel("A", "余额 0.5 USD")
+	if err != nil || target != "C" {
+		t.Fatalf("DivertLowBalanceChannel(A) = %q, %v; want C", target, err)
+	}
+	active := m.groupManager.GetActiveGroups()
+	if len(active) != 1 || active[0].Name != "C" {
+		t.Fatalf("expected active channel C after divert, got %+v", active)
+	}
+
+	m.SetBalanceGuard(nil)
+	if m.ChannelExcludedByBalance("A") {
+		t.Fatal("no guard should exclude nothing")
+	}
+}
@@ -0,0 +1,181 @@
+// budget_guard.go - 预算守卫
+// 超出预算硬限制（reject / divert / manual）的渠道与端点不参与路由与故障转移
+
+package endpoint
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+)
+
+// BudgetGuard 预算守卫（由 tracking.UsageTracker 实现）
+type BudgetGuard interface {
+	// ChannelBlocked 渠道是否被渠道级预算阻断
+	ChannelBlocked(channel string) bool
+	// EndpointBlocked 端点是否被端点级预算阻断
+	EndpointBlocked(channel, endpointName string) bool
+	// EffectivePrice 端点处理指定模型的参考价格（用于选择最便宜的分流渠道）
+	EffectivePrice(channel, endpointName, model string) float64
+}
+
+// SetBudgetGuard 设置预算守卫（nil 表示不限制）
+func (m *Manager) SetBudgetGuard(guard BudgetGuard) {
+	m.budgetGuardMu.Lock()
+	defer m.budgetGuardMu.Unlock()
+	m.budgetGuard = guard
+}
+
+func (m *Manager) getBudgetGuard() BudgetGuard {
+	m.budgetGuardMu.RLock()
+	defer m.budgetGuardMu.RUnlock()
+	return m.budgetGuard
+}
+
+// ChannelBlockedByBudget 渠道是否因预算超限被阻断
+func (m *Manager) ChannelBlockedByBudget(channel string) bool {
+	guard := m.getBudgetGuard()
+	return guard != nil && channel != "" && guard.ChannelBlocked(channel)
+}
+
+// EndpointBlockedByBudget 端点是否因预算超限被阻断
+func (m *Manager) EndpointBlockedByBudget(ep *Endpoint) bool {
+	guard := m.getBudgetGuard()
+	return guard != nil && ep != nil && guard.EndpointBlocked(ep.Config.Channel, ep.Config.Name)
+}
+
+// filterEndpointsByBudget 过滤被端点级预算阻断的端点（全部被阻断时返回空，由上层分流或拒绝）
+func (m *Manager) filterEndpointsByBudget(endpoints []*Endpoint) []*Endpoint {
+	if len(endpoints) == 0 || m.getBudgetGuard() == nil {
+		return endpoints
+	}
+
+	filtered := make([]*Endpoint, 0, len(endpoints))
+	for _, ep := range endpoints {
+		if m.EndpointBlockedByBudget(ep) {
+			slog.Debug(fmt.Sprintf("⏭️ [端点选择] 跳过超出预算的端点: %s", ep.Config.Name))
+			continue
+		}
+		filtered = append(filtered, ep)
+	}
+	return filtered
+}
+
+// ChannelEndpointsBlockedByBudget 渠道内所有端点是否均被端点级预算阻断（渠道无端点时返回 false）
+func (m *Manager) ChannelEndpointsBlockedByBudget(channel string) bool {
+	blocked := false
+	for _, ep := range m.GetAllEndpoints() {
+		if ep.Config.Channel != channel {
+			continue
+		}
+		if !m.EndpointBlockedByBudget(ep) {
+			return false
+		}
+		blocked = true
+	}
+	return blocked
+}
+
+// DivertChannel 因预算超限将流量从活跃渠道分流到目标渠道（由预算超限事件触发，每次超限只执行一次）
+// targetChannel 为空时选择 model 有效价格最低的可用渠道；fromChannel 未激活时不做任何操作，返回空字符串
+func (m *Manager) DivertChannel(fromChannel, targetChannel, model, reason string) (string, error) {
+	if m == nil || m.groupManager == nil {
+		return "", fmt.Errorf("端点管理器未初始化")
+	}
+
+	active := false
+	for _, g := range m.groupManager.GetActiveGroups() {
+		if g != nil && g.Name == fromChannel {
+			active = true
+			break
+		}
+	}
+	if !active {
+		return "", nil
+	}
+
+	if targetChannel == "" {
+		next, err := m.SelectCheapestChannel(fromChannel, model)
+		if err != nil {
+			return "", err
+		}
+		targetChannel = next
+	}
+	if targetChannel == fromChannel {
+		return "", fmt.Errorf("分流目标渠道与当前渠道相同: %s", targetChannel)
+	}
+	if m.ChannelBlockedByBudget(targetChannel) {
+		return "", fmt.Errorf("分流目标渠道 %s 同样超出预算", targetChannel)
+	}
+
+	if err := m.activateDivertTarget(fromChannel, targetChannel, "budget: "+reason); err != nil {
+		return "", err
+	}
+
+	slog.Info(fmt.Sprintf("💸 [预算分流] 渠道 %s 超出预算（%s），已切换到渠道: %s", fromChannel, reason, targetChannel))
+	return targetChannel, nil
+}
+
+// SelectCheapestChannel 在可切换渠道中选择 model 有效价格最低的渠道
+// 渠道价格取其未被预算阻断的端点中的最低价；价格相同时按故障转移顺序，未设置预算守卫时等同 SelectNextAvailableChannel
+func (m *Manager) SelectCheapestChannel(excludeChannel, model string) (string, error) {
+	if m == nil || m.groupManager == nil || m.config == nil {
+		return "", fmt.Errorf("配置未初始化")
+	}
+
+	candidates := m.collectFailoverCandidates(excludeChannel)
+	if len(candidates) == 0 {
+		return "", fmt.Errorf("没有可用的故障转移渠道")
+	}
+	strategy := "priority"
+	if m.config.Strategy.Type != "" {
+		strategy = m.config.Strategy.Type
+	}
+	sortFailoverCandidates(strategy, candidates)
+
+	guard := m.getBudgetGuard()
+	if guard == nil {
+		return candidates[0].name, nil
+	}
+
+	prices := make(map[string]float64, len(candidates))
+	for _, ep := range m.GetAllEndpoints() {
+		if m.EndpointBlockedByBudget(ep) {
+			continue
+		}
+		price := guard.EffectivePrice(ep.Config.Channel, ep.Config.Name, model)
+		if current, ok := prices[ep.Config.Channel]; !ok || price < current {
+			prices[ep.Config.Channel] = price
+		}
+	}
+
+	best := candidates[0].name
+	bestPrice := math.Inf(1)
+	for _, c := range candidates {
+		if price, ok := prices[c.name]; ok && price < bestPrice {
+			best, bestPrice = c.name, price
+		}
+	}
+	return best, nil
+}
+
+// activateDivertTarget 激活分流目标渠道（常规激活失败时回退强制激活），并复用故障转移通知与回调
+func (m *Manager) activateDivertTarget(fromChannel, targetChannel, reason string) error {
+	if err := m.groupManager.ManualActivateGroup(targetChannel); err != nil {
+		slog.Warn(fmt.Sprintf("⚠️ [渠道分流] 常规激活渠道失败，回退强制激活: %s, 错误: %v", targetChannel, err))
+		if err2 := m.groupManager.ManualActivateGroupWithForce(targetChannel, true); err2 != nil {
+			return fmt.Errorf("激活分流渠道失败: %w", err2)
+		}
+	}
+
+	go m.notifyFailover(fromChannel, targetChannel, reason, nil)
+
+	// 复用故障转移回调同步数据库与前端
+	if m.onFailoverTriggered != nil {
+		go m.onFailoverTriggered(fromChannel, targetChannel)
+	}
+	if m.onHealthCheckComplete != nil {
+		go m.onHealthCheckComplete()
+	}
+	return nil
+}
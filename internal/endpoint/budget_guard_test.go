@@ -0,0 +1,124 @@
+package endpoint
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"cc-forwarder/config"
+)
+
+// stubBudgetGuard 按渠道阻断并按端点返回固定参考价格
+type stubBudgetGuard struct {
+	blockedChannels  map[string]bool
+	blockedEndpoints map[string]bool
+	prices           map[string]float64 // key: 端点名
+}
+
+func (g stubBudgetGuard) ChannelBlocked(channel string) bool { return g.blockedChannels[channel] }
+
+func (g stubBudgetGuard) EndpointBlocked(channel, endpointName string) bool {
+	return g.blockedEndpoints[endpointName]
+}
+
+func (g stubBudgetGuard) EffectivePrice(channel, endpointName, model string) float64 {
+	return g.prices[endpointName]
+}
+
+func TestDivertChannel_SelectsCheapestChannel(t *testing.T) {
+	cfg := &config.Config{
+		Strategy: config.StrategyConfig{Type: "priority"},
+		Health:   config.HealthConfig{Timeout: 5 * time.Second},
+		Endpoints: []config.EndpointConfig{
+			{Name: "a1", URL: "http://example.invalid", Channel: "A", Priority: 1, Timeout: 1 * time.Second},
+			{Name: "b1", URL: "http://example.invalid", Channel: "B", Priority: 2, Timeout: 1 * time.Second},
+			{Name: "c1", URL: "http://example.invalid", Channel: "C", Priority: 3, Timeout: 1 * time.Second},
+			{Name: "c2", URL: "http://example.invalid", Channel: "C", Priority: 4, Timeout: 1 * time.Second},
+			{Name: "d1", URL: "http://example.invalid", Channel: "D", Priority: 5, Timeout: 1 * time.Second},
+		},
+	}
+
+	m := NewManager(cfg)
+	for _, ep := range m.GetAllEndpoints() {
+		ep.mutex.Lock()
+		ep.Status.Healthy = true
+		ep.Status.NeverChecked = false
+		ep.mutex.Unlock()
+	}
+	if err := m.ManualActivateGroup("A"); err != nil {
+		t.Fatalf("ManualActivateGroup(A) error: %v", err)
+	}
+
+	// B 按优先级排在最前但最贵；C 的 c2 被端点预算阻断，按 c1 计价；D 被渠道预算阻断
+	m.SetBudgetGuard(stubBudgetGuard{
+		blockedChannels:  map[string]bool{"D": true},
+		blockedEndpoints: map[string]bool{"c2": true},
+		prices:           map[string]float64{"a1": 20, "b1": 18, "c1": 9, "c2": 1, "d1": 0.5},
+	})
+
+	if next, err := m.SelectCheapestChannel("A", "claude-sonnet-4"); err != nil || next != "C" {
+		t.Fatalf("SelectCheapestChannel(A) = %q, %v; want C", next, err)
+	}
+
+	// 非活跃渠道超限时不切换
+	if target, err := m.DivertChannel("B", "", "claude-sonnet-4", "b-daily"); err != nil || target != "" {
+		t.Fatalf("DivertChannel(B) = %q, %v; want no-op for inactive channel", target, err)
+	}
+
+	target, err := m.DivertChannel("A", "", "claude-sonnet-4", "a-daily")
+	if err != nil || target != "C" {
+		t.Fatalf("DivertChannel(A) = %q, %v; want C", target, err)
+	}
+	active := m.groupManager.GetActiveGroups()
+	if len(active) != 1 || active[0].Name != "C" {
+		t.Fatalf("expected active channel C after divert, got %+v", active)
+	}
+
+	if m.ChannelEndpointsBlockedByBudget("C") {
+		t.Error("C 渠道仍有未阻断的端点 c1")
+	}
+	m.SetBudgetGuard(stubBudgetGuard{blockedEndpoints: map[string]bool{"c1": true, "c2": true}})
+	if !m.ChannelEndpointsBlockedByBudget("C") {
+		t.Error("C 渠道所有端点均被端点预算阻断")
+	}
+}
+
+// TestFilterEndpointsForRequest_SkipsBudgetBlocked 测试忽略健康状态的回退路径同样跳过超出预算的端点
+func TestFilterEndpointsForRequest_SkipsBudgetBlocked(t *testing.T) {
+	cfg := &config.Config{
+		Strategy: config.StrategyConfig{Type: "priority"},
+		Health:   config.HealthConfig{Timeout: 5 * time.Second},
+		Endpoints: []config.EndpointConfig{
+			{Name: "a1", URL: "http://example.invalid", Channel: "A", Priority: 1, Timeout: 1 * time.Second},
+			{Name: "a2", URL: "http://example.invalid", Channel: "A", Priority: 2, Timeout: 1 * time.Second},
+			{Name: "a3", URL: "http://example.invalid", Channel: "A", Priority: 3, Timeout: 1 * time.Second},
+		},
+	}
+
+	m := NewManager(cfg)
+	for _, ep := range m.GetAllEndpoints() {
+		ep.mutex.Lock()
+		ep.Status.Healthy = ep.Config.Name == "a1"
+		ep.Status.NeverChecked = false
+		ep.mutex.Unlock()
+	}
+	if err := m.ManualActivateGroup("A"); err != nil {
+		t.Fatalf("ManualActivateGroup(A) error: %v", err)
+	}
+	// 唯一健康的 a1 被端点预算阻断，其余端点不健康
+	m.SetBudgetGuard(stubBudgetGuard{blockedEndpoints: map[string]bool{"a1": true}})
+
+	ctx := context.Background()
+	if healthy := m.GetHealthyEndpointsForRequest(ctx); len(healthy) != 0 {
+		t.Fatalf("不应有可用的健康端点: %d", len(healthy))
+	}
+
+	fallback := m.FilterEndpointsForRequest(ctx, m.GetGroupManager().FilterEndpointsByActiveGroups(m.GetAllEndpoints()))
+	var names []string
+	for _, ep := range fallback {
+		names = append(names, ep.Config.Name)
+	}
+	if len(names) != 2 || names[0] != "a2" || names[1] != "a3" {
+		t.Errorf("回退端点 = %v, want [a2 a3]（不含超出预算的 a1）", names)
+	}
+}
@@ -0,0 +1,328 @@
+// capabilities.go - 端点能力集
+// 包含能力声明/自动探测、请求所需能力识别、按能力筛选端点
+
+package endpoint
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 端点能力名称（与 config.EndpointConfig.Capabilities 的键一致）
+const (
+	CapabilityContext1M     = "context_1m"     // 1M 上下文 beta
+	CapabilityThinking      = "thinking"       // 扩展思考
+	CapabilityBatches       = "batches"        // Message Batches API
+	CapabilityFiles         = "files"          // Files API
+	CapabilityPromptCaching = "prompt_caching" // 提示缓存
+	CapabilityWebSearch     = "web_search"     // 服务端 web search 工具
+)
+
+// AllCapabilities 所有已知能力（用于展示）
+var AllCapabilities = []string{
+	CapabilityContext1M,
+	CapabilityThinking,
+	CapabilityBatches,
+	CapabilityFiles,
+	CapabilityPromptCaching,
+	CapabilityWebSearch,
+}
+
+// 能力来源
+const (
+	CapabilitySourceConfigured = "configured" // 配置声明
+	CapabilitySourceDetected   = "detected"   // 运行时探测
+	CapabilitySourceUnknown    = "unknown"    // 未知（视为支持）
+)
+
+// 请求体超过该大小时视为需要 1M 上下文（按约 4 字节/Token 估算超过 200K Token）
+const context1MBodyThreshold = 800 * 1024
+
+// detectedUnsupportedTTL 探测到的"不支持"结果有效期
+// 过期后端点重新参与筛选（半开探测）：成功则标记为支持，再次失败则重新计时
+const detectedUnsupportedTTL = 30 * time.Minute
+
+// CapabilityStatus 端点单项能力状态
+type CapabilityStatus struct {
+	Capability string    `json:"capability"`
+	Supported  bool      `json:"supported"`
+	Source     string    `json:"source"`
+	Reason     string    `json:"reason,omitempty"`
+	DetectedAt time.Time `json:"detected_at,omitempty"`
+}
+
+// detectedCapability 运行时探测结果
+type detectedCapability struct {
+	supported  bool
+	reason     string
+	detectedAt time.Time
+}
+
+// capabilityRegistry 运行时能力探测结果（按 EndpointKey 索引）
+type capabilityRegistry struct {
+	mu       sync.RWMutex
+	detected map[string]map[string]detectedCapability
+}
+
+func newCapabilityRegistry() *capabilityRegistry {
+	return &capabilityRegistry{detected: make(map[string]map[string]detectedCapability)}
+}
+
+// ==================== 请求所需能力 ====================
+
+type requiredCapabilitiesKey struct{}
+
+// WithRequiredCapabilities 将请求所需能力写入上下文，供端点选择时筛选
+func WithRequiredCapabilities(ctx context.Context, capabilities []string) context.Context {
+	if len(capabilities) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, requiredCapabilitiesKey{}, capabilities)
+}
+
+// RequiredCapabilitiesFromContext 从上下文获取请求所需能力
+func RequiredCapabilitiesFromContext(ctx context.Context) []string {
+	if ctx == nil {
+		return nil
+	}
+	capabilities, _ := ctx.Value(requiredCapabilitiesKey{}).([]string)
+	return capabilities
+}
+
+// RequestBodyFeatures 请求体中与能力识别相关的字段（由调用方解码请求体后传入，避免重复解析）
+type RequestBodyFeatures struct {
+	ThinkingType string   // thinking.type
+	ToolTypes    []string // tools[].type
+}
+
+// DetectRequiredCapabilitiesWithFeatures 根据请求路径、beta 头、请求体及已解码的请求体字段识别所需能力
+// features 为 nil 表示请求体无法解码（仅按原始请求体判定提示缓存与 1M 上下文）
+func DetectRequiredCapabilitiesWithFeatures(path string, header http.Header, body []byte, features *RequestBodyFeatures) []string {
+	required := make(map[string]bool)
+
+	// 1. 路径
+	if strings.HasPrefix(path, "/v1/messages/batches") {
+		required[CapabilityBatches] = true
+	}
+	if strings.HasPrefix(path, "/v1/files") {
+		required[CapabilityFiles] = true
+	}
+
+	// 2. anthropic-beta 头
+	for _, value := range header.Values("anthropic-beta") {
+		for _, beta := range strings.Split(value, ",") {
+			beta = strings.TrimSpace(beta)
+			switch {
+			case strings.HasPrefix(beta, "context-1m"):
+				required[CapabilityContext1M] = true
+			case strings.HasPrefix(beta, "files-api"):
+				required[CapabilityFiles] = true
+			case strings.HasPrefix(beta, "prompt-caching"):
+				required[CapabilityPromptCaching] = true
+			case strings.HasPrefix(beta, "message-batches"):
+				required[CapabilityBatches] = true
+			case strings.HasPrefix(beta, "interleaved-thinking"):
+				required[CapabilityThinking] = true
+			}
+		}
+	}
+
+	// 3. 请求体
+	if len(body) > 0 && strings.HasPrefix(path, "/v1/messages") {
+		if features != nil {
+			if features.ThinkingType != "" && features.ThinkingType != "disabled" {
+				required[CapabilityThinking] = true
+			}
+			for _, toolType := range features.ToolTypes {
+				if strings.HasPrefix(toolType, "web_search") {
+					required[CapabilityWebSearch] = true
+				}
+			}
+		}
+		if bytes.Contains(body, []byte(`"cache_control"`)) {
+			required[CapabilityPromptCaching] = true
+		}
+		if len(body) > context1MBodyThreshold {
+			required[CapabilityContext1M] = true
+		}
+	}
+
+	if len(required) == 0 {
+		return nil
+	}
+	result := make([]string, 0, len(required))
+	for capability := range required {
+		result = append(result, capability)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// ==================== 能力判定 ====================
+
+// capabilityKeywords 上游错误信息中用于识别能力的关键字
+var capabilityKeywords = map[string][]string{
+	CapabilityContext1M:     {"context-1m", "1m context", "context_1m"},
+	CapabilityThinking:      {"thinking"},
+	CapabilityBatches:       {"batch"},
+	CapabilityFiles:         {"files-api", "file_id", "files api"},
+	CapabilityPromptCaching: {"cache_control", "prompt-caching", "prompt caching"},
+	CapabilityWebSearch:     {"web_search", "web search"},
+}
+
+// unsupportedMarkers 上游错误信息中表示"不支持"的关键字
+var unsupportedMarkers = []string{
+	"not supported", "unsupported", "not support", "not enabled", "not available",
+	"unknown beta", "invalid beta", "unexpected value", "extra inputs are not permitted",
+	"not allowed", "不支持",
+}
+
+// capabilityRejectionMarkers 需要明确拒绝该功能才判定为不支持的能力（覆盖 unsupportedMarkers）
+// 提示缓存几乎出现在所有请求中，"not allowed" 等泛化表述多为 cache_control 用法错误
+var capabilityRejectionMarkers = map[string][]string{
+	CapabilityPromptCaching: {
+		"not supported", "unsupported", "not support", "not enabled",
+		"unknown beta", "invalid beta", "extra inputs are not permitted", "不支持",
+	},
+}
+
+// capabilityUsageErrorMarkers 表示请求用法错误（而非端点不支持该能力）的关键字
+var capabilityUsageErrorMarkers = map[string][]string{
+	CapabilityPromptCaching: {"maximum", "at most", "too many", "breakpoint", "exceed", "ttl", "minimum"},
+}
+
+// InferUnsupportedCapability 从上游 400 错误信息推断端点不支持的能力
+// 仅在本次请求所需能力中匹配，避免误判
+func InferUnsupportedCapability(message string, required []string) (string, bool) {
+	if message == "" || len(required) == 0 {
+		return "", false
+	}
+	lower := strings.ToLower(message)
+
+	for _, capability := range required {
+		if !containsAny(lower, capabilityKeywords[capability]) {
+			continue
+		}
+		markers, ok := capabilityRejectionMarkers[capability]
+		if !ok {
+			markers = unsupportedMarkers
+		}
+		if containsAny(lower, markers) && !containsAny(lower, capabilityUsageErrorMarkers[capability]) {
+			return capability, true
+		}
+	}
+	return "", false
+}
+
+// containsAny 判断 s 是否包含任一关键字
+func containsAny(s string, keywords []string) bool {
+	for _, keyword := range keywords {
+		if strings.Contains(s, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// MarkEndpointCapability 记录端点能力探测结果
+// 已在配置中声明的能力以配置为准，探测结果仅作记录
+func (m *Manager) MarkEndpointCapability(ep *Endpoint, capability string, supported bool, reason string) {
+	if ep == nil || capability == "" {
+		return
+	}
+	key := endpointKeyFromConfig(ep.Config)
+
+	m.capabilities.mu.Lock()
+	entries, ok := m.capabilities.detected[key]
+	if !ok {
+		entries = make(map[string]detectedCapability)
+		m.capabilities.detected[key] = entries
+	}
+	previous, existed := entries[capability]
+	entries[capability] = detectedCapability{supported: supported, reason: reason, detectedAt: time.Now()}
+	m.capabilities.mu.Unlock()
+
+	if !existed || previous.supported != supported {
+		slog.Info(fmt.Sprintf("🧩 [能力探测] 端点 %s 能力 %s: supported=%v (%s)", key, capability, supported, reason))
+	}
+}
+
+// ResetDetectedCapabilities 清除端点的能力探测结果
+func (m *Manager) ResetDetectedCapabilities(endpointKey string) {
+	m.capabilities.mu.Lock()
+	defer m.capabilities.mu.Unlock()
+
+	delete(m.capabilities.detected, endpointKey)
+}
+
+// GetEndpointCapabilities 获取端点所有能力状态（配置 > 探测 > 未知，过期的"不支持"探测结果视为支持）
+func (m *Manager) GetEndpointCapabilities(ep *Endpoint) []CapabilityStatus {
+	result := make([]CapabilityStatus, 0, len(AllCapabilities))
+	for _, capability := range AllCapabilities {
+		result = append(result, m.endpointCapabilityStatus(ep, capability))
+	}
+	return result
+}
+
+// endpointCapabilityStatus 获取端点单项能力状态
+func (m *Manager) endpointCapabilityStatus(ep *Endpoint, capability string) CapabilityStatus {
+	if supported, ok := ep.Config.Capabilities[capability]; ok {
+		return CapabilityStatus{Capability: capability, Supported: supported, Source: CapabilitySourceConfigured}
+	}
+
+	m.capabilities.mu.RLock()
+	detected, ok := m.capabilities.detected[endpointKeyFromConfig(ep.Config)][capability]
+	m.capabilities.mu.RUnlock()
+	if ok {
+		status := CapabilityStatus{
+			Capability: capability,
+			Supported:  detected.supported,
+			Source:     CapabilitySourceDetected,
+			Reason:     detected.reason,
+			DetectedAt: detected.detectedAt,
+		}
+		if !detected.supported && time.Since(detected.detectedAt) > detectedUnsupportedTTL {
+			// 半开探测：放行请求，由下一次结果重新判定
+			status.Supported = true
+			status.Reason = "探测结果已过期，重新探测中: " + detected.reason
+		}
+		return status
+	}
+
+	return CapabilityStatus{Capability: capability, Supported: true, Source: CapabilitySourceUnknown}
+}
+
+// EndpointSupportsCapabilities 判断端点是否支持所有指定能力（未知能力视为支持）
+func (m *Manager) EndpointSupportsCapabilities(ep *Endpoint, capabilities []string) (bool, string) {
+	for _, capability := range capabilities {
+		if !m.endpointCapabilityStatus(ep, capability).Supported {
+			return false, capability
+		}
+	}
+	return true, ""
+}
+
+// filterEndpointsByCapabilities 按请求所需能力筛选端点
+// 若筛选后无可用端点返回空，由 getHealthyEndpoints 切换到支持这些能力的渠道（与模型筛选保持一致）
+func (m *Manager) filterEndpointsByCapabilities(endpoints []*Endpoint, capabilities []string) []*Endpoint {
+	if len(capabilities) == 0 || len(endpoints) == 0 {
+		return endpoints
+	}
+
+	filtered := make([]*Endpoint, 0, len(endpoints))
+	for _, ep := range endpoints {
+		if ok, missing := m.EndpointSupportsCapabilities(ep, capabilities); ok {
+			filtered = append(filtered, ep)
+		} else {
+			slog.Debug(fmt.Sprintf("⏭️ [端点选择] 跳过不支持能力 %s 的端点: %s", missing, ep.Config.Name))
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,199 @@
+package endpoint
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"cc-forwarder/config"
+)
+
+func TestDetectRequiredCapabilitiesWithFeatures(t *testing.T) {
+	testCases := []struct {
+		name     string
+		path     string
+		beta     string
+		body     string
+		features *RequestBodyFeatures
+		expected []string
+	}{
+		{"普通请求", "/v1/messages", "", `{"model":"claude-sonnet-4-5","messages":[]}`, &RequestBodyFeatures{}, nil},
+		{"thinking 启用", "/v1/messages", "", `{"thinking":{"type":"enabled","budget_tokens":1024}}`, &RequestBodyFeatures{ThinkingType: "enabled"}, []string{CapabilityThinking}},
+		{"thinking 禁用", "/v1/messages", "", `{"thinking":{"type":"disabled"}}`, &RequestBodyFeatures{ThinkingType: "disabled"}, nil},
+		{"web search 工具", "/v1/messages", "", `{"tools":[{"type":"web_search_20250305","name":"web_search"}]}`, &RequestBodyFeatures{ToolTypes: []string{"web_search_20250305"}}, []string{CapabilityWebSearch}},
+		{"普通工具", "/v1/messages", "", `{"tools":[{"name":"get_weather","input_schema":{}}]}`, &RequestBodyFeatures{ToolTypes: []string{""}}, nil},
+		{"cache_control", "/v1/messages", "", `{"system":[{"type":"text","text":"x","cache_control":{"type":"ephemeral"}}]}`, &RequestBodyFeatures{}, []string{CapabilityPromptCaching}},
+		{"请求体无法解码", "/v1/messages", "", `{"thinking":{"type":"enabled"`, nil, nil},
+		{"1M 上下文 beta", "/v1/messages", "context-1m-2025-08-07", `{}`, &RequestBodyFeatures{}, []string{CapabilityContext1M}},
+		{"多个 beta", "/v1/messages", "files-api-2025-04-14, interleaved-thinking-2025-05-14", `{}`, &RequestBodyFeatures{}, []string{CapabilityFiles, CapabilityThinking}},
+		{"Batches 路径", "/v1/messages/batches", "", `{"requests":[]}`, &RequestBodyFeatures{}, []string{CapabilityBatches}},
+		{"Files 路径", "/v1/files", "", "", nil, []string{CapabilityFiles}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			header := http.Header{}
+			if tc.beta != "" {
+				header.Set("anthropic-beta", tc.beta)
+			}
+			got := DetectRequiredCapabilitiesWithFeatures(tc.path, header, []byte(tc.body), tc.features)
+			if !reflect.DeepEqual(got, tc.expected) {
+				t.Errorf("DetectRequiredCapabilitiesWithFeatures = %v, 期望 %v", got, tc.expected)
+			}
+		})
+	}
+}
+
+func TestDetectRequiredCapabilitiesWithFeatures_LargeBody(t *testing.T) {
+	body := `{"messages":[{"role":"user","content":"` + strings.Repeat("a", context1MBodyThreshold) + `"}]}`
+	got := DetectRequiredCapabilitiesWithFeatures("/v1/messages", http.Header{}, []byte(body), &RequestBodyFeatures{})
+	if !reflect.DeepEqual(got, []string{CapabilityContext1M}) {
+		t.Errorf("超大请求体应需要 context_1m, 实际 %v", got)
+	}
+}
+
+func TestInferUnsupportedCapability(t *testing.T) {
+	required := []string{CapabilityThinking, CapabilityWebSearch}
+
+	if capability, ok := InferUnsupportedCapability("thinking: Extra inputs are not permitted", required); !ok || capability != CapabilityThinking {
+		t.Errorf("应识别为 thinking 不支持, 实际 %q %v", capability, ok)
+	}
+	if capability, ok := InferUnsupportedCapability("Tool type web_search_20250305 is not supported", required); !ok || capability != CapabilityWebSearch {
+		t.Errorf("应识别为 web_search 不支持, 实际 %q %v", capability, ok)
+	}
+	// 不在所需能力中的关键字不判定
+	if _, ok := InferUnsupportedCapability("cache_control is not supported", required); ok {
+		t.Error("非本次请求所需能力不应被判定")
+	}
+	// 没有"不支持"语义的错误不判定
+	if _, ok := InferUnsupportedCapability("thinking.budget_tokens: must be >= 1024", required); ok {
+		t.Error("参数校验错误不应被判定为能力不支持")
+	}
+}
+
+func TestInferUnsupportedCapability_PromptCaching(t *testing.T) {
+	required := []string{CapabilityPromptCaching}
+
+	if capability, ok := InferUnsupportedCapability("cache_control: Extra inputs are not permitted", required); !ok || capability != CapabilityPromptCaching {
+		t.Errorf("应识别为 prompt_caching 不支持, 实际 %q %v", capability, ok)
+	}
+	// cache_control 用法错误（断点过多、泛化的 not allowed）不判定为端点不支持
+	usageErrors := []string{
+		"A maximum of 4 blocks with cache_control may be provided. Found 5.",
+		"cache_control is not allowed on this block type",
+		"Too many cache_control breakpoints: not supported beyond 4",
+	}
+	for _, message := range usageErrors {
+		if capability, ok := InferUnsupportedCapability(message, required); ok {
+			t.Errorf("%q 不应判定为不支持, 实际 %q", message, capability)
+		}
+	}
+}
+
+func TestGetHealthyEndpointsForRequest_FiltersByCapabilities(t *testing.T) {
+	m := newModelCatalogTestManager(t,
+		config.EndpointConfig{Name: "a1", URL: "http://example.invalid", Channel: "A", Priority: 1,
+			Capabilities: map[string]bool{CapabilityThinking: false}},
+		config.EndpointConfig{Name: "a2", URL: "http://example.invalid", Channel: "A", Priority: 2},
+		config.EndpointConfig{Name: "a3", URL: "http://example.invalid", Channel: "A", Priority: 3,
+			Capabilities: map[string]bool{CapabilityWebSearch: true}},
+	)
+	if err := m.ManualActivateGroup("A"); err != nil {
+		t.Fatalf("ManualActivateGroup(A) error: %v", err)
+	}
+
+	names := func(eps []*Endpoint) []string {
+		var result []string
+		for _, ep := range eps {
+			result = append(result, ep.Config.Name)
+		}
+		return result
+	}
+
+	ctx := WithRequiredCapabilities(context.Background(), []string{CapabilityThinking})
+	if got := names(m.GetHealthyEndpointsForRequest(ctx)); !reflect.DeepEqual(got, []string{"a2", "a3"}) {
+		t.Errorf("thinking 候选端点 = %v, 期望 [a2 a3]", got)
+	}
+
+	// 运行时探测：a2 不支持 web_search
+	a2 := m.GetEndpointByNameAny(EndpointKey("A", "a2"))
+	m.MarkEndpointCapability(a2, CapabilityWebSearch, false, "Tool type web_search is not supported")
+	ctx = WithRequiredCapabilities(context.Background(), []string{CapabilityWebSearch})
+	if got := names(m.GetHealthyEndpointsForRequest(ctx)); !reflect.DeepEqual(got, []string{"a1", "a3"}) {
+		t.Errorf("web_search 候选端点 = %v, 期望 [a1 a3]", got)
+	}
+
+	// 配置声明优先于探测结果
+	a3 := m.GetEndpointByNameAny(EndpointKey("A", "a3"))
+	m.MarkEndpointCapability(a3, CapabilityWebSearch, false, "误判")
+	if status := m.endpointCapabilityStatus(a3, CapabilityWebSearch); !status.Supported || status.Source != CapabilitySourceConfigured {
+		t.Errorf("配置声明应优先, 实际 %+v", status)
+	}
+
+	// 重置探测结果后恢复为未知（视为支持）
+	m.ResetDetectedCapabilities(EndpointKey("A", "a2"))
+	if status := m.endpointCapabilityStatus(a2, CapabilityWebSearch); !status.Supported || status.Source != CapabilitySourceUnknown {
+		t.Errorf("重置后应为未知, 实际 %+v", status)
+	}
+
+	// 全部不支持且没有其他渠道时返回空，不再回退到全部健康端点
+	for _, ep := range m.GetAllEndpoints() {
+		m.MarkEndpointCapability(ep, CapabilityBatches, false, "not supported")
+	}
+	ctx = WithRequiredCapabilities(context.Background(), []string{CapabilityBatches})
+	if got := m.GetHealthyEndpointsForRequest(ctx); len(got) != 0 {
+		t.Errorf("无端点支持时应返回空, 实际 %d 个", len(got))
+	}
+}
+
+func TestGetHealthyEndpointsForRequest_DivertsForCapabilities(t *testing.T) {
+	m := newModelCatalogTestManager(t,
+		config.EndpointConfig{Name: "a1", URL: "http://example.invalid", Channel: "A", Priority: 1,
+			Capabilities: map[string]bool{CapabilityThinking: false}},
+		config.EndpointConfig{Name: "b1", URL: "http://example.invalid", Channel: "B", Priority: 2,
+			Capabilities: map[string]bool{CapabilityThinking: false}},
+		config.EndpointConfig{Name: "c1", URL: "http://example.invalid", Channel: "C", Priority: 3},
+	)
+	if err := m.ManualActivateGroup("A"); err != nil {
+		t.Fatalf("ManualActivateGroup(A) error: %v", err)
+	}
+
+	// A、B 渠道均不支持 thinking：本次请求改用 C 渠道
+	ctx := WithRequiredCapabilities(context.Background(), []string{CapabilityThinking})
+	if got := m.GetHealthyEndpointsForRequest(ctx); len(got) != 1 || got[0].Config.Name != "c1" {
+		t.Fatalf("thinking 应改用 C 渠道的 c1, 实际 %v", got)
+	}
+	if active := m.groupManager.GetActiveGroups(); len(active) != 1 || active[0].Name != "A" {
+		t.Errorf("按请求分流不应改变激活渠道, 实际 %+v", active)
+	}
+}
+
+func TestDetectedUnsupportedCapability_Expires(t *testing.T) {
+	m := newModelCatalogTestManager(t,
+		config.EndpointConfig{Name: "a1", URL: "http://example.invalid", Channel: "A", Priority: 1},
+	)
+	a1 := m.GetEndpointByNameAny(EndpointKey("A", "a1"))
+	m.MarkEndpointCapability(a1, CapabilityPromptCaching, false, "prompt caching is not supported")
+	if ok, _ := m.EndpointSupportsCapabilities(a1, []string{CapabilityPromptCaching}); ok {
+		t.Fatal("刚探测到不支持时应被筛除")
+	}
+
+	// 超过有效期后放行（半开探测）
+	m.capabilities.mu.Lock()
+	entry := m.capabilities.detected[EndpointKey("A", "a1")][CapabilityPromptCaching]
+	entry.detectedAt = time.Now().Add(-detectedUnsupportedTTL - time.Minute)
+	m.capabilities.detected[EndpointKey("A", "a1")][CapabilityPromptCaching] = entry
+	m.capabilities.mu.Unlock()
+	if status := m.endpointCapabilityStatus(a1, CapabilityPromptCaching); !status.Supported || status.Source != CapabilitySourceDetected {
+		t.Errorf("过期的不支持结果应放行, 实际 %+v", status)
+	}
+
+	// 放行后的请求成功即恢复为支持
+	m.MarkEndpointCapability(a1, CapabilityPromptCaching, true, "请求成功")
+	if status := m.endpointCapabilityStatus(a1, CapabilityPromptCaching); !status.Supported || status.Reason != "请求成功" {
+		t.Errorf("成功后应标记为支持, 实际 %+v", status)
+	}
+}
@@ -16,6 +16,44 @@ import (
 // v6.0: 以“渠道(channel)”为单位路由，优先只返回当前激活渠道内的端点；
 // 跨渠道切换由请求级故障转移触发（见 TriggerRequestFailover）。
 func (m *Manager) GetHealthyEndpoints() []*Endpoint {
+	return m.GetHealthyEndpointsForModel("")
+}
+
+// GetHealthyEndpointsForModel 与 GetHealthyEndpoints 相同，但额外按请求模型筛选端点（见 model_catalog.go）
+func (m *Manager) GetHealthyEndpointsForModel(model string) []*Endpoint {
+	return m.getHealthyEndpoints(model, nil)
+}
+
+// GetHealthyEndpointsForRequest 按上下文中的请求模型与所需能力筛选健康端点（见 capabilities.go）
+func (m *Manager) GetHealthyEndpointsForRequest(ctx context.Context) []*Endpoint {
+	return m.getHealthyEndpoints(RequestedModelFromContext(ctx), RequiredCapabilitiesFromContext(ctx))
+}
+
+// GetActiveHealthyEndpointsForRequest 与 GetHealthyEndpointsForRequest 相同，但只在当前激活渠道内筛选，不分流到其他渠道
+// 用于"是否还有可切换端点"之类的只读检查
+func (m *Manager) GetActiveHealthyEndpointsForRequest(ctx context.Context) []*Endpoint {
+	routable := m.filterEndpointsForRequest(m.activeHealthyEndpoints(true), RequestedModelFromContext(ctx), RequiredCapabilitiesFromContext(ctx))
+	return m.sortHealthyEndpoints(m.filterEndpointsByBudget(routable), false)
+}
+
+func (m *Manager) getHealthyEndpoints(model string, capabilities []string) []*Endpoint {
+	healthy := m.activeHealthyEndpoints(true)
+	if len(healthy) == 0 {
+		// 当前渠道没有可用端点：由上层触发跨渠道故障转移
+		return nil
+	}
+
+	// 2. 按能力与模型筛选；当前渠道没有端点满足请求时本次请求改用提供该模型与能力的渠道
+	routable := m.filterEndpointsForRequest(healthy, model, capabilities)
+	if len(routable) == 0 {
+		routable = m.divertEndpointsForRequest(model, capabilities, true)
+	}
+	return m.sortHealthyEndpoints(m.filterEndpointsByBudget(routable), true)
+}
+
+// activeHealthyEndpoints 返回当前激活渠道中参与故障转移的健康端点
+// checkCooldown 为 true 时跳过请求冷却中的端点
+func (m *Manager) activeHealthyEndpoints(checkCooldown bool) []*Endpoint {
 	// v5.0+: 使用快照机制
 	m.endpointsMu.RLock()
 	snapshot := make([]*Endpoint, len(m.endpoints))
@@ -23,11 +61,28 @@ func (m *Manager) GetHealthyEndpoints() []*Endpoint {
 	m.endpointsMu.RUnlock()
 
 	// 1. 首先尝试获取活跃组（当前激活渠道）的端点
-	activeEndpoints := m.groupManager.FilterEndpointsByActiveGroups(snapshot)
+	return healthyFailoverEndpoints(m.groupManager.FilterEndpointsByActiveGroups(snapshot), checkCooldown)
+}
 
+// channelHealthyEndpoints 返回指定渠道中参与故障转移的健康端点（不要求渠道处于激活状态）
+func (m *Manager) channelHealthyEndpoints(channel string, checkCooldown bool) []*Endpoint {
+	m.endpointsMu.RLock()
+	var channelEndpoints []*Endpoint
+	for _, ep := range m.endpoints {
+		if ChannelKey(ep) == channel {
+			channelEndpoints = append(channelEndpoints, ep)
+		}
+	}
+	m.endpointsMu.RUnlock()
+
+	return healthyFailoverEndpoints(channelEndpoints, checkCooldown)
+}
+
+// healthyFailoverEndpoints 筛选参与故障转移的健康端点
+func healthyFailoverEndpoints(endpoints []*Endpoint, checkCooldown bool) []*Endpoint {
 	now := time.Now()
 	var healthy []*Endpoint
-	for _, endpoint := range activeEndpoints {
+	for _, endpoint := range endpoints {
 		// 检查是否参与故障转移（默认为 true），不参与则不作为代理候选
 		failoverEnabled := true
 		if endpoint.Config.FailoverEnabled != nil {
@@ -40,7 +95,7 @@ func (m *Manager) GetHealthyEndpoints() []*Endpoint {
 		endpoint.mutex.RLock()
 		isHealthy := endpoint.Status.Healthy
 		// 检查是否在请求冷却中
-		inCooldown := !endpoint.Status.CooldownUntil.IsZero() && now.Before(endpoint.Status.CooldownUntil)
+		inCooldown := checkCooldown && !endpoint.Status.CooldownUntil.IsZero() && now.Before(endpoint.Status.CooldownUntil)
 		endpoint.mutex.RUnlock()
 
 		if isHealthy && !inCooldown {
@@ -49,14 +104,45 @@ func (m *Manager) GetHealthyEndpoints() []*Endpoint {
 			slog.Debug(fmt.Sprintf("⏭️ [端点选择] 跳过冷却中的端点: %s", endpoint.Config.Name))
 		}
 	}
+	return healthy
+}
+
+// filterEndpointsForRequest 按请求所需能力与模型筛选端点
+func (m *Manager) filterEndpointsForRequest(endpoints []*Endpoint, model string, capabilities []string) []*Endpoint {
+	return m.filterEndpointsByModel(m.filterEndpointsByCapabilities(endpoints, capabilities), model)
+}
 
-	// 2. 如果当前激活渠道有可用端点，直接返回
-	if len(healthy) > 0 {
-		return m.sortHealthyEndpoints(healthy, true)
+// divertEndpointsForRequest 当前渠道没有端点满足请求（模型、能力）时，按故障转移顺序选出有健康端点满足请求的渠道，
+// 仅为本次请求返回该渠道的端点；不切换激活渠道，避免混合模型流量导致激活渠道来回翻转
+func (m *Manager) divertEndpointsForRequest(model string, capabilities []string, checkCooldown bool) []*Endpoint {
+	if (model == "" && len(capabilities) == 0) || m.groupManager == nil || m.config == nil {
+		return nil
+	}
+	active := m.groupManager.GetActiveGroups()
+	if len(active) == 0 || active[0] == nil {
+		return nil
+	}
+	fromChannel := active[0].Name
+
+	target := m.selectChannelWithEndpoint(fromChannel, func(ep *Endpoint) bool {
+		supported, _ := m.EndpointSupportsCapabilities(ep, capabilities)
+		return supported && m.EndpointSupportsModel(ep, model)
+	})
+	if target == "" {
+		slog.Warn(fmt.Sprintf("⚠️ [端点选择] 渠道 %s 没有端点支持模型 %s / 能力 %v，且没有其他渠道可用", fromChannel, model, capabilities))
+		return nil
 	}
 
-	// 当前渠道没有可用端点：由上层触发跨渠道故障转移
-	return nil
+	routable := m.filterEndpointsForRequest(m.channelHealthyEndpoints(target, checkCooldown), model, capabilities)
+	if len(routable) > 0 {
+		slog.Info(fmt.Sprintf("🔀 [请求路由] 渠道 %s 没有端点支持模型 %s / 能力 %v，本次请求改用渠道: %s", fromChannel, model, capabilities, target))
+	}
+	return routable
+}
+
+// FilterEndpointsForRequest 按上下文中的请求模型与所需能力筛选端点，并跳过超出预算的端点（用于忽略健康状态的回退路径）
+func (m *Manager) FilterEndpointsForRequest(ctx context.Context, endpoints []*Endpoint) []*Endpoint {
+	return m.filterEndpointsByBudget(m.filterEndpointsForRequest(endpoints, RequestedModelFromContext(ctx), RequiredCapabilitiesFromContext(ctx)))
 }
 
 // sortHealthyEndpoints sorts healthy endpoints based on strategy with optional logging
@@ -95,33 +181,17 @@ func (m *Manager) sortHealthyEndpoints(healthy []*Endpoint, showLogs bool) []*En
 // GetFastestEndpointsWithRealTimeTest returns endpoints from active groups sorted by real-time testing.
 // v6.0: 以“渠道(channel)”为单位路由，只测试/排序当前激活渠道内端点。
 func (m *Manager) GetFastestEndpointsWithRealTimeTest(ctx context.Context) []*Endpoint {
-	// v5.0+: 使用快照机制
-	m.endpointsMu.RLock()
-	snapshot := make([]*Endpoint, len(m.endpoints))
-	copy(snapshot, m.endpoints)
-	m.endpointsMu.RUnlock()
-
-	// 1. 首先尝试获取活跃组（当前激活渠道）的端点
-	activeEndpoints := m.groupManager.FilterEndpointsByActiveGroups(snapshot)
-
-	var healthy []*Endpoint
-	for _, endpoint := range activeEndpoints {
-		// 检查是否参与故障转移（默认为 true），不参与则不作为代理候选
-		failoverEnabled := true
-		if endpoint.Config.FailoverEnabled != nil {
-			failoverEnabled = *endpoint.Config.FailoverEnabled
-		}
-		if !failoverEnabled {
-			continue
-		}
-
-		endpoint.mutex.RLock()
-		if endpoint.Status.Healthy {
-			healthy = append(healthy, endpoint)
-		}
-		endpoint.mutex.RUnlock()
+	healthy := m.activeHealthyEndpoints(false)
+	if len(healthy) == 0 {
+		return healthy
 	}
-
+	model := RequestedModelFromContext(ctx)
+	capabilities := RequiredCapabilitiesFromContext(ctx)
+	routable := m.filterEndpointsForRequest(healthy, model, capabilities)
+	if len(routable) == 0 {
+		routable = m.divertEndpointsForRequest(model, capabilities, false)
+	}
+	healthy = m.filterEndpointsByBudget(routable)
 	if len(healthy) == 0 {
 		return healthy
 	}
@@ -35,6 +35,14 @@ func (m *Manager) collectFailoverCandidates(excludeChannel string) []groupCandid
 		if !g.CooldownUntil.IsZero() && now.Before(g.CooldownUntil) {
 			continue
 		}
+		// 超出渠道级预算硬限制的渠道不作为切换目标
+		if m.ChannelBlockedByBudget(g.Name) {
+			continue
+		}
+		// 余额低于阈值（且开启排除）的中转渠道不作为切换目标
+		if m.ChannelExcludedByBalance(g.Name) {
+			continue
+		}
 
 		// 渠道内至少有一个“可用端点”才视为可切换（与渠道内路由候选保持一致）
 		hasAvailableEndpoint := false
@@ -67,6 +75,9 @@ func (m *Manager) collectFailoverCandidates(excludeChannel string) []groupCandid
 			if !(isHealthy || neverChecked) {
 				continue
 			}
+			if m.EndpointBlockedByBudget(ep) {
+				continue
+			}
 
 			hasAvailableEndpoint = true
 
@@ -144,6 +155,46 @@ func (m *Manager) SelectNextAvailableChannel(excludeChannel string) (string, err
 	return candidates[0].name, nil
 }
 
+// selectChannelWithEndpoint 按故障转移顺序选择存在满足 accept 的健康端点的渠道（未找到时返回空字符串）
+func (m *Manager) selectChannelWithEndpoint(excludeChannel string, accept func(ep *Endpoint) bool) string {
+	candidates := m.collectFailoverCandidates(excludeChannel)
+	if len(candidates) == 0 {
+		return ""
+	}
+	strategy := "priority"
+	if m.config.Strategy.Type != "" {
+		strategy = m.config.Strategy.Type
+	}
+	sortFailoverCandidates(strategy, candidates)
+
+	groups := make(map[string]*GroupInfo)
+	for _, g := range m.groupManager.GetAllGroups() {
+		if g != nil {
+			groups[g.Name] = g
+		}
+	}
+
+	now := time.Now()
+	for _, c := range candidates {
+		g := groups[c.name]
+		if g == nil {
+			continue
+		}
+		for _, ep := range g.Endpoints {
+			if ep == nil || (ep.Config.FailoverEnabled != nil && !*ep.Config.FailoverEnabled) {
+				continue
+			}
+			ep.mutex.RLock()
+			available := ep.Status.Healthy && (ep.Status.CooldownUntil.IsZero() || !now.Before(ep.Status.CooldownUntil))
+			ep.mutex.RUnlock()
+			if available && !m.EndpointBlockedByBudget(ep) && accept(ep) {
+				return c.name
+			}
+		}
+	}
+	return ""
+}
+
 // SetOnFailoverTriggered 设置故障转移回调
 // 当请求失败触发“跨渠道”故障转移时调用，用于同步数据库
 func (m *Manager) SetOnFailoverTriggered(fn func(failedChannel, newChannel string)) {
@@ -234,6 +285,7 @@ func (m *Manager) TriggerRequestFailoverWithFailedEndpoints(failedEndpointNames
 	newChannel, err := m.SelectNextAvailableChannel(failedChannel)
 	if err != nil {
 		slog.Error("❌ [故障转移] 没有可用的故障转移渠道")
+		go m.notifyFailover(failedChannel, "", reason, uniqueNames)
 		return "", err
 	}
 
@@ -243,11 +295,13 @@ func (m *Manager) TriggerRequestFailoverWithFailedEndpoints(failedEndpointNames
 		slog.Warn(fmt.Sprintf("⚠️ [故障转移] 常规激活新渠道失败，回退强制激活: %s, 错误: %v", newChannel, err))
 		if err2 := m.groupManager.ManualActivateGroupWithForce(newChannel, true); err2 != nil {
 			slog.Error(fmt.Sprintf("❌ [故障转移] 强制激活新渠道失败: %v", err2))
+			go m.notifyFailover(failedChannel, "", reason, uniqueNames)
 			return "", fmt.Errorf("激活新渠道失败: %w", err2)
 		}
 	}
 
 	slog.Info(fmt.Sprintf("✅ [故障转移] 已切换到渠道: %s", newChannel))
+	go m.notifyFailover(failedChannel, newChannel, reason, uniqueNames)
 
 	// 5) 调用回调通知 App 层同步数据库
 	if m.onFailoverTriggered != nil {
@@ -216,6 +216,7 @@ func (m *Manager) updateEndpointStatus(endpoint *Endpoint, healthy bool, respons
 
 	endpoint.Status.LastCheck = time.Now()
 	endpoint.Status.ResponseTime = responseTime
+	wasNeverChecked := endpoint.Status.NeverChecked
 	endpoint.Status.NeverChecked = false // 标记为已检测
 
 	// 记录状态变化前的健康状态
@@ -251,6 +252,13 @@ func (m *Manager) updateEndpointStatus(endpoint *Endpoint, healthy bool, respons
 	// 通知Web界面端点状态变化
 	go m.notifyWebInterface(endpoint)
 
+	// 健康状态切换通知：首次检测健康不视为恢复，首次检测即不可用视为宕机
+	wentDown := !healthy && (!wasUnhealthy || wasNeverChecked)
+	cameUp := healthy && wasUnhealthy && !wasNeverChecked
+	if wentDown || cameUp {
+		go m.notifyEndpointTransition(endpoint, healthy)
+	}
+
 	// v5.0+: 当端点从不健康变为健康时，重新评估组的激活状态
 	// 这对新增端点后立即激活特别重要
 	if healthy && wasUnhealthy {
@@ -60,6 +60,18 @@ type Manager struct {
 	// 故障转移回调（用于同步数据库）
 	// 参数: failedChannel 失败的渠道名, newChannel 新激活的渠道名
 	onFailoverTriggered func(failedChannel, newChannel string)
+	// 端点模型目录（/v1/models 发现 + 手动覆盖）
+	modelCatalog *modelCatalog
+	// 模型发现完成回调（用于持久化到数据库）
+	onModelsDiscovered func(channel, endpointName string, models []ModelInfo)
+	// 端点能力探测结果（配置未声明的能力）
+	capabilities *capabilityRegistry
+	// 预算守卫（超出预算的渠道/端点不参与路由）
+	budgetGuard   BudgetGuard
+	budgetGuardMu sync.RWMutex
+	// 余额守卫（余额不足的中转渠道不作为故障转移目标）
+	balanceGuard   BalanceGuard
+	balanceGuardMu sync.RWMutex
 }
 
 // UpdateChannelPriorities 同步渠道优先级到运行时组管理器，用于“渠道间”故障转移顺序。
@@ -101,6 +113,8 @@ func NewManager(cfg *config.Config) *Manager {
 		fastTester:   NewFastTester(cfg),
 		groupManager: NewGroupManager(cfg),
 		keyManager:   NewKeyManager(), // 初始化 Key 管理器
+		modelCatalog: newModelCatalog(),
+		capabilities: newCapabilityRegistry(),
 	}
 
 	// Initialize endpoints
@@ -140,6 +154,11 @@ func NewManager(cfg *config.Config) *Manager {
 func (m *Manager) Start() {
 	m.wg.Add(1)
 	go m.healthCheckLoop()
+
+	if m.config.ModelDiscovery.Enabled {
+		m.wg.Add(1)
+		go m.modelDiscoveryLoop()
+	}
 }
 
 // Stop stops the health checking routine
@@ -34,6 +34,9 @@ func (m *MockEventBus) SetSSEBroadcaster(broadcaster events.SSEBroadcaster) {
 	m.broadcaster = broadcaster
 }
 
+// Subscribe 实现EventBus接口
+func (m *MockEventBus) Subscribe(handler events.EventHandler) {}
+
 // Start 实现EventBus接口
 func (m *MockEventBus) Start() error {
 	return nil
@@ -0,0 +1,447 @@
+// model_catalog.go - 端点模型目录
+// 包含 /v1/models 模型发现、手动覆盖、按请求模型筛选端点
+
+package endpoint
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 模型发现分页上限，避免异常中转站无限返回 has_more
+const maxModelDiscoveryPages = 20
+
+// ModelInfo 模型信息（/v1/models 返回的单个模型）
+type ModelInfo struct {
+	ID          string `json:"id"`
+	DisplayName string `json:"display_name,omitempty"`
+	CreatedAt   string `json:"created_at,omitempty"`
+}
+
+// EndpointModels 端点模型目录快照
+type EndpointModels struct {
+	Models       []ModelInfo     // 自动发现的模型
+	Overrides    map[string]bool // 手动覆盖: model -> 允许/禁止
+	DiscoveredAt time.Time       // 最近一次成功发现时间
+	LastError    string          // 最近一次发现失败原因
+}
+
+// modelCatalog 运行时端点模型目录（按 EndpointKey 索引）
+type modelCatalog struct {
+	mu      sync.RWMutex
+	entries map[string]*EndpointModels
+}
+
+func newModelCatalog() *modelCatalog {
+	return &modelCatalog{entries: make(map[string]*EndpointModels)}
+}
+
+// entry 获取或创建端点条目（调用方需持有写锁）
+func (c *modelCatalog) entry(key string) *EndpointModels {
+	e, ok := c.entries[key]
+	if !ok {
+		e = &EndpointModels{Overrides: make(map[string]bool)}
+		c.entries[key] = e
+	}
+	return e
+}
+
+// supports 判断端点是否支持指定模型
+// 规则：手动禁止 > 手动允许 > 自动发现；未发现过模型的端点视为支持（乐观策略）
+func (c *modelCatalog) supports(key, model string) bool {
+	if model == "" {
+		return true
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		return true
+	}
+	if allowed, ok := e.Overrides[model]; ok {
+		return allowed
+	}
+	if len(e.Models) == 0 {
+		return true
+	}
+	for _, m := range e.Models {
+		if modelIDMatches(m.ID, model) {
+			return true
+		}
+	}
+	return false
+}
+
+// modelIDMatches 判断发现的模型 ID 是否匹配请求模型
+// 仅一方带 -YYYYMMDD 快照后缀时去掉后缀比较：claude-sonnet-4-5 与 claude-sonnet-4-5-20250929 互相匹配；
+// claude-sonnet-4 不匹配 claude-sonnet-4-5-20250929，两个不同日期的快照也互不匹配
+func modelIDMatches(discovered, requested string) bool {
+	if discovered == requested {
+		return true
+	}
+	discoveredBase, requestedBase := stripSnapshotSuffix(discovered), stripSnapshotSuffix(requested)
+	if (discoveredBase == discovered) == (requestedBase == requested) {
+		return false
+	}
+	return discoveredBase == requestedBase
+}
+
+// stripSnapshotSuffix 去掉模型 ID 末尾的 -YYYYMMDD 快照日期
+func stripSnapshotSuffix(model string) string {
+	const suffixLen = len("-20060102")
+	if len(model) <= suffixLen || model[len(model)-suffixLen] != '-' {
+		return model
+	}
+	for _, c := range model[len(model)-suffixLen+1:] {
+		if c < '0' || c > '9' {
+			return model
+		}
+	}
+	return model[:len(model)-suffixLen]
+}
+
+// ==================== 请求模型上下文 ====================
+
+type requestedModelKey struct{}
+
+// WithRequestedModel 将请求模型写入上下文，供端点选择时按模型筛选
+func WithRequestedModel(ctx context.Context, model string) context.Context {
+	if model == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, requestedModelKey{}, model)
+}
+
+// RequestedModelFromContext 从上下文获取请求模型
+func RequestedModelFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	model, _ := ctx.Value(requestedModelKey{}).(string)
+	return model
+}
+
+// ==================== 目录维护 ====================
+
+// SetOnModelsDiscovered 设置模型发现完成回调（用于持久化到数据库）
+func (m *Manager) SetOnModelsDiscovered(fn func(channel, endpointName string, models []ModelInfo)) {
+	m.onModelsDiscovered = fn
+}
+
+// SetEndpointModels 设置端点已发现的模型（启动时从数据库加载）
+func (m *Manager) SetEndpointModels(endpointKey string, models []ModelInfo, discoveredAt time.Time) {
+	m.modelCatalog.mu.Lock()
+	defer m.modelCatalog.mu.Unlock()
+
+	e := m.modelCatalog.entry(endpointKey)
+	e.Models = append([]ModelInfo(nil), models...)
+	e.DiscoveredAt = discoveredAt
+	e.LastError = ""
+}
+
+// SetEndpointModelOverride 设置端点模型手动覆盖
+func (m *Manager) SetEndpointModelOverride(endpointKey, model string, allowed bool) {
+	m.modelCatalog.mu.Lock()
+	defer m.modelCatalog.mu.Unlock()
+
+	m.modelCatalog.entry(endpointKey).Overrides[model] = allowed
+}
+
+// RemoveEndpointModelOverride 移除端点模型手动覆盖
+func (m *Manager) RemoveEndpointModelOverride(endpointKey, model string) {
+	m.modelCatalog.mu.Lock()
+	defer m.modelCatalog.mu.Unlock()
+
+	if e, ok := m.modelCatalog.entries[endpointKey]; ok {
+		delete(e.Overrides, model)
+	}
+}
+
+// RemoveEndpointModels 移除端点的模型目录（端点删除时调用）
+func (m *Manager) RemoveEndpointModels(endpointKey string) {
+	m.modelCatalog.mu.Lock()
+	defer m.modelCatalog.mu.Unlock()
+
+	delete(m.modelCatalog.entries, endpointKey)
+}
+
+// GetEndpointModels 获取端点模型目录快照
+func (m *Manager) GetEndpointModels(endpointKey string) EndpointModels {
+	m.modelCatalog.mu.RLock()
+	defer m.modelCatalog.mu.RUnlock()
+
+	e, ok := m.modelCatalog.entries[endpointKey]
+	if !ok {
+		return EndpointModels{Overrides: map[string]bool{}}
+	}
+
+	overrides := make(map[string]bool, len(e.Overrides))
+	for k, v := range e.Overrides {
+		overrides[k] = v
+	}
+	return EndpointModels{
+		Models:       append([]ModelInfo(nil), e.Models...),
+		Overrides:    overrides,
+		DiscoveredAt: e.DiscoveredAt,
+		LastError:    e.LastError,
+	}
+}
+
+// EndpointSupportsModel 判断端点是否支持指定模型
+func (m *Manager) EndpointSupportsModel(ep *Endpoint, model string) bool {
+	if ep == nil {
+		return false
+	}
+	return m.modelCatalog.supports(endpointKeyFromConfig(ep.Config), model)
+}
+
+// ListAvailableModels 返回所有端点可用模型的并集（用于响应 /v1/models）
+// 未发现任何模型时返回空列表，调用方应回退到透传上游
+func (m *Manager) ListAvailableModels() []ModelInfo {
+	endpoints := m.GetAllEndpoints()
+
+	m.modelCatalog.mu.RLock()
+	defer m.modelCatalog.mu.RUnlock()
+
+	seen := make(map[string]bool)
+	var result []ModelInfo
+	for _, ep := range endpoints {
+		if ep.Config.Enabled != nil && !*ep.Config.Enabled {
+			continue
+		}
+		e, ok := m.modelCatalog.entries[endpointKeyFromConfig(ep.Config)]
+		if !ok {
+			continue
+		}
+		for _, model := range e.Models {
+			if seen[model.ID] {
+				continue
+			}
+			if allowed, ok := e.Overrides[model.ID]; ok && !allowed {
+				continue
+			}
+			seen[model.ID] = true
+			result = append(result, model)
+		}
+		for model, allowed := range e.Overrides {
+			if allowed && !seen[model] {
+				seen[model] = true
+				result = append(result, ModelInfo{ID: model})
+			}
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ID < result[j].ID
+	})
+	return result
+}
+
+// filterEndpointsByModel 按请求模型筛选端点（未发现模型列表的端点视为支持）
+// 若筛选后无可用端点返回空，由 getHealthyEndpoints 为本次请求改用目录包含该模型的渠道（见 divertEndpointsForRequest）
+func (m *Manager) filterEndpointsByModel(endpoints []*Endpoint, model string) []*Endpoint {
+	if model == "" || len(endpoints) == 0 {
+		return endpoints
+	}
+
+	filtered := make([]*Endpoint, 0, len(endpoints))
+	for _, ep := range endpoints {
+		if m.EndpointSupportsModel(ep, model) {
+			filtered = append(filtered, ep)
+		} else {
+			slog.Debug(fmt.Sprintf("⏭️ [端点选择] 跳过不支持模型 %s 的端点: %s", model, ep.Config.Name))
+		}
+	}
+	return filtered
+}
+
+// ==================== 模型发现 ====================
+
+// modelListResponse /v1/models 响应（兼容 Anthropic 与 OpenAI 格式）
+type modelListResponse struct {
+	Data    []ModelInfo `json:"data"`
+	HasMore bool        `json:"has_more"`
+	LastID  string      `json:"last_id"`
+}
+
+// DiscoverEndpointModels 请求端点的 /v1/models 获取模型列表（支持分页）
+func (m *Manager) DiscoverEndpointModels(ctx context.Context, ep *Endpoint) ([]ModelInfo, error) {
+	timeout := m.config.ModelDiscovery.Timeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	path := m.config.ModelDiscovery.Path
+	if path == "" {
+		path = "/v1/models"
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	baseURL := strings.TrimRight(strings.TrimSpace(ep.Config.URL), "/")
+	var models []ModelInfo
+	afterID := ""
+
+	for page := 0; page < maxModelDiscoveryPages; page++ {
+		query := url.Values{}
+		query.Set("limit", "1000")
+		if afterID != "" {
+			query.Set("after_id", afterID)
+		}
+
+		req, err := http.NewRequestWithContext(ctx, "GET", baseURL+path+"?"+query.Encode(), nil)
+		if err != nil {
+			return nil, fmt.Errorf("创建请求失败: %w", err)
+		}
+		req.Header.Set("anthropic-version", "2023-06-01")
+		if token := m.GetTokenForEndpoint(ep); token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		if apiKey := m.GetApiKeyForEndpoint(ep); apiKey != "" {
+			req.Header.Set("x-api-key", apiKey)
+			if req.Header.Get("Authorization") == "" {
+				req.Header.Set("Authorization", "Bearer "+apiKey)
+			}
+		}
+
+		resp, err := m.client.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("请求失败: %w", err)
+		}
+		body, err := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("读取响应失败: %w", err)
+		}
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+		}
+
+		var list modelListResponse
+		if err := json.Unmarshal(body, &list); err != nil {
+			return nil, fmt.Errorf("解析模型列表失败: %w", err)
+		}
+		for _, model := range list.Data {
+			if model.ID != "" {
+				models = append(models, model)
+			}
+		}
+
+		if !list.HasMore || list.LastID == "" || list.LastID == afterID {
+			break
+		}
+		afterID = list.LastID
+	}
+
+	return models, nil
+}
+
+// RefreshEndpointModels 刷新单个端点的模型目录
+func (m *Manager) RefreshEndpointModels(ctx context.Context, ep *Endpoint) error {
+	key := endpointKeyFromConfig(ep.Config)
+
+	models, err := m.DiscoverEndpointModels(ctx, ep)
+	if err != nil {
+		m.modelCatalog.mu.Lock()
+		m.modelCatalog.entry(key).LastError = err.Error()
+		m.modelCatalog.mu.Unlock()
+		slog.Warn(fmt.Sprintf("⚠️ [模型发现] 端点 %s 模型发现失败: %v", key, err))
+		return err
+	}
+
+	// 返回空列表的端点保留原有目录，避免中转站临时异常导致全部被过滤
+	if len(models) == 0 {
+		slog.Debug(fmt.Sprintf("🔍 [模型发现] 端点 %s 未返回任何模型，保留原有目录", key))
+		return nil
+	}
+
+	m.SetEndpointModels(key, models, time.Now())
+	slog.Debug(fmt.Sprintf("🔍 [模型发现] 端点 %s 发现 %d 个模型", key, len(models)))
+
+	if m.onModelsDiscovered != nil {
+		m.onModelsDiscovered(ep.Config.Channel, ep.Config.Name, models)
+	}
+	return nil
+}
+
+// RefreshAllEndpointModels 刷新所有端点的模型目录
+// 返回: 成功数, 失败数
+func (m *Manager) RefreshAllEndpointModels(ctx context.Context) (int, int) {
+	endpoints := m.GetAllEndpoints()
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	succeeded, failed := 0, 0
+
+	for _, ep := range endpoints {
+		if ep.Config.Enabled != nil && !*ep.Config.Enabled {
+			continue
+		}
+		wg.Add(1)
+		go func(ep *Endpoint) {
+			defer wg.Done()
+			err := m.RefreshEndpointModels(ctx, ep)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				failed++
+			} else {
+				succeeded++
+			}
+		}(ep)
+	}
+	wg.Wait()
+
+	slog.Info(fmt.Sprintf("🔍 [模型发现] 模型目录刷新完成: 成功 %d, 失败 %d", succeeded, failed))
+	return succeeded, failed
+}
+
+// modelDiscoveryLoop 定期刷新端点模型目录
+func (m *Manager) modelDiscoveryLoop() {
+	defer m.wg.Done()
+
+	getInterval := func() time.Duration {
+		interval := m.config.ModelDiscovery.Interval
+		if interval <= 0 {
+			interval = 6 * time.Hour
+		}
+		return interval
+	}
+
+	currentInterval := getInterval()
+	ticker := time.NewTicker(currentInterval)
+	defer ticker.Stop()
+
+	// 启动时执行一次
+	m.RefreshAllEndpointModels(m.ctx)
+
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-ticker.C:
+			if !m.config.ModelDiscovery.Enabled {
+				continue
+			}
+			m.RefreshAllEndpointModels(m.ctx)
+
+			if newInterval := getInterval(); newInterval != currentInterval {
+				slog.Info("🔄 [模型发现] 间隔已更新", "old", currentInterval, "new", newInterval)
+				currentInterval = newInterval
+				ticker.Reset(currentInterval)
+			}
+		}
+	}
+}
@@ -0,0 +1,256 @@
+package endpoint
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"cc-forwarder/config"
+)
+
+func newModelCatalogTestManager(t *testing.T, endpoints ...config.EndpointConfig) *Manager {
+	t.Helper()
+
+	cfg := &config.Config{
+		Strategy:  config.StrategyConfig{Type: "priority"},
+		Health:    config.HealthConfig{Timeout: 5 * time.Second},
+		Endpoints: endpoints,
+	}
+	m := NewManager(cfg)
+	for _, ep := range m.GetAllEndpoints() {
+		ep.mutex.Lock()
+		ep.Status.Healthy = true
+		ep.Status.NeverChecked = false
+		ep.mutex.Unlock()
+	}
+	return m
+}
+
+func TestModelIDMatches(t *testing.T) {
+	testCases := []struct {
+		discovered string
+		requested  string
+		expected   bool
+	}{
+		{"claude-sonnet-4-5-20250929", "claude-sonnet-4-5-20250929", true},
+		{"claude-sonnet-4-5-20250929", "claude-sonnet-4-5", true},
+		{"claude-sonnet-4-5", "claude-sonnet-4-5-20250929", true},
+		{"claude-sonnet-4-5-20250514", "claude-sonnet-4-5-20250929", false},
+		{"claude-sonnet-4-5-20250929", "claude-sonnet-4", false},
+		{"claude-sonnet-4-5-20250929", "claude-sonnet-4-", false},
+		{"claude-sonnet-4-5-20250929", "claude-sonnet", false},
+		{"claude-sonnet-4-5-2025092x", "claude-sonnet-4-5", false},
+		{"claude-sonnet-45", "claude-sonnet-4", false},
+		{"claude-opus-4-1", "claude-sonnet-4", false},
+	}
+
+	for _, tc := range testCases {
+		if got := modelIDMatches(tc.discovered, tc.requested); got != tc.expected {
+			t.Errorf("modelIDMatches(%q, %q) = %v, 期望 %v", tc.discovered, tc.requested, got, tc.expected)
+		}
+	}
+}
+
+func TestGetHealthyEndpointsForModel_FiltersByCatalog(t *testing.T) {
+	m := newModelCatalogTestManager(t,
+		config.EndpointConfig{Name: "a1", URL: "http://example.invalid", Channel: "A", Priority: 1},
+		config.EndpointConfig{Name: "a2", URL: "http://example.invalid", Channel: "A", Priority: 2},
+		config.EndpointConfig{Name: "a3", URL: "http://example.invalid", Channel: "A", Priority: 3},
+	)
+	if err := m.ManualActivateGroup("A"); err != nil {
+		t.Fatalf("ManualActivateGroup(A) error: %v", err)
+	}
+
+	// a1 只支持 sonnet，a2 只支持 opus，a3 未发现（视为支持）
+	m.SetEndpointModels(EndpointKey("A", "a1"), []ModelInfo{{ID: "claude-sonnet-4-5-20250929"}}, time.Now())
+	m.SetEndpointModels(EndpointKey("A", "a2"), []ModelInfo{{ID: "claude-opus-4-1-20250805"}}, time.Now())
+
+	names := func(eps []*Endpoint) []string {
+		var result []string
+		for _, ep := range eps {
+			result = append(result, ep.Config.Name)
+		}
+		return result
+	}
+
+	if got := names(m.GetHealthyEndpointsForModel("claude-opus-4-1")); len(got) != 2 || got[0] != "a2" || got[1] != "a3" {
+		t.Errorf("opus 候选端点 = %v, 期望 [a2 a3]", got)
+	}
+	if got := names(m.GetHealthyEndpointsForModel("claude-sonnet-4-5")); len(got) != 2 || got[0] != "a1" || got[1] != "a3" {
+		t.Errorf("sonnet 候选端点 = %v, 期望 [a1 a3]", got)
+	}
+	if got := names(m.GetHealthyEndpoints()); len(got) != 3 {
+		t.Errorf("未指定模型时应返回全部健康端点, 实际 %v", got)
+	}
+
+	// 手动禁止优先于自动发现
+	m.SetEndpointModelOverride(EndpointKey("A", "a2"), "claude-opus-4-1", false)
+	if got := names(m.GetHealthyEndpointsForModel("claude-opus-4-1")); len(got) != 1 || got[0] != "a3" {
+		t.Errorf("手动禁止后 opus 候选端点 = %v, 期望 [a3]", got)
+	}
+
+	// 手动允许可覆盖未发现的模型
+	m.SetEndpointModelOverride(EndpointKey("A", "a1"), "claude-haiku-4-5", true)
+	if got := names(m.GetHealthyEndpointsForModel("claude-haiku-4-5")); len(got) != 2 || got[0] != "a1" || got[1] != "a3" {
+		t.Errorf("手动允许后 haiku 候选端点 = %v, 期望 [a1 a3]", got)
+	}
+}
+
+func TestGetHealthyEndpointsForModel_SnapshotAliases(t *testing.T) {
+	m := newModelCatalogTestManager(t,
+		config.EndpointConfig{Name: "a1", URL: "http://example.invalid", Channel: "A", Priority: 1},
+		config.EndpointConfig{Name: "a2", URL: "http://example.invalid", Channel: "A", Priority: 2},
+	)
+	if err := m.ManualActivateGroup("A"); err != nil {
+		t.Fatalf("ManualActivateGroup(A) error: %v", err)
+	}
+	// a1 只列出别名，a2 只列出带日期的快照
+	m.SetEndpointModels(EndpointKey("A", "a1"), []ModelInfo{{ID: "claude-sonnet-4-5"}}, time.Now())
+	m.SetEndpointModels(EndpointKey("A", "a2"), []ModelInfo{{ID: "claude-sonnet-4-5-20250929"}}, time.Now())
+
+	// 带日期的请求应匹配只列出别名的 a1
+	if got := m.GetHealthyEndpointsForModel("claude-sonnet-4-5-20250929"); len(got) != 2 {
+		t.Errorf("带日期请求候选端点数 = %d, 期望 2", len(got))
+	}
+	// claude-sonnet-4 是不同模型，不应被 claude-sonnet-4-5 的端点服务
+	if m.EndpointSupportsModel(m.GetAllEndpoints()[0], "claude-sonnet-4") {
+		t.Error("claude-sonnet-4 不应匹配 claude-sonnet-4-5")
+	}
+	if m.EndpointSupportsModel(m.GetAllEndpoints()[1], "claude-sonnet-4") {
+		t.Error("claude-sonnet-4 不应匹配 claude-sonnet-4-5-20250929")
+	}
+}
+
+func TestGetHealthyEndpointsForModel_DivertsWhenNoneSupports(t *testing.T) {
+	m := newModelCatalogTestManager(t,
+		config.EndpointConfig{Name: "a1", URL: "http://example.invalid", Channel: "A", Priority: 1},
+		config.EndpointConfig{Name: "a2", URL: "http://example.invalid", Channel: "A", Priority: 2},
+		config.EndpointConfig{Name: "b1", URL: "http://example.invalid", Channel: "B", Priority: 3},
+		config.EndpointConfig{Name: "c1", URL: "http://example.invalid", Channel: "C", Priority: 4},
+	)
+	if err := m.ManualActivateGroup("A"); err != nil {
+		t.Fatalf("ManualActivateGroup(A) error: %v", err)
+	}
+	m.SetEndpointModels(EndpointKey("A", "a1"), []ModelInfo{{ID: "claude-sonnet-4-5-20250929"}}, time.Now())
+	m.SetEndpointModels(EndpointKey("A", "a2"), []ModelInfo{{ID: "claude-sonnet-4-5-20250929"}}, time.Now())
+	m.SetEndpointModels(EndpointKey("B", "b1"), []ModelInfo{{ID: "claude-sonnet-4-5-20250929"}}, time.Now())
+	m.SetEndpointModels(EndpointKey("C", "c1"), []ModelInfo{{ID: "claude-opus-4-1-20250805"}}, time.Now())
+
+	// A 渠道没有端点提供 opus：本次请求改用目录包含 opus 的 C 渠道（跳过同样不提供的 B）
+	got := m.GetHealthyEndpointsForModel("claude-opus-4-1")
+	if len(got) != 1 || got[0].Config.Name != "c1" {
+		t.Fatalf("opus 应改用 C 渠道的 c1, 实际 %v", got)
+	}
+	if active := m.groupManager.GetActiveGroups(); len(active) != 1 || active[0].Name != "A" {
+		t.Fatalf("按请求分流不应改变激活渠道, 实际 %+v", active)
+	}
+
+	// 混合模型流量：sonnet 仍由激活渠道 A 服务
+	if got := m.GetHealthyEndpointsForModel("claude-sonnet-4-5"); len(got) != 2 || got[0].Config.Channel != "A" {
+		t.Fatalf("sonnet 应继续由 A 渠道服务, 实际 %v", got)
+	}
+
+	// 没有任何渠道提供的模型返回空，不再回退到全部端点
+	if got := m.GetHealthyEndpointsForModel("claude-unknown-model"); len(got) != 0 {
+		t.Errorf("无渠道提供该模型时应返回空, 实际 %d 个", len(got))
+	}
+	if active := m.groupManager.GetActiveGroups(); len(active) != 1 || active[0].Name != "A" {
+		t.Errorf("无可切换渠道时不应改变激活渠道, 实际 %+v", active)
+	}
+}
+
+func TestListAvailableModels_Union(t *testing.T) {
+	m := newModelCatalogTestManager(t,
+		config.EndpointConfig{Name: "a1", URL: "http://example.invalid", Channel: "A"},
+		config.EndpointConfig{Name: "b1", URL: "http://example.invalid", Channel: "B"},
+	)
+
+	if models := m.ListAvailableModels(); len(models) != 0 {
+		t.Fatalf("未发现模型时应返回空列表, 实际 %v", models)
+	}
+
+	m.SetEndpointModels(EndpointKey("A", "a1"), []ModelInfo{{ID: "claude-sonnet-4-5"}, {ID: "claude-opus-4-1"}}, time.Now())
+	m.SetEndpointModels(EndpointKey("B", "b1"), []ModelInfo{{ID: "claude-sonnet-4-5"}, {ID: "claude-haiku-4-5"}}, time.Now())
+	m.SetEndpointModelOverride(EndpointKey("A", "a1"), "claude-opus-4-1", false)
+	m.SetEndpointModelOverride(EndpointKey("B", "b1"), "custom-model", true)
+
+	models := m.ListAvailableModels()
+	var ids []string
+	for _, model := range models {
+		ids = append(ids, model.ID)
+	}
+	expected := []string{"claude-haiku-4-5", "claude-sonnet-4-5", "custom-model"}
+	if len(ids) != len(expected) {
+		t.Fatalf("模型并集 = %v, 期望 %v", ids, expected)
+	}
+	for i := range expected {
+		if ids[i] != expected[i] {
+			t.Fatalf("模型并集 = %v, 期望 %v", ids, expected)
+		}
+	}
+}
+
+func TestDiscoverEndpointModels_Pagination(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/models" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Header.Get("x-api-key") != "sk-test" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("after_id") == "" {
+			w.Write([]byte(`{"data":[{"type":"model","id":"claude-sonnet-4-5","display_name":"Claude Sonnet 4.5"}],"has_more":true,"last_id":"claude-sonnet-4-5"}`))
+			return
+		}
+		w.Write([]byte(`{"data":[{"type":"model","id":"claude-opus-4-1"}],"has_more":false,"last_id":"claude-opus-4-1"}`))
+	}))
+	defer server.Close()
+
+	m := newModelCatalogTestManager(t,
+		config.EndpointConfig{Name: "a1", URL: server.URL, Channel: "A", ApiKey: "sk-test"},
+	)
+	m.config.ModelDiscovery.Timeout = 5 * time.Second
+
+	var persisted []ModelInfo
+	m.SetOnModelsDiscovered(func(channel, endpointName string, models []ModelInfo) {
+		persisted = models
+	})
+
+	ep := m.GetAllEndpoints()[0]
+	if err := m.RefreshEndpointModels(context.Background(), ep); err != nil {
+		t.Fatalf("模型发现失败: %v", err)
+	}
+
+	snapshot := m.GetEndpointModels(EndpointKey("A", "a1"))
+	if len(snapshot.Models) != 2 || snapshot.Models[0].DisplayName != "Claude Sonnet 4.5" {
+		t.Errorf("发现的模型 = %+v, 期望分页合并 2 个模型", snapshot.Models)
+	}
+	if len(persisted) != 2 {
+		t.Errorf("模型发现回调应收到 2 个模型, 实际 %d", len(persisted))
+	}
+
+	// 请求失败时保留原有目录并记录错误
+	m.config.ModelDiscovery.Path = "/missing"
+	if err := m.RefreshEndpointModels(context.Background(), ep); err == nil {
+		t.Fatal("404 应返回错误")
+	}
+	snapshot = m.GetEndpointModels(EndpointKey("A", "a1"))
+	if len(snapshot.Models) != 2 || snapshot.LastError == "" {
+		t.Errorf("发现失败后应保留目录并记录错误, 实际 %+v", snapshot)
+	}
+}
+
+func TestRequestedModelContext(t *testing.T) {
+	ctx := WithRequestedModel(context.Background(), "claude-sonnet-4-5")
+	if got := RequestedModelFromContext(ctx); got != "claude-sonnet-4-5" {
+		t.Errorf("RequestedModelFromContext = %q", got)
+	}
+	if got := RequestedModelFromContext(context.Background()); got != "" {
+		t.Errorf("空上下文应返回空模型, 实际 %q", got)
+	}
+}
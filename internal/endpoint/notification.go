@@ -53,6 +53,60 @@ func (m *Manager) notifyWebInterface(endpoint *Endpoint) {
 	})
 }
 
+// notifyEndpointTransition 发布端点健康状态切换事件（健康 ↔ 不可用）
+func (m *Manager) notifyEndpointTransition(endpoint *Endpoint, healthy bool) {
+	if m.eventBus == nil {
+		return
+	}
+
+	endpoint.mutex.RLock()
+	status := endpoint.Status
+	endpoint.mutex.RUnlock()
+
+	eventType := events.EventEndpointUp
+	priority := events.PriorityHigh
+	if !healthy {
+		eventType = events.EventEndpointDown
+		priority = events.PriorityCritical
+	}
+
+	m.eventBus.Publish(events.Event{
+		Type:     eventType,
+		Source:   "endpoint_manager",
+		Priority: priority,
+		Data: map[string]interface{}{
+			"endpoint":          endpoint.Config.Name,
+			"channel":           ChannelKey(endpoint),
+			"url":               endpoint.Config.URL,
+			"healthy":           healthy,
+			"response_time":     utils.FormatResponseTime(status.ResponseTime),
+			"consecutive_fails": status.ConsecutiveFails,
+			"timestamp":         status.LastCheck.Format("2006-01-02 15:04:05"),
+		},
+	})
+}
+
+// notifyFailover 发布渠道故障转移事件（newChannel 为空表示没有可用渠道）
+func (m *Manager) notifyFailover(failedChannel, newChannel, reason string, endpointNames []string) {
+	if m.eventBus == nil {
+		return
+	}
+
+	m.eventBus.Publish(events.Event{
+		Type:     events.EventFailoverTriggered,
+		Source:   "endpoint_manager",
+		Priority: events.PriorityCritical,
+		Data: map[string]interface{}{
+			"failed_channel":   failedChannel,
+			"new_channel":      newChannel,
+			"success":          newChannel != "",
+			"reason":           reason,
+			"failed_endpoints": endpointNames,
+			"timestamp":        time.Now().Format("2006-01-02 15:04:05"),
+		},
+	})
+}
+
 // ManualActivateGroup manually activates a specific group via web interface
 func (m *Manager) ManualActivateGroup(groupName string) error {
 	err := m.groupManager.ManualActivateGroup(groupName)
@@ -15,6 +15,9 @@ type EventBus interface {
 	// 设置 SSE 推送器
 	SetSSEBroadcaster(broadcaster SSEBroadcaster)
 
+	// 订阅事件（处理器在事件处理协程中同步调用，不应阻塞）
+	Subscribe(handler EventHandler)
+
 	// 启动和停止
 	Start() error
 	Stop() error
@@ -29,6 +32,9 @@ type SSEBroadcaster interface {
 	IsEventManagerActive() bool
 }
 
+// EventHandler 事件订阅处理器
+type EventHandler func(event Event)
+
 // 事件过滤器
 type EventFilter struct {
 	// 是否推送给 SSE
@@ -52,6 +58,10 @@ type eventBus struct {
 	eventChan      chan Event
 	sseBroadcaster SSEBroadcaster
 
+	// 订阅者（如 Webhook 通知）
+	handlers   []EventHandler
+	handlersMu sync.RWMutex
+
 	// 过滤和限制
 	filters      map[EventType]EventFilter
 	rateLimiters map[EventType]*rateLimiter
@@ -206,6 +216,31 @@ func (eb *eventBus) setupDefaultFilters() {
 		RateLimit:       0, // 暂时移除频率限制用于调试
 	}
 
+	// 预算事件过滤器 - 每个窗口每个阈值只发布一次，无需限流
+	eb.filters[EventBudgetAlert] = EventFilter{
+		ShouldBroadcast: func(event Event) bool { return true },
+		DataTransformer: func(event Event) map[string]interface{} { return event.Data },
+	}
+	eb.filters[EventBudgetExceeded] = EventFilter{
+		ShouldBroadcast: func(event Event) bool { return true },
+		DataTransformer: func(event Event) map[string]interface{} { return event.Data },
+	}
+
+	// 运维事件过滤器 - 仅在状态切换/故障时发布，无需限流
+	for _, eventType := range []EventType{EventEndpointDown, EventEndpointUp, EventFailoverTriggered, EventRequestSuspendTimeout, EventBalanceLow, EventBalanceRecovered} {
+		eb.filters[eventType] = EventFilter{
+			ShouldBroadcast: func(event Event) bool { return true },
+			DataTransformer: func(event Event) map[string]interface{} { return event.Data },
+		}
+	}
+
+	// 数据库错误过滤器 - 故障期间可能连续发生，限制频率
+	eb.filters[EventDatabaseError] = EventFilter{
+		ShouldBroadcast: func(event Event) bool { return true },
+		DataTransformer: func(event Event) map[string]interface{} { return event.Data },
+		RateLimit:       5 * time.Second,
+	}
+
 	// 初始化频率限制器
 	for eventType, filter := range eb.filters {
 		if filter.RateLimit > 0 {
@@ -244,6 +279,16 @@ func (eb *eventBus) SetSSEBroadcaster(broadcaster SSEBroadcaster) {
 	eb.sseBroadcaster = broadcaster
 }
 
+// Subscribe 订阅所有事件
+func (eb *eventBus) Subscribe(handler EventHandler) {
+	if handler == nil {
+		return
+	}
+	eb.handlersMu.Lock()
+	eb.handlers = append(eb.handlers, handler)
+	eb.handlersMu.Unlock()
+}
+
 // Start 启动EventBus
 func (eb *eventBus) Start() error {
 	if eb.running {
@@ -325,6 +370,14 @@ func (eb *eventBus) eventProcessor() {
 
 // 处理单个事件
 func (eb *eventBus) processEvent(event Event) {
+	// 订阅者不受 SSE 过滤/限流影响
+	eb.handlersMu.RLock()
+	handlers := eb.handlers
+	eb.handlersMu.RUnlock()
+	for _, handler := range handlers {
+		handler(event)
+	}
+
 	// v5.0 优化：Wails 桌面应用不使用 SSE 广播，提前返回避免无用的过滤/限流操作
 	if eb.sseBroadcaster == nil {
 		return
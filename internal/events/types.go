@@ -14,6 +14,8 @@ const (
 	// 端点健康事件
 	EventEndpointHealthy   EventType = "endpoint_healthy"
 	EventEndpointUnhealthy EventType = "endpoint_unhealthy"
+	EventEndpointDown      EventType = "endpoint_down" // 健康 → 不可用（仅状态切换时发布）
+	EventEndpointUp        EventType = "endpoint_up"   // 不可用 → 恢复（仅状态切换时发布）
 
 	// 连接统计事件
 	EventConnectionStats        EventType = "connection_stats"
@@ -23,11 +25,24 @@ const (
 	// 组管理事件
 	EventGroupStatusChanged      EventType = "group_status_changed"
 	EventGroupHealthStatsChanged EventType = "group_health_stats_changed"
+	EventFailoverTriggered       EventType = "failover_triggered" // 渠道故障转移（含失败：无可用渠道）
+
+	// 挂起请求事件
+	EventRequestSuspendTimeout EventType = "request_suspend_timeout" // 挂起等待端点恢复/组切换超时
 
 	// 系统级事件
 	EventSystemError        EventType = "system_error"
 	EventSystemStatsUpdated EventType = "system_stats_updated"
 	EventConfigChanged      EventType = "config_changed"
+	EventDatabaseError      EventType = "database_error" // 使用记录写入数据库失败
+
+	// 预算事件
+	EventBudgetAlert    EventType = "budget_alert"    // 花费达到告警阈值
+	EventBudgetExceeded EventType = "budget_exceeded" // 超出预算，硬限制生效
+
+	// 渠道余额事件
+	EventBalanceLow       EventType = "balance_low"       // 中转渠道余额低于阈值
+	EventBalanceRecovered EventType = "balance_recovered" // 中转渠道余额恢复到阈值以上
 )
 
 // 事件优先级
@@ -57,12 +72,21 @@ var EventTypeMapping = map[EventType]string{
 	EventRequestCompleted:        "request",
 	EventEndpointHealthy:         "endpoint",
 	EventEndpointUnhealthy:       "endpoint",
+	EventEndpointDown:            "endpoint",
+	EventEndpointUp:              "endpoint",
 	EventConnectionStats:         "connection",
 	EventConnectionStatsUpdated:  "connection",
 	EventResponseReceived:        "connection",
 	EventGroupStatusChanged:      "group",
 	EventGroupHealthStatsChanged: "group",
+	EventFailoverTriggered:       "group",
+	EventRequestSuspendTimeout:   "request",
 	EventSystemError:             "status",
 	EventSystemStatsUpdated:      "status",
 	EventConfigChanged:           "config",
+	EventDatabaseError:           "status",
+	EventBudgetAlert:             "budget",
+	EventBudgetExceeded:          "budget",
+	EventBalanceLow:              "balance",
+	EventBalanceRecovered:        "balance",
 }
\ No newline at end of file
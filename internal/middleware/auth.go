@@ -1,42 +1,102 @@
 package middleware
 
 import (
-	"cc-forwarder/config"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net"
 	"net/http"
 	"strings"
+	"sync"
+
+	"cc-forwarder/config"
+)
+
+// 入站鉴权方式（与 config.AuthConfig.Schemes 的取值一致）
+const (
+	AuthSchemeBearer = "bearer"    // Authorization: Bearer <token>
+	AuthSchemeAPIKey = "x-api-key" // x-api-key: <token>（Claude Code 配置 ANTHROPIC_API_KEY 时使用）
+	AuthSchemeQuery  = "query"     // ?token=<token>
+)
+
+// 非本机监听且未启用鉴权时的处理策略
+const (
+	NonLoopbackPolicyRefuse   = "refuse"   // 拒绝启动
+	NonLoopbackPolicyGenerate = "generate" // 自动生成随机 Token 并启用鉴权
 )
 
 type AuthMiddleware struct {
+	mu     sync.RWMutex
 	config config.AuthConfig
+	allow  []*net.IPNet
+	deny   []*net.IPNet
+
+	// 配置了允许列表（即使全部条目无效也按白名单处理，避免误配置导致放行所有地址）
+	allowConfigured bool
 }
 
 func NewAuthMiddleware(cfg config.AuthConfig) *AuthMiddleware {
-	return &AuthMiddleware{
-		config: cfg,
-	}
+	am := &AuthMiddleware{}
+	am.UpdateConfig(cfg)
+	return am
 }
 
+// Wrap 校验请求 Token（按配置接受 Bearer / x-api-key / query 三种方式）
 func (am *AuthMiddleware) Wrap(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if !am.config.Enabled {
+		am.mu.RLock()
+		cfg := am.config
+		am.mu.RUnlock()
+
+		if !cfg.Enabled {
 			next.ServeHTTP(w, r)
 			return
 		}
 
-		auth := r.Header.Get("Authorization")
-		if auth == "" {
-			http.Error(w, "Authorization header required", http.StatusUnauthorized)
+		schemes := acceptedSchemes(cfg)
+		token, scheme := extractToken(r, cfg, schemes)
+		if token == "" {
+			http.Error(w, fmt.Sprintf("Authentication required. Accepted schemes: %s", strings.Join(schemes, ", ")), http.StatusUnauthorized)
 			return
 		}
 
-		if !strings.HasPrefix(auth, "Bearer ") {
-			http.Error(w, "Invalid authorization format. Expected 'Bearer <token>'", http.StatusUnauthorized)
+		// 未配置 Token 时拒绝所有请求（fail closed）
+		if cfg.Token == "" || !tokenEqual(token, cfg.Token) {
+			http.Error(w, "Invalid token", http.StatusUnauthorized)
 			return
 		}
 
-		token := strings.TrimPrefix(auth, "Bearer ")
-		if token != am.config.Token {
-			http.Error(w, "Invalid token", http.StatusUnauthorized)
+		// query 方式通过后移除 Token 参数，避免转发到上游
+		if scheme == AuthSchemeQuery {
+			query := r.URL.Query()
+			query.Del(queryParamName(cfg))
+			r.URL.RawQuery = query.Encode()
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// FilterIP 按 CIDR 允许/拒绝列表过滤客户端地址（独立于 Token 鉴权，作用于所有路由）
+// 客户端地址取自 TCP 连接的 RemoteAddr，不信任 X-Forwarded-For 等可伪造的请求头
+func (am *AuthMiddleware) FilterIP(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		am.mu.RLock()
+		allow, deny, allowConfigured := am.allow, am.deny, am.allowConfigured
+		am.mu.RUnlock()
+
+		if !allowConfigured && len(deny) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ip := remoteIP(r)
+		if !ipAllowed(ip, allow, deny, allowConfigured) {
+			slog.Warn(fmt.Sprintf("🚫 [访问控制] 拒绝来自 %s 的请求: %s %s", r.RemoteAddr, r.Method, r.URL.Path))
+			http.Error(w, "Forbidden", http.StatusForbidden)
 			return
 		}
 
@@ -46,5 +106,171 @@ func (am *AuthMiddleware) Wrap(next http.Handler) http.Handler {
 
 // UpdateConfig updates the auth middleware configuration
 func (am *AuthMiddleware) UpdateConfig(cfg config.AuthConfig) {
+	allow := parseCIDRs(cfg.AllowCIDRs)
+	deny := parseCIDRs(cfg.DenyCIDRs)
+
+	am.mu.Lock()
+	defer am.mu.Unlock()
 	am.config = cfg
-}
\ No newline at end of file
+	am.allow = allow
+	am.deny = deny
+	am.allowConfigured = len(cfg.AllowCIDRs) > 0
+}
+
+// acceptedSchemes 返回生效的鉴权方式（未配置时默认 Bearer + x-api-key）
+func acceptedSchemes(cfg config.AuthConfig) []string {
+	if len(cfg.Schemes) == 0 {
+		return []string{AuthSchemeBearer, AuthSchemeAPIKey}
+	}
+	schemes := make([]string, 0, len(cfg.Schemes))
+	for _, scheme := range cfg.Schemes {
+		schemes = append(schemes, strings.ToLower(strings.TrimSpace(scheme)))
+	}
+	return schemes
+}
+
+func queryParamName(cfg config.AuthConfig) string {
+	if cfg.QueryParam == "" {
+		return "token"
+	}
+	return cfg.QueryParam
+}
+
+// extractToken 按配置顺序提取请求携带的 Token，返回 Token 及其鉴权方式
+func extractToken(r *http.Request, cfg config.AuthConfig, schemes []string) (string, string) {
+	for _, scheme := range schemes {
+		switch scheme {
+		case AuthSchemeBearer:
+			auth := r.Header.Get("Authorization")
+			if len(auth) > len("Bearer ") && strings.EqualFold(auth[:len("Bearer ")], "Bearer ") {
+				return strings.TrimSpace(auth[len("Bearer "):]), scheme
+			}
+		case AuthSchemeAPIKey:
+			if key := strings.TrimSpace(r.Header.Get("x-api-key")); key != "" {
+				return key, scheme
+			}
+		case AuthSchemeQuery:
+			if token := r.URL.Query().Get(queryParamName(cfg)); token != "" {
+				return token, scheme
+			}
+		}
+	}
+	return "", ""
+}
+
+// tokenEqual 常量时间比较 Token（先做哈希，避免长度差异泄露信息）
+func tokenEqual(provided, expected string) bool {
+	a := sha256.Sum256([]byte(provided))
+	b := sha256.Sum256([]byte(expected))
+	return subtle.ConstantTimeCompare(a[:], b[:]) == 1
+}
+
+// parseCIDRs 解析 CIDR 列表，单个 IP 视为 /32（IPv6 为 /128）
+// 无法解析的条目记录警告后跳过（桌面设置未经 config.validate() 校验）
+func parseCIDRs(values []string) []*net.IPNet {
+	var nets []*net.IPNet
+	for _, value := range values {
+		ipNet, err := ParseCIDROrIP(value)
+		if err != nil {
+			slog.Warn(fmt.Sprintf("⚠️ [访问控制] 忽略无效的地址规则: %v", err))
+			continue
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets
+}
+
+// ParseCIDROrIP 解析 CIDR 或单个 IP 地址
+func ParseCIDROrIP(value string) (*net.IPNet, error) {
+	value = strings.TrimSpace(value)
+	if strings.Contains(value, "/") {
+		_, ipNet, err := net.ParseCIDR(value)
+		return ipNet, err
+	}
+	ip := net.ParseIP(value)
+	if ip == nil {
+		return nil, fmt.Errorf("无效的 IP 或 CIDR: %q", value)
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return &net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)}, nil
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}, nil
+}
+
+func remoteIP(r *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return net.ParseIP(host)
+}
+
+// ipAllowed 拒绝列表优先；配置了允许列表时仅放行列表内地址
+func ipAllowed(ip net.IP, allow, deny []*net.IPNet, allowConfigured bool) bool {
+	if ip == nil {
+		return false
+	}
+	for _, ipNet := range deny {
+		if ipNet.Contains(ip) {
+			return false
+		}
+	}
+	if !allowConfigured {
+		return true
+	}
+	for _, ipNet := range allow {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsLoopbackHost 判断监听地址是否仅限本机访问
+func IsLoopbackHost(host string) bool {
+	host = strings.Trim(strings.TrimSpace(host), "[]")
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// EnforceListenerAuth 监听非本机地址时确保入站鉴权已启用
+// refuse 策略返回错误（调用方应拒绝启动）；generate 策略自动启用鉴权并生成随机 Token
+// previousToken 为此前自动生成的 Token，配置重载时复用以免客户端失效
+// 返回本次生效的自动生成 Token（未自动生成时为空）
+func EnforceListenerAuth(host string, auth *config.AuthConfig, previousToken string) (string, error) {
+	if IsLoopbackHost(host) {
+		return "", nil
+	}
+	if auth.Enabled && auth.Token != "" {
+		return "", nil
+	}
+
+	switch auth.NonLoopbackPolicy {
+	case NonLoopbackPolicyRefuse:
+		return "", fmt.Errorf("服务器监听非本机地址 %s 但未启用鉴权（或未设置 Token），拒绝启动；请设置 auth.enabled 与 auth.token，或将 auth.non_loopback_policy 设为 generate", host)
+	default:
+		token := previousToken
+		if token == "" {
+			generated, err := GenerateAuthToken()
+			if err != nil {
+				return "", err
+			}
+			token = generated
+		}
+		auth.Enabled = true
+		auth.Token = token
+		return token, nil
+	}
+}
+
+// GenerateAuthToken 生成随机访问 Token
+func GenerateAuthToken() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("生成随机 Token 失败: %w", err)
+	}
+	return "ccf-" + hex.EncodeToString(buf), nil
+}
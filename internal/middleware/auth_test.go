@@ -0,0 +1,143 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"cc-forwarder/config"
+)
+
+func newAuthTestHandler(cfg config.AuthConfig) (http.Handler, *string) {
+	var forwardedQuery string
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwardedQuery = r.URL.RawQuery
+		w.WriteHeader(http.StatusOK)
+	})
+	am := NewAuthMiddleware(cfg)
+	return am.FilterIP(am.Wrap(next)), &forwardedQuery
+}
+
+func TestAuthMiddleware_Schemes(t *testing.T) {
+	testCases := []struct {
+		name     string
+		schemes  []string
+		setup    func(r *http.Request)
+		expected int
+	}{
+		{"Bearer 正确", nil, func(r *http.Request) { r.Header.Set("Authorization", "Bearer secret") }, http.StatusOK},
+		{"Bearer 大小写不敏感", nil, func(r *http.Request) { r.Header.Set("Authorization", "bearer secret") }, http.StatusOK},
+		{"Bearer 错误", nil, func(r *http.Request) { r.Header.Set("Authorization", "Bearer wrong") }, http.StatusUnauthorized},
+		{"x-api-key 正确", nil, func(r *http.Request) { r.Header.Set("x-api-key", "secret") }, http.StatusOK},
+		{"x-api-key 错误", nil, func(r *http.Request) { r.Header.Set("x-api-key", "secret2") }, http.StatusUnauthorized},
+		{"未携带 Token", nil, func(r *http.Request) {}, http.StatusUnauthorized},
+		{"默认不接受 query", nil, func(r *http.Request) { r.URL.RawQuery = "token=secret" }, http.StatusUnauthorized},
+		{"仅 Bearer 时拒绝 x-api-key", []string{"bearer"}, func(r *http.Request) { r.Header.Set("x-api-key", "secret") }, http.StatusUnauthorized},
+		{"query 正确", []string{"query"}, func(r *http.Request) { r.URL.RawQuery = "token=secret&beta=true" }, http.StatusOK},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			handler, _ := newAuthTestHandler(config.AuthConfig{Enabled: true, Token: "secret", Schemes: tc.schemes})
+			req := httptest.NewRequest(http.MethodPost, "/v1/messages", nil)
+			tc.setup(req)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tc.expected {
+				t.Errorf("状态码 = %d, 期望 %d", rec.Code, tc.expected)
+			}
+		})
+	}
+}
+
+func TestAuthMiddleware_QueryTokenStripped(t *testing.T) {
+	handler, forwardedQuery := newAuthTestHandler(config.AuthConfig{Enabled: true, Token: "secret", Schemes: []string{"query"}, QueryParam: "key"})
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages?key=secret&beta=true", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("状态码 = %d, 期望 200", rec.Code)
+	}
+	if strings.Contains(*forwardedQuery, "secret") || *forwardedQuery != "beta=true" {
+		t.Errorf("转发的查询参数应移除 Token, 实际 %q", *forwardedQuery)
+	}
+}
+
+func TestAuthMiddleware_EmptyTokenFailsClosed(t *testing.T) {
+	handler, _ := newAuthTestHandler(config.AuthConfig{Enabled: true})
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages", nil)
+	req.Header.Set("Authorization", "Bearer anything")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("未配置 Token 时应拒绝请求, 实际 %d", rec.Code)
+	}
+}
+
+func TestAuthMiddleware_FilterIP(t *testing.T) {
+	testCases := []struct {
+		name       string
+		allow      []string
+		deny       []string
+		remoteAddr string
+		expected   int
+	}{
+		{"未配置规则", nil, nil, "203.0.113.5:1234", http.StatusOK},
+		{"允许列表命中", []string{"192.168.1.0/24"}, nil, "192.168.1.20:1234", http.StatusOK},
+		{"允许列表未命中", []string{"192.168.1.0/24"}, nil, "192.168.2.20:1234", http.StatusForbidden},
+		{"拒绝列表优先", []string{"192.168.1.0/24"}, []string{"192.168.1.20"}, "192.168.1.20:1234", http.StatusForbidden},
+		{"仅拒绝列表", nil, []string{"10.0.0.0/8"}, "127.0.0.1:1234", http.StatusOK},
+		{"IPv6 回环", []string{"::1"}, nil, "[::1]:1234", http.StatusOK},
+		{"允许列表全部无效时拒绝", []string{"not-an-ip"}, nil, "127.0.0.1:1234", http.StatusForbidden},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			handler, _ := newAuthTestHandler(config.AuthConfig{AllowCIDRs: tc.allow, DenyCIDRs: tc.deny})
+			req := httptest.NewRequest(http.MethodGet, "/health", nil)
+			req.RemoteAddr = tc.remoteAddr
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tc.expected {
+				t.Errorf("状态码 = %d, 期望 %d", rec.Code, tc.expected)
+			}
+		})
+	}
+}
+
+func TestEnforceListenerAuth(t *testing.T) {
+	// 本机地址不做要求
+	for _, host := range []string{"localhost", "127.0.0.1", "::1", "[::1]"} {
+		auth := config.AuthConfig{NonLoopbackPolicy: NonLoopbackPolicyRefuse}
+		if _, err := EnforceListenerAuth(host, &auth, ""); err != nil {
+			t.Errorf("本机地址 %s 不应报错: %v", host, err)
+		}
+	}
+
+	// 已启用鉴权
+	auth := config.AuthConfig{Enabled: true, Token: "secret", NonLoopbackPolicy: NonLoopbackPolicyRefuse}
+	if token, err := EnforceListenerAuth("0.0.0.0", &auth, ""); err != nil || token != "" {
+		t.Errorf("已启用鉴权时不应生成 Token: %q %v", token, err)
+	}
+
+	// refuse 策略
+	auth = config.AuthConfig{NonLoopbackPolicy: NonLoopbackPolicyRefuse}
+	if _, err := EnforceListenerAuth("0.0.0.0", &auth, ""); err == nil {
+		t.Error("refuse 策略下未启用鉴权应返回错误")
+	}
+
+	// generate 策略
+	auth = config.AuthConfig{NonLoopbackPolicy: NonLoopbackPolicyGenerate}
+	token, err := EnforceListenerAuth("0.0.0.0", &auth, "")
+	if err != nil || token == "" || !auth.Enabled || auth.Token != token {
+		t.Fatalf("generate 策略应自动启用鉴权: token=%q auth=%+v err=%v", token, auth, err)
+	}
+
+	// 重载时复用已生成的 Token
+	reloaded := config.AuthConfig{NonLoopbackPolicy: NonLoopbackPolicyGenerate}
+	if again, _ := EnforceListenerAuth("192.168.1.10", &reloaded, token); again != token {
+		t.Errorf("重载时应复用已生成的 Token, 实际 %q", again)
+	}
+}
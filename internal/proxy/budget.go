@@ -0,0 +1,102 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"cc-forwarder/internal/proxy/handlers"
+	"cc-forwarder/internal/tracking"
+)
+
+// enforceBudget 路由前检查预算硬限制：当前渠道仍被阻断时返回 Anthropic 格式错误
+// divert 动作的渠道切换在预算超限时由评估协程执行一次（见 App.handleBudgetEvent），分流失败时请求在此被拒绝
+// 返回 true 表示请求已被拒绝
+func (h *Handler) enforceBudget(w http.ResponseWriter, model string, lifecycleManager *RequestLifecycleManager) bool {
+	if h.usageTracker == nil || h.endpointManager == nil {
+		return false
+	}
+
+	channel := h.activeChannel()
+	status := h.blockingBudget(channel, model)
+	if status == nil {
+		return false
+	}
+
+	httpStatus, errorType := budgetErrorStatus(status)
+	message := budgetErrorMessage(status)
+	if lifecycleManager != nil {
+		lifecycleManager.FailRequest("budget_exceeded", message, httpStatus)
+	}
+	if httpStatus == http.StatusTooManyRequests {
+		if wait := time.Until(status.WindowEnd); wait > 0 {
+			w.Header().Set("Retry-After", fmt.Sprintf("%d", int(wait.Seconds())+1))
+		}
+	}
+	writeAnthropicError(w, httpStatus, errorType, message)
+	return true
+}
+
+// blockingBudget 返回阻断当前渠道请求的预算：全局/模型/渠道预算，或渠道内所有端点均被端点预算阻断
+func (h *Handler) blockingBudget(channel, model string) *tracking.BudgetStatus {
+	if status := h.usageTracker.CheckBudget(channel, "", model); status != nil {
+		return status
+	}
+
+	var first *tracking.BudgetStatus
+	endpoints := h.endpointManager.GetGroupManager().FilterEndpointsByActiveGroups(h.endpointManager.GetAllEndpoints())
+	for _, ep := range endpoints {
+		status := h.usageTracker.CheckBudget(ep.Config.Channel, ep.Config.Name, model)
+		if status == nil {
+			return nil
+		}
+		if first == nil {
+			first = status
+		}
+	}
+	return first
+}
+
+// activeChannel 返回当前激活渠道
+func (h *Handler) activeChannel() string {
+	gm := h.endpointManager.GetGroupManager()
+	if gm == nil {
+		return ""
+	}
+	if groups := gm.GetActiveGroups(); len(groups) > 0 {
+		return groups[0].Name
+	}
+	return ""
+}
+
+// budgetErrorStatus 超出预算的响应状态码与错误类型
+// reject 在窗口重置后自动恢复，按限流处理；manual 需要手动放行，按权限错误处理（客户端不会自动重试）
+func budgetErrorStatus(status *tracking.BudgetStatus) (int, string) {
+	if status.Action == tracking.BudgetActionManual {
+		return http.StatusForbidden, handlers.UpstreamErrorPermission
+	}
+	return http.StatusTooManyRequests, handlers.UpstreamErrorRateLimit
+}
+
+// budgetErrorMessage 超出预算的错误信息
+func budgetErrorMessage(status *tracking.BudgetStatus) string {
+	message := fmt.Sprintf("Budget %q exceeded: $%.4f of $%.2f (%s)", status.Name, status.SpentUSD, status.LimitUSD, status.Window)
+	if status.Action == tracking.BudgetActionManual {
+		return message + "; manual override required"
+	}
+	return message + "; resets at " + status.WindowEnd.Format(time.RFC3339)
+}
+
+// writeAnthropicError 返回 Anthropic API 标准格式的错误响应
+func writeAnthropicError(w http.ResponseWriter, httpStatus int, errorType, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(httpStatus)
+	json.NewEncoder(w).Encode(map[string]any{
+		"type": "error",
+		"error": map[string]string{
+			"type":    errorType,
+			"message": message,
+		},
+	})
+}
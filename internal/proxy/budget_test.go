@@ -0,0 +1,87 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"cc-forwarder/config"
+	"cc-forwarder/internal/endpoint"
+	"cc-forwarder/internal/store"
+	"cc-forwarder/internal/tracking"
+)
+
+// TestServeHTTP_BatchCreateEnforcesBudget 测试创建批处理同样受预算硬限制约束
+func TestServeHTTP_BatchCreateEnforcesBudget(t *testing.T) {
+	var hits int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"msgbatch_01","type":"message_batch","processing_status":"in_progress"}`))
+	}))
+	defer server.Close()
+
+	tracker, err := tracking.NewUsageTracker(&tracking.Config{
+		Enabled:         true,
+		DatabasePath:    filepath.Join(t.TempDir(), "usage.db"),
+		BufferSize:      100,
+		BatchSize:       10,
+		FlushInterval:   50 * time.Millisecond,
+		MaxRetry:        3,
+		CleanupInterval: 24 * time.Hour,
+		RetentionDays:   30,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create usage tracker: %v", err)
+	}
+	defer tracker.Close()
+
+	location, _ := time.LoadLocation("Asia/Shanghai")
+	if _, err := tracker.GetDB().Exec(`INSERT INTO request_logs (
+		request_id, start_time, channel, endpoint_name, model_name, status, total_cost_usd
+	) VALUES ('req-opus', ?, 'A', 'a', 'claude-opus-4', 'completed', 2)`,
+		time.Now().In(location).Format("2006-01-02 15:04:05")); err != nil {
+		t.Fatalf("插入测试数据失败: %v", err)
+	}
+	tracker.UpdateBudgets([]tracking.Budget{
+		{Name: "opus", Scope: tracking.BudgetScopeModel, Model: "claude-opus-4", Window: tracking.BudgetWindowMonthly, LimitUSD: 1, Action: tracking.BudgetActionReject},
+	})
+
+	cfg := &config.Config{
+		Endpoints: []config.EndpointConfig{{Name: "a", URL: server.URL, Channel: "A", Priority: 1, Timeout: 5 * time.Second}},
+	}
+	endpointManager := endpoint.NewManager(cfg)
+	for _, ep := range endpointManager.GetAllEndpoints() {
+		ep.Status.Healthy = true
+		ep.Status.NeverChecked = false
+		ep.Status.LastCheck = time.Now()
+	}
+	if err := endpointManager.ManualActivateGroup("A"); err != nil {
+		t.Fatalf("ManualActivateGroup(A) error: %v", err)
+	}
+
+	handler := NewHandler(endpointManager, cfg)
+	handler.SetUsageTracker(tracker)
+	handler.SetMessageBatchStore(store.NewSQLiteMessageBatchStore(tracker.GetDB()))
+
+	create := func(model string) *httptest.ResponseRecorder {
+		body := `{"requests":[{"custom_id":"a","params":{"model":"claude-sonnet-4","max_tokens":10}},{"custom_id":"b","params":{"model":"` + model + `","max_tokens":10}}]}`
+		req := httptest.NewRequest(http.MethodPost, "/v1/messages/batches", strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// 批处理中任一模型超出预算：拒绝且不转发
+	if rec := create("claude-opus-4"); rec.Code != http.StatusTooManyRequests || hits != 0 {
+		t.Fatalf("超出预算时应拒绝创建批处理: status=%d hits=%d", rec.Code, hits)
+	}
+
+	// 未超出预算的模型正常转发
+	if rec := create("claude-haiku-4-5"); rec.Code != http.StatusOK || hits != 1 {
+		t.Errorf("未超出预算时应转发: status=%d hits=%d", rec.Code, hits)
+	}
+}
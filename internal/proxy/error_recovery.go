@@ -12,6 +12,7 @@ import (
 	"syscall"
 	"time"
 
+	"cc-forwarder/internal/proxy/handlers"
 	"cc-forwarder/internal/tracking"
 )
 
@@ -33,6 +34,8 @@ const (
 	ErrorTypeParsing                        // 解析错误
 	ErrorTypeClientCancel                   // 客户端取消错误
 	ErrorTypeNoHealthyEndpoints             // 没有健康端点可用
+	ErrorTypeOverloaded                     // 上游过载（overloaded_error / 529）
+	ErrorTypeFakeSuccess                    // 假成功（2xx 但响应内容被校验器判定为失败）
 )
 
 // ErrorContext 错误上下文信息
@@ -45,6 +48,9 @@ type ErrorContext struct {
 	OriginalError  error
 	RetryableAfter time.Duration // 建议重试延迟
 	MaxRetries     int
+	// 上游结构化错误信息（error.type 与 HTTP 状态码），为空表示非上游业务错误
+	UpstreamErrorType  string
+	UpstreamStatusCode int
 }
 
 // ErrorRecoveryManager 错误恢复管理器
@@ -84,6 +90,20 @@ func (erm *ErrorRecoveryManager) ClassifyError(err error, requestID, endpoint, g
 		return errorCtx
 	}
 
+	// 上游结构化错误（HTTP 错误响应体 / SSE error 事件）优先按 error.type 查决策表分类
+	if erm.classifyUpstreamError(err, errorCtx) {
+		return errorCtx
+	}
+
+	// 中转站假成功：2xx 响应被校验器判定为失败，切换端点
+	if fakeErr, ok := handlers.AsFakeSuccessError(err); ok {
+		errorCtx.ErrorType = ErrorTypeFakeSuccess
+		errorCtx.RetryableAfter = 0
+		slog.Warn(fmt.Sprintf("🎭 [假成功分类] [%s] 端点: %s, 尝试: %d, 校验器: %s, 原因: %s",
+			requestID, endpoint, attempt, fakeErr.Validator, fakeErr.Reason))
+		return errorCtx
+	}
+
 	errStr := strings.ToLower(err.Error())
 
 	// 首先检查客户端取消错误（最高优先级）
@@ -228,6 +248,35 @@ func (erm *ErrorRecoveryManager) ClassifyError(err error, requestID, endpoint, g
 	return errorCtx
 }
 
+// classifyUpstreamError 按上游 error.type 分类错误，返回是否命中决策表
+func (erm *ErrorRecoveryManager) classifyUpstreamError(err error, errorCtx *ErrorContext) bool {
+	upErr, ok := handlers.AsUpstreamError(err)
+	if !ok || upErr.Type == "" {
+		return false
+	}
+	policy, ok := handlers.LookupUpstreamErrorPolicy(upErr.Type)
+	if !ok {
+		return false
+	}
+
+	errorCtx.ErrorType = ErrorType(policy.ErrorType)
+	errorCtx.UpstreamErrorType = upErr.Type
+	errorCtx.UpstreamStatusCode = upErr.StatusCode
+	switch {
+	case policy.ErrorType == handlers.ErrorTypeRateLimit:
+		errorCtx.RetryableAfter = time.Minute
+	case policy.RetrySameEndpoint:
+		errorCtx.RetryableAfter = erm.calculateBackoffDelay(errorCtx.AttemptCount)
+	default:
+		errorCtx.RetryableAfter = 0
+	}
+
+	slog.Warn(fmt.Sprintf("🧾 [上游错误分类] [%s] 端点: %s, 尝试: %d, 状态码: %d, 错误类型: %s, 分类: %s, 错误: %v",
+		errorCtx.RequestID, errorCtx.EndpointName, errorCtx.AttemptCount, upErr.StatusCode, upErr.Type,
+		errorCtx.ErrorType.String(), err))
+	return true
+}
+
 // ShouldRetry 判断是否应该重试
 func (erm *ErrorRecoveryManager) ShouldRetry(errorCtx *ErrorContext) bool {
 	// 超过最大重试次数
@@ -288,6 +337,16 @@ func (erm *ErrorRecoveryManager) ShouldRetry(errorCtx *ErrorContext) bool {
 			errorCtx.RequestID, errorCtx.AttemptCount, errorCtx.MaxRetries, errorCtx.RetryableAfter))
 		return true
 
+	case ErrorTypeOverloaded:
+		// 上游过载不在同一端点重试，直接切换端点（在 RetryManager 中处理）
+		slog.Info(fmt.Sprintf("🔥 [重试判断] [%s] 上游过载不在同一端点重试，切换端点", errorCtx.RequestID))
+		return false
+
+	case ErrorTypeFakeSuccess:
+		// 假成功说明中转站本身有问题，同端点重试意义不大，直接切换端点
+		slog.Info(fmt.Sprintf("🎭 [重试判断] [%s] 假成功响应不在同一端点重试，切换端点", errorCtx.RequestID))
+		return false
+
 	case ErrorTypeAuth:
 		// 2025-12-10: 认证/权限错误不在同一端点重试，但支持切换端点（在 RetryManager 中处理）
 		slog.Info(fmt.Sprintf("🔐 [重试判断] [%s] 认证/权限错误不在同一端点重试，但可切换端点", errorCtx.RequestID))
@@ -362,6 +421,10 @@ func (erm *ErrorRecoveryManager) HandleFinalFailure(errorCtx *ErrorContext) {
 			status = "server_error"
 		case ErrorTypeStream:
 			status = "stream_error"
+		case ErrorTypeOverloaded:
+			status = "overloaded"
+		case ErrorTypeFakeSuccess:
+			status = "fake_success"
 		}
 
 		opts := tracking.UpdateOptions{
@@ -635,6 +698,10 @@ func (et ErrorType) String() string {
 		return "客户端取消"
 	case ErrorTypeNoHealthyEndpoints:
 		return "无健康端点"
+	case ErrorTypeOverloaded:
+		return "过载"
+	case ErrorTypeFakeSuccess:
+		return "假成功"
 	default:
 		return "未知"
 	}
@@ -15,6 +15,7 @@ import (
 	"cc-forwarder/internal/monitor"
 	"cc-forwarder/internal/proxy/handlers"
 	"cc-forwarder/internal/proxy/response"
+	"cc-forwarder/internal/store"
 	"cc-forwarder/internal/tracking"
 )
 
@@ -41,6 +42,8 @@ type Handler struct {
 	sharedSuspensionManager handlers.SuspensionManager
 	// 🚀 [端点自愈] 端点恢复信号管理器
 	recoverySignalManager *EndpointRecoverySignalManager
+	// 📦 [批处理] Message Batches 记录（固定后续请求端点、防止重复计费）
+	batchStore store.MessageBatchStore
 }
 
 // TokenParserProviderImpl 实现TokenParserProvider接口
@@ -80,6 +83,18 @@ func (spa *StreamProcessorAdapter) ProcessStreamWithRetry(ctx context.Context, r
 	return spa.innerProcessor.ProcessStreamWithRetry(ctx, resp)
 }
 
+func (spa *StreamProcessorAdapter) StreamTimings() (time.Time, time.Time) {
+	return spa.innerProcessor.StreamTimings()
+}
+
+func (spa *StreamProcessorAdapter) BytesReceived() int64 {
+	return spa.innerProcessor.BytesReceived()
+}
+
+func (spa *StreamProcessorAdapter) ResponseMeta() tracking.ResponseMeta {
+	return spa.innerProcessor.ResponseMeta()
+}
+
 // ErrorRecoveryManagerAdapter 适配*ErrorRecoveryManager到handlers.ErrorRecoveryManager
 type ErrorRecoveryManagerAdapter struct {
 	innerManager *ErrorRecoveryManager
@@ -96,6 +111,9 @@ func (era *ErrorRecoveryManagerAdapter) ClassifyError(err error, connID, endpoin
 		OriginalError:  ctx.OriginalError,
 		RetryableAfter: ctx.RetryableAfter,
 		MaxRetries:     ctx.MaxRetries,
+
+		UpstreamErrorType:  ctx.UpstreamErrorType,
+		UpstreamStatusCode: ctx.UpstreamStatusCode,
 	}
 }
 
@@ -109,6 +127,9 @@ func (era *ErrorRecoveryManagerAdapter) HandleFinalFailure(errorCtx handlers.Err
 		OriginalError:  errorCtx.OriginalError,
 		RetryableAfter: errorCtx.RetryableAfter,
 		MaxRetries:     errorCtx.MaxRetries,
+
+		UpstreamErrorType:  errorCtx.UpstreamErrorType,
+		UpstreamStatusCode: errorCtx.UpstreamStatusCode,
 	}
 	era.innerManager.HandleFinalFailure(&innerCtx)
 }
@@ -133,6 +154,10 @@ func (taa *TokenAnalyzerAdapter) AnalyzeResponseForTokensUnified(responseBytes [
 	return tokenUsage, modelName
 }
 
+func (taa *TokenAnalyzerAdapter) AnalyzeResponseMeta(responseBytes []byte) tracking.ResponseMeta {
+	return taa.innerAnalyzer.AnalyzeResponseMeta(responseBytes)
+}
+
 // RequestLifecycleManagerAdapter 适配handlers.RequestLifecycleManager到response.RequestLifecycleManager
 type RequestLifecycleManagerAdapter struct {
 	innerManager handlers.RequestLifecycleManager
@@ -412,35 +437,22 @@ func (h *Handler) GetRetryHandler() *RetryHandler {
 // SetEventBus 设置EventBus事件总线
 func (h *Handler) SetEventBus(eventBus events.EventBus) {
 	h.eventBus = eventBus
+	if sm, ok := h.sharedSuspensionManager.(*SuspensionManager); ok {
+		sm.SetEventBus(eventBus)
+	}
 }
 
-// extractModelFromRequestBody 从请求体中提取模型名称
-// 仅对 /v1/messages 相关路径进行解析，避免不必要的JSON解析开销
-func (h *Handler) extractModelFromRequestBody(bodyBytes []byte, path string) string {
-	// 仅对包含 messages 的路径尝试解析模型
-	if !strings.Contains(path, "/v1/messages") {
-		return ""
-	}
-	
-	// 避免解析空请求体
-	if len(bodyBytes) == 0 {
-		return ""
-	}
-	
-	var requestBody struct {
-		Model string `json:"model"`
-	}
-	
-	if err := json.Unmarshal(bodyBytes, &requestBody); err == nil && requestBody.Model != "" {
-		return requestBody.Model
-	}
-	
-	return ""
+// SetMessageBatchStore 设置 Message Batches 存储，启用批处理端点固定与结果用量计费
+func (h *Handler) SetMessageBatchStore(batchStore store.MessageBatchStore) {
+	h.batchStore = batchStore
 }
 
 // ServeHTTP implements the http.Handler interface
 // 统一请求分发逻辑 - 整合流式处理、错误恢复和生命周期管理
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// 🏷️ [请求标签] 剥离 /p/<project>/ 路径前缀并读取标签请求头（均不转发到上游）
+	requestTags := extractRequestTags(r, h.config.UsageTracking.TagHeaders)
+
 	// 🔢 [count_tokens拦截] 特殊处理count_tokens端点
 	if r.URL.Path == "/v1/messages/count_tokens" && h.config.TokenCounting.Enabled {
 		ctx := r.Context()
@@ -464,6 +476,41 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// 📦 [批处理拦截] Message Batches 请求固定到创建批处理的端点，结果用量按批处理价格计费
+	if h.batchStore != nil && strings.HasPrefix(r.URL.Path, handlers.BatchesPathPrefix) {
+		connID, _ := r.Context().Value("conn_id").(string)
+
+		var bodyBytes []byte
+		if r.Body != nil {
+			var err error
+			bodyBytes, err = io.ReadAll(r.Body)
+			if err != nil {
+				http.Error(w, "Failed to read request body", http.StatusInternalServerError)
+				return
+			}
+			r.Body.Close()
+		}
+
+		// 💸 [预算] 创建批处理的用量同样计入预算，超出硬限制时拒绝
+		if op, _, ok := handlers.ParseBatchRequest(r.Method, r.URL.Path); ok && op == handlers.BatchOperationCreate {
+			for _, model := range handlers.BatchRequestModels(bodyBytes) {
+				if h.enforceBudget(w, model, nil) {
+					return
+				}
+			}
+		}
+
+		batchHandler := handlers.NewBatchHandler(h.config, h.endpointManager, h.forwarder, h.batchStore, h.usageTracker)
+		batchHandler.SetRequestTags(requestTags)
+		batchHandler.Handle(r.Context(), w, r, bodyBytes, connID)
+		return
+	}
+
+	// 📋 [模型列表] 已发现端点模型时，直接返回所有端点可用模型的并集
+	if r.Method == http.MethodGet && r.URL.Path == "/v1/models" && h.serveModelList(w) {
+		return
+	}
+
 	// 创建请求上下文
 	ctx := r.Context()
 	
@@ -489,12 +536,24 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		r.Body.Close()
 	}
 
-	// 异步解析请求体中的模型名称（不阻塞主转发流程）
-	go func(body []byte, path string) {
-		if modelName := h.extractModelFromRequestBody(body, path); modelName != "" {
-			lifecycleManager.SetModel(modelName)
-		}
-	}(append([]byte(nil), bodyBytes...), r.URL.Path) // 传递副本避免数据竞争
+	// 解码请求体一次，供模型路由、能力识别、会话归属与请求参数共享
+	sniff := sniffRequestBody(bodyBytes, r.URL.Path)
+
+	// 解析请求体中的模型名称：写入上下文供端点选择按模型筛选，生命周期记录异步进行
+	modelName := ""
+	if sniff != nil {
+		modelName = sniff.Model
+	}
+	if modelName != "" {
+		ctx = endpoint.WithRequestedModel(ctx, modelName)
+		r = r.WithContext(ctx)
+		go lifecycleManager.SetModel(modelName)
+	}
+	// 识别请求所需的端点能力（beta 头、thinking、工具类型、请求体大小），路由时跳过不兼容端点
+	if capabilities := endpoint.DetectRequiredCapabilitiesWithFeatures(r.URL.Path, r.Header, bodyBytes, sniff.capabilityFeatures()); len(capabilities) > 0 {
+		ctx = endpoint.WithRequiredCapabilities(ctx, capabilities)
+		r = r.WithContext(ctx)
+	}
 
 	// 检测是否为SSE流式请求
 	isSSE := h.detectSSERequest(r, bodyBytes)
@@ -503,6 +562,20 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	clientIP := r.RemoteAddr
 	userAgent := r.Header.Get("User-Agent")
 	lifecycleManager.StartRequest(clientIP, userAgent, r.Method, r.URL.Path, isSSE)
+
+	// 🧵 [会话归属] Claude Code 会话标识（metadata.user_id，缺失时使用会话请求头）
+	lifecycleManager.SetSessionID(extractSessionID(sniff, r.Header, h.config.UsageTracking.SessionHeader))
+
+	// 📐 [请求参数] max_tokens、消息数、工具数、思考预算、温度与请求大小
+	lifecycleManager.SetRequestParams(extractRequestParams(sniff, int64(len(bodyBytes))))
+
+	// 🏷️ [请求标签] 项目/成本中心归属
+	lifecycleManager.SetTags(requestTags)
+
+	// 💸 [预算] 超出预算硬限制时分流到其他渠道或直接拒绝
+	if h.enforceBudget(w, modelName, lifecycleManager) {
+		return
+	}
 	
 	// 统一请求处理
 	if isSSE {
@@ -520,6 +593,39 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// serveModelList 以 Anthropic 格式返回所有端点可用模型的并集
+// 尚未发现任何模型时返回 false，由调用方透传到上游
+func (h *Handler) serveModelList(w http.ResponseWriter) bool {
+	models := h.endpointManager.ListAvailableModels()
+	if len(models) == 0 {
+		return false
+	}
+
+	type modelEntry struct {
+		Type        string `json:"type"`
+		ID          string `json:"id"`
+		DisplayName string `json:"display_name"`
+		CreatedAt   string `json:"created_at,omitempty"`
+	}
+	data := make([]modelEntry, 0, len(models))
+	for _, m := range models {
+		displayName := m.DisplayName
+		if displayName == "" {
+			displayName = m.ID
+		}
+		data = append(data, modelEntry{Type: "model", ID: m.ID, DisplayName: displayName, CreatedAt: m.CreatedAt})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"data":     data,
+		"has_more": false,
+		"first_id": data[0].ID,
+		"last_id":  data[len(data)-1].ID,
+	})
+	return true
+}
+
 // detectSSERequest 统一SSE请求检测逻辑
 func (h *Handler) detectSSERequest(r *http.Request, bodyBytes []byte) bool {
 	// 检查多种SSE请求模式:
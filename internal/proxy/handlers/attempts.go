@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"strings"
+	"time"
+
+	"cc-forwarder/internal/tracking"
+)
+
+// AttemptRecorder 可选接口：生命周期管理器记录单次上游尝试（重试/故障转移轨迹）
+type AttemptRecorder interface {
+	RecordAttempt(attempt tracking.RequestAttempt)
+}
+
+// StreamBytesReporter 可选接口：流式处理器报告已接收的响应字节数
+type StreamBytesReporter interface {
+	BytesReceived() int64
+}
+
+// recordAttempt 补全尝试序号与结束时间后记录上游尝试（端点信息由生命周期管理器按当前端点补全）
+// 需在完成/失败请求前调用，确保热池模式下随请求一起归档
+func recordAttempt(lifecycleManager RequestLifecycleManager, attempt tracking.RequestAttempt) {
+	recorder, ok := lifecycleManager.(AttemptRecorder)
+	if !ok {
+		return
+	}
+
+	attempt.AttemptNumber = lifecycleManager.GetAttemptCount()
+	attempt.EndTime = time.Now()
+	recorder.RecordAttempt(attempt)
+}
+
+// failedAttempt 根据失败尝试的错误分类与重试决策构建尝试记录
+func failedAttempt(lifecycleManager RequestLifecycleManager, sentAt time.Time, err error, errorCtx *ErrorContext, decision *RetryDecision, attempt, maxAttempts int) tracking.RequestAttempt {
+	trace := tracking.RequestAttempt{
+		StartTime:      sentAt,
+		Decision:       attemptDecision(decision, attempt, maxAttempts),
+		DecisionReason: decision.Reason,
+	}
+	if errorCtx != nil {
+		trace.ErrorType = failureReasonForError(lifecycleManager, errorCtx.ErrorType, err)
+	}
+	if err != nil {
+		trace.ErrorMessage = err.Error()
+	}
+	if upErr, ok := AsUpstreamError(err); ok {
+		trace.HTTPStatus = upErr.StatusCode
+		trace.BytesReceived = int64(len(upErr.Body))
+	} else if fakeErr, ok := AsFakeSuccessError(err); ok {
+		trace.HTTPStatus = fakeErr.StatusCode
+	}
+	return trace
+}
+
+// committedStreamAttempt 根据流式处理结果构建已提交响应的尝试记录（响应头已写出，不再重试）
+// 判定规则与流式处理后的完成/失败分支一致
+func committedStreamAttempt(sentAt time.Time, statusCode int, processor interface{}, tokens *tracking.TokenUsage, err error, validators *ResponseValidators) tracking.RequestAttempt {
+	trace := tracking.RequestAttempt{
+		StartTime:  sentAt,
+		HTTPStatus: statusCode,
+		Decision:   tracking.AttemptDecisionSuccess,
+	}
+	if reporter, ok := processor.(StreamBytesReporter); ok {
+		trace.BytesReceived = reporter.BytesReceived()
+	}
+
+	if err == nil {
+		if fakeErr := validators.ValidateTokens(statusCode, tokens); fakeErr != nil {
+			trace.Decision = tracking.AttemptDecisionFail
+			trace.ErrorType = fakeErr.Validator
+			trace.ErrorMessage = fakeErr.Error()
+		}
+		return trace
+	}
+
+	trace.ErrorMessage = err.Error()
+	if streamErr, ok := err.(StreamIncompleteErrorInterface); ok {
+		// 流不完整默认按完成处理，启用 missing_message_stop 校验时判定为失败
+		trace.ErrorType = streamErr.GetFailureReason()
+		if validators.Has(ValidatorMissingMessageStop) {
+			trace.Decision = tracking.AttemptDecisionFail
+			trace.ErrorType = ValidatorMissingMessageStop
+		}
+		return trace
+	}
+	if strings.HasPrefix(err.Error(), "stream_status:cancelled") {
+		trace.Decision = tracking.AttemptDecisionCancel
+		return trace
+	}
+	trace.Decision = tracking.AttemptDecisionFail
+	trace.ErrorType = "stream_error"
+	return trace
+}
+
+// attemptDecision 将重试决策映射为尝试轨迹中的决策（挂起由调用方在确认挂起后覆盖）
+// 同端点重试次数耗尽时由外层切换到下一端点
+func attemptDecision(decision *RetryDecision, attempt, maxAttempts int) string {
+	switch {
+	case decision.FinalStatus == "cancelled":
+		return tracking.AttemptDecisionCancel
+	case decision.RetrySameEndpoint && attempt < maxAttempts:
+		return tracking.AttemptDecisionRetry
+	case decision.RetrySameEndpoint, decision.SwitchEndpoint:
+		return tracking.AttemptDecisionSwitch
+	default:
+		return tracking.AttemptDecisionFail
+	}
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"cc-forwarder/internal/tracking"
+)
+
+func TestAttemptDecision(t *testing.T) {
+	cases := []struct {
+		name     string
+		decision RetryDecision
+		attempt  int
+		want     string
+	}{
+		{"同端点重试", RetryDecision{RetrySameEndpoint: true}, 1, tracking.AttemptDecisionRetry},
+		{"重试次数耗尽", RetryDecision{RetrySameEndpoint: true}, 3, tracking.AttemptDecisionSwitch},
+		{"切换端点", RetryDecision{SwitchEndpoint: true}, 1, tracking.AttemptDecisionSwitch},
+		{"客户端取消", RetryDecision{FinalStatus: "cancelled"}, 1, tracking.AttemptDecisionCancel},
+		{"终止", RetryDecision{FinalStatus: "auth_error"}, 1, tracking.AttemptDecisionFail},
+	}
+	for _, c := range cases {
+		if got := attemptDecision(&c.decision, c.attempt, 3); got != c.want {
+			t.Errorf("%s: got %s, want %s", c.name, got, c.want)
+		}
+	}
+}
+
+func TestCommittedStreamAttempt(t *testing.T) {
+	sentAt := time.Now()
+
+	trace := committedStreamAttempt(sentAt, 200, nil, &tracking.TokenUsage{OutputTokens: 10}, nil, nil)
+	if trace.Decision != tracking.AttemptDecisionSuccess || trace.HTTPStatus != 200 || trace.ErrorType != "" {
+		t.Errorf("正常完成: %+v", trace)
+	}
+
+	trace = committedStreamAttempt(sentAt, 200, nil, nil, errors.New("stream_status:cancelled:model:claude"), nil)
+	if trace.Decision != tracking.AttemptDecisionCancel {
+		t.Errorf("流式取消: %+v", trace)
+	}
+
+	trace = committedStreamAttempt(sentAt, 200, nil, nil, errors.New("stream_status:error:model:claude:boom"), nil)
+	if trace.Decision != tracking.AttemptDecisionFail || trace.ErrorType != "stream_error" || trace.ErrorMessage == "" {
+		t.Errorf("流式错误: %+v", trace)
+	}
+}
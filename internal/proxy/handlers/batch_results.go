@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+
+	"cc-forwarder/internal/tracking"
+)
+
+// batchResultLine 批处理结果 JSONL 单行（仅解析计费需要的字段）
+type batchResultLine struct {
+	CustomID string `json:"custom_id"`
+	Result   struct {
+		Type    string `json:"type"` // succeeded / errored / canceled / expired
+		Message *struct {
+			Model string `json:"model"`
+			Usage *struct {
+				InputTokens              int64 `json:"input_tokens"`
+				OutputTokens             int64 `json:"output_tokens"`
+				CacheCreationInputTokens int64 `json:"cache_creation_input_tokens"`
+				CacheReadInputTokens     int64 `json:"cache_read_input_tokens"`
+				CacheCreation            *struct {
+					Ephemeral5mInputTokens int64 `json:"ephemeral_5m_input_tokens"`
+					Ephemeral1hInputTokens int64 `json:"ephemeral_1h_input_tokens"`
+				} `json:"cache_creation"`
+				ServerToolUse *struct {
+					WebSearchRequests int64 `json:"web_search_requests"`
+					WebFetchRequests  int64 `json:"web_fetch_requests"`
+				} `json:"server_tool_use"`
+			} `json:"usage"`
+		} `json:"message"`
+	} `json:"result"`
+}
+
+// BatchResultsAggregator 按模型汇总批处理结果用量
+// 仅 succeeded 结果计费，errored/canceled/expired 不产生费用
+type BatchResultsAggregator struct {
+	lines     int
+	succeeded int
+	byModel   map[string][]tracking.TokenUsage
+}
+
+// NewBatchResultsAggregator 创建结果汇总器
+func NewBatchResultsAggregator() *BatchResultsAggregator {
+	return &BatchResultsAggregator{byModel: make(map[string][]tracking.TokenUsage)}
+}
+
+// AddLine 解析一行结果（空行与无法解析的行忽略）
+func (a *BatchResultsAggregator) AddLine(line []byte) {
+	line = bytes.TrimSpace(line)
+	if len(line) == 0 {
+		return
+	}
+	a.lines++
+
+	var entry batchResultLine
+	if err := json.Unmarshal(line, &entry); err != nil {
+		return
+	}
+	if entry.Result.Type != "succeeded" || entry.Result.Message == nil || entry.Result.Message.Usage == nil {
+		return
+	}
+	a.succeeded++
+
+	u := entry.Result.Message.Usage
+	usage := tracking.TokenUsage{
+		InputTokens:         u.InputTokens,
+		OutputTokens:        u.OutputTokens,
+		CacheCreationTokens: u.CacheCreationInputTokens,
+		CacheReadTokens:     u.CacheReadInputTokens,
+	}
+	if u.CacheCreation != nil {
+		usage.CacheCreation5mTokens = u.CacheCreation.Ephemeral5mInputTokens
+		usage.CacheCreation1hTokens = u.CacheCreation.Ephemeral1hInputTokens
+	}
+	if u.ServerToolUse != nil {
+		usage.WebSearchRequests = u.ServerToolUse.WebSearchRequests
+		usage.WebFetchRequests = u.ServerToolUse.WebFetchRequests
+	}
+
+	model := entry.Result.Message.Model
+	if model == "" {
+		model = "unknown"
+	}
+	a.byModel[model] = append(a.byModel[model], usage)
+}
+
+// Lines 返回已解析的结果行数
+func (a *BatchResultsAggregator) Lines() int {
+	return a.lines
+}
+
+// Succeeded 返回成功结果条数
+func (a *BatchResultsAggregator) Succeeded() int {
+	return a.succeeded
+}
+
+// Usage 返回按模型分组的用量（按模型名排序）
+func (a *BatchResultsAggregator) Usage() []tracking.BatchUsage {
+	models := make([]string, 0, len(a.byModel))
+	for model := range a.byModel {
+		models = append(models, model)
+	}
+	sort.Strings(models)
+
+	result := make([]tracking.BatchUsage, 0, len(models))
+	for _, model := range models {
+		result = append(result, tracking.BatchUsage{ModelName: model, Items: a.byModel[model]})
+	}
+	return result
+}
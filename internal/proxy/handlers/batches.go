@@ -0,0 +1,447 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"cc-forwarder/config"
+	"cc-forwarder/internal/endpoint"
+	"cc-forwarder/internal/store"
+	"cc-forwarder/internal/tracking"
+	"cc-forwarder/internal/transport"
+)
+
+// BatchesPathPrefix Message Batches API 路径前缀
+const BatchesPathPrefix = "/v1/messages/batches"
+
+// BatchOperation Message Batches 操作类型
+type BatchOperation string
+
+const (
+	BatchOperationCreate   BatchOperation = "create"   // POST /v1/messages/batches
+	BatchOperationList     BatchOperation = "list"     // GET /v1/messages/batches
+	BatchOperationRetrieve BatchOperation = "retrieve" // GET /v1/messages/batches/{id}
+	BatchOperationResults  BatchOperation = "results"  // GET /v1/messages/batches/{id}/results
+	BatchOperationCancel   BatchOperation = "cancel"   // POST /v1/messages/batches/{id}/cancel
+	BatchOperationDelete   BatchOperation = "delete"   // DELETE /v1/messages/batches/{id}
+)
+
+// ParseBatchRequest 识别 Message Batches 请求的操作类型及批处理 ID
+func ParseBatchRequest(method, path string) (BatchOperation, string, bool) {
+	if !strings.HasPrefix(path, BatchesPathPrefix) {
+		return "", "", false
+	}
+	rest := strings.Trim(strings.TrimPrefix(path, BatchesPathPrefix), "/")
+	if rest == "" {
+		switch method {
+		case http.MethodPost:
+			return BatchOperationCreate, "", true
+		case http.MethodGet:
+			return BatchOperationList, "", true
+		}
+		return "", "", false
+	}
+
+	parts := strings.Split(rest, "/")
+	batchID := parts[0]
+	switch {
+	case len(parts) == 1 && method == http.MethodGet:
+		return BatchOperationRetrieve, batchID, true
+	case len(parts) == 1 && method == http.MethodDelete:
+		return BatchOperationDelete, batchID, true
+	case len(parts) == 2 && parts[1] == "results" && method == http.MethodGet:
+		return BatchOperationResults, batchID, true
+	case len(parts) == 2 && parts[1] == "cancel" && method == http.MethodPost:
+		return BatchOperationCancel, batchID, true
+	}
+	return "", "", false
+}
+
+// BatchHandler 处理 Message Batches API 请求
+// 策略：创建时记录批处理所属端点，后续查询/结果/取消/删除固定转发到该端点；
+// 拉取结果时解析 JSONL 中每条结果的 usage，按批处理计费倍率计入使用统计
+type BatchHandler struct {
+	config          *config.Config
+	endpointManager *endpoint.Manager
+	forwarder       *Forwarder
+	batchStore      store.MessageBatchStore
+	usageTracker    *tracking.UsageTracker
+	tags            []tracking.RequestTag // 当前请求的标签，创建批处理时随记录保存
+}
+
+// NewBatchHandler 创建 BatchHandler
+func NewBatchHandler(cfg *config.Config, em *endpoint.Manager, f *Forwarder, batchStore store.MessageBatchStore, ut *tracking.UsageTracker) *BatchHandler {
+	return &BatchHandler{
+		config:          cfg,
+		endpointManager: em,
+		forwarder:       f,
+		batchStore:      batchStore,
+		usageTracker:    ut,
+	}
+}
+
+// SetRequestTags 设置当前请求的标签（项目/成本中心归属）
+func (h *BatchHandler) SetRequestTags(tags []tracking.RequestTag) {
+	h.tags = tags
+}
+
+// BatchRequestModels 解析创建批处理请求中涉及的模型（去重，未指定模型时返回一个空字符串）
+func BatchRequestModels(body []byte) []string {
+	var payload struct {
+		Requests []struct {
+			Params struct {
+				Model string `json:"model"`
+			} `json:"params"`
+		} `json:"requests"`
+	}
+	var models []string
+	if err := json.Unmarshal(body, &payload); err == nil {
+		seen := make(map[string]bool)
+		for _, req := range payload.Requests {
+			if model := req.Params.Model; model != "" && !seen[model] {
+				seen[model] = true
+				models = append(models, model)
+			}
+		}
+	}
+	if len(models) == 0 {
+		return []string{""}
+	}
+	return models
+}
+
+// toBatchTags 转换为批处理记录标签
+func toBatchTags(tags []tracking.RequestTag) []store.MessageBatchTag {
+	if len(tags) == 0 {
+		return nil
+	}
+	result := make([]store.MessageBatchTag, 0, len(tags))
+	for _, tag := range tags {
+		result = append(result, store.MessageBatchTag{Key: tag.Key, Value: tag.Value})
+	}
+	return result
+}
+
+// fromBatchTags 将批处理记录标签转换为请求标签
+func fromBatchTags(tags []store.MessageBatchTag) []tracking.RequestTag {
+	if len(tags) == 0 {
+		return nil
+	}
+	result := make([]tracking.RequestTag, 0, len(tags))
+	for _, tag := range tags {
+		result = append(result, tracking.RequestTag{Key: tag.Key, Value: tag.Value})
+	}
+	return result
+}
+
+// batchObject 上游批处理对象（仅解析需要的字段）
+type batchObject struct {
+	ID               string `json:"id"`
+	Type             string `json:"type"`
+	ProcessingStatus string `json:"processing_status"`
+	RequestCounts    struct {
+		Processing int64 `json:"processing"`
+		Succeeded  int64 `json:"succeeded"`
+		Errored    int64 `json:"errored"`
+		Canceled   int64 `json:"canceled"`
+		Expired    int64 `json:"expired"`
+	} `json:"request_counts"`
+	ExpiresAt *time.Time `json:"expires_at"`
+	EndedAt   *time.Time `json:"ended_at"`
+}
+
+// toRecord 转换为批处理存储记录
+func (b *batchObject) toRecord() *store.MessageBatchRecord {
+	return &store.MessageBatchRecord{
+		BatchID:          b.ID,
+		ProcessingStatus: b.ProcessingStatus,
+		ProcessingCount:  b.RequestCounts.Processing,
+		SucceededCount:   b.RequestCounts.Succeeded,
+		ErroredCount:     b.RequestCounts.Errored,
+		CanceledCount:    b.RequestCounts.Canceled,
+		ExpiredCount:     b.RequestCounts.Expired,
+		ExpiresAt:        b.ExpiresAt,
+		EndedAt:          b.EndedAt,
+	}
+}
+
+// Handle 处理 Message Batches 请求
+func (h *BatchHandler) Handle(ctx context.Context, w http.ResponseWriter, r *http.Request, bodyBytes []byte, connID string) {
+	op, batchID, ok := ParseBatchRequest(r.Method, r.URL.Path)
+	if !ok {
+		http.Error(w, "Unsupported message batches request", http.StatusNotFound)
+		return
+	}
+	slog.Info(fmt.Sprintf("📦 [批处理] [%s] 收到请求: %s %s", connID, op, batchID))
+
+	candidates, pinned := h.selectEndpoints(ctx, batchID, connID)
+	if len(candidates) == 0 {
+		http.Error(w, "Service Unavailable: No healthy endpoints support message batches", http.StatusServiceUnavailable)
+		return
+	}
+
+	var lastErr error
+	for _, ep := range candidates {
+		resp, err := h.forward(ctx, r, bodyBytes, ep, op == BatchOperationResults)
+		if err != nil {
+			lastErr = err
+			slog.Warn(fmt.Sprintf("❌ [批处理] [%s] 端点: %s, 错误: %v", connID, ep.Config.Name, err))
+			continue
+		}
+
+		// 未固定端点时，服务端错误/限流/未找到批处理切换到下一个端点
+		if !pinned && ep != candidates[len(candidates)-1] && shouldTryNextBatchEndpoint(op, resp.StatusCode) {
+			resp.Body.Close()
+			lastErr = fmt.Errorf("endpoint %s returned %d", ep.Config.Name, resp.StatusCode)
+			slog.Warn(fmt.Sprintf("⚠️ [批处理] [%s] 端点: %s 返回 %d，尝试下一个端点", connID, ep.Config.Name, resp.StatusCode))
+			continue
+		}
+
+		h.writeResponse(ctx, w, resp, op, batchID, ep, connID)
+		return
+	}
+
+	if lastErr == nil {
+		lastErr = errors.New("no endpoint available")
+	}
+	http.Error(w, "All endpoints failed: "+lastErr.Error(), http.StatusBadGateway)
+}
+
+// selectEndpoints 选择转发目标：已记录的批处理固定到创建时的端点，否则按能力筛选健康端点
+func (h *BatchHandler) selectEndpoints(ctx context.Context, batchID, connID string) ([]*endpoint.Endpoint, bool) {
+	if batchID != "" && h.batchStore != nil {
+		record, err := h.batchStore.Get(ctx, batchID)
+		if err != nil {
+			slog.Warn(fmt.Sprintf("⚠️ [批处理] [%s] 查询批处理记录失败: %v", connID, err))
+		} else if record != nil {
+			ep := h.endpointManager.GetEndpointByNameAny(endpoint.EndpointKey(record.Channel, record.EndpointName))
+			if ep == nil {
+				slog.Warn(fmt.Sprintf("⚠️ [批处理] [%s] 批处理 %s 所属端点 %s 已不存在",
+					connID, batchID, endpoint.EndpointKey(record.Channel, record.EndpointName)))
+				return nil, true
+			}
+			slog.Debug(fmt.Sprintf("📌 [批处理] [%s] 批处理 %s 固定转发到端点: %s", connID, batchID, ep.Config.Name))
+			return []*endpoint.Endpoint{ep}, true
+		}
+	}
+
+	ctx = endpoint.WithRequiredCapabilities(ctx, []string{endpoint.CapabilityBatches})
+	return h.endpointManager.GetHealthyEndpointsForRequest(ctx), false
+}
+
+// shouldTryNextBatchEndpoint 判断未固定端点时是否切换到下一个端点
+func shouldTryNextBatchEndpoint(op BatchOperation, statusCode int) bool {
+	if statusCode >= 500 || statusCode == http.StatusTooManyRequests {
+		return true
+	}
+	// 未记录的批处理可能由其他端点创建（例如启用跟踪前创建），404 时继续查找
+	return statusCode == http.StatusNotFound && op != BatchOperationCreate && op != BatchOperationList
+}
+
+// forward 转发请求到指定端点
+func (h *BatchHandler) forward(ctx context.Context, r *http.Request, bodyBytes []byte, ep *endpoint.Endpoint, streaming bool) (*http.Response, error) {
+	targetURL := ep.Config.URL + r.URL.Path
+	if r.URL.RawQuery != "" {
+		targetURL += "?" + r.URL.RawQuery
+	}
+
+	req, err := http.NewRequestWithContext(ctx, r.Method, targetURL, bytes.NewReader(bodyBytes))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	h.forwarder.CopyHeaders(r, req, ep)
+	// 交由 Transport 自动协商并解压 gzip，结果 JSONL 需要逐行解析
+	req.Header.Del("Accept-Encoding")
+
+	httpTransport, err := transport.CreateTransport(h.config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create transport: %w", err)
+	}
+
+	client := &http.Client{
+		Timeout:   ep.Config.Timeout,
+		Transport: httpTransport,
+	}
+	if streaming {
+		client.Timeout = 0 // 结果文件可能很大，按上下文取消
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	return resp, nil
+}
+
+// writeResponse 写回上游响应并同步批处理记录
+func (h *BatchHandler) writeResponse(ctx context.Context, w http.ResponseWriter, resp *http.Response, op BatchOperation, batchID string, ep *endpoint.Endpoint, connID string) {
+	defer resp.Body.Close()
+
+	for key, values := range resp.Header {
+		// 响应体已由 Transport 解压，长度可能变化
+		if strings.EqualFold(key, "Content-Encoding") || strings.EqualFold(key, "Content-Length") {
+			continue
+		}
+		for _, value := range values {
+			w.Header().Add(key, value)
+		}
+	}
+	w.WriteHeader(resp.StatusCode)
+
+	success := resp.StatusCode >= 200 && resp.StatusCode < 300
+	if op == BatchOperationResults && success {
+		h.streamResults(ctx, w, resp.Body, batchID, ep, connID)
+		return
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		slog.Error(fmt.Sprintf("❌ [批处理] [%s] 读取响应失败: %v", connID, err))
+		return
+	}
+	w.Write(body)
+
+	if !success {
+		slog.Warn(fmt.Sprintf("⚠️ [批处理] [%s] 端点: %s, %s 返回 %d", connID, ep.Config.Name, op, resp.StatusCode))
+		return
+	}
+	switch op {
+	case BatchOperationCreate, BatchOperationRetrieve, BatchOperationCancel:
+		h.saveBatch(ctx, body, op, ep, connID)
+	}
+}
+
+// saveBatch 根据创建/查询/取消响应保存批处理状态
+func (h *BatchHandler) saveBatch(ctx context.Context, body []byte, op BatchOperation, ep *endpoint.Endpoint, connID string) {
+	if h.batchStore == nil {
+		return
+	}
+
+	var batch batchObject
+	if err := json.Unmarshal(body, &batch); err != nil || batch.ID == "" {
+		slog.Warn(fmt.Sprintf("⚠️ [批处理] [%s] 无法解析批处理响应: %v", connID, err))
+		return
+	}
+
+	record := batch.toRecord()
+	record.Channel = ep.Config.Channel
+	record.EndpointName = ep.Config.Name
+
+	// 请求结束后继续使用独立上下文写入，避免客户端断开导致记录丢失
+	storeCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+	defer cancel()
+
+	var err error
+	if op == BatchOperationCreate {
+		record.Tags = toBatchTags(h.tags)
+		err = h.batchStore.Upsert(storeCtx, record)
+	} else if existing, getErr := h.batchStore.Get(storeCtx, batch.ID); getErr == nil && existing != nil {
+		err = h.batchStore.UpdateStatus(storeCtx, record)
+	} else {
+		// 未记录的批处理（如在其他端点命中），补录所属端点
+		err = h.batchStore.Upsert(storeCtx, record)
+	}
+	if err != nil {
+		slog.Warn(fmt.Sprintf("⚠️ [批处理] [%s] 保存批处理 %s 失败: %v", connID, batch.ID, err))
+		return
+	}
+	slog.Info(fmt.Sprintf("📦 [批处理] [%s] 批处理 %s 状态: %s, 端点: %s",
+		connID, batch.ID, batch.ProcessingStatus, ep.Config.Name))
+}
+
+// streamResults 逐行转发结果 JSONL，同时汇总每个模型的用量
+func (h *BatchHandler) streamResults(ctx context.Context, w http.ResponseWriter, body io.Reader, batchID string, ep *endpoint.Endpoint, connID string) {
+	flusher, _ := w.(http.Flusher)
+	aggregator := NewBatchResultsAggregator()
+	reader := bufio.NewReader(body)
+
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			if _, writeErr := w.Write(line); writeErr != nil {
+				slog.Warn(fmt.Sprintf("⚠️ [批处理] [%s] 客户端写入失败，停止解析结果: %v", connID, writeErr))
+				return
+			}
+			aggregator.AddLine(line)
+		}
+		if err != nil {
+			if err != io.EOF {
+				slog.Warn(fmt.Sprintf("⚠️ [批处理] [%s] 读取结果失败，跳过用量记录: %v", connID, err))
+				return
+			}
+			break
+		}
+		if flusher != nil && aggregator.Lines()%100 == 0 {
+			flusher.Flush()
+		}
+	}
+	if flusher != nil {
+		flusher.Flush()
+	}
+
+	slog.Info(fmt.Sprintf("📦 [批处理] [%s] 批处理 %s 结果解析完成: %d 条, 成功 %d 条",
+		connID, batchID, aggregator.Lines(), aggregator.Succeeded()))
+	h.recordResultsUsage(ctx, batchID, ep, aggregator, connID)
+}
+
+// recordResultsUsage 记录批处理结果用量（每个批处理只记录一次）
+func (h *BatchHandler) recordResultsUsage(ctx context.Context, batchID string, ep *endpoint.Endpoint, aggregator *BatchResultsAggregator, connID string) {
+	if h.batchStore == nil || h.usageTracker == nil || aggregator.Succeeded() == 0 {
+		return
+	}
+
+	storeCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+	defer cancel()
+
+	var submittedAt time.Time
+	tags := h.tags
+	record, err := h.batchStore.Get(storeCtx, batchID)
+	if err != nil || record == nil {
+		// 未记录的批处理先补录（标签取拉取结果的请求），保证后续只计费一次
+		if err := h.batchStore.Upsert(storeCtx, &store.MessageBatchRecord{
+			BatchID:          batchID,
+			Channel:          ep.Config.Channel,
+			EndpointName:     ep.Config.Name,
+			ProcessingStatus: store.MessageBatchStatusEnded,
+			Tags:             toBatchTags(tags),
+		}); err != nil {
+			slog.Warn(fmt.Sprintf("⚠️ [批处理] [%s] 补录批处理 %s 失败: %v", connID, batchID, err))
+			return
+		}
+	} else if record.UsageRecorded {
+		slog.Debug(fmt.Sprintf("📦 [批处理] [%s] 批处理 %s 用量已记录，跳过", connID, batchID))
+		return
+	} else {
+		submittedAt = record.CreatedAt
+		tags = fromBatchTags(record.Tags)
+	}
+
+	// 先写入用量再标记已记录：任一模型写入失败时保持未记录，下次拉取结果时重新计费
+	// 用量按批处理 + 模型的请求 ID 去重，已写入的模型重复记录会被忽略
+	for _, usage := range aggregator.Usage() {
+		usage.BatchID = batchID
+		usage.Channel = ep.Config.Channel
+		usage.EndpointName = ep.Config.Name
+		usage.SubmittedAt = submittedAt
+		usage.Tags = tags
+		if err := h.usageTracker.RecordBatchUsage(usage, h.config.MessageBatches.CostMultiplier); err != nil {
+			slog.Error(fmt.Sprintf("❌ [批处理] [%s] 记录批处理 %s 模型 %s 用量失败，下次拉取结果时重试: %v", connID, batchID, usage.ModelName, err))
+			return
+		}
+	}
+
+	markCtx, markCancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+	defer markCancel()
+	if _, err := h.batchStore.MarkUsageRecorded(markCtx, batchID); err != nil {
+		slog.Warn(fmt.Sprintf("⚠️ [批处理] [%s] 标记批处理 %s 用量已记录失败: %v", connID, batchID, err))
+	}
+}
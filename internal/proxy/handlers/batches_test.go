@@ -0,0 +1,301 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"cc-forwarder/config"
+	"cc-forwarder/internal/endpoint"
+	"cc-forwarder/internal/store"
+	"cc-forwarder/internal/tracking"
+)
+
+func TestParseBatchRequest(t *testing.T) {
+	tests := []struct {
+		method string
+		path   string
+		wantOp BatchOperation
+		wantID string
+		wantOK bool
+	}{
+		{http.MethodPost, "/v1/messages/batches", BatchOperationCreate, "", true},
+		{http.MethodGet, "/v1/messages/batches", BatchOperationList, "", true},
+		{http.MethodGet, "/v1/messages/batches/msgbatch_01", BatchOperationRetrieve, "msgbatch_01", true},
+		{http.MethodGet, "/v1/messages/batches/msgbatch_01/results", BatchOperationResults, "msgbatch_01", true},
+		{http.MethodPost, "/v1/messages/batches/msgbatch_01/cancel", BatchOperationCancel, "msgbatch_01", true},
+		{http.MethodDelete, "/v1/messages/batches/msgbatch_01", BatchOperationDelete, "msgbatch_01", true},
+		{http.MethodPut, "/v1/messages/batches/msgbatch_01", "", "", false},
+		{http.MethodPost, "/v1/messages", "", "", false},
+	}
+
+	for _, tt := range tests {
+		op, id, ok := ParseBatchRequest(tt.method, tt.path)
+		if op != tt.wantOp || id != tt.wantID || ok != tt.wantOK {
+			t.Errorf("ParseBatchRequest(%s %s) = (%q, %q, %v), 期望 (%q, %q, %v)",
+				tt.method, tt.path, op, id, ok, tt.wantOp, tt.wantID, tt.wantOK)
+		}
+	}
+}
+
+func TestBatchResultsAggregator(t *testing.T) {
+	jsonl := strings.Join([]string{
+		`{"custom_id":"a","result":{"type":"succeeded","message":{"model":"claude-sonnet-4-5","usage":{"input_tokens":100,"output_tokens":50,"cache_read_input_tokens":10,"cache_creation":{"ephemeral_5m_input_tokens":20,"ephemeral_1h_input_tokens":0},"cache_creation_input_tokens":20}}}}`,
+		`{"custom_id":"b","result":{"type":"succeeded","message":{"model":"claude-sonnet-4-5","usage":{"input_tokens":200,"output_tokens":80,"server_tool_use":{"web_search_requests":2}}}}}`,
+		`{"custom_id":"c","result":{"type":"succeeded","message":{"model":"claude-haiku-4-5","usage":{"input_tokens":30,"output_tokens":5}}}}`,
+		`{"custom_id":"d","result":{"type":"errored","error":{"type":"invalid_request_error"}}}`,
+		`{"custom_id":"e","result":{"type":"expired"}}`,
+		``,
+		`not json`,
+	}, "\n")
+
+	aggregator := NewBatchResultsAggregator()
+	for _, line := range strings.Split(jsonl, "\n") {
+		aggregator.AddLine([]byte(line))
+	}
+
+	if aggregator.Lines() != 6 {
+		t.Errorf("Lines() = %d, 期望 6", aggregator.Lines())
+	}
+	if aggregator.Succeeded() != 3 {
+		t.Errorf("Succeeded() = %d, 期望 3", aggregator.Succeeded())
+	}
+
+	usage := aggregator.Usage()
+	if len(usage) != 2 || usage[0].ModelName != "claude-haiku-4-5" || usage[1].ModelName != "claude-sonnet-4-5" {
+		t.Fatalf("按模型分组结果不符: %+v", usage)
+	}
+
+	sonnet := usage[1]
+	if len(sonnet.Items) != 2 {
+		t.Fatalf("sonnet 条目数 = %d, 期望 2", len(sonnet.Items))
+	}
+	total := sonnet.Total()
+	if total.InputTokens != 300 || total.OutputTokens != 130 || total.CacheReadTokens != 10 {
+		t.Errorf("sonnet 合计不符: %+v", total)
+	}
+	if total.CacheCreation5mTokens != 20 || total.WebSearchRequests != 2 {
+		t.Errorf("sonnet 缓存/工具用量不符: %+v", total)
+	}
+}
+
+// memoryBatchStore 内存批处理存储（测试用）
+type memoryBatchStore struct {
+	records map[string]*store.MessageBatchRecord
+}
+
+func newMemoryBatchStore() *memoryBatchStore {
+	return &memoryBatchStore{records: make(map[string]*store.MessageBatchRecord)}
+}
+
+func (s *memoryBatchStore) Upsert(_ context.Context, record *store.MessageBatchRecord) error {
+	copied := *record
+	if existing, ok := s.records[record.BatchID]; ok {
+		copied.UsageRecorded = existing.UsageRecorded
+		if copied.Tags == nil {
+			copied.Tags = existing.Tags
+		}
+	}
+	s.records[record.BatchID] = &copied
+	return nil
+}
+
+func (s *memoryBatchStore) Get(_ context.Context, batchID string) (*store.MessageBatchRecord, error) {
+	return s.records[batchID], nil
+}
+
+func (s *memoryBatchStore) List(_ context.Context, _ int) ([]*store.MessageBatchRecord, error) {
+	var records []*store.MessageBatchRecord
+	for _, record := range s.records {
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+func (s *memoryBatchStore) UpdateStatus(_ context.Context, record *store.MessageBatchRecord) error {
+	if existing, ok := s.records[record.BatchID]; ok {
+		existing.ProcessingStatus = record.ProcessingStatus
+		existing.SucceededCount = record.SucceededCount
+	}
+	return nil
+}
+
+func (s *memoryBatchStore) MarkUsageRecorded(_ context.Context, batchID string) (bool, error) {
+	record, ok := s.records[batchID]
+	if !ok || record.UsageRecorded {
+		return false, nil
+	}
+	record.UsageRecorded = true
+	return true, nil
+}
+
+func (s *memoryBatchStore) WithTx(_ *sql.Tx) store.MessageBatchStore {
+	return s
+}
+
+// TestBatchHandler_PinsFollowUpToCreatingEndpoint 测试后续请求固定转发到创建批处理的端点
+func TestBatchHandler_PinsFollowUpToCreatingEndpoint(t *testing.T) {
+	var hitsA, hitsB int
+	serverA := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hitsA++
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"msgbatch_01","type":"message_batch","processing_status":"ended","request_counts":{"succeeded":1}}`))
+	}))
+	defer serverA.Close()
+	serverB := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hitsB++
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer serverB.Close()
+
+	cfg := &config.Config{
+		Endpoints: []config.EndpointConfig{
+			{Name: "b", URL: serverB.URL, Channel: "B", Priority: 1, Timeout: 5 * time.Second},
+			{Name: "a", URL: serverA.URL, Channel: "A", Priority: 2, Timeout: 5 * time.Second},
+		},
+	}
+	manager := endpoint.NewManager(cfg)
+	batchStore := newMemoryBatchStore()
+	batchStore.Upsert(context.Background(), &store.MessageBatchRecord{BatchID: "msgbatch_01", Channel: "A", EndpointName: "a"})
+
+	handler := NewBatchHandler(cfg, manager, NewForwarder(cfg, manager), batchStore, nil)
+	req := httptest.NewRequest(http.MethodGet, "/v1/messages/batches/msgbatch_01", nil)
+	rec := httptest.NewRecorder()
+	handler.Handle(context.Background(), rec, req, nil, "test")
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("状态码 = %d, 期望 200", rec.Code)
+	}
+	if hitsA != 1 || hitsB != 0 {
+		t.Errorf("请求应只转发到端点 a, 实际 a=%d b=%d", hitsA, hitsB)
+	}
+	if record := batchStore.records["msgbatch_01"]; record.ProcessingStatus != store.MessageBatchStatusEnded || record.SucceededCount != 1 {
+		t.Errorf("批处理状态未同步: %+v", record)
+	}
+}
+
+// TestBatchHandler_MarksUsageRecordedAfterWrite 测试用量写入失败时不标记已记录，下次拉取结果时重新计费
+func TestBatchHandler_MarksUsageRecordedAfterWrite(t *testing.T) {
+	tracker, err := tracking.NewUsageTracker(&tracking.Config{
+		Enabled:         true,
+		DatabasePath:    filepath.Join(t.TempDir(), "usage.db"),
+		BufferSize:      100,
+		BatchSize:       10,
+		FlushInterval:   50 * time.Millisecond,
+		MaxRetry:        3,
+		CleanupInterval: 24 * time.Hour,
+		RetentionDays:   30,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create usage tracker: %v", err)
+	}
+	defer tracker.Close()
+
+	cfg := &config.Config{
+		Endpoints: []config.EndpointConfig{{Name: "a", URL: "http://example.invalid", Channel: "A", Timeout: 5 * time.Second}},
+	}
+	manager := endpoint.NewManager(cfg)
+	ep := manager.GetEndpointByNameAny(endpoint.EndpointKey("A", "a"))
+	batchStore := newMemoryBatchStore()
+	handler := NewBatchHandler(cfg, manager, NewForwarder(cfg, manager), batchStore, tracker)
+
+	aggregator := NewBatchResultsAggregator()
+	aggregator.AddLine([]byte(`{"custom_id":"a","result":{"type":"succeeded","message":{"model":"claude-sonnet-4-5","usage":{"input_tokens":100,"output_tokens":50}}}}`))
+
+	// 写入失败：批处理保持未记录
+	db := tracker.GetDB()
+	if _, err := db.Exec(`ALTER TABLE request_logs RENAME TO request_logs_hidden`); err != nil {
+		t.Fatalf("隐藏 request_logs 失败: %v", err)
+	}
+	handler.recordResultsUsage(context.Background(), "msgbatch_01", ep, aggregator, "test")
+	if record := batchStore.records["msgbatch_01"]; record == nil || record.UsageRecorded {
+		t.Fatalf("用量写入失败时不应标记已记录: %+v", record)
+	}
+
+	// 恢复后重新拉取：写入成功并标记已记录
+	if _, err := db.Exec(`ALTER TABLE request_logs_hidden RENAME TO request_logs`); err != nil {
+		t.Fatalf("恢复 request_logs 失败: %v", err)
+	}
+	handler.recordResultsUsage(context.Background(), "msgbatch_01", ep, aggregator, "test")
+	if record := batchStore.records["msgbatch_01"]; !record.UsageRecorded {
+		t.Fatal("用量写入成功后应标记已记录")
+	}
+	var count int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM request_logs WHERE request_id = ?`,
+		tracking.BatchRequestID("msgbatch_01", "claude-sonnet-4-5")).Scan(&count); err != nil || count != 1 {
+		t.Errorf("批处理用量记录数 = %d, %v; 期望 1", count, err)
+	}
+}
+
+// TestBatchHandler_RecordsCreateTags 测试创建批处理时保存请求标签，结果用量写入时沿用
+func TestBatchHandler_RecordsCreateTags(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"msgbatch_tags","type":"message_batch","processing_status":"in_progress"}`))
+	}))
+	defer server.Close()
+
+	tracker, err := tracking.NewUsageTracker(&tracking.Config{
+		Enabled:         true,
+		DatabasePath:    filepath.Join(t.TempDir(), "usage.db"),
+		BufferSize:      100,
+		BatchSize:       10,
+		FlushInterval:   50 * time.Millisecond,
+		MaxRetry:        3,
+		CleanupInterval: 24 * time.Hour,
+		RetentionDays:   30,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create usage tracker: %v", err)
+	}
+	defer tracker.Close()
+
+	cfg := &config.Config{
+		Endpoints: []config.EndpointConfig{{Name: "a", URL: server.URL, Channel: "A", Timeout: 5 * time.Second}},
+	}
+	manager := endpoint.NewManager(cfg)
+	ep := manager.GetEndpointByNameAny(endpoint.EndpointKey("A", "a"))
+	ep.Status.Healthy = true
+	ep.Status.NeverChecked = false
+	batchStore := newMemoryBatchStore()
+
+	creator := NewBatchHandler(cfg, manager, NewForwarder(cfg, manager), batchStore, tracker)
+	creator.SetRequestTags([]tracking.RequestTag{{Key: "project", Value: "alpha"}})
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages/batches", strings.NewReader(`{"requests":[]}`))
+	rec := httptest.NewRecorder()
+	creator.Handle(context.Background(), rec, req, []byte(`{"requests":[]}`), "test")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("状态码 = %d, 期望 200", rec.Code)
+	}
+	if record := batchStore.records["msgbatch_tags"]; record == nil || len(record.Tags) != 1 || record.Tags[0].Value != "alpha" {
+		t.Fatalf("创建批处理应保存请求标签: %+v", record)
+	}
+
+	// 拉取结果的请求不带标签：沿用创建时的标签
+	fetcher := NewBatchHandler(cfg, manager, NewForwarder(cfg, manager), batchStore, tracker)
+	aggregator := NewBatchResultsAggregator()
+	aggregator.AddLine([]byte(`{"custom_id":"a","result":{"type":"succeeded","message":{"model":"claude-sonnet-4-5","usage":{"input_tokens":100,"output_tokens":50}}}}`))
+	fetcher.recordResultsUsage(context.Background(), "msgbatch_tags", ep, aggregator, "test")
+
+	var value string
+	if err := tracker.GetDB().QueryRow(`SELECT tag_value FROM request_tags WHERE request_id = ? AND tag_key = 'project'`,
+		tracking.BatchRequestID("msgbatch_tags", "claude-sonnet-4-5")).Scan(&value); err != nil || value != "alpha" {
+		t.Errorf("批处理用量标签 = %q, %v; 期望 alpha", value, err)
+	}
+}
+
+// TestBatchRequestModels 测试解析创建批处理请求中的模型
+func TestBatchRequestModels(t *testing.T) {
+	body := []byte(`{"requests":[{"params":{"model":"claude-opus-4"}},{"params":{"model":"claude-sonnet-4"}},{"params":{"model":"claude-opus-4"}}]}`)
+	if got := BatchRequestModels(body); len(got) != 2 || got[0] != "claude-opus-4" || got[1] != "claude-sonnet-4" {
+		t.Errorf("BatchRequestModels = %v, 期望 [claude-opus-4 claude-sonnet-4]", got)
+	}
+	if got := BatchRequestModels([]byte(`not json`)); len(got) != 1 || got[0] != "" {
+		t.Errorf("无法解析时应返回一个空模型: %v", got)
+	}
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"cc-forwarder/internal/endpoint"
+)
+
+// recordUnsupportedCapability 从上游 400 错误推断端点不支持的能力并记录，返回推断出的能力（未推断出时为空）
+// 后续请求的端点选择会跳过该端点（仅限需要该能力的请求）
+func recordUnsupportedCapability(ctx context.Context, mgr *endpoint.Manager, ep *endpoint.Endpoint, err error) string {
+	if mgr == nil || ep == nil {
+		return ""
+	}
+	upErr, ok := AsUpstreamError(err)
+	if !ok || upErr.StatusCode != 400 {
+		return ""
+	}
+
+	required := endpoint.RequiredCapabilitiesFromContext(ctx)
+	message := upErr.Message
+	if message == "" {
+		message = string(upErr.Body)
+	}
+	if capability, ok := endpoint.InferUnsupportedCapability(message, required); ok {
+		slog.Warn(fmt.Sprintf("🧩 [能力探测] 端点 %s 不支持能力 %s: %s", ep.Config.Name, capability, truncateForReason(message)))
+		mgr.MarkEndpointCapability(ep, capability, false, truncateForReason(message))
+		return capability
+	}
+	return ""
+}
+
+// capabilitySwitchEndpoints 端点被推断为不支持请求所需能力后，按更新后的能力在当前激活渠道内重新筛选端点（不含该端点）
+// 返回非空时本次请求切换端点重试，而不是按 invalid_request_error 直接失败；仅做只读筛选，不触发渠道分流
+func capabilitySwitchEndpoints(ctx context.Context, mgr *endpoint.Manager, ep *endpoint.Endpoint) []*endpoint.Endpoint {
+	var result []*endpoint.Endpoint
+	for _, candidate := range mgr.GetActiveHealthyEndpointsForRequest(ctx) {
+		if candidate != ep {
+			result = append(result, candidate)
+		}
+	}
+	return result
+}
+
+// capabilitySwitchDecision 能力缺失时的切换决策：端点本身可用，不进入冷却
+func capabilitySwitchDecision(capability string) RetryDecision {
+	return RetryDecision{
+		SwitchEndpoint: true,
+		Reason:         fmt.Sprintf("端点不支持能力 %s，切换到支持该能力的端点", capability),
+	}
+}
+
+// recordSupportedCapabilities 请求成功时将本次请求所需能力记录为端点支持
+func recordSupportedCapabilities(ctx context.Context, mgr *endpoint.Manager, ep *endpoint.Endpoint) {
+	if mgr == nil || ep == nil {
+		return
+	}
+	for _, capability := range endpoint.RequiredCapabilitiesFromContext(ctx) {
+		mgr.MarkEndpointCapability(ep, capability, true, "请求成功")
+	}
+}
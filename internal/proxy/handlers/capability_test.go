@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"cc-forwarder/config"
+	"cc-forwarder/internal/endpoint"
+)
+
+// TestCapabilityGapSwitchesEndpoint 测试推断出能力缺失后本次请求切换到其他端点
+func TestCapabilityGapSwitchesEndpoint(t *testing.T) {
+	cfg := &config.Config{
+		Strategy: config.StrategyConfig{Type: "priority"},
+		Endpoints: []config.EndpointConfig{
+			{Name: "a1", URL: "http://example.invalid", Channel: "A", Priority: 1, Timeout: 5 * time.Second},
+			{Name: "a2", URL: "http://example.invalid", Channel: "A", Priority: 2, Timeout: 5 * time.Second},
+			{Name: "b1", URL: "http://example.invalid", Channel: "B", Priority: 3, Timeout: 5 * time.Second},
+		},
+	}
+	manager := endpoint.NewManager(cfg)
+	for _, ep := range manager.GetAllEndpoints() {
+		ep.Status.Healthy = true
+		ep.Status.NeverChecked = false
+	}
+	if err := manager.ManualActivateGroup("A"); err != nil {
+		t.Fatalf("ManualActivateGroup(A) error: %v", err)
+	}
+
+	ctx := endpoint.WithRequiredCapabilities(context.Background(), []string{endpoint.CapabilityWebSearch})
+	upErr := NewUpstreamHTTPError(400, []byte(`{"type":"error","error":{"type":"invalid_request_error","message":"Tool type web_search_20250305 is not supported"}}`))
+
+	a1 := manager.GetEndpointByNameAny(endpoint.EndpointKey("A", "a1"))
+	capability := recordUnsupportedCapability(ctx, manager, a1, upErr)
+	if capability != endpoint.CapabilityWebSearch {
+		t.Fatalf("推断能力 = %q, 期望 %q", capability, endpoint.CapabilityWebSearch)
+	}
+	next := capabilitySwitchEndpoints(ctx, manager, a1)
+	if len(next) != 1 || next[0].Config.Name != "a2" {
+		t.Fatalf("切换候选端点 = %v, 期望 [a2]", next)
+	}
+	if decision := capabilitySwitchDecision(capability); !decision.SwitchEndpoint || decision.RetrySameEndpoint || decision.CooldownEndpoint {
+		t.Errorf("能力缺失应切换端点且不冷却: %+v", decision)
+	}
+
+	// a2 同样不支持：激活渠道内没有可切换端点，沿用原决策透传上游错误
+	a2 := manager.GetEndpointByNameAny(endpoint.EndpointKey("A", "a2"))
+	recordUnsupportedCapability(ctx, manager, a2, upErr)
+	if next := capabilitySwitchEndpoints(ctx, manager, a2); len(next) != 0 {
+		t.Errorf("激活渠道内所有端点均不支持时不应切换, 实际 %v", next)
+	}
+	// 只读检查不应分流到 B 渠道或改变激活渠道
+	if active := manager.GetGroupManager().GetActiveGroups(); len(active) != 1 || active[0].Name != "A" {
+		t.Errorf("只读检查不应改变激活渠道, 实际 %+v", active)
+	}
+}
@@ -79,6 +79,9 @@ type ErrorContext struct {
 	OriginalError  error
 	RetryableAfter time.Duration
 	MaxRetries     int
+	// 上游结构化错误信息（来自错误响应体或 SSE error 事件），用于按错误类型决策
+	UpstreamErrorType  string
+	UpstreamStatusCode int
 }
 
 // ErrorType 错误类型枚举
@@ -100,6 +103,8 @@ const (
 	ErrorTypeParsing                            // 11: 解析错误
 	ErrorTypeClientCancel                       // 12: 客户端取消错误
 	ErrorTypeNoHealthyEndpoints                 // 13: 没有健康端点可用
+	ErrorTypeOverloaded                         // 14: 上游过载（overloaded_error / 529）
+	ErrorTypeFakeSuccess                        // 15: 假成功（2xx 但响应内容被校验器判定为失败）
 )
 
 // StreamIncompleteErrorInterface 流不完整错误接口
@@ -196,6 +201,7 @@ type RetryDecision struct {
 	Delay             time.Duration // 重试延迟时间
 	FinalStatus       string        // 若终止，应记录的最终状态
 	Reason            string        // 决策原因（用于日志）
+	CooldownEndpoint  bool          // 切换端点前是否将当前端点置入冷却（按上游错误类型决策）
 }
 
 // RetryManager 重试管理器接口
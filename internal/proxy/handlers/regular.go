@@ -74,6 +74,8 @@ func getDefaultStatusCodeForFinalStatus(finalStatus string) int {
 		return http.StatusUnauthorized
 	case "rate_limited":
 		return http.StatusTooManyRequests
+	case "overloaded":
+		return 529
 	case "error":
 		return http.StatusBadRequest
 	default:
@@ -103,9 +105,9 @@ func (rh *RegularHandler) HandleRegularRequestUnified(ctx context.Context, w htt
 			errorCtx := errorRecovery.ClassifyError(noHealthyErr, connID, "", "", 0)
 
 			if errorCtx.ErrorType == ErrorTypeNoHealthyEndpoints {
-				// 尝试获取所有活跃端点，忽略健康状态
-				allActiveEndpoints := rh.endpointManager.GetGroupManager().FilterEndpointsByActiveGroups(
-					rh.endpointManager.GetAllEndpoints())
+				// 尝试获取所有活跃端点，忽略健康状态（仍按请求模型、能力与预算筛选）
+				allActiveEndpoints := rh.endpointManager.FilterEndpointsForRequest(ctx,
+					rh.endpointManager.GetGroupManager().FilterEndpointsByActiveGroups(rh.endpointManager.GetAllEndpoints()))
 
 				if len(allActiveEndpoints) > 0 {
 					slog.InfoContext(ctx, fmt.Sprintf("🔄 [健康检查回退] [%s] 忽略健康状态，尝试 %d 个活跃端点",
@@ -162,7 +164,9 @@ func (rh *RegularHandler) HandleRegularRequestUnified(ctx context.Context, w htt
 				globalAttemptCount := lifecycleManager.IncrementAttempt()
 
 				// 执行请求
+				sentAt := time.Now()
 				resp, err := rh.executeRequest(ctx, r, bodyBytes, endpoint)
+				headerAt := time.Now()
 
 				if err == nil && IsSuccessStatus(resp.StatusCode) {
 					// ✅ [重试决策] 成功请求的决策日志 - 保持监控完整性
@@ -170,21 +174,27 @@ func (rh *RegularHandler) HandleRegularRequestUnified(ctx context.Context, w htt
 						connID, endpoint.Config.Name, attempt))
 
 					lifecycleManager.UpdateStatus("processing", globalAttemptCount, resp.StatusCode)
-					rh.processSuccessResponse(ctx, w, resp, lifecycleManager, endpoint.Config.Name, r)
-					return
+					fakeErr := rh.processSuccessResponse(ctx, w, resp, lifecycleManager, endpoint.Config.Name, r, sentAt, headerAt)
+					if fakeErr == nil {
+						recordSupportedCapabilities(ctx, rh.endpointManager, endpoint)
+						return
+					}
+
+					// 🎭 [假成功] 响应未写出，按失败进入重试/故障转移决策（响应体已在处理中关闭）
+					err = fakeErr
+					resp = nil
 				}
 
-				// 构造HTTP状态码错误（保持现有逻辑）
+				// 构造上游错误：读取错误响应体，解析 Anthropic error.type 供分类决策使用
+				capabilityGap := ""
 				if err == nil && resp != nil && !IsSuccessStatus(resp.StatusCode) {
+					errorBody := readUpstreamErrorBody(resp, rh.responseProcessor)
+
 					// 先尝试从HTTP错误中提取Token信息（如果可能）
-					rh.tryExtractTokensFromHttpError(resp, lifecycleManager, endpoint.Config.Name)
+					rh.tryExtractTokensFromHttpError(resp.StatusCode, errorBody, lifecycleManager, endpoint.Config.Name)
 
-					closeErr := resp.Body.Close()
-					if closeErr != nil {
-						slog.Warn(fmt.Sprintf("⚠️ [响应体关闭失败] [%s] 端点: %s, Close错误: %v",
-							connID, endpoint.Config.Name, closeErr))
-					}
-					err = fmt.Errorf("HTTP %d: %s", resp.StatusCode, http.StatusText(resp.StatusCode))
+					err = NewUpstreamHTTPError(resp.StatusCode, errorBody)
+					capabilityGap = recordUnsupportedCapability(ctx, rh.endpointManager, endpoint, err)
 				} else if err != nil && resp != nil {
 					closeErr := resp.Body.Close()
 					if closeErr != nil {
@@ -205,10 +215,23 @@ func (rh *RegularHandler) HandleRegularRequestUnified(ctx context.Context, w htt
 				// localAttempt: 当前端点内的尝试次数，用于退避计算
 				// globalAttemptCount: 全局尝试次数，用于限流策略
 				decision := retryMgr.ShouldRetryWithDecision(&errorCtx, attempt, globalAttemptCount, false) // 常规请求: isStreaming=false
+				// 能力缺失：仍有支持该能力的端点时本次请求切换端点（重新选择端点列表）
+				if capabilityGap != "" && len(capabilitySwitchEndpoints(ctx, rh.endpointManager, endpoint)) > 0 {
+					decision = capabilitySwitchDecision(capabilityGap)
+					groupSwitchNeeded = true
+				}
+
+				// 记录本次尝试轨迹（挂起需先确认满足挂起条件）
+				suspending := decision.SuspendRequest && rh.sharedSuspensionManager.ShouldSuspend(ctx)
+				trace := failedAttempt(lifecycleManager, sentAt, err, &errorCtx, &decision, attempt, retryMgr.GetMaxAttempts())
+				if suspending {
+					trace.Decision = tracking.AttemptDecisionSuspend
+				}
+				recordAttempt(lifecycleManager, trace)
 
 				// 处理挂起决策
 				if decision.SuspendRequest {
-					if rh.sharedSuspensionManager.ShouldSuspend(ctx) {
+					if suspending {
 						// 🚀 [状态机重构] Phase 4: 挂起时更新状态
 						lifecycleManager.UpdateStatus("suspended", globalAttemptCount, 0)
 						slog.Info(fmt.Sprintf("⏸️ [请求挂起] [%s] 原因: %s，失败端点: %s",
@@ -246,11 +269,14 @@ func (rh *RegularHandler) HandleRegularRequestUnified(ctx context.Context, w htt
 
 				if !decision.RetrySameEndpoint {
 					if decision.SwitchEndpoint {
+						if decision.CooldownEndpoint {
+							cooldownFailedEndpoint(rh.endpointManager, endpoint, connID, endpointCooldownReason(&errorCtx))
+						}
 						break // 尝试下一个端点
 					} else {
 						// 🚀 [状态机重构] Phase 4: 最终失败处理
 						// 获取失败原因
-						failureReason := lifecycleManager.MapErrorTypeToFailureReason(errorCtx.ErrorType)
+						failureReason := failureReasonForError(lifecycleManager, errorCtx.ErrorType, err)
 
 						// 获取真实状态码，避免http.Error panic
 						statusCode := GetStatusCodeFromError(err, resp)
@@ -262,7 +288,10 @@ func (rh *RegularHandler) HandleRegularRequestUnified(ctx context.Context, w htt
 
 						// 使用新的FailRequest方法标记最终失败（修复：添加HTTP状态码）
 						lifecycleManager.FailRequest(failureReason, err.Error(), statusCode)
-						http.Error(w, decision.Reason, statusCode)
+						// 上游业务错误（如 invalid_request_error）原样透传，让客户端看到真实错误信息
+						if !writeUpstreamErrorResponse(w, err, statusCode) {
+							http.Error(w, decision.Reason, statusCode)
+						}
 						return
 					}
 				}
@@ -342,7 +371,7 @@ func (rh *RegularHandler) HandleRegularRequestUnified(ctx context.Context, w htt
 
 	// 检查是否应该挂起请求
 	if suspensionMgr.ShouldSuspend(ctx) {
-		currentEndpoints := rh.endpointManager.GetHealthyEndpoints()
+		currentEndpoints := rh.endpointManager.GetHealthyEndpointsForRequest(ctx)
 		if cfg := rh.endpointManager.GetConfig(); cfg != nil && cfg.Strategy.Type == "fastest" && cfg.Strategy.FastTestEnabled {
 			currentEndpoints = rh.endpointManager.GetFastestEndpointsWithRealTimeTest(ctx)
 		}
@@ -367,7 +396,7 @@ func (rh *RegularHandler) HandleRegularRequestUnified(ctx context.Context, w htt
 			if rh.endpointManager.GetConfig().Strategy.Type == "fastest" && rh.endpointManager.GetConfig().Strategy.FastTestEnabled {
 				newEndpoints = rh.endpointManager.GetFastestEndpointsWithRealTimeTest(ctx)
 			} else {
-				newEndpoints = rh.endpointManager.GetHealthyEndpoints()
+				newEndpoints = rh.endpointManager.GetHealthyEndpointsForRequest(ctx)
 			}
 
 			if len(newEndpoints) > 0 {
@@ -438,55 +467,101 @@ func (rh *RegularHandler) executeRequest(ctx context.Context, r *http.Request, b
 }
 
 // processSuccessResponse 处理成功响应
-func (rh *RegularHandler) processSuccessResponse(ctx context.Context, w http.ResponseWriter, resp *http.Response, lifecycleManager RequestLifecycleManager, endpointName string, r *http.Request) {
+// 返回非 nil 表示响应被校验器判定为假成功，此时尚未向客户端写出任何内容
+// sentAt/headerAt 为上游请求发出与响应头到达时刻，用于记录响应时间线
+func (rh *RegularHandler) processSuccessResponse(ctx context.Context, w http.ResponseWriter, resp *http.Response, lifecycleManager RequestLifecycleManager, endpointName string, r *http.Request, sentAt, headerAt time.Time) *FakeSuccessError {
 	defer func() {
 		if err := resp.Body.Close(); err != nil {
 			slog.Warn("Failed to close response body", "request_id", lifecycleManager.GetRequestID(), "error", err)
 		}
 	}()
 
-	// 复制响应头（排除Content-Encoding用于gzip处理）
-	rh.responseProcessor.CopyResponseHeaders(resp, w)
-
-	// 写入状态码
-	w.WriteHeader(resp.StatusCode)
+	connID := lifecycleManager.GetRequestID()
 
-	// 读取并处理响应体
+	// 读取并处理响应体（先读取完整响应体，校验通过后再写出，假成功时可继续故障转移）
 	responseBytes, err := rh.responseProcessor.ProcessResponseBody(resp)
 	if err != nil {
-		connID := lifecycleManager.GetRequestID()
+		// 复制响应头（排除Content-Encoding用于gzip处理）并写入状态码，保持原有行为
+		rh.responseProcessor.CopyResponseHeaders(resp, w)
+		w.WriteHeader(resp.StatusCode)
 		lifecycleManager.HandleError(fmt.Errorf("failed to process response: %w", err))
 		slog.Error("Failed to process response body", "request_id", connID, "error", err)
+		recordAttempt(lifecycleManager, tracking.RequestAttempt{
+			StartTime:     sentAt,
+			HTTPStatus:    resp.StatusCode,
+			ErrorType:     "response_read_error",
+			ErrorMessage:  err.Error(),
+			BytesReceived: int64(len(responseBytes)),
+			Decision:      tracking.AttemptDecisionFail,
+		})
 		// 🔧 [修复] 2025-12-11: 响应体读取失败时必须终结请求，否则会滞留在内存热池
 		lifecycleManager.FailRequest("response_read_error", err.Error(), resp.StatusCode)
-		return
+		return nil
+	}
+
+	// 🔍 [路径过滤] count_tokens端点不需要Token解析
+	isCountTokens := r.URL.Path == "/v1/messages/count_tokens"
+
+	// ✅ 同步Token解析：简化逻辑，避免协程控制问题
+	var tokenUsage *tracking.TokenUsage
+	var modelName string
+	if !isCountTokens {
+		slog.Debug(fmt.Sprintf("🔄 [Token解析] [%s] 开始Token解析", connID))
+		tokenUsage, modelName = rh.tokenAnalyzer.AnalyzeResponseForTokensUnified(responseBytes, connID, endpointName)
 	}
 
+	// 🎭 [假成功检测] 在写出响应前执行响应校验
+	validators := NewResponseValidators(rh.config)
+	fakeErr := validators.ValidateBody(resp.StatusCode, resp.Header.Get("Content-Type"), responseBytes)
+	if fakeErr == nil && !isCountTokens {
+		fakeErr = validators.ValidateTokens(resp.StatusCode, tokenUsage)
+	}
+	if fakeErr != nil {
+		if tokenUsage != nil {
+			if modelName != "" && modelName != "unknown" {
+				lifecycleManager.SetModel(modelName)
+			}
+			lifecycleManager.RecordTokensForFailedRequest(tokenUsage, fakeErr.Validator)
+		}
+		slog.Warn(fmt.Sprintf("🎭 [假成功] [%s] 端点: %s, 状态码: %d, 校验器: %s, 原因: %s",
+			connID, endpointName, resp.StatusCode, fakeErr.Validator, fakeErr.Reason))
+		return fakeErr
+	}
+
+	// 复制响应头（排除Content-Encoding用于gzip处理）
+	rh.responseProcessor.CopyResponseHeaders(resp, w)
+
+	// 写入状态码
+	w.WriteHeader(resp.StatusCode)
+
 	// 写入响应体到客户端
 	if _, err := w.Write(responseBytes); err != nil {
-		connID := lifecycleManager.GetRequestID()
 		lifecycleManager.HandleError(fmt.Errorf("failed to write response: %w", err))
 		slog.Error("Failed to write response to client", "request_id", connID, "error", err)
 		// 🔧 [修复] 2025-12-11: 写入失败时必须终结请求，否则会滞留在内存热池
 		lifecycleManager.FailRequest("response_write_error", err.Error(), resp.StatusCode)
-		return
+		return nil
 	}
 
-	// ✅ 同步Token解析：简化逻辑，避免协程控制问题
-	connID := lifecycleManager.GetRequestID()
-	slog.Debug(fmt.Sprintf("🔄 [Token解析] [%s] 开始Token解析", connID))
-
-	// 🔍 [路径过滤] 跳过count_tokens端点的Token解析
-	if r.URL.Path == "/v1/messages/count_tokens" {
+	// 响应时间线：非流式只有响应头耗时与整体输出速率
+	recordResponseTimings(lifecycleManager, nil, sentAt, headerAt, tokenUsage)
+	if analyzer, ok := rh.tokenAnalyzer.(ResponseMetaAnalyzer); ok && !isCountTokens {
+		recordResponseMeta(lifecycleManager, analyzer.AnalyzeResponseMeta(responseBytes))
+	}
+	recordAttempt(lifecycleManager, tracking.RequestAttempt{
+		StartTime:     sentAt,
+		HTTPStatus:    resp.StatusCode,
+		BytesReceived: int64(len(responseBytes)),
+		Decision:      tracking.AttemptDecisionSuccess,
+	})
+
+	if isCountTokens {
 		slog.Debug(fmt.Sprintf("🔍 [路径过滤] [%s] 跳过count_tokens端点的Token解析", connID))
 		// count_tokens端点不需要Token解析，直接完成请求
 		lifecycleManager.CompleteRequest(nil)
-		return
+		return nil
 	}
 
-	// 对于常规请求，同步解析Token信息（如果存在）
-	tokenUsage, modelName := rh.tokenAnalyzer.AnalyzeResponseForTokensUnified(responseBytes, connID, endpointName)
-
 	// 使用生命周期管理器完成请求
 	if tokenUsage != nil {
 		// 设置模型名称并完成请求
@@ -503,6 +578,7 @@ func (rh *RegularHandler) processSuccessResponse(ctx context.Context, w http.Res
 		slog.Info(fmt.Sprintf("✅ [常规请求完成] [%s] 端点: %s, 响应类型: %s",
 			connID, endpointName, modelName))
 	}
+	return nil
 }
 
 // HandleRegularRequest handles non-streaming requests
@@ -631,29 +707,22 @@ func (rh *RegularHandler) HandleRegularRequest(ctx context.Context, w http.Respo
 }
 
 // tryExtractTokensFromHttpError 尝试从HTTP错误响应中提取Token信息
-// 注意：此方法必须在响应体关闭前调用
-func (rh *RegularHandler) tryExtractTokensFromHttpError(resp *http.Response, lifecycleManager RequestLifecycleManager, endpointName string) {
-	if resp == nil || resp.Body == nil {
+// 响应体由调用方预先读取（见 readUpstreamErrorBody），此处只做解析
+func (rh *RegularHandler) tryExtractTokensFromHttpError(statusCode int, responseBytes []byte, lifecycleManager RequestLifecycleManager, endpointName string) {
+	// ✅ 只对可能包含Token信息的错误码进行解析
+	if statusCode != 429 && statusCode != 413 && statusCode < 500 {
 		return
 	}
-
-	// ✅ 只对可能包含Token信息的错误码进行解析
-	if resp.StatusCode != 429 && resp.StatusCode != 413 && resp.StatusCode < 500 {
+	if len(responseBytes) == 0 {
 		return
 	}
 
-	// ✅ 同步解析，确保在响应体关闭前完成
 	defer func() {
 		if r := recover(); r != nil {
 			slog.Warn(fmt.Sprintf("⚠️ [错误响应解析恢复] 解析过程中出现异常: %v", r))
 		}
 	}()
 
-	responseBytes, err := rh.responseProcessor.ProcessResponseBody(resp)
-	if err != nil || len(responseBytes) == 0 {
-		return
-	}
-
 	tokenUsage, modelName := rh.tokenAnalyzer.AnalyzeResponseForTokensUnified(responseBytes, lifecycleManager.GetRequestID(), endpointName)
 	if tokenUsage != nil {
 		// ✅ 修复：将解析到的模型信息设置到生命周期管理器
@@ -661,8 +730,8 @@ func (rh *RegularHandler) tryExtractTokensFromHttpError(resp *http.Response, lif
 			lifecycleManager.SetModel(modelName)
 		}
 
-		lifecycleManager.RecordTokensForFailedRequest(tokenUsage, fmt.Sprintf("http_%d", resp.StatusCode))
+		lifecycleManager.RecordTokensForFailedRequest(tokenUsage, fmt.Sprintf("http_%d", statusCode))
 		slog.Info(fmt.Sprintf("💾 [HTTP错误Token记录] [%s] 端点: %s, 状态码: %d, 模型: %s",
-			lifecycleManager.GetRequestID(), endpointName, resp.StatusCode, modelName))
+			lifecycleManager.GetRequestID(), endpointName, statusCode, modelName))
 	}
 }
@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"cc-forwarder/internal/tracking"
+)
+
+// ResponseMetaRecorder 可选接口：生命周期管理器记录上游响应元信息
+type ResponseMetaRecorder interface {
+	SetResponseMeta(meta tracking.ResponseMeta)
+}
+
+// ResponseMetaReporter 可选接口：流式处理器报告已解析的响应元信息
+type ResponseMetaReporter interface {
+	ResponseMeta() tracking.ResponseMeta
+}
+
+// ResponseMetaAnalyzer 可选接口：Token 分析器从完整响应中解析元信息
+type ResponseMetaAnalyzer interface {
+	AnalyzeResponseMeta(responseBytes []byte) tracking.ResponseMeta
+}
+
+// recordStreamResponseMeta 记录流式处理器解析到的响应元信息（需在完成/失败请求前调用）
+func recordStreamResponseMeta(lifecycleManager RequestLifecycleManager, processor interface{}) {
+	reporter, ok := processor.(ResponseMetaReporter)
+	if !ok {
+		return
+	}
+	recordResponseMeta(lifecycleManager, reporter.ResponseMeta())
+}
+
+// recordResponseMeta 记录响应元信息（停止原因、消息 ID、工具调用与 thinking 内容块）
+func recordResponseMeta(lifecycleManager RequestLifecycleManager, meta tracking.ResponseMeta) {
+	recorder, ok := lifecycleManager.(ResponseMetaRecorder)
+	if !ok || meta.IsZero() {
+		return
+	}
+	recorder.SetResponseMeta(meta)
+}
@@ -0,0 +1,230 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"cc-forwarder/config"
+	"cc-forwarder/internal/tracking"
+)
+
+// 响应校验器名称（同时作为校验失败时记录的 failure_reason）
+const (
+	ValidatorErrorJSON          = "error_json"           // 2xx 响应体实为错误 JSON / SSE error 事件
+	ValidatorHTMLPage           = "html_page"            // 2xx 响应体为 HTML 错误页
+	ValidatorMissingMessageStop = "missing_message_stop" // 流式响应缺少 message_stop
+	ValidatorZeroOutputTokens   = "zero_output_tokens"   // 完成结果输出 Token 为 0
+)
+
+// FakeSuccessError 中转站"假成功"错误
+// 上游返回 2xx，但响应内容被校验器判定为失败
+type FakeSuccessError struct {
+	Validator  string // 命中的校验器名称
+	Reason     string // 判定原因（用于日志与错误详情）
+	StatusCode int    // 上游返回的 HTTP 状态码
+}
+
+// Error 实现 error 接口
+func (e *FakeSuccessError) Error() string {
+	return fmt.Sprintf("假成功响应 [%s]: %s", e.Validator, e.Reason)
+}
+
+// AsFakeSuccessError 从错误链中提取 FakeSuccessError
+func AsFakeSuccessError(err error) (*FakeSuccessError, bool) {
+	var fakeErr *FakeSuccessError
+	if err != nil && errors.As(err, &fakeErr) {
+		return fakeErr, true
+	}
+	return nil, false
+}
+
+// ResponseValidators 按配置启用的响应校验器集合
+// 未启用响应校验时为 nil，nil 上的所有校验方法均直接通过
+type ResponseValidators struct {
+	enabled map[string]bool
+}
+
+// NewResponseValidators 根据配置创建响应校验器集合
+func NewResponseValidators(cfg *config.Config) *ResponseValidators {
+	if cfg == nil || !cfg.ResponseValidation.Enabled || len(cfg.ResponseValidation.Validators) == 0 {
+		return nil
+	}
+
+	enabled := make(map[string]bool, len(cfg.ResponseValidation.Validators))
+	for _, name := range cfg.ResponseValidation.Validators {
+		enabled[name] = true
+	}
+	return &ResponseValidators{enabled: enabled}
+}
+
+// Has 检查指定校验器是否启用
+func (v *ResponseValidators) Has(name string) bool {
+	return v != nil && v.enabled[name]
+}
+
+// only 返回仅保留指定校验器（且已启用）的子集，均未启用时返回 nil
+func (v *ResponseValidators) only(names ...string) *ResponseValidators {
+	enabled := make(map[string]bool, len(names))
+	for _, name := range names {
+		if v.Has(name) {
+			enabled[name] = true
+		}
+	}
+	if len(enabled) == 0 {
+		return nil
+	}
+	return &ResponseValidators{enabled: enabled}
+}
+
+// ValidateBody 校验完整的 2xx 响应体（已解压）
+// 常规请求在写出响应前调用；流式请求在上游未返回 SSE 时于提交前调用（SSE 响应见 ValidateFirstEvent）
+func (v *ResponseValidators) ValidateBody(statusCode int, contentType string, body []byte) *FakeSuccessError {
+	if v == nil {
+		return nil
+	}
+
+	trimmed := strings.TrimSpace(string(body))
+	fail := func(name, reason string) *FakeSuccessError {
+		return &FakeSuccessError{Validator: name, Reason: reason, StatusCode: statusCode}
+	}
+
+	if v.Has(ValidatorHTMLPage) && isHTMLBody(contentType, trimmed) {
+		return fail(ValidatorHTMLPage, "响应体为 HTML 页面")
+	}
+
+	isSSE := strings.HasPrefix(trimmed, "event:") || strings.HasPrefix(trimmed, "data:")
+	if isSSE {
+		if v.Has(ValidatorErrorJSON) && strings.Contains(trimmed, "event: error") {
+			return fail(ValidatorErrorJSON, "流式响应包含 error 事件")
+		}
+		if v.Has(ValidatorMissingMessageStop) && strings.Contains(trimmed, "message_start") &&
+			!strings.Contains(trimmed, "message_stop") {
+			return fail(ValidatorMissingMessageStop, "流式响应缺少 message_stop 事件")
+		}
+		return nil
+	}
+
+	if v.Has(ValidatorErrorJSON) {
+		if reason, ok := detectErrorJSON(trimmed); ok {
+			return fail(ValidatorErrorJSON, reason)
+		}
+	}
+	return nil
+}
+
+// ValidateFirstEvent 校验 SSE 响应的首个事件（流式请求提交响应前调用）
+// 中转站常以 200 + event: error 返回失败，首个事件为 error 时判定为假成功
+func (v *ResponseValidators) ValidateFirstEvent(statusCode int, event []byte) *FakeSuccessError {
+	if !v.Has(ValidatorErrorJSON) {
+		return nil
+	}
+
+	// 只看首个事件（读取的前缀可能包含后续事件）
+	text := strings.TrimLeft(strings.ReplaceAll(string(event), "\r\n", "\n"), "\n")
+	if idx := strings.Index(text, "\n\n"); idx >= 0 {
+		text = text[:idx]
+	}
+
+	isError := false
+	var dataLines []string
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "event:"):
+			isError = isError || strings.TrimSpace(strings.TrimPrefix(line, "event:")) == "error"
+		case strings.HasPrefix(line, "data:"):
+			dataLines = append(dataLines, strings.TrimSpace(strings.TrimPrefix(line, "data:")))
+		}
+	}
+	data := strings.Join(dataLines, "\n")
+
+	var payload struct {
+		Type string `json:"type"`
+	}
+	if data != "" && json.Unmarshal([]byte(data), &payload) == nil && payload.Type == "error" {
+		isError = true
+	}
+	if !isError {
+		return nil
+	}
+
+	reason := "流式响应首个事件为 error 事件"
+	if errType, message, _, ok := ParseUpstreamErrorBody([]byte(data)); ok {
+		reason = fmt.Sprintf("%s %s: %s", reason, errType, message)
+	}
+	return &FakeSuccessError{Validator: ValidatorErrorJSON, Reason: reason, StatusCode: statusCode}
+}
+
+// ValidateTokens 校验完成结果的 Token 使用情况
+// 上游已返回完整消息但输出 Token 为 0，通常是中转站吞掉了实际内容
+func (v *ResponseValidators) ValidateTokens(statusCode int, tokens *tracking.TokenUsage) *FakeSuccessError {
+	if !v.Has(ValidatorZeroOutputTokens) || tokens == nil || tokens.OutputTokens > 0 {
+		return nil
+	}
+	return &FakeSuccessError{
+		Validator:  ValidatorZeroOutputTokens,
+		Reason:     fmt.Sprintf("输出 Token 为 0（输入: %d）", tokens.InputTokens),
+		StatusCode: statusCode,
+	}
+}
+
+// isHTMLBody 判断响应体是否为 HTML 页面
+func isHTMLBody(contentType, trimmed string) bool {
+	if strings.Contains(strings.ToLower(contentType), "text/html") {
+		return true
+	}
+	lower := strings.ToLower(trimmed)
+	return strings.HasPrefix(lower, "<!doctype html") || strings.HasPrefix(lower, "<html")
+}
+
+// detectErrorJSON 判断 JSON 响应体是否为错误对象
+// 识别 Anthropic 格式 {"type":"error",...} 及常见中转站格式 {"error":...}（非 message 类型）
+func detectErrorJSON(trimmed string) (string, bool) {
+	if trimmed == "" || trimmed[0] != '{' {
+		return "", false
+	}
+
+	var payload map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(trimmed), &payload); err != nil {
+		return "", false
+	}
+
+	var msgType string
+	if raw, ok := payload["type"]; ok {
+		_ = json.Unmarshal(raw, &msgType)
+	}
+	if msgType == "message" {
+		return "", false
+	}
+
+	if errType, message, _, ok := ParseUpstreamErrorBody([]byte(trimmed)); ok {
+		return fmt.Sprintf("响应体为错误对象 %s: %s", errType, message), true
+	}
+	if msgType == "error" {
+		return "响应体为错误对象", true
+	}
+	if raw, ok := payload["error"]; ok && string(raw) != "null" {
+		return fmt.Sprintf("响应体包含 error 字段: %s", truncateForReason(string(raw))), true
+	}
+	return "", false
+}
+
+// truncateForReason 截断过长的判定原因
+func truncateForReason(s string) string {
+	const maxLen = 200
+	if len(s) > maxLen {
+		return s[:maxLen] + "..."
+	}
+	return s
+}
+
+// failureReasonForError 获取错误对应的 failure_reason
+// 假成功错误记录命中的校验器名称，其余按错误类型映射
+func failureReasonForError(lifecycleManager RequestLifecycleManager, errorType ErrorType, err error) string {
+	if fakeErr, ok := AsFakeSuccessError(err); ok {
+		return fakeErr.Validator
+	}
+	return lifecycleManager.MapErrorTypeToFailureReason(errorType)
+}
@@ -0,0 +1,259 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"cc-forwarder/config"
+	"cc-forwarder/internal/tracking"
+)
+
+func newTestValidators(names ...string) *ResponseValidators {
+	cfg := &config.Config{}
+	cfg.ResponseValidation.Enabled = true
+	cfg.ResponseValidation.Validators = names
+	return NewResponseValidators(cfg)
+}
+
+func TestNewResponseValidators_Disabled(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.ResponseValidation.Validators = []string{ValidatorErrorJSON}
+
+	validators := NewResponseValidators(cfg)
+	if validators != nil {
+		t.Fatal("未启用响应校验时应返回 nil")
+	}
+	// nil 校验器集合上的校验应直接通过
+	if fakeErr := validators.ValidateBody(200, "application/json", []byte(`{"type":"error"}`)); fakeErr != nil {
+		t.Errorf("nil 校验器不应判定失败: %v", fakeErr)
+	}
+	if fakeErr := validators.ValidateTokens(200, &tracking.TokenUsage{}); fakeErr != nil {
+		t.Errorf("nil 校验器不应判定失败: %v", fakeErr)
+	}
+}
+
+func TestResponseValidators_ValidateBody(t *testing.T) {
+	validators := newTestValidators(ValidatorErrorJSON, ValidatorHTMLPage, ValidatorMissingMessageStop, ValidatorZeroOutputTokens)
+
+	testCases := []struct {
+		name        string
+		contentType string
+		body        string
+		expected    string // 期望命中的校验器，空表示通过
+	}{
+		{"正常消息", "application/json", `{"id":"msg_1","type":"message","content":[{"type":"text","text":"hi"}],"usage":{"input_tokens":5,"output_tokens":3}}`, ""},
+		{"count_tokens 响应", "application/json", `{"input_tokens":42}`, ""},
+		{"Anthropic 错误对象", "application/json", `{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`, ValidatorErrorJSON},
+		{"中转站错误格式", "application/json", `{"error":{"message":"余额不足","code":"insufficient_quota"}}`, ValidatorErrorJSON},
+		{"error 字段为 null", "application/json", `{"data":[],"error":null}`, ""},
+		{"HTML 错误页", "text/plain", "<!DOCTYPE html><html><body>502</body></html>", ValidatorHTMLPage},
+		{"HTML Content-Type", "text/html; charset=utf-8", "Service Unavailable", ValidatorHTMLPage},
+		{"完整 SSE", "text/event-stream", "event: message_start\ndata: {}\n\nevent: message_stop\ndata: {}\n\n", ""},
+		{"SSE 缺少 message_stop", "text/event-stream", "event: message_start\ndata: {}\n\nevent: content_block_delta\ndata: {}\n\n", ValidatorMissingMessageStop},
+		{"SSE error 事件", "text/event-stream", "event: error\ndata: {\"type\":\"error\",\"error\":{\"type\":\"api_error\"}}\n\n", ValidatorErrorJSON},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeErr := validators.ValidateBody(200, tc.contentType, []byte(tc.body))
+			if tc.expected == "" {
+				if fakeErr != nil {
+					t.Errorf("不应判定为假成功，实际命中: %s (%s)", fakeErr.Validator, fakeErr.Reason)
+				}
+				return
+			}
+			if fakeErr == nil {
+				t.Fatalf("应命中校验器 %s", tc.expected)
+			}
+			if fakeErr.Validator != tc.expected {
+				t.Errorf("命中校验器 = %s, 期望 %s", fakeErr.Validator, tc.expected)
+			}
+		})
+	}
+}
+
+func TestResponseValidators_OnlyEnabledValidatorsRun(t *testing.T) {
+	validators := newTestValidators(ValidatorHTMLPage)
+
+	if fakeErr := validators.ValidateBody(200, "application/json", []byte(`{"type":"error","error":{"type":"api_error","message":"x"}}`)); fakeErr != nil {
+		t.Errorf("未启用 error_json 时不应判定失败: %v", fakeErr)
+	}
+	if fakeErr := validators.ValidateTokens(200, &tracking.TokenUsage{InputTokens: 10}); fakeErr != nil {
+		t.Errorf("未启用 zero_output_tokens 时不应判定失败: %v", fakeErr)
+	}
+}
+
+func TestResponseValidators_ValidateTokens(t *testing.T) {
+	validators := newTestValidators(ValidatorZeroOutputTokens)
+
+	if fakeErr := validators.ValidateTokens(200, &tracking.TokenUsage{InputTokens: 10, OutputTokens: 0}); fakeErr == nil || fakeErr.Validator != ValidatorZeroOutputTokens {
+		t.Errorf("输出 Token 为 0 应命中 zero_output_tokens, 实际: %v", fakeErr)
+	}
+	if fakeErr := validators.ValidateTokens(200, &tracking.TokenUsage{InputTokens: 10, OutputTokens: 1}); fakeErr != nil {
+		t.Errorf("有输出时不应判定失败: %v", fakeErr)
+	}
+	if fakeErr := validators.ValidateTokens(200, nil); fakeErr != nil {
+		t.Errorf("无 Token 信息时不应判定失败: %v", fakeErr)
+	}
+}
+
+func TestValidateStreamingResponseBeforeCommit(t *testing.T) {
+	validators := newTestValidators(ValidatorErrorJSON, ValidatorHTMLPage)
+
+	// 正常 SSE 响应：读取的首个事件拼回响应体
+	sseBody := "event: message_start\ndata: {}\n\n"
+	sseResp := &http.Response{
+		StatusCode: 200,
+		Header:     http.Header{"Content-Type": []string{"text/event-stream"}},
+		Body:       io.NopCloser(strings.NewReader(sseBody)),
+	}
+	if fakeErr := validateStreamingResponseBeforeCommit(sseResp, validators); fakeErr != nil {
+		t.Fatalf("SSE 响应不应在提交前判定失败: %v", fakeErr)
+	}
+	if data, _ := io.ReadAll(sseResp.Body); string(data) != sseBody {
+		t.Errorf("SSE 响应体未正确恢复, 实际: %q", string(data))
+	}
+
+	// 200 + 错误 JSON
+	errResp := &http.Response{
+		StatusCode: 200,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(`{"type":"error","error":{"type":"api_error","message":"boom"}}`)),
+	}
+	fakeErr := validateStreamingResponseBeforeCommit(errResp, validators)
+	if fakeErr == nil || fakeErr.Validator != ValidatorErrorJSON {
+		t.Fatalf("200 + 错误 JSON 应命中 error_json, 实际: %v", fakeErr)
+	}
+
+	// 非 SSE 但内容正常：响应体需恢复供后续转发
+	okBody := `{"type":"message","content":[]}`
+	okResp := &http.Response{
+		StatusCode: 200,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(okBody)),
+	}
+	if fakeErr := validateStreamingResponseBeforeCommit(okResp, validators); fakeErr != nil {
+		t.Fatalf("正常响应不应判定失败: %v", fakeErr)
+	}
+	if data, _ := io.ReadAll(okResp.Body); string(data) != okBody {
+		t.Errorf("响应体未正确恢复: %q", string(data))
+	}
+}
+
+// trackingBody 记录读取字节数与关闭状态的响应体
+type trackingBody struct {
+	io.Reader
+	read   int
+	closed bool
+}
+
+func (b *trackingBody) Read(p []byte) (int, error) {
+	n, err := b.Reader.Read(p)
+	b.read += n
+	return n, err
+}
+
+func (b *trackingBody) Close() error {
+	b.closed = true
+	return nil
+}
+
+func TestValidateStreamingResponseBeforeCommit_LargeBody(t *testing.T) {
+	validators := newTestValidators(ValidatorErrorJSON, ValidatorHTMLPage, ValidatorMissingMessageStop)
+
+	// 非 SSE Content-Type 的大响应：只读取有限前缀，完整响应体拼回供后续转发
+	largeBody := "event: message_start\ndata: {}\n\n" + strings.Repeat("data: {\"type\":\"ping\"}\n\n", 4096) + "event: message_stop\ndata: {}\n\n"
+	body := &trackingBody{Reader: strings.NewReader(largeBody)}
+	resp := &http.Response{
+		StatusCode: 200,
+		Header:     http.Header{"Content-Type": []string{"application/octet-stream"}},
+		Body:       body,
+	}
+	if fakeErr := validateStreamingResponseBeforeCommit(resp, validators); fakeErr != nil {
+		t.Fatalf("截断的前缀不应判定缺少 message_stop: %v", fakeErr)
+	}
+	if body.read > streamingValidationPeekLimit+1 {
+		t.Errorf("提交前读取了 %d 字节, 上限 %d", body.read, streamingValidationPeekLimit+1)
+	}
+	if data, _ := io.ReadAll(resp.Body); string(data) != largeBody {
+		t.Errorf("响应体未完整恢复: 长度 %d, 期望 %d", len(data), len(largeBody))
+	}
+	resp.Body.Close()
+	if !body.closed {
+		t.Error("恢复后的响应体应关闭原始响应体")
+	}
+
+	// 大 HTML 错误页：前缀即可判定，命中时关闭响应体
+	htmlBody := &trackingBody{Reader: strings.NewReader("<!DOCTYPE html><html>" + strings.Repeat("x", 64<<10) + "</html>")}
+	htmlResp := &http.Response{
+		StatusCode: 200,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       htmlBody,
+	}
+	fakeErr := validateStreamingResponseBeforeCommit(htmlResp, validators)
+	if fakeErr == nil || fakeErr.Validator != ValidatorHTMLPage {
+		t.Fatalf("大 HTML 错误页应命中 html_page, 实际: %v", fakeErr)
+	}
+	if !htmlBody.closed {
+		t.Error("命中校验器时应关闭响应体")
+	}
+}
+
+func TestValidateStreamingResponseBeforeCommit_SSEFirstEvent(t *testing.T) {
+	validators := newTestValidators(ValidatorErrorJSON)
+
+	tests := []struct {
+		name    string
+		body    string
+		wantErr bool
+	}{
+		{"event: error 开头", "event: error\ndata: {\"type\":\"error\",\"error\":{\"type\":\"overloaded_error\",\"message\":\"busy\"}}\n\n", true},
+		{"仅 data 行的 error", "\r\ndata: {\"type\":\"error\",\"error\":{\"type\":\"api_error\",\"message\":\"boom\"}}\r\n\r\n", true},
+		{"正常首个事件后出现 error", "event: message_start\ndata: {\"type\":\"message_start\"}\n\nevent: error\ndata: {\"type\":\"error\"}\n\n", false},
+		{"正常流", "event: message_start\ndata: {\"type\":\"message_start\"}\n\n", false},
+	}
+	for _, tt := range tests {
+		body := &trackingBody{Reader: strings.NewReader(tt.body)}
+		resp := &http.Response{
+			StatusCode: 200,
+			Header:     http.Header{"Content-Type": []string{"text/event-stream"}},
+			Body:       body,
+		}
+		fakeErr := validateStreamingResponseBeforeCommit(resp, validators)
+		if tt.wantErr {
+			if fakeErr == nil || fakeErr.Validator != ValidatorErrorJSON {
+				t.Errorf("%s: 应命中 error_json, 实际: %v", tt.name, fakeErr)
+			}
+			if !body.closed {
+				t.Errorf("%s: 命中校验器时应关闭响应体", tt.name)
+			}
+			continue
+		}
+		if fakeErr != nil {
+			t.Errorf("%s: 不应判定失败: %v", tt.name, fakeErr)
+		}
+		if data, _ := io.ReadAll(resp.Body); string(data) != tt.body {
+			t.Errorf("%s: 响应体未完整恢复: %q", tt.name, string(data))
+		}
+	}
+
+	// 只读到首个事件结束，不等待后续流
+	largeBody := "event: message_start\ndata: {}\n\n" + strings.Repeat("data: {\"type\":\"ping\"}\n\n", 4096)
+	body := &trackingBody{Reader: strings.NewReader(largeBody)}
+	resp := &http.Response{
+		StatusCode: 200,
+		Header:     http.Header{"Content-Type": []string{"text/event-stream"}},
+		Body:       body,
+	}
+	if fakeErr := validateStreamingResponseBeforeCommit(resp, validators); fakeErr != nil {
+		t.Fatalf("正常流不应判定失败: %v", fakeErr)
+	}
+	if body.read > streamingValidationChunkSize {
+		t.Errorf("提交前读取了 %d 字节, 应只读取首个事件", body.read)
+	}
+	if data, _ := io.ReadAll(resp.Body); string(data) != largeBody {
+		t.Errorf("响应体未完整恢复: 长度 %d, 期望 %d", len(data), len(largeBody))
+	}
+}
@@ -1,8 +1,10 @@
 package handlers
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
 	"strings"
@@ -10,6 +12,7 @@ import (
 
 	"cc-forwarder/config"
 	"cc-forwarder/internal/endpoint"
+	"cc-forwarder/internal/proxy/response"
 	"cc-forwarder/internal/tracking"
 
 	"github.com/google/uuid"
@@ -79,6 +82,145 @@ func sendAnthropicRetryableError(w http.ResponseWriter, flusher http.Flusher, me
 	flusher.Flush()
 }
 
+// streamingValidationPeekLimit 流式请求提交前校验读取的响应体前缀上限
+const streamingValidationPeekLimit = 8 << 10
+
+// streamingValidationChunkSize 读取 SSE 首个事件时每次读取的字节数
+const streamingValidationChunkSize = 512
+
+// validateStreamingResponseBeforeCommit 流式请求提交响应前的假成功校验
+// 上游返回 SSE 时只读取首个事件（最多 streamingValidationPeekLimit）校验是否为 error 事件；
+// 上游未返回 SSE（如 200 + 错误 JSON / HTML 页面）时读取响应体前缀（最多 streamingValidationPeekLimit）校验；
+// 未命中时恢复响应体供后续转发并返回 nil，命中时关闭响应体
+func validateStreamingResponseBeforeCommit(resp *http.Response, validators *ResponseValidators) *FakeSuccessError {
+	if validators == nil || resp == nil || resp.Body == nil {
+		return nil
+	}
+	if strings.Contains(strings.ToLower(resp.Header.Get("Content-Type")), "text/event-stream") {
+		return validateFirstSSEEventBeforeCommit(resp, validators)
+	}
+
+	// 只读取有限前缀：错误 JSON / HTML 错误页通常很小，避免把大响应整体读入内存
+	// 读取的前缀拼回剩余响应体，供后续流式处理继续读取
+	raw, readErr := io.ReadAll(io.LimitReader(resp.Body, streamingValidationPeekLimit+1))
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(raw), resp.Body), resp.Body}
+	if readErr != nil {
+		// 读取失败交由后续流式处理按原有逻辑报告
+		return nil
+	}
+
+	if len(raw) > streamingValidationPeekLimit {
+		// 响应体超出前缀上限：只做不依赖完整响应体的 HTML 错误页校验
+		validators = validators.only(ValidatorHTMLPage)
+		if validators == nil {
+			return nil
+		}
+	}
+
+	// 校验前解压（不改变原始响应体，转发时仍由流式处理器负责解压）
+	body, err := response.NewProcessor().ProcessResponseBody(&http.Response{
+		Header: resp.Header,
+		Body:   io.NopCloser(bytes.NewReader(raw)),
+	})
+	if err != nil {
+		body = raw
+	}
+
+	if fakeErr := validators.ValidateBody(resp.StatusCode, resp.Header.Get("Content-Type"), body); fakeErr != nil {
+		resp.Body.Close()
+		return fakeErr
+	}
+	return nil
+}
+
+// validateFirstSSEEventBeforeCommit 读取 SSE 首个事件，上游以 error 事件开头（200 + event: error）时判定为假成功
+// 只读到首个事件结束，不等待后续事件；压缩的 SSE 无法按前缀解析，直接放行
+func validateFirstSSEEventBeforeCommit(resp *http.Response, validators *ResponseValidators) *FakeSuccessError {
+	if !validators.Has(ValidatorErrorJSON) {
+		return nil
+	}
+	if encoding := strings.ToLower(resp.Header.Get("Content-Encoding")); encoding != "" && encoding != "identity" {
+		return nil
+	}
+
+	raw, readErr := readFirstSSEEvent(resp.Body, streamingValidationPeekLimit)
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(raw), resp.Body), resp.Body}
+	if readErr != nil && readErr != io.EOF {
+		// 读取失败交由后续流式处理按原有逻辑报告
+		return nil
+	}
+
+	if fakeErr := validators.ValidateFirstEvent(resp.StatusCode, raw); fakeErr != nil {
+		resp.Body.Close()
+		return fakeErr
+	}
+	return nil
+}
+
+// readFirstSSEEvent 读取响应体直到首个 SSE 事件结束（空行）、达到 limit 或读取出错
+func readFirstSSEEvent(body io.Reader, limit int) ([]byte, error) {
+	buf := make([]byte, 0, streamingValidationChunkSize)
+	chunk := make([]byte, streamingValidationChunkSize)
+	for len(buf) < limit {
+		size := len(chunk)
+		if remaining := limit - len(buf); remaining < size {
+			size = remaining
+		}
+		n, err := body.Read(chunk[:size])
+		buf = append(buf, chunk[:n]...)
+		if sseEventComplete(buf) {
+			return buf, nil
+		}
+		if err != nil {
+			return buf, err
+		}
+	}
+	return buf, nil
+}
+
+// sseEventComplete 是否已读到完整的首个 SSE 事件（忽略开头的空行）
+func sseEventComplete(buf []byte) bool {
+	trimmed := bytes.TrimLeft(buf, "\r\n")
+	return bytes.Contains(trimmed, []byte("\n\n")) || bytes.Contains(trimmed, []byte("\r\n\r\n"))
+}
+
+// failCommittedStream 已提交的流式响应被判定为假成功
+// 响应已写出无法再故障转移，记录失败（failure_reason 为校验器名称）并冷却端点
+func (sh *StreamingHandler) failCommittedStream(lifecycleManager RequestLifecycleManager, ep *endpoint.Endpoint, r *http.Request,
+	statusCode int, tokens *tracking.TokenUsage, modelName string, fakeErr *FakeSuccessError) {
+	connID := lifecycleManager.GetRequestID()
+
+	if modelName != "unknown" && modelName != "" {
+		lifecycleManager.SetModelWithComparison(modelName, "流式响应解析")
+	}
+	if tokens != nil {
+		lifecycleManager.RecordTokensForFailedRequest(tokens, fakeErr.Validator)
+	}
+	lifecycleManager.FailRequest(fakeErr.Validator, fakeErr.Error(), statusCode)
+	*r = *r.WithContext(context.WithValue(r.Context(), "final_status_code", statusCode))
+
+	slog.Warn(fmt.Sprintf("🎭 [假成功] [%s] 端点: %s, 校验器: %s, 原因: %s (响应已提交，无法故障转移)",
+		connID, ep.Config.Name, fakeErr.Validator, fakeErr.Reason))
+	cooldownFailedEndpoint(sh.endpointManager, ep, connID, "fake_success_"+fakeErr.Validator)
+}
+
+// sendAnthropicError 发送 Anthropic API 标准格式的 error 事件，保留上游 error.type
+func sendAnthropicError(w http.ResponseWriter, flusher http.Flusher, errType, message string) {
+	if message == "" {
+		message = errType
+	}
+	fmt.Fprintf(w, "event: error\n")
+	fmt.Fprintf(w, "data: {\"type\":\"error\",\"error\":{\"type\":\"%s\",\"message\":\"%s\"}}\n\n",
+		escapeJSONString(errType), escapeJSONString(message))
+	flusher.Flush()
+}
+
 // sendStreamInterruptedMessage 发送流中断消息，触发客户端自动重试
 // 模拟上游代理的行为：当 EOF 发生时，发送一个完整的新消息（第二个 message_start）
 // Claude Code 会识别这种模式并自动重试请求
@@ -190,7 +332,7 @@ func (sh *StreamingHandler) executeStreamingWithRetry(ctx context.Context, w htt
 	if sh.endpointManager.GetConfig().Strategy.Type == "fastest" && sh.endpointManager.GetConfig().Strategy.FastTestEnabled {
 		endpoints = sh.endpointManager.GetFastestEndpointsWithRealTimeTest(ctx)
 	} else {
-		endpoints = sh.endpointManager.GetHealthyEndpoints()
+		endpoints = sh.endpointManager.GetHealthyEndpointsForRequest(ctx)
 	}
 
 	if len(endpoints) == 0 {
@@ -200,9 +342,9 @@ func (sh *StreamingHandler) executeStreamingWithRetry(ctx context.Context, w htt
 		errorCtx := errorRecovery.ClassifyError(noHealthyErr, connID, "", "", 0)
 
 		if errorCtx.ErrorType == ErrorTypeNoHealthyEndpoints {
-			// 尝试获取所有活跃端点，忽略健康状态
-			allActiveEndpoints := sh.endpointManager.GetGroupManager().FilterEndpointsByActiveGroups(
-				sh.endpointManager.GetAllEndpoints())
+			// 尝试获取所有活跃端点，忽略健康状态（仍按请求模型、能力与预算筛选）
+			allActiveEndpoints := sh.endpointManager.FilterEndpointsForRequest(ctx,
+				sh.endpointManager.GetGroupManager().FilterEndpointsByActiveGroups(sh.endpointManager.GetAllEndpoints()))
 
 			if len(allActiveEndpoints) > 0 {
 				slog.InfoContext(ctx, fmt.Sprintf("🔄 [健康检查回退] [%s] 忽略健康状态，尝试 %d 个活跃端点",
@@ -273,9 +415,24 @@ func (sh *StreamingHandler) executeStreamingWithRetry(ctx context.Context, w htt
 			}
 
 			// 尝试连接端点
+			sentAt := time.Now()
 			resp, err := sh.forwarder.ForwardRequestToEndpoint(ctx, r, bodyBytes, ep)
+			headerAt := time.Now()
 			// 🔧 [修复] 保存最后的响应，用于获取真实HTTP状态码
 			lastResp = resp
+
+			// 🎭 [假成功检测] 在提交响应前校验 SSE 首个事件或非 SSE 响应体，命中则按失败进入故障转移
+			validators := NewResponseValidators(sh.config)
+			if err == nil && IsSuccessStatus(resp.StatusCode) {
+				if fakeErr := validateStreamingResponseBeforeCommit(resp, validators); fakeErr != nil {
+					slog.Warn(fmt.Sprintf("🎭 [假成功] [%s] 端点: %s, 状态码: %d, 校验器: %s, 原因: %s",
+						connID, ep.Config.Name, resp.StatusCode, fakeErr.Validator, fakeErr.Reason))
+					err = fakeErr
+					resp = nil
+					lastResp = nil
+				}
+			}
+
 			if err == nil && IsSuccessStatus(resp.StatusCode) {
 				// 🔢 [成功计数] 成功的尝试记录到生命周期管理器
 				lifecycleManager.IncrementAttempt()
@@ -303,9 +460,22 @@ func (sh *StreamingHandler) executeStreamingWithRetry(ctx context.Context, w htt
 
 				// 执行流式处理并获取Token信息和模型名称
 				finalTokenUsage, modelName, err := processor.ProcessStreamWithRetry(ctx, resp)
+				recordResponseTimings(lifecycleManager, processor, sentAt, headerAt, finalTokenUsage)
+				recordStreamResponseMeta(lifecycleManager, processor)
+				recordAttempt(lifecycleManager, committedStreamAttempt(sentAt, resp.StatusCode, processor, finalTokenUsage, err, validators))
 				if err != nil {
 					// 🔧 [结构化错误处理] 2025-12-11: 优先使用接口断言处理流不完整错误
 					if streamErr, ok := err.(StreamIncompleteErrorInterface); ok {
+						// 🎭 [假成功] 启用 missing_message_stop 校验时，流不完整按失败处理
+						if validators.Has(ValidatorMissingMessageStop) {
+							sh.failCommittedStream(lifecycleManager, ep, r, resp.StatusCode, finalTokenUsage, streamErr.GetModelName(), &FakeSuccessError{
+								Validator:  ValidatorMissingMessageStop,
+								Reason:     streamErr.GetReason(),
+								StatusCode: resp.StatusCode,
+							})
+							return
+						}
+
 						// 流不完整但请求已完成，需要标记 failure_reason
 						parsedModelName := streamErr.GetModelName()
 						failureReason := streamErr.GetFailureReason()
@@ -352,6 +522,13 @@ func (sh *StreamingHandler) executeStreamingWithRetry(ctx context.Context, w htt
 					// 设置failure_reason，让错误分类器正确识别stream_status错误
 					lifecycleManager.HandleError(err)
 
+					// 流中途收到的上游错误事件（如 overloaded_error）已透传给客户端，按决策表冷却端点
+					if upErr, ok := AsUpstreamError(err); ok {
+						if policy, found := LookupUpstreamErrorPolicy(upErr.Type); found && policy.CooldownEndpoint {
+							cooldownFailedEndpoint(sh.endpointManager, ep, connID, "upstream_"+upErr.Type)
+						}
+					}
+
 					// 🚀 [HTTP状态码修复] 流式API错误应该映射为207 Multi-Status
 					statusCode := GetStatusCodeFromError(err, resp)
 					if status == "error" || status == "stream_error" {
@@ -409,6 +586,14 @@ func (sh *StreamingHandler) executeStreamingWithRetry(ctx context.Context, w htt
 					return
 				}
 
+				// 🎭 [假成功] 流已完整结束但输出 Token 为 0
+				if fakeErr := validators.ValidateTokens(resp.StatusCode, finalTokenUsage); fakeErr != nil {
+					sh.failCommittedStream(lifecycleManager, ep, r, resp.StatusCode, finalTokenUsage, modelName, fakeErr)
+					return
+				}
+
+				recordSupportedCapabilities(ctx, sh.endpointManager, ep)
+
 				// ✅ 流式处理成功完成，使用生命周期管理器完成请求
 				if finalTokenUsage != nil {
 					// 设置模型名称并通过生命周期管理器完成请求
@@ -428,14 +613,12 @@ func (sh *StreamingHandler) executeStreamingWithRetry(ctx context.Context, w htt
 			globalAttemptCount := lifecycleManager.IncrementAttempt()
 			lastErr = err
 
-			// 错误处理 - 先构造HTTP状态码错误（保持现有逻辑）
+			// 错误处理 - 读取错误响应体构造上游错误，确保RetryManager能按 error.type 与状态码正确分类
+			capabilityGap := ""
 			if err == nil && resp != nil && !IsSuccessStatus(resp.StatusCode) {
-				closeErr := resp.Body.Close() // 立即关闭非成功响应体
-				if closeErr != nil {
-					slog.Warn(fmt.Sprintf("⚠️ [响应体关闭失败] [%s] 端点: %s, Close错误: %v", connID, ep.Config.Name, closeErr))
-				}
-				// 构造HTTP状态码错误，确保RetryManager能正确分类429等状态
-				lastErr = fmt.Errorf("HTTP %d: %s", resp.StatusCode, http.StatusText(resp.StatusCode))
+				errorBody := readUpstreamErrorBody(resp, response.NewProcessor())
+				lastErr = NewUpstreamHTTPError(resp.StatusCode, errorBody)
+				capabilityGap = recordUnsupportedCapability(ctx, sh.endpointManager, ep, lastErr)
 			} else if err != nil && resp != nil {
 				closeErr := resp.Body.Close()
 				if closeErr != nil {
@@ -458,7 +641,22 @@ func (sh *StreamingHandler) executeStreamingWithRetry(ctx context.Context, w htt
 			// attempt: 当前端点内的尝试次数，用于退避计算
 			// globalAttemptCount: 全局尝试次数，用于限流策略
 			decision := retryMgr.ShouldRetryWithDecision(&errorCtx, attempt, globalAttemptCount, true) // 流式请求: isStreaming=true
-			lastDecision = &decision                                                                   // 保存决策，供外层逻辑使用
+			// 能力缺失：仍有支持该能力的端点时本次请求切换端点，后续按重新选择的端点列表尝试
+			if capabilityGap != "" {
+				if next := capabilitySwitchEndpoints(ctx, sh.endpointManager, ep); len(next) > 0 {
+					decision = capabilitySwitchDecision(capabilityGap)
+					endpoints = append(endpoints[:i+1:i+1], next...)
+				}
+			}
+			lastDecision = &decision // 保存决策，供外层逻辑使用
+
+			// 记录本次尝试轨迹（挂起需先确认满足挂起条件）
+			suspending := decision.SuspendRequest && sh.sharedSuspensionManager.ShouldSuspend(ctx)
+			trace := failedAttempt(lifecycleManager, sentAt, lastErr, &errorCtx, &decision, attempt, sh.config.Retry.MaxAttempts)
+			if suspending {
+				trace.Decision = tracking.AttemptDecisionSuspend
+			}
+			recordAttempt(lifecycleManager, trace)
 
 			// 检查决策结果
 			if decision.FinalStatus == "cancelled" {
@@ -475,7 +673,7 @@ func (sh *StreamingHandler) executeStreamingWithRetry(ctx context.Context, w htt
 
 			// 处理挂起决策
 			if decision.SuspendRequest {
-				if sh.sharedSuspensionManager.ShouldSuspend(ctx) {
+				if suspending {
 					// 🚀 [状态机重构] Phase 4: 挂起时更新状态
 					lifecycleManager.UpdateStatus("suspended", -1, 0)
 					slog.Info(fmt.Sprintf("⏸️ [流式挂起] [%s] 原因: %s，失败端点: %s", connID, decision.Reason, ep.Config.Name))
@@ -517,11 +715,14 @@ func (sh *StreamingHandler) executeStreamingWithRetry(ctx context.Context, w htt
 				if decision.SwitchEndpoint {
 					slog.Info(fmt.Sprintf("🔀 [切换端点] [%s] 当前端点: %s, 原因: %s",
 						connID, ep.Config.Name, decision.Reason))
+					if decision.CooldownEndpoint {
+						cooldownFailedEndpoint(sh.endpointManager, ep, connID, endpointCooldownReason(&errorCtx))
+					}
 					break // 尝试下一个端点
 				} else {
 					// 🚀 [状态机重构] Phase 4: 最终失败处理
 					// 获取失败原因
-					failureReason := lifecycleManager.MapErrorTypeToFailureReason(errorCtx.ErrorType)
+					failureReason := failureReasonForError(lifecycleManager, errorCtx.ErrorType, lastErr)
 
 					// 使用GetStatusCodeFromError获取真实的HTTP状态码
 					statusCode := GetStatusCodeFromError(lastErr, lastResp)
@@ -535,6 +736,8 @@ func (sh *StreamingHandler) executeStreamingWithRetry(ctx context.Context, w htt
 							statusCode = http.StatusUnauthorized
 						case "rate_limited":
 							statusCode = http.StatusTooManyRequests
+						case "overloaded":
+							statusCode = 529
 						default:
 							statusCode = http.StatusBadGateway
 						}
@@ -546,6 +749,11 @@ func (sh *StreamingHandler) executeStreamingWithRetry(ctx context.Context, w htt
 					// 终止重试
 					slog.Info(fmt.Sprintf("🛑 [终止重试] [%s] 端点: %s, 状态: %s, 状态码: %d, 原因: %s",
 						connID, ep.Config.Name, decision.FinalStatus, statusCode, decision.Reason))
+					// 上游结构化错误以 Anthropic SSE error 事件透传，客户端可按 error.type 处理
+					if upErr, ok := AsUpstreamError(lastErr); ok && upErr.Type != "" {
+						sendAnthropicError(w, flusher, upErr.Type, upErr.Message)
+						return
+					}
 					fmt.Fprintf(w, "data: error: %s\n\n", decision.Reason)
 					flusher.Flush()
 					return
@@ -700,7 +908,7 @@ func (sh *StreamingHandler) executeStreamingWithRetry(ctx context.Context, w htt
 
 	// 检查是否应该挂起请求
 	if suspensionMgr.ShouldSuspend(ctx) {
-		currentEndpoints := sh.endpointManager.GetHealthyEndpoints()
+		currentEndpoints := sh.endpointManager.GetHealthyEndpointsForRequest(ctx)
 		if cfg := sh.endpointManager.GetConfig(); cfg != nil && cfg.Strategy.Type == "fastest" && cfg.Strategy.FastTestEnabled {
 			currentEndpoints = sh.endpointManager.GetFastestEndpointsWithRealTimeTest(ctx)
 		}
@@ -728,7 +936,7 @@ func (sh *StreamingHandler) executeStreamingWithRetry(ctx context.Context, w htt
 			if sh.endpointManager.GetConfig().Strategy.Type == "fastest" && sh.endpointManager.GetConfig().Strategy.FastTestEnabled {
 				newEndpoints = sh.endpointManager.GetFastestEndpointsWithRealTimeTest(ctx)
 			} else {
-				newEndpoints = sh.endpointManager.GetHealthyEndpoints()
+				newEndpoints = sh.endpointManager.GetHealthyEndpointsForRequest(ctx)
 			}
 
 			if len(newEndpoints) > 0 {
@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"time"
+
+	"cc-forwarder/internal/tracking"
+)
+
+// ResponseTimingRecorder 可选接口：生命周期管理器记录上游响应时间线
+type ResponseTimingRecorder interface {
+	SetTimings(timings tracking.RequestTimings)
+}
+
+// StreamTimingReporter 可选接口：流式处理器报告首个 SSE 事件与首个内容增量（content_block_delta）的到达时间
+type StreamTimingReporter interface {
+	StreamTimings() (firstEventAt, firstContentAt time.Time)
+}
+
+// recordResponseTimings 计算并记录本次上游请求的响应时间线（需在完成请求前调用，确保随请求一起归档）
+// processor 为 nil 或未实现 StreamTimingReporter 时只记录响应头耗时与整体输出速率
+func recordResponseTimings(lifecycleManager RequestLifecycleManager, processor interface{}, sentAt, headerAt time.Time, tokens *tracking.TokenUsage) {
+	recorder, ok := lifecycleManager.(ResponseTimingRecorder)
+	if !ok {
+		return
+	}
+
+	var firstEventAt, firstContentAt time.Time
+	if reporter, ok := processor.(StreamTimingReporter); ok {
+		firstEventAt, firstContentAt = reporter.StreamTimings()
+	}
+	var outputTokens int64
+	if tokens != nil {
+		outputTokens = tokens.OutputTokens
+	}
+
+	timings := tracking.NewRequestTimings(sentAt, headerAt, firstEventAt, firstContentAt, time.Now(), outputTokens)
+	if !timings.IsZero() {
+		recorder.SetTimings(timings)
+	}
+}
@@ -0,0 +1,214 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// Anthropic 上游错误类型（错误响应体 / SSE error 事件中的 error.type）
+const (
+	UpstreamErrorInvalidRequest  = "invalid_request_error"
+	UpstreamErrorAuthentication  = "authentication_error"
+	UpstreamErrorPermission      = "permission_error"
+	UpstreamErrorNotFound        = "not_found_error"
+	UpstreamErrorRequestTooLarge = "request_too_large"
+	UpstreamErrorRateLimit       = "rate_limit_error"
+	UpstreamErrorAPI             = "api_error"
+	UpstreamErrorOverloaded      = "overloaded_error"
+)
+
+// maxUpstreamErrorBody 错误响应体最多保留的字节数（用于解析与透传）
+const maxUpstreamErrorBody = 64 * 1024
+
+// UpstreamError 上游返回的结构化错误
+// 来源有两种：非 2xx 的 HTTP 响应体，或流式响应中的 SSE error 事件
+type UpstreamError struct {
+	StatusCode int    // HTTP 状态码（SSE error 事件为 0）
+	Type       string // error.type；响应体无法解析时按状态码推断，仍无法确定则为空
+	Message    string // error.message
+	RequestID  string // 上游 request_id（如有）
+	Body       []byte // 原始错误响应体（截断到 maxUpstreamErrorBody），用于透传给客户端
+	FromStream bool   // 是否来自 SSE error 事件
+}
+
+// Error 实现 error 接口
+// HTTP 错误保持 "HTTP <code>: <text>" 前缀，兼容按字符串解析状态码的旧逻辑
+func (e *UpstreamError) Error() string {
+	if e.FromStream {
+		return fmt.Sprintf("API错误 %s: %s", e.Type, e.Message)
+	}
+	msg := fmt.Sprintf("HTTP %d: %s", e.StatusCode, http.StatusText(e.StatusCode))
+	if e.Type != "" {
+		if e.Message != "" {
+			return fmt.Sprintf("%s (%s: %s)", msg, e.Type, e.Message)
+		}
+		return fmt.Sprintf("%s (%s)", msg, e.Type)
+	}
+	return msg
+}
+
+// upstreamErrorBody Anthropic 错误响应体结构
+// {"type":"error","error":{"type":"overloaded_error","message":"Overloaded"},"request_id":"req_xxx"}
+type upstreamErrorBody struct {
+	Type  string `json:"type"`
+	Error *struct {
+		Type      string `json:"type"`
+		Message   string `json:"message"`
+		RequestID string `json:"request_id,omitempty"`
+	} `json:"error"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// ParseUpstreamErrorBody 从错误响应体中解析 error.type / error.message / request_id
+// 非 JSON 或不含 error 对象时返回 ok=false
+func ParseUpstreamErrorBody(body []byte) (errType, message, requestID string, ok bool) {
+	trimmed := strings.TrimSpace(string(body))
+	if trimmed == "" || trimmed[0] != '{' {
+		return "", "", "", false
+	}
+
+	var parsed upstreamErrorBody
+	if err := json.Unmarshal([]byte(trimmed), &parsed); err != nil || parsed.Error == nil || parsed.Error.Type == "" {
+		return "", "", "", false
+	}
+
+	requestID = parsed.RequestID
+	if requestID == "" {
+		requestID = parsed.Error.RequestID
+	}
+	return parsed.Error.Type, parsed.Error.Message, requestID, true
+}
+
+// inferUpstreamErrorType 响应体无法解析时按状态码推断 Anthropic 错误类型
+// 502/503/504 等网关类错误不推断，交由原有的状态码分类处理
+func inferUpstreamErrorType(statusCode int) string {
+	switch statusCode {
+	case http.StatusBadRequest:
+		return UpstreamErrorInvalidRequest
+	case http.StatusUnauthorized:
+		return UpstreamErrorAuthentication
+	case http.StatusForbidden:
+		return UpstreamErrorPermission
+	case http.StatusNotFound:
+		return UpstreamErrorNotFound
+	case http.StatusRequestEntityTooLarge:
+		return UpstreamErrorRequestTooLarge
+	case http.StatusTooManyRequests:
+		return UpstreamErrorRateLimit
+	case http.StatusInternalServerError:
+		return UpstreamErrorAPI
+	case 529:
+		return UpstreamErrorOverloaded
+	default:
+		return ""
+	}
+}
+
+// NewUpstreamHTTPError 根据非 2xx 响应的状态码和响应体构造上游错误
+func NewUpstreamHTTPError(statusCode int, body []byte) *UpstreamError {
+	if len(body) > maxUpstreamErrorBody {
+		body = body[:maxUpstreamErrorBody]
+	}
+
+	upErr := &UpstreamError{
+		StatusCode: statusCode,
+		Body:       body,
+	}
+	if errType, message, requestID, ok := ParseUpstreamErrorBody(body); ok {
+		upErr.Type = errType
+		upErr.Message = message
+		upErr.RequestID = requestID
+	} else {
+		upErr.Type = inferUpstreamErrorType(statusCode)
+	}
+	return upErr
+}
+
+// NewUpstreamStreamError 根据 SSE error 事件构造上游错误
+func NewUpstreamStreamError(errType, message string) *UpstreamError {
+	return &UpstreamError{
+		Type:       errType,
+		Message:    message,
+		FromStream: true,
+	}
+}
+
+// AsUpstreamError 从错误链中提取 UpstreamError
+func AsUpstreamError(err error) (*UpstreamError, bool) {
+	var upErr *UpstreamError
+	if err != nil && errors.As(err, &upErr) {
+		return upErr, true
+	}
+	return nil, false
+}
+
+// UpstreamErrorPolicy 按上游错误类型确定的重试 / 故障转移 / 冷却策略
+type UpstreamErrorPolicy struct {
+	ErrorType         ErrorType // 映射到的内部错误分类（用于 failure_reason 与日志）
+	RetrySameEndpoint bool      // 是否在同一端点重试
+	SwitchEndpoint    bool      // 同端点不再重试后，是否切换到其他端点/渠道
+	CooldownEndpoint  bool      // 切换端点时是否将当前端点置入冷却
+	FinalStatus       string    // 不再尝试时记录的最终状态
+}
+
+// upstreamErrorPolicies 上游错误类型决策表
+//   - 请求本身有问题（格式错误、请求过大）：任何端点都会失败，直接返回，不重试不切换
+//   - 凭据/权限问题：只影响当前端点的 Key，切换端点并冷却当前端点
+//   - not_found：多为中转站不支持该模型，切换端点但不冷却（其他模型仍可用）
+//   - 限流：先在同端点退避重试，耗尽后切换并冷却
+//   - api_error：上游偶发故障，同端点重试后切换
+//   - 过载（529）：同端点重试意义不大，立即切换并冷却
+var upstreamErrorPolicies = map[string]UpstreamErrorPolicy{
+	UpstreamErrorInvalidRequest:  {ErrorType: ErrorTypeHTTP, FinalStatus: "error"},
+	UpstreamErrorRequestTooLarge: {ErrorType: ErrorTypeHTTP, FinalStatus: "error"},
+	UpstreamErrorAuthentication:  {ErrorType: ErrorTypeAuth, SwitchEndpoint: true, CooldownEndpoint: true, FinalStatus: "auth_error"},
+	UpstreamErrorPermission:      {ErrorType: ErrorTypeAuth, SwitchEndpoint: true, CooldownEndpoint: true, FinalStatus: "auth_error"},
+	UpstreamErrorNotFound:        {ErrorType: ErrorTypeHTTP, SwitchEndpoint: true, FinalStatus: "error"},
+	UpstreamErrorRateLimit:       {ErrorType: ErrorTypeRateLimit, RetrySameEndpoint: true, SwitchEndpoint: true, CooldownEndpoint: true, FinalStatus: "rate_limited"},
+	UpstreamErrorAPI:             {ErrorType: ErrorTypeServerError, RetrySameEndpoint: true, SwitchEndpoint: true, FinalStatus: "server_error"},
+	UpstreamErrorOverloaded:      {ErrorType: ErrorTypeOverloaded, SwitchEndpoint: true, CooldownEndpoint: true, FinalStatus: "overloaded"},
+}
+
+// LookupUpstreamErrorPolicy 查询上游错误类型对应的处理策略
+func LookupUpstreamErrorPolicy(errType string) (UpstreamErrorPolicy, bool) {
+	policy, ok := upstreamErrorPolicies[errType]
+	return policy, ok
+}
+
+// readUpstreamErrorBody 读取并关闭非成功响应的响应体（含解压），最多保留 maxUpstreamErrorBody 字节
+func readUpstreamErrorBody(resp *http.Response, processor ResponseProcessor) []byte {
+	if resp == nil || resp.Body == nil {
+		return nil
+	}
+	defer resp.Body.Close()
+
+	body, err := processor.ProcessResponseBody(resp)
+	if err != nil {
+		slog.Debug(fmt.Sprintf("⚠️ [上游错误体] 读取错误响应体失败: %v", err))
+	}
+	if len(body) > maxUpstreamErrorBody {
+		body = body[:maxUpstreamErrorBody]
+	}
+	return body
+}
+
+// writeUpstreamErrorResponse 将上游错误原样透传给客户端（常规请求）
+// 响应体可解析时直接透传，让客户端看到真实错误信息；返回 false 表示无可透传内容
+func writeUpstreamErrorResponse(w http.ResponseWriter, err error, statusCode int) bool {
+	upErr, ok := AsUpstreamError(err)
+	if !ok || upErr.FromStream || len(upErr.Body) == 0 {
+		return false
+	}
+	if _, _, _, parsed := ParseUpstreamErrorBody(upErr.Body); !parsed {
+		return false
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	_, _ = w.Write(upErr.Body)
+	return true
+}
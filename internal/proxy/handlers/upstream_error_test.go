@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNewUpstreamHTTPError_ParsesAnthropicBody(t *testing.T) {
+	body := []byte(`{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"},"request_id":"req_123"}`)
+
+	upErr := NewUpstreamHTTPError(529, body)
+
+	if upErr.Type != UpstreamErrorOverloaded {
+		t.Errorf("Type = %q, 期望 %q", upErr.Type, UpstreamErrorOverloaded)
+	}
+	if upErr.Message != "Overloaded" {
+		t.Errorf("Message = %q, 期望 Overloaded", upErr.Message)
+	}
+	if upErr.RequestID != "req_123" {
+		t.Errorf("RequestID = %q, 期望 req_123", upErr.RequestID)
+	}
+	if upErr.StatusCode != 529 {
+		t.Errorf("StatusCode = %d, 期望 529", upErr.StatusCode)
+	}
+}
+
+func TestNewUpstreamHTTPError_InfersTypeFromStatus(t *testing.T) {
+	testCases := []struct {
+		name       string
+		statusCode int
+		body       string
+		expected   string
+	}{
+		{"400 HTML 错误页", 400, "<html>bad request</html>", UpstreamErrorInvalidRequest},
+		{"401 空响应体", 401, "", UpstreamErrorAuthentication},
+		{"429 纯文本", 429, "too many requests", UpstreamErrorRateLimit},
+		{"529 非 Anthropic JSON", 529, `{"message":"busy"}`, UpstreamErrorOverloaded},
+		{"502 网关错误不推断", 502, "bad gateway", ""},
+		// 响应体中的 error.type 优先于状态码
+		{"400 携带 rate_limit_error", 400, `{"type":"error","error":{"type":"rate_limit_error","message":"slow down"}}`, UpstreamErrorRateLimit},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			upErr := NewUpstreamHTTPError(tc.statusCode, []byte(tc.body))
+			if upErr.Type != tc.expected {
+				t.Errorf("Type = %q, 期望 %q", upErr.Type, tc.expected)
+			}
+		})
+	}
+}
+
+func TestUpstreamError_ErrorKeepsHTTPPrefix(t *testing.T) {
+	upErr := NewUpstreamHTTPError(429, []byte(`{"type":"error","error":{"type":"rate_limit_error","message":"slow down"}}`))
+
+	expected := "HTTP 429: Too Many Requests (rate_limit_error: slow down)"
+	if upErr.Error() != expected {
+		t.Errorf("Error() = %q, 期望 %q", upErr.Error(), expected)
+	}
+
+	streamErr := NewUpstreamStreamError(UpstreamErrorOverloaded, "Overloaded")
+	if streamErr.Error() != "API错误 overloaded_error: Overloaded" {
+		t.Errorf("流式错误 Error() = %q", streamErr.Error())
+	}
+}
+
+func TestAsUpstreamError_Wrapped(t *testing.T) {
+	wrapped := fmt.Errorf("stream_status:overloaded:model:claude: %w", NewUpstreamStreamError(UpstreamErrorOverloaded, "Overloaded"))
+
+	upErr, ok := AsUpstreamError(wrapped)
+	if !ok {
+		t.Fatal("应能从包装错误中提取 UpstreamError")
+	}
+	if upErr.Type != UpstreamErrorOverloaded {
+		t.Errorf("Type = %q, 期望 %q", upErr.Type, UpstreamErrorOverloaded)
+	}
+
+	if _, ok := AsUpstreamError(fmt.Errorf("plain error")); ok {
+		t.Error("普通错误不应被识别为 UpstreamError")
+	}
+}
+
+func TestLookupUpstreamErrorPolicy(t *testing.T) {
+	testCases := []struct {
+		errType     string
+		errorType   ErrorType
+		retrySame   bool
+		switchEp    bool
+		cooldown    bool
+		finalStatus string
+	}{
+		{UpstreamErrorInvalidRequest, ErrorTypeHTTP, false, false, false, "error"},
+		{UpstreamErrorRequestTooLarge, ErrorTypeHTTP, false, false, false, "error"},
+		{UpstreamErrorAuthentication, ErrorTypeAuth, false, true, true, "auth_error"},
+		{UpstreamErrorPermission, ErrorTypeAuth, false, true, true, "auth_error"},
+		{UpstreamErrorNotFound, ErrorTypeHTTP, false, true, false, "error"},
+		{UpstreamErrorRateLimit, ErrorTypeRateLimit, true, true, true, "rate_limited"},
+		{UpstreamErrorAPI, ErrorTypeServerError, true, true, false, "server_error"},
+		{UpstreamErrorOverloaded, ErrorTypeOverloaded, false, true, true, "overloaded"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.errType, func(t *testing.T) {
+			policy, ok := LookupUpstreamErrorPolicy(tc.errType)
+			if !ok {
+				t.Fatalf("未找到 %s 的处理策略", tc.errType)
+			}
+			if policy.ErrorType != tc.errorType || policy.RetrySameEndpoint != tc.retrySame ||
+				policy.SwitchEndpoint != tc.switchEp || policy.CooldownEndpoint != tc.cooldown ||
+				policy.FinalStatus != tc.finalStatus {
+				t.Errorf("策略不符: %+v", policy)
+			}
+		})
+	}
+
+	if _, ok := LookupUpstreamErrorPolicy("unknown_error"); ok {
+		t.Error("未知错误类型不应有处理策略")
+	}
+}
+
+func TestWriteUpstreamErrorResponse(t *testing.T) {
+	body := `{"type":"error","error":{"type":"invalid_request_error","message":"max_tokens too large"}}`
+	w := httptest.NewRecorder()
+
+	if !writeUpstreamErrorResponse(w, NewUpstreamHTTPError(400, []byte(body)), http.StatusBadRequest) {
+		t.Fatal("可解析的上游错误应被透传")
+	}
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("状态码 = %d, 期望 400", w.Code)
+	}
+	if w.Body.String() != body {
+		t.Errorf("响应体 = %q, 期望原样透传", w.Body.String())
+	}
+
+	// 非 JSON 响应体不透传，交由调用方使用默认错误响应
+	if writeUpstreamErrorResponse(httptest.NewRecorder(), NewUpstreamHTTPError(502, []byte("bad gateway")), http.StatusBadGateway) {
+		t.Error("不可解析的响应体不应被透传")
+	}
+}
+
+func TestGetStatusCodeFromError_UpstreamError(t *testing.T) {
+	if code := GetStatusCodeFromError(NewUpstreamHTTPError(529, nil), nil); code != 529 {
+		t.Errorf("HTTP 上游错误状态码 = %d, 期望 529", code)
+	}
+	if code := GetStatusCodeFromError(NewUpstreamStreamError(UpstreamErrorOverloaded, "Overloaded"), nil); code != 529 {
+		t.Errorf("SSE overloaded_error 状态码 = %d, 期望 529", code)
+	}
+}
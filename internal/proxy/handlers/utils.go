@@ -1,9 +1,13 @@
 package handlers
 
 import (
+	"fmt"
+	"log/slog"
 	"net/http"
 	"strconv"
 	"strings"
+
+	"cc-forwarder/internal/endpoint"
 )
 
 // IsSuccessStatus 判断是否为成功状态码
@@ -44,6 +48,16 @@ func GetStatusCodeFromError(err error, resp *http.Response) int {
 		return 0
 	}
 
+	// 上游结构化错误直接使用其状态码（SSE error 事件没有状态码，按类型推断）
+	if upErr, ok := AsUpstreamError(err); ok {
+		if upErr.StatusCode != 0 {
+			return upErr.StatusCode
+		}
+		if upErr.Type == UpstreamErrorOverloaded {
+			return 529
+		}
+	}
+
 	errorStr := err.Error()
 
 	// 常见的HTTP状态码提取模式
@@ -82,4 +96,30 @@ func GetStatusCodeFromError(err error, resp *http.Response) int {
 
 	// 无法提取状态码，返回0表示网络错误或其他未知错误
 	return 0
-}
\ No newline at end of file
+}
+// endpointCooldownReason 根据错误上下文生成端点冷却原因
+func endpointCooldownReason(errorCtx *ErrorContext) string {
+	if fakeErr, ok := AsFakeSuccessError(errorCtx.OriginalError); ok {
+		return "fake_success_" + fakeErr.Validator
+	}
+	if errorCtx.UpstreamErrorType != "" {
+		return "upstream_" + errorCtx.UpstreamErrorType
+	}
+	return "request_failure"
+}
+
+// cooldownFailedEndpoint 按重试决策将失败端点置入冷却，避免后续请求继续命中
+func cooldownFailedEndpoint(mgr *endpoint.Manager, ep *endpoint.Endpoint, requestID, reason string) {
+	if mgr == nil || ep == nil {
+		return
+	}
+
+	key := endpoint.EndpointKey(ep.Config.Channel, ep.Config.Name)
+	until, err := mgr.SetEndpointCooldown(key, reason)
+	if err != nil {
+		slog.Warn(fmt.Sprintf("⚠️ [端点冷却] 设置端点冷却失败: %s, 错误: %v", key, err))
+		return
+	}
+	slog.Info(fmt.Sprintf("❄️ [端点冷却] [%s] 端点: %s, 原因: %s, 冷却至: %s",
+		requestID, key, reason, until.Format("15:04:05")))
+}
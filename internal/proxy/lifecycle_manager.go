@@ -53,6 +53,8 @@ type RequestLifecycleManager struct {
 	channel               string                         // 渠道标签
 	endpointName          string                         // 端点名称
 	groupName             string                         // 组名称
+	sessionID             string                         // 客户端会话标识（Claude Code 会话）
+	tags                  []tracking.RequestTag          // 请求标签（项目/成本中心归属）
 	retryCount            int                            // 重试计数
 	lastStatus            string                         // 最后状态
 	lastError             error                          // 最后一次错误
@@ -439,6 +441,101 @@ func (rlm *RequestLifecycleManager) SetModel(modelName string) {
 	}
 }
 
+// SetSessionID 记录请求所属的客户端会话（需在 StartRequest 之后调用）
+func (rlm *RequestLifecycleManager) SetSessionID(sessionID string) {
+	if sessionID == "" {
+		return
+	}
+
+	rlm.modelMu.Lock()
+	rlm.sessionID = sessionID
+	rlm.modelMu.Unlock()
+
+	if rlm.usageTracker != nil && rlm.requestID != "" {
+		rlm.usageTracker.RecordRequestUpdate(rlm.requestID, tracking.UpdateOptions{SessionID: &sessionID})
+		slog.Debug(fmt.Sprintf("🧵 [会话归属] [%s] 会话: %s", rlm.requestID, sessionID))
+	}
+}
+
+// SetRequestParams 记录请求体中的生成参数与请求大小（需在 StartRequest 之后调用）
+func (rlm *RequestLifecycleManager) SetRequestParams(params tracking.RequestParams) {
+	if params.IsZero() || rlm.usageTracker == nil || rlm.requestID == "" {
+		return
+	}
+
+	rlm.usageTracker.RecordRequestUpdate(rlm.requestID, tracking.UpdateOptions{RequestParams: &params})
+	slog.Debug(fmt.Sprintf("📐 [请求参数] [%s] max_tokens: %d, 消息数: %d, 工具数: %d, 思考预算: %d, 请求大小: %d bytes",
+		rlm.requestID, params.MaxTokens, params.MessageCount, params.ToolCount, params.ThinkingBudget, params.RequestBytes))
+}
+
+// SetResponseMeta 记录上游响应元信息（停止原因、消息 ID、工具调用与 thinking 内容块）
+func (rlm *RequestLifecycleManager) SetResponseMeta(meta tracking.ResponseMeta) {
+	if meta.IsZero() || rlm.usageTracker == nil || rlm.requestID == "" {
+		return
+	}
+
+	rlm.usageTracker.RecordRequestUpdate(rlm.requestID, tracking.UpdateOptions{ResponseMeta: &meta})
+	slog.Debug(fmt.Sprintf("🛑 [响应元信息] [%s] stop_reason: %s, 消息ID: %s, 工具调用: %d, thinking: %v",
+		rlm.requestID, meta.StopReason, meta.MessageID, meta.ToolUseCount, meta.HasThinking))
+}
+
+// SetTimings 记录上游响应时间线（TTFB、首个事件、首个 token 与输出速率）
+func (rlm *RequestLifecycleManager) SetTimings(timings tracking.RequestTimings) {
+	if timings.IsZero() || rlm.usageTracker == nil || rlm.requestID == "" {
+		return
+	}
+
+	rlm.usageTracker.RecordRequestUpdate(rlm.requestID, tracking.UpdateOptions{Timings: &timings})
+	slog.Debug(fmt.Sprintf("⏱️ [响应时间线] [%s] TTFB: %dms, 首token: %dms, 输出速率: %.2f tokens/s",
+		rlm.requestID, timings.TTFBMs, timings.FirstTokenMs, timings.OutputTokensPerSec))
+}
+
+// RecordAttempt 记录一次上游尝试（重试/故障转移轨迹），未指定端点时使用当前端点
+func (rlm *RequestLifecycleManager) RecordAttempt(attempt tracking.RequestAttempt) {
+	if rlm.usageTracker == nil || rlm.requestID == "" {
+		return
+	}
+
+	if attempt.EndpointName == "" {
+		attempt.Channel = rlm.channel
+		attempt.EndpointName = rlm.endpointName
+	}
+
+	rlm.usageTracker.RecordRequestAttempt(rlm.requestID, attempt)
+	slog.Debug(fmt.Sprintf("🧭 [尝试轨迹] [%s] 第 %d 次尝试, 端点: %s, 状态码: %d, 决策: %s",
+		rlm.requestID, attempt.AttemptNumber, attempt.EndpointName, attempt.HTTPStatus, attempt.Decision))
+}
+
+// GetSessionID 获取请求所属的客户端会话（线程安全）
+func (rlm *RequestLifecycleManager) GetSessionID() string {
+	rlm.modelMu.RLock()
+	defer rlm.modelMu.RUnlock()
+	return rlm.sessionID
+}
+
+// SetTags 记录请求标签（需在 StartRequest 之后调用）
+func (rlm *RequestLifecycleManager) SetTags(tags []tracking.RequestTag) {
+	if len(tags) == 0 {
+		return
+	}
+
+	rlm.modelMu.Lock()
+	rlm.tags = append(rlm.tags, tags...)
+	rlm.modelMu.Unlock()
+
+	if rlm.usageTracker != nil && rlm.requestID != "" {
+		rlm.usageTracker.RecordRequestTags(rlm.requestID, tags)
+		slog.Debug(fmt.Sprintf("🏷️ [请求标签] [%s] 标签: %v", rlm.requestID, tags))
+	}
+}
+
+// GetTags 获取请求标签（线程安全）
+func (rlm *RequestLifecycleManager) GetTags() []tracking.RequestTag {
+	rlm.modelMu.RLock()
+	defer rlm.modelMu.RUnlock()
+	return append([]tracking.RequestTag(nil), rlm.tags...)
+}
+
 // SetModelWithComparison 设置模型名称并进行对比检查（线程安全）
 // 如果已有模型，会进行对比并在不一致时输出警告，最终以新模型为准
 func (rlm *RequestLifecycleManager) SetModelWithComparison(newModelName, source string) {
@@ -577,6 +674,9 @@ func (rlm *RequestLifecycleManager) PrepareErrorContext(errorCtx *handlers.Error
 		OriginalError:  errorCtx.OriginalError,
 		RetryableAfter: errorCtx.RetryableAfter,
 		MaxRetries:     errorCtx.MaxRetries,
+
+		UpstreamErrorType:  errorCtx.UpstreamErrorType,
+		UpstreamStatusCode: errorCtx.UpstreamStatusCode,
 	}
 
 	rlm.pendingErrorContext = converted
@@ -636,6 +736,10 @@ func (rlm *RequestLifecycleManager) HandleError(err error) {
 		// 状态转换由重试逻辑控制(retry/suspended/failed)，不在HandleError中处理
 		if rlm.usageTracker != nil {
 			failureReason := rlm.MapErrorTypeToFailureReason(handlers.ErrorType(errorCtx.ErrorType))
+			// 假成功记录命中的校验器名称，便于定位中转站问题
+			if fakeErr, ok := handlers.AsFakeSuccessError(err); ok {
+				failureReason = fakeErr.Validator
+			}
 			opts := tracking.UpdateOptions{
 				FailureReason: &failureReason,
 			}
@@ -712,16 +816,13 @@ func (rlm *RequestLifecycleManager) GetLastError() error {
 }
 
 // calculateCost 计算Token使用成本的辅助方法
-func (rlm *RequestLifecycleManager) calculateCost(tokens *tracking.TokenUsage, pricing tracking.ModelPricing) float64 {
-	if tokens == nil {
-		return 0.0
+// 渠道/端点价目表优先于模型定价，返回结果中记录价格来源
+func (rlm *RequestLifecycleManager) calculateCost(tokens *tracking.TokenUsage) tracking.CostBreakdown {
+	if tokens == nil || rlm.usageTracker == nil {
+		return tracking.CostBreakdown{}
 	}
 
-	inputCost := float64(tokens.InputTokens) * pricing.Input / 1000000
-	outputCost := float64(tokens.OutputTokens) * pricing.Output / 1000000
-	cacheCost := float64(tokens.CacheCreationTokens) * pricing.CacheCreation / 1000000
-
-	return inputCost + outputCost + cacheCost
+	return rlm.usageTracker.CalculateRequestCost(rlm.channel, rlm.groupName, rlm.endpointName, rlm.GetModelName(), tokens, rlm.startTime)
 }
 
 // SetFinalStatusCode 设置最终状态码
@@ -848,6 +949,10 @@ func (rlm *RequestLifecycleManager) MapErrorTypeToFailureReason(errorType handle
 		return "parsing_error"
 	case handlers.ErrorTypeNoHealthyEndpoints:
 		return "no_healthy"
+	case handlers.ErrorTypeOverloaded:
+		return "overloaded"
+	case handlers.ErrorTypeFakeSuccess:
+		return "fake_success"
 	case handlers.ErrorTypeUnknown:
 		return "unknown_error"
 	case handlers.ErrorTypeClientCancel:
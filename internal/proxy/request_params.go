@@ -0,0 +1,23 @@
+package proxy
+
+import (
+	"cc-forwarder/internal/tracking"
+)
+
+// extractRequestParams 从已解码的请求体中提取生成参数（max_tokens、消息数、工具数、思考预算、温度）与请求大小
+// sniff 为 nil（非 messages 路径或请求体无法解码）时只记录请求大小
+func extractRequestParams(sniff *requestSniff, requestBytes int64) tracking.RequestParams {
+	params := tracking.RequestParams{RequestBytes: requestBytes}
+	if sniff == nil {
+		return params
+	}
+
+	params.MaxTokens = sniff.MaxTokens
+	params.MessageCount = len(sniff.Messages)
+	params.ToolCount = len(sniff.Tools)
+	params.Temperature = sniff.Temperature
+	if sniff.Thinking != nil {
+		params.ThinkingBudget = sniff.Thinking.BudgetTokens
+	}
+	return params
+}
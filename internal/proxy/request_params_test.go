@@ -0,0 +1,35 @@
+package proxy
+
+import (
+	"testing"
+)
+
+// TestExtractRequestParams 测试从请求体提取生成参数与请求大小
+func TestExtractRequestParams(t *testing.T) {
+	body := `{"model":"claude-sonnet-4","max_tokens":8192,"temperature":0.2,` +
+		`"thinking":{"type":"enabled","budget_tokens":4096},` +
+		`"messages":[{"role":"user","content":"hi"},{"role":"assistant","content":"hello"},{"role":"user","content":"go"}],` +
+		`"tools":[{"name":"read"},{"name":"write"}]}`
+
+	params := extractRequestParams(sniffRequestBody([]byte(body), "/v1/messages"), int64(len(body)))
+	if params.MaxTokens != 8192 || params.MessageCount != 3 || params.ToolCount != 2 || params.ThinkingBudget != 4096 {
+		t.Errorf("请求参数不符: %+v", params)
+	}
+	if params.Temperature == nil || *params.Temperature != 0.2 {
+		t.Errorf("温度应为 0.2, got %v", params.Temperature)
+	}
+	if params.RequestBytes != int64(len(body)) {
+		t.Errorf("请求大小应为 %d, got %d", len(body), params.RequestBytes)
+	}
+
+	// 未指定温度时保持 nil，非 messages 路径只记录请求大小
+	params = extractRequestParams(sniffRequestBody([]byte(`{"max_tokens":100,"messages":[]}`), "/v1/messages"), 0)
+	if params.Temperature != nil || params.MaxTokens != 100 {
+		t.Errorf("未指定温度时应为 nil: %+v", params)
+	}
+	other := `{"max_tokens":100}`
+	params = extractRequestParams(sniffRequestBody([]byte(other), "/v1/complete"), int64(len(other)))
+	if params.MaxTokens != 0 || params.RequestBytes != int64(len(other)) {
+		t.Errorf("非 messages 路径只应记录请求大小: %+v", params)
+	}
+}
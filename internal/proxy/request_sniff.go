@@ -0,0 +1,55 @@
+package proxy
+
+import (
+	"encoding/json"
+	"strings"
+
+	"cc-forwarder/internal/endpoint"
+)
+
+// requestSniff 请求体中用于模型路由、会话归属、请求参数与能力识别的字段
+// 每个请求只解码一次，结果由各提取函数共享
+type requestSniff struct {
+	Model       string            `json:"model"`
+	MaxTokens   int64             `json:"max_tokens"`
+	Messages    []json.RawMessage `json:"messages"`
+	Temperature *float64          `json:"temperature"`
+	Tools       []struct {
+		Type string `json:"type"`
+	} `json:"tools"`
+	Thinking *struct {
+		Type         string `json:"type"`
+		BudgetTokens int64  `json:"budget_tokens"`
+	} `json:"thinking"`
+	Metadata struct {
+		UserID string `json:"user_id"`
+	} `json:"metadata"`
+}
+
+// sniffRequestBody 解码请求体
+// 仅对 /v1/messages 相关路径解析，空请求体或非法 JSON 返回 nil
+func sniffRequestBody(bodyBytes []byte, path string) *requestSniff {
+	if !strings.Contains(path, "/v1/messages") || len(bodyBytes) == 0 {
+		return nil
+	}
+	var sniff requestSniff
+	if err := json.Unmarshal(bodyBytes, &sniff); err != nil {
+		return nil
+	}
+	return &sniff
+}
+
+// capabilityFeatures 转换为能力识别所需的请求体字段（nil 表示请求体未解码）
+func (s *requestSniff) capabilityFeatures() *endpoint.RequestBodyFeatures {
+	if s == nil {
+		return nil
+	}
+	features := &endpoint.RequestBodyFeatures{}
+	if s.Thinking != nil {
+		features.ThinkingType = s.Thinking.Type
+	}
+	for _, tool := range s.Tools {
+		features.ToolTypes = append(features.ToolTypes, tool.Type)
+	}
+	return features
+}
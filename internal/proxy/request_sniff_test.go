@@ -0,0 +1,41 @@
+package proxy
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"cc-forwarder/internal/endpoint"
+)
+
+// TestSniffRequestBody 测试请求体单次解码的结果与各独立解析保持一致
+func TestSniffRequestBody(t *testing.T) {
+	body := []byte(`{"model":"claude-sonnet-4","max_tokens":1024,` +
+		`"thinking":{"type":"enabled","budget_tokens":512},` +
+		`"tools":[{"type":"web_search_20250305","name":"web_search"},{"name":"read"}],` +
+		`"metadata":{"user_id":"user_x_account_y_session_sess-1"},` +
+		`"system":[{"type":"text","text":"x","cache_control":{"type":"ephemeral"}}],` +
+		`"messages":[{"role":"user","content":"hi"}]}`)
+
+	sniff := sniffRequestBody(body, "/v1/messages")
+	if sniff == nil || sniff.Model != "claude-sonnet-4" {
+		t.Fatalf("应解析出模型名称: %+v", sniff)
+	}
+	if got := extractSessionID(sniff, nil, ""); got != "sess-1" {
+		t.Errorf("会话标识 = %q, want sess-1", got)
+	}
+	if params := extractRequestParams(sniff, int64(len(body))); params.ToolCount != 2 || params.ThinkingBudget != 512 {
+		t.Errorf("请求参数不符: %+v", params)
+	}
+
+	got := endpoint.DetectRequiredCapabilitiesWithFeatures("/v1/messages", http.Header{}, body, sniff.capabilityFeatures())
+	want := []string{endpoint.CapabilityPromptCaching, endpoint.CapabilityThinking, endpoint.CapabilityWebSearch}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("能力识别 = %v, want %v", got, want)
+	}
+
+	// 非 messages 路径与非法 JSON 不解码
+	if sniffRequestBody(body, "/v1/models") != nil || sniffRequestBody([]byte("not json"), "/v1/messages") != nil {
+		t.Error("非 messages 路径或非法 JSON 应返回 nil")
+	}
+}
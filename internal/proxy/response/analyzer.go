@@ -325,6 +325,34 @@ func (a *TokenAnalyzer) AnalyzeResponseForTokensUnified(responseBytes []byte, co
 	}
 }
 
+// AnalyzeResponseMeta 解析完整响应中的元信息（停止原因、消息 ID、工具调用与 thinking 内容块）
+func (a *TokenAnalyzer) AnalyzeResponseMeta(responseBytes []byte) tracking.ResponseMeta {
+	var meta tracking.ResponseMeta
+	if len(responseBytes) == 0 {
+		return meta
+	}
+
+	responseStr := string(responseBytes)
+	if detectResponseFormat(responseStr) != FormatSSE {
+		meta.Observe(responseBytes)
+		return meta
+	}
+
+	// SSE：逐个 data 行解析（非元信息事件由 Observe 忽略）
+	for _, line := range strings.Split(responseStr, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if strings.Contains(data, `"content_block_delta"`) {
+			continue
+		}
+		meta.Observe([]byte(data))
+	}
+	return meta
+}
+
 // parseSSEForTokens 解析SSE格式响应获取Token信息（不直接记录）
 func (a *TokenAnalyzer) parseSSEForTokens(responseStr, connID, endpointName string) (*tracking.TokenUsage, string) {
 	tokenParser := a.tokenParserProvider.NewTokenParserWithUsageTracker(connID, a.usageTracker)
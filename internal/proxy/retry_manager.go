@@ -2,6 +2,7 @@ package proxy
 
 import (
 	"context"
+	"fmt"
 	"math"
 	"net/http"
 	"time"
@@ -54,8 +55,9 @@ func (rm *RetryManager) ShouldRetry(errorCtx *handlers.ErrorContext, attempt int
 	case handlers.ErrorTypeHTTP, handlers.ErrorTypeClientCancel:
 		// HTTP错误（4xx）、客户端取消不可重试
 		return false, 0
-	case handlers.ErrorTypeAuth:
+	case handlers.ErrorTypeAuth, handlers.ErrorTypeOverloaded, handlers.ErrorTypeFakeSuccess:
 		// 2025-12-10: 认证/权限错误不在同一端点重试（故障转移在 ShouldRetryWithDecision 处理）
+		// 上游过载、假成功同理，直接切换端点
 		return false, 0
 	case handlers.ErrorTypeRateLimit:
 		// 限流错误可重试，但使用更长的延迟
@@ -84,8 +86,8 @@ func (rm *RetryManager) GetHealthyEndpoints(ctx context.Context) []*endpoint.End
 	if rm.endpointMgr.GetConfig().Strategy.Type == "fastest" && rm.endpointMgr.GetConfig().Strategy.FastTestEnabled {
 		return rm.endpointMgr.GetFastestEndpointsWithRealTimeTest(ctx)
 	}
-	// 否则返回健康的端点
-	return rm.endpointMgr.GetHealthyEndpoints()
+	// 否则返回健康的端点（按请求模型筛选）
+	return rm.endpointMgr.GetHealthyEndpointsForRequest(ctx)
 }
 
 // calculateBackoff 计算指数退避延迟
@@ -176,6 +178,13 @@ func (rm *RetryManager) ShouldRetryWithDecision(errorCtx *handlers.ErrorContext,
 	// localAttempt: 用于退避计算和端点内重试判断
 	// globalAttempt: 仅用于限流策略和全局挂起判断
 
+	// 上游返回了结构化错误（error.type），按决策表决定重试/切换/冷却
+	if errorCtx.UpstreamErrorType != "" {
+		if policy, ok := handlers.LookupUpstreamErrorPolicy(errorCtx.UpstreamErrorType); ok {
+			return rm.decideByUpstreamPolicy(errorCtx, policy, localAttempt)
+		}
+	}
+
 	// 使用 ErrorType 枚举进行类型安全的判断
 	switch errorCtx.ErrorType {
 	case handlers.ErrorTypeClientCancel:
@@ -296,6 +305,26 @@ func (rm *RetryManager) ShouldRetryWithDecision(errorCtx *handlers.ErrorContext,
 			Reason:           "流式处理错误，不重试避免重复计费",
 		}
 
+	case handlers.ErrorTypeOverloaded:
+		// 上游过载：同端点重试意义不大，立即切换端点
+		return handlers.RetryDecision{
+			RetrySameEndpoint: false,
+			SwitchEndpoint:    true,
+			SuspendRequest:    false,
+			CooldownEndpoint:  true,
+			Reason:           "上游过载，立即切换端点",
+		}
+
+	case handlers.ErrorTypeFakeSuccess:
+		// 中转站假成功（200 + 错误内容）：切换端点并冷却
+		return handlers.RetryDecision{
+			RetrySameEndpoint: false,
+			SwitchEndpoint:    true,
+			SuspendRequest:    false,
+			CooldownEndpoint:  true,
+			Reason:            "响应校验失败（假成功），切换端点",
+		}
+
 	case handlers.ErrorTypeAuth:
 		// 2025-12-10: 认证/权限错误（401/403）支持故障转移
 		// 不同端点可能配置了不同的 token，切换端点可能解决问题
@@ -359,6 +388,43 @@ func (rm *RetryManager) ShouldRetryWithDecision(errorCtx *handlers.ErrorContext,
 	}
 }
 
+// decideByUpstreamPolicy 按上游错误类型决策表生成重试决策
+// 同端点重试次数仍受 Retry.MaxAttempts 约束；切换端点时按表决定是否冷却当前端点
+func (rm *RetryManager) decideByUpstreamPolicy(errorCtx *handlers.ErrorContext, policy handlers.UpstreamErrorPolicy, localAttempt int) handlers.RetryDecision {
+	errType := errorCtx.UpstreamErrorType
+
+	if policy.RetrySameEndpoint && localAttempt < rm.config.Retry.MaxAttempts {
+		delay := rm.calculateBackoff(localAttempt)
+		if policy.ErrorType == handlers.ErrorTypeRateLimit {
+			delay = rm.calculateRateLimitBackoff(localAttempt)
+		}
+		return handlers.RetryDecision{
+			RetrySameEndpoint: true,
+			SwitchEndpoint:    false,
+			SuspendRequest:    policy.ErrorType == handlers.ErrorTypeRateLimit && delay > 30*time.Second,
+			Delay:             delay,
+			Reason:            fmt.Sprintf("上游错误 %s，在同一端点重试", errType),
+		}
+	}
+
+	if policy.SwitchEndpoint {
+		return handlers.RetryDecision{
+			RetrySameEndpoint: false,
+			SwitchEndpoint:    true,
+			SuspendRequest:    false,
+			CooldownEndpoint:  policy.CooldownEndpoint,
+			Reason:            fmt.Sprintf("上游错误 %s，切换端点", errType),
+		}
+	}
+
+	return handlers.RetryDecision{
+		RetrySameEndpoint: false,
+		SwitchEndpoint:    false,
+		SuspendRequest:    false,
+		FinalStatus:       policy.FinalStatus,
+		Reason:            fmt.Sprintf("上游错误 %s，请求本身无效，不重试也不切换端点", errType),
+	}
+}
 
 // GetDefaultStatusCodeForFinalStatus 根据最终状态获取默认HTTP状态码
 func GetDefaultStatusCodeForFinalStatus(finalStatus string) int {
@@ -369,6 +435,8 @@ func GetDefaultStatusCodeForFinalStatus(finalStatus string) int {
 		return http.StatusUnauthorized
 	case "rate_limited":
 		return http.StatusTooManyRequests
+	case "overloaded":
+		return 529
 	case "error":
 		return http.StatusBadRequest
 	default:
@@ -432,6 +432,42 @@ func TestRetryManager_Integration_RetryFlow(t *testing.T) {
 	assert.Equal(t, time.Duration(0), delay)
 }
 
+func TestRetryManager_ShouldRetryWithDecision_UpstreamErrorType(t *testing.T) {
+	rm := createTestRetryManager()
+	erm := &ErrorRecoveryManagerAdapter{innerManager: NewErrorRecoveryManager(nil)}
+
+	testCases := []struct {
+		name         string
+		err          error
+		localAttempt int
+		retrySame    bool
+		switchEp     bool
+		cooldown     bool
+		finalStatus  string
+	}{
+		{"invalid_request 直接返回", handlers.NewUpstreamHTTPError(400, []byte(`{"type":"error","error":{"type":"invalid_request_error","message":"bad"}}`)), 1, false, false, false, "error"},
+		{"authentication 切换并冷却", handlers.NewUpstreamHTTPError(401, nil), 1, false, true, true, ""},
+		{"not_found 切换不冷却", handlers.NewUpstreamHTTPError(404, nil), 1, false, true, false, ""},
+		{"rate_limit 同端点重试", handlers.NewUpstreamHTTPError(429, nil), 1, true, false, false, ""},
+		{"rate_limit 耗尽后切换并冷却", handlers.NewUpstreamHTTPError(429, nil), 3, false, true, true, ""},
+		{"overloaded 立即切换并冷却", handlers.NewUpstreamHTTPError(529, nil), 1, false, true, true, ""},
+		{"SSE overloaded_error", handlers.NewUpstreamStreamError("overloaded_error", "Overloaded"), 1, false, true, true, ""},
+		{"假成功切换并冷却", &handlers.FakeSuccessError{Validator: handlers.ValidatorErrorJSON, Reason: "响应体为错误对象", StatusCode: 200}, 1, false, true, true, ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			errorCtx := erm.ClassifyError(tc.err, "test-req", "test-endpoint-1", "test-group", tc.localAttempt-1)
+			decision := rm.ShouldRetryWithDecision(&errorCtx, tc.localAttempt, tc.localAttempt, false)
+
+			assert.Equal(t, tc.retrySame, decision.RetrySameEndpoint, "RetrySameEndpoint")
+			assert.Equal(t, tc.switchEp, decision.SwitchEndpoint, "SwitchEndpoint")
+			assert.Equal(t, tc.cooldown, decision.CooldownEndpoint, "CooldownEndpoint")
+			assert.Equal(t, tc.finalStatus, decision.FinalStatus, "FinalStatus")
+		})
+	}
+}
+
 // 基准测试：测试重试决策的性能
 func BenchmarkRetryManager_ShouldRetry(b *testing.B) {
 	rm := createTestRetryManager()
@@ -0,0 +1,64 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// maxSessionIDLength 会话标识最大长度（防止异常请求写入超长值）
+const maxSessionIDLength = 128
+
+// legacySessionPattern 旧版 Claude Code user_id 格式：user_<hash>_account_<uuid>_session_<uuid>
+var legacySessionPattern = regexp.MustCompile(`_session_([A-Za-z0-9-]+)$`)
+
+// extractSessionID 从已解码请求体的 metadata.user_id 中提取 Claude Code 会话标识，缺失时回退到会话请求头
+// sniff 仅对 /v1/messages 相关路径解码（见 sniffRequestBody），请求头后备对所有路径生效
+func extractSessionID(sniff *requestSniff, header http.Header, headerName string) string {
+	if sniff != nil {
+		if sessionID := parseClaudeCodeUserID(sniff.Metadata.UserID); sessionID != "" {
+			return sessionID
+		}
+	}
+
+	if headerName == "" || header == nil {
+		return ""
+	}
+	return sanitizeSessionID(header.Get(headerName))
+}
+
+// parseClaudeCodeUserID 解析 metadata.user_id 中的会话标识
+// 兼容两种格式：
+//   - JSON 字符串：{"device_id":"...","account_uuid":"...","session_id":"..."}
+//   - 旧版拼接格式：user_<hash>_account_<uuid>_session_<uuid>
+func parseClaudeCodeUserID(userID string) string {
+	userID = strings.TrimSpace(userID)
+	if userID == "" {
+		return ""
+	}
+
+	if strings.HasPrefix(userID, "{") {
+		var payload struct {
+			SessionID string `json:"session_id"`
+		}
+		if err := json.Unmarshal([]byte(userID), &payload); err == nil {
+			return sanitizeSessionID(payload.SessionID)
+		}
+		return ""
+	}
+
+	if m := legacySessionPattern.FindStringSubmatch(userID); len(m) == 2 {
+		return sanitizeSessionID(m[1])
+	}
+	return ""
+}
+
+// sanitizeSessionID 规范化会话标识（去除空白、限制长度）
+func sanitizeSessionID(sessionID string) string {
+	sessionID = strings.TrimSpace(sessionID)
+	if len(sessionID) > maxSessionIDLength {
+		sessionID = sessionID[:maxSessionIDLength]
+	}
+	return sessionID
+}
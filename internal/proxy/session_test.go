@@ -0,0 +1,85 @@
+package proxy
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// TestExtractSessionID 测试从 metadata.user_id 与会话请求头提取 Claude Code 会话标识
+func TestExtractSessionID(t *testing.T) {
+	const headerName = "X-Claude-Code-Session-Id"
+	header := http.Header{}
+	header.Set(headerName, "header-session")
+
+	tests := []struct {
+		name   string
+		body   string
+		path   string
+		header http.Header
+		want   string
+	}{
+		{
+			name: "旧版拼接格式",
+			body: `{"model":"claude-sonnet-4","metadata":{"user_id":"user_abc123_account_4f2d-11aa_session_9b1e6c2a-0d7f-4e58-8a4c-2f9d3b7e1c55"}}`,
+			path: "/v1/messages",
+			want: "9b1e6c2a-0d7f-4e58-8a4c-2f9d3b7e1c55",
+		},
+		{
+			name: "JSON 字符串格式",
+			body: `{"model":"claude-sonnet-4","metadata":{"user_id":"{\"device_id\":\"dev\",\"account_uuid\":\"acc\",\"session_id\":\"sess-json\"}"}}`,
+			path: "/v1/messages",
+			want: "sess-json",
+		},
+		{
+			name:   "user_id 不含会话时回退请求头",
+			body:   `{"model":"claude-sonnet-4","metadata":{"user_id":"plain-user"}}`,
+			path:   "/v1/messages",
+			header: header,
+			want:   "header-session",
+		},
+		{
+			name:   "请求体优先于请求头",
+			body:   `{"metadata":{"user_id":"user_x_account_y_session_body-session"}}`,
+			path:   "/v1/messages",
+			header: header,
+			want:   "body-session",
+		},
+		{
+			name:   "非 messages 路径只使用请求头",
+			body:   `{"metadata":{"user_id":"user_x_account_y_session_body-session"}}`,
+			path:   "/v1/models",
+			header: header,
+			want:   "header-session",
+		},
+		{
+			name: "无会话信息",
+			body: `{"model":"claude-sonnet-4"}`,
+			path: "/v1/messages",
+			want: "",
+		},
+		{
+			name: "非法 JSON",
+			body: `not json`,
+			path: "/v1/messages",
+			want: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := extractSessionID(sniffRequestBody([]byte(tt.body), tt.path), tt.header, headerName); got != tt.want {
+				t.Errorf("extractSessionID() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	long := http.Header{}
+	long.Set(headerName, strings.Repeat("x", 300))
+	if got := extractSessionID(nil, long, headerName); len(got) != maxSessionIDLength {
+		t.Errorf("超长会话标识应截断为 %d, got %d", maxSessionIDLength, len(got))
+	}
+	if got := extractSessionID(nil, header, ""); got != "" {
+		t.Errorf("未配置会话请求头时不应读取请求头, got %q", got)
+	}
+}
@@ -2,6 +2,7 @@ package proxy
 
 import (
 	"bufio"
+	"bytes"
 	"context"
 	"fmt"
 	"io"
@@ -11,6 +12,7 @@ import (
 	"sync"
 	"time"
 
+	"cc-forwarder/internal/proxy/handlers"
 	"cc-forwarder/internal/proxy/response"
 	"cc-forwarder/internal/tracking"
 	"cc-forwarder/internal/utils"
@@ -57,6 +59,10 @@ type StreamProcessor struct {
 	// 完成状态跟踪
 	completionRecorded bool // 是否已经记录完成状态，防止重复记录
 
+	// 响应时间线（按数据块到达时刻记录，不受后台解析延迟影响）
+	firstEventAt   time.Time // 首个 SSE 事件到达时间
+	firstContentAt time.Time // 首个完整 content_block_delta 事件所在数据块的到达时间
+
 	// 🔍 [调试缓冲区] 轻量级调试数据收集（仅在token解析失败时使用）
 	debugLines []string // SSE行数据收集，最多保存DebugLineLimit行
 }
@@ -127,6 +133,9 @@ func (sp *StreamProcessor) ProcessStream(ctx context.Context, resp *http.Respons
 		if n > 0 {
 			chunk := buffer[:n]
 
+			// 记录首个事件的到达时间
+			sp.markStreamTimings(chunk)
+
 			// 保存部分数据用于错误恢复
 			sp.savePartialData(chunk)
 
@@ -166,6 +175,44 @@ func (sp *StreamProcessor) ProcessStream(ctx context.Context, resp *http.Respons
 	}
 }
 
+// markStreamTimings 记录首个 SSE 事件的到达时间
+// 首个内容增量需要完整的事件行，由后台解析在 content_block_delta 解析完成时记录（见 markContentTiming）
+func (sp *StreamProcessor) markStreamTimings(chunk []byte) {
+	if !sp.firstEventAt.IsZero() {
+		return
+	}
+	if bytes.Contains(chunk, []byte("event:")) || bytes.Contains(chunk, []byte("data:")) {
+		sp.firstEventAt = time.Now()
+	}
+}
+
+// markContentTiming 解析器完成首个 content_block_delta 事件时，记录完成该事件的数据块到达时间（调用方需持有 parseMutex）
+func (sp *StreamProcessor) markContentTiming(arrivedAt time.Time) {
+	if sp.firstContentAt.IsZero() && sp.tokenParser.HasContentDelta() {
+		sp.firstContentAt = arrivedAt
+	}
+}
+
+// BytesReceived 返回已接收的上游响应字节数
+func (sp *StreamProcessor) BytesReceived() int64 {
+	return sp.bytesProcessed
+}
+
+// ResponseMeta 返回已解析的响应元信息（停止原因、消息 ID、工具调用与 thinking 内容块）
+func (sp *StreamProcessor) ResponseMeta() tracking.ResponseMeta {
+	if sp.tokenParser == nil {
+		return tracking.ResponseMeta{}
+	}
+	return sp.tokenParser.GetResponseMeta()
+}
+
+// StreamTimings 返回首个 SSE 事件与首个内容增量的到达时间（未收到时为零值）
+func (sp *StreamProcessor) StreamTimings() (firstEventAt, firstContentAt time.Time) {
+	sp.parseMutex.Lock()
+	defer sp.parseMutex.Unlock()
+	return sp.firstEventAt, sp.firstContentAt
+}
+
 // forwardToClient 立即转发数据到客户端
 func (sp *StreamProcessor) forwardToClient(data []byte) error {
 	// 写入数据到响应
@@ -184,6 +231,7 @@ func (sp *StreamProcessor) forwardToClient(data []byte) error {
 func (sp *StreamProcessor) parseTokensInBackground(data []byte) {
 	// 为每个数据块启动一个后台goroutine
 	sp.parseWg.Add(1)
+	arrivedAt := time.Now()
 
 	go func() {
 		defer sp.parseWg.Done()
@@ -206,6 +254,7 @@ func (sp *StreamProcessor) parseTokensInBackground(data []byte) {
 
 				// ✅ 修复：处理所有行，包括空行（空行触发SSE事件解析）
 				sp.processSSELine(line)
+				sp.markContentTiming(arrivedAt)
 
 				// 重置行缓冲区，准备下一行
 				sp.lineBuffer = sp.lineBuffer[:0]
@@ -233,7 +282,7 @@ func (sp *StreamProcessor) processSSELine(line string) {
 				sp.requestID, result.ErrorInfo.Type, result.ErrorInfo.Message))
 
 			// 将错误信息存储，供上层生命周期管理器处理
-			sp.lastAPIError = fmt.Errorf("API错误 %s: %s", result.ErrorInfo.Type, result.ErrorInfo.Message)
+			sp.lastAPIError = handlers.NewUpstreamStreamError(result.ErrorInfo.Type, result.ErrorInfo.Message)
 			return
 		}
 
@@ -350,6 +399,19 @@ func (sp *StreamProcessor) handlePartialStream(err error) error {
 	return err
 }
 
+// upstreamStreamErrorStatus 按 SSE error 事件的 error.type 查询决策表中的最终状态
+// 非结构化上游错误或未知类型返回空字符串
+func upstreamStreamErrorStatus(err error) string {
+	upErr, ok := handlers.AsUpstreamError(err)
+	if !ok {
+		return ""
+	}
+	if policy, found := handlers.LookupUpstreamErrorPolicy(upErr.Type); found {
+		return policy.FinalStatus
+	}
+	return ""
+}
+
 // ProcessStreamWithRetry 流式处理入口（无重试版本）
 // 2025-12-11: 移除无效的重试循环，流式传输阶段不重试以避免重复计费
 // 原因：resp.Body 在 ProcessStream 返回后会被关闭，重试无法重新读取
@@ -374,7 +436,10 @@ func (sp *StreamProcessor) ProcessStreamWithRetry(ctx context.Context, resp *htt
 			// 根据API错误内容智能确定状态
 			status := "stream_error"
 			errorMsg := sp.lastAPIError.Error()
-			if strings.Contains(errorMsg, "rate") || strings.Contains(errorMsg, "429") {
+			if policyStatus := upstreamStreamErrorStatus(sp.lastAPIError); policyStatus != "" {
+				// 优先按上游 error.type 决策表确定状态
+				status = policyStatus
+			} else if strings.Contains(errorMsg, "rate") || strings.Contains(errorMsg, "429") {
 				status = "rate_limited"
 			} else if strings.Contains(errorMsg, "timeout") || strings.Contains(errorMsg, "deadline") {
 				status = "timeout"
@@ -451,6 +516,7 @@ func (sp *StreamProcessor) waitForBackgroundParsing() {
 		line := strings.TrimSpace(string(sp.lineBuffer))
 		if len(line) > 0 {
 			sp.processSSELine(line)
+			sp.markContentTiming(time.Now())
 		}
 		sp.lineBuffer = sp.lineBuffer[:0]
 	}
@@ -462,7 +528,7 @@ func (sp *StreamProcessor) waitForBackgroundParsing() {
 		if result.ErrorInfo != nil {
 			slog.Error(fmt.Sprintf("❌ [Flush错误] [%s] 类型: %s, 消息: %s",
 				sp.requestID, result.ErrorInfo.Type, result.ErrorInfo.Message))
-			sp.lastAPIError = fmt.Errorf("API错误 %s: %s", result.ErrorInfo.Type, result.ErrorInfo.Message)
+			sp.lastAPIError = handlers.NewUpstreamStreamError(result.ErrorInfo.Type, result.ErrorInfo.Message)
 		} else if result.TokenUsage != nil {
 			slog.Debug(fmt.Sprintf("🔄 [Flush成功] [%s] 成功解析待处理事件的Token信息", sp.requestID))
 		}
@@ -517,6 +583,8 @@ func (sp *StreamProcessor) Reset() {
 	sp.lineBuffer = sp.lineBuffer[:0]
 	sp.partialData = sp.partialData[:0] // 重置部分数据缓冲区
 	sp.parseErrors = sp.parseErrors[:0]
+	sp.firstEventAt = time.Time{}
+	sp.firstContentAt = time.Time{}
 
 	// 重置TokenParser状态
 	if sp.tokenParser != nil {
@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"strings"
 	"testing"
+	"time"
 )
 
 // mockResponseWriter 实现 http.ResponseWriter 和 http.Flusher
@@ -187,4 +188,41 @@ type mockNetError struct {
 
 func (e *mockNetError) Error() string {
 	return e.msg
-}
\ No newline at end of file
+}
+
+// TestStreamProcessor_MarkStreamTimings 测试首个事件与首个内容增量的时间记录（内容增量跨数据块拆分）
+func TestStreamProcessor_MarkStreamTimings(t *testing.T) {
+	tokenParser := NewTokenParser()
+	writer := &mockResponseWriter{}
+	processor := NewStreamProcessor(tokenParser, nil, writer, writer, "test-timings", "endpoint")
+
+	feed := func(chunk string) {
+		processor.markStreamTimings([]byte(chunk))
+		processor.parseTokensInBackground([]byte(chunk))
+		processor.parseWg.Wait()
+	}
+
+	feed("event: message_start\ndata: {\"type\":\"message_start\"}\n\n")
+	firstEvent, firstContent := processor.StreamTimings()
+	if firstEvent.IsZero() || !firstContent.IsZero() {
+		t.Fatalf("首个事件应已记录且首个内容未记录: %v %v", firstEvent, firstContent)
+	}
+
+	// content_block_delta 事件被拆分到两个数据块：第一块不完整，不应记录
+	feed("event: content_block_delta\ndata: {\"type\":\"content_bl")
+	if _, c := processor.StreamTimings(); !c.IsZero() {
+		t.Fatalf("事件未完整到达时不应记录首个内容: %v", c)
+	}
+
+	secondChunkAt := time.Now()
+	feed("ock_delta\"}\n\n")
+	event2, firstContent := processor.StreamTimings()
+	if !event2.Equal(firstEvent) || firstContent.IsZero() || firstContent.Before(secondChunkAt) {
+		t.Errorf("首个内容增量应按完成事件的数据块记录: event=%v content=%v second=%v", event2, firstContent, secondChunkAt)
+	}
+
+	processor.Reset()
+	if e, c := processor.StreamTimings(); !e.IsZero() || !c.IsZero() {
+		t.Error("Reset 后时间线应清空")
+	}
+}
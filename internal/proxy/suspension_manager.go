@@ -10,6 +10,7 @@ import (
 
 	"cc-forwarder/config"
 	"cc-forwarder/internal/endpoint"
+	"cc-forwarder/internal/events"
 	"cc-forwarder/internal/proxy/handlers" // 🎯 [挂起取消区分] 新增handlers包导入
 )
 
@@ -20,6 +21,7 @@ type SuspensionManager struct {
 	endpointManager       *endpoint.Manager
 	groupManager          *endpoint.GroupManager
 	recoverySignalManager *EndpointRecoverySignalManager // 端点恢复信号管理器
+	eventBus              events.EventBus                // 挂起超时事件通知（可选）
 
 	// 挂起请求计数相关字段
 	suspendedRequestsMutex sync.RWMutex
@@ -200,6 +202,7 @@ func (sm *SuspensionManager) WaitForGroupSwitch(ctx context.Context, connID stri
 		// 挂起超时
 		if errors.Is(timeoutCtx.Err(), context.DeadlineExceeded) {
 			slog.WarnContext(ctx, fmt.Sprintf("⏰ [挂起超时] 连接 %s 挂起等待超时 (%v)，停止等待", connID, timeout))
+			sm.notifySuspendTimeout(connID, "", timeout)
 		} else {
 			slog.InfoContext(ctx, fmt.Sprintf("🔄 [上下文取消] 连接 %s 挂起期间上下文被取消", connID))
 		}
@@ -226,6 +229,35 @@ func (sm *SuspensionManager) GetSuspendedRequestsCount() int {
 	return sm.suspendedRequestsCount
 }
 
+// SetEventBus 设置EventBus事件总线（挂起超时时发布事件）
+func (sm *SuspensionManager) SetEventBus(eventBus events.EventBus) {
+	sm.eventBus = eventBus
+}
+
+// notifySuspendTimeout 发布挂起请求超时事件
+func (sm *SuspensionManager) notifySuspendTimeout(connID, failedEndpoint string, timeout time.Duration) {
+	if sm.eventBus == nil {
+		return
+	}
+
+	sm.suspendedRequestsMutex.RLock()
+	suspended := sm.suspendedRequestsCount
+	sm.suspendedRequestsMutex.RUnlock()
+
+	sm.eventBus.Publish(events.Event{
+		Type:     events.EventRequestSuspendTimeout,
+		Source:   "suspension_manager",
+		Priority: events.PriorityHigh,
+		Data: map[string]interface{}{
+			"request_id":         connID,
+			"failed_endpoint":    failedEndpoint,
+			"timeout_seconds":    int(timeout.Seconds()),
+			"suspended_requests": suspended,
+			"timestamp":          time.Now().Format("2006-01-02 15:04:05"),
+		},
+	})
+}
+
 // UpdateConfig 更新配置
 func (sm *SuspensionManager) UpdateConfig(cfg *config.Config) {
 	sm.config = cfg
@@ -333,6 +365,7 @@ func (sm *SuspensionManager) WaitForEndpointRecovery(ctx context.Context, connID
 			// ⏰ [优先级3] 挂起超时
 			if errors.Is(timeoutCtx.Err(), context.DeadlineExceeded) {
 				slog.WarnContext(ctx, fmt.Sprintf("⏰ [挂起超时] 连接 %s 挂起等待超时 (%v)，停止等待", connID, timeout))
+				sm.notifySuspendTimeout(connID, failedEndpoint, timeout)
 			} else {
 				slog.InfoContext(ctx, fmt.Sprintf("🔄 [上下文取消] 连接 %s 挂起期间上下文被取消", connID))
 			}
@@ -450,6 +483,7 @@ func (sm *SuspensionManager) WaitForEndpointRecoveryWithResult(ctx context.Conte
 			// ⏰ [优先级3] 挂起超时
 			if errors.Is(timeoutCtx.Err(), context.DeadlineExceeded) {
 				slog.WarnContext(ctx, fmt.Sprintf("⏰ [挂起超时] 连接 %s 挂起等待超时 (%v)，停止等待", connID, timeout))
+				sm.notifySuspendTimeout(connID, failedEndpoint, timeout)
 			} else {
 				slog.InfoContext(ctx, fmt.Sprintf("🔄 [上下文取消] 连接 %s 挂起期间超时上下文被取消", connID))
 			}
@@ -0,0 +1,87 @@
+package proxy
+
+import (
+	"net/http"
+	"strings"
+
+	"cc-forwarder/internal/tracking"
+)
+
+const (
+	// projectPathPrefix 项目路径前缀：/p/<project>/v1/messages 转发时剥离为 /v1/messages
+	projectPathPrefix = "/p/"
+	// projectTagKey 路径前缀对应的标签键
+	projectTagKey = "project"
+	// tagHeaderPrefix 标签请求头的通用前缀（X-CC-Project → project）
+	tagHeaderPrefix = "x-cc-"
+	// maxTagValueLength 标签值最大长度
+	maxTagValueLength = 128
+	// maxRequestTags 单个请求最多记录的标签数
+	maxRequestTags = 16
+)
+
+// extractRequestTags 从 /p/<project>/ 路径前缀与标签请求头提取请求标签
+// 会剥离路径前缀并移除标签请求头，二者都不会转发到上游
+func extractRequestTags(r *http.Request, headerNames []string) []tracking.RequestTag {
+	var tags []tracking.RequestTag
+	add := func(key, value string) {
+		value = strings.TrimSpace(value)
+		if value == "" || len(tags) >= maxRequestTags {
+			return
+		}
+		if len(value) > maxTagValueLength {
+			value = value[:maxTagValueLength]
+		}
+		tag := tracking.RequestTag{Key: key, Value: value}
+		for _, existing := range tags {
+			if existing == tag {
+				return
+			}
+		}
+		tags = append(tags, tag)
+	}
+
+	if project, rest, ok := splitProjectPath(r.URL.Path); ok {
+		r.URL.Path = rest
+		r.URL.RawPath = ""
+		add(projectTagKey, project)
+	}
+
+	for _, name := range headerNames {
+		values := r.Header.Values(name)
+		if len(values) == 0 {
+			continue
+		}
+		key := tagKeyFromHeader(name)
+		for _, value := range values {
+			for _, part := range strings.Split(value, ",") {
+				add(key, part)
+			}
+		}
+		r.Header.Del(name)
+	}
+
+	return tags
+}
+
+// splitProjectPath 解析 /p/<project>/<rest> 路径，返回项目名与剥离前缀后的路径
+func splitProjectPath(path string) (project, rest string, ok bool) {
+	if !strings.HasPrefix(path, projectPathPrefix) {
+		return "", path, false
+	}
+	remainder := path[len(projectPathPrefix):]
+	idx := strings.Index(remainder, "/")
+	if idx <= 0 {
+		return "", path, false
+	}
+	return remainder[:idx], remainder[idx:], true
+}
+
+// tagKeyFromHeader 由请求头名称得到标签键（小写并去掉 X-CC- 前缀）
+func tagKeyFromHeader(name string) string {
+	key := strings.ToLower(strings.TrimSpace(name))
+	if trimmed := strings.TrimPrefix(key, tagHeaderPrefix); trimmed != "" {
+		key = trimmed
+	}
+	return key
+}
@@ -0,0 +1,66 @@
+package proxy
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"cc-forwarder/internal/tracking"
+)
+
+// TestExtractRequestTags 测试从项目路径前缀与标签请求头提取请求标签
+func TestExtractRequestTags(t *testing.T) {
+	headers := []string{"X-CC-Project", "X-CC-Tag", "X-Cost-Center"}
+
+	r := httptest.NewRequest("POST", "/p/alpha/v1/messages?beta=true", nil)
+	r.Header.Set("X-CC-Tag", "ci, nightly")
+	r.Header.Add("X-CC-Tag", "ci")
+	r.Header.Set("X-Cost-Center", "rd-01")
+	r.Header.Set("X-CC-Project", "alpha")
+
+	tags := extractRequestTags(r, headers)
+	want := []tracking.RequestTag{
+		{Key: "project", Value: "alpha"},
+		{Key: "tag", Value: "ci"},
+		{Key: "tag", Value: "nightly"},
+		{Key: "x-cost-center", Value: "rd-01"},
+	}
+	if len(tags) != len(want) {
+		t.Fatalf("标签数量不符: %v", tags)
+	}
+	for i := range want {
+		if tags[i] != want[i] {
+			t.Errorf("标签[%d] = %v, want %v", i, tags[i], want[i])
+		}
+	}
+
+	if r.URL.Path != "/v1/messages" || r.URL.RawQuery != "beta=true" {
+		t.Errorf("应剥离项目路径前缀并保留查询参数: path=%s query=%s", r.URL.Path, r.URL.RawQuery)
+	}
+	for _, name := range headers {
+		if r.Header.Get(name) != "" {
+			t.Errorf("标签请求头 %s 不应转发到上游", name)
+		}
+	}
+}
+
+// TestSplitProjectPath 测试项目路径前缀解析
+func TestSplitProjectPath(t *testing.T) {
+	tests := []struct {
+		path, project, rest string
+		ok                  bool
+	}{
+		{"/p/alpha/v1/messages", "alpha", "/v1/messages", true},
+		{"/p/alpha/", "alpha", "/", true},
+		{"/p/alpha", "", "/p/alpha", false},
+		{"/p//v1/messages", "", "/p//v1/messages", false},
+		{"/v1/messages", "", "/v1/messages", false},
+		{"/pp/alpha/v1/messages", "", "/pp/alpha/v1/messages", false},
+	}
+	for _, tt := range tests {
+		project, rest, ok := splitProjectPath(tt.path)
+		if project != tt.project || rest != tt.rest || ok != tt.ok {
+			t.Errorf("splitProjectPath(%q) = (%q, %q, %v), want (%q, %q, %v)",
+				tt.path, project, rest, ok, tt.project, tt.rest, tt.ok)
+		}
+	}
+}
@@ -54,6 +54,22 @@ type UsageData struct {
 	CacheReadInputTokens     int64 `json:"cache_read_input_tokens"`
 	// v5.0+: 分开的缓存创建详情
 	CacheCreation *CacheCreationDetail `json:"cache_creation,omitempty"`
+	// 服务端工具调用次数（web search / web fetch，按次计费）
+	ServerToolUse *ServerToolUseData `json:"server_tool_use,omitempty"`
+}
+
+// ServerToolUseData 表示服务端工具调用统计
+type ServerToolUseData struct {
+	WebSearchRequests int64 `json:"web_search_requests"`
+	WebFetchRequests  int64 `json:"web_fetch_requests"`
+}
+
+// serverToolRequests 返回服务端工具调用次数（未返回时为 0）
+func (u *UsageData) serverToolRequests() (webSearch, webFetch int64) {
+	if u == nil || u.ServerToolUse == nil {
+		return 0, 0
+	}
+	return u.ServerToolUse.WebSearchRequests, u.ServerToolUse.WebFetchRequests
 }
 
 // CacheCreationDetail 表示缓存创建详情（分开的 5m/1h）
@@ -127,6 +143,12 @@ type TokenParser struct {
 	hasMessageStart      bool // 是否收到 message_start 事件
 	hasMessageDeltaUsage bool // 是否收到带 usage 的 message_delta 事件
 	hasMessageStop       bool // 是否收到 message_stop 事件
+
+	// 响应元信息（停止原因、消息 ID、工具调用与 thinking 内容块）
+	responseMeta tracking.ResponseMeta
+
+	// 是否已收到完整的 content_block_delta 事件（首个内容增量）
+	hasContentDelta bool
 }
 
 // fixMalformedEventType 修复格式错误的事件类型
@@ -198,12 +220,18 @@ func (tp *TokenParser) ParseSSELineV2(line string) *ParseResult {
 			tp.hasMessageStop = true
 		}
 
-		// 为message_start（模型信息）、message_delta（使用量）和error事件收集数据
-		tp.collectingData = eventType == "message_delta" || eventType == "message_start" || eventType == "error"
+		// 为message_start（模型信息）、message_delta（使用量）、error事件和content_block_start（内容块类型）收集数据
+		tp.collectingData = eventType == "message_delta" || eventType == "message_start" || eventType == "error" || eventType == "content_block_start"
 		tp.eventBuffer.Reset()
 		return nil
 	}
 
+	// content_block_delta 不收集数据，其 data 行完整到达即视为首个内容增量
+	if tp.currentEvent == "content_block_delta" && strings.HasPrefix(line, "data:") {
+		tp.hasContentDelta = true
+		return nil
+	}
+
 	// 处理数据行 - 支持 "data: " 和 "data:" 两种格式
 	if strings.HasPrefix(line, "data:") && tp.collectingData {
 		var dataContent string
@@ -218,7 +246,12 @@ func (tp *TokenParser) ParseSSELineV2(line string) *ParseResult {
 
 	// 处理表示SSE事件结束的空行
 	if line == "" && tp.collectingData && tp.eventBuffer.Len() > 0 {
+		tp.responseMeta.Observe([]byte(tp.eventBuffer.String()))
 		switch tp.currentEvent {
+		case "content_block_start":
+			// 仅用于统计内容块类型
+			tp.skipCollectedEvent()
+			return nil
 		case "message_start":
 			// 仅解析message_start以获取模型信息（不需要ParseResult）
 			tp.parseMessageStart()
@@ -252,8 +285,8 @@ func (tp *TokenParser) ParseSSELine(line string) *monitor.TokenUsage {
 		eventType = tp.fixMalformedEventType(eventType)
 
 		tp.currentEvent = eventType
-		// 为message_start（模型信息）、message_delta（使用量）和error事件收集数据
-		tp.collectingData = eventType == "message_delta" || eventType == "message_start" || eventType == "error"
+		// 为message_start（模型信息）、message_delta（使用量）、error事件和content_block_start（内容块类型）收集数据
+		tp.collectingData = eventType == "message_delta" || eventType == "message_start" || eventType == "error" || eventType == "content_block_start"
 		tp.eventBuffer.Reset()
 		return nil
 	}
@@ -272,7 +305,12 @@ func (tp *TokenParser) ParseSSELine(line string) *monitor.TokenUsage {
 
 	// 处理表示SSE事件结束的空行
 	if line == "" && tp.collectingData && tp.eventBuffer.Len() > 0 {
+		tp.responseMeta.Observe([]byte(tp.eventBuffer.String()))
 		switch tp.currentEvent {
+		case "content_block_start":
+			// 仅用于统计内容块类型
+			tp.skipCollectedEvent()
+			return nil
 		case "message_start":
 			// 解析message_start以获取模型信息和token使用量
 			return tp.parseMessageStart()
@@ -340,6 +378,8 @@ func (tp *TokenParser) parseMessageStart() *monitor.TokenUsage {
 			cacheCreationTotal = cache5m + cache1h
 		}
 
+		webSearch, webFetch := usage.serverToolRequests()
+
 		tp.partialUsage = &tracking.TokenUsage{
 			InputTokens:           usage.InputTokens,
 			OutputTokens:          usage.OutputTokens,
@@ -347,6 +387,8 @@ func (tp *TokenParser) parseMessageStart() *monitor.TokenUsage {
 			CacheReadTokens:       usage.CacheReadInputTokens,
 			CacheCreation5mTokens: cache5m,
 			CacheCreation1hTokens: cache1h,
+			WebSearchRequests:     webSearch,
+			WebFetchRequests:      webFetch,
 		}
 
 		slog.Info(fmt.Sprintf("🎯 [Usage初始化] [%s] 从message_start提取token信息: input=%d, output=%d, cache_create=%d (5m=%d, 1h=%d), cache_read=%d",
@@ -467,6 +509,15 @@ func (tp *TokenParser) parseMessageDeltaV2() *ParseResult {
 			tp.requestID, inputTokens))
 	}
 
+	// 服务端工具调用次数合并：message_delta 中为累计值，缺失时保留 message_start 的值
+	webSearch, webFetch := usage.serverToolRequests()
+	if webSearch == 0 && tp.partialUsage != nil {
+		webSearch = tp.partialUsage.WebSearchRequests
+	}
+	if webFetch == 0 && tp.partialUsage != nil {
+		webFetch = tp.partialUsage.WebFetchRequests
+	}
+
 	// ✅ 设置finalUsage供GetFinalUsage()方法使用
 	tp.finalUsage = &tracking.TokenUsage{
 		InputTokens:           inputTokens,
@@ -475,6 +526,13 @@ func (tp *TokenParser) parseMessageDeltaV2() *ParseResult {
 		CacheReadTokens:       cacheReadTokens,
 		CacheCreation5mTokens: cache5m,
 		CacheCreation1hTokens: cache1h,
+		WebSearchRequests:     webSearch,
+		WebFetchRequests:      webFetch,
+	}
+
+	if webSearch > 0 || webFetch > 0 {
+		slog.Info(fmt.Sprintf("🔎 [服务端工具] [%s] web_search=%d, web_fetch=%d",
+			tp.requestID, webSearch, webFetch))
 	}
 
 	// 如果有分开的缓存信息，记录日志
@@ -551,6 +609,8 @@ func (tp *TokenParser) parseMessageDelta() *monitor.TokenUsage {
 		CacheReadTokens:     usage.CacheReadInputTokens,
 	}
 
+	webSearch, webFetch := usage.serverToolRequests()
+
 	// ✅ 设置finalUsage供GetFinalUsage()方法使用
 	tp.finalUsage = &tracking.TokenUsage{
 		InputTokens:           usage.InputTokens,
@@ -559,6 +619,8 @@ func (tp *TokenParser) parseMessageDelta() *monitor.TokenUsage {
 		CacheReadTokens:       usage.CacheReadInputTokens,
 		CacheCreation5mTokens: cache5m,
 		CacheCreation1hTokens: cache1h,
+		WebSearchRequests:     webSearch,
+		WebFetchRequests:      webFetch,
 	}
 
 	// 移除重复的日志记录 - 由StreamProcessor统一处理
@@ -603,6 +665,25 @@ func (tp *TokenParser) Reset() {
 	tp.hasMessageStart = false
 	tp.hasMessageDeltaUsage = false
 	tp.hasMessageStop = false
+	tp.responseMeta = tracking.ResponseMeta{}
+	tp.hasContentDelta = false
+}
+
+// skipCollectedEvent 丢弃已收集的事件数据（不需要进一步解析的事件）
+func (tp *TokenParser) skipCollectedEvent() {
+	tp.eventBuffer.Reset()
+	tp.collectingData = false
+	tp.currentEvent = ""
+}
+
+// HasContentDelta 是否已解析到完整的 content_block_delta 事件
+func (tp *TokenParser) HasContentDelta() bool {
+	return tp.hasContentDelta
+}
+
+// GetResponseMeta 获取已解析的响应元信息（停止原因、消息 ID、工具调用与 thinking 内容块）
+func (tp *TokenParser) GetResponseMeta() tracking.ResponseMeta {
+	return tp.responseMeta
 }
 
 // parseErrorEventV2 新版本的错误事件解析方法
@@ -788,6 +869,14 @@ func (tp *TokenParser) ParseMessageDelta(line string) *tracking.TokenUsage {
 			if cacheRead, ok := usage["cache_read_input_tokens"].(float64); ok {
 				tokenUsage.CacheReadTokens = int64(cacheRead)
 			}
+			if serverToolUse, ok := usage["server_tool_use"].(map[string]interface{}); ok {
+				if webSearch, ok := serverToolUse["web_search_requests"].(float64); ok {
+					tokenUsage.WebSearchRequests = int64(webSearch)
+				}
+				if webFetch, ok := serverToolUse["web_fetch_requests"].(float64); ok {
+					tokenUsage.WebFetchRequests = int64(webFetch)
+				}
+			}
 
 			// 保存最终使用统计
 			tp.finalUsage = tokenUsage
@@ -954,8 +1043,13 @@ func (tp *TokenParser) FlushPendingEvent() *ParseResult {
 		return nil
 	}
 
+	tp.responseMeta.Observe([]byte(tp.eventBuffer.String()))
+
 	// 根据当前事件类型调用相应的解析方法
 	switch tp.currentEvent {
+	case "content_block_start":
+		tp.skipCollectedEvent()
+		return nil
 	case "message_delta":
 		if tp.requestID != "" {
 			slog.Info(fmt.Sprintf("🔄 [事件Flush] [%s] 强制解析缓存的message_delta事件", tp.requestID))
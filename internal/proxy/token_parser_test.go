@@ -407,4 +407,98 @@ func TestTokenParserV2_GetFinalUsageWithCacheCreation(t *testing.T) {
 		finalUsage.InputTokens, finalUsage.OutputTokens,
 		finalUsage.CacheCreation5mTokens, finalUsage.CacheCreation1hTokens,
 		finalUsage.CacheReadTokens)
-}
\ No newline at end of file
+}
+
+// TestTokenParserV2_ServerToolUse 测试解析服务端工具调用次数（web search / web fetch）
+func TestTokenParserV2_ServerToolUse(t *testing.T) {
+	parser := NewTokenParserWithRequestID("test-req-server-tool")
+
+	lines := []string{
+		"event: message_start",
+		`data: {"type":"message_start","message":{"id":"msg-1","type":"message","role":"assistant","model":"claude-sonnet-4-5-20250929","usage":{"input_tokens":1200,"output_tokens":1}}}`,
+		"",
+		"event: message_delta",
+		`data: {"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":300,"server_tool_use":{"web_search_requests":3,"web_fetch_requests":1}}}`,
+		"",
+	}
+
+	var result *ParseResult
+	for _, line := range lines {
+		if parseResult := parser.ParseSSELineV2(line); parseResult != nil {
+			result = parseResult
+		}
+	}
+
+	if result == nil || result.TokenUsage == nil {
+		t.Fatal("Expected TokenUsage in ParseResult, got nil")
+	}
+	if result.TokenUsage.InputTokens != 1200 {
+		t.Errorf("Expected InputTokens=1200 (merged from message_start), got %d", result.TokenUsage.InputTokens)
+	}
+	if result.TokenUsage.WebSearchRequests != 3 {
+		t.Errorf("Expected WebSearchRequests=3, got %d", result.TokenUsage.WebSearchRequests)
+	}
+	if result.TokenUsage.WebFetchRequests != 1 {
+		t.Errorf("Expected WebFetchRequests=1, got %d", result.TokenUsage.WebFetchRequests)
+	}
+}
+
+// TestTokenParser_ServerToolUseJSON 测试非流式 JSON 响应（包装为 message_delta 解析）中的服务端工具调用次数
+func TestTokenParser_ServerToolUseJSON(t *testing.T) {
+	parser := NewTokenParserWithRequestID("test-req-server-tool-json")
+
+	parser.ParseSSELine("event: message_delta")
+	parser.ParseSSELine(`data: {"id":"msg-2","type":"message","model":"claude-sonnet-4-5-20250929","usage":{"input_tokens":800,"output_tokens":200,"server_tool_use":{"web_search_requests":2}}}`)
+	if parser.ParseSSELine("") == nil {
+		t.Fatal("Expected token usage from JSON response, got nil")
+	}
+
+	finalUsage := parser.GetFinalUsage()
+	if finalUsage == nil {
+		t.Fatal("Expected GetFinalUsage to return non-nil")
+	}
+	if finalUsage.WebSearchRequests != 2 {
+		t.Errorf("Expected WebSearchRequests=2, got %d", finalUsage.WebSearchRequests)
+	}
+	if finalUsage.WebFetchRequests != 0 {
+		t.Errorf("Expected WebFetchRequests=0, got %d", finalUsage.WebFetchRequests)
+	}
+}
+
+// TestTokenParser_ResponseMeta 测试从 SSE 事件中解析停止原因、消息 ID、工具调用与 thinking 内容块
+func TestTokenParser_ResponseMeta(t *testing.T) {
+	parser := NewTokenParserWithRequestID("req-meta")
+	lines := []string{
+		"event: message_start",
+		`data: {"type":"message_start","message":{"id":"msg_01ABC","type":"message","role":"assistant","model":"claude-sonnet-4","content":[],"usage":{"input_tokens":10,"output_tokens":1}}}`,
+		"",
+		"event: content_block_start",
+		`data: {"type":"content_block_start","index":0,"content_block":{"type":"thinking","thinking":""}}`,
+		"",
+		"event: content_block_start",
+		`data: {"type":"content_block_start","index":1,"content_block":{"type":"text","text":""}}`,
+		"",
+		"event: content_block_start",
+		`data: {"type":"content_block_start","index":2,"content_block":{"type":"tool_use","id":"toolu_01","name":"read","input":{}}}`,
+		"",
+		"event: message_delta",
+		`data: {"type":"message_delta","delta":{"stop_reason":"tool_use","stop_sequence":null},"usage":{"output_tokens":42}}`,
+		"",
+	}
+	for _, line := range lines {
+		parser.ParseSSELineV2(line)
+	}
+
+	meta := parser.GetResponseMeta()
+	if meta.MessageID != "msg_01ABC" || meta.StopReason != "tool_use" || meta.ToolUseCount != 1 || !meta.HasThinking {
+		t.Errorf("响应元信息不符: %+v", meta)
+	}
+	if usage := parser.GetFinalUsage(); usage == nil || usage.OutputTokens != 42 {
+		t.Errorf("content_block_start 不应影响 usage 解析: %+v", usage)
+	}
+
+	parser.Reset()
+	if !parser.GetResponseMeta().IsZero() {
+		t.Errorf("Reset 后响应元信息应清空: %+v", parser.GetResponseMeta())
+	}
+}
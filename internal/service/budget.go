@@ -0,0 +1,245 @@
+// 预算服务
+// 花费预算的校验、运行状态维护（超限/手动放行）与 tracking 格式转换
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"cc-forwarder/internal/store"
+	"cc-forwarder/internal/tracking"
+)
+
+// BudgetService 预算管理业务服务
+type BudgetService struct {
+	store store.BudgetStore
+}
+
+// NewBudgetService 创建预算服务实例
+func NewBudgetService(st store.BudgetStore) *BudgetService {
+	return &BudgetService{store: st}
+}
+
+// CreateBudget 创建预算
+func (s *BudgetService) CreateBudget(ctx context.Context, record *store.BudgetRecord) (*store.BudgetRecord, error) {
+	normalizeBudget(record)
+	if err := validateBudget(record); err != nil {
+		return nil, err
+	}
+
+	existing, err := s.store.Get(ctx, record.Name)
+	if err != nil {
+		return nil, fmt.Errorf("检查预算是否存在失败: %w", err)
+	}
+	if existing != nil {
+		return nil, fmt.Errorf("预算 '%s' 已存在", record.Name)
+	}
+
+	created, err := s.store.Create(ctx, record)
+	if err != nil {
+		return nil, err
+	}
+
+	slog.Info(fmt.Sprintf("✅ [BudgetService] 创建预算: %s (%s, %s, $%.2f, 动作: %s)",
+		record.Name, record.Scope, record.Window, record.LimitUSD, record.Action))
+	return created, nil
+}
+
+// ListBudgets 列出所有预算
+func (s *BudgetService) ListBudgets(ctx context.Context) ([]*store.BudgetRecord, error) {
+	return s.store.List(ctx)
+}
+
+// UpdateBudget 更新预算配置
+func (s *BudgetService) UpdateBudget(ctx context.Context, record *store.BudgetRecord) error {
+	normalizeBudget(record)
+	if err := validateBudget(record); err != nil {
+		return err
+	}
+
+	if err := s.store.Update(ctx, record); err != nil {
+		return err
+	}
+
+	slog.Info(fmt.Sprintf("✅ [BudgetService] 更新预算: %s", record.Name))
+	return nil
+}
+
+// DeleteBudget 删除预算
+func (s *BudgetService) DeleteBudget(ctx context.Context, name string) error {
+	if err := s.store.Delete(ctx, name); err != nil {
+		return err
+	}
+
+	slog.Info(fmt.Sprintf("✅ [BudgetService] 删除预算: %s", name))
+	return nil
+}
+
+// MarkTripped 持久化 manual 预算的超限时间（手动放行前保持阻断，重启后仍然有效）
+func (s *BudgetService) MarkTripped(ctx context.Context, name string, trippedAt time.Time) error {
+	return s.store.SetTripped(ctx, name, &trippedAt)
+}
+
+// OverrideBudget 手动放行预算直到 until（清除 manual 超限状态）
+func (s *BudgetService) OverrideBudget(ctx context.Context, name string, until time.Time) error {
+	if err := s.store.SetTripped(ctx, name, nil); err != nil {
+		return err
+	}
+	if err := s.store.SetOverride(ctx, name, &until); err != nil {
+		return err
+	}
+
+	slog.Info(fmt.Sprintf("🔓 [BudgetService] 手动放行预算: %s，截止 %s", name, until.Format(time.RFC3339)))
+	return nil
+}
+
+// ClearOverride 取消手动放行，预算恢复按花费判定
+func (s *BudgetService) ClearOverride(ctx context.Context, name string) error {
+	if err := s.store.SetOverride(ctx, name, nil); err != nil {
+		return err
+	}
+
+	slog.Info(fmt.Sprintf("🔒 [BudgetService] 取消手动放行: %s", name))
+	return nil
+}
+
+// ToTrackingBudgets 转换已启用的预算为 tracking 格式
+func (s *BudgetService) ToTrackingBudgets(records []*store.BudgetRecord) []tracking.Budget {
+	budgets := make([]tracking.Budget, 0, len(records))
+	for _, r := range records {
+		if r == nil || !r.Enabled {
+			continue
+		}
+		budget := tracking.Budget{
+			Name:            r.Name,
+			Scope:           r.Scope,
+			Channel:         r.Channel,
+			EndpointName:    r.EndpointName,
+			Model:           r.ModelName,
+			Window:          r.Window,
+			LimitUSD:        r.LimitUSD,
+			AlertThresholds: r.AlertThresholds,
+			Action:          r.Action,
+			DivertChannel:   r.DivertChannel,
+		}
+		if r.TrippedAt != nil {
+			budget.TrippedAt = *r.TrippedAt
+		}
+		if r.OverrideUntil != nil {
+			budget.OverrideUntil = *r.OverrideUntil
+		}
+		budgets = append(budgets, budget)
+	}
+	return budgets
+}
+
+// normalizeBudget 规范化预算字段（去空白、补全默认值、清理与作用范围无关的字段）
+func normalizeBudget(record *store.BudgetRecord) {
+	if record == nil {
+		return
+	}
+	record.Name = strings.TrimSpace(record.Name)
+	record.Scope = strings.ToLower(strings.TrimSpace(record.Scope))
+	record.Channel = strings.TrimSpace(record.Channel)
+	record.EndpointName = strings.TrimSpace(record.EndpointName)
+	record.ModelName = strings.TrimSpace(record.ModelName)
+	record.Window = strings.ToLower(strings.TrimSpace(record.Window))
+	record.Action = strings.ToLower(strings.TrimSpace(record.Action))
+	record.DivertChannel = strings.TrimSpace(record.DivertChannel)
+
+	if record.Scope == "" {
+		record.Scope = tracking.BudgetScopeGlobal
+	}
+	if record.Window == "" {
+		record.Window = tracking.BudgetWindowDaily
+	}
+	if record.Action == "" {
+		record.Action = tracking.BudgetActionAlert
+	}
+	switch record.Scope {
+	case tracking.BudgetScopeGlobal:
+		record.Channel, record.EndpointName, record.ModelName = "", "", ""
+	case tracking.BudgetScopeChannel:
+		record.EndpointName, record.ModelName = "", ""
+	case tracking.BudgetScopeEndpoint:
+		record.ModelName = ""
+	case tracking.BudgetScopeModel:
+		record.Channel, record.EndpointName = "", ""
+	}
+	if record.Action != tracking.BudgetActionDivert {
+		record.DivertChannel = ""
+	}
+
+	thresholds := make([]float64, 0, len(record.AlertThresholds))
+	seen := make(map[float64]bool, len(record.AlertThresholds))
+	for _, t := range record.AlertThresholds {
+		if !seen[t] {
+			seen[t] = true
+			thresholds = append(thresholds, t)
+		}
+	}
+	sort.Float64s(thresholds)
+	record.AlertThresholds = thresholds
+}
+
+// validateBudget 验证预算记录
+func validateBudget(record *store.BudgetRecord) error {
+	if record == nil {
+		return fmt.Errorf("预算不能为空")
+	}
+	if record.Name == "" {
+		return fmt.Errorf("预算名称不能为空")
+	}
+	if record.LimitUSD <= 0 {
+		return fmt.Errorf("预算上限必须大于 0")
+	}
+
+	switch record.Scope {
+	case tracking.BudgetScopeGlobal:
+	case tracking.BudgetScopeChannel:
+		if record.Channel == "" {
+			return fmt.Errorf("渠道预算需要指定渠道")
+		}
+	case tracking.BudgetScopeEndpoint:
+		if record.Channel == "" || record.EndpointName == "" {
+			return fmt.Errorf("端点预算需要指定渠道和端点")
+		}
+	case tracking.BudgetScopeModel:
+		if record.ModelName == "" {
+			return fmt.Errorf("模型预算需要指定模型")
+		}
+	default:
+		return fmt.Errorf("预算作用范围无效: %s（支持 global / channel / endpoint / model）", record.Scope)
+	}
+
+	switch record.Window {
+	case tracking.BudgetWindowDaily, tracking.BudgetWindowWeekly, tracking.BudgetWindowMonthly:
+	default:
+		return fmt.Errorf("预算窗口无效: %s（支持 daily / weekly / monthly）", record.Window)
+	}
+
+	switch record.Action {
+	case tracking.BudgetActionAlert, tracking.BudgetActionReject, tracking.BudgetActionManual:
+	case tracking.BudgetActionDivert:
+		// 全局/模型预算的花费与渠道无关，必须指定分流目标渠道
+		if record.DivertChannel == "" && (record.Scope == tracking.BudgetScopeGlobal || record.Scope == tracking.BudgetScopeModel) {
+			return fmt.Errorf("全局/模型预算的分流动作需要指定目标渠道")
+		}
+		if record.DivertChannel != "" && record.DivertChannel == record.Channel {
+			return fmt.Errorf("分流目标渠道不能与预算渠道相同")
+		}
+	default:
+		return fmt.Errorf("超限动作无效: %s（支持 alert / reject / divert / manual）", record.Action)
+	}
+
+	for _, t := range record.AlertThresholds {
+		if t <= 0 {
+			return fmt.Errorf("告警阈值必须大于 0: %v", t)
+		}
+	}
+	return nil
+}
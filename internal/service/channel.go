@@ -7,6 +7,7 @@ import (
 	"fmt"
 
 	"cc-forwarder/internal/store"
+	"cc-forwarder/internal/tracking"
 )
 
 type ChannelService struct {
@@ -29,6 +30,9 @@ func (s *ChannelService) CreateChannel(ctx context.Context, record *store.Channe
 	}
 	// 默认参与渠道间故障转移
 	record.FailoverEnabled = true
+	if err := normalizeBillingCurrency(record); err != nil {
+		return nil, err
+	}
 	existing, err := s.store.Get(ctx, record.Name)
 	if err != nil {
 		return nil, err
@@ -79,6 +83,13 @@ func (s *ChannelService) UpdateChannel(ctx context.Context, record *store.Channe
 	if !record.FailoverEnabled {
 		record.FailoverEnabled = existing.FailoverEnabled
 	}
+	// 未指定结算币种时保留原值
+	if record.BillingCurrency == "" {
+		record.BillingCurrency = existing.BillingCurrency
+	}
+	if err := normalizeBillingCurrency(record); err != nil {
+		return err
+	}
 	return s.store.Update(ctx, record)
 }
 
@@ -140,3 +151,12 @@ func (s *ChannelService) BackfillChannelsFromEndpoints(ctx context.Context, endp
 	}
 	return added, nil
 }
+
+// normalizeBillingCurrency 规范化并校验渠道结算币种（空值按 USD 处理）
+func normalizeBillingCurrency(record *store.ChannelRecord) error {
+	record.BillingCurrency = tracking.NormalizeCurrency(record.BillingCurrency)
+	if !tracking.IsCurrencyCode(record.BillingCurrency) {
+		return fmt.Errorf("结算币种无效: %s（需为 3 位 ISO 4217 代码，如 USD、CNY）", record.BillingCurrency)
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+// 渠道余额服务
+// 余额探测配置的校验、探测结果落库与 balance 轮询器格式转换
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+	"time"
+
+	"cc-forwarder/internal/balance"
+	"cc-forwarder/internal/store"
+)
+
+const (
+	defaultBalanceIntervalSeconds = 600
+	minBalanceIntervalSeconds     = 60
+)
+
+// ChannelBalanceService 渠道余额业务服务
+type ChannelBalanceService struct {
+	store store.ChannelBalanceStore
+}
+
+// NewChannelBalanceService 创建渠道余额服务实例
+func NewChannelBalanceService(st store.ChannelBalanceStore) *ChannelBalanceService {
+	return &ChannelBalanceService{store: st}
+}
+
+// SaveProbe 保存渠道余额探测配置（不存在时创建）
+func (s *ChannelBalanceService) SaveProbe(ctx context.Context, record *store.ChannelBalanceProbeRecord) error {
+	normalizeBalanceProbe(record)
+	if err := validateBalanceProbe(record); err != nil {
+		return err
+	}
+
+	if err := s.store.SaveProbe(ctx, record); err != nil {
+		return err
+	}
+
+	slog.Info(fmt.Sprintf("✅ [ChannelBalanceService] 保存余额探测配置: %s (阈值 %.4f %s)", record.Channel, record.LowThreshold, record.Unit))
+	return nil
+}
+
+// GetProbe 获取渠道余额探测配置（不存在时返回 nil）
+func (s *ChannelBalanceService) GetProbe(ctx context.Context, channel string) (*store.ChannelBalanceProbeRecord, error) {
+	return s.store.GetProbe(ctx, channel)
+}
+
+// ListProbes 列出所有渠道余额探测配置
+func (s *ChannelBalanceService) ListProbes(ctx context.Context) ([]*store.ChannelBalanceProbeRecord, error) {
+	return s.store.ListProbes(ctx)
+}
+
+// DeleteProbe 删除渠道余额探测配置
+func (s *ChannelBalanceService) DeleteProbe(ctx context.Context, channel string) error {
+	if err := s.store.DeleteProbe(ctx, channel); err != nil {
+		return err
+	}
+
+	slog.Info(fmt.Sprintf("✅ [ChannelBalanceService] 删除余额探测配置: %s", channel))
+	return nil
+}
+
+// RecordResult 记录余额探测结果
+func (s *ChannelBalanceService) RecordResult(ctx context.Context, r balance.Result) error {
+	return s.store.RecordBalance(ctx, &store.ChannelBalanceRecord{
+		Channel:   r.Channel,
+		Balance:   r.Balance,
+		RawValue:  r.Raw,
+		Unit:      r.Unit,
+		Low:       r.Low,
+		Error:     r.Error,
+		CheckedAt: r.CheckedAt,
+	})
+}
+
+// ListHistory 获取渠道余额时间序列（最近 days 天）
+func (s *ChannelBalanceService) ListHistory(ctx context.Context, channel string, days int, limit int) ([]*store.ChannelBalanceRecord, error) {
+	var since time.Time
+	if days > 0 {
+		since = time.Now().AddDate(0, 0, -days)
+	}
+	return s.store.ListBalances(ctx, channel, since, limit)
+}
+
+// PruneHistory 清理超过保留天数的余额记录
+func (s *ChannelBalanceService) PruneHistory(ctx context.Context, retentionDays int) (int64, error) {
+	if retentionDays <= 0 {
+		return 0, nil
+	}
+	return s.store.DeleteBalancesBefore(ctx, time.Now().AddDate(0, 0, -retentionDays))
+}
+
+// ToProbes 转换已启用的探测配置为轮询器格式
+func (s *ChannelBalanceService) ToProbes(records []*store.ChannelBalanceProbeRecord) []balance.Probe {
+	probes := make([]balance.Probe, 0, len(records))
+	for _, r := range records {
+		if r == nil || !r.Enabled {
+			continue
+		}
+		probes = append(probes, balance.Probe{
+			Channel:        r.Channel,
+			URL:            r.URL,
+			AuthHeader:     r.AuthHeader,
+			AuthToken:      r.AuthToken,
+			BalancePath:    r.BalancePath,
+			Unit:           r.Unit,
+			Scale:          r.Scale,
+			LowThreshold:   r.LowThreshold,
+			ExcludeWhenLow: r.ExcludeWhenLow,
+			Interval:       time.Duration(r.IntervalSeconds) * time.Second,
+		})
+	}
+	return probes
+}
+
+// normalizeBalanceProbe 规范化探测配置字段并填充默认值
+func normalizeBalanceProbe(record *store.ChannelBalanceProbeRecord) {
+	if record == nil {
+		return
+	}
+	record.Channel = strings.TrimSpace(record.Channel)
+	record.URL = strings.TrimSpace(record.URL)
+	record.AuthHeader = strings.TrimSpace(record.AuthHeader)
+	record.AuthToken = strings.TrimSpace(record.AuthToken)
+	record.BalancePath = strings.TrimSpace(record.BalancePath)
+	record.Unit = strings.TrimSpace(record.Unit)
+
+	if record.AuthHeader == "" {
+		record.AuthHeader = "Authorization"
+	}
+	if record.Unit == "" {
+		record.Unit = "USD"
+	}
+	if record.Scale == 0 {
+		record.Scale = 1
+	}
+	if record.IntervalSeconds == 0 {
+		record.IntervalSeconds = defaultBalanceIntervalSeconds
+	}
+}
+
+// validateBalanceProbe 验证探测配置
+func validateBalanceProbe(record *store.ChannelBalanceProbeRecord) error {
+	if record == nil {
+		return fmt.Errorf("余额探测配置不能为空")
+	}
+	if record.Channel == "" {
+		return fmt.Errorf("渠道名称不能为空")
+	}
+
+	u, err := url.Parse(record.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("余额接口地址无效: %s", record.URL)
+	}
+	if record.BalancePath == "" {
+		return fmt.Errorf("余额 JSON 路径不能为空")
+	}
+	if record.Scale < 0 {
+		return fmt.Errorf("换算系数必须大于 0")
+	}
+	if record.LowThreshold < 0 {
+		return fmt.Errorf("低余额阈值不能为负数")
+	}
+	if record.ExcludeWhenLow && record.LowThreshold == 0 {
+		return fmt.Errorf("开启低余额排除时必须设置低余额阈值")
+	}
+	if record.IntervalSeconds < minBalanceIntervalSeconds {
+		return fmt.Errorf("轮询间隔不能小于 %d 秒", minBalanceIntervalSeconds)
+	}
+	return nil
+}
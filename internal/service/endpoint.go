@@ -527,6 +527,7 @@ func (s *EndpointService) recordToConfig(record *store.EndpointRecord) config.En
 		Headers:             record.Headers,
 		Timeout:             time.Duration(record.TimeoutSeconds) * time.Second,
 		SupportsCountTokens: record.SupportsCountTokens,
+		Capabilities:        record.Capabilities,
 	}
 
 	// v5.0: 设置 Enabled（是否作为代理端点）
@@ -559,6 +560,7 @@ func (s *EndpointService) configToRecord(cfg config.EndpointConfig) *store.Endpo
 		FailoverEnabled:     true, // 默认参与故障转移
 		TimeoutSeconds:      int(cfg.Timeout.Seconds()),
 		SupportsCountTokens: cfg.SupportsCountTokens,
+		Capabilities:        cfg.Capabilities,
 		CostMultiplier:      1.0,
 		Enabled:             true,
 	}
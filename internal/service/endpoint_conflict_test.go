@@ -48,6 +48,7 @@ CREATE TABLE IF NOT EXISTS endpoints (
 	cooldown_seconds INTEGER,
 	timeout_seconds INTEGER DEFAULT 300,
 	supports_count_tokens INTEGER DEFAULT 0,
+	capabilities TEXT,
 	cost_multiplier REAL DEFAULT 1.0,
 	input_cost_multiplier REAL DEFAULT 1.0,
 	output_cost_multiplier REAL DEFAULT 1.0,
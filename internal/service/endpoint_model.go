@@ -0,0 +1,180 @@
+// Package service 提供业务逻辑层实现
+// 端点模型服务：连接 EndpointModelStore（持久化）与 EndpointManager 模型目录（运行时）
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+
+	"cc-forwarder/internal/endpoint"
+	"cc-forwarder/internal/store"
+)
+
+// 端点模型矩阵单元格状态
+const (
+	ModelCellDiscovered = "discovered" // 自动发现
+	ModelCellAllowed    = "allowed"    // 手动允许
+	ModelCellDenied     = "denied"     // 手动禁止
+)
+
+// EndpointModelRow 端点模型矩阵中的一行（单个端点）
+type EndpointModelRow struct {
+	Channel      string            `json:"channel"`
+	EndpointName string            `json:"endpoint_name"`
+	DiscoveredAt time.Time         `json:"discovered_at"`
+	LastError    string            `json:"last_error,omitempty"`
+	Models       map[string]string `json:"models"` // model -> 单元格状态
+}
+
+// EndpointModelMatrix 端点 × 模型矩阵
+type EndpointModelMatrix struct {
+	Models    []string           `json:"models"`
+	Endpoints []EndpointModelRow `json:"endpoints"`
+}
+
+// EndpointModelService 端点模型业务服务
+type EndpointModelService struct {
+	store   store.EndpointModelStore
+	manager *endpoint.Manager
+}
+
+// NewEndpointModelService 创建端点模型服务实例
+func NewEndpointModelService(store store.EndpointModelStore, manager *endpoint.Manager) *EndpointModelService {
+	return &EndpointModelService{
+		store:   store,
+		manager: manager,
+	}
+}
+
+// LoadIntoManager 从数据库加载端点模型目录到运行时管理器
+func (s *EndpointModelService) LoadIntoManager(ctx context.Context) error {
+	records, err := s.store.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	type discovered struct {
+		models []endpoint.ModelInfo
+		at     time.Time
+	}
+	byEndpoint := make(map[string]*discovered)
+
+	for _, record := range records {
+		key := endpoint.EndpointKey(record.Channel, record.EndpointName)
+		if record.Source == store.EndpointModelSourceManual {
+			s.manager.SetEndpointModelOverride(key, record.ModelID, record.Allowed)
+			continue
+		}
+
+		d, ok := byEndpoint[key]
+		if !ok {
+			d = &discovered{}
+			byEndpoint[key] = d
+		}
+		d.models = append(d.models, endpoint.ModelInfo{ID: record.ModelID, DisplayName: record.DisplayName})
+		if record.DiscoveredAt.After(d.at) {
+			d.at = record.DiscoveredAt
+		}
+	}
+
+	for key, d := range byEndpoint {
+		s.manager.SetEndpointModels(key, d.models, d.at)
+	}
+
+	slog.Info(fmt.Sprintf("📋 [端点模型] 已加载 %d 个端点的模型目录 (%d 条记录)", len(byEndpoint), len(records)))
+	return nil
+}
+
+// SaveDiscovered 持久化端点模型发现结果（作为 Manager 的模型发现回调）
+func (s *EndpointModelService) SaveDiscovered(channel, endpointName string, models []endpoint.ModelInfo) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	records := make([]*store.EndpointModelRecord, 0, len(models))
+	for _, m := range models {
+		records = append(records, &store.EndpointModelRecord{
+			ModelID:      m.ID,
+			DisplayName:  m.DisplayName,
+			DiscoveredAt: now,
+		})
+	}
+
+	if err := s.store.ReplaceDiscovered(ctx, channel, endpointName, records); err != nil {
+		slog.Warn(fmt.Sprintf("⚠️ [端点模型] 保存端点 %s 模型目录失败: %v", endpointName, err))
+	}
+}
+
+// RefreshAll 立即刷新所有端点的模型目录
+func (s *EndpointModelService) RefreshAll(ctx context.Context) (int, int) {
+	return s.manager.RefreshAllEndpointModels(ctx)
+}
+
+// SetOverride 设置端点模型手动覆盖（允许/禁止）
+func (s *EndpointModelService) SetOverride(ctx context.Context, channel, endpointName, modelID string, allowed bool) error {
+	if endpointName == "" || modelID == "" {
+		return fmt.Errorf("端点名称和模型不能为空")
+	}
+	if err := s.store.UpsertOverride(ctx, channel, endpointName, modelID, allowed); err != nil {
+		return err
+	}
+	s.manager.SetEndpointModelOverride(endpoint.EndpointKey(channel, endpointName), modelID, allowed)
+
+	slog.Info(fmt.Sprintf("✅ [端点模型] 设置模型覆盖: %s / %s → allowed=%v", endpoint.EndpointKey(channel, endpointName), modelID, allowed))
+	return nil
+}
+
+// DeleteOverride 删除端点模型手动覆盖
+func (s *EndpointModelService) DeleteOverride(ctx context.Context, channel, endpointName, modelID string) error {
+	if err := s.store.DeleteOverride(ctx, channel, endpointName, modelID); err != nil {
+		return err
+	}
+	s.manager.RemoveEndpointModelOverride(endpoint.EndpointKey(channel, endpointName), modelID)
+	return nil
+}
+
+// GetModelMatrix 构建端点 × 模型矩阵（基于运行时目录）
+func (s *EndpointModelService) GetModelMatrix() *EndpointModelMatrix {
+	matrix := &EndpointModelMatrix{}
+	modelSet := make(map[string]bool)
+
+	for _, ep := range s.manager.GetAllEndpoints() {
+		snapshot := s.manager.GetEndpointModels(endpoint.EndpointKey(ep.Config.Channel, ep.Config.Name))
+
+		row := EndpointModelRow{
+			Channel:      ep.Config.Channel,
+			EndpointName: ep.Config.Name,
+			DiscoveredAt: snapshot.DiscoveredAt,
+			LastError:    snapshot.LastError,
+			Models:       make(map[string]string),
+		}
+		for _, m := range snapshot.Models {
+			row.Models[m.ID] = ModelCellDiscovered
+			modelSet[m.ID] = true
+		}
+		for model, allowed := range snapshot.Overrides {
+			if allowed {
+				row.Models[model] = ModelCellAllowed
+			} else {
+				row.Models[model] = ModelCellDenied
+			}
+			modelSet[model] = true
+		}
+		matrix.Endpoints = append(matrix.Endpoints, row)
+	}
+
+	for model := range modelSet {
+		matrix.Models = append(matrix.Models, model)
+	}
+	sort.Strings(matrix.Models)
+	sort.SliceStable(matrix.Endpoints, func(i, j int) bool {
+		if matrix.Endpoints[i].Channel != matrix.Endpoints[j].Channel {
+			return matrix.Endpoints[i].Channel < matrix.Endpoints[j].Channel
+		}
+		return matrix.Endpoints[i].EndpointName < matrix.Endpoints[j].EndpointName
+	})
+	return matrix
+}
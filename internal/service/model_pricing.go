@@ -4,9 +4,11 @@ package service
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 	"log/slog"
 	"sync"
+	"time"
 
 	"cc-forwarder/internal/store"
 	"cc-forwarder/internal/tracking"
@@ -22,6 +24,9 @@ type ModelPricingService struct {
 
 	// 默认定价缓存
 	defaultPricing *store.ModelPricingRecord
+
+	// 定价历史版本（可选，未设置时不记录版本）
+	history store.ModelPricingHistoryStore
 }
 
 // NewModelPricingService 创建模型定价服务实例
@@ -32,8 +37,23 @@ func NewModelPricingService(st store.ModelPricingStore) *ModelPricingService {
 	}
 }
 
+// SetHistoryStore 设置定价历史存储（创建/修改定价时记录版本）
+func (s *ModelPricingService) SetHistoryStore(history store.ModelPricingHistoryStore) {
+	s.history = history
+}
+
+// withTx 返回在事务中读写定价与定价版本的服务副本（使用独立缓存，提交后由调用方刷新本服务缓存）
+func (s *ModelPricingService) withTx(tx *sql.Tx) *ModelPricingService {
+	txService := NewModelPricingService(s.store.WithTx(tx))
+	if s.history != nil {
+		txService.history = s.history.WithTx(tx)
+	}
+	return txService
+}
+
 // CreatePricing 创建新的模型定价
-func (s *ModelPricingService) CreatePricing(ctx context.Context, record *store.ModelPricingRecord) (*store.ModelPricingRecord, error) {
+// effectiveFrom 为可选的版本生效时间（默认立即生效）
+func (s *ModelPricingService) CreatePricing(ctx context.Context, record *store.ModelPricingRecord, effectiveFrom ...time.Time) (*store.ModelPricingRecord, error) {
 	// 验证必填字段
 	if err := s.validateRecord(record); err != nil {
 		return nil, err
@@ -64,6 +84,11 @@ func (s *ModelPricingService) CreatePricing(ctx context.Context, record *store.M
 	// 更新缓存
 	s.updateCache(created)
 
+	// 记录定价版本
+	if err := s.recordVersion(ctx, created, versionEffectiveFrom(effectiveFrom), "创建定价"); err != nil {
+		return nil, err
+	}
+
 	slog.Info(fmt.Sprintf("✅ [ModelPricingService] 创建模型定价: %s", record.ModelName))
 	return created, nil
 }
@@ -123,6 +148,7 @@ func (s *ModelPricingService) GetDefaultPricing(ctx context.Context) *store.Mode
 			CacheCreationPrice5m: 3.75,  // input * 1.25
 			CacheCreationPrice1h: 6.0,   // input * 2.0
 			CacheReadPrice:       0.30,  // input * 0.1
+			WebSearchPrice:       10.0,  // $10 / 1K searches
 			IsDefault:            true,
 		}
 	}
@@ -144,7 +170,8 @@ func (s *ModelPricingService) ListPricings(ctx context.Context) ([]*store.ModelP
 }
 
 // UpdatePricing 更新模型定价
-func (s *ModelPricingService) UpdatePricing(ctx context.Context, record *store.ModelPricingRecord) error {
+// effectiveFrom 为可选的版本生效时间（默认立即生效；早于当前时间时用于修正历史价格，需配合成本重算）
+func (s *ModelPricingService) UpdatePricing(ctx context.Context, record *store.ModelPricingRecord, effectiveFrom ...time.Time) error {
 	// 验证必填字段
 	if err := s.validateRecord(record); err != nil {
 		return err
@@ -174,6 +201,11 @@ func (s *ModelPricingService) UpdatePricing(ctx context.Context, record *store.M
 	// 更新缓存
 	s.updateCache(record)
 
+	// 记录定价版本
+	if err := s.recordVersion(ctx, record, versionEffectiveFrom(effectiveFrom), "修改定价"); err != nil {
+		return err
+	}
+
 	slog.Info(fmt.Sprintf("✅ [ModelPricingService] 更新模型定价: %s", record.ModelName))
 	return nil
 }
@@ -235,6 +267,9 @@ func (s *ModelPricingService) ImportFromYAML(ctx context.Context, modelPricing m
 			CacheCreationPrice5m: pricing.CacheCreation,        // YAML 中的 CacheCreation 默认为 5m
 			CacheCreationPrice1h: pricing.Input * 2.0,          // 1h 价格自动计算
 			CacheReadPrice:       pricing.CacheRead,
+			WebSearchPrice:       pricing.WebSearch,
+			WebFetchPrice:        pricing.WebFetch,
+			Tiers:                fromTrackingTiers(pricing.Tiers),
 			IsDefault:            false,
 		}
 		records = append(records, record)
@@ -250,6 +285,9 @@ func (s *ModelPricingService) ImportFromYAML(ctx context.Context, modelPricing m
 		CacheCreationPrice5m: defaultPricing.CacheCreation,
 		CacheCreationPrice1h: defaultPricing.Input * 2.0,
 		CacheReadPrice:       defaultPricing.CacheRead,
+		WebSearchPrice:       defaultPricing.WebSearch,
+		WebFetchPrice:        defaultPricing.WebFetch,
+		Tiers:                fromTrackingTiers(defaultPricing.Tiers),
 		IsDefault:            true,
 	}
 	records = append(records, defaultRecord)
@@ -301,7 +339,146 @@ func (s *ModelPricingService) ToTrackingPricing(record *store.ModelPricingRecord
 		Output:        record.OutputPrice,
 		CacheCreation: record.CacheCreationPrice5m, // 使用 5m 价格作为默认
 		CacheRead:     record.CacheReadPrice,
+		WebSearch:     record.WebSearchPrice,
+		WebFetch:      record.WebFetchPrice,
+		Tiers:         toTrackingTiers(record.Tiers),
+	}
+}
+
+// EnsureHistory 为尚无历史版本的模型补录初始版本（生效时间为 Unix 零点，覆盖全部历史请求）
+// 返回补录的版本数量
+func (s *ModelPricingService) EnsureHistory(ctx context.Context) (int, error) {
+	if s.history == nil {
+		return 0, nil
+	}
+
+	records, err := s.store.List(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("获取定价列表失败: %w", err)
+	}
+	versions, err := s.history.List(ctx, "")
+	if err != nil {
+		return 0, fmt.Errorf("获取定价历史失败: %w", err)
+	}
+	versioned := make(map[string]bool, len(versions))
+	for _, v := range versions {
+		versioned[v.ModelName] = true
+	}
+
+	seeded := 0
+	for _, record := range records {
+		if versioned[record.ModelName] {
+			continue
+		}
+		if err := s.recordVersion(ctx, record, time.Unix(0, 0), "初始版本"); err != nil {
+			return seeded, err
+		}
+		seeded++
 	}
+	if seeded > 0 {
+		slog.Info(fmt.Sprintf("✅ [ModelPricingService] 补录 %d 个模型的初始定价版本", seeded))
+	}
+	return seeded, nil
+}
+
+// ListHistory 列出定价版本（modelName 为空时返回全部）
+func (s *ModelPricingService) ListHistory(ctx context.Context, modelName string) ([]*store.ModelPricingVersionRecord, error) {
+	if s.history == nil {
+		return nil, nil
+	}
+	return s.history.List(ctx, modelName)
+}
+
+// ToTrackingVersions 转换定价版本为 tracking 格式
+func (s *ModelPricingService) ToTrackingVersions(versions []*store.ModelPricingVersionRecord) []tracking.PricingVersion {
+	result := make([]tracking.PricingVersion, 0, len(versions))
+	for _, v := range versions {
+		result = append(result, tracking.PricingVersion{
+			Model:         v.ModelName,
+			EffectiveFrom: v.EffectiveFrom,
+			Pricing: s.ToTrackingPricing(&store.ModelPricingRecord{
+				InputPrice:           v.InputPrice,
+				OutputPrice:          v.OutputPrice,
+				CacheCreationPrice5m: v.CacheCreationPrice5m,
+				CacheCreationPrice1h: v.CacheCreationPrice1h,
+				CacheReadPrice:       v.CacheReadPrice,
+				WebSearchPrice:       v.WebSearchPrice,
+				WebFetchPrice:        v.WebFetchPrice,
+				Tiers:                v.Tiers,
+			}),
+		})
+	}
+	return result
+}
+
+// recordVersion 记录定价版本（未设置历史存储时跳过）
+func (s *ModelPricingService) recordVersion(ctx context.Context, record *store.ModelPricingRecord, effectiveFrom time.Time, note string) error {
+	if s.history == nil || record == nil {
+		return nil
+	}
+	_, err := s.history.Upsert(ctx, &store.ModelPricingVersionRecord{
+		ModelName:            record.ModelName,
+		InputPrice:           record.InputPrice,
+		OutputPrice:          record.OutputPrice,
+		CacheCreationPrice5m: record.CacheCreationPrice5m,
+		CacheCreationPrice1h: record.CacheCreationPrice1h,
+		CacheReadPrice:       record.CacheReadPrice,
+		WebSearchPrice:       record.WebSearchPrice,
+		WebFetchPrice:        record.WebFetchPrice,
+		Tiers:                record.Tiers,
+		EffectiveFrom:        effectiveFrom,
+		Note:                 note,
+	})
+	if err != nil {
+		return fmt.Errorf("记录定价版本失败: %w", err)
+	}
+	return nil
+}
+
+// versionEffectiveFrom 返回可选参数中的版本生效时间（未提供或为零值时立即生效）
+func versionEffectiveFrom(values []time.Time) time.Time {
+	if len(values) == 0 || values[0].IsZero() {
+		return time.Now()
+	}
+	return values[0]
+}
+
+// toTrackingTiers 转换长上下文档位为 tracking 格式
+func toTrackingTiers(tiers []store.PricingTierRecord) []tracking.PricingTier {
+	if len(tiers) == 0 {
+		return nil
+	}
+	result := make([]tracking.PricingTier, 0, len(tiers))
+	for _, tier := range tiers {
+		result = append(result, tracking.PricingTier{
+			Threshold:       tier.Threshold,
+			Input:           tier.InputPrice,
+			Output:          tier.OutputPrice,
+			CacheCreation:   tier.CacheCreationPrice5m,
+			CacheCreation1h: tier.CacheCreationPrice1h,
+			CacheRead:       tier.CacheReadPrice,
+		})
+	}
+	return result
+}
+
+// fromTrackingTiers 将 tracking 格式的长上下文档位转换为存储格式
+func fromTrackingTiers(tiers []tracking.PricingTier) []store.PricingTierRecord {
+	if len(tiers) == 0 {
+		return nil
+	}
+	result := make([]store.PricingTierRecord, 0, len(tiers))
+	for _, tier := range tiers {
+		result = append(result, store.PricingTierRecord{
+			Threshold:            tier.Threshold,
+			InputPrice:           tier.Input,
+			OutputPrice:          tier.Output,
+			CacheCreationPrice5m: tier.CacheCreation,
+			CacheCreationPrice1h: tier.CacheCreation1h,
+			CacheReadPrice:       tier.CacheRead,
+		})
+	}
+	return result
 }
 
 // validateRecord 验证模型定价记录
@@ -315,6 +492,23 @@ func (s *ModelPricingService) validateRecord(record *store.ModelPricingRecord) e
 	if record.OutputPrice < 0 {
 		return fmt.Errorf("输出价格不能为负数")
 	}
+	if record.WebSearchPrice < 0 || record.WebFetchPrice < 0 {
+		return fmt.Errorf("服务端工具价格不能为负数")
+	}
+	seen := make(map[int64]bool, len(record.Tiers))
+	for _, tier := range record.Tiers {
+		if tier.Threshold <= 0 {
+			return fmt.Errorf("长上下文档位阈值必须大于 0")
+		}
+		if seen[tier.Threshold] {
+			return fmt.Errorf("长上下文档位阈值重复: %d", tier.Threshold)
+		}
+		seen[tier.Threshold] = true
+		if tier.InputPrice < 0 || tier.OutputPrice < 0 || tier.CacheCreationPrice5m < 0 ||
+			tier.CacheCreationPrice1h < 0 || tier.CacheReadPrice < 0 {
+			return fmt.Errorf("长上下文档位 %d 的价格不能为负数", tier.Threshold)
+		}
+	}
 	return nil
 }
 
@@ -0,0 +1,195 @@
+// 价目表服务
+// 渠道/端点级别价目表的校验与转换，命中时优先于模型定价计费
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"cc-forwarder/internal/store"
+	"cc-forwarder/internal/tracking"
+)
+
+// PriceBookService 价目表管理业务服务
+type PriceBookService struct {
+	store store.PriceBookStore
+}
+
+// NewPriceBookService 创建价目表服务实例
+func NewPriceBookService(st store.PriceBookStore) *PriceBookService {
+	return &PriceBookService{store: st}
+}
+
+// CreatePriceBook 创建价目表
+func (s *PriceBookService) CreatePriceBook(ctx context.Context, record *store.PriceBookRecord) (*store.PriceBookRecord, error) {
+	normalizePriceBook(record)
+	if err := s.validateRecord(ctx, record); err != nil {
+		return nil, err
+	}
+
+	existing, err := s.store.Get(ctx, record.Name)
+	if err != nil {
+		return nil, fmt.Errorf("检查价目表是否存在失败: %w", err)
+	}
+	if existing != nil {
+		return nil, fmt.Errorf("价目表 '%s' 已存在", record.Name)
+	}
+
+	created, err := s.store.Create(ctx, record)
+	if err != nil {
+		return nil, err
+	}
+
+	slog.Info(fmt.Sprintf("✅ [PriceBookService] 创建价目表: %s (渠道: %s, 端点: %s)", record.Name, record.Channel, record.EndpointName))
+	return created, nil
+}
+
+// ListPriceBooks 列出所有价目表
+func (s *PriceBookService) ListPriceBooks(ctx context.Context) ([]*store.PriceBookRecord, error) {
+	return s.store.List(ctx)
+}
+
+// UpdatePriceBook 更新价目表
+func (s *PriceBookService) UpdatePriceBook(ctx context.Context, record *store.PriceBookRecord) error {
+	normalizePriceBook(record)
+	if err := s.validateRecord(ctx, record); err != nil {
+		return err
+	}
+
+	if err := s.store.Update(ctx, record); err != nil {
+		return err
+	}
+
+	slog.Info(fmt.Sprintf("✅ [PriceBookService] 更新价目表: %s", record.Name))
+	return nil
+}
+
+// DeletePriceBook 删除价目表
+func (s *PriceBookService) DeletePriceBook(ctx context.Context, name string) error {
+	if err := s.store.Delete(ctx, name); err != nil {
+		return err
+	}
+
+	slog.Info(fmt.Sprintf("✅ [PriceBookService] 删除价目表: %s", name))
+	return nil
+}
+
+// ToTrackingPriceBooks 转换已启用的价目表为 tracking 格式
+func (s *PriceBookService) ToTrackingPriceBooks(records []*store.PriceBookRecord) []tracking.PriceBook {
+	books := make([]tracking.PriceBook, 0, len(records))
+	for _, r := range records {
+		if r == nil || !r.Enabled {
+			continue
+		}
+		book := tracking.PriceBook{
+			Name:           r.Name,
+			Channel:        r.Channel,
+			EndpointName:   r.EndpointName,
+			Mode:           r.Mode,
+			GroupRatio:     r.GroupRatio,
+			RatioUnitPrice: r.RatioUnitPrice,
+			PerRequestFee:  r.PerRequestFee,
+			Entries:        make([]tracking.PriceBookEntry, 0, len(r.Entries)),
+		}
+		for _, e := range r.Entries {
+			book.Entries = append(book.Entries, tracking.PriceBookEntry{
+				Model:              e.Model,
+				Input:              e.InputPrice,
+				Output:             e.OutputPrice,
+				CacheCreation:      e.CacheCreationPrice5m,
+				CacheCreation1h:    e.CacheCreationPrice1h,
+				CacheRead:          e.CacheReadPrice,
+				ModelRatio:         e.ModelRatio,
+				CompletionRatio:    e.CompletionRatio,
+				CacheReadRatio:     e.CacheReadRatio,
+				CacheCreationRatio: e.CacheCreationRatio,
+				PerRequestFee:      e.PerRequestFee,
+			})
+		}
+		books = append(books, book)
+	}
+	return books
+}
+
+// normalizePriceBook 规范化价目表字段（去空白、补全默认计价模式）
+func normalizePriceBook(record *store.PriceBookRecord) {
+	if record == nil {
+		return
+	}
+	record.Name = strings.TrimSpace(record.Name)
+	record.Channel = strings.TrimSpace(record.Channel)
+	record.EndpointName = strings.TrimSpace(record.EndpointName)
+	record.Mode = strings.ToLower(strings.TrimSpace(record.Mode))
+	if record.Mode == "" {
+		record.Mode = tracking.PriceBookModeAbsolute
+	}
+	if record.GroupRatio <= 0 {
+		record.GroupRatio = 1
+	}
+	if record.RatioUnitPrice <= 0 {
+		record.RatioUnitPrice = tracking.DefaultRatioUnitPrice
+	}
+	for i := range record.Entries {
+		record.Entries[i].Model = strings.TrimSpace(record.Entries[i].Model)
+	}
+}
+
+// validateRecord 验证价目表记录（同一渠道/端点作用域只允许一个启用的价目表）
+func (s *PriceBookService) validateRecord(ctx context.Context, record *store.PriceBookRecord) error {
+	if record == nil {
+		return fmt.Errorf("价目表不能为空")
+	}
+	if record.Name == "" {
+		return fmt.Errorf("价目表名称不能为空")
+	}
+	if record.Channel == "" {
+		return fmt.Errorf("价目表所属渠道不能为空")
+	}
+	if record.Mode != tracking.PriceBookModeAbsolute && record.Mode != tracking.PriceBookModeRatio {
+		return fmt.Errorf("计价模式无效: %s（支持 absolute / ratio）", record.Mode)
+	}
+	if record.PerRequestFee < 0 {
+		return fmt.Errorf("按次费用不能为负数")
+	}
+	if len(record.Entries) == 0 {
+		return fmt.Errorf("价目表至少需要一个模型条目")
+	}
+
+	seen := make(map[string]bool, len(record.Entries))
+	for _, e := range record.Entries {
+		if e.Model == "" {
+			return fmt.Errorf("价目表条目的模型名称不能为空")
+		}
+		if seen[e.Model] {
+			return fmt.Errorf("价目表条目模型重复: %s", e.Model)
+		}
+		seen[e.Model] = true
+		if e.InputPrice < 0 || e.OutputPrice < 0 || e.CacheCreationPrice5m < 0 || e.CacheCreationPrice1h < 0 ||
+			e.CacheReadPrice < 0 || e.ModelRatio < 0 || e.CompletionRatio < 0 || e.CacheReadRatio < 0 ||
+			e.CacheCreationRatio < 0 || e.PerRequestFee < 0 {
+			return fmt.Errorf("模型 %s 的价格不能为负数", e.Model)
+		}
+		if record.Mode == tracking.PriceBookModeRatio && e.ModelRatio == 0 && e.PerRequestFee == 0 {
+			return fmt.Errorf("倍率模式下模型 %s 需要设置模型倍率或按次费用", e.Model)
+		}
+	}
+
+	if !record.Enabled {
+		return nil
+	}
+	records, err := s.store.List(ctx)
+	if err != nil {
+		return fmt.Errorf("获取价目表列表失败: %w", err)
+	}
+	for _, other := range records {
+		if other.Name == record.Name || !other.Enabled {
+			continue
+		}
+		if other.Channel == record.Channel && other.EndpointName == record.EndpointName {
+			return fmt.Errorf("渠道 %s 端点 %q 已存在启用的价目表: %s", record.Channel, record.EndpointName, other.Name)
+		}
+	}
+	return nil
+}
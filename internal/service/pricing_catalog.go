@@ -0,0 +1,502 @@
+// 模型定价目录导入
+// 从本地 JSON/YAML 定价目录（含 LiteLLM model_prices_and_context_window.json 格式）导入模型定价，
+// 支持预览差异、merge（仅新增）/ overwrite（新增并覆盖已有价格）两种模式，并报告使用中但目录缺失的模型
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"cc-forwarder/internal/store"
+)
+
+// 定价目录导入模式
+const (
+	CatalogImportMerge     = "merge"     // 仅新增目录中有、本地没有的模型，已有模型保持不变
+	CatalogImportOverwrite = "overwrite" // 新增缺失模型，并用目录价格覆盖已有模型
+)
+
+// 定价目录格式
+const (
+	CatalogFormatLiteLLM = "litellm" // LiteLLM model_prices_and_context_window.json（每 token 价格）
+	CatalogFormatNative  = "native"  // 与 config.yaml model_pricing 相同（每百万 token 价格）
+)
+
+// 导入变更类型
+const (
+	CatalogChangeAdd    = "add"    // 新增模型
+	CatalogChangeUpdate = "update" // 覆盖已有模型价格
+	CatalogChangeKeep   = "keep"   // merge 模式下价格不同但保留本地价格
+)
+
+// litellmLongContextThreshold LiteLLM *_above_200k_tokens 价格对应的长上下文阈值
+const litellmLongContextThreshold = 200000
+
+// PricingCatalogOptions 定价目录导入选项
+type PricingCatalogOptions struct {
+	Mode      string   // merge / overwrite（默认 merge）
+	Providers []string // 仅导入指定 litellm_provider（为空不过滤，仅对 LiteLLM 格式生效）
+	Models    []string // 仅导入指定模型（为空不过滤）
+
+	// UsedModels 使用中的模型（来自请求记录），用于报告目录缺失的模型
+	UsedModels []string
+	// EffectiveFrom 导入价格的版本生效时间（零值表示立即生效）
+	EffectiveFrom time.Time
+}
+
+// PricingCatalogChange 单个模型的导入变更
+type PricingCatalogChange struct {
+	ModelName string                    `json:"model_name"`
+	Action    string                    `json:"action"` // add / update / keep
+	Fields    []string                  `json:"fields,omitempty"`
+	Before    *store.ModelPricingRecord `json:"before,omitempty"`
+	After     *store.ModelPricingRecord `json:"after"`
+}
+
+// PricingCatalogReport 定价目录导入报告（预览与实际导入共用）
+type PricingCatalogReport struct {
+	Format      string `json:"format"`
+	Mode        string `json:"mode"`
+	CatalogSize int    `json:"catalog_size"` // 过滤后的目录模型数
+	Ignored     int    `json:"ignored"`      // 非对话模型、无价格或被过滤的条目数
+
+	Added     int `json:"added"`
+	Updated   int `json:"updated"`
+	Kept      int `json:"kept"`
+	Unchanged int `json:"unchanged"`
+
+	Changes []PricingCatalogChange `json:"changes"`
+	// MissingInCatalog 使用中但目录缺失的模型
+	MissingInCatalog []PricingCatalogMissing `json:"missing_in_catalog"`
+	Applied          bool                    `json:"applied"`
+}
+
+// PricingCatalogMissing 使用中但目录缺失的模型
+type PricingCatalogMissing struct {
+	ModelName string `json:"model_name"`
+	// HasPricing 本地是否已有定价（false 表示按默认定价计费，需要手动补录）
+	HasPricing bool `json:"has_pricing"`
+}
+
+// catalogEntry 解析后的目录条目
+type catalogEntry struct {
+	record        *store.ModelPricingRecord
+	hasTiers      bool // 目录提供了长上下文档位
+	hasToolPrices bool // 目录提供了服务端工具价格
+}
+
+// PreviewCatalogImport 预览定价目录导入（不写入数据库）
+func (s *ModelPricingService) PreviewCatalogImport(ctx context.Context, data []byte, opts PricingCatalogOptions) (*PricingCatalogReport, error) {
+	report, _, err := s.planCatalogImport(ctx, data, opts)
+	return report, err
+}
+
+// ImportCatalog 导入定价目录（新增/覆盖均记录定价版本）
+// 定价与定价版本在同一事务中写入，任一模型失败时整体回滚；提交后刷新定价缓存
+func (s *ModelPricingService) ImportCatalog(ctx context.Context, db *sql.DB, data []byte, opts PricingCatalogOptions) (*PricingCatalogReport, error) {
+	if db == nil {
+		return nil, fmt.Errorf("数据库未初始化")
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("开启事务失败: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	txService := s.withTx(tx)
+	report, existing, err := txService.planCatalogImport(ctx, data, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	effectiveFrom := opts.EffectiveFrom
+	if effectiveFrom.IsZero() {
+		effectiveFrom = time.Now()
+	}
+	for _, change := range report.Changes {
+		switch change.Action {
+		case CatalogChangeAdd:
+			if _, err := txService.CreatePricing(ctx, change.After, effectiveFrom); err != nil {
+				return nil, fmt.Errorf("导入模型 %s 失败: %w", change.ModelName, err)
+			}
+		case CatalogChangeUpdate:
+			record := change.After
+			record.ID = existing[change.ModelName].ID
+			if err := txService.UpdatePricing(ctx, record, effectiveFrom); err != nil {
+				return nil, fmt.Errorf("导入模型 %s 失败: %w", change.ModelName, err)
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("提交事务失败: %w", err)
+	}
+	report.Applied = true
+
+	if err := s.LoadCache(ctx); err != nil {
+		slog.Warn("⚠️ [ModelPricingService] 导入定价目录后刷新缓存失败", "error", err)
+	}
+
+	slog.Info(fmt.Sprintf("✅ [ModelPricingService] 导入定价目录(%s/%s): 新增 %d, 覆盖 %d, 保留 %d, 未变化 %d, 目录缺失 %d",
+		report.Format, report.Mode, report.Added, report.Updated, report.Kept, report.Unchanged, len(report.MissingInCatalog)))
+	return report, nil
+}
+
+// planCatalogImport 解析目录并与现有定价对比，生成导入计划
+func (s *ModelPricingService) planCatalogImport(ctx context.Context, data []byte, opts PricingCatalogOptions) (*PricingCatalogReport, map[string]*store.ModelPricingRecord, error) {
+	mode := strings.ToLower(strings.TrimSpace(opts.Mode))
+	if mode == "" {
+		mode = CatalogImportMerge
+	}
+	if mode != CatalogImportMerge && mode != CatalogImportOverwrite {
+		return nil, nil, fmt.Errorf("导入模式无效: %s（支持 merge / overwrite）", opts.Mode)
+	}
+
+	format, entries, ignored, err := parsePricingCatalog(data, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	records, err := s.store.List(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("获取定价列表失败: %w", err)
+	}
+	existing := make(map[string]*store.ModelPricingRecord, len(records))
+	for _, r := range records {
+		existing[r.ModelName] = r
+	}
+
+	report := &PricingCatalogReport{
+		Format:           format,
+		Mode:             mode,
+		CatalogSize:      len(entries),
+		Ignored:          ignored,
+		Changes:          []PricingCatalogChange{},
+		MissingInCatalog: []PricingCatalogMissing{},
+	}
+
+	names := make([]string, 0, len(entries))
+	for name := range entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		entry := entries[name]
+		current, ok := existing[name]
+		if !ok {
+			// 目录未提供服务端工具价格时沿用默认定价
+			if !entry.hasToolPrices {
+				def := s.GetDefaultPricing(ctx)
+				entry.record.WebSearchPrice = def.WebSearchPrice
+				entry.record.WebFetchPrice = def.WebFetchPrice
+			}
+			report.Added++
+			report.Changes = append(report.Changes, PricingCatalogChange{ModelName: name, Action: CatalogChangeAdd, After: entry.record})
+			continue
+		}
+
+		merged := mergeCatalogEntry(current, entry)
+		fields := diffPricingFields(current, merged)
+		if len(fields) == 0 {
+			report.Unchanged++
+			continue
+		}
+		action := CatalogChangeUpdate
+		if mode == CatalogImportMerge {
+			action = CatalogChangeKeep
+			report.Kept++
+		} else {
+			report.Updated++
+		}
+		report.Changes = append(report.Changes, PricingCatalogChange{
+			ModelName: name, Action: action, Fields: fields, Before: current, After: merged,
+		})
+	}
+
+	seen := make(map[string]bool, len(opts.UsedModels))
+	for _, model := range opts.UsedModels {
+		model = strings.TrimSpace(model)
+		if model == "" || seen[model] {
+			continue
+		}
+		seen[model] = true
+		if _, inCatalog := entries[model]; inCatalog {
+			continue
+		}
+		_, priced := existing[model]
+		report.MissingInCatalog = append(report.MissingInCatalog, PricingCatalogMissing{ModelName: model, HasPricing: priced})
+	}
+	sort.Slice(report.MissingInCatalog, func(i, j int) bool {
+		return report.MissingInCatalog[i].ModelName < report.MissingInCatalog[j].ModelName
+	})
+
+	return report, existing, nil
+}
+
+// mergeCatalogEntry 以现有定价为基础应用目录价格（保留显示名、描述、默认标记，目录未提供的档位/工具价格保持原值）
+func mergeCatalogEntry(current *store.ModelPricingRecord, entry catalogEntry) *store.ModelPricingRecord {
+	merged := *current
+	merged.InputPrice = entry.record.InputPrice
+	merged.OutputPrice = entry.record.OutputPrice
+	merged.CacheCreationPrice5m = entry.record.CacheCreationPrice5m
+	merged.CacheCreationPrice1h = entry.record.CacheCreationPrice1h
+	merged.CacheReadPrice = entry.record.CacheReadPrice
+	if entry.hasTiers {
+		merged.Tiers = entry.record.Tiers
+	}
+	if entry.hasToolPrices {
+		merged.WebSearchPrice = entry.record.WebSearchPrice
+		merged.WebFetchPrice = entry.record.WebFetchPrice
+	}
+	if merged.DisplayName == "" {
+		merged.DisplayName = entry.record.DisplayName
+	}
+	return &merged
+}
+
+// diffPricingFields 返回价格发生变化的字段
+func diffPricingFields(before, after *store.ModelPricingRecord) []string {
+	var fields []string
+	check := func(name string, a, b float64) {
+		if math.Abs(a-b) > 1e-9 {
+			fields = append(fields, name)
+		}
+	}
+	check("input_price", before.InputPrice, after.InputPrice)
+	check("output_price", before.OutputPrice, after.OutputPrice)
+	check("cache_creation_price_5m", before.CacheCreationPrice5m, after.CacheCreationPrice5m)
+	check("cache_creation_price_1h", before.CacheCreationPrice1h, after.CacheCreationPrice1h)
+	check("cache_read_price", before.CacheReadPrice, after.CacheReadPrice)
+	check("web_search_price", before.WebSearchPrice, after.WebSearchPrice)
+	check("web_fetch_price", before.WebFetchPrice, after.WebFetchPrice)
+
+	beforeTiers, _ := json.Marshal(before.Tiers)
+	afterTiers, _ := json.Marshal(after.Tiers)
+	if string(beforeTiers) != string(afterTiers) {
+		fields = append(fields, "tiers")
+	}
+	return fields
+}
+
+// parsePricingCatalog 解析 JSON/YAML 定价目录（按条目自动识别 LiteLLM 或本地格式）
+func parsePricingCatalog(data []byte, opts PricingCatalogOptions) (string, map[string]catalogEntry, int, error) {
+	var root map[string]interface{}
+	trimmed := strings.TrimSpace(string(data))
+	if trimmed == "" {
+		return "", nil, 0, fmt.Errorf("定价目录为空")
+	}
+	if strings.HasPrefix(trimmed, "{") {
+		if err := json.Unmarshal(data, &root); err != nil {
+			return "", nil, 0, fmt.Errorf("解析 JSON 定价目录失败: %w", err)
+		}
+	} else if err := yaml.Unmarshal(data, &root); err != nil {
+		return "", nil, 0, fmt.Errorf("解析 YAML 定价目录失败: %w", err)
+	}
+
+	// 兼容直接导入 config.yaml 片段（model_pricing: {...}）
+	if nested, ok := root["model_pricing"].(map[string]interface{}); ok {
+		root = nested
+	}
+
+	providers := toLowerSet(opts.Providers)
+	models := toLowerSet(opts.Models)
+
+	format := CatalogFormatNative
+	entries := make(map[string]catalogEntry, len(root))
+	ignored := 0
+	for name, raw := range root {
+		name = strings.TrimSpace(name)
+		fields, ok := raw.(map[string]interface{})
+		if !ok || name == "" || name == "sample_spec" || name == "_default" {
+			ignored++
+			continue
+		}
+		if len(models) > 0 && !models[strings.ToLower(name)] {
+			ignored++
+			continue
+		}
+
+		var entry catalogEntry
+		if isLiteLLMEntry(fields) {
+			format = CatalogFormatLiteLLM
+			if len(providers) > 0 && !providers[strings.ToLower(catalogString(fields, "litellm_provider"))] {
+				ignored++
+				continue
+			}
+			if mode := catalogString(fields, "mode"); mode != "" && mode != "chat" && mode != "completion" {
+				ignored++
+				continue
+			}
+			entry = parseLiteLLMEntry(name, fields)
+		} else {
+			entry = parseNativeEntry(name, fields)
+		}
+
+		if entry.record.InputPrice <= 0 && entry.record.OutputPrice <= 0 {
+			ignored++
+			continue
+		}
+		if entry.record.InputPrice < 0 || entry.record.OutputPrice < 0 || entry.record.CacheCreationPrice5m < 0 ||
+			entry.record.CacheCreationPrice1h < 0 || entry.record.CacheReadPrice < 0 {
+			return "", nil, 0, fmt.Errorf("模型 %s 的价格不能为负数", name)
+		}
+		entries[name] = entry
+	}
+
+	if len(entries) == 0 {
+		return format, nil, ignored, fmt.Errorf("定价目录中没有可导入的模型（已忽略 %d 个条目）", ignored)
+	}
+	return format, entries, ignored, nil
+}
+
+// isLiteLLMEntry 是否为 LiteLLM 格式条目（每 token 价格）
+func isLiteLLMEntry(fields map[string]interface{}) bool {
+	_, hasInput := fields["input_cost_per_token"]
+	_, hasOutput := fields["output_cost_per_token"]
+	return hasInput || hasOutput
+}
+
+// parseLiteLLMEntry 解析 LiteLLM 条目：每 token 价格换算为每百万 token
+// 缺失的缓存价格按 Anthropic 规则推算（5m 写入 1.25x、1h 写入 2x、读取 0.1x 输入价格）
+func parseLiteLLMEntry(name string, fields map[string]interface{}) catalogEntry {
+	perMillion := func(key string) (float64, bool) {
+		v, ok := catalogFloat(fields, key)
+		return roundPrice(v * 1e6), ok
+	}
+
+	input, _ := perMillion("input_cost_per_token")
+	output, _ := perMillion("output_cost_per_token")
+	record := &store.ModelPricingRecord{
+		ModelName:   name,
+		InputPrice:  input,
+		OutputPrice: output,
+	}
+	var ok bool
+	if record.CacheCreationPrice5m, ok = perMillion("cache_creation_input_token_cost"); !ok {
+		record.CacheCreationPrice5m = roundPrice(input * 1.25)
+	}
+	if record.CacheCreationPrice1h, ok = perMillion("cache_creation_input_token_cost_above_1hr"); !ok {
+		record.CacheCreationPrice1h = roundPrice(input * 2.0)
+	}
+	if record.CacheReadPrice, ok = perMillion("cache_read_input_token_cost"); !ok {
+		record.CacheReadPrice = roundPrice(input * 0.1)
+	}
+
+	entry := catalogEntry{record: record}
+	tier := store.PricingTierRecord{Threshold: litellmLongContextThreshold}
+	hasTier := false
+	if v, ok := perMillion("input_cost_per_token_above_200k_tokens"); ok {
+		tier.InputPrice, hasTier = v, true
+	}
+	if v, ok := perMillion("output_cost_per_token_above_200k_tokens"); ok {
+		tier.OutputPrice, hasTier = v, true
+	}
+	if v, ok := perMillion("cache_creation_input_token_cost_above_200k_tokens"); ok {
+		tier.CacheCreationPrice5m, hasTier = v, true
+	}
+	if v, ok := perMillion("cache_read_input_token_cost_above_200k_tokens"); ok {
+		tier.CacheReadPrice, hasTier = v, true
+	}
+	if hasTier {
+		record.Tiers = []store.PricingTierRecord{tier}
+		entry.hasTiers = true
+	}
+	return entry
+}
+
+// parseNativeEntry 解析本地格式条目（与 config.yaml model_pricing 相同，每百万 token 价格）
+func parseNativeEntry(name string, fields map[string]interface{}) catalogEntry {
+	input, _ := catalogFloat(fields, "input")
+	output, _ := catalogFloat(fields, "output")
+	record := &store.ModelPricingRecord{
+		ModelName:   name,
+		DisplayName: catalogString(fields, "display_name"),
+		Description: catalogString(fields, "description"),
+		InputPrice:  input,
+		OutputPrice: output,
+	}
+	var ok bool
+	if record.CacheCreationPrice5m, ok = catalogFloat(fields, "cache_creation"); !ok {
+		record.CacheCreationPrice5m = roundPrice(input * 1.25)
+	}
+	if record.CacheCreationPrice1h, ok = catalogFloat(fields, "cache_creation_1h"); !ok {
+		record.CacheCreationPrice1h = roundPrice(input * 2.0)
+	}
+	if record.CacheReadPrice, ok = catalogFloat(fields, "cache_read"); !ok {
+		record.CacheReadPrice = roundPrice(input * 0.1)
+	}
+
+	entry := catalogEntry{record: record}
+	webSearch, hasSearch := catalogFloat(fields, "web_search")
+	webFetch, hasFetch := catalogFloat(fields, "web_fetch")
+	if hasSearch || hasFetch {
+		record.WebSearchPrice = webSearch
+		record.WebFetchPrice = webFetch
+		entry.hasToolPrices = true
+	}
+
+	if rawTiers, ok := fields["tiers"].([]interface{}); ok {
+		for _, raw := range rawTiers {
+			t, ok := raw.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			threshold, _ := catalogFloat(t, "threshold")
+			tier := store.PricingTierRecord{Threshold: int64(threshold)}
+			tier.InputPrice, _ = catalogFloat(t, "input")
+			tier.OutputPrice, _ = catalogFloat(t, "output")
+			tier.CacheCreationPrice5m, _ = catalogFloat(t, "cache_creation")
+			tier.CacheCreationPrice1h, _ = catalogFloat(t, "cache_creation_1h")
+			tier.CacheReadPrice, _ = catalogFloat(t, "cache_read")
+			record.Tiers = append(record.Tiers, tier)
+		}
+		entry.hasTiers = true
+	}
+	return entry
+}
+
+// catalogFloat 读取数值字段（兼容 JSON float64 与 YAML int/float）
+func catalogFloat(fields map[string]interface{}, key string) (float64, bool) {
+	switch v := fields[key].(type) {
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	}
+	return 0, false
+}
+
+// catalogString 读取字符串字段
+func catalogString(fields map[string]interface{}, key string) string {
+	v, _ := fields[key].(string)
+	return strings.TrimSpace(v)
+}
+
+// roundPrice 消除每 token 价格换算带来的浮点误差（保留 6 位小数）
+func roundPrice(v float64) float64 {
+	return math.Round(v*1e6) / 1e6
+}
+
+func toLowerSet(values []string) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, v := range values {
+		if v = strings.ToLower(strings.TrimSpace(v)); v != "" {
+			set[v] = true
+		}
+	}
+	return set
+}
@@ -0,0 +1,251 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"cc-forwarder/internal/store"
+
+	_ "modernc.org/sqlite"
+)
+
+func createPricingCatalogTestService(t *testing.T) (*ModelPricingService, *sql.DB, func()) {
+	t.Helper()
+
+	tmpDir, err := os.MkdirTemp("", "pricing_catalog_test_*")
+	if err != nil {
+		t.Fatalf("创建临时目录失败: %v", err)
+	}
+	db, err := sql.Open("sqlite", filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		_ = os.RemoveAll(tmpDir)
+		t.Fatalf("打开数据库失败: %v", err)
+	}
+
+	schema := `
+CREATE TABLE IF NOT EXISTS model_pricing (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	model_name TEXT UNIQUE NOT NULL,
+	input_price REAL NOT NULL DEFAULT 3.0,
+	output_price REAL NOT NULL DEFAULT 15.0,
+	cache_creation_price_5m REAL DEFAULT 3.75,
+	cache_creation_price_1h REAL DEFAULT 6.0,
+	cache_read_price REAL DEFAULT 0.30,
+	web_search_price REAL DEFAULT 10.0,
+	web_fetch_price REAL DEFAULT 0,
+	pricing_tiers TEXT,
+	display_name TEXT,
+	description TEXT,
+	is_default INTEGER DEFAULT 0,
+	created_at DATETIME DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now', 'localtime') || '+08:00'),
+	updated_at DATETIME DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now', 'localtime') || '+08:00')
+);
+`
+	if _, err := db.Exec(schema); err != nil {
+		_ = db.Close()
+		_ = os.RemoveAll(tmpDir)
+		t.Fatalf("创建 model_pricing 表失败: %v", err)
+	}
+
+	cleanup := func() {
+		_ = db.Close()
+		_ = os.RemoveAll(tmpDir)
+	}
+	return NewModelPricingService(store.NewSQLiteModelPricingStore(db)), db, cleanup
+}
+
+const testLiteLLMCatalog = `{
+	"sample_spec": {"input_cost_per_token": 0, "output_cost_per_token": 0, "mode": "chat"},
+	"claude-sonnet-4-5": {
+		"litellm_provider": "anthropic",
+		"mode": "chat",
+		"input_cost_per_token": 3e-06,
+		"output_cost_per_token": 1.5e-05,
+		"cache_creation_input_token_cost": 3.75e-06,
+		"cache_read_input_token_cost": 3e-07,
+		"input_cost_per_token_above_200k_tokens": 6e-06,
+		"output_cost_per_token_above_200k_tokens": 2.25e-05
+	},
+	"claude-haiku-4-5": {
+		"litellm_provider": "anthropic",
+		"mode": "chat",
+		"input_cost_per_token": 1e-06,
+		"output_cost_per_token": 5e-06
+	},
+	"gpt-4o": {
+		"litellm_provider": "openai",
+		"mode": "chat",
+		"input_cost_per_token": 2.5e-06,
+		"output_cost_per_token": 1e-05
+	},
+	"voyage-3": {
+		"litellm_provider": "anthropic",
+		"mode": "embedding",
+		"input_cost_per_token": 6e-08,
+		"output_cost_per_token": 0
+	}
+}`
+
+// TestPricingCatalog_LiteLLMParsing 测试 LiteLLM 格式解析：每 token 价格换算、长上下文档位与过滤
+func TestPricingCatalog_LiteLLMParsing(t *testing.T) {
+	format, entries, ignored, err := parsePricingCatalog([]byte(testLiteLLMCatalog), PricingCatalogOptions{Providers: []string{"Anthropic"}})
+	if err != nil {
+		t.Fatalf("解析目录失败: %v", err)
+	}
+	if format != CatalogFormatLiteLLM {
+		t.Errorf("format = %s, want %s", format, CatalogFormatLiteLLM)
+	}
+	// sample_spec、gpt-4o（provider 过滤）、voyage-3（非对话模型）被忽略
+	if len(entries) != 2 || ignored != 3 {
+		t.Fatalf("entries = %d, ignored = %d, want 2/3", len(entries), ignored)
+	}
+
+	sonnet := entries["claude-sonnet-4-5"]
+	r := sonnet.record
+	if r.InputPrice != 3 || r.OutputPrice != 15 || r.CacheCreationPrice5m != 3.75 || r.CacheReadPrice != 0.3 || r.CacheCreationPrice1h != 6 {
+		t.Errorf("sonnet 价格换算不符: %+v", r)
+	}
+	if !sonnet.hasTiers || len(r.Tiers) != 1 || r.Tiers[0].Threshold != 200000 || r.Tiers[0].InputPrice != 6 || r.Tiers[0].OutputPrice != 22.5 {
+		t.Errorf("sonnet 长上下文档位不符: %+v", r.Tiers)
+	}
+
+	haiku := entries["claude-haiku-4-5"].record
+	if haiku.CacheCreationPrice5m != 1.25 || haiku.CacheReadPrice != 0.1 || len(haiku.Tiers) != 0 {
+		t.Errorf("haiku 缓存价格推算不符: %+v", haiku)
+	}
+}
+
+// TestPricingCatalog_NativeYAML 测试本地 YAML 格式（config.yaml model_pricing 片段）
+func TestPricingCatalog_NativeYAML(t *testing.T) {
+	data := `
+model_pricing:
+  claude-opus-4-1:
+    input: 15
+    output: 75
+    cache_read: 1.5
+    web_search: 10
+    display_name: Claude Opus 4.1
+`
+	format, entries, _, err := parsePricingCatalog([]byte(data), PricingCatalogOptions{})
+	if err != nil {
+		t.Fatalf("解析目录失败: %v", err)
+	}
+	if format != CatalogFormatNative || len(entries) != 1 {
+		t.Fatalf("format = %s, entries = %d", format, len(entries))
+	}
+	opus := entries["claude-opus-4-1"]
+	if opus.record.InputPrice != 15 || opus.record.CacheCreationPrice5m != 18.75 || opus.record.CacheReadPrice != 1.5 ||
+		!opus.hasToolPrices || opus.record.WebSearchPrice != 10 || opus.record.DisplayName != "Claude Opus 4.1" {
+		t.Errorf("本地格式解析不符: %+v", opus.record)
+	}
+
+	if _, _, _, err := parsePricingCatalog([]byte("{}"), PricingCatalogOptions{}); err == nil {
+		t.Error("空目录应返回错误")
+	}
+}
+
+// TestPricingCatalog_MergeAndOverwrite 测试 merge / overwrite 导入与目录缺失模型报告
+func TestPricingCatalog_MergeAndOverwrite(t *testing.T) {
+	svc, db, cleanup := createPricingCatalogTestService(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	if _, err := svc.CreatePricing(ctx, &store.ModelPricingRecord{
+		ModelName:   "claude-sonnet-4-5",
+		DisplayName: "Sonnet 4.5",
+		InputPrice:  2,
+		OutputPrice: 10,
+	}); err != nil {
+		t.Fatalf("创建定价失败: %v", err)
+	}
+	if _, err := svc.CreatePricing(ctx, &store.ModelPricingRecord{
+		ModelName:   "custom-model",
+		InputPrice:  1,
+		OutputPrice: 2,
+	}); err != nil {
+		t.Fatalf("创建定价失败: %v", err)
+	}
+
+	opts := PricingCatalogOptions{
+		Providers:  []string{"anthropic"},
+		UsedModels: []string{"claude-haiku-4-5", "custom-model", "unknown-model", "custom-model"},
+	}
+
+	preview, err := svc.PreviewCatalogImport(ctx, []byte(testLiteLLMCatalog), opts)
+	if err != nil {
+		t.Fatalf("预览失败: %v", err)
+	}
+	if preview.Mode != CatalogImportMerge || preview.Added != 1 || preview.Kept != 1 || preview.Updated != 0 || preview.Applied {
+		t.Errorf("merge 预览不符: %+v", preview)
+	}
+	if len(preview.MissingInCatalog) != 2 ||
+		preview.MissingInCatalog[0] != (PricingCatalogMissing{ModelName: "custom-model", HasPricing: true}) ||
+		preview.MissingInCatalog[1] != (PricingCatalogMissing{ModelName: "unknown-model", HasPricing: false}) {
+		t.Errorf("目录缺失模型不符: %+v", preview.MissingInCatalog)
+	}
+	if count, _ := svc.GetPricingCount(ctx); count != 2 {
+		t.Errorf("预览不应写入数据库, count = %d", count)
+	}
+
+	merged, err := svc.ImportCatalog(ctx, db, []byte(testLiteLLMCatalog), opts)
+	if err != nil || !merged.Applied {
+		t.Fatalf("merge 导入失败: %v", err)
+	}
+	haiku, _ := svc.GetPricing(ctx, "claude-haiku-4-5")
+	if haiku == nil || haiku.InputPrice != 1 || haiku.WebSearchPrice != 10 {
+		t.Errorf("新增模型不符（应沿用默认工具价格）: %+v", haiku)
+	}
+	sonnet, _ := svc.GetPricing(ctx, "claude-sonnet-4-5")
+	if sonnet.InputPrice != 2 {
+		t.Errorf("merge 模式不应覆盖已有价格: %+v", sonnet)
+	}
+
+	opts.Mode = CatalogImportOverwrite
+	overwritten, err := svc.ImportCatalog(ctx, db, []byte(testLiteLLMCatalog), opts)
+	if err != nil {
+		t.Fatalf("overwrite 导入失败: %v", err)
+	}
+	if overwritten.Added != 0 || overwritten.Updated != 1 || overwritten.Unchanged != 1 {
+		t.Errorf("overwrite 报告不符: %+v", overwritten)
+	}
+	sonnet, _ = svc.GetPricing(ctx, "claude-sonnet-4-5")
+	if sonnet.InputPrice != 3 || sonnet.OutputPrice != 15 || len(sonnet.Tiers) != 1 || sonnet.DisplayName != "Sonnet 4.5" {
+		t.Errorf("overwrite 后定价不符: %+v", sonnet)
+	}
+
+	if _, err := svc.PreviewCatalogImport(ctx, []byte(testLiteLLMCatalog), PricingCatalogOptions{Mode: "replace"}); err == nil {
+		t.Error("无效导入模式应返回错误")
+	}
+}
+
+// TestPricingCatalog_ImportRollback 测试导入失败时整体回滚（已处理的模型不会部分写入）
+func TestPricingCatalog_ImportRollback(t *testing.T) {
+	svc, db, cleanup := createPricingCatalogTestService(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	// 按模型名排序导入：a-model 合法，b-model 的档位阈值无效导致导入失败
+	data := `
+a-model:
+  input: 1
+  output: 5
+b-model:
+  input: 2
+  output: 10
+  tiers:
+    - threshold: 0
+      input: 4
+`
+	if _, err := svc.ImportCatalog(ctx, db, []byte(data), PricingCatalogOptions{}); err == nil {
+		t.Fatal("无效档位应导致导入失败")
+	}
+	if count, _ := svc.GetPricingCount(ctx); count != 0 {
+		t.Errorf("导入失败应整体回滚, count = %d", count)
+	}
+	if record, _ := svc.GetPricing(ctx, "a-model"); record != nil {
+		t.Errorf("回滚后不应缓存或查询到 a-model: %+v", record)
+	}
+}
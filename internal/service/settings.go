@@ -379,6 +379,8 @@ func (s *SettingsService) getDefaultsForCategory(category string) []*store.Setti
 			{Category: CategoryHealth, Key: "check_interval", Value: "30s", ValueType: ValueTypeDuration, Label: "检查间隔", Description: "健康检查的时间间隔", DisplayOrder: 1},
 			{Category: CategoryHealth, Key: "timeout", Value: "5s", ValueType: ValueTypeDuration, Label: "检查超时", Description: "健康检查的超时时间", DisplayOrder: 2},
 			{Category: CategoryHealth, Key: "health_path", Value: "/v1/models", ValueType: ValueTypeString, Label: "检查路径", Description: "健康检查请求的 API 路径", DisplayOrder: 3},
+			{Category: CategoryHealth, Key: "model_discovery_enabled", Value: "false", ValueType: ValueTypeBool, Label: "模型发现", Description: "定期请求各端点的 /v1/models，按请求模型筛选端点并汇总本地 /v1/models 响应", DisplayOrder: 4, RequiresRestart: true},
+			{Category: CategoryHealth, Key: "model_discovery_interval", Value: "6h", ValueType: ValueTypeDuration, Label: "模型发现间隔", Description: "端点模型目录的刷新间隔", DisplayOrder: 5},
 		}
 
 	case CategoryFailover:
@@ -407,7 +409,10 @@ func (s *SettingsService) getDefaultsForCategory(category string) []*store.Setti
 	case CategoryAuth:
 		return []*store.SettingRecord{
 			{Category: CategoryAuth, Key: "enabled", Value: "false", ValueType: ValueTypeBool, Label: "启用鉴权", Description: "是否启用 API 访问鉴权", DisplayOrder: 1},
-			{Category: CategoryAuth, Key: "token", Value: "", ValueType: ValueTypePassword, Label: "鉴权 Token", Description: "Bearer Token 值", DisplayOrder: 2},
+			{Category: CategoryAuth, Key: "token", Value: "", ValueType: ValueTypePassword, Label: "鉴权 Token", Description: "访问 Token 值（各鉴权方式共用）", DisplayOrder: 2},
+			{Category: CategoryAuth, Key: "schemes", Value: "bearer,x-api-key", ValueType: ValueTypeString, Label: "鉴权方式", Description: "接受的鉴权方式，逗号分隔：bearer（Authorization 头）、x-api-key（Claude Code 的 ANTHROPIC_API_KEY）、query（?token= 参数）", DisplayOrder: 3},
+			{Category: CategoryAuth, Key: "allow_cidrs", Value: "", ValueType: ValueTypeString, Label: "IP 允许列表", Description: "仅允许这些地址访问，逗号分隔的 CIDR 或 IP，留空表示不限制", DisplayOrder: 4},
+			{Category: CategoryAuth, Key: "deny_cidrs", Value: "", ValueType: ValueTypeString, Label: "IP 拒绝列表", Description: "拒绝这些地址访问（优先于允许列表），逗号分隔的 CIDR 或 IP", DisplayOrder: 5},
 		}
 
 	case CategoryTokenCounting:
@@ -0,0 +1,216 @@
+// Webhook 通知服务
+// Webhook 目标的校验、投递记录落库与 webhook 投递器格式转换
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+	"time"
+
+	"cc-forwarder/internal/events"
+	"cc-forwarder/internal/store"
+	"cc-forwarder/internal/webhook"
+)
+
+// maxWebhookRetries 单次投递最多重试次数
+const maxWebhookRetries = 10
+
+// WebhookService Webhook 通知业务服务
+type WebhookService struct {
+	store store.WebhookStore
+}
+
+// NewWebhookService 创建 Webhook 服务实例
+func NewWebhookService(st store.WebhookStore) *WebhookService {
+	return &WebhookService{store: st}
+}
+
+// CreateWebhook 创建 Webhook 目标
+func (s *WebhookService) CreateWebhook(ctx context.Context, record *store.WebhookRecord) (*store.WebhookRecord, error) {
+	normalizeWebhook(record)
+	if err := validateWebhook(record); err != nil {
+		return nil, err
+	}
+
+	existing, err := s.store.Get(ctx, record.Name)
+	if err != nil {
+		return nil, fmt.Errorf("检查 Webhook 是否存在失败: %w", err)
+	}
+	if existing != nil {
+		return nil, fmt.Errorf("Webhook '%s' 已存在", record.Name)
+	}
+
+	created, err := s.store.Create(ctx, record)
+	if err != nil {
+		return nil, err
+	}
+
+	slog.Info(fmt.Sprintf("✅ [WebhookService] 创建 Webhook: %s (%s)", record.Name, record.Kind))
+	return created, nil
+}
+
+// GetWebhook 获取 Webhook 目标（不存在时返回 nil）
+func (s *WebhookService) GetWebhook(ctx context.Context, name string) (*store.WebhookRecord, error) {
+	return s.store.Get(ctx, name)
+}
+
+// ListWebhooks 列出所有 Webhook 目标
+func (s *WebhookService) ListWebhooks(ctx context.Context) ([]*store.WebhookRecord, error) {
+	return s.store.List(ctx)
+}
+
+// UpdateWebhook 更新 Webhook 目标
+func (s *WebhookService) UpdateWebhook(ctx context.Context, record *store.WebhookRecord) error {
+	normalizeWebhook(record)
+	if err := validateWebhook(record); err != nil {
+		return err
+	}
+
+	if err := s.store.Update(ctx, record); err != nil {
+		return err
+	}
+
+	slog.Info(fmt.Sprintf("✅ [WebhookService] 更新 Webhook: %s", record.Name))
+	return nil
+}
+
+// DeleteWebhook 删除 Webhook 目标
+func (s *WebhookService) DeleteWebhook(ctx context.Context, name string) error {
+	if err := s.store.Delete(ctx, name); err != nil {
+		return err
+	}
+
+	slog.Info(fmt.Sprintf("✅ [WebhookService] 删除 Webhook: %s", name))
+	return nil
+}
+
+// RecordDelivery 记录投递结果
+func (s *WebhookService) RecordDelivery(ctx context.Context, d webhook.Delivery) error {
+	record := &store.WebhookDeliveryRecord{
+		WebhookName: d.Webhook,
+		EventType:   d.EventType,
+		DedupKey:    d.DedupKey,
+		Status:      d.Status,
+		Attempts:    d.Attempts,
+		HTTPStatus:  d.HTTPStatus,
+		Error:       d.Error,
+		Payload:     d.Payload,
+		CreatedAt:   d.CreatedAt,
+	}
+	if !d.DeliveredAt.IsZero() {
+		deliveredAt := d.DeliveredAt
+		record.DeliveredAt = &deliveredAt
+	}
+	return s.store.RecordDelivery(ctx, record)
+}
+
+// ListDeliveries 获取最近的投递记录
+func (s *WebhookService) ListDeliveries(ctx context.Context, webhookName string, limit int) ([]*store.WebhookDeliveryRecord, error) {
+	return s.store.ListDeliveries(ctx, webhookName, limit)
+}
+
+// PruneDeliveries 清理超过保留天数的投递记录
+func (s *WebhookService) PruneDeliveries(ctx context.Context, retentionDays int) (int64, error) {
+	if retentionDays <= 0 {
+		return 0, nil
+	}
+	return s.store.DeleteDeliveriesBefore(ctx, time.Now().AddDate(0, 0, -retentionDays))
+}
+
+// ToTargets 转换已启用的 Webhook 为投递目标
+func (s *WebhookService) ToTargets(records []*store.WebhookRecord) []webhook.Target {
+	targets := make([]webhook.Target, 0, len(records))
+	for _, r := range records {
+		if r == nil || !r.Enabled {
+			continue
+		}
+		targets = append(targets, ToWebhookTarget(r))
+	}
+	return targets
+}
+
+// ToWebhookTarget 转换 Webhook 记录为投递目标（不检查启用状态，用于测试投递）
+func ToWebhookTarget(r *store.WebhookRecord) webhook.Target {
+	return webhook.Target{
+		Name:        r.Name,
+		Kind:        r.Kind,
+		URL:         r.URL,
+		Secret:      r.Secret,
+		EventTypes:  r.EventTypes,
+		DedupWindow: time.Duration(r.DedupSeconds) * time.Second,
+		RateLimit:   r.RateLimitPerMinute,
+		MaxRetries:  r.MaxRetries,
+	}
+}
+
+// normalizeWebhook 规范化 Webhook 字段
+func normalizeWebhook(record *store.WebhookRecord) {
+	if record == nil {
+		return
+	}
+	record.Name = strings.TrimSpace(record.Name)
+	record.Kind = strings.ToLower(strings.TrimSpace(record.Kind))
+	record.URL = strings.TrimSpace(record.URL)
+	record.Secret = strings.TrimSpace(record.Secret)
+	if record.Kind == "" {
+		record.Kind = webhook.KindGeneric
+	}
+
+	eventTypes := make([]string, 0, len(record.EventTypes))
+	seen := make(map[string]bool, len(record.EventTypes))
+	for _, et := range record.EventTypes {
+		et = strings.TrimSpace(et)
+		if et != "" && !seen[et] {
+			seen[et] = true
+			eventTypes = append(eventTypes, et)
+		}
+	}
+	if len(eventTypes) == 0 {
+		eventTypes = nil
+	}
+	record.EventTypes = eventTypes
+}
+
+// validateWebhook 验证 Webhook 记录
+func validateWebhook(record *store.WebhookRecord) error {
+	if record == nil {
+		return fmt.Errorf("Webhook 不能为空")
+	}
+	if record.Name == "" {
+		return fmt.Errorf("Webhook 名称不能为空")
+	}
+
+	switch record.Kind {
+	case webhook.KindGeneric, webhook.KindSlack, webhook.KindFeishu, webhook.KindDingTalk, webhook.KindWeCom:
+	default:
+		return fmt.Errorf("Webhook 类型无效: %s（支持 generic / slack / feishu / dingtalk / wecom）", record.Kind)
+	}
+
+	u, err := url.Parse(record.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("Webhook 地址无效: %s", record.URL)
+	}
+
+	for _, et := range record.EventTypes {
+		if et == "*" {
+			continue
+		}
+		if _, ok := events.EventTypeMapping[events.EventType(et)]; !ok {
+			return fmt.Errorf("未知的事件类型: %s", et)
+		}
+	}
+
+	if record.DedupSeconds < 0 {
+		return fmt.Errorf("去重窗口不能为负数")
+	}
+	if record.RateLimitPerMinute < 0 {
+		return fmt.Errorf("限流次数不能为负数")
+	}
+	if record.MaxRetries < 0 || record.MaxRetries > maxWebhookRetries {
+		return fmt.Errorf("重试次数必须在 0-%d 之间", maxWebhookRetries)
+	}
+	return nil
+}
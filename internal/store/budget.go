@@ -0,0 +1,293 @@
+// 花费预算存储
+// 全局/渠道/端点/模型级别的日/周/月预算，超限时告警或阻断请求
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// BudgetRecord 表示数据库中的预算记录
+type BudgetRecord struct {
+	ID int64 `json:"id"`
+
+	Name         string `json:"name"`          // 预算名称（唯一）
+	Scope        string `json:"scope"`         // global / channel / endpoint / model
+	Channel      string `json:"channel"`       // channel / endpoint 作用范围
+	EndpointName string `json:"endpoint_name"` // endpoint 作用范围
+	ModelName    string `json:"model_name"`    // model 作用范围
+
+	Window          string    `json:"window"`           // daily / weekly / monthly
+	LimitUSD        float64   `json:"limit_usd"`        // 预算上限（USD）
+	AlertThresholds []float64 `json:"alert_thresholds"` // 告警阈值百分比
+
+	Action        string `json:"action"`         // alert / reject / divert / manual
+	DivertChannel string `json:"divert_channel"` // divert 动作的目标渠道
+
+	// 运行状态
+	TrippedAt     *time.Time `json:"tripped_at,omitempty"`     // manual 动作：超出预算时间
+	OverrideUntil *time.Time `json:"override_until,omitempty"` // 手动放行截止时间
+
+	Enabled     bool   `json:"enabled"`
+	Description string `json:"description,omitempty"`
+
+	// 审计字段
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// BudgetStore 定义预算存储接口
+type BudgetStore interface {
+	// CRUD 操作
+	Create(ctx context.Context, record *BudgetRecord) (*BudgetRecord, error)
+	Get(ctx context.Context, name string) (*BudgetRecord, error)
+	List(ctx context.Context) ([]*BudgetRecord, error)
+	Update(ctx context.Context, record *BudgetRecord) error
+	Delete(ctx context.Context, name string) error
+
+	// 运行状态
+	SetTripped(ctx context.Context, name string, trippedAt *time.Time) error
+	SetOverride(ctx context.Context, name string, until *time.Time) error
+
+	// 事务支持
+	WithTx(tx *sql.Tx) BudgetStore
+}
+
+// SQLiteBudgetStore 实现 BudgetStore 接口
+type SQLiteBudgetStore struct {
+	db *sql.DB
+	tx *sql.Tx // 事务上下文（可选）
+	mu sync.RWMutex
+}
+
+// NewSQLiteBudgetStore 创建新的 SQLite 预算存储
+func NewSQLiteBudgetStore(db *sql.DB) *SQLiteBudgetStore {
+	return &SQLiteBudgetStore{db: db}
+}
+
+// getQuerier 返回用于执行查询的对象（事务或数据库连接）
+func (s *SQLiteBudgetStore) getQuerier() interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+} {
+	if s.tx != nil {
+		return s.tx
+	}
+	return s.db
+}
+
+const budgetColumns = `id, name, scope, COALESCE(channel, ''), COALESCE(endpoint_name, ''), COALESCE(model_name, ''),
+	period, limit_usd, alert_thresholds, action, COALESCE(divert_channel, ''),
+	tripped_at, override_until, enabled, COALESCE(description, ''), created_at, updated_at`
+
+// Create 创建预算
+func (s *SQLiteBudgetStore) Create(ctx context.Context, record *BudgetRecord) (*BudgetRecord, error) {
+	if record == nil {
+		return nil, fmt.Errorf("record 不能为空")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	query := `
+		INSERT INTO budgets (
+			name, scope, channel, endpoint_name, model_name,
+			period, limit_usd, alert_thresholds, action, divert_channel,
+			enabled, description
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+
+	result, err := s.getQuerier().ExecContext(ctx, query,
+		record.Name, record.Scope, record.Channel, record.EndpointName, record.ModelName,
+		record.Window, record.LimitUSD, marshalAlertThresholds(record.AlertThresholds), record.Action, record.DivertChannel,
+		boolToInt(record.Enabled), nullIfEmpty(record.Description),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("创建预算失败: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("获取插入 ID 失败: %w", err)
+	}
+
+	record.ID = id
+	record.CreatedAt = time.Now()
+	record.UpdatedAt = time.Now()
+
+	return record, nil
+}
+
+// Get 根据名称获取预算（不存在时返回 nil）
+func (s *SQLiteBudgetStore) Get(ctx context.Context, name string) (*BudgetRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	records, err := s.scanBudgets(ctx, `SELECT `+budgetColumns+` FROM budgets WHERE name = ?`, name)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+	return records[0], nil
+}
+
+// List 获取所有预算（按名称排序）
+func (s *SQLiteBudgetStore) List(ctx context.Context) ([]*BudgetRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.scanBudgets(ctx, `SELECT `+budgetColumns+` FROM budgets ORDER BY name ASC`)
+}
+
+// Update 更新预算配置（按名称定位，不修改运行状态）
+func (s *SQLiteBudgetStore) Update(ctx context.Context, record *BudgetRecord) error {
+	if record == nil {
+		return fmt.Errorf("record 不能为空")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	query := `
+		UPDATE budgets SET
+			scope = ?, channel = ?, endpoint_name = ?, model_name = ?,
+			period = ?, limit_usd = ?, alert_thresholds = ?, action = ?, divert_channel = ?,
+			enabled = ?, description = ?
+		WHERE name = ?
+	`
+
+	result, err := s.getQuerier().ExecContext(ctx, query,
+		record.Scope, record.Channel, record.EndpointName, record.ModelName,
+		record.Window, record.LimitUSD, marshalAlertThresholds(record.AlertThresholds), record.Action, record.DivertChannel,
+		boolToInt(record.Enabled), nullIfEmpty(record.Description),
+		record.Name,
+	)
+	if err != nil {
+		return fmt.Errorf("更新预算失败: %w", err)
+	}
+	return checkBudgetAffected(result, record.Name)
+}
+
+// Delete 删除预算
+func (s *SQLiteBudgetStore) Delete(ctx context.Context, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result, err := s.getQuerier().ExecContext(ctx, `DELETE FROM budgets WHERE name = ?`, name)
+	if err != nil {
+		return fmt.Errorf("删除预算失败: %w", err)
+	}
+	return checkBudgetAffected(result, name)
+}
+
+// SetTripped 记录（或清除，trippedAt 为 nil）manual 预算的超限时间
+func (s *SQLiteBudgetStore) SetTripped(ctx context.Context, name string, trippedAt *time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result, err := s.getQuerier().ExecContext(ctx, `UPDATE budgets SET tripped_at = ? WHERE name = ?`,
+		nullableSQLiteTime(trippedAt), name)
+	if err != nil {
+		return fmt.Errorf("更新预算超限状态失败: %w", err)
+	}
+	return checkBudgetAffected(result, name)
+}
+
+// SetOverride 设置（或清除，until 为 nil）手动放行截止时间
+func (s *SQLiteBudgetStore) SetOverride(ctx context.Context, name string, until *time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result, err := s.getQuerier().ExecContext(ctx, `UPDATE budgets SET override_until = ? WHERE name = ?`,
+		nullableSQLiteTime(until), name)
+	if err != nil {
+		return fmt.Errorf("更新预算放行状态失败: %w", err)
+	}
+	return checkBudgetAffected(result, name)
+}
+
+// WithTx 返回使用事务的存储实例
+func (s *SQLiteBudgetStore) WithTx(tx *sql.Tx) BudgetStore {
+	return &SQLiteBudgetStore{db: s.db, tx: tx}
+}
+
+// scanBudgets 执行查询并扫描预算记录
+func (s *SQLiteBudgetStore) scanBudgets(ctx context.Context, query string, args ...interface{}) ([]*BudgetRecord, error) {
+	rows, err := s.getQuerier().QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("查询预算失败: %w", err)
+	}
+	defer rows.Close()
+
+	var records []*BudgetRecord
+	for rows.Next() {
+		var record BudgetRecord
+		var thresholdsJSON, trippedAt, overrideUntil sql.NullString
+		var enabled int
+		var createdAt, updatedAt string
+
+		if err := rows.Scan(
+			&record.ID, &record.Name, &record.Scope, &record.Channel, &record.EndpointName, &record.ModelName,
+			&record.Window, &record.LimitUSD, &thresholdsJSON, &record.Action, &record.DivertChannel,
+			&trippedAt, &overrideUntil, &enabled, &record.Description, &createdAt, &updatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("扫描预算记录失败: %w", err)
+		}
+
+		record.AlertThresholds = unmarshalAlertThresholds(thresholdsJSON.String)
+		record.TrippedAt = parseNullableSQLiteTime(trippedAt)
+		record.OverrideUntil = parseNullableSQLiteTime(overrideUntil)
+		record.Enabled = enabled == 1
+		record.CreatedAt = parseSQLiteDateTime(createdAt)
+		record.UpdatedAt = parseSQLiteDateTime(updatedAt)
+		records = append(records, &record)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历预算记录失败: %w", err)
+	}
+	return records, nil
+}
+
+// checkBudgetAffected 检查更新/删除是否命中预算
+func checkBudgetAffected(result sql.Result, name string) error {
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("获取影响行数失败: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("预算不存在: %s", name)
+	}
+	return nil
+}
+
+// marshalAlertThresholds 序列化告警阈值，未配置时存储 NULL
+func marshalAlertThresholds(thresholds []float64) interface{} {
+	if len(thresholds) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(thresholds)
+	if err != nil {
+		return nil
+	}
+	return string(data)
+}
+
+// unmarshalAlertThresholds 解析告警阈值，解析失败时视为未配置
+func unmarshalAlertThresholds(data string) []float64 {
+	if data == "" || data == "null" {
+		return nil
+	}
+	var thresholds []float64
+	if err := json.Unmarshal([]byte(data), &thresholds); err != nil {
+		return nil
+	}
+	return thresholds
+}
@@ -0,0 +1,118 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func createBudgetTestDB(t *testing.T) (*SQLiteBudgetStore, func()) {
+	t.Helper()
+
+	db, cleanup := createTestDB(t)
+	schema := `
+		CREATE TABLE IF NOT EXISTS budgets (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL UNIQUE,
+			scope TEXT NOT NULL DEFAULT 'global',
+			channel TEXT NOT NULL DEFAULT '',
+			endpoint_name TEXT NOT NULL DEFAULT '',
+			model_name TEXT NOT NULL DEFAULT '',
+			period TEXT NOT NULL DEFAULT 'daily',
+			limit_usd REAL NOT NULL,
+			alert_thresholds TEXT,
+			action TEXT NOT NULL DEFAULT 'alert',
+			divert_channel TEXT NOT NULL DEFAULT '',
+			tripped_at DATETIME,
+			override_until DATETIME,
+			enabled INTEGER DEFAULT 1,
+			description TEXT,
+			created_at DATETIME DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now', 'localtime') || '+08:00'),
+			updated_at DATETIME DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now', 'localtime') || '+08:00')
+		);
+	`
+	if _, err := db.Exec(schema); err != nil {
+		cleanup()
+		t.Fatalf("创建 budgets 表失败: %v", err)
+	}
+	return NewSQLiteBudgetStore(db), cleanup
+}
+
+// TestBudgetStore_CRUD 测试预算增删改查、告警阈值 JSON 往返与运行状态读写
+func TestBudgetStore_CRUD(t *testing.T) {
+	s, cleanup := createBudgetTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	created, err := s.Create(ctx, &BudgetRecord{
+		Name:            "relay-daily",
+		Scope:           "channel",
+		Channel:         "relay",
+		Window:          "daily",
+		LimitUSD:        20,
+		AlertThresholds: []float64{50, 80, 100},
+		Action:          "divert",
+		DivertChannel:   "cheap",
+		Enabled:         true,
+	})
+	if err != nil {
+		t.Fatalf("创建预算失败: %v", err)
+	}
+	if created.ID == 0 {
+		t.Fatal("创建后应返回 ID")
+	}
+	if _, err := s.Create(ctx, &BudgetRecord{Name: "relay-daily", Scope: "global", Window: "daily", LimitUSD: 1, Action: "alert"}); err == nil {
+		t.Error("重复名称应返回错误")
+	}
+
+	got, err := s.Get(ctx, "relay-daily")
+	if err != nil || got == nil {
+		t.Fatalf("获取预算失败: %v", err)
+	}
+	if got.Channel != "relay" || got.Window != "daily" || got.DivertChannel != "cheap" || !got.Enabled ||
+		len(got.AlertThresholds) != 3 || got.AlertThresholds[1] != 80 || got.TrippedAt != nil {
+		t.Errorf("预算内容不符: %+v", got)
+	}
+
+	// 运行状态：超限时间与手动放行
+	loc := time.FixedZone("CST", 8*3600)
+	tripped := time.Date(2025, 3, 1, 10, 0, 0, 0, loc)
+	until := time.Date(2025, 3, 2, 0, 0, 0, 0, loc)
+	if err := s.SetTripped(ctx, "relay-daily", &tripped); err != nil {
+		t.Fatalf("记录超限状态失败: %v", err)
+	}
+	if err := s.SetOverride(ctx, "relay-daily", &until); err != nil {
+		t.Fatalf("记录放行状态失败: %v", err)
+	}
+	got, _ = s.Get(ctx, "relay-daily")
+	if got.TrippedAt == nil || !got.TrippedAt.Equal(tripped) || got.OverrideUntil == nil || !got.OverrideUntil.Equal(until) {
+		t.Errorf("运行状态不符: tripped=%v override=%v", got.TrippedAt, got.OverrideUntil)
+	}
+
+	// 更新配置不影响运行状态
+	got.LimitUSD = 30
+	got.AlertThresholds = nil
+	if err := s.Update(ctx, got); err != nil {
+		t.Fatalf("更新预算失败: %v", err)
+	}
+	if updated, _ := s.Get(ctx, "relay-daily"); updated.LimitUSD != 30 || updated.AlertThresholds != nil || updated.TrippedAt == nil {
+		t.Errorf("更新结果不符: %+v", updated)
+	}
+
+	if err := s.SetTripped(ctx, "relay-daily", nil); err != nil {
+		t.Fatalf("清除超限状态失败: %v", err)
+	}
+	if cleared, _ := s.Get(ctx, "relay-daily"); cleared.TrippedAt != nil {
+		t.Errorf("清除后超限时间应为空: %v", cleared.TrippedAt)
+	}
+
+	if err := s.Delete(ctx, "relay-daily"); err != nil {
+		t.Fatalf("删除预算失败: %v", err)
+	}
+	if err := s.Delete(ctx, "relay-daily"); err == nil {
+		t.Error("删除不存在的预算应返回错误")
+	}
+	if missing, err := s.Get(ctx, "relay-daily"); err != nil || missing != nil {
+		t.Errorf("删除后应返回 nil: %+v, %v", missing, err)
+	}
+}
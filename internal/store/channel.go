@@ -19,6 +19,8 @@ type ChannelRecord struct {
 	Priority int    `json:"priority"`
 	// FailoverEnabled 表示该渠道是否参与“渠道间故障转移”（暂停/恢复按钮持久化）。
 	FailoverEnabled bool `json:"failover_enabled"`
+	// BillingCurrency 渠道结算币种（ISO 4217，空值按 USD 处理）。
+	BillingCurrency string `json:"billing_currency"`
 
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
@@ -84,10 +86,10 @@ func (s *SQLiteChannelStore) Create(ctx context.Context, record *ChannelRecord)
 
 	s.mu.Lock()
 	query := `
-		INSERT INTO channels (name, website, priority, failover_enabled)
-		VALUES (?, ?, ?, ?)
+		INSERT INTO channels (name, website, priority, failover_enabled, billing_currency)
+		VALUES (?, ?, ?, ?, ?)
 	`
-	res, err := s.execContext(ctx, query, record.Name, nullIfEmpty(record.Website), record.Priority, boolToInt(record.FailoverEnabled))
+	res, err := s.execContext(ctx, query, record.Name, nullIfEmpty(record.Website), record.Priority, boolToInt(record.FailoverEnabled), billingCurrencyOrDefault(record.BillingCurrency))
 	if err != nil {
 		s.mu.Unlock()
 		return nil, fmt.Errorf("创建渠道失败: %w", err)
@@ -108,7 +110,7 @@ func (s *SQLiteChannelStore) Get(ctx context.Context, name string) (*ChannelReco
 	defer s.mu.RUnlock()
 
 	query := `
-		SELECT id, name, COALESCE(website, ''), COALESCE(priority, 1), COALESCE(failover_enabled, 1), created_at, updated_at
+		SELECT id, name, COALESCE(website, ''), COALESCE(priority, 1), COALESCE(failover_enabled, 1), COALESCE(billing_currency, 'USD'), created_at, updated_at
 		FROM channels
 		WHERE name = ?
 	`
@@ -123,6 +125,7 @@ func (s *SQLiteChannelStore) Get(ctx context.Context, name string) (*ChannelReco
 		&record.Website,
 		&record.Priority,
 		&failoverEnabled,
+		&record.BillingCurrency,
 		&createdAt,
 		&updatedAt,
 	)
@@ -145,7 +148,7 @@ func (s *SQLiteChannelStore) List(ctx context.Context) ([]*ChannelRecord, error)
 	defer s.mu.RUnlock()
 
 	query := `
-		SELECT id, name, COALESCE(website, ''), COALESCE(priority, 1), COALESCE(failover_enabled, 1), created_at, updated_at
+		SELECT id, name, COALESCE(website, ''), COALESCE(priority, 1), COALESCE(failover_enabled, 1), COALESCE(billing_currency, 'USD'), created_at, updated_at
 		FROM channels
 		ORDER BY COALESCE(priority, 1) ASC, created_at DESC, name ASC
 	`
@@ -160,7 +163,7 @@ func (s *SQLiteChannelStore) List(ctx context.Context) ([]*ChannelRecord, error)
 		var record ChannelRecord
 		var createdAt, updatedAt string
 		var failoverEnabled int
-		if err := rows.Scan(&record.ID, &record.Name, &record.Website, &record.Priority, &failoverEnabled, &createdAt, &updatedAt); err != nil {
+		if err := rows.Scan(&record.ID, &record.Name, &record.Website, &record.Priority, &failoverEnabled, &record.BillingCurrency, &createdAt, &updatedAt); err != nil {
 			return nil, fmt.Errorf("读取渠道失败: %w", err)
 		}
 		record.CreatedAt = parseSQLiteDateTime(createdAt)
@@ -188,8 +191,8 @@ func (s *SQLiteChannelStore) Update(ctx context.Context, record *ChannelRecord)
 		record.Priority = 1
 	}
 
-	query := `UPDATE channels SET website = ?, priority = ?, failover_enabled = ? WHERE name = ?`
-	res, err := s.execContext(ctx, query, nullIfEmpty(record.Website), record.Priority, boolToInt(record.FailoverEnabled), record.Name)
+	query := `UPDATE channels SET website = ?, priority = ?, failover_enabled = ?, billing_currency = ? WHERE name = ?`
+	res, err := s.execContext(ctx, query, nullIfEmpty(record.Website), record.Priority, boolToInt(record.FailoverEnabled), billingCurrencyOrDefault(record.BillingCurrency), record.Name)
 	if err != nil {
 		return fmt.Errorf("更新渠道失败: %w", err)
 	}
@@ -221,3 +224,11 @@ func nullIfEmpty(s string) any {
 	}
 	return s
 }
+
+// billingCurrencyOrDefault 结算币种为空时使用 USD
+func billingCurrencyOrDefault(currency string) string {
+	if currency == "" {
+		return "USD"
+	}
+	return currency
+}
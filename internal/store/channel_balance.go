@@ -0,0 +1,302 @@
+// 渠道余额存储
+// 中转渠道余额探测配置与余额时间序列
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ChannelBalanceProbeRecord 表示数据库中的渠道余额探测配置（每个渠道一条）
+type ChannelBalanceProbeRecord struct {
+	ID int64 `json:"id"`
+
+	Channel     string  `json:"channel"`      // 渠道名称（唯一）
+	URL         string  `json:"url"`          // 余额/额度接口地址
+	AuthHeader  string  `json:"auth_header"`  // 认证头名称
+	AuthToken   string  `json:"auth_token"`   // 认证头值
+	BalancePath string  `json:"balance_path"` // 余额 JSON 路径
+	Unit        string  `json:"unit"`         // 余额单位
+	Scale       float64 `json:"scale"`        // 换算系数：余额 = 原始值 / scale
+
+	LowThreshold    float64 `json:"low_threshold"`    // 低余额阈值（0 不告警）
+	ExcludeWhenLow  bool    `json:"exclude_when_low"` // 低余额时排除出故障转移候选
+	IntervalSeconds int     `json:"interval_seconds"` // 轮询间隔（秒）
+
+	Enabled bool `json:"enabled"`
+
+	// 审计字段
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ChannelBalanceRecord 表示一次余额探测结果（时间序列）
+type ChannelBalanceRecord struct {
+	ID        int64     `json:"id"`
+	Channel   string    `json:"channel"`
+	Balance   float64   `json:"balance"`
+	RawValue  float64   `json:"raw_value"`
+	Unit      string    `json:"unit"`
+	Low       bool      `json:"low"`
+	Error     string    `json:"error,omitempty"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// ChannelBalanceStore 定义渠道余额存储接口
+type ChannelBalanceStore interface {
+	// 探测配置
+	SaveProbe(ctx context.Context, record *ChannelBalanceProbeRecord) error
+	GetProbe(ctx context.Context, channel string) (*ChannelBalanceProbeRecord, error)
+	ListProbes(ctx context.Context) ([]*ChannelBalanceProbeRecord, error)
+	DeleteProbe(ctx context.Context, channel string) error
+
+	// 余额时间序列
+	RecordBalance(ctx context.Context, record *ChannelBalanceRecord) error
+	ListBalances(ctx context.Context, channel string, since time.Time, limit int) ([]*ChannelBalanceRecord, error)
+	DeleteBalancesBefore(ctx context.Context, before time.Time) (int64, error)
+
+	// 事务支持
+	WithTx(tx *sql.Tx) ChannelBalanceStore
+}
+
+// SQLiteChannelBalanceStore 实现 ChannelBalanceStore 接口
+type SQLiteChannelBalanceStore struct {
+	db *sql.DB
+	tx *sql.Tx // 事务上下文（可选）
+	mu sync.RWMutex
+}
+
+// NewSQLiteChannelBalanceStore 创建新的 SQLite 渠道余额存储
+func NewSQLiteChannelBalanceStore(db *sql.DB) *SQLiteChannelBalanceStore {
+	return &SQLiteChannelBalanceStore{db: db}
+}
+
+// getQuerier 返回用于执行查询的对象（事务或数据库连接）
+func (s *SQLiteChannelBalanceStore) getQuerier() interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+} {
+	if s.tx != nil {
+		return s.tx
+	}
+	return s.db
+}
+
+const channelBalanceProbeColumns = `id, channel, url, auth_header, auth_token, balance_path, unit, scale,
+	low_threshold, exclude_when_low, interval_seconds, enabled, created_at, updated_at`
+
+// SaveProbe 保存渠道余额探测配置（不存在时创建，存在时更新）
+func (s *SQLiteChannelBalanceStore) SaveProbe(ctx context.Context, record *ChannelBalanceProbeRecord) error {
+	if record == nil {
+		return fmt.Errorf("record 不能为空")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	query := `
+		INSERT INTO channel_balance_probes (
+			channel, url, auth_header, auth_token, balance_path, unit, scale,
+			low_threshold, exclude_when_low, interval_seconds, enabled
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(channel) DO UPDATE SET
+			url = excluded.url,
+			auth_header = excluded.auth_header,
+			auth_token = excluded.auth_token,
+			balance_path = excluded.balance_path,
+			unit = excluded.unit,
+			scale = excluded.scale,
+			low_threshold = excluded.low_threshold,
+			exclude_when_low = excluded.exclude_when_low,
+			interval_seconds = excluded.interval_seconds,
+			enabled = excluded.enabled
+	`
+	_, err := s.getQuerier().ExecContext(ctx, query,
+		record.Channel, record.URL, record.AuthHeader, record.AuthToken, record.BalancePath, record.Unit, record.Scale,
+		record.LowThreshold, boolToInt(record.ExcludeWhenLow), record.IntervalSeconds, boolToInt(record.Enabled),
+	)
+	if err != nil {
+		return fmt.Errorf("保存余额探测配置失败: %w", err)
+	}
+	return nil
+}
+
+// GetProbe 获取渠道余额探测配置（不存在时返回 nil）
+func (s *SQLiteChannelBalanceStore) GetProbe(ctx context.Context, channel string) (*ChannelBalanceProbeRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	records, err := s.scanProbes(ctx, `SELECT `+channelBalanceProbeColumns+` FROM channel_balance_probes WHERE channel = ?`, channel)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+	return records[0], nil
+}
+
+// ListProbes 获取所有渠道余额探测配置（按渠道名排序）
+func (s *SQLiteChannelBalanceStore) ListProbes(ctx context.Context) ([]*ChannelBalanceProbeRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.scanProbes(ctx, `SELECT `+channelBalanceProbeColumns+` FROM channel_balance_probes ORDER BY channel ASC`)
+}
+
+// DeleteProbe 删除渠道余额探测配置（保留余额历史）
+func (s *SQLiteChannelBalanceStore) DeleteProbe(ctx context.Context, channel string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result, err := s.getQuerier().ExecContext(ctx, `DELETE FROM channel_balance_probes WHERE channel = ?`, channel)
+	if err != nil {
+		return fmt.Errorf("删除余额探测配置失败: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("获取影响行数失败: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("渠道 %s 未配置余额探测", channel)
+	}
+	return nil
+}
+
+// RecordBalance 记录一次余额探测结果
+func (s *SQLiteChannelBalanceStore) RecordBalance(ctx context.Context, record *ChannelBalanceRecord) error {
+	if record == nil {
+		return fmt.Errorf("record 不能为空")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	checkedAt := record.CheckedAt
+	if checkedAt.IsZero() {
+		checkedAt = time.Now()
+	}
+
+	query := `
+		INSERT INTO channel_balances (channel, balance, raw_value, unit, low, error, checked_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`
+	result, err := s.getQuerier().ExecContext(ctx, query,
+		record.Channel, record.Balance, record.RawValue, record.Unit, boolToInt(record.Low),
+		nullIfEmpty(record.Error), formatSQLiteDateTime(checkedAt),
+	)
+	if err != nil {
+		return fmt.Errorf("记录渠道余额失败: %w", err)
+	}
+	if id, err := result.LastInsertId(); err == nil {
+		record.ID = id
+	}
+	record.CheckedAt = checkedAt
+	return nil
+}
+
+// ListBalances 获取渠道余额时间序列（按时间倒序；channel 为空时返回所有渠道，since 为零值时不限起点）
+func (s *SQLiteChannelBalanceStore) ListBalances(ctx context.Context, channel string, since time.Time, limit int) ([]*ChannelBalanceRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if limit <= 0 {
+		limit = 500
+	}
+
+	query := `
+		SELECT id, channel, balance, raw_value, unit, low, COALESCE(error, ''), checked_at
+		FROM channel_balances
+		WHERE 1 = 1
+	`
+	args := []interface{}{}
+	if channel != "" {
+		query += ` AND channel = ?`
+		args = append(args, channel)
+	}
+	if !since.IsZero() {
+		query += ` AND checked_at >= ?`
+		args = append(args, formatSQLiteDateTime(since))
+	}
+	query += ` ORDER BY checked_at DESC, id DESC LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := s.getQuerier().QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("查询渠道余额失败: %w", err)
+	}
+	defer rows.Close()
+
+	var records []*ChannelBalanceRecord
+	for rows.Next() {
+		var record ChannelBalanceRecord
+		var low int
+		var checkedAt string
+		if err := rows.Scan(&record.ID, &record.Channel, &record.Balance, &record.RawValue, &record.Unit, &low, &record.Error, &checkedAt); err != nil {
+			return nil, fmt.Errorf("扫描渠道余额失败: %w", err)
+		}
+		record.Low = low == 1
+		record.CheckedAt = parseSQLiteDateTime(checkedAt)
+		records = append(records, &record)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历渠道余额失败: %w", err)
+	}
+	return records, nil
+}
+
+// DeleteBalancesBefore 清理指定时间之前的余额记录，返回删除行数
+func (s *SQLiteChannelBalanceStore) DeleteBalancesBefore(ctx context.Context, before time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result, err := s.getQuerier().ExecContext(ctx, `DELETE FROM channel_balances WHERE checked_at < ?`, formatSQLiteDateTime(before))
+	if err != nil {
+		return 0, fmt.Errorf("清理渠道余额记录失败: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// WithTx 返回使用事务的存储实例
+func (s *SQLiteChannelBalanceStore) WithTx(tx *sql.Tx) ChannelBalanceStore {
+	return &SQLiteChannelBalanceStore{db: s.db, tx: tx}
+}
+
+// scanProbes 执行查询并扫描余额探测配置
+func (s *SQLiteChannelBalanceStore) scanProbes(ctx context.Context, query string, args ...interface{}) ([]*ChannelBalanceProbeRecord, error) {
+	rows, err := s.getQuerier().QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("查询余额探测配置失败: %w", err)
+	}
+	defer rows.Close()
+
+	var records []*ChannelBalanceProbeRecord
+	for rows.Next() {
+		var record ChannelBalanceProbeRecord
+		var excludeWhenLow, enabled int
+		var createdAt, updatedAt string
+
+		if err := rows.Scan(
+			&record.ID, &record.Channel, &record.URL, &record.AuthHeader, &record.AuthToken, &record.BalancePath, &record.Unit, &record.Scale,
+			&record.LowThreshold, &excludeWhenLow, &record.IntervalSeconds, &enabled, &createdAt, &updatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("扫描余额探测配置失败: %w", err)
+		}
+
+		record.ExcludeWhenLow = excludeWhenLow == 1
+		record.Enabled = enabled == 1
+		record.CreatedAt = parseSQLiteDateTime(createdAt)
+		record.UpdatedAt = parseSQLiteDateTime(updatedAt)
+		records = append(records, &record)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历余额探测配置失败: %w", err)
+	}
+	return records, nil
+}
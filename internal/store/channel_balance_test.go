@@ -0,0 +1,124 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func createChannelBalanceTestDB(t *testing.T) (*SQLiteChannelBalanceStore, func()) {
+	t.Helper()
+
+	db, cleanup := createTestDB(t)
+	schema := `
+		CREATE TABLE IF NOT EXISTS channel_balance_probes (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			channel TEXT NOT NULL UNIQUE,
+			url TEXT NOT NULL,
+			auth_header TEXT NOT NULL DEFAULT 'Authorization',
+			auth_token TEXT NOT NULL DEFAULT '',
+			balance_path TEXT NOT NULL,
+			unit TEXT NOT NULL DEFAULT 'USD',
+			scale REAL NOT NULL DEFAULT 1,
+			low_threshold REAL NOT NULL DEFAULT 0,
+			exclude_when_low INTEGER NOT NULL DEFAULT 0,
+			interval_seconds INTEGER NOT NULL DEFAULT 600,
+			enabled INTEGER DEFAULT 1,
+			created_at DATETIME DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now', 'localtime') || '+08:00'),
+			updated_at DATETIME DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now', 'localtime') || '+08:00')
+		);
+		CREATE TABLE IF NOT EXISTS channel_balances (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			channel TEXT NOT NULL,
+			balance REAL NOT NULL DEFAULT 0,
+			raw_value REAL NOT NULL DEFAULT 0,
+			unit TEXT NOT NULL DEFAULT '',
+			low INTEGER NOT NULL DEFAULT 0,
+			error TEXT,
+			checked_at DATETIME NOT NULL
+		);
+	`
+	if _, err := db.Exec(schema); err != nil {
+		cleanup()
+		t.Fatalf("创建渠道余额表失败: %v", err)
+	}
+	return NewSQLiteChannelBalanceStore(db), cleanup
+}
+
+// TestChannelBalanceStore_ProbesAndHistory 测试余额探测配置的保存/覆盖与余额时间序列
+func TestChannelBalanceStore_ProbesAndHistory(t *testing.T) {
+	s, cleanup := createChannelBalanceTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	probe := &ChannelBalanceProbeRecord{
+		Channel:         "relay",
+		URL:             "https://relay.example.com/api/user/self",
+		AuthHeader:      "Authorization",
+		AuthToken:       "sk-test",
+		BalancePath:     "data.quota",
+		Unit:            "USD",
+		Scale:           500000,
+		LowThreshold:    5,
+		IntervalSeconds: 300,
+		Enabled:         true,
+	}
+	if err := s.SaveProbe(ctx, probe); err != nil {
+		t.Fatalf("保存探测配置失败: %v", err)
+	}
+
+	probe.ExcludeWhenLow = true
+	probe.LowThreshold = 10
+	if err := s.SaveProbe(ctx, probe); err != nil {
+		t.Fatalf("覆盖探测配置失败: %v", err)
+	}
+
+	got, err := s.GetProbe(ctx, "relay")
+	if err != nil || got == nil {
+		t.Fatalf("获取探测配置失败: %v", err)
+	}
+	if !got.ExcludeWhenLow || got.LowThreshold != 10 || got.Scale != 500000 || got.AuthToken != "sk-test" || !got.Enabled {
+		t.Errorf("探测配置不符: %+v", got)
+	}
+	if list, _ := s.ListProbes(ctx); len(list) != 1 {
+		t.Errorf("探测配置数 = %d, want 1", len(list))
+	}
+	if missing, err := s.GetProbe(ctx, "none"); err != nil || missing != nil {
+		t.Errorf("不存在的配置应返回 nil: %+v, %v", missing, err)
+	}
+
+	now := time.Now()
+	for _, r := range []*ChannelBalanceRecord{
+		{Channel: "relay", Balance: 20, RawValue: 10000000, Unit: "USD", CheckedAt: now.Add(-72 * time.Hour)},
+		{Channel: "relay", Balance: 8, RawValue: 4000000, Unit: "USD", Low: true, CheckedAt: now.Add(-time.Hour)},
+		{Channel: "relay", Error: "HTTP 502", CheckedAt: now},
+		{Channel: "other", Balance: 100, Unit: "CNY", CheckedAt: now},
+	} {
+		if err := s.RecordBalance(ctx, r); err != nil {
+			t.Fatalf("记录余额失败: %v", err)
+		}
+	}
+
+	history, err := s.ListBalances(ctx, "relay", now.Add(-24*time.Hour), 0)
+	if err != nil {
+		t.Fatalf("查询余额历史失败: %v", err)
+	}
+	if len(history) != 2 || history[0].Error != "HTTP 502" || !history[1].Low || history[1].Balance != 8 {
+		t.Errorf("余额历史不符: %+v", history)
+	}
+	if all, _ := s.ListBalances(ctx, "", time.Time{}, 0); len(all) != 4 {
+		t.Errorf("全部余额记录数 = %d, want 4", len(all))
+	}
+
+	removed, err := s.DeleteBalancesBefore(ctx, now.Add(-24*time.Hour))
+	if err != nil || removed != 1 {
+		t.Errorf("清理过期余额记录 = %d, %v, want 1", removed, err)
+	}
+
+	if err := s.DeleteProbe(ctx, "relay"); err != nil {
+		t.Fatalf("删除探测配置失败: %v", err)
+	}
+	if err := s.DeleteProbe(ctx, "relay"); err == nil {
+		t.Error("删除不存在的配置应返回错误")
+	}
+}
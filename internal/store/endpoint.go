@@ -33,7 +33,8 @@ type EndpointRecord struct {
 	TimeoutSeconds  int  `json:"timeout_seconds"`  // 请求超时（秒）
 
 	// 功能支持
-	SupportsCountTokens bool `json:"supports_count_tokens"` // 是否支持 count_tokens
+	SupportsCountTokens bool            `json:"supports_count_tokens"`  // 是否支持 count_tokens
+	Capabilities        map[string]bool `json:"capabilities,omitempty"` // 能力声明（未声明的能力由运行时自动探测）
 
 	// 成本倍率
 	CostMultiplier                float64 `json:"cost_multiplier"`
@@ -141,17 +142,17 @@ func (s *SQLiteEndpointStore) Create(ctx context.Context, record *EndpointRecord
 		INSERT INTO endpoints (
 			channel, name, url, token, api_key, headers,
 			priority, failover_enabled, cooldown_seconds, timeout_seconds,
-			supports_count_tokens,
+			supports_count_tokens, capabilities,
 			cost_multiplier, input_cost_multiplier, output_cost_multiplier,
 			cache_creation_cost_multiplier, cache_creation_cost_multiplier_1h, cache_read_cost_multiplier,
 			enabled
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 
 	result, err := s.getQuerier().ExecContext(ctx, query,
 		record.Channel, record.Name, record.URL, record.Token, record.ApiKey, string(headersJSON),
 		record.Priority, boolToInt(record.FailoverEnabled), record.CooldownSeconds, record.TimeoutSeconds,
-		boolToInt(record.SupportsCountTokens),
+		boolToInt(record.SupportsCountTokens), marshalCapabilities(record.Capabilities),
 		record.CostMultiplier, record.InputCostMultiplier, record.OutputCostMultiplier,
 		record.CacheCreationCostMultiplier, record.CacheCreationCostMultiplier1h, record.CacheReadCostMultiplier,
 		boolToInt(record.Enabled),
@@ -180,7 +181,7 @@ func (s *SQLiteEndpointStore) Get(ctx context.Context, name string) (*EndpointRe
 	query := `
 		SELECT id, channel, name, url, token, api_key, headers,
 			priority, failover_enabled, cooldown_seconds, timeout_seconds,
-			supports_count_tokens,
+			supports_count_tokens, capabilities,
 			cost_multiplier, input_cost_multiplier, output_cost_multiplier,
 			cache_creation_cost_multiplier, cache_creation_cost_multiplier_1h, cache_read_cost_multiplier,
 			enabled, created_at, updated_at
@@ -198,7 +199,7 @@ func (s *SQLiteEndpointStore) GetByChannelAndName(ctx context.Context, channel,
 	query := `
 		SELECT id, channel, name, url, token, api_key, headers,
 			priority, failover_enabled, cooldown_seconds, timeout_seconds,
-			supports_count_tokens,
+			supports_count_tokens, capabilities,
 			cost_multiplier, input_cost_multiplier, output_cost_multiplier,
 			cache_creation_cost_multiplier, cache_creation_cost_multiplier_1h, cache_read_cost_multiplier,
 			enabled, created_at, updated_at
@@ -216,7 +217,7 @@ func (s *SQLiteEndpointStore) GetByID(ctx context.Context, id int64) (*EndpointR
 	query := `
 		SELECT id, channel, name, url, token, api_key, headers,
 			priority, failover_enabled, cooldown_seconds, timeout_seconds,
-			supports_count_tokens,
+			supports_count_tokens, capabilities,
 			cost_multiplier, input_cost_multiplier, output_cost_multiplier,
 			cache_creation_cost_multiplier, cache_creation_cost_multiplier_1h, cache_read_cost_multiplier,
 			enabled, created_at, updated_at
@@ -234,7 +235,7 @@ func (s *SQLiteEndpointStore) List(ctx context.Context) ([]*EndpointRecord, erro
 	query := `
 		SELECT id, channel, name, url, token, api_key, headers,
 			priority, failover_enabled, cooldown_seconds, timeout_seconds,
-			supports_count_tokens,
+			supports_count_tokens, capabilities,
 			cost_multiplier, input_cost_multiplier, output_cost_multiplier,
 			cache_creation_cost_multiplier, cache_creation_cost_multiplier_1h, cache_read_cost_multiplier,
 			enabled, created_at, updated_at
@@ -319,7 +320,7 @@ func (s *SQLiteEndpointStore) updateByIDLocked(ctx context.Context, record *Endp
 		UPDATE endpoints SET
 			channel = ?, name = ?, url = ?, token = ?, api_key = ?, headers = ?,
 			priority = ?, failover_enabled = ?, cooldown_seconds = ?, timeout_seconds = ?,
-			supports_count_tokens = ?,
+			supports_count_tokens = ?, capabilities = ?,
 			cost_multiplier = ?, input_cost_multiplier = ?, output_cost_multiplier = ?,
 			cache_creation_cost_multiplier = ?, cache_creation_cost_multiplier_1h = ?, cache_read_cost_multiplier = ?,
 			enabled = ?
@@ -329,7 +330,7 @@ func (s *SQLiteEndpointStore) updateByIDLocked(ctx context.Context, record *Endp
 	result, err := s.getQuerier().ExecContext(ctx, query,
 		record.Channel, record.Name, record.URL, record.Token, record.ApiKey, string(headersJSON),
 		record.Priority, boolToInt(record.FailoverEnabled), record.CooldownSeconds, record.TimeoutSeconds,
-		boolToInt(record.SupportsCountTokens),
+		boolToInt(record.SupportsCountTokens), marshalCapabilities(record.Capabilities),
 		record.CostMultiplier, record.InputCostMultiplier, record.OutputCostMultiplier,
 		record.CacheCreationCostMultiplier, record.CacheCreationCostMultiplier1h, record.CacheReadCostMultiplier,
 		boolToInt(record.Enabled),
@@ -360,7 +361,7 @@ func (s *SQLiteEndpointStore) updateByChannelAndNameLocked(ctx context.Context,
 		UPDATE endpoints SET
 			url = ?, token = ?, api_key = ?, headers = ?,
 			priority = ?, failover_enabled = ?, cooldown_seconds = ?, timeout_seconds = ?,
-			supports_count_tokens = ?,
+			supports_count_tokens = ?, capabilities = ?,
 			cost_multiplier = ?, input_cost_multiplier = ?, output_cost_multiplier = ?,
 			cache_creation_cost_multiplier = ?, cache_creation_cost_multiplier_1h = ?, cache_read_cost_multiplier = ?,
 			enabled = ?
@@ -370,7 +371,7 @@ func (s *SQLiteEndpointStore) updateByChannelAndNameLocked(ctx context.Context,
 	result, err := s.getQuerier().ExecContext(ctx, query,
 		record.URL, record.Token, record.ApiKey, string(headersJSON),
 		record.Priority, boolToInt(record.FailoverEnabled), record.CooldownSeconds, record.TimeoutSeconds,
-		boolToInt(record.SupportsCountTokens),
+		boolToInt(record.SupportsCountTokens), marshalCapabilities(record.Capabilities),
 		record.CostMultiplier, record.InputCostMultiplier, record.OutputCostMultiplier,
 		record.CacheCreationCostMultiplier, record.CacheCreationCostMultiplier1h, record.CacheReadCostMultiplier,
 		boolToInt(record.Enabled),
@@ -429,11 +430,11 @@ func (s *SQLiteEndpointStore) BatchCreate(ctx context.Context, records []*Endpoi
 		INSERT INTO endpoints (
 			channel, name, url, token, api_key, headers,
 			priority, failover_enabled, cooldown_seconds, timeout_seconds,
-			supports_count_tokens,
+			supports_count_tokens, capabilities,
 			cost_multiplier, input_cost_multiplier, output_cost_multiplier,
 			cache_creation_cost_multiplier, cache_creation_cost_multiplier_1h, cache_read_cost_multiplier,
 			enabled
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 
 	stmt, err := tx.PrepareContext(ctx, query)
@@ -474,7 +475,7 @@ func (s *SQLiteEndpointStore) BatchCreate(ctx context.Context, records []*Endpoi
 		_, err = stmt.ExecContext(ctx,
 			record.Channel, record.Name, record.URL, record.Token, record.ApiKey, string(headersJSON),
 			record.Priority, boolToInt(record.FailoverEnabled), record.CooldownSeconds, record.TimeoutSeconds,
-			boolToInt(record.SupportsCountTokens),
+			boolToInt(record.SupportsCountTokens), marshalCapabilities(record.Capabilities),
 			record.CostMultiplier, record.InputCostMultiplier, record.OutputCostMultiplier,
 			record.CacheCreationCostMultiplier, record.CacheCreationCostMultiplier1h, record.CacheReadCostMultiplier,
 			boolToInt(record.Enabled),
@@ -525,7 +526,7 @@ func (s *SQLiteEndpointStore) ListByChannel(ctx context.Context, channel string)
 	query := `
 		SELECT id, channel, name, url, token, api_key, headers,
 			priority, failover_enabled, cooldown_seconds, timeout_seconds,
-			supports_count_tokens,
+			supports_count_tokens, capabilities,
 			cost_multiplier, input_cost_multiplier, output_cost_multiplier,
 			cache_creation_cost_multiplier, cache_creation_cost_multiplier_1h, cache_read_cost_multiplier,
 			enabled, created_at, updated_at
@@ -545,7 +546,7 @@ func (s *SQLiteEndpointStore) ListEnabled(ctx context.Context) ([]*EndpointRecor
 	query := `
 		SELECT id, channel, name, url, token, api_key, headers,
 			priority, failover_enabled, cooldown_seconds, timeout_seconds,
-			supports_count_tokens,
+			supports_count_tokens, capabilities,
 			cost_multiplier, input_cost_multiplier, output_cost_multiplier,
 			cache_creation_cost_multiplier, cache_creation_cost_multiplier_1h, cache_read_cost_multiplier,
 			enabled, created_at, updated_at
@@ -583,6 +584,7 @@ func (s *SQLiteEndpointStore) WithTx(tx *sql.Tx) EndpointStore {
 func (s *SQLiteEndpointStore) scanEndpoint(row *sql.Row) (*EndpointRecord, error) {
 	var record EndpointRecord
 	var headersJSON string
+	var capabilitiesJSON sql.NullString
 	var cooldownSeconds sql.NullInt64
 	var failoverEnabled, supportsCountTokens, enabled int
 	var createdAt, updatedAt string
@@ -591,7 +593,7 @@ func (s *SQLiteEndpointStore) scanEndpoint(row *sql.Row) (*EndpointRecord, error
 		&record.ID, &record.Channel, &record.Name, &record.URL,
 		&record.Token, &record.ApiKey, &headersJSON,
 		&record.Priority, &failoverEnabled, &cooldownSeconds, &record.TimeoutSeconds,
-		&supportsCountTokens,
+		&supportsCountTokens, &capabilitiesJSON,
 		&record.CostMultiplier, &record.InputCostMultiplier, &record.OutputCostMultiplier,
 		&record.CacheCreationCostMultiplier, &record.CacheCreationCostMultiplier1h, &record.CacheReadCostMultiplier,
 		&enabled, &createdAt, &updatedAt,
@@ -619,6 +621,7 @@ func (s *SQLiteEndpointStore) scanEndpoint(row *sql.Row) (*EndpointRecord, error
 	// 转换布尔值
 	record.FailoverEnabled = failoverEnabled == 1
 	record.SupportsCountTokens = supportsCountTokens == 1
+	record.Capabilities = unmarshalCapabilities(capabilitiesJSON.String)
 	record.Enabled = enabled == 1
 
 	// 解析时间
@@ -645,6 +648,7 @@ func (s *SQLiteEndpointStore) scanEndpointsWithArgs(ctx context.Context, query s
 	for rows.Next() {
 		var record EndpointRecord
 		var headersJSON string
+		var capabilitiesJSON sql.NullString
 		var cooldownSeconds sql.NullInt64
 		var failoverEnabled, supportsCountTokens, enabled int
 		var createdAt, updatedAt string
@@ -653,7 +657,7 @@ func (s *SQLiteEndpointStore) scanEndpointsWithArgs(ctx context.Context, query s
 			&record.ID, &record.Channel, &record.Name, &record.URL,
 			&record.Token, &record.ApiKey, &headersJSON,
 			&record.Priority, &failoverEnabled, &cooldownSeconds, &record.TimeoutSeconds,
-			&supportsCountTokens,
+			&supportsCountTokens, &capabilitiesJSON,
 			&record.CostMultiplier, &record.InputCostMultiplier, &record.OutputCostMultiplier,
 			&record.CacheCreationCostMultiplier, &record.CacheCreationCostMultiplier1h, &record.CacheReadCostMultiplier,
 			&enabled, &createdAt, &updatedAt,
@@ -678,6 +682,7 @@ func (s *SQLiteEndpointStore) scanEndpointsWithArgs(ctx context.Context, query s
 		// 转换布尔值
 		record.FailoverEnabled = failoverEnabled == 1
 		record.SupportsCountTokens = supportsCountTokens == 1
+		record.Capabilities = unmarshalCapabilities(capabilitiesJSON.String)
 		record.Enabled = enabled == 1
 
 		// 解析时间
@@ -694,6 +699,30 @@ func (s *SQLiteEndpointStore) scanEndpointsWithArgs(ctx context.Context, query s
 	return records, nil
 }
 
+// marshalCapabilities 序列化能力声明（空集合存为 NULL）
+func marshalCapabilities(capabilities map[string]bool) interface{} {
+	if len(capabilities) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(capabilities)
+	if err != nil {
+		return nil
+	}
+	return string(data)
+}
+
+// unmarshalCapabilities 解析能力声明，解析失败时视为未声明
+func unmarshalCapabilities(data string) map[string]bool {
+	if data == "" || data == "null" {
+		return nil
+	}
+	var capabilities map[string]bool
+	if err := json.Unmarshal([]byte(data), &capabilities); err != nil {
+		return nil
+	}
+	return capabilities
+}
+
 // boolToInt 将布尔值转换为整数
 func boolToInt(b bool) int {
 	if b {
@@ -0,0 +1,276 @@
+// 端点模型存储
+// 记录各端点 /v1/models 自动发现的模型及手动覆盖（允许/禁止）
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// 端点模型来源
+const (
+	EndpointModelSourceDiscovered = "discovered" // /v1/models 自动发现
+	EndpointModelSourceManual     = "manual"     // 手动覆盖
+)
+
+// EndpointModelRecord 表示数据库中的端点模型记录
+type EndpointModelRecord struct {
+	ID int64 `json:"id"`
+
+	// 端点标识
+	Channel      string `json:"channel"`
+	EndpointName string `json:"endpoint_name"`
+
+	// 模型信息
+	ModelID     string `json:"model_id"`
+	DisplayName string `json:"display_name,omitempty"`
+	Source      string `json:"source"`  // discovered / manual
+	Allowed     bool   `json:"allowed"` // 手动覆盖: true=允许, false=禁止
+
+	// 审计字段
+	DiscoveredAt time.Time `json:"discovered_at"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// EndpointModelStore 定义端点模型存储接口
+type EndpointModelStore interface {
+	// 查询
+	List(ctx context.Context) ([]*EndpointModelRecord, error)
+	ListByEndpoint(ctx context.Context, channel, endpointName string) ([]*EndpointModelRecord, error)
+
+	// 自动发现：整体替换端点的 discovered 记录
+	ReplaceDiscovered(ctx context.Context, channel, endpointName string, records []*EndpointModelRecord) error
+
+	// 手动覆盖
+	UpsertOverride(ctx context.Context, channel, endpointName, modelID string, allowed bool) error
+	DeleteOverride(ctx context.Context, channel, endpointName, modelID string) error
+
+	// 端点删除时清理
+	DeleteByEndpoint(ctx context.Context, channel, endpointName string) error
+
+	// 事务支持
+	WithTx(tx *sql.Tx) EndpointModelStore
+}
+
+// SQLiteEndpointModelStore 实现 EndpointModelStore 接口
+type SQLiteEndpointModelStore struct {
+	db *sql.DB
+	tx *sql.Tx // 事务上下文（可选）
+	mu sync.RWMutex
+}
+
+// NewSQLiteEndpointModelStore 创建新的 SQLite 端点模型存储
+func NewSQLiteEndpointModelStore(db *sql.DB) *SQLiteEndpointModelStore {
+	return &SQLiteEndpointModelStore{db: db}
+}
+
+// getQuerier 返回用于执行查询的对象（事务或数据库连接）
+func (s *SQLiteEndpointModelStore) getQuerier() interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+} {
+	if s.tx != nil {
+		return s.tx
+	}
+	return s.db
+}
+
+const endpointModelColumns = `
+	id, channel, endpoint_name, model_id, display_name, source, allowed,
+	discovered_at, created_at, updated_at
+`
+
+// List 获取所有端点模型记录
+func (s *SQLiteEndpointModelStore) List(ctx context.Context) ([]*EndpointModelRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	query := `SELECT ` + endpointModelColumns + ` FROM endpoint_models
+		ORDER BY channel ASC, endpoint_name ASC, model_id ASC`
+
+	return s.scanEndpointModels(ctx, query)
+}
+
+// ListByEndpoint 获取指定端点的模型记录
+func (s *SQLiteEndpointModelStore) ListByEndpoint(ctx context.Context, channel, endpointName string) ([]*EndpointModelRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	query := `SELECT ` + endpointModelColumns + ` FROM endpoint_models
+		WHERE channel = ? AND endpoint_name = ?
+		ORDER BY model_id ASC`
+
+	return s.scanEndpointModels(ctx, query, channel, endpointName)
+}
+
+// ReplaceDiscovered 整体替换端点的自动发现记录（手动覆盖不受影响）
+func (s *SQLiteEndpointModelStore) ReplaceDiscovered(ctx context.Context, channel, endpointName string, records []*EndpointModelRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// 已处于外部事务中时直接复用
+	if s.tx != nil {
+		return replaceDiscoveredModels(ctx, s.tx, channel, endpointName, records)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("开始事务失败: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := replaceDiscoveredModels(ctx, tx, channel, endpointName, records); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("提交事务失败: %w", err)
+	}
+	return nil
+}
+
+func replaceDiscoveredModels(ctx context.Context, tx *sql.Tx, channel, endpointName string, records []*EndpointModelRecord) error {
+	if _, err := tx.ExecContext(ctx,
+		`DELETE FROM endpoint_models WHERE channel = ? AND endpoint_name = ? AND source = ?`,
+		channel, endpointName, EndpointModelSourceDiscovered,
+	); err != nil {
+		return fmt.Errorf("清理端点 %s 已发现模型失败: %w", endpointName, err)
+	}
+
+	if len(records) == 0 {
+		return nil
+	}
+
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT OR IGNORE INTO endpoint_models (
+			channel, endpoint_name, model_id, display_name, source, allowed, discovered_at
+		) VALUES (?, ?, ?, ?, ?, 1, ?)
+	`)
+	if err != nil {
+		return fmt.Errorf("准备语句失败: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, record := range records {
+		discoveredAt := record.DiscoveredAt
+		if discoveredAt.IsZero() {
+			discoveredAt = time.Now()
+		}
+		if _, err := stmt.ExecContext(ctx,
+			channel, endpointName, record.ModelID, record.DisplayName,
+			EndpointModelSourceDiscovered, formatSQLiteDateTime(discoveredAt),
+		); err != nil {
+			return fmt.Errorf("插入端点模型 %s 失败: %w", record.ModelID, err)
+		}
+	}
+	return nil
+}
+
+// UpsertOverride 创建或更新手动覆盖
+func (s *SQLiteEndpointModelStore) UpsertOverride(ctx context.Context, channel, endpointName, modelID string, allowed bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	query := `
+		INSERT INTO endpoint_models (channel, endpoint_name, model_id, source, allowed)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(channel, endpoint_name, model_id, source) DO UPDATE SET
+			allowed = excluded.allowed
+	`
+
+	if _, err := s.getQuerier().ExecContext(ctx, query,
+		channel, endpointName, modelID, EndpointModelSourceManual, boolToInt(allowed),
+	); err != nil {
+		return fmt.Errorf("保存端点模型覆盖失败: %w", err)
+	}
+	return nil
+}
+
+// DeleteOverride 删除手动覆盖
+func (s *SQLiteEndpointModelStore) DeleteOverride(ctx context.Context, channel, endpointName, modelID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result, err := s.getQuerier().ExecContext(ctx,
+		`DELETE FROM endpoint_models WHERE channel = ? AND endpoint_name = ? AND model_id = ? AND source = ?`,
+		channel, endpointName, modelID, EndpointModelSourceManual,
+	)
+	if err != nil {
+		return fmt.Errorf("删除端点模型覆盖失败: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("获取影响行数失败: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("端点 '%s' 的模型 '%s' 没有手动覆盖", endpointName, modelID)
+	}
+	return nil
+}
+
+// DeleteByEndpoint 删除端点的全部模型记录
+func (s *SQLiteEndpointModelStore) DeleteByEndpoint(ctx context.Context, channel, endpointName string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.getQuerier().ExecContext(ctx,
+		`DELETE FROM endpoint_models WHERE channel = ? AND endpoint_name = ?`,
+		channel, endpointName,
+	); err != nil {
+		return fmt.Errorf("删除端点模型记录失败: %w", err)
+	}
+	return nil
+}
+
+// WithTx 返回使用事务的存储实例
+func (s *SQLiteEndpointModelStore) WithTx(tx *sql.Tx) EndpointModelStore {
+	return &SQLiteEndpointModelStore{
+		db: s.db,
+		tx: tx,
+	}
+}
+
+// scanEndpointModels 扫描多行端点模型记录
+func (s *SQLiteEndpointModelStore) scanEndpointModels(ctx context.Context, query string, args ...interface{}) ([]*EndpointModelRecord, error) {
+	rows, err := queryRowsWithSQLiteBusyRetry(ctx, func() (*sql.Rows, error) {
+		return s.getQuerier().QueryContext(ctx, query, args...)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("查询端点模型失败: %w", err)
+	}
+	defer rows.Close()
+
+	var records []*EndpointModelRecord
+	for rows.Next() {
+		record := &EndpointModelRecord{}
+		var displayName, discoveredAt sql.NullString
+		var allowed int
+		var createdAt, updatedAt string
+
+		if err := rows.Scan(
+			&record.ID, &record.Channel, &record.EndpointName, &record.ModelID,
+			&displayName, &record.Source, &allowed,
+			&discoveredAt, &createdAt, &updatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("扫描端点模型失败: %w", err)
+		}
+
+		record.DisplayName = displayName.String
+		record.Allowed = allowed == 1
+		record.DiscoveredAt = parseSQLiteDateTime(discoveredAt.String)
+		record.CreatedAt = parseSQLiteDateTime(createdAt)
+		record.UpdatedAt = parseSQLiteDateTime(updatedAt)
+		records = append(records, record)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历端点模型失败: %w", err)
+	}
+	return records, nil
+}
@@ -0,0 +1,120 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func createEndpointModelTestDB(t *testing.T) (*SQLiteEndpointModelStore, func()) {
+	t.Helper()
+
+	db, cleanup := createTestDB(t)
+	schema := `
+		CREATE TABLE IF NOT EXISTS endpoint_models (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			channel TEXT NOT NULL DEFAULT '',
+			endpoint_name TEXT NOT NULL,
+			model_id TEXT NOT NULL,
+			display_name TEXT,
+			source TEXT NOT NULL DEFAULT 'discovered',
+			allowed INTEGER NOT NULL DEFAULT 1,
+			discovered_at DATETIME,
+			created_at DATETIME DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now', 'localtime') || '+08:00'),
+			updated_at DATETIME DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now', 'localtime') || '+08:00'),
+			UNIQUE(channel, endpoint_name, model_id, source)
+		);
+	`
+	if _, err := db.Exec(schema); err != nil {
+		cleanup()
+		t.Fatalf("创建 endpoint_models 表失败: %v", err)
+	}
+	return NewSQLiteEndpointModelStore(db), cleanup
+}
+
+// TestEndpointModelStore_ReplaceDiscovered 测试整体替换自动发现记录
+func TestEndpointModelStore_ReplaceDiscovered(t *testing.T) {
+	s, cleanup := createEndpointModelTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	if err := s.ReplaceDiscovered(ctx, "A", "ep1", []*EndpointModelRecord{
+		{ModelID: "claude-sonnet-4-5", DisplayName: "Claude Sonnet 4.5"},
+		{ModelID: "claude-opus-4-1"},
+	}); err != nil {
+		t.Fatalf("保存发现结果失败: %v", err)
+	}
+	if err := s.UpsertOverride(ctx, "A", "ep1", "claude-opus-4-1", false); err != nil {
+		t.Fatalf("保存覆盖失败: %v", err)
+	}
+
+	// 再次发现：旧的 discovered 记录被替换，manual 记录保留
+	discoveredAt := time.Date(2025, 10, 1, 12, 0, 0, 0, time.FixedZone("CST", 8*3600))
+	if err := s.ReplaceDiscovered(ctx, "A", "ep1", []*EndpointModelRecord{
+		{ModelID: "claude-haiku-4-5", DiscoveredAt: discoveredAt},
+	}); err != nil {
+		t.Fatalf("替换发现结果失败: %v", err)
+	}
+
+	records, err := s.ListByEndpoint(ctx, "A", "ep1")
+	if err != nil {
+		t.Fatalf("查询失败: %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("期望 2 条记录（1 discovered + 1 manual）, 实际 %d", len(records))
+	}
+
+	for _, r := range records {
+		switch r.Source {
+		case EndpointModelSourceDiscovered:
+			if r.ModelID != "claude-haiku-4-5" || !r.Allowed || !r.DiscoveredAt.Equal(discoveredAt) {
+				t.Errorf("discovered 记录不符: %+v", r)
+			}
+		case EndpointModelSourceManual:
+			if r.ModelID != "claude-opus-4-1" || r.Allowed {
+				t.Errorf("manual 记录不符: %+v", r)
+			}
+		default:
+			t.Errorf("未知来源: %s", r.Source)
+		}
+	}
+}
+
+// TestEndpointModelStore_Overrides 测试手动覆盖的增删改
+func TestEndpointModelStore_Overrides(t *testing.T) {
+	s, cleanup := createEndpointModelTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	if err := s.UpsertOverride(ctx, "A", "ep1", "custom-model", true); err != nil {
+		t.Fatalf("保存覆盖失败: %v", err)
+	}
+	if err := s.UpsertOverride(ctx, "A", "ep1", "custom-model", false); err != nil {
+		t.Fatalf("更新覆盖失败: %v", err)
+	}
+
+	records, err := s.List(ctx)
+	if err != nil {
+		t.Fatalf("查询失败: %v", err)
+	}
+	if len(records) != 1 || records[0].Allowed {
+		t.Fatalf("覆盖应被更新为禁止, 实际 %+v", records)
+	}
+
+	if err := s.DeleteOverride(ctx, "A", "ep1", "custom-model"); err != nil {
+		t.Fatalf("删除覆盖失败: %v", err)
+	}
+	if err := s.DeleteOverride(ctx, "A", "ep1", "custom-model"); err == nil {
+		t.Error("删除不存在的覆盖应返回错误")
+	}
+
+	if err := s.ReplaceDiscovered(ctx, "A", "ep1", []*EndpointModelRecord{{ModelID: "m1"}}); err != nil {
+		t.Fatalf("保存发现结果失败: %v", err)
+	}
+	if err := s.DeleteByEndpoint(ctx, "A", "ep1"); err != nil {
+		t.Fatalf("删除端点记录失败: %v", err)
+	}
+	if records, _ := s.List(ctx); len(records) != 0 {
+		t.Errorf("删除端点后应无记录, 实际 %d", len(records))
+	}
+}
@@ -43,6 +43,7 @@ func createTestDB(t *testing.T) (*sql.DB, func()) {
 			cooldown_seconds INTEGER,
 			timeout_seconds INTEGER DEFAULT 300,
 			supports_count_tokens INTEGER DEFAULT 0,
+			capabilities TEXT,
 			cost_multiplier REAL DEFAULT 1.0,
 			input_cost_multiplier REAL DEFAULT 1.0,
 			output_cost_multiplier REAL DEFAULT 1.0,
@@ -555,6 +556,49 @@ func TestCooldownSeconds(t *testing.T) {
 	}
 }
 
+// TestCapabilities 测试能力集读写
+func TestCapabilities(t *testing.T) {
+	db, cleanup := createTestDB(t)
+	defer cleanup()
+
+	store := NewSQLiteEndpointStore(db)
+	ctx := context.Background()
+
+	record := &EndpointRecord{
+		Channel:         "test",
+		Name:            "capability-test",
+		URL:             "https://api.example.com",
+		FailoverEnabled: true,
+		Enabled:         true,
+		Capabilities:    map[string]bool{"thinking": true, "web_search": false},
+	}
+
+	if _, err := store.Create(ctx, record); err != nil {
+		t.Fatalf("创建端点失败: %v", err)
+	}
+
+	got, err := store.Get(ctx, "capability-test")
+	if err != nil {
+		t.Fatalf("获取端点失败: %v", err)
+	}
+	if len(got.Capabilities) != 2 || !got.Capabilities["thinking"] || got.Capabilities["web_search"] {
+		t.Errorf("Capabilities 不匹配: got %v", got.Capabilities)
+	}
+
+	// 清空能力集
+	got.Capabilities = nil
+	if err := store.Update(ctx, got); err != nil {
+		t.Fatalf("更新端点失败: %v", err)
+	}
+	got, err = store.Get(ctx, "capability-test")
+	if err != nil {
+		t.Fatalf("获取端点失败: %v", err)
+	}
+	if len(got.Capabilities) != 0 {
+		t.Errorf("Capabilities 应为空: got %v", got.Capabilities)
+	}
+}
+
 // TestCount 测试计数
 func TestCount(t *testing.T) {
 	db, cleanup := createTestDB(t)
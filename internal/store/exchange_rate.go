@@ -0,0 +1,169 @@
+// 汇率存储
+// 记录 1 USD 可兑换的目标币种数量及生效时间，用于多币种成本换算
+// 渠道专属汇率（Channel 非空）优先于同币种的全局汇率
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ExchangeRateRecord 表示数据库中的汇率记录
+type ExchangeRateRecord struct {
+	ID            int64     `json:"id"`
+	Channel       string    `json:"channel,omitempty"` // 渠道名（空表示全局汇率）
+	Currency      string    `json:"currency"`          // 币种代码（ISO 4217）
+	Rate          float64   `json:"rate"`              // 1 USD 可兑换的该币种数量
+	EffectiveFrom time.Time `json:"effective_from"`    // 生效时间
+	Note          string    `json:"note,omitempty"`
+
+	// 审计字段
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ExchangeRateStore 定义汇率存储接口
+type ExchangeRateStore interface {
+	// 创建或更新（同一渠道/全局、同一币种、同一生效时间唯一，重复保存时更新汇率与备注）
+	Upsert(ctx context.Context, record *ExchangeRateRecord) (*ExchangeRateRecord, error)
+	// 列出汇率（currency 为空时返回全部），按币种、渠道（全局在前）、生效时间升序
+	List(ctx context.Context, currency string) ([]*ExchangeRateRecord, error)
+	Delete(ctx context.Context, id int64) error
+
+	// 事务支持
+	WithTx(tx *sql.Tx) ExchangeRateStore
+}
+
+// SQLiteExchangeRateStore 实现 ExchangeRateStore 接口
+type SQLiteExchangeRateStore struct {
+	db *sql.DB
+	tx *sql.Tx // 事务上下文（可选）
+	mu sync.RWMutex
+}
+
+// NewSQLiteExchangeRateStore 创建新的 SQLite 汇率存储
+func NewSQLiteExchangeRateStore(db *sql.DB) *SQLiteExchangeRateStore {
+	return &SQLiteExchangeRateStore{db: db}
+}
+
+// getQuerier 返回用于执行查询的对象（事务或数据库连接）
+func (s *SQLiteExchangeRateStore) getQuerier() interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+} {
+	if s.tx != nil {
+		return s.tx
+	}
+	return s.db
+}
+
+// Upsert 保存汇率记录，返回保存后的记录
+func (s *SQLiteExchangeRateStore) Upsert(ctx context.Context, record *ExchangeRateRecord) (*ExchangeRateRecord, error) {
+	if record == nil {
+		return nil, fmt.Errorf("record 不能为空")
+	}
+	if record.Currency == "" {
+		return nil, fmt.Errorf("币种不能为空")
+	}
+	if record.Rate <= 0 {
+		return nil, fmt.Errorf("汇率必须大于 0")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	effectiveFrom := formatSQLiteDateTime(record.EffectiveFrom)
+	query := `
+		INSERT INTO exchange_rates (channel, currency, rate, effective_from, note)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(COALESCE(channel, ''), currency, effective_from) DO UPDATE SET
+			rate = excluded.rate,
+			note = excluded.note
+	`
+	if _, err := s.getQuerier().ExecContext(ctx, query,
+		nullIfEmpty(record.Channel), record.Currency, record.Rate, effectiveFrom, nullIfEmpty(record.Note),
+	); err != nil {
+		return nil, fmt.Errorf("保存汇率失败: %w", err)
+	}
+
+	records, err := s.scanExchangeRates(ctx, exchangeRateSelect+`
+		WHERE COALESCE(channel, '') = ? AND currency = ? AND effective_from = ?
+	`, record.Channel, record.Currency, effectiveFrom)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("保存汇率失败: 记录不存在")
+	}
+	return records[0], nil
+}
+
+// List 列出汇率记录
+func (s *SQLiteExchangeRateStore) List(ctx context.Context, currency string) ([]*ExchangeRateRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	query := exchangeRateSelect
+	var args []interface{}
+	if currency != "" {
+		query += ` WHERE currency = ?`
+		args = append(args, currency)
+	}
+	query += ` ORDER BY currency ASC, COALESCE(channel, '') ASC, effective_from ASC`
+
+	return s.scanExchangeRates(ctx, query, args...)
+}
+
+// Delete 删除汇率记录
+func (s *SQLiteExchangeRateStore) Delete(ctx context.Context, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	res, err := s.getQuerier().ExecContext(ctx, `DELETE FROM exchange_rates WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("删除汇率失败: %w", err)
+	}
+	affected, _ := res.RowsAffected()
+	if affected == 0 {
+		return fmt.Errorf("汇率记录不存在: %d", id)
+	}
+	return nil
+}
+
+// WithTx 返回使用事务的存储实例
+func (s *SQLiteExchangeRateStore) WithTx(tx *sql.Tx) ExchangeRateStore {
+	return &SQLiteExchangeRateStore{db: s.db, tx: tx}
+}
+
+// exchangeRateSelect 汇率记录查询列（与 scanExchangeRates 的扫描顺序一致）
+const exchangeRateSelect = `SELECT id, COALESCE(channel, ''), currency, rate, effective_from, COALESCE(note, ''), created_at, updated_at FROM exchange_rates`
+
+// scanExchangeRates 执行查询并扫描汇率记录
+func (s *SQLiteExchangeRateStore) scanExchangeRates(ctx context.Context, query string, args ...interface{}) ([]*ExchangeRateRecord, error) {
+	rows, err := s.getQuerier().QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("查询汇率失败: %w", err)
+	}
+	defer rows.Close()
+
+	var records []*ExchangeRateRecord
+	for rows.Next() {
+		var record ExchangeRateRecord
+		var effectiveFrom, createdAt, updatedAt string
+		if err := rows.Scan(&record.ID, &record.Channel, &record.Currency, &record.Rate, &effectiveFrom, &record.Note, &createdAt, &updatedAt); err != nil {
+			return nil, fmt.Errorf("读取汇率失败: %w", err)
+		}
+		record.EffectiveFrom = parseSQLiteDateTime(effectiveFrom)
+		record.CreatedAt = parseSQLiteDateTime(createdAt)
+		record.UpdatedAt = parseSQLiteDateTime(updatedAt)
+		records = append(records, &record)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("读取汇率失败: %w", err)
+	}
+	return records, nil
+}
@@ -0,0 +1,130 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func createExchangeRateTestDB(t *testing.T) (*SQLiteExchangeRateStore, func()) {
+	t.Helper()
+
+	db, cleanup := createTestDB(t)
+	schema := `
+		CREATE TABLE IF NOT EXISTS exchange_rates (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			channel TEXT,
+			currency TEXT NOT NULL,
+			rate REAL NOT NULL,
+			effective_from DATETIME NOT NULL,
+			note TEXT,
+			created_at DATETIME DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now', 'localtime') || '+08:00'),
+			updated_at DATETIME DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now', 'localtime') || '+08:00')
+		);
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_exchange_rates_channel_unique ON exchange_rates(COALESCE(channel, ''), currency, effective_from);
+	`
+	if _, err := db.Exec(schema); err != nil {
+		cleanup()
+		t.Fatalf("创建 exchange_rates 表失败: %v", err)
+	}
+	return NewSQLiteExchangeRateStore(db), cleanup
+}
+
+// TestExchangeRateStore_UpsertListDelete 测试汇率保存（同一生效时间覆盖）、列表排序与删除
+func TestExchangeRateStore_UpsertListDelete(t *testing.T) {
+	s, cleanup := createExchangeRateTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	loc := time.FixedZone("CST", 8*3600)
+	jan := time.Date(2025, 1, 1, 0, 0, 0, 0, loc)
+	feb := time.Date(2025, 2, 1, 0, 0, 0, 0, loc)
+
+	if _, err := s.Upsert(ctx, &ExchangeRateRecord{Currency: "CNY", Rate: 7.2, EffectiveFrom: feb}); err != nil {
+		t.Fatalf("保存汇率失败: %v", err)
+	}
+	created, err := s.Upsert(ctx, &ExchangeRateRecord{Currency: "CNY", Rate: 7.1, EffectiveFrom: jan, Note: "初始"})
+	if err != nil {
+		t.Fatalf("保存汇率失败: %v", err)
+	}
+	if !created.EffectiveFrom.Equal(jan) || created.Note != "初始" {
+		t.Errorf("保存结果不符: %+v", created)
+	}
+
+	// 同一币种同一生效时间再次保存：覆盖汇率
+	updated, err := s.Upsert(ctx, &ExchangeRateRecord{Currency: "CNY", Rate: 7.15, EffectiveFrom: jan})
+	if err != nil {
+		t.Fatalf("覆盖汇率失败: %v", err)
+	}
+	if updated.ID != created.ID || updated.Rate != 7.15 {
+		t.Errorf("覆盖结果 = id %d rate %f, 期望 id %d rate 7.15", updated.ID, updated.Rate, created.ID)
+	}
+
+	if _, err := s.Upsert(ctx, &ExchangeRateRecord{Currency: "EUR", Rate: 0.9, EffectiveFrom: jan}); err != nil {
+		t.Fatalf("保存汇率失败: %v", err)
+	}
+	if _, err := s.Upsert(ctx, &ExchangeRateRecord{Currency: "EUR", Rate: 0, EffectiveFrom: feb}); err == nil {
+		t.Error("汇率为 0 时应返回错误")
+	}
+
+	all, err := s.List(ctx, "")
+	if err != nil {
+		t.Fatalf("列出汇率失败: %v", err)
+	}
+	if len(all) != 3 || all[0].Currency != "CNY" || !all[0].EffectiveFrom.Equal(jan) || all[2].Currency != "EUR" {
+		t.Fatalf("汇率列表顺序不符: %+v", all)
+	}
+
+	cny, _ := s.List(ctx, "CNY")
+	if len(cny) != 2 {
+		t.Fatalf("CNY 汇率条数 = %d, 期望 2", len(cny))
+	}
+
+	if err := s.Delete(ctx, cny[1].ID); err != nil {
+		t.Fatalf("删除汇率失败: %v", err)
+	}
+	if err := s.Delete(ctx, cny[1].ID); err == nil {
+		t.Error("重复删除应返回错误")
+	}
+	if cny, _ = s.List(ctx, "CNY"); len(cny) != 1 {
+		t.Errorf("删除后 CNY 汇率条数 = %d, 期望 1", len(cny))
+	}
+}
+
+// TestExchangeRateStore_ChannelRates 测试渠道专属汇率与全局汇率同一生效时间可并存，且按渠道覆盖
+func TestExchangeRateStore_ChannelRates(t *testing.T) {
+	s, cleanup := createExchangeRateTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	jan := time.Date(2025, 1, 1, 0, 0, 0, 0, time.FixedZone("CST", 8*3600))
+
+	global, err := s.Upsert(ctx, &ExchangeRateRecord{Currency: "CNY", Rate: 7.2, EffectiveFrom: jan})
+	if err != nil {
+		t.Fatalf("保存全局汇率失败: %v", err)
+	}
+	relay, err := s.Upsert(ctx, &ExchangeRateRecord{Channel: "relay", Currency: "CNY", Rate: 7.0, EffectiveFrom: jan})
+	if err != nil {
+		t.Fatalf("保存渠道汇率失败: %v", err)
+	}
+	if relay.ID == global.ID || relay.Channel != "relay" || global.Channel != "" {
+		t.Fatalf("渠道汇率应独立保存: global=%+v relay=%+v", global, relay)
+	}
+
+	// 同一渠道同一生效时间再次保存：只覆盖该渠道的汇率
+	updated, err := s.Upsert(ctx, &ExchangeRateRecord{Channel: "relay", Currency: "CNY", Rate: 6.9, EffectiveFrom: jan})
+	if err != nil {
+		t.Fatalf("覆盖渠道汇率失败: %v", err)
+	}
+	if updated.ID != relay.ID || updated.Rate != 6.9 {
+		t.Errorf("覆盖结果 = id %d rate %f, 期望 id %d rate 6.9", updated.ID, updated.Rate, relay.ID)
+	}
+
+	all, err := s.List(ctx, "CNY")
+	if err != nil {
+		t.Fatalf("列出汇率失败: %v", err)
+	}
+	if len(all) != 2 || all[0].Channel != "" || all[0].Rate != 7.2 || all[1].Channel != "relay" {
+		t.Fatalf("汇率列表不符（全局在前）: %+v %+v", all[0], all[len(all)-1])
+	}
+}
@@ -0,0 +1,309 @@
+// Message Batches 存储
+// 记录经代理创建的批处理任务及其所属端点，用于固定后续请求的转发目标并防止重复计费
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// 批处理状态（与上游 processing_status 一致）
+const (
+	MessageBatchStatusInProgress = "in_progress"
+	MessageBatchStatusCanceling  = "canceling"
+	MessageBatchStatusEnded      = "ended"
+)
+
+// MessageBatchRecord 表示数据库中的批处理记录
+type MessageBatchRecord struct {
+	ID      int64  `json:"id"`
+	BatchID string `json:"batch_id"`
+
+	// 端点标识
+	Channel      string `json:"channel"`
+	EndpointName string `json:"endpoint_name"`
+
+	// 批处理状态
+	ProcessingStatus string     `json:"processing_status"`
+	ProcessingCount  int64      `json:"processing_count"`
+	SucceededCount   int64      `json:"succeeded_count"`
+	ErroredCount     int64      `json:"errored_count"`
+	CanceledCount    int64      `json:"canceled_count"`
+	ExpiredCount     int64      `json:"expired_count"`
+	ExpiresAt        *time.Time `json:"expires_at,omitempty"`
+	EndedAt          *time.Time `json:"ended_at,omitempty"`
+
+	// 创建请求的标签（项目/成本中心归属），结果用量写入时沿用
+	Tags []MessageBatchTag `json:"tags,omitempty"`
+
+	// 用量记录
+	UsageRecorded   bool       `json:"usage_recorded"`
+	UsageRecordedAt *time.Time `json:"usage_recorded_at,omitempty"`
+
+	// 审计字段
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// MessageBatchTag 批处理创建请求携带的标签
+type MessageBatchTag struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// MessageBatchStore 定义批处理存储接口
+type MessageBatchStore interface {
+	// 创建或更新（批处理 ID 唯一，重复创建时仅刷新状态）
+	Upsert(ctx context.Context, record *MessageBatchRecord) error
+	Get(ctx context.Context, batchID string) (*MessageBatchRecord, error)
+	List(ctx context.Context, limit int) ([]*MessageBatchRecord, error)
+
+	// 状态同步（retrieve/cancel 响应）
+	UpdateStatus(ctx context.Context, record *MessageBatchRecord) error
+
+	// 用量记录：仅在尚未记录时标记成功，返回是否由本次调用完成标记
+	MarkUsageRecorded(ctx context.Context, batchID string) (bool, error)
+
+	// 事务支持
+	WithTx(tx *sql.Tx) MessageBatchStore
+}
+
+// SQLiteMessageBatchStore 实现 MessageBatchStore 接口
+type SQLiteMessageBatchStore struct {
+	db *sql.DB
+	tx *sql.Tx // 事务上下文（可选）
+	mu sync.RWMutex
+}
+
+// NewSQLiteMessageBatchStore 创建新的 SQLite 批处理存储
+func NewSQLiteMessageBatchStore(db *sql.DB) *SQLiteMessageBatchStore {
+	return &SQLiteMessageBatchStore{db: db}
+}
+
+// getQuerier 返回用于执行查询的对象（事务或数据库连接）
+func (s *SQLiteMessageBatchStore) getQuerier() interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+} {
+	if s.tx != nil {
+		return s.tx
+	}
+	return s.db
+}
+
+const messageBatchColumns = `
+	id, batch_id, channel, endpoint_name, processing_status,
+	processing_count, succeeded_count, errored_count, canceled_count, expired_count,
+	expires_at, ended_at, tags, usage_recorded, usage_recorded_at, created_at, updated_at
+`
+
+// Upsert 创建批处理记录，已存在时刷新端点与状态（用量标记保持不变，未提供标签时保留原标签）
+func (s *SQLiteMessageBatchStore) Upsert(ctx context.Context, record *MessageBatchRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status := record.ProcessingStatus
+	if status == "" {
+		status = MessageBatchStatusInProgress
+	}
+
+	query := `
+		INSERT INTO message_batches (
+			batch_id, channel, endpoint_name, processing_status,
+			processing_count, succeeded_count, errored_count, canceled_count, expired_count,
+			expires_at, ended_at, tags
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(batch_id) DO UPDATE SET
+			channel = excluded.channel,
+			endpoint_name = excluded.endpoint_name,
+			processing_status = excluded.processing_status,
+			processing_count = excluded.processing_count,
+			succeeded_count = excluded.succeeded_count,
+			errored_count = excluded.errored_count,
+			canceled_count = excluded.canceled_count,
+			expired_count = excluded.expired_count,
+			expires_at = excluded.expires_at,
+			ended_at = excluded.ended_at,
+			tags = COALESCE(excluded.tags, tags)
+	`
+
+	if _, err := s.getQuerier().ExecContext(ctx, query,
+		record.BatchID, record.Channel, record.EndpointName, status,
+		record.ProcessingCount, record.SucceededCount, record.ErroredCount, record.CanceledCount, record.ExpiredCount,
+		nullableSQLiteTime(record.ExpiresAt), nullableSQLiteTime(record.EndedAt), marshalMessageBatchTags(record.Tags),
+	); err != nil {
+		return fmt.Errorf("保存批处理记录失败: %w", err)
+	}
+	return nil
+}
+
+// Get 获取批处理记录，不存在时返回 nil
+func (s *SQLiteMessageBatchStore) Get(ctx context.Context, batchID string) (*MessageBatchRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	query := `SELECT ` + messageBatchColumns + ` FROM message_batches WHERE batch_id = ?`
+	records, err := s.scanMessageBatches(ctx, query, batchID)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+	return records[0], nil
+}
+
+// List 按创建时间倒序获取批处理记录（limit <= 0 表示不限制）
+func (s *SQLiteMessageBatchStore) List(ctx context.Context, limit int) ([]*MessageBatchRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	query := `SELECT ` + messageBatchColumns + ` FROM message_batches ORDER BY created_at DESC, id DESC`
+	if limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", limit)
+	}
+	return s.scanMessageBatches(ctx, query)
+}
+
+// UpdateStatus 同步批处理状态与请求计数
+func (s *SQLiteMessageBatchStore) UpdateStatus(ctx context.Context, record *MessageBatchRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	query := `
+		UPDATE message_batches SET
+			processing_status = ?,
+			processing_count = ?, succeeded_count = ?, errored_count = ?, canceled_count = ?, expired_count = ?,
+			expires_at = COALESCE(?, expires_at),
+			ended_at = COALESCE(?, ended_at)
+		WHERE batch_id = ?
+	`
+
+	if _, err := s.getQuerier().ExecContext(ctx, query,
+		record.ProcessingStatus,
+		record.ProcessingCount, record.SucceededCount, record.ErroredCount, record.CanceledCount, record.ExpiredCount,
+		nullableSQLiteTime(record.ExpiresAt), nullableSQLiteTime(record.EndedAt),
+		record.BatchID,
+	); err != nil {
+		return fmt.Errorf("更新批处理状态失败: %w", err)
+	}
+	return nil
+}
+
+// MarkUsageRecorded 标记批处理用量已记录（条件更新，保证同一批处理只计费一次）
+func (s *SQLiteMessageBatchStore) MarkUsageRecorded(ctx context.Context, batchID string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result, err := s.getQuerier().ExecContext(ctx,
+		`UPDATE message_batches SET usage_recorded = 1, usage_recorded_at = ? WHERE batch_id = ? AND usage_recorded = 0`,
+		formatSQLiteDateTime(time.Now()), batchID,
+	)
+	if err != nil {
+		return false, fmt.Errorf("标记批处理用量失败: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("获取影响行数失败: %w", err)
+	}
+	return rows > 0, nil
+}
+
+// WithTx 返回使用事务的存储实例
+func (s *SQLiteMessageBatchStore) WithTx(tx *sql.Tx) MessageBatchStore {
+	return &SQLiteMessageBatchStore{
+		db: s.db,
+		tx: tx,
+	}
+}
+
+// scanMessageBatches 扫描多行批处理记录
+func (s *SQLiteMessageBatchStore) scanMessageBatches(ctx context.Context, query string, args ...interface{}) ([]*MessageBatchRecord, error) {
+	rows, err := queryRowsWithSQLiteBusyRetry(ctx, func() (*sql.Rows, error) {
+		return s.getQuerier().QueryContext(ctx, query, args...)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("查询批处理记录失败: %w", err)
+	}
+	defer rows.Close()
+
+	var records []*MessageBatchRecord
+	for rows.Next() {
+		record := &MessageBatchRecord{}
+		var expiresAt, endedAt, tags, usageRecordedAt sql.NullString
+		var usageRecorded int
+		var createdAt, updatedAt string
+
+		if err := rows.Scan(
+			&record.ID, &record.BatchID, &record.Channel, &record.EndpointName, &record.ProcessingStatus,
+			&record.ProcessingCount, &record.SucceededCount, &record.ErroredCount, &record.CanceledCount, &record.ExpiredCount,
+			&expiresAt, &endedAt, &tags, &usageRecorded, &usageRecordedAt, &createdAt, &updatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("扫描批处理记录失败: %w", err)
+		}
+
+		record.ExpiresAt = parseNullableSQLiteTime(expiresAt)
+		record.EndedAt = parseNullableSQLiteTime(endedAt)
+		record.Tags = unmarshalMessageBatchTags(tags.String)
+		record.UsageRecorded = usageRecorded != 0
+		record.UsageRecordedAt = parseNullableSQLiteTime(usageRecordedAt)
+		record.CreatedAt = parseSQLiteDateTime(createdAt)
+		record.UpdatedAt = parseSQLiteDateTime(updatedAt)
+		records = append(records, record)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历批处理记录失败: %w", err)
+	}
+	return records, nil
+}
+
+// nullableSQLiteTime 将可选时间转换为数据库参数（nil 写入 NULL）
+func nullableSQLiteTime(t *time.Time) interface{} {
+	if t == nil || t.IsZero() {
+		return nil
+	}
+	return formatSQLiteDateTime(*t)
+}
+
+// parseNullableSQLiteTime 解析可为空的时间列
+func parseNullableSQLiteTime(value sql.NullString) *time.Time {
+	if !value.Valid || value.String == "" {
+		return nil
+	}
+	t := parseSQLiteDateTime(value.String)
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// marshalMessageBatchTags 序列化批处理标签（无标签存为 NULL）
+func marshalMessageBatchTags(tags []MessageBatchTag) interface{} {
+	if len(tags) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(tags)
+	if err != nil {
+		return nil
+	}
+	return string(data)
+}
+
+// unmarshalMessageBatchTags 解析批处理标签，解析失败时视为无标签
+func unmarshalMessageBatchTags(data string) []MessageBatchTag {
+	if data == "" || data == "null" {
+		return nil
+	}
+	var tags []MessageBatchTag
+	if err := json.Unmarshal([]byte(data), &tags); err != nil {
+		return nil
+	}
+	return tags
+}
@@ -0,0 +1,131 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func createMessageBatchTestDB(t *testing.T) (*SQLiteMessageBatchStore, func()) {
+	t.Helper()
+
+	db, cleanup := createTestDB(t)
+	schema := `
+		CREATE TABLE IF NOT EXISTS message_batches (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			batch_id TEXT UNIQUE NOT NULL,
+			channel TEXT NOT NULL DEFAULT '',
+			endpoint_name TEXT NOT NULL,
+			processing_status TEXT NOT NULL DEFAULT 'in_progress',
+			processing_count INTEGER DEFAULT 0,
+			succeeded_count INTEGER DEFAULT 0,
+			errored_count INTEGER DEFAULT 0,
+			canceled_count INTEGER DEFAULT 0,
+			expired_count INTEGER DEFAULT 0,
+			expires_at DATETIME,
+			ended_at DATETIME,
+			tags TEXT,
+			usage_recorded INTEGER NOT NULL DEFAULT 0,
+			usage_recorded_at DATETIME,
+			created_at DATETIME DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now', 'localtime') || '+08:00'),
+			updated_at DATETIME DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now', 'localtime') || '+08:00')
+		);
+	`
+	if _, err := db.Exec(schema); err != nil {
+		cleanup()
+		t.Fatalf("创建 message_batches 表失败: %v", err)
+	}
+	return NewSQLiteMessageBatchStore(db), cleanup
+}
+
+// TestMessageBatchStore_UpsertAndUpdate 测试创建、重复创建与状态同步
+func TestMessageBatchStore_UpsertAndUpdate(t *testing.T) {
+	s, cleanup := createMessageBatchTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	expiresAt := time.Date(2025, 10, 2, 12, 0, 0, 0, time.FixedZone("CST", 8*3600))
+	if err := s.Upsert(ctx, &MessageBatchRecord{
+		BatchID:         "msgbatch_01",
+		Channel:         "A",
+		EndpointName:    "ep1",
+		ProcessingCount: 3,
+		ExpiresAt:       &expiresAt,
+		Tags:            []MessageBatchTag{{Key: "project", Value: "alpha"}},
+	}); err != nil {
+		t.Fatalf("创建批处理记录失败: %v", err)
+	}
+
+	record, err := s.Get(ctx, "msgbatch_01")
+	if err != nil || record == nil {
+		t.Fatalf("获取批处理记录失败: %v", err)
+	}
+	if record.Channel != "A" || record.EndpointName != "ep1" {
+		t.Errorf("端点 = %s/%s, 期望 A/ep1", record.Channel, record.EndpointName)
+	}
+	if record.ProcessingStatus != MessageBatchStatusInProgress {
+		t.Errorf("状态 = %s, 期望 %s", record.ProcessingStatus, MessageBatchStatusInProgress)
+	}
+	if record.ExpiresAt == nil || !record.ExpiresAt.Equal(expiresAt) {
+		t.Errorf("过期时间 = %v, 期望 %v", record.ExpiresAt, expiresAt)
+	}
+
+	// 状态同步：未提供的时间保持原值
+	if err := s.UpdateStatus(ctx, &MessageBatchRecord{
+		BatchID:          "msgbatch_01",
+		ProcessingStatus: MessageBatchStatusEnded,
+		SucceededCount:   2,
+		ErroredCount:     1,
+	}); err != nil {
+		t.Fatalf("更新批处理状态失败: %v", err)
+	}
+	record, _ = s.Get(ctx, "msgbatch_01")
+	if record.ProcessingStatus != MessageBatchStatusEnded || record.SucceededCount != 2 || record.ErroredCount != 1 || record.ProcessingCount != 0 {
+		t.Errorf("状态同步结果不符: %+v", record)
+	}
+	if record.ExpiresAt == nil {
+		t.Error("未提供过期时间时应保留原值")
+	}
+
+	// 补录（不带标签）不应清除创建时的标签
+	if err := s.Upsert(ctx, &MessageBatchRecord{BatchID: "msgbatch_01", Channel: "A", EndpointName: "ep1", ProcessingStatus: MessageBatchStatusEnded}); err != nil {
+		t.Fatalf("补录批处理记录失败: %v", err)
+	}
+	record, _ = s.Get(ctx, "msgbatch_01")
+	if len(record.Tags) != 1 || record.Tags[0] != (MessageBatchTag{Key: "project", Value: "alpha"}) {
+		t.Errorf("标签 = %+v, 期望 [project=alpha]", record.Tags)
+	}
+
+	if missing, err := s.Get(ctx, "msgbatch_missing"); err != nil || missing != nil {
+		t.Errorf("不存在的批处理应返回 nil, 实际 %+v, err=%v", missing, err)
+	}
+}
+
+// TestMessageBatchStore_MarkUsageRecorded 测试用量只标记一次
+func TestMessageBatchStore_MarkUsageRecorded(t *testing.T) {
+	s, cleanup := createMessageBatchTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	if err := s.Upsert(ctx, &MessageBatchRecord{BatchID: "msgbatch_02", EndpointName: "ep1"}); err != nil {
+		t.Fatalf("创建批处理记录失败: %v", err)
+	}
+
+	marked, err := s.MarkUsageRecorded(ctx, "msgbatch_02")
+	if err != nil || !marked {
+		t.Fatalf("首次标记应成功, marked=%v err=%v", marked, err)
+	}
+	marked, err = s.MarkUsageRecorded(ctx, "msgbatch_02")
+	if err != nil || marked {
+		t.Errorf("重复标记应返回 false, marked=%v err=%v", marked, err)
+	}
+
+	// 重复创建不应重置用量标记
+	if err := s.Upsert(ctx, &MessageBatchRecord{BatchID: "msgbatch_02", EndpointName: "ep1"}); err != nil {
+		t.Fatalf("重复创建失败: %v", err)
+	}
+	record, _ := s.Get(ctx, "msgbatch_02")
+	if !record.UsageRecorded || record.UsageRecordedAt == nil {
+		t.Errorf("用量标记应保持, 实际 %+v", record)
+	}
+}
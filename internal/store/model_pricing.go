@@ -5,6 +5,7 @@ package store
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"sync"
 	"time"
@@ -24,6 +25,13 @@ type ModelPricingRecord struct {
 	CacheCreationPrice1h  float64 `json:"cache_creation_price_1h"`  // 1小时缓存创建价格 (input * 2.0)
 	CacheReadPrice        float64 `json:"cache_read_price"`         // 缓存读取价格 (input * 0.1)
 
+	// 服务端工具定价 (USD per 1K requests)
+	WebSearchPrice float64 `json:"web_search_price"` // web search 每千次价格
+	WebFetchPrice  float64 `json:"web_fetch_price"`  // web fetch 每千次价格
+
+	// 长上下文分档定价（提示总 token 超过阈值时适用）
+	Tiers []PricingTierRecord `json:"tiers,omitempty"`
+
 	// 模型元信息
 	DisplayName string `json:"display_name,omitempty"` // 显示名称
 	Description string `json:"description,omitempty"`  // 模型描述
@@ -34,6 +42,16 @@ type ModelPricingRecord struct {
 	UpdatedAt time.Time `json:"updated_at"`
 }
 
+// PricingTierRecord 长上下文定价档位 (USD per 1M tokens，0 表示沿用基础价格)
+type PricingTierRecord struct {
+	Threshold            int64   `json:"threshold"` // 提示总 token 阈值（超过即适用）
+	InputPrice           float64 `json:"input_price,omitempty"`
+	OutputPrice          float64 `json:"output_price,omitempty"`
+	CacheCreationPrice5m float64 `json:"cache_creation_price_5m,omitempty"`
+	CacheCreationPrice1h float64 `json:"cache_creation_price_1h,omitempty"`
+	CacheReadPrice       float64 `json:"cache_read_price,omitempty"`
+}
+
 // ModelPricingStore 定义模型定价存储接口
 type ModelPricingStore interface {
 	// CRUD 操作
@@ -109,13 +127,15 @@ func (s *SQLiteModelPricingStore) Create(ctx context.Context, record *ModelPrici
 		INSERT INTO model_pricing (
 			model_name, input_price, output_price,
 			cache_creation_price_5m, cache_creation_price_1h, cache_read_price,
+			web_search_price, web_fetch_price, pricing_tiers,
 			display_name, description, is_default
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 
 	result, err := s.getQuerier().ExecContext(ctx, query,
 		record.ModelName, record.InputPrice, record.OutputPrice,
 		record.CacheCreationPrice5m, record.CacheCreationPrice1h, record.CacheReadPrice,
+		record.WebSearchPrice, record.WebFetchPrice, marshalPricingTiers(record.Tiers),
 		record.DisplayName, record.Description, boolToInt(record.IsDefault),
 	)
 	if err != nil {
@@ -142,6 +162,7 @@ func (s *SQLiteModelPricingStore) Get(ctx context.Context, modelName string) (*M
 	query := `
 		SELECT id, model_name, input_price, output_price,
 			cache_creation_price_5m, cache_creation_price_1h, cache_read_price,
+			COALESCE(web_search_price, 0), COALESCE(web_fetch_price, 0), pricing_tiers,
 			display_name, description, is_default,
 			created_at, updated_at
 		FROM model_pricing WHERE model_name = ?
@@ -158,6 +179,7 @@ func (s *SQLiteModelPricingStore) GetByID(ctx context.Context, id int64) (*Model
 	query := `
 		SELECT id, model_name, input_price, output_price,
 			cache_creation_price_5m, cache_creation_price_1h, cache_read_price,
+			COALESCE(web_search_price, 0), COALESCE(web_fetch_price, 0), pricing_tiers,
 			display_name, description, is_default,
 			created_at, updated_at
 		FROM model_pricing WHERE id = ?
@@ -174,6 +196,7 @@ func (s *SQLiteModelPricingStore) List(ctx context.Context) ([]*ModelPricingReco
 	query := `
 		SELECT id, model_name, input_price, output_price,
 			cache_creation_price_5m, cache_creation_price_1h, cache_read_price,
+			COALESCE(web_search_price, 0), COALESCE(web_fetch_price, 0), pricing_tiers,
 			display_name, description, is_default,
 			created_at, updated_at
 		FROM model_pricing
@@ -192,6 +215,7 @@ func (s *SQLiteModelPricingStore) Update(ctx context.Context, record *ModelPrici
 		UPDATE model_pricing SET
 			input_price = ?, output_price = ?,
 			cache_creation_price_5m = ?, cache_creation_price_1h = ?, cache_read_price = ?,
+			web_search_price = ?, web_fetch_price = ?, pricing_tiers = ?,
 			display_name = ?, description = ?, is_default = ?
 		WHERE model_name = ?
 	`
@@ -199,6 +223,7 @@ func (s *SQLiteModelPricingStore) Update(ctx context.Context, record *ModelPrici
 	result, err := s.getQuerier().ExecContext(ctx, query,
 		record.InputPrice, record.OutputPrice,
 		record.CacheCreationPrice5m, record.CacheCreationPrice1h, record.CacheReadPrice,
+		record.WebSearchPrice, record.WebFetchPrice, marshalPricingTiers(record.Tiers),
 		record.DisplayName, record.Description, boolToInt(record.IsDefault),
 		record.ModelName,
 	)
@@ -262,8 +287,9 @@ func (s *SQLiteModelPricingStore) BatchCreate(ctx context.Context, records []*Mo
 		INSERT INTO model_pricing (
 			model_name, input_price, output_price,
 			cache_creation_price_5m, cache_creation_price_1h, cache_read_price,
+			web_search_price, web_fetch_price, pricing_tiers,
 			display_name, description, is_default
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 
 	stmt, err := tx.PrepareContext(ctx, query)
@@ -293,6 +319,7 @@ func (s *SQLiteModelPricingStore) BatchCreate(ctx context.Context, records []*Mo
 		_, err = stmt.ExecContext(ctx,
 			record.ModelName, record.InputPrice, record.OutputPrice,
 			record.CacheCreationPrice5m, record.CacheCreationPrice1h, record.CacheReadPrice,
+			record.WebSearchPrice, record.WebFetchPrice, marshalPricingTiers(record.Tiers),
 			record.DisplayName, record.Description, boolToInt(record.IsDefault),
 		)
 		if err != nil {
@@ -327,14 +354,18 @@ func (s *SQLiteModelPricingStore) BatchUpsert(ctx context.Context, records []*Mo
 		INSERT INTO model_pricing (
 			model_name, input_price, output_price,
 			cache_creation_price_5m, cache_creation_price_1h, cache_read_price,
+			web_search_price, web_fetch_price, pricing_tiers,
 			display_name, description, is_default
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 		ON CONFLICT(model_name) DO UPDATE SET
 			input_price = excluded.input_price,
 			output_price = excluded.output_price,
 			cache_creation_price_5m = excluded.cache_creation_price_5m,
 			cache_creation_price_1h = excluded.cache_creation_price_1h,
 			cache_read_price = excluded.cache_read_price,
+			web_search_price = excluded.web_search_price,
+			web_fetch_price = excluded.web_fetch_price,
+			pricing_tiers = excluded.pricing_tiers,
 			display_name = excluded.display_name,
 			description = excluded.description,
 			is_default = excluded.is_default
@@ -367,6 +398,7 @@ func (s *SQLiteModelPricingStore) BatchUpsert(ctx context.Context, records []*Mo
 		_, err = stmt.ExecContext(ctx,
 			record.ModelName, record.InputPrice, record.OutputPrice,
 			record.CacheCreationPrice5m, record.CacheCreationPrice1h, record.CacheReadPrice,
+			record.WebSearchPrice, record.WebFetchPrice, marshalPricingTiers(record.Tiers),
 			record.DisplayName, record.Description, boolToInt(record.IsDefault),
 		)
 		if err != nil {
@@ -389,6 +421,7 @@ func (s *SQLiteModelPricingStore) GetDefault(ctx context.Context) (*ModelPricing
 	query := `
 		SELECT id, model_name, input_price, output_price,
 			cache_creation_price_5m, cache_creation_price_1h, cache_read_price,
+			COALESCE(web_search_price, 0), COALESCE(web_fetch_price, 0), pricing_tiers,
 			display_name, description, is_default,
 			created_at, updated_at
 		FROM model_pricing WHERE is_default = 1 LIMIT 1
@@ -462,7 +495,7 @@ func (s *SQLiteModelPricingStore) WithTx(tx *sql.Tx) ModelPricingStore {
 // scanModelPricing 从单行扫描模型定价记录
 func (s *SQLiteModelPricingStore) scanModelPricing(row *sql.Row) (*ModelPricingRecord, error) {
 	var record ModelPricingRecord
-	var displayName, description sql.NullString
+	var displayName, description, tiersJSON sql.NullString
 	var isDefault int
 	var createdAt, updatedAt string
 
@@ -470,6 +503,7 @@ func (s *SQLiteModelPricingStore) scanModelPricing(row *sql.Row) (*ModelPricingR
 		&record.ID, &record.ModelName,
 		&record.InputPrice, &record.OutputPrice,
 		&record.CacheCreationPrice5m, &record.CacheCreationPrice1h, &record.CacheReadPrice,
+		&record.WebSearchPrice, &record.WebFetchPrice, &tiersJSON,
 		&displayName, &description, &isDefault,
 		&createdAt, &updatedAt,
 	)
@@ -490,6 +524,7 @@ func (s *SQLiteModelPricingStore) scanModelPricing(row *sql.Row) (*ModelPricingR
 
 	// 转换布尔值
 	record.IsDefault = isDefault == 1
+	record.Tiers = unmarshalPricingTiers(tiersJSON.String)
 
 	// 解析时间
 	record.CreatedAt = parseSQLiteDateTime(createdAt)
@@ -519,7 +554,7 @@ func (s *SQLiteModelPricingStore) scanModelPricings(ctx context.Context, query s
 	var records []*ModelPricingRecord
 	for rows.Next() {
 		var record ModelPricingRecord
-		var displayName, description sql.NullString
+		var displayName, description, tiersJSON sql.NullString
 		var isDefault int
 		var createdAt, updatedAt string
 
@@ -527,6 +562,7 @@ func (s *SQLiteModelPricingStore) scanModelPricings(ctx context.Context, query s
 			&record.ID, &record.ModelName,
 			&record.InputPrice, &record.OutputPrice,
 			&record.CacheCreationPrice5m, &record.CacheCreationPrice1h, &record.CacheReadPrice,
+			&record.WebSearchPrice, &record.WebFetchPrice, &tiersJSON,
 			&displayName, &description, &isDefault,
 			&createdAt, &updatedAt,
 		)
@@ -544,6 +580,7 @@ func (s *SQLiteModelPricingStore) scanModelPricings(ctx context.Context, query s
 
 		// 转换布尔值
 		record.IsDefault = isDefault == 1
+		record.Tiers = unmarshalPricingTiers(tiersJSON.String)
 
 		// 解析时间
 		record.CreatedAt = parseSQLiteDateTime(createdAt)
@@ -558,3 +595,27 @@ func (s *SQLiteModelPricingStore) scanModelPricings(ctx context.Context, query s
 
 	return records, nil
 }
+
+// marshalPricingTiers 序列化长上下文档位，未配置时存储 NULL
+func marshalPricingTiers(tiers []PricingTierRecord) interface{} {
+	if len(tiers) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(tiers)
+	if err != nil {
+		return nil
+	}
+	return string(data)
+}
+
+// unmarshalPricingTiers 解析长上下文档位，解析失败时视为未配置
+func unmarshalPricingTiers(data string) []PricingTierRecord {
+	if data == "" || data == "null" {
+		return nil
+	}
+	var tiers []PricingTierRecord
+	if err := json.Unmarshal([]byte(data), &tiers); err != nil {
+		return nil
+	}
+	return tiers
+}
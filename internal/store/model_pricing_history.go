@@ -0,0 +1,196 @@
+// 模型定价历史版本存储
+// 每次创建/修改模型定价时记录一个带生效时间的版本，成本按请求开始时生效的版本计算
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ModelPricingVersionRecord 表示数据库中的模型定价版本
+type ModelPricingVersionRecord struct {
+	ID        int64  `json:"id"`
+	ModelName string `json:"model_name"`
+
+	// 定价信息 (USD per 1M tokens)
+	InputPrice           float64 `json:"input_price"`
+	OutputPrice          float64 `json:"output_price"`
+	CacheCreationPrice5m float64 `json:"cache_creation_price_5m"`
+	CacheCreationPrice1h float64 `json:"cache_creation_price_1h"`
+	CacheReadPrice       float64 `json:"cache_read_price"`
+
+	// 服务端工具定价 (USD per 1K requests)
+	WebSearchPrice float64 `json:"web_search_price"`
+	WebFetchPrice  float64 `json:"web_fetch_price"`
+
+	Tiers []PricingTierRecord `json:"tiers,omitempty"`
+
+	EffectiveFrom time.Time `json:"effective_from"` // 生效时间
+	Note          string    `json:"note,omitempty"` // 变更说明
+
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ModelPricingHistoryStore 定义模型定价历史存储接口
+type ModelPricingHistoryStore interface {
+	// 创建或更新（同一模型同一生效时间唯一，重复保存时覆盖价格）
+	Upsert(ctx context.Context, record *ModelPricingVersionRecord) (*ModelPricingVersionRecord, error)
+	// 列出定价版本（modelName 为空时返回全部），按模型、生效时间升序
+	List(ctx context.Context, modelName string) ([]*ModelPricingVersionRecord, error)
+	Delete(ctx context.Context, id int64) error
+
+	// 事务支持
+	WithTx(tx *sql.Tx) ModelPricingHistoryStore
+}
+
+// SQLiteModelPricingHistoryStore 实现 ModelPricingHistoryStore 接口
+type SQLiteModelPricingHistoryStore struct {
+	db *sql.DB
+	tx *sql.Tx // 事务上下文（可选）
+	mu sync.RWMutex
+}
+
+// NewSQLiteModelPricingHistoryStore 创建新的 SQLite 模型定价历史存储
+func NewSQLiteModelPricingHistoryStore(db *sql.DB) *SQLiteModelPricingHistoryStore {
+	return &SQLiteModelPricingHistoryStore{db: db}
+}
+
+// getQuerier 返回用于执行查询的对象（事务或数据库连接）
+func (s *SQLiteModelPricingHistoryStore) getQuerier() interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+} {
+	if s.tx != nil {
+		return s.tx
+	}
+	return s.db
+}
+
+const modelPricingVersionColumns = `id, model_name, input_price, output_price,
+	COALESCE(cache_creation_price_5m, 0), COALESCE(cache_creation_price_1h, 0), COALESCE(cache_read_price, 0),
+	COALESCE(web_search_price, 0), COALESCE(web_fetch_price, 0), pricing_tiers,
+	effective_from, COALESCE(note, ''), created_at`
+
+// Upsert 保存定价版本，返回保存后的记录
+func (s *SQLiteModelPricingHistoryStore) Upsert(ctx context.Context, record *ModelPricingVersionRecord) (*ModelPricingVersionRecord, error) {
+	if record == nil {
+		return nil, fmt.Errorf("record 不能为空")
+	}
+	if record.ModelName == "" {
+		return nil, fmt.Errorf("模型名称不能为空")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	effectiveFrom := formatSQLiteDateTime(record.EffectiveFrom)
+	query := `
+		INSERT INTO model_pricing_history (
+			model_name, input_price, output_price,
+			cache_creation_price_5m, cache_creation_price_1h, cache_read_price,
+			web_search_price, web_fetch_price, pricing_tiers,
+			effective_from, note
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(model_name, effective_from) DO UPDATE SET
+			input_price = excluded.input_price,
+			output_price = excluded.output_price,
+			cache_creation_price_5m = excluded.cache_creation_price_5m,
+			cache_creation_price_1h = excluded.cache_creation_price_1h,
+			cache_read_price = excluded.cache_read_price,
+			web_search_price = excluded.web_search_price,
+			web_fetch_price = excluded.web_fetch_price,
+			pricing_tiers = excluded.pricing_tiers,
+			note = excluded.note
+	`
+	if _, err := s.getQuerier().ExecContext(ctx, query,
+		record.ModelName, record.InputPrice, record.OutputPrice,
+		record.CacheCreationPrice5m, record.CacheCreationPrice1h, record.CacheReadPrice,
+		record.WebSearchPrice, record.WebFetchPrice, marshalPricingTiers(record.Tiers),
+		effectiveFrom, nullIfEmpty(record.Note),
+	); err != nil {
+		return nil, fmt.Errorf("保存定价版本失败: %w", err)
+	}
+
+	records, err := s.scanVersions(ctx, `SELECT `+modelPricingVersionColumns+`
+		FROM model_pricing_history WHERE model_name = ? AND effective_from = ?`, record.ModelName, effectiveFrom)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("保存定价版本失败: 记录不存在")
+	}
+	return records[0], nil
+}
+
+// List 列出定价版本
+func (s *SQLiteModelPricingHistoryStore) List(ctx context.Context, modelName string) ([]*ModelPricingVersionRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	query := `SELECT ` + modelPricingVersionColumns + ` FROM model_pricing_history`
+	var args []interface{}
+	if modelName != "" {
+		query += ` WHERE model_name = ?`
+		args = append(args, modelName)
+	}
+	query += ` ORDER BY model_name ASC, effective_from ASC`
+
+	return s.scanVersions(ctx, query, args...)
+}
+
+// Delete 删除定价版本
+func (s *SQLiteModelPricingHistoryStore) Delete(ctx context.Context, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	res, err := s.getQuerier().ExecContext(ctx, `DELETE FROM model_pricing_history WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("删除定价版本失败: %w", err)
+	}
+	affected, _ := res.RowsAffected()
+	if affected == 0 {
+		return fmt.Errorf("定价版本不存在: %d", id)
+	}
+	return nil
+}
+
+// WithTx 返回使用事务的存储实例
+func (s *SQLiteModelPricingHistoryStore) WithTx(tx *sql.Tx) ModelPricingHistoryStore {
+	return &SQLiteModelPricingHistoryStore{db: s.db, tx: tx}
+}
+
+// scanVersions 执行查询并扫描定价版本
+func (s *SQLiteModelPricingHistoryStore) scanVersions(ctx context.Context, query string, args ...interface{}) ([]*ModelPricingVersionRecord, error) {
+	rows, err := s.getQuerier().QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("查询定价版本失败: %w", err)
+	}
+	defer rows.Close()
+
+	var records []*ModelPricingVersionRecord
+	for rows.Next() {
+		var record ModelPricingVersionRecord
+		var tiersJSON sql.NullString
+		var effectiveFrom, createdAt string
+		if err := rows.Scan(
+			&record.ID, &record.ModelName, &record.InputPrice, &record.OutputPrice,
+			&record.CacheCreationPrice5m, &record.CacheCreationPrice1h, &record.CacheReadPrice,
+			&record.WebSearchPrice, &record.WebFetchPrice, &tiersJSON,
+			&effectiveFrom, &record.Note, &createdAt,
+		); err != nil {
+			return nil, fmt.Errorf("读取定价版本失败: %w", err)
+		}
+		record.Tiers = unmarshalPricingTiers(tiersJSON.String)
+		record.EffectiveFrom = parseSQLiteDateTime(effectiveFrom)
+		record.CreatedAt = parseSQLiteDateTime(createdAt)
+		records = append(records, &record)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("读取定价版本失败: %w", err)
+	}
+	return records, nil
+}
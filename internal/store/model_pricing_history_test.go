@@ -0,0 +1,98 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func createModelPricingHistoryTestDB(t *testing.T) (*SQLiteModelPricingHistoryStore, func()) {
+	t.Helper()
+
+	db, cleanup := createTestDB(t)
+	schema := `
+		CREATE TABLE IF NOT EXISTS model_pricing_history (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			model_name TEXT NOT NULL,
+			input_price REAL NOT NULL DEFAULT 0,
+			output_price REAL NOT NULL DEFAULT 0,
+			cache_creation_price_5m REAL DEFAULT 0,
+			cache_creation_price_1h REAL DEFAULT 0,
+			cache_read_price REAL DEFAULT 0,
+			web_search_price REAL DEFAULT 0,
+			web_fetch_price REAL DEFAULT 0,
+			pricing_tiers TEXT,
+			effective_from DATETIME NOT NULL,
+			note TEXT,
+			created_at DATETIME DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now', 'localtime') || '+08:00'),
+			UNIQUE(model_name, effective_from)
+		);
+	`
+	if _, err := db.Exec(schema); err != nil {
+		cleanup()
+		t.Fatalf("创建 model_pricing_history 表失败: %v", err)
+	}
+	return NewSQLiteModelPricingHistoryStore(db), cleanup
+}
+
+// TestModelPricingHistoryStore_UpsertListDelete 测试定价版本保存（同一生效时间覆盖）、列表排序与删除
+func TestModelPricingHistoryStore_UpsertListDelete(t *testing.T) {
+	s, cleanup := createModelPricingHistoryTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	loc := time.FixedZone("CST", 8*3600)
+	jan := time.Date(2025, 1, 1, 0, 0, 0, 0, loc)
+	feb := time.Date(2025, 2, 1, 0, 0, 0, 0, loc)
+
+	if _, err := s.Upsert(ctx, &ModelPricingVersionRecord{ModelName: "claude-sonnet-4", InputPrice: 6, OutputPrice: 30, EffectiveFrom: feb}); err != nil {
+		t.Fatalf("保存定价版本失败: %v", err)
+	}
+	created, err := s.Upsert(ctx, &ModelPricingVersionRecord{
+		ModelName:     "claude-sonnet-4",
+		InputPrice:    3,
+		OutputPrice:   15,
+		Tiers:         []PricingTierRecord{{Threshold: 200000, InputPrice: 6, OutputPrice: 22.5}},
+		EffectiveFrom: jan,
+		Note:          "初始版本",
+	})
+	if err != nil {
+		t.Fatalf("保存定价版本失败: %v", err)
+	}
+	if !created.EffectiveFrom.Equal(jan) || created.Note != "初始版本" || len(created.Tiers) != 1 {
+		t.Errorf("保存结果不符: %+v", created)
+	}
+
+	// 同一模型同一生效时间再次保存：覆盖价格
+	updated, err := s.Upsert(ctx, &ModelPricingVersionRecord{ModelName: "claude-sonnet-4", InputPrice: 3.5, OutputPrice: 15, EffectiveFrom: jan})
+	if err != nil {
+		t.Fatalf("覆盖定价版本失败: %v", err)
+	}
+	if updated.ID != created.ID || updated.InputPrice != 3.5 {
+		t.Errorf("覆盖结果 = id %d input %f, 期望 id %d input 3.5", updated.ID, updated.InputPrice, created.ID)
+	}
+
+	if _, err := s.Upsert(ctx, &ModelPricingVersionRecord{ModelName: "claude-haiku", InputPrice: 1, EffectiveFrom: jan}); err != nil {
+		t.Fatalf("保存定价版本失败: %v", err)
+	}
+
+	versions, err := s.List(ctx, "claude-sonnet-4")
+	if err != nil {
+		t.Fatalf("列出定价版本失败: %v", err)
+	}
+	if len(versions) != 2 || !versions[0].EffectiveFrom.Equal(jan) || !versions[1].EffectiveFrom.Equal(feb) {
+		t.Fatalf("版本列表应按生效时间升序: %+v", versions)
+	}
+
+	all, err := s.List(ctx, "")
+	if err != nil || len(all) != 3 {
+		t.Fatalf("全部版本数量 = %d, err=%v, 期望 3", len(all), err)
+	}
+
+	if err := s.Delete(ctx, versions[1].ID); err != nil {
+		t.Fatalf("删除定价版本失败: %v", err)
+	}
+	if err := s.Delete(ctx, versions[1].ID); err == nil {
+		t.Error("删除不存在的版本应返回错误")
+	}
+}
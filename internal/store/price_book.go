@@ -0,0 +1,280 @@
+// 价目表存储
+// 渠道/端点级别的模型价格（绝对价格或倍率公式 + 按次固定费用），优先于 model_pricing
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// PriceBookRecord 表示数据库中的价目表记录
+type PriceBookRecord struct {
+	ID int64 `json:"id"`
+
+	Name         string `json:"name"`          // 价目表名称（唯一）
+	Channel      string `json:"channel"`       // 所属渠道
+	EndpointName string `json:"endpoint_name"` // 端点名称（为空表示作用于整个渠道）
+
+	// 计价方式
+	Mode           string  `json:"mode"`             // absolute / ratio
+	GroupRatio     float64 `json:"group_ratio"`      // 分组倍率（ratio 模式）
+	RatioUnitPrice float64 `json:"ratio_unit_price"` // 倍率为 1 时的输入单价 USD / 1M tokens（ratio 模式）
+	PerRequestFee  float64 `json:"per_request_fee"`  // 每次请求固定费用（USD）
+
+	Entries []PriceBookEntryRecord `json:"entries"`
+
+	Enabled     bool   `json:"enabled"`
+	Description string `json:"description,omitempty"`
+
+	// 审计字段
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// PriceBookEntryRecord 价目表中单个模型的价格（model 为 "*" 时匹配表内未列出的模型）
+type PriceBookEntryRecord struct {
+	Model string `json:"model"`
+
+	// absolute 模式 (USD per 1M tokens)
+	InputPrice           float64 `json:"input_price,omitempty"`
+	OutputPrice          float64 `json:"output_price,omitempty"`
+	CacheCreationPrice5m float64 `json:"cache_creation_price_5m,omitempty"`
+	CacheCreationPrice1h float64 `json:"cache_creation_price_1h,omitempty"`
+	CacheReadPrice       float64 `json:"cache_read_price,omitempty"`
+
+	// ratio 模式
+	ModelRatio         float64 `json:"model_ratio,omitempty"`
+	CompletionRatio    float64 `json:"completion_ratio,omitempty"`
+	CacheReadRatio     float64 `json:"cache_read_ratio,omitempty"`
+	CacheCreationRatio float64 `json:"cache_creation_ratio,omitempty"`
+
+	PerRequestFee float64 `json:"per_request_fee,omitempty"` // 每次请求固定费用（USD）
+}
+
+// PriceBookStore 定义价目表存储接口
+type PriceBookStore interface {
+	// CRUD 操作
+	Create(ctx context.Context, record *PriceBookRecord) (*PriceBookRecord, error)
+	Get(ctx context.Context, name string) (*PriceBookRecord, error)
+	List(ctx context.Context) ([]*PriceBookRecord, error)
+	Update(ctx context.Context, record *PriceBookRecord) error
+	Delete(ctx context.Context, name string) error
+
+	// 事务支持
+	WithTx(tx *sql.Tx) PriceBookStore
+}
+
+// SQLitePriceBookStore 实现 PriceBookStore 接口
+type SQLitePriceBookStore struct {
+	db *sql.DB
+	tx *sql.Tx // 事务上下文（可选）
+	mu sync.RWMutex
+}
+
+// NewSQLitePriceBookStore 创建新的 SQLite 价目表存储
+func NewSQLitePriceBookStore(db *sql.DB) *SQLitePriceBookStore {
+	return &SQLitePriceBookStore{db: db}
+}
+
+// getQuerier 返回用于执行查询的对象（事务或数据库连接）
+func (s *SQLitePriceBookStore) getQuerier() interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+} {
+	if s.tx != nil {
+		return s.tx
+	}
+	return s.db
+}
+
+const priceBookColumns = `id, name, channel, COALESCE(endpoint_name, ''), mode,
+	COALESCE(group_ratio, 1), COALESCE(ratio_unit_price, 2), COALESCE(per_request_fee, 0),
+	entries, enabled, COALESCE(description, ''), created_at, updated_at`
+
+// Create 创建价目表
+func (s *SQLitePriceBookStore) Create(ctx context.Context, record *PriceBookRecord) (*PriceBookRecord, error) {
+	if record == nil {
+		return nil, fmt.Errorf("record 不能为空")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	query := `
+		INSERT INTO price_books (
+			name, channel, endpoint_name, mode,
+			group_ratio, ratio_unit_price, per_request_fee,
+			entries, enabled, description
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+
+	result, err := s.getQuerier().ExecContext(ctx, query,
+		record.Name, record.Channel, record.EndpointName, record.Mode,
+		record.GroupRatio, record.RatioUnitPrice, record.PerRequestFee,
+		marshalPriceBookEntries(record.Entries), boolToInt(record.Enabled), nullIfEmpty(record.Description),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("创建价目表失败: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("获取插入 ID 失败: %w", err)
+	}
+
+	record.ID = id
+	record.CreatedAt = time.Now()
+	record.UpdatedAt = time.Now()
+
+	return record, nil
+}
+
+// Get 根据名称获取价目表（不存在时返回 nil）
+func (s *SQLitePriceBookStore) Get(ctx context.Context, name string) (*PriceBookRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	records, err := s.scanPriceBooks(ctx, `SELECT `+priceBookColumns+` FROM price_books WHERE name = ?`, name)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+	return records[0], nil
+}
+
+// List 获取所有价目表（按渠道、端点、名称排序）
+func (s *SQLitePriceBookStore) List(ctx context.Context) ([]*PriceBookRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.scanPriceBooks(ctx, `SELECT `+priceBookColumns+` FROM price_books ORDER BY channel ASC, endpoint_name ASC, name ASC`)
+}
+
+// Update 更新价目表（按名称定位）
+func (s *SQLitePriceBookStore) Update(ctx context.Context, record *PriceBookRecord) error {
+	if record == nil {
+		return fmt.Errorf("record 不能为空")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	query := `
+		UPDATE price_books SET
+			channel = ?, endpoint_name = ?, mode = ?,
+			group_ratio = ?, ratio_unit_price = ?, per_request_fee = ?,
+			entries = ?, enabled = ?, description = ?
+		WHERE name = ?
+	`
+
+	result, err := s.getQuerier().ExecContext(ctx, query,
+		record.Channel, record.EndpointName, record.Mode,
+		record.GroupRatio, record.RatioUnitPrice, record.PerRequestFee,
+		marshalPriceBookEntries(record.Entries), boolToInt(record.Enabled), nullIfEmpty(record.Description),
+		record.Name,
+	)
+	if err != nil {
+		return fmt.Errorf("更新价目表失败: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("获取影响行数失败: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("价目表不存在: %s", record.Name)
+	}
+	return nil
+}
+
+// Delete 删除价目表
+func (s *SQLitePriceBookStore) Delete(ctx context.Context, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result, err := s.getQuerier().ExecContext(ctx, `DELETE FROM price_books WHERE name = ?`, name)
+	if err != nil {
+		return fmt.Errorf("删除价目表失败: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("获取影响行数失败: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("价目表不存在: %s", name)
+	}
+	return nil
+}
+
+// WithTx 返回使用事务的存储实例
+func (s *SQLitePriceBookStore) WithTx(tx *sql.Tx) PriceBookStore {
+	return &SQLitePriceBookStore{db: s.db, tx: tx}
+}
+
+// scanPriceBooks 执行查询并扫描价目表记录
+func (s *SQLitePriceBookStore) scanPriceBooks(ctx context.Context, query string, args ...interface{}) ([]*PriceBookRecord, error) {
+	rows, err := s.getQuerier().QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("查询价目表失败: %w", err)
+	}
+	defer rows.Close()
+
+	var records []*PriceBookRecord
+	for rows.Next() {
+		var record PriceBookRecord
+		var entriesJSON sql.NullString
+		var enabled int
+		var createdAt, updatedAt string
+
+		if err := rows.Scan(
+			&record.ID, &record.Name, &record.Channel, &record.EndpointName, &record.Mode,
+			&record.GroupRatio, &record.RatioUnitPrice, &record.PerRequestFee,
+			&entriesJSON, &enabled, &record.Description, &createdAt, &updatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("扫描价目表记录失败: %w", err)
+		}
+
+		record.Entries = unmarshalPriceBookEntries(entriesJSON.String)
+		record.Enabled = enabled == 1
+		record.CreatedAt = parseSQLiteDateTime(createdAt)
+		record.UpdatedAt = parseSQLiteDateTime(updatedAt)
+		records = append(records, &record)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历价目表记录失败: %w", err)
+	}
+	return records, nil
+}
+
+// marshalPriceBookEntries 序列化价目表条目，未配置时存储 NULL
+func marshalPriceBookEntries(entries []PriceBookEntryRecord) interface{} {
+	if len(entries) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return nil
+	}
+	return string(data)
+}
+
+// unmarshalPriceBookEntries 解析价目表条目，解析失败时视为未配置
+func unmarshalPriceBookEntries(data string) []PriceBookEntryRecord {
+	if data == "" || data == "null" {
+		return nil
+	}
+	var entries []PriceBookEntryRecord
+	if err := json.Unmarshal([]byte(data), &entries); err != nil {
+		return nil
+	}
+	return entries
+}
@@ -0,0 +1,101 @@
+package store
+
+import (
+	"context"
+	"testing"
+)
+
+func createPriceBookTestDB(t *testing.T) (*SQLitePriceBookStore, func()) {
+	t.Helper()
+
+	db, cleanup := createTestDB(t)
+	schema := `
+		CREATE TABLE IF NOT EXISTS price_books (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL UNIQUE,
+			channel TEXT NOT NULL,
+			endpoint_name TEXT NOT NULL DEFAULT '',
+			mode TEXT NOT NULL DEFAULT 'absolute',
+			group_ratio REAL DEFAULT 1,
+			ratio_unit_price REAL DEFAULT 2,
+			per_request_fee REAL DEFAULT 0,
+			entries TEXT,
+			enabled INTEGER DEFAULT 1,
+			description TEXT,
+			created_at DATETIME DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now', 'localtime') || '+08:00'),
+			updated_at DATETIME DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now', 'localtime') || '+08:00')
+		);
+	`
+	if _, err := db.Exec(schema); err != nil {
+		cleanup()
+		t.Fatalf("创建 price_books 表失败: %v", err)
+	}
+	return NewSQLitePriceBookStore(db), cleanup
+}
+
+// TestPriceBookStore_CRUD 测试价目表增删改查与条目 JSON 往返
+func TestPriceBookStore_CRUD(t *testing.T) {
+	s, cleanup := createPriceBookTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	created, err := s.Create(ctx, &PriceBookRecord{
+		Name:           "relay-hk",
+		Channel:        "relay",
+		EndpointName:   "hk",
+		Mode:           "ratio",
+		GroupRatio:     0.8,
+		RatioUnitPrice: 2,
+		PerRequestFee:  0.001,
+		Entries: []PriceBookEntryRecord{
+			{Model: "claude-sonnet-4", ModelRatio: 1.5, CompletionRatio: 5},
+			{Model: "*", ModelRatio: 1},
+		},
+		Enabled: true,
+	})
+	if err != nil {
+		t.Fatalf("创建价目表失败: %v", err)
+	}
+	if created.ID == 0 {
+		t.Fatal("创建后应返回 ID")
+	}
+	if _, err := s.Create(ctx, &PriceBookRecord{Name: "relay-hk", Channel: "relay", Mode: "absolute"}); err == nil {
+		t.Error("重复名称应返回错误")
+	}
+
+	got, err := s.Get(ctx, "relay-hk")
+	if err != nil || got == nil {
+		t.Fatalf("获取价目表失败: %v", err)
+	}
+	if got.EndpointName != "hk" || got.GroupRatio != 0.8 || !got.Enabled || len(got.Entries) != 2 ||
+		got.Entries[0].CompletionRatio != 5 || got.Entries[1].Model != "*" {
+		t.Errorf("价目表内容不符: %+v", got)
+	}
+
+	got.Enabled = false
+	got.Entries = got.Entries[:1]
+	if err := s.Update(ctx, got); err != nil {
+		t.Fatalf("更新价目表失败: %v", err)
+	}
+	if updated, _ := s.Get(ctx, "relay-hk"); updated.Enabled || len(updated.Entries) != 1 {
+		t.Errorf("更新结果不符: %+v", updated)
+	}
+
+	if _, err := s.Create(ctx, &PriceBookRecord{Name: "relay", Channel: "relay", Mode: "absolute", Enabled: true}); err != nil {
+		t.Fatalf("创建价目表失败: %v", err)
+	}
+	list, err := s.List(ctx)
+	if err != nil || len(list) != 2 || list[0].Name != "relay" {
+		t.Fatalf("价目表列表不符: %v %+v", err, list)
+	}
+
+	if err := s.Delete(ctx, "relay-hk"); err != nil {
+		t.Fatalf("删除价目表失败: %v", err)
+	}
+	if err := s.Delete(ctx, "relay-hk"); err == nil {
+		t.Error("重复删除应返回错误")
+	}
+	if missing, err := s.Get(ctx, "relay-hk"); err != nil || missing != nil {
+		t.Errorf("删除后 Get = (%v, %v), want (nil, nil)", missing, err)
+	}
+}
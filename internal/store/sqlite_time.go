@@ -18,6 +18,7 @@ func parseSQLiteDateTime(value string) time.Time {
 		"2006-01-02 15:04:05.999999",
 		"2006-01-02 15:04:05.999",
 		"2006-01-02 15:04:05",
+		time.RFC3339Nano, // modernc 驱动读取 DATETIME 列时返回 RFC3339 格式
 	}
 	for _, layout := range layouts {
 		if t, err := time.Parse(layout, value); err == nil {
@@ -41,3 +42,8 @@ func parseSQLiteDateTime(value string) time.Time {
 
 	return time.Time{}
 }
+
+// formatSQLiteDateTime 格式化为与 schema.sql 默认值一致的时间字符串（带时区）
+func formatSQLiteDateTime(t time.Time) string {
+	return t.Format("2006-01-02 15:04:05.000-07:00")
+}
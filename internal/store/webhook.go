@@ -0,0 +1,363 @@
+// Webhook 通知存储
+// 出站 Webhook 目标配置与投递记录
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// WebhookRecord 表示数据库中的 Webhook 目标
+type WebhookRecord struct {
+	ID int64 `json:"id"`
+
+	Name       string   `json:"name"`        // 目标名称（唯一）
+	Kind       string   `json:"kind"`        // generic / slack / feishu / dingtalk / wecom
+	URL        string   `json:"url"`         // Webhook 地址
+	Secret     string   `json:"secret"`      // 签名密钥
+	EventTypes []string `json:"event_types"` // 订阅的事件类型（为空时订阅默认运维事件）
+
+	DedupSeconds       int `json:"dedup_seconds"`         // 去重窗口（秒）
+	RateLimitPerMinute int `json:"rate_limit_per_minute"` // 每分钟最多发送次数
+	MaxRetries         int `json:"max_retries"`           // 失败重试次数
+
+	Enabled     bool   `json:"enabled"`
+	Description string `json:"description,omitempty"`
+
+	// 审计字段
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// WebhookDeliveryRecord 表示一次 Webhook 投递记录
+type WebhookDeliveryRecord struct {
+	ID          int64      `json:"id"`
+	WebhookName string     `json:"webhook_name"`
+	EventType   string     `json:"event_type"`
+	DedupKey    string     `json:"dedup_key"`
+	Status      string     `json:"status"` // success / failed
+	Attempts    int        `json:"attempts"`
+	HTTPStatus  int        `json:"http_status"`
+	Error       string     `json:"error,omitempty"`
+	Payload     string     `json:"payload,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	DeliveredAt *time.Time `json:"delivered_at,omitempty"`
+}
+
+// WebhookStore 定义 Webhook 存储接口
+type WebhookStore interface {
+	// CRUD 操作
+	Create(ctx context.Context, record *WebhookRecord) (*WebhookRecord, error)
+	Get(ctx context.Context, name string) (*WebhookRecord, error)
+	List(ctx context.Context) ([]*WebhookRecord, error)
+	Update(ctx context.Context, record *WebhookRecord) error
+	Delete(ctx context.Context, name string) error
+
+	// 投递记录
+	RecordDelivery(ctx context.Context, record *WebhookDeliveryRecord) error
+	ListDeliveries(ctx context.Context, webhookName string, limit int) ([]*WebhookDeliveryRecord, error)
+	DeleteDeliveriesBefore(ctx context.Context, before time.Time) (int64, error)
+
+	// 事务支持
+	WithTx(tx *sql.Tx) WebhookStore
+}
+
+// SQLiteWebhookStore 实现 WebhookStore 接口
+type SQLiteWebhookStore struct {
+	db *sql.DB
+	tx *sql.Tx // 事务上下文（可选）
+	mu sync.RWMutex
+}
+
+// NewSQLiteWebhookStore 创建新的 SQLite Webhook 存储
+func NewSQLiteWebhookStore(db *sql.DB) *SQLiteWebhookStore {
+	return &SQLiteWebhookStore{db: db}
+}
+
+// getQuerier 返回用于执行查询的对象（事务或数据库连接）
+func (s *SQLiteWebhookStore) getQuerier() interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+} {
+	if s.tx != nil {
+		return s.tx
+	}
+	return s.db
+}
+
+const webhookColumns = `id, name, kind, url, COALESCE(secret, ''), event_types,
+	dedup_seconds, rate_limit_per_minute, max_retries, enabled, COALESCE(description, ''), created_at, updated_at`
+
+// Create 创建 Webhook 目标
+func (s *SQLiteWebhookStore) Create(ctx context.Context, record *WebhookRecord) (*WebhookRecord, error) {
+	if record == nil {
+		return nil, fmt.Errorf("record 不能为空")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	query := `
+		INSERT INTO webhooks (
+			name, kind, url, secret, event_types,
+			dedup_seconds, rate_limit_per_minute, max_retries, enabled, description
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+
+	result, err := s.getQuerier().ExecContext(ctx, query,
+		record.Name, record.Kind, record.URL, record.Secret, marshalEventTypes(record.EventTypes),
+		record.DedupSeconds, record.RateLimitPerMinute, record.MaxRetries, boolToInt(record.Enabled), nullIfEmpty(record.Description),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("创建 Webhook 失败: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("获取插入 ID 失败: %w", err)
+	}
+
+	record.ID = id
+	record.CreatedAt = time.Now()
+	record.UpdatedAt = time.Now()
+
+	return record, nil
+}
+
+// Get 根据名称获取 Webhook 目标（不存在时返回 nil）
+func (s *SQLiteWebhookStore) Get(ctx context.Context, name string) (*WebhookRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	records, err := s.scanWebhooks(ctx, `SELECT `+webhookColumns+` FROM webhooks WHERE name = ?`, name)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+	return records[0], nil
+}
+
+// List 获取所有 Webhook 目标（按名称排序）
+func (s *SQLiteWebhookStore) List(ctx context.Context) ([]*WebhookRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.scanWebhooks(ctx, `SELECT `+webhookColumns+` FROM webhooks ORDER BY name ASC`)
+}
+
+// Update 更新 Webhook 目标（按名称定位）
+func (s *SQLiteWebhookStore) Update(ctx context.Context, record *WebhookRecord) error {
+	if record == nil {
+		return fmt.Errorf("record 不能为空")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	query := `
+		UPDATE webhooks SET
+			kind = ?, url = ?, secret = ?, event_types = ?,
+			dedup_seconds = ?, rate_limit_per_minute = ?, max_retries = ?, enabled = ?, description = ?
+		WHERE name = ?
+	`
+
+	result, err := s.getQuerier().ExecContext(ctx, query,
+		record.Kind, record.URL, record.Secret, marshalEventTypes(record.EventTypes),
+		record.DedupSeconds, record.RateLimitPerMinute, record.MaxRetries, boolToInt(record.Enabled), nullIfEmpty(record.Description),
+		record.Name,
+	)
+	if err != nil {
+		return fmt.Errorf("更新 Webhook 失败: %w", err)
+	}
+	return checkWebhookAffected(result, record.Name)
+}
+
+// Delete 删除 Webhook 目标（投递记录保留，随过期清理）
+func (s *SQLiteWebhookStore) Delete(ctx context.Context, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result, err := s.getQuerier().ExecContext(ctx, `DELETE FROM webhooks WHERE name = ?`, name)
+	if err != nil {
+		return fmt.Errorf("删除 Webhook 失败: %w", err)
+	}
+	return checkWebhookAffected(result, name)
+}
+
+// RecordDelivery 记录一次投递结果
+func (s *SQLiteWebhookStore) RecordDelivery(ctx context.Context, record *WebhookDeliveryRecord) error {
+	if record == nil {
+		return fmt.Errorf("record 不能为空")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	createdAt := record.CreatedAt
+	if createdAt.IsZero() {
+		createdAt = time.Now()
+	}
+
+	query := `
+		INSERT INTO webhook_deliveries (
+			webhook_name, event_type, dedup_key, status, attempts, http_status, error, payload, created_at, delivered_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	result, err := s.getQuerier().ExecContext(ctx, query,
+		record.WebhookName, record.EventType, record.DedupKey, record.Status, record.Attempts, record.HTTPStatus,
+		nullIfEmpty(record.Error), nullIfEmpty(record.Payload), formatSQLiteDateTime(createdAt), nullableSQLiteTime(record.DeliveredAt),
+	)
+	if err != nil {
+		return fmt.Errorf("记录 Webhook 投递失败: %w", err)
+	}
+	if id, err := result.LastInsertId(); err == nil {
+		record.ID = id
+	}
+	record.CreatedAt = createdAt
+	return nil
+}
+
+// ListDeliveries 获取最近的投递记录（webhookName 为空时返回所有目标）
+func (s *SQLiteWebhookStore) ListDeliveries(ctx context.Context, webhookName string, limit int) ([]*WebhookDeliveryRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if limit <= 0 {
+		limit = 100
+	}
+
+	query := `
+		SELECT id, webhook_name, event_type, dedup_key, status, attempts, http_status,
+			COALESCE(error, ''), COALESCE(payload, ''), created_at, delivered_at
+		FROM webhook_deliveries
+	`
+	args := []interface{}{}
+	if webhookName != "" {
+		query += ` WHERE webhook_name = ?`
+		args = append(args, webhookName)
+	}
+	query += ` ORDER BY id DESC LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := s.getQuerier().QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("查询 Webhook 投递记录失败: %w", err)
+	}
+	defer rows.Close()
+
+	var records []*WebhookDeliveryRecord
+	for rows.Next() {
+		var record WebhookDeliveryRecord
+		var createdAt string
+		var deliveredAt sql.NullString
+		if err := rows.Scan(
+			&record.ID, &record.WebhookName, &record.EventType, &record.DedupKey, &record.Status, &record.Attempts, &record.HTTPStatus,
+			&record.Error, &record.Payload, &createdAt, &deliveredAt,
+		); err != nil {
+			return nil, fmt.Errorf("扫描 Webhook 投递记录失败: %w", err)
+		}
+		record.CreatedAt = parseSQLiteDateTime(createdAt)
+		record.DeliveredAt = parseNullableSQLiteTime(deliveredAt)
+		records = append(records, &record)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历 Webhook 投递记录失败: %w", err)
+	}
+	return records, nil
+}
+
+// DeleteDeliveriesBefore 清理指定时间之前的投递记录，返回删除行数
+func (s *SQLiteWebhookStore) DeleteDeliveriesBefore(ctx context.Context, before time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result, err := s.getQuerier().ExecContext(ctx, `DELETE FROM webhook_deliveries WHERE created_at < ?`, formatSQLiteDateTime(before))
+	if err != nil {
+		return 0, fmt.Errorf("清理 Webhook 投递记录失败: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// WithTx 返回使用事务的存储实例
+func (s *SQLiteWebhookStore) WithTx(tx *sql.Tx) WebhookStore {
+	return &SQLiteWebhookStore{db: s.db, tx: tx}
+}
+
+// scanWebhooks 执行查询并扫描 Webhook 目标
+func (s *SQLiteWebhookStore) scanWebhooks(ctx context.Context, query string, args ...interface{}) ([]*WebhookRecord, error) {
+	rows, err := s.getQuerier().QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("查询 Webhook 失败: %w", err)
+	}
+	defer rows.Close()
+
+	var records []*WebhookRecord
+	for rows.Next() {
+		var record WebhookRecord
+		var eventTypes sql.NullString
+		var enabled int
+		var createdAt, updatedAt string
+
+		if err := rows.Scan(
+			&record.ID, &record.Name, &record.Kind, &record.URL, &record.Secret, &eventTypes,
+			&record.DedupSeconds, &record.RateLimitPerMinute, &record.MaxRetries, &enabled, &record.Description, &createdAt, &updatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("扫描 Webhook 记录失败: %w", err)
+		}
+
+		record.EventTypes = unmarshalEventTypes(eventTypes.String)
+		record.Enabled = enabled == 1
+		record.CreatedAt = parseSQLiteDateTime(createdAt)
+		record.UpdatedAt = parseSQLiteDateTime(updatedAt)
+		records = append(records, &record)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历 Webhook 记录失败: %w", err)
+	}
+	return records, nil
+}
+
+// checkWebhookAffected 检查更新/删除是否命中 Webhook 目标
+func checkWebhookAffected(result sql.Result, name string) error {
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("获取影响行数失败: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("Webhook 不存在: %s", name)
+	}
+	return nil
+}
+
+// marshalEventTypes 序列化事件类型列表，未配置时存储 NULL
+func marshalEventTypes(eventTypes []string) interface{} {
+	if len(eventTypes) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(eventTypes)
+	if err != nil {
+		return nil
+	}
+	return string(data)
+}
+
+// unmarshalEventTypes 解析事件类型列表，解析失败时视为未配置
+func unmarshalEventTypes(data string) []string {
+	if data == "" || data == "null" {
+		return nil
+	}
+	var eventTypes []string
+	if err := json.Unmarshal([]byte(data), &eventTypes); err != nil {
+		return nil
+	}
+	return eventTypes
+}
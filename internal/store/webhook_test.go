@@ -0,0 +1,123 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func createWebhookTestDB(t *testing.T) (*SQLiteWebhookStore, func()) {
+	t.Helper()
+
+	db, cleanup := createTestDB(t)
+	schema := `
+		CREATE TABLE IF NOT EXISTS webhooks (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL UNIQUE,
+			kind TEXT NOT NULL DEFAULT 'generic',
+			url TEXT NOT NULL,
+			secret TEXT NOT NULL DEFAULT '',
+			event_types TEXT,
+			dedup_seconds INTEGER NOT NULL DEFAULT 300,
+			rate_limit_per_minute INTEGER NOT NULL DEFAULT 20,
+			max_retries INTEGER NOT NULL DEFAULT 3,
+			enabled INTEGER DEFAULT 1,
+			description TEXT,
+			created_at DATETIME DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now', 'localtime') || '+08:00'),
+			updated_at DATETIME DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now', 'localtime') || '+08:00')
+		);
+		CREATE TABLE IF NOT EXISTS webhook_deliveries (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			webhook_name TEXT NOT NULL,
+			event_type TEXT NOT NULL,
+			dedup_key TEXT NOT NULL DEFAULT '',
+			status TEXT NOT NULL,
+			attempts INTEGER NOT NULL DEFAULT 0,
+			http_status INTEGER NOT NULL DEFAULT 0,
+			error TEXT,
+			payload TEXT,
+			created_at DATETIME DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now', 'localtime') || '+08:00'),
+			delivered_at DATETIME
+		);
+	`
+	if _, err := db.Exec(schema); err != nil {
+		cleanup()
+		t.Fatalf("创建 webhooks 表失败: %v", err)
+	}
+	return NewSQLiteWebhookStore(db), cleanup
+}
+
+// TestWebhookStore_CRUDAndDeliveries 测试 Webhook 增删改查、事件类型 JSON 往返与投递记录
+func TestWebhookStore_CRUDAndDeliveries(t *testing.T) {
+	s, cleanup := createWebhookTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	if _, err := s.Create(ctx, &WebhookRecord{
+		Name:               "team-feishu",
+		Kind:               "feishu",
+		URL:                "https://open.feishu.cn/open-apis/bot/v2/hook/xxx",
+		Secret:             "s3cret",
+		EventTypes:         []string{"failover_triggered", "endpoint_down"},
+		DedupSeconds:       300,
+		RateLimitPerMinute: 10,
+		MaxRetries:         3,
+		Enabled:            true,
+	}); err != nil {
+		t.Fatalf("创建 Webhook 失败: %v", err)
+	}
+
+	got, err := s.Get(ctx, "team-feishu")
+	if err != nil || got == nil {
+		t.Fatalf("获取 Webhook 失败: %v", err)
+	}
+	if got.Kind != "feishu" || got.Secret != "s3cret" || len(got.EventTypes) != 2 || got.EventTypes[1] != "endpoint_down" ||
+		got.RateLimitPerMinute != 10 || !got.Enabled {
+		t.Errorf("Webhook 内容不符: %+v", got)
+	}
+
+	got.EventTypes = nil
+	got.Enabled = false
+	if err := s.Update(ctx, got); err != nil {
+		t.Fatalf("更新 Webhook 失败: %v", err)
+	}
+	if updated, _ := s.Get(ctx, "team-feishu"); updated.EventTypes != nil || updated.Enabled {
+		t.Errorf("更新结果不符: %+v", updated)
+	}
+
+	old := time.Now().Add(-48 * time.Hour)
+	delivered := time.Now()
+	for _, d := range []*WebhookDeliveryRecord{
+		{WebhookName: "team-feishu", EventType: "endpoint_down", Status: "failed", Attempts: 4, HTTPStatus: 502, Error: "HTTP 502", CreatedAt: old},
+		{WebhookName: "team-feishu", EventType: "failover_triggered", DedupKey: "failover_triggered:relay→official", Status: "success", Attempts: 1, HTTPStatus: 200, DeliveredAt: &delivered},
+		{WebhookName: "other", EventType: "budget_alert", Status: "success", Attempts: 1, HTTPStatus: 200},
+	} {
+		if err := s.RecordDelivery(ctx, d); err != nil {
+			t.Fatalf("记录投递失败: %v", err)
+		}
+	}
+
+	deliveries, err := s.ListDeliveries(ctx, "team-feishu", 10)
+	if err != nil {
+		t.Fatalf("查询投递记录失败: %v", err)
+	}
+	if len(deliveries) != 2 || deliveries[0].EventType != "failover_triggered" || deliveries[0].DeliveredAt == nil ||
+		deliveries[1].Error != "HTTP 502" || deliveries[1].DeliveredAt != nil {
+		t.Errorf("投递记录不符: %+v", deliveries)
+	}
+	if all, _ := s.ListDeliveries(ctx, "", 0); len(all) != 3 {
+		t.Errorf("全部投递记录数 = %d, want 3", len(all))
+	}
+
+	removed, err := s.DeleteDeliveriesBefore(ctx, time.Now().Add(-24*time.Hour))
+	if err != nil || removed != 1 {
+		t.Errorf("清理过期投递记录 = %d, %v, want 1", removed, err)
+	}
+
+	if err := s.Delete(ctx, "team-feishu"); err != nil {
+		t.Fatalf("删除 Webhook 失败: %v", err)
+	}
+	if err := s.Delete(ctx, "team-feishu"); err == nil {
+		t.Error("删除不存在的 Webhook 应返回错误")
+	}
+}
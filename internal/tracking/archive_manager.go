@@ -36,16 +36,23 @@ func DefaultArchiveManagerConfig() ArchiveManagerConfig {
 
 // ArchiveManager 管理归档写入
 type ArchiveManager struct {
-	archiveChan chan *ArchiveEvent
-	adapter     DatabaseAdapter
-	config      ArchiveManagerConfig
-	pricing     map[string]ModelPricing       // 模型定价缓存
-	endpointMu  map[string]EndpointMultiplier // 端点倍率缓存
-	location    *time.Location
+	archiveChan    chan *ArchiveEvent
+	adapter        DatabaseAdapter
+	config         ArchiveManagerConfig
+	pricing        map[string]ModelPricing       // 模型定价缓存
+	defaultPricing ModelPricing                  // 未配置模型的默认定价
+	endpointMu     map[string]EndpointMultiplier // 端点倍率缓存
+	currency       *CurrencyAccounting           // 多币种记账快照
+	priceBooks     *PriceBookSet                 // 渠道/端点价目表快照
+	history        *PricingHistory               // 模型定价历史版本
+	location       *time.Location
 
 	// 热池引用（用于归档成功后清理）
 	hotPool *HotPool
 
+	// 归档失败回调
+	onError func(operation string, err error)
+
 	// 统计信息
 	stats ArchiveStats
 
@@ -113,11 +120,36 @@ func (am *ArchiveManager) SetHotPool(hp *HotPool) {
 	am.hotPool = hp
 }
 
+// SetErrorHandler 设置归档失败回调
+func (am *ArchiveManager) SetErrorHandler(handler func(operation string, err error)) {
+	am.onError = handler
+}
+
 // UpdateEndpointMultipliers 更新端点成本倍率
 func (am *ArchiveManager) UpdateEndpointMultipliers(multipliers map[string]EndpointMultiplier) {
 	am.endpointMu = multipliers
 }
 
+// UpdateCurrencyAccounting 更新多币种记账快照（渠道结算币种、报表币种、汇率）
+func (am *ArchiveManager) UpdateCurrencyAccounting(accounting *CurrencyAccounting) {
+	am.currency = accounting
+}
+
+// UpdatePriceBooks 更新渠道/端点价目表快照
+func (am *ArchiveManager) UpdatePriceBooks(books *PriceBookSet) {
+	am.priceBooks = books
+}
+
+// UpdatePricingHistory 更新模型定价历史版本（按请求开始时间选取生效版本）
+func (am *ArchiveManager) UpdatePricingHistory(history *PricingHistory) {
+	am.history = history
+}
+
+// UpdateDefaultPricing 更新未配置模型的默认定价
+func (am *ArchiveManager) UpdateDefaultPricing(pricing ModelPricing) {
+	am.defaultPricing = pricing
+}
+
 // UpdatePricing 更新模型定价（运行时动态更新）
 func (am *ArchiveManager) UpdatePricing(pricing map[string]ModelPricing) {
 	am.pricing = pricing
@@ -190,6 +222,9 @@ func (am *ArchiveManager) startArchiver() {
 				"request_ids", strings.Join(requestIDs, ", "),
 				"error", err,
 				"latency", latency)
+			if am.onError != nil {
+				am.onError("archive", fmt.Errorf("%d 条请求归档失败: %w", len(batch), err))
+			}
 		} else {
 			slog.Debug("📦 批量归档成功",
 				"batch_size", len(batch),
@@ -306,19 +341,44 @@ func (am *ArchiveManager) batchInsert(events []*ArchiveEvent) error {
 			cache_read_tokens,
 			input_cost_usd, output_cost_usd,
 			cache_creation_cost_usd, cache_creation_5m_cost_usd, cache_creation_1h_cost_usd,
-			cache_read_cost_usd, total_cost_usd
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			cache_read_cost_usd, total_cost_usd,
+			web_search_requests, web_fetch_requests, server_tool_cost_usd,
+			pricing_tier,
+			billing_currency, billing_cost, reporting_currency, reporting_cost,
+			price_source, request_fee_usd,
+			session_id,
+			ttfb_ms, first_event_ms, first_token_ms, output_tokens_per_sec,
+			stop_reason, response_message_id, tool_use_count, has_thinking,
+			max_tokens, message_count, tool_count, thinking_budget, temperature, request_bytes
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`)
 	if err != nil {
 		return fmt.Errorf("failed to prepare statement: %w", err)
 	}
 	defer stmt.Close()
 
+	tagStmt, err := tx.PrepareContext(ctx, `
+		INSERT OR IGNORE INTO request_tags (request_id, tag_key, tag_value) VALUES (?, ?, ?)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare tag statement: %w", err)
+	}
+	defer tagStmt.Close()
+
+	attemptStmt, err := tx.PrepareContext(ctx, insertRequestAttemptSQL)
+	if err != nil {
+		return fmt.Errorf("failed to prepare attempt statement: %w", err)
+	}
+	defer attemptStmt.Close()
+
+	rollups := make(usageRollupDeltas)
 	for _, event := range events {
 		req := event.Request
 
 		// v5.0.1+: 计算分开的成本
 		costBreakdown := am.calculateCostV2(req)
+		// 按请求开始时的汇率换算结算币种与报表币种成本
+		amounts := am.currency.Amounts(req.Channel, costBreakdown.TotalCost, req.StartTime)
 
 		// 格式化时间
 		startTime := am.formatTime(req.StartTime)
@@ -361,10 +421,47 @@ func (am *ArchiveManager) batchInsert(events []*ArchiveEvent) error {
 			costBreakdown.CacheCreation1hCost, // 1小时缓存成本
 			costBreakdown.CacheReadCost,
 			costBreakdown.TotalCost,
+			req.WebSearchRequests,
+			req.WebFetchRequests,
+			costBreakdown.ServerToolCost,
+			costBreakdown.PricingTier,
+			amounts.BillingCurrency,
+			nullableFloat(amounts.BillingCost),
+			amounts.ReportingCurrency,
+			nullableFloat(amounts.ReportingCost),
+			nullString(costBreakdown.PriceSource),
+			costBreakdown.RequestFee,
+			nullString(req.SessionID),
+			nullableMs(req.Timings.TTFBMs),
+			nullableMs(req.Timings.FirstEventMs),
+			nullableMs(req.Timings.FirstTokenMs),
+			nullableRate(req.Timings.OutputTokensPerSec),
+			nullString(req.ResponseMeta.StopReason),
+			nullString(req.ResponseMeta.MessageID),
+			req.ResponseMeta.ToolUseCount,
+			req.ResponseMeta.HasThinking,
+			req.RequestParams.MaxTokens,
+			req.RequestParams.MessageCount,
+			req.RequestParams.ToolCount,
+			req.RequestParams.ThinkingBudget,
+			nullableTemperature(req.RequestParams.Temperature),
+			req.RequestParams.RequestBytes,
 		)
 		if err != nil {
 			return fmt.Errorf("failed to insert request %s: %w", req.RequestID, err)
 		}
+		if err := insertRequestTagsTx(ctx, tagStmt, req.RequestID, req.Tags); err != nil {
+			return err
+		}
+		if err := insertRequestAttemptsTx(ctx, attemptStmt, req.RequestID, req.Attempts, am.formatTime); err != nil {
+			return err
+		}
+		rollups.add(startTime, req, costBreakdown.TotalCost)
+	}
+
+	// 同一事务内累加小时/日用量汇总，保证与 request_logs 一致
+	if err := applyUsageRollupDeltasTx(ctx, tx, rollups); err != nil {
+		return err
 	}
 
 	if err := tx.Commit(); err != nil {
@@ -375,33 +472,8 @@ func (am *ArchiveManager) batchInsert(events []*ArchiveEvent) error {
 }
 
 // calculateCostV2 计算请求成本（v5.0.1+: 支持分开的 5m/1h 缓存）
+// 与跟踪器共用计价规则（见 costRules），按请求开始时生效的定价版本计价
 func (am *ArchiveManager) calculateCostV2(req *ActiveRequest) CostBreakdown {
-	if am.pricing == nil {
-		return CostBreakdown{}
-	}
-
-	// 查找模型定价，不存在则回退到 _default 定价
-	pricing, exists := am.pricing[req.ModelName]
-	if !exists {
-		pricing, exists = am.pricing["_default"]
-		if !exists {
-			return CostBreakdown{}
-		}
-	}
-
-	// 获取端点倍率
-	var multiplier *EndpointMultiplier
-	if am.endpointMu != nil {
-		if key := endpointMultiplierKey(req.Channel, req.GroupName, req.EndpointName); key != "" {
-			if m, ok := am.endpointMu[key]; ok {
-				multiplier = &m
-			}
-		} else if m, ok := am.endpointMu[req.EndpointName]; ok {
-			// 兼容旧 key：endpointName 作为唯一键
-			multiplier = &m
-		}
-	}
-
 	// 构建 TokenUsage 对象
 	usage := &TokenUsage{
 		InputTokens:           req.InputTokens,
@@ -410,10 +482,18 @@ func (am *ArchiveManager) calculateCostV2(req *ActiveRequest) CostBreakdown {
 		CacheCreation5mTokens: req.CacheCreation5mTokens,
 		CacheCreation1hTokens: req.CacheCreation1hTokens,
 		CacheReadTokens:       req.CacheReadTokens,
+		WebSearchRequests:     req.WebSearchRequests,
+		WebFetchRequests:      req.WebFetchRequests,
 	}
 
-	// 调用公共成本计算函数（v5.0.1+）
-	return CalculateCostV2(usage, &pricing, multiplier)
+	rules := costRules{
+		pricing:        am.pricing,
+		history:        am.history,
+		defaultPricing: am.defaultPricing,
+		multipliers:    am.endpointMu,
+		books:          am.priceBooks,
+	}
+	return rules.cost(req.Channel, req.GroupName, req.EndpointName, req.ModelName, usage, req.StartTime)
 }
 
 // formatTime 格式化时间为易读格式（使用配置的时区）
@@ -0,0 +1,192 @@
+package tracking
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+	"unicode/utf8"
+)
+
+// 上游尝试的重试/故障转移决策
+const (
+	AttemptDecisionSuccess = "success"         // 响应被接受
+	AttemptDecisionRetry   = "retry"           // 重试同一端点
+	AttemptDecisionSwitch  = "switch_endpoint" // 切换到下一端点
+	AttemptDecisionSuspend = "suspend"         // 挂起等待端点恢复
+	AttemptDecisionFail    = "fail"            // 终止重试，请求失败
+	AttemptDecisionCancel  = "cancelled"       // 客户端取消
+)
+
+// maxAttemptErrorMessageLen 上游错误摘要最大长度（字符）
+const maxAttemptErrorMessageLen = 500
+
+// RequestAttempt 单次上游尝试（一次请求在重试与故障转移中可能有多次）
+type RequestAttempt struct {
+	RequestID     string    `json:"request_id"`
+	AttemptNumber int       `json:"attempt_number"` // 全局尝试序号（从 1 开始）
+	Channel       string    `json:"channel"`
+	EndpointName  string    `json:"endpoint_name"`
+	StartTime     time.Time `json:"start_time"`
+	EndTime       time.Time `json:"end_time"`
+	DurationMs    int64     `json:"duration_ms"`
+	HTTPStatus    int       `json:"http_status"` // 0 表示未收到上游响应
+
+	ErrorType     string `json:"error_type,omitempty"`    // 错误分类（取值同 failure_reason）
+	ErrorMessage  string `json:"error_message,omitempty"` // 上游错误摘要（截断）
+	BytesReceived int64  `json:"bytes_received"`
+
+	ErrorSignature string `json:"error_signature,omitempty"` // 归一化后的错误消息（去除 ID 与数字）
+	Fingerprint    string `json:"fingerprint,omitempty"`     // 错误指纹（错误分析按此聚合）
+
+	Decision       string `json:"decision"`                  // success/retry/switch_endpoint/suspend/fail/cancelled
+	DecisionReason string `json:"decision_reason,omitempty"` // 重试决策原因
+}
+
+// RecordRequestAttempt 记录一次上游尝试（需在 RecordRequestStart 之后调用）
+// 热池模式下随请求归档写入 request_attempts，请求已归档或传统模式通过事件队列写入
+func (ut *UsageTracker) RecordRequestAttempt(requestID string, attempt RequestAttempt) {
+	if ut.config == nil || !ut.config.Enabled || requestID == "" {
+		return
+	}
+
+	attempt.RequestID = requestID
+	attempt.ErrorMessage = truncateAttemptMessage(attempt.ErrorMessage)
+	fingerprintAttempt(&attempt)
+	if attempt.DurationMs == 0 && !attempt.StartTime.IsZero() && attempt.EndTime.After(attempt.StartTime) {
+		attempt.DurationMs = attempt.EndTime.Sub(attempt.StartTime).Milliseconds()
+	}
+
+	if ut.hotPoolEnabled && ut.hotPool != nil {
+		err := ut.hotPool.Update(requestID, func(req *ActiveRequest) {
+			// 复制后再追加，避免与热池快照共享底层数组
+			req.Attempts = append(append([]RequestAttempt(nil), req.Attempts...), attempt)
+		})
+		if err == nil {
+			return
+		}
+		slog.Debug("🔥 热池记录上游尝试失败，降级到事件队列模式",
+			"request_id", requestID,
+			"error", err)
+	}
+
+	event := RequestEvent{
+		Type:      "attempt",
+		RequestID: requestID,
+		Timestamp: ut.now(),
+		Data:      attempt,
+	}
+
+	select {
+	case ut.eventChan <- event:
+	default:
+		slog.Warn("Usage tracking event buffer full, dropping attempt event",
+			"request_id", requestID)
+	}
+}
+
+// buildAttemptQuery 构建上游尝试写入查询（传统模式）
+func (ut *UsageTracker) buildAttemptQuery(event RequestEvent) (string, []interface{}, error) {
+	attempt, ok := event.Data.(RequestAttempt)
+	if !ok {
+		return "", nil, fmt.Errorf("invalid attempt event data type")
+	}
+	return insertRequestAttemptSQL, attemptInsertArgs(event.RequestID, attempt, ut.formatStartTimeQueryBound), nil
+}
+
+const insertRequestAttemptSQL = `INSERT INTO request_attempts (
+		request_id, attempt_number, channel, endpoint_name,
+		start_time, end_time, duration_ms, http_status,
+		error_type, error_message, bytes_received,
+		decision, decision_reason,
+		error_signature, error_fingerprint
+	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+// attemptInsertArgs 构建上游尝试写入参数（时间按配置时区格式化）
+func attemptInsertArgs(requestID string, attempt RequestAttempt, formatTime func(time.Time) string) []interface{} {
+	var endTime interface{}
+	if !attempt.EndTime.IsZero() {
+		endTime = formatTime(attempt.EndTime)
+	}
+	var httpStatus interface{}
+	if attempt.HTTPStatus > 0 {
+		httpStatus = attempt.HTTPStatus
+	}
+	return []interface{}{
+		requestID, attempt.AttemptNumber, nullString(attempt.Channel), nullString(attempt.EndpointName),
+		formatTime(attempt.StartTime), endTime, attempt.DurationMs, httpStatus,
+		nullString(attempt.ErrorType), nullString(attempt.ErrorMessage), attempt.BytesReceived,
+		attempt.Decision, nullString(attempt.DecisionReason),
+		nullString(attempt.ErrorSignature), nullString(attempt.Fingerprint),
+	}
+}
+
+// insertRequestAttemptsTx 在归档事务内写入请求的全部上游尝试
+func insertRequestAttemptsTx(ctx context.Context, stmt *sql.Stmt, requestID string, attempts []RequestAttempt, formatTime func(time.Time) string) error {
+	for _, attempt := range attempts {
+		if _, err := stmt.ExecContext(ctx, attemptInsertArgs(requestID, attempt, formatTime)...); err != nil {
+			return fmt.Errorf("failed to insert attempt %d for request %s: %w", attempt.AttemptNumber, requestID, err)
+		}
+	}
+	return nil
+}
+
+// QueryRequestAttempts 查询请求的上游尝试明细（按尝试顺序；进行中的请求从热池读取）
+func (ut *UsageTracker) QueryRequestAttempts(ctx context.Context, requestID string) ([]RequestAttempt, error) {
+	if ut.hotPoolEnabled && ut.hotPool != nil {
+		if req, ok := ut.hotPool.Get(requestID); ok {
+			req.mu.RLock()
+			attempts := append([]RequestAttempt(nil), req.Attempts...)
+			req.mu.RUnlock()
+			return attempts, nil
+		}
+	}
+
+	if ut.readDB == nil {
+		return nil, fmt.Errorf("read database not initialized")
+	}
+
+	rows, err := ut.readDB.QueryContext(ctx, `SELECT attempt_number,
+		COALESCE(channel, ''), COALESCE(endpoint_name, ''),
+		start_time, end_time, COALESCE(duration_ms, 0), COALESCE(http_status, 0),
+		COALESCE(error_type, ''), COALESCE(error_message, ''), COALESCE(bytes_received, 0),
+		decision, COALESCE(decision_reason, ''),
+		COALESCE(error_signature, ''), COALESCE(error_fingerprint, '')
+		FROM request_attempts WHERE request_id = ?
+		ORDER BY attempt_number, id`, requestID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query request attempts: %w", err)
+	}
+	defer rows.Close()
+
+	var attempts []RequestAttempt
+	for rows.Next() {
+		attempt := RequestAttempt{RequestID: requestID}
+		var endTime *time.Time
+		if err := rows.Scan(&attempt.AttemptNumber, &attempt.Channel, &attempt.EndpointName,
+			&attempt.StartTime, &endTime, &attempt.DurationMs, &attempt.HTTPStatus,
+			&attempt.ErrorType, &attempt.ErrorMessage, &attempt.BytesReceived,
+			&attempt.Decision, &attempt.DecisionReason,
+			&attempt.ErrorSignature, &attempt.Fingerprint); err != nil {
+			return nil, fmt.Errorf("failed to scan request attempt: %w", err)
+		}
+		if endTime != nil {
+			attempt.EndTime = *endTime
+		}
+		attempts = append(attempts, attempt)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating request attempts: %w", err)
+	}
+	return attempts, nil
+}
+
+// truncateAttemptMessage 截断上游错误摘要，避免大响应体撑大数据库
+func truncateAttemptMessage(message string) string {
+	if utf8.RuneCountInString(message) <= maxAttemptErrorMessageLen {
+		return message
+	}
+	runes := []rune(message)
+	return string(runes[:maxAttemptErrorMessageLen]) + "..."
+}
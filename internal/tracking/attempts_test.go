@@ -0,0 +1,110 @@
+package tracking
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestRequestAttempts 测试上游尝试轨迹在热池中的记录、随请求归档与传统模式写入
+func TestRequestAttempts(t *testing.T) {
+	config := &Config{
+		Enabled:         true,
+		DatabasePath:    ":memory:",
+		BufferSize:      100,
+		BatchSize:       10,
+		FlushInterval:   50 * time.Millisecond,
+		MaxRetry:        3,
+		CleanupInterval: 24 * time.Hour,
+		RetentionDays:   30,
+	}
+	tracker, err := NewUsageTracker(config)
+	if err != nil {
+		t.Fatalf("Failed to create usage tracker: %v", err)
+	}
+	defer tracker.Close()
+
+	ctx := context.Background()
+	start := tracker.now().Add(-time.Minute).Truncate(time.Second)
+	tracker.RecordRequestStart("req-attempts", "127.0.0.1", "test", "POST", "/v1/messages", true)
+	tracker.RecordRequestAttempt("req-attempts", RequestAttempt{
+		AttemptNumber:  1,
+		Channel:        "relay",
+		EndpointName:   "ep-a",
+		StartTime:      start,
+		EndTime:        start.Add(1500 * time.Millisecond),
+		HTTPStatus:     529,
+		ErrorType:      "overloaded",
+		ErrorMessage:   strings.Repeat("x", maxAttemptErrorMessageLen+100),
+		BytesReceived:  120,
+		Decision:       AttemptDecisionSwitch,
+		DecisionReason: "上游过载，切换端点",
+	})
+	tracker.RecordRequestAttempt("req-attempts", RequestAttempt{
+		AttemptNumber: 2,
+		Channel:       "relay",
+		EndpointName:  "ep-b",
+		StartTime:     start.Add(2 * time.Second),
+		EndTime:       start.Add(5 * time.Second),
+		HTTPStatus:    200,
+		BytesReceived: 4096,
+		Decision:      AttemptDecisionSuccess,
+	})
+
+	// 进行中的请求从热池读取
+	attempts, err := tracker.QueryRequestAttempts(ctx, "req-attempts")
+	if err != nil {
+		t.Fatalf("查询热池尝试轨迹失败: %v", err)
+	}
+	if len(attempts) != 2 || attempts[0].DurationMs != 1500 || attempts[1].EndpointName != "ep-b" {
+		t.Fatalf("热池尝试轨迹不符: %+v", attempts)
+	}
+	if got := len([]rune(attempts[0].ErrorMessage)); got != maxAttemptErrorMessageLen+3 {
+		t.Errorf("错误摘要应被截断: len=%d", got)
+	}
+
+	// 归档后从数据库读取
+	req := tracker.hotPool.Remove("req-attempts")
+	if req == nil {
+		t.Fatal("热池中未找到请求")
+	}
+	req.Status = "completed"
+	if err := tracker.archiveManager.batchInsert([]*ArchiveEvent{{Request: req}}); err != nil {
+		t.Fatalf("归档失败: %v", err)
+	}
+	attempts, err = tracker.QueryRequestAttempts(ctx, "req-attempts")
+	if err != nil {
+		t.Fatalf("查询归档尝试轨迹失败: %v", err)
+	}
+	if len(attempts) != 2 {
+		t.Fatalf("归档尝试轨迹应有 2 条, got %d", len(attempts))
+	}
+	first := attempts[0]
+	if first.AttemptNumber != 1 || first.Channel != "relay" || first.EndpointName != "ep-a" ||
+		first.HTTPStatus != 529 || first.ErrorType != "overloaded" || first.BytesReceived != 120 ||
+		first.Decision != AttemptDecisionSwitch || first.DecisionReason == "" || first.DurationMs != 1500 {
+		t.Errorf("归档尝试轨迹不符: %+v", first)
+	}
+	if first.StartTime.Format("2006-01-02 15:04:05") != tracker.formatStartTimeQueryBound(start) || first.EndTime.IsZero() {
+		t.Errorf("尝试时间不符: start=%v end=%v", first.StartTime, first.EndTime)
+	}
+
+	// 传统模式：通过事件写入查询追加
+	query, args, err := tracker.buildWriteQuery(RequestEvent{Type: "attempt", RequestID: "req-legacy", Data: RequestAttempt{
+		AttemptNumber: 1,
+		StartTime:     start,
+		ErrorType:     "network_error",
+		Decision:      AttemptDecisionFail,
+	}})
+	if err != nil {
+		t.Fatalf("构建尝试写入查询失败: %v", err)
+	}
+	if _, err := tracker.writeDB.ExecContext(ctx, query, args...); err != nil {
+		t.Fatalf("写入尝试失败: %v", err)
+	}
+	attempts, err = tracker.QueryRequestAttempts(ctx, "req-legacy")
+	if err != nil || len(attempts) != 1 || attempts[0].HTTPStatus != 0 || !attempts[0].EndTime.IsZero() {
+		t.Errorf("传统模式尝试轨迹不符: %+v err=%v", attempts, err)
+	}
+}
@@ -0,0 +1,219 @@
+package tracking
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// DefaultBatchCostMultiplier Message Batches 默认计费倍率（标准价格的 50%）
+const DefaultBatchCostMultiplier = 0.5
+
+// BatchUsage 批处理结果中单个模型的用量
+// 每条结果单独计费（长上下文档位按单条请求判定），汇总后写入一条记录
+type BatchUsage struct {
+	BatchID      string
+	Channel      string
+	EndpointName string
+	ModelName    string
+	SubmittedAt  time.Time    // 批处理创建时间：计价版本、汇率与 start_time 均以此为准（为零时使用当前时间）
+	Tags         []RequestTag // 创建请求的标签，写入 request_tags
+	Items        []TokenUsage // 每条成功结果的用量
+}
+
+// batchSubmittedAt 返回批处理用量的计价时间（配置时区）
+func (ut *UsageTracker) batchSubmittedAt(usage *BatchUsage) time.Time {
+	if usage.SubmittedAt.IsZero() {
+		return ut.now()
+	}
+	if ut.location != nil {
+		return usage.SubmittedAt.In(ut.location)
+	}
+	return usage.SubmittedAt
+}
+
+// Total 返回所有结果的用量合计
+func (b *BatchUsage) Total() TokenUsage {
+	var total TokenUsage
+	for _, item := range b.Items {
+		total.InputTokens += item.InputTokens
+		total.OutputTokens += item.OutputTokens
+		total.CacheCreationTokens += item.CacheCreationTokens
+		total.CacheCreation5mTokens += item.CacheCreation5mTokens
+		total.CacheCreation1hTokens += item.CacheCreation1hTokens
+		total.CacheReadTokens += item.CacheReadTokens
+		total.WebSearchRequests += item.WebSearchRequests
+		total.WebFetchRequests += item.WebFetchRequests
+	}
+	return total
+}
+
+// BatchRequestID 返回批处理用量在 request_logs 中的请求 ID（批处理 ID + 模型）
+func BatchRequestID(batchID, modelName string) string {
+	return batchID + ":" + modelName
+}
+
+// Scale 按倍率缩放各项成本（用于批处理折扣等整体计费调整）
+func (c CostBreakdown) Scale(factor float64) CostBreakdown {
+	c.InputCost *= factor
+	c.OutputCost *= factor
+	c.CacheCreationCost *= factor
+	c.CacheCreation5mCost *= factor
+	c.CacheCreation1hCost *= factor
+	c.CacheReadCost *= factor
+	c.WebSearchCost *= factor
+	c.WebFetchCost *= factor
+	c.ServerToolCost *= factor
+	c.RequestFee *= factor
+	c.TotalCost *= factor
+	return c
+}
+
+// add 累加成本（档位取最高值，价格来源取首个）
+func (c CostBreakdown) add(other CostBreakdown) CostBreakdown {
+	c.InputCost += other.InputCost
+	c.OutputCost += other.OutputCost
+	c.CacheCreationCost += other.CacheCreationCost
+	c.CacheCreation5mCost += other.CacheCreation5mCost
+	c.CacheCreation1hCost += other.CacheCreation1hCost
+	c.CacheReadCost += other.CacheReadCost
+	c.WebSearchCost += other.WebSearchCost
+	c.WebFetchCost += other.WebFetchCost
+	c.ServerToolCost += other.ServerToolCost
+	c.RequestFee += other.RequestFee
+	c.TotalCost += other.TotalCost
+	if other.PricingTier > c.PricingTier {
+		c.PricingTier = other.PricingTier
+	}
+	if c.PriceSource == "" {
+		c.PriceSource = other.PriceSource
+	}
+	return c
+}
+
+// CalculateBatchCost 计算批处理用量成本：逐条标准成本（价目表或模型定价 × 端点倍率）之和 * 批处理计费倍率
+// 按批处理创建时生效的定价版本计价
+func (ut *UsageTracker) CalculateBatchCost(usage BatchUsage, batchMultiplier float64) CostBreakdown {
+	if batchMultiplier <= 0 {
+		batchMultiplier = DefaultBatchCostMultiplier
+	}
+
+	at := ut.batchSubmittedAt(&usage)
+	var total CostBreakdown
+	for i := range usage.Items {
+		total = total.add(ut.CalculateRequestCost(usage.Channel, "", usage.EndpointName, usage.ModelName, &usage.Items[i], at))
+	}
+	return total.Scale(batchMultiplier)
+}
+
+// RecordBatchUsage 将批处理结果用量写入 request_logs（每个批处理 + 模型一条记录，is_batch=1），标签写入 request_tags
+// start_time 与汇率取批处理创建时间，用量计入提交时所在的日期与预算窗口
+// 同一请求 ID 已存在时忽略，避免重复拉取结果导致重复计费
+func (ut *UsageTracker) RecordBatchUsage(usage BatchUsage, batchMultiplier float64) error {
+	if ut.config == nil || !ut.config.Enabled || ut.writeQueue == nil {
+		return nil
+	}
+
+	cost := ut.CalculateBatchCost(usage, batchMultiplier)
+	at := ut.batchSubmittedAt(&usage)
+	timestamp := at.Format("2006-01-02 15:04:05")
+	amounts := ut.currencyAccounting().Amounts(usage.Channel, cost.TotalCost, at)
+	tokens := usage.Total()
+	cacheCreationTokens := tokens.CacheCreationTokens
+	if cacheCreationTokens == 0 {
+		cacheCreationTokens = tokens.CacheCreation5mTokens + tokens.CacheCreation1hTokens
+	}
+
+	query := `INSERT OR IGNORE INTO request_logs (
+		request_id, method, path,
+		start_time, end_time, duration_ms,
+		channel, endpoint_name, model_name,
+		status, http_status_code, retry_count,
+		is_streaming, is_batch,
+		input_tokens, output_tokens,
+		cache_creation_tokens, cache_creation_5m_tokens, cache_creation_1h_tokens,
+		cache_read_tokens, web_search_requests, web_fetch_requests,
+		input_cost_usd, output_cost_usd,
+		cache_creation_cost_usd, cache_creation_5m_cost_usd, cache_creation_1h_cost_usd,
+		cache_read_cost_usd, server_tool_cost_usd, total_cost_usd,
+		pricing_tier,
+		billing_currency, billing_cost, reporting_currency, reporting_cost,
+		price_source, request_fee_usd
+	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	args := []interface{}{
+		BatchRequestID(usage.BatchID, usage.ModelName), "GET", "/v1/messages/batches/" + usage.BatchID + "/results",
+		timestamp, timestamp, 0,
+		usage.Channel, usage.EndpointName, usage.ModelName,
+		"completed", 200, 0,
+		false, true,
+		tokens.InputTokens, tokens.OutputTokens,
+		cacheCreationTokens, tokens.CacheCreation5mTokens, tokens.CacheCreation1hTokens,
+		tokens.CacheReadTokens, tokens.WebSearchRequests, tokens.WebFetchRequests,
+		cost.InputCost, cost.OutputCost,
+		cost.CacheCreationCost, cost.CacheCreation5mCost, cost.CacheCreation1hCost,
+		cost.CacheReadCost, cost.ServerToolCost, cost.TotalCost,
+		cost.PricingTier,
+		amounts.BillingCurrency, nullableFloat(amounts.BillingCost),
+		amounts.ReportingCurrency, nullableFloat(amounts.ReportingCost),
+		nullString(cost.PriceSource), cost.RequestFee,
+	}
+
+	writeReq := WriteRequest{
+		Query:     query,
+		Args:      args,
+		Response:  make(chan error, 1),
+		Context:   context.Background(),
+		EventType: "batch_usage",
+	}
+
+	select {
+	case ut.writeQueue <- writeReq:
+		if err := <-writeReq.Response; err != nil {
+			return fmt.Errorf("failed to record batch usage: %w", err)
+		}
+	case <-ut.ctx.Done():
+		return ut.ctx.Err()
+	case <-time.After(30 * time.Second):
+		return fmt.Errorf("write queue full, batch usage dropped: %s", usage.BatchID)
+	}
+
+	// 标签写入失败时返回错误，下次拉取结果时重试（两条写入均按请求 ID 去重）
+	if len(usage.Tags) > 0 {
+		tagsQuery, tagsArgs, err := ut.buildTagsQuery(RequestEvent{
+			Type:      "tags",
+			RequestID: BatchRequestID(usage.BatchID, usage.ModelName),
+			Data:      usage.Tags,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to build batch tags: %w", err)
+		}
+		tagsWriteReq := WriteRequest{
+			Query:     tagsQuery,
+			Args:      tagsArgs,
+			Response:  make(chan error, 1),
+			Context:   context.Background(),
+			EventType: "batch_tags",
+		}
+		select {
+		case ut.writeQueue <- tagsWriteReq:
+			if err := <-tagsWriteReq.Response; err != nil {
+				return fmt.Errorf("failed to record batch tags: %w", err)
+			}
+		case <-ut.ctx.Done():
+			return ut.ctx.Err()
+		case <-time.After(30 * time.Second):
+			return fmt.Errorf("write queue full, batch tags dropped: %s", usage.BatchID)
+		}
+	}
+
+	// 批处理用量不经过归档批次，重建提交当日的汇总
+	if err := ut.RebuildUsageRollups(ut.ctx, at, at.Add(time.Nanosecond)); err != nil {
+		slog.Warn("⚠️ [用量汇总] 批处理用量写入后重建汇总失败", "error", err)
+	}
+
+	slog.Info(fmt.Sprintf("📦 [批处理计费] 批处理: %s, 模型: %s, 成功: %d, 输入: %d, 输出: %d, 成本: $%.6f",
+		usage.BatchID, usage.ModelName, len(usage.Items), tokens.InputTokens, tokens.OutputTokens, cost.TotalCost))
+	return nil
+}
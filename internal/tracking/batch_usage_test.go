@@ -0,0 +1,83 @@
+package tracking
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// TestCalculateBatchCost 测试批处理按单条结果计费并应用批处理倍率
+func TestCalculateBatchCost(t *testing.T) {
+	ut := &UsageTracker{
+		config: &Config{},
+		pricing: map[string]ModelPricing{
+			"claude-sonnet-4-5": *sonnetPricingWithTiers(),
+		},
+		endpointMu: map[string]EndpointMultiplier{
+			EndpointMultiplierKey("A", "ep1"): {CostMultiplier: 2.0},
+		},
+	}
+
+	// 两条各 150K 输入：逐条计费均低于 200K 长上下文阈值
+	usage := BatchUsage{
+		Channel:      "A",
+		EndpointName: "ep1",
+		ModelName:    "claude-sonnet-4-5",
+		Items: []TokenUsage{
+			{InputTokens: 150_000, OutputTokens: 1_000},
+			{InputTokens: 150_000, OutputTokens: 1_000},
+		},
+	}
+
+	cost := ut.CalculateBatchCost(usage, 0.5)
+	if cost.PricingTier != 0 {
+		t.Errorf("PricingTier = %d, want 0（按单条判定档位）", cost.PricingTier)
+	}
+	// Input: 300K * $3/1M * 2.0 (端点) * 0.5 (批处理) = $0.90
+	if math.Abs(cost.InputCost-0.90) > 0.0001 {
+		t.Errorf("InputCost = %f, want 0.90", cost.InputCost)
+	}
+	// Output: 2K * $15/1M * 2.0 * 0.5 = $0.03
+	if math.Abs(cost.OutputCost-0.03) > 0.0001 {
+		t.Errorf("OutputCost = %f, want 0.03", cost.OutputCost)
+	}
+	if math.Abs(cost.TotalCost-0.93) > 0.0001 {
+		t.Errorf("TotalCost = %f, want 0.93", cost.TotalCost)
+	}
+
+	// 倍率无效时使用默认 50%
+	if defaultCost := ut.CalculateBatchCost(usage, 0); math.Abs(defaultCost.TotalCost-cost.TotalCost) > 0.0001 {
+		t.Errorf("默认倍率成本 = %f, want %f", defaultCost.TotalCost, cost.TotalCost)
+	}
+}
+
+// TestCalculateBatchCost_SubmittedAt 测试批处理按创建时生效的定价版本计价
+func TestCalculateBatchCost_SubmittedAt(t *testing.T) {
+	repriced := time.Now().Add(-time.Hour)
+	ut := &UsageTracker{
+		config: &Config{},
+		pricing: map[string]ModelPricing{
+			"claude-sonnet-4-5": {Input: 6, Output: 30},
+		},
+		pricingHistory: NewPricingHistory([]PricingVersion{
+			{Model: "claude-sonnet-4-5", EffectiveFrom: repriced.Add(-24 * time.Hour), Pricing: ModelPricing{Input: 3, Output: 15}},
+			{Model: "claude-sonnet-4-5", EffectiveFrom: repriced, Pricing: ModelPricing{Input: 6, Output: 30}},
+		}),
+	}
+
+	usage := BatchUsage{
+		ModelName:   "claude-sonnet-4-5",
+		SubmittedAt: repriced.Add(-time.Minute),
+		Items:       []TokenUsage{{InputTokens: 1_000_000}},
+	}
+	// 提交时旧价格生效: 1M * $3/1M * 0.5 = $1.50
+	if cost := ut.CalculateBatchCost(usage, 0.5); math.Abs(cost.TotalCost-1.5) > 0.0001 {
+		t.Errorf("TotalCost = %f, want 1.50（按提交时定价）", cost.TotalCost)
+	}
+
+	// 未知提交时间时按当前定价: 1M * $6/1M * 0.5 = $3.00
+	usage.SubmittedAt = time.Time{}
+	if cost := ut.CalculateBatchCost(usage, 0.5); math.Abs(cost.TotalCost-3) > 0.0001 {
+		t.Errorf("TotalCost = %f, want 3.00（按当前定价）", cost.TotalCost)
+	}
+}
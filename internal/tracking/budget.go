@@ -0,0 +1,525 @@
+package tracking
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 预算作用范围
+const (
+	BudgetScopeGlobal   = "global"   // 全部请求
+	BudgetScopeChannel  = "channel"  // 单个渠道
+	BudgetScopeEndpoint = "endpoint" // 渠道内单个端点
+	BudgetScopeModel    = "model"    // 单个模型
+)
+
+// 预算统计窗口（按配置时区的自然日/周/月）
+const (
+	BudgetWindowDaily   = "daily"
+	BudgetWindowWeekly  = "weekly" // 周一 00:00 起
+	BudgetWindowMonthly = "monthly"
+)
+
+// 超出预算后的动作
+const (
+	BudgetActionAlert  = "alert"  // 仅告警，不限制请求
+	BudgetActionReject = "reject" // 拒绝请求（Anthropic 格式错误），窗口重置后自动恢复
+	BudgetActionDivert = "divert" // 分流到更便宜的渠道（DivertChannel，为空时选择该模型有效价格最低的可用渠道）
+	BudgetActionManual = "manual" // 拒绝请求，直到手动放行（窗口重置后仍保持阻断）
+)
+
+// 预算事件类型
+const (
+	BudgetEventAlert   = "alert"   // 花费达到告警阈值
+	BudgetEventTripped = "tripped" // 超出预算，硬限制生效
+)
+
+// budgetCheckInterval 预算定期评估间隔
+const budgetCheckInterval = 15 * time.Second
+
+// Budget 花费预算（按 USD 计）
+type Budget struct {
+	Name         string
+	Scope        string
+	Channel      string // channel / endpoint 作用范围
+	EndpointName string // endpoint 作用范围
+	Model        string // model 作用范围
+	Window       string
+	LimitUSD     float64
+
+	// 告警阈值（占预算的百分比，如 50, 80, 100），每个窗口每个阈值只告警一次
+	AlertThresholds []float64
+
+	Action        string
+	DivertChannel string // divert 动作的目标渠道
+
+	// 持久化状态
+	TrippedAt     time.Time // manual 动作：超出预算的时间（手动放行前保持阻断）
+	OverrideUntil time.Time // 手动放行截止时间（通常为当前窗口结束）
+}
+
+// BudgetStatus 预算当前状态
+type BudgetStatus struct {
+	Name          string    `json:"name"`
+	Scope         string    `json:"scope"`
+	Channel       string    `json:"channel,omitempty"`
+	EndpointName  string    `json:"endpoint_name,omitempty"`
+	Model         string    `json:"model,omitempty"`
+	Window        string    `json:"window"`
+	Action        string    `json:"action"`
+	DivertChannel string    `json:"divert_channel,omitempty"`
+	DivertedTo    string    `json:"diverted_to,omitempty"` // divert 动作实际分流到的渠道（未配置 DivertChannel 时由比价选出）
+	LimitUSD      float64   `json:"limit_usd"`
+	SpentUSD      float64   `json:"spent_usd"`     // 已归档花费 + 进行中请求花费
+	InFlightUSD   float64   `json:"in_flight_usd"` // 热池中进行中请求的花费
+	Percent       float64   `json:"percent"`
+	AlertLevel    float64   `json:"alert_level"` // 已达到的最高告警阈值（0 表示未达到）
+	WindowStart   time.Time `json:"window_start"`
+	WindowEnd     time.Time `json:"window_end"`
+	Exceeded      bool      `json:"exceeded"`
+	Blocked       bool      `json:"blocked"` // 硬限制生效中
+	Overridden    bool      `json:"overridden"`
+	TrippedAt     time.Time `json:"tripped_at,omitempty"`
+}
+
+// BudgetEvent 预算告警/超限事件
+type BudgetEvent struct {
+	Type      string       // BudgetEventAlert / BudgetEventTripped
+	Threshold float64      // 告警阈值（百分比）
+	Status    BudgetStatus // 触发时的预算状态
+}
+
+// budgetState 预算运行时状态（预算配置 + 最近一次评估结果）
+type budgetState struct {
+	mu       sync.RWMutex
+	budgets  []Budget
+	statuses []BudgetStatus
+	alerted  map[string]budgetAlertMark // key: 预算名称
+	tripped  map[string]time.Time       // 本次运行中触发但尚未持久化的 manual 阻断
+	diverted map[string]string          // divert 动作实际分流到的渠道（key: 预算名称），阻断解除时清除
+	onEvent  func(BudgetEvent)
+}
+
+// budgetAlertMark 当前窗口已告警的最高阈值
+type budgetAlertMark struct {
+	windowStart time.Time
+	level       float64
+}
+
+// Matches 预算是否覆盖指定请求（channel/endpoint/model 为空时不匹配对应作用范围）
+func (b *Budget) Matches(channel, endpointName, model string) bool {
+	switch b.Scope {
+	case BudgetScopeGlobal:
+		return true
+	case BudgetScopeChannel:
+		return channel != "" && channel == b.Channel
+	case BudgetScopeEndpoint:
+		return channel == b.Channel && endpointName != "" && endpointName == b.EndpointName
+	case BudgetScopeModel:
+		return model != "" && model == b.Model
+	}
+	return false
+}
+
+// BudgetWindow 返回 at 所在统计窗口的 [start, end)
+func BudgetWindow(window string, at time.Time) (time.Time, time.Time) {
+	loc := at.Location()
+	day := time.Date(at.Year(), at.Month(), at.Day(), 0, 0, 0, 0, loc)
+	switch window {
+	case BudgetWindowWeekly:
+		offset := (int(day.Weekday()) + 6) % 7 // 周一为 0
+		start := day.AddDate(0, 0, -offset)
+		return start, start.AddDate(0, 0, 7)
+	case BudgetWindowMonthly:
+		start := time.Date(at.Year(), at.Month(), 1, 0, 0, 0, 0, loc)
+		return start, start.AddDate(0, 1, 0)
+	default:
+		return day, day.AddDate(0, 0, 1)
+	}
+}
+
+// CurrentBudgetWindow 返回当前时间（配置时区）所在统计窗口的 [start, end)
+func (ut *UsageTracker) CurrentBudgetWindow(window string) (time.Time, time.Time) {
+	return BudgetWindow(window, ut.now())
+}
+
+// UpdateBudgets 更新预算配置（运行时动态更新），并立即重新评估
+func (ut *UsageTracker) UpdateBudgets(budgets []Budget) {
+	state := ut.budgetRuntime()
+	state.mu.Lock()
+	state.budgets = budgets
+	for name := range state.tripped {
+		// 已持久化（TrippedAt）或已手动放行的阻断不再由内存状态维持
+		for _, b := range budgets {
+			if b.Name == name && (!b.TrippedAt.IsZero() || !b.OverrideUntil.IsZero()) {
+				delete(state.tripped, name)
+			}
+		}
+	}
+	state.mu.Unlock()
+
+	slog.Info("Budgets updated", "budget_count", len(budgets))
+
+	if ut.readDB != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if _, err := ut.EvaluateBudgets(ctx); err != nil {
+			slog.Warn("⚠️ [预算] 评估预算失败", "error", err)
+		}
+	}
+}
+
+// SetBudgetEventHandler 设置预算告警/超限事件回调（在评估协程中同步调用）
+func (ut *UsageTracker) SetBudgetEventHandler(handler func(BudgetEvent)) {
+	state := ut.budgetRuntime()
+	state.mu.Lock()
+	state.onEvent = handler
+	state.mu.Unlock()
+}
+
+// BudgetStatuses 返回最近一次评估的预算状态
+func (ut *UsageTracker) BudgetStatuses() []BudgetStatus {
+	if ut == nil {
+		return nil
+	}
+	state := ut.budgetRuntime()
+	state.mu.RLock()
+	defer state.mu.RUnlock()
+
+	statuses := make([]BudgetStatus, len(state.statuses))
+	copy(statuses, state.statuses)
+	return statuses
+}
+
+// RecordBudgetDivert 记录 divert 动作实际分流到的渠道，CheckBudget 放行路由到该渠道的请求
+func (ut *UsageTracker) RecordBudgetDivert(name, channel string) {
+	if ut == nil || channel == "" {
+		return
+	}
+	state := ut.budgetRuntime()
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	state.diverted[name] = channel
+	for i := range state.statuses {
+		if state.statuses[i].Name == name {
+			state.statuses[i].DivertedTo = channel
+		}
+	}
+}
+
+// CheckBudget 返回请求命中的第一个阻断中的预算（nil 表示放行）
+// divert 动作的预算不阻断已路由到其目标渠道（配置的 DivertChannel 或实际分流到的渠道）的请求
+func (ut *UsageTracker) CheckBudget(channel, endpointName, model string) *BudgetStatus {
+	if ut == nil {
+		return nil
+	}
+	state := ut.budgetRuntime()
+	state.mu.RLock()
+	defer state.mu.RUnlock()
+
+	for i := range state.statuses {
+		status := &state.statuses[i]
+		if !status.Blocked {
+			continue
+		}
+		budget := Budget{Scope: status.Scope, Channel: status.Channel, EndpointName: status.EndpointName, Model: status.Model}
+		if !budget.Matches(channel, endpointName, model) {
+			continue
+		}
+		if status.Action == BudgetActionDivert && channel != "" &&
+			(channel == status.DivertChannel || channel == status.DivertedTo) {
+			continue
+		}
+		result := *status
+		return &result
+	}
+	return nil
+}
+
+// ChannelBlocked 渠道是否被渠道级预算阻断（故障转移时跳过该渠道）
+func (ut *UsageTracker) ChannelBlocked(channel string) bool {
+	status := ut.CheckBudget(channel, "", "")
+	return status != nil && status.Scope == BudgetScopeChannel
+}
+
+// EndpointBlocked 端点是否被端点级预算阻断（路由时跳过该端点）
+func (ut *UsageTracker) EndpointBlocked(channel, endpointName string) bool {
+	if ut == nil {
+		return false
+	}
+	state := ut.budgetRuntime()
+	state.mu.RLock()
+	defer state.mu.RUnlock()
+
+	for _, status := range state.statuses {
+		if status.Blocked && status.Scope == BudgetScopeEndpoint &&
+			status.Channel == channel && status.EndpointName == endpointName {
+			return true
+		}
+	}
+	return false
+}
+
+// EvaluateBudgets 按 request_logs + 热池进行中请求计算各预算花费，触发阈值告警与硬限制
+func (ut *UsageTracker) EvaluateBudgets(ctx context.Context) ([]BudgetStatus, error) {
+	if ut.readDB == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+
+	state := ut.budgetRuntime()
+	state.mu.RLock()
+	budgets := make([]Budget, len(state.budgets))
+	copy(budgets, state.budgets)
+	state.mu.RUnlock()
+
+	now := ut.now()
+	active := ut.GetActiveRequests()
+	statuses := make([]BudgetStatus, 0, len(budgets))
+
+	for _, b := range budgets {
+		start, end := BudgetWindow(b.Window, now)
+		spent, err := ut.budgetArchivedSpend(ctx, &b, start, end)
+		if err != nil {
+			return nil, err
+		}
+
+		var inFlight float64
+		for _, req := range active {
+			req.mu.RLock()
+			startTime := req.StartTime
+			channel, groupName, endpointName, model := req.Channel, req.GroupName, req.EndpointName, req.ModelName
+			usage := TokenUsage{
+				InputTokens:           req.InputTokens,
+				OutputTokens:          req.OutputTokens,
+				CacheCreationTokens:   req.CacheCreationTokens,
+				CacheCreation5mTokens: req.CacheCreation5mTokens,
+				CacheCreation1hTokens: req.CacheCreation1hTokens,
+				CacheReadTokens:       req.CacheReadTokens,
+				WebSearchRequests:     req.WebSearchRequests,
+				WebFetchRequests:      req.WebFetchRequests,
+			}
+			req.mu.RUnlock()
+
+			if startTime.Before(start) || !startTime.Before(end) || !b.Matches(channel, endpointName, model) {
+				continue
+			}
+			inFlight += ut.CalculateRequestCost(channel, groupName, endpointName, model, &usage, startTime).TotalCost
+		}
+
+		status := BudgetStatus{
+			Name:          b.Name,
+			Scope:         b.Scope,
+			Channel:       b.Channel,
+			EndpointName:  b.EndpointName,
+			Model:         b.Model,
+			Window:        b.Window,
+			Action:        b.Action,
+			DivertChannel: b.DivertChannel,
+			LimitUSD:      b.LimitUSD,
+			SpentUSD:      spent + inFlight,
+			InFlightUSD:   inFlight,
+			WindowStart:   start,
+			WindowEnd:     end,
+			TrippedAt:     b.TrippedAt,
+		}
+		if b.LimitUSD > 0 {
+			status.Percent = status.SpentUSD / b.LimitUSD * 100
+			status.Exceeded = status.SpentUSD >= b.LimitUSD
+		}
+		for _, threshold := range b.AlertThresholds {
+			if threshold > 0 && status.Percent >= threshold && threshold > status.AlertLevel {
+				status.AlertLevel = threshold
+			}
+		}
+		statuses = append(statuses, status)
+	}
+
+	events := ut.applyBudgetStatuses(statuses, now)
+	state.mu.RLock()
+	handler := state.onEvent
+	state.mu.RUnlock()
+	for _, event := range events {
+		if event.Type == BudgetEventTripped {
+			slog.Warn(fmt.Sprintf("🚫 [预算] %s 已超出预算: $%.4f / $%.4f (%s)，动作: %s",
+				event.Status.Name, event.Status.SpentUSD, event.Status.LimitUSD, event.Status.Window, event.Status.Action))
+		} else {
+			slog.Warn(fmt.Sprintf("💸 [预算] %s 花费达到 %.0f%%: $%.4f / $%.4f (%s)",
+				event.Status.Name, event.Threshold, event.Status.SpentUSD, event.Status.LimitUSD, event.Status.Window))
+		}
+		if handler != nil {
+			handler(event)
+		}
+	}
+
+	return ut.BudgetStatuses(), nil
+}
+
+// applyBudgetStatuses 计算阻断状态并保存评估结果，返回需要通知的事件
+func (ut *UsageTracker) applyBudgetStatuses(statuses []BudgetStatus, now time.Time) []BudgetEvent {
+	state := ut.budgetRuntime()
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	overrides := make(map[string]time.Time, len(state.budgets))
+	for _, b := range state.budgets {
+		overrides[b.Name] = b.OverrideUntil
+	}
+
+	var events []BudgetEvent
+	for i := range statuses {
+		status := &statuses[i]
+		if trippedAt, ok := state.tripped[status.Name]; ok && status.TrippedAt.IsZero() {
+			status.TrippedAt = trippedAt
+		}
+		status.Overridden = now.Before(overrides[status.Name])
+
+		newlyTripped := false
+		switch status.Action {
+		case BudgetActionReject, BudgetActionDivert:
+			status.Blocked = status.Exceeded && !status.Overridden
+			newlyTripped = status.Blocked
+		case BudgetActionManual:
+			if status.Exceeded && status.TrippedAt.IsZero() && !status.Overridden {
+				status.TrippedAt = now
+				state.tripped[status.Name] = now
+				newlyTripped = true
+			}
+			status.Blocked = !status.TrippedAt.IsZero() && !status.Overridden
+		}
+		if status.Blocked {
+			status.DivertedTo = state.diverted[status.Name]
+		} else {
+			delete(state.diverted, status.Name)
+		}
+
+		// 告警：每个窗口每个阈值只通知一次；超出预算（硬限制生效）单独通知一次
+		mark := state.alerted[status.Name]
+		if !mark.windowStart.Equal(status.WindowStart) {
+			mark = budgetAlertMark{windowStart: status.WindowStart}
+		}
+		if status.AlertLevel > mark.level {
+			events = append(events, BudgetEvent{Type: BudgetEventAlert, Threshold: status.AlertLevel, Status: *status})
+			mark.level = status.AlertLevel
+		}
+		if newlyTripped && mark.level < budgetTrippedLevel {
+			events = append(events, BudgetEvent{Type: BudgetEventTripped, Threshold: status.Percent, Status: *status})
+			mark.level = budgetTrippedLevel
+		}
+		state.alerted[status.Name] = mark
+	}
+
+	sort.SliceStable(statuses, func(i, j int) bool {
+		return statuses[i].Percent > statuses[j].Percent
+	})
+	state.statuses = statuses
+	return events
+}
+
+// budgetTrippedLevel 超限事件已通知的标记（高于任何百分比阈值）
+const budgetTrippedLevel = 1e12
+
+// budgetArchivedSpend 查询窗口内已写入 request_logs 的花费
+func (ut *UsageTracker) budgetArchivedSpend(ctx context.Context, b *Budget, start, end time.Time) (float64, error) {
+	where, args := ut.budgetWindowFilter(b, start, end)
+	var spent float64
+	if err := ut.readDB.QueryRowContext(ctx, `SELECT COALESCE(SUM(total_cost_usd), 0) FROM request_logs`+where, args...).Scan(&spent); err != nil {
+		return 0, fmt.Errorf("failed to query budget spend for %s: %w", b.Name, err)
+	}
+	return spent, nil
+}
+
+// budgetWindowFilter 构建预算窗口 + 作用范围的 WHERE 子句
+func (ut *UsageTracker) budgetWindowFilter(b *Budget, start, end time.Time) (string, []interface{}) {
+	where := " WHERE start_time >= ? AND start_time < ?"
+	args := []interface{}{ut.formatStartTimeQueryBound(start), ut.formatStartTimeQueryBound(end)}
+	switch b.Scope {
+	case BudgetScopeChannel:
+		where += " AND channel = ?"
+		args = append(args, b.Channel)
+	case BudgetScopeEndpoint:
+		where += " AND channel = ? AND endpoint_name = ?"
+		args = append(args, b.Channel, b.EndpointName)
+	case BudgetScopeModel:
+		where += " AND model_name = ?"
+		args = append(args, b.Model)
+	}
+	return where, args
+}
+
+// budgetReferenceUsage 比较渠道价格使用的参考用量（100 万输入 + 100 万输出 tokens）
+var budgetReferenceUsage = TokenUsage{InputTokens: 1000000, OutputTokens: 1000000}
+
+// EffectivePrice 端点处理指定模型的参考价格（USD / 100 万输入 + 100 万输出 tokens）
+// 计价规则与实际请求一致：优先渠道/端点价目表，否则按模型定价 × 端点倍率
+func (ut *UsageTracker) EffectivePrice(channel, endpointName, model string) float64 {
+	usage := budgetReferenceUsage
+	return ut.CalculateRequestCost(channel, "", endpointName, model, &usage, ut.now()).TotalCost
+}
+
+// BudgetTopModel 返回预算当前窗口内花费最高的模型（未限定模型的预算按该模型比较分流渠道价格）
+// 窗口内无记录时返回空字符串
+func (ut *UsageTracker) BudgetTopModel(ctx context.Context, status BudgetStatus) string {
+	if status.Model != "" {
+		return status.Model
+	}
+	if ut == nil || ut.readDB == nil {
+		return ""
+	}
+	b := Budget{Scope: status.Scope, Channel: status.Channel, EndpointName: status.EndpointName, Model: status.Model}
+	where, args := ut.budgetWindowFilter(&b, status.WindowStart, status.WindowEnd)
+	var model string
+	err := ut.readDB.QueryRowContext(ctx, `SELECT COALESCE(model_name, '') FROM request_logs`+where+
+		` GROUP BY model_name ORDER BY SUM(total_cost_usd) DESC LIMIT 1`, args...).Scan(&model)
+	if err != nil && err != sql.ErrNoRows {
+		slog.Debug("查询预算窗口主要模型失败", "budget", status.Name, "error", err)
+	}
+	return model
+}
+
+// budgetRuntime 返回预算运行时状态（惰性初始化）
+func (ut *UsageTracker) budgetRuntime() *budgetState {
+	ut.budgetsOnce.Do(func() {
+		ut.budgets = &budgetState{
+			alerted:  make(map[string]budgetAlertMark),
+			tripped:  make(map[string]time.Time),
+			diverted: make(map[string]string),
+		}
+	})
+	return ut.budgets
+}
+
+// periodicBudgetCheck 定期评估预算（未配置预算时跳过）
+func (ut *UsageTracker) periodicBudgetCheck() {
+	defer ut.wg.Done()
+
+	ticker := time.NewTicker(budgetCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			state := ut.budgetRuntime()
+			state.mu.RLock()
+			count := len(state.budgets)
+			state.mu.RUnlock()
+			if count == 0 {
+				continue
+			}
+
+			ctx, cancel := context.WithTimeout(ut.ctx, 10*time.Second)
+			if _, err := ut.EvaluateBudgets(ctx); err != nil {
+				slog.Warn("⚠️ [预算] 评估预算失败", "error", err)
+			}
+			cancel()
+
+		case <-ut.ctx.Done():
+			return
+		}
+	}
+}
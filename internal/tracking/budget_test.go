@@ -0,0 +1,260 @@
+package tracking
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// TestBudgetWindow 测试日/周/月统计窗口边界
+func TestBudgetWindow(t *testing.T) {
+	loc := time.FixedZone("CST", 8*3600)
+	at := time.Date(2025, 3, 13, 15, 30, 0, 0, loc) // 周四
+
+	tests := []struct {
+		window     string
+		start, end time.Time
+	}{
+		{BudgetWindowDaily, time.Date(2025, 3, 13, 0, 0, 0, 0, loc), time.Date(2025, 3, 14, 0, 0, 0, 0, loc)},
+		{BudgetWindowWeekly, time.Date(2025, 3, 10, 0, 0, 0, 0, loc), time.Date(2025, 3, 17, 0, 0, 0, 0, loc)},
+		{BudgetWindowMonthly, time.Date(2025, 3, 1, 0, 0, 0, 0, loc), time.Date(2025, 4, 1, 0, 0, 0, 0, loc)},
+	}
+	for _, tt := range tests {
+		start, end := BudgetWindow(tt.window, at)
+		if !start.Equal(tt.start) || !end.Equal(tt.end) {
+			t.Errorf("%s 窗口 = [%v, %v), want [%v, %v)", tt.window, start, end, tt.start, tt.end)
+		}
+	}
+
+	// 周日属于前一个周一开始的窗口
+	sunday := time.Date(2025, 3, 16, 23, 0, 0, 0, loc)
+	if start, _ := BudgetWindow(BudgetWindowWeekly, sunday); !start.Equal(time.Date(2025, 3, 10, 0, 0, 0, 0, loc)) {
+		t.Errorf("周日所在周窗口起点 = %v, want 2025-03-10", start)
+	}
+}
+
+// TestEvaluateBudgets 测试预算花费统计、阈值告警、divert / manual 阻断与手动放行
+func TestEvaluateBudgets(t *testing.T) {
+	config := &Config{
+		Enabled:         true,
+		DatabasePath:    ":memory:",
+		BufferSize:      100,
+		BatchSize:       10,
+		FlushInterval:   50 * time.Millisecond,
+		MaxRetry:        3,
+		CleanupInterval: 24 * time.Hour,
+		RetentionDays:   30,
+	}
+	tracker, err := NewUsageTracker(config)
+	if err != nil {
+		t.Fatalf("Failed to create usage tracker: %v", err)
+	}
+	defer tracker.Close()
+
+	ctx := context.Background()
+	now := tracker.now()
+	for i, cost := range []float64{6, 3} {
+		_, err := tracker.writeDB.ExecContext(ctx, `INSERT INTO request_logs (
+			request_id, start_time, channel, endpoint_name, model_name, status, total_cost_usd
+		) VALUES (?, ?, 'relay', 'relay-a', 'claude-sonnet-4', 'completed', ?)`,
+			"req-budget-"+string(rune('a'+i)), tracker.formatStartTimeQueryBound(now), cost)
+		if err != nil {
+			t.Fatalf("插入测试数据失败: %v", err)
+		}
+	}
+
+	var events []BudgetEvent
+	tracker.SetBudgetEventHandler(func(e BudgetEvent) { events = append(events, e) })
+	tracker.UpdateBudgets([]Budget{
+		{Name: "relay-daily", Scope: BudgetScopeChannel, Channel: "relay", Window: BudgetWindowDaily, LimitUSD: 10,
+			AlertThresholds: []float64{50, 80}, Action: BudgetActionDivert, DivertChannel: "cheap"},
+		{Name: "global-monthly", Scope: BudgetScopeGlobal, Window: BudgetWindowMonthly, LimitUSD: 8, Action: BudgetActionManual},
+		{Name: "opus", Scope: BudgetScopeModel, Model: "claude-opus-4", Window: BudgetWindowDaily, LimitUSD: 1, Action: BudgetActionReject},
+	})
+
+	statuses := tracker.BudgetStatuses()
+	if len(statuses) != 3 || statuses[0].Name != "global-monthly" {
+		t.Fatalf("预算状态应按占用百分比降序: %+v", statuses)
+	}
+	for _, st := range statuses {
+		switch st.Name {
+		case "relay-daily":
+			if st.SpentUSD != 9 || st.AlertLevel != 80 || st.Exceeded || st.Blocked {
+				t.Errorf("relay-daily 状态不符: %+v", st)
+			}
+		case "global-monthly":
+			if !st.Exceeded || !st.Blocked || st.TrippedAt.IsZero() {
+				t.Errorf("global-monthly 应已阻断: %+v", st)
+			}
+		case "opus":
+			if st.SpentUSD != 0 || st.Blocked {
+				t.Errorf("opus 不应有花费: %+v", st)
+			}
+		}
+	}
+
+	// relay-daily 只通知最高阈值 80%，global-monthly 通知一次超限
+	if len(events) != 2 {
+		t.Fatalf("事件数 = %d, want 2: %+v", len(events), events)
+	}
+	if _, err := tracker.EvaluateBudgets(ctx); err != nil {
+		t.Fatalf("评估预算失败: %v", err)
+	}
+	if len(events) != 2 {
+		t.Errorf("同一窗口不应重复通知: %+v", events)
+	}
+
+	if st := tracker.CheckBudget("cheap", "", "claude-sonnet-4"); st == nil || st.Name != "global-monthly" {
+		t.Errorf("全局 manual 预算应阻断所有渠道: %+v", st)
+	}
+
+	// 手动放行后 manual 预算不再阻断
+	overrideUntil := now.Add(time.Hour)
+	tracker.UpdateBudgets([]Budget{
+		{Name: "relay-daily", Scope: BudgetScopeChannel, Channel: "relay", Window: BudgetWindowDaily, LimitUSD: 5,
+			Action: BudgetActionDivert, DivertChannel: "cheap"},
+		{Name: "global-monthly", Scope: BudgetScopeGlobal, Window: BudgetWindowMonthly, LimitUSD: 8, Action: BudgetActionManual,
+			OverrideUntil: overrideUntil},
+	})
+	if st := tracker.CheckBudget("cheap", "", "claude-sonnet-4"); st != nil {
+		t.Errorf("放行后目标渠道不应被阻断: %+v", st)
+	}
+	if st := tracker.CheckBudget("relay", "", "claude-sonnet-4"); st == nil || st.Name != "relay-daily" || st.Action != BudgetActionDivert {
+		t.Errorf("relay 渠道应被 divert 预算阻断: %+v", st)
+	}
+	if !tracker.ChannelBlocked("relay") || tracker.ChannelBlocked("cheap") {
+		t.Error("仅 relay 渠道应被渠道预算阻断")
+	}
+	if tracker.EndpointBlocked("relay", "relay-a") {
+		t.Error("渠道预算不应标记端点阻断")
+	}
+}
+
+// TestBudgetDivertWithoutFixedTarget 测试未配置目标渠道的模型预算：放行实际分流到的渠道，阻断解除后清除
+func TestBudgetDivertWithoutFixedTarget(t *testing.T) {
+	tracker, err := NewUsageTracker(&Config{
+		Enabled:         true,
+		DatabasePath:    ":memory:",
+		BufferSize:      100,
+		BatchSize:       10,
+		FlushInterval:   50 * time.Millisecond,
+		MaxRetry:        3,
+		CleanupInterval: 24 * time.Hour,
+		RetentionDays:   30,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create usage tracker: %v", err)
+	}
+	defer tracker.Close()
+
+	ctx := context.Background()
+	_, err = tracker.writeDB.ExecContext(ctx, `INSERT INTO request_logs (
+		request_id, start_time, channel, endpoint_name, model_name, status, total_cost_usd
+	) VALUES ('req-opus', ?, 'relay', 'relay-a', 'claude-opus-4', 'completed', 5)`, tracker.formatStartTimeQueryBound(tracker.now()))
+	if err != nil {
+		t.Fatalf("插入测试数据失败: %v", err)
+	}
+
+	// 模拟 App 层：超限事件触发分流并记录比价选出的渠道
+	tracker.SetBudgetEventHandler(func(e BudgetEvent) {
+		if e.Type == BudgetEventTripped {
+			tracker.RecordBudgetDivert(e.Status.Name, "cheap")
+		}
+	})
+	opus := Budget{Name: "opus", Scope: BudgetScopeModel, Model: "claude-opus-4", Window: BudgetWindowDaily, LimitUSD: 1, Action: BudgetActionDivert}
+	tracker.UpdateBudgets([]Budget{opus})
+
+	if st := tracker.CheckBudget("cheap", "cheap-a", "claude-opus-4"); st != nil {
+		t.Errorf("分流到的渠道不应被模型预算阻断: %+v", st)
+	}
+	if st := tracker.CheckBudget("relay", "relay-a", "claude-opus-4"); st == nil || st.Name != "opus" || st.DivertedTo != "cheap" {
+		t.Errorf("原渠道应继续被阻断并标记分流目标: %+v", st)
+	}
+
+	// 再次评估保持分流目标
+	if _, err := tracker.EvaluateBudgets(ctx); err != nil {
+		t.Fatalf("评估预算失败: %v", err)
+	}
+	if st := tracker.CheckBudget("cheap", "cheap-a", "claude-opus-4"); st != nil {
+		t.Errorf("再次评估后分流目标应仍被放行: %+v", st)
+	}
+
+	// 阻断解除后清除分流目标
+	tracker.SetBudgetEventHandler(nil)
+	opus.LimitUSD = 100
+	tracker.UpdateBudgets([]Budget{opus})
+	opus.LimitUSD = 1
+	tracker.UpdateBudgets([]Budget{opus})
+	if st := tracker.CheckBudget("cheap", "cheap-a", "claude-opus-4"); st == nil || st.DivertedTo != "" {
+		t.Errorf("阻断解除后应清除分流目标: %+v", st)
+	}
+}
+
+// TestBudgetDivertPricing 测试分流渠道比价：有效价格按价目表/端点倍率计算，未限定模型的预算按窗口内花费最高的模型比价
+func TestBudgetDivertPricing(t *testing.T) {
+	tracker, err := NewUsageTracker(&Config{
+		Enabled:         true,
+		DatabasePath:    ":memory:",
+		BufferSize:      100,
+		BatchSize:       10,
+		FlushInterval:   50 * time.Millisecond,
+		MaxRetry:        3,
+		CleanupInterval: 24 * time.Hour,
+		RetentionDays:   30,
+		ModelPricing: map[string]ModelPricing{
+			"claude-sonnet-4": {Input: 3, Output: 15},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create usage tracker: %v", err)
+	}
+	defer tracker.Close()
+
+	tracker.UpdateEndpointMultipliers(map[string]EndpointMultiplier{
+		EndpointMultiplierKey("relay", "relay-a"): {CostMultiplier: 0.5},
+	})
+	tracker.UpdatePriceBooks([]PriceBook{{
+		Name:    "cheap-book",
+		Channel: "cheap",
+		Mode:    PriceBookModeAbsolute,
+		Entries: []PriceBookEntry{{Model: "claude-sonnet-4", Input: 1, Output: 5}},
+	}})
+
+	tests := []struct {
+		channel, endpoint string
+		want              float64
+	}{
+		{"official", "official-a", 18},
+		{"relay", "relay-a", 9},
+		{"cheap", "cheap-a", 6},
+	}
+	for _, tt := range tests {
+		if got := tracker.EffectivePrice(tt.channel, tt.endpoint, "claude-sonnet-4"); got != tt.want {
+			t.Errorf("EffectivePrice(%s, %s) = %v, want %v", tt.channel, tt.endpoint, got, tt.want)
+		}
+	}
+
+	ctx := context.Background()
+	now := tracker.now()
+	for i, row := range []struct {
+		model string
+		cost  float64
+	}{{"claude-haiku", 1}, {"claude-haiku", 1}, {"claude-sonnet-4", 3}} {
+		_, err := tracker.writeDB.ExecContext(ctx, `INSERT INTO request_logs (
+			request_id, start_time, channel, endpoint_name, model_name, status, total_cost_usd
+		) VALUES (?, ?, 'official', 'official-a', ?, 'completed', ?)`,
+			"req-divert-"+string(rune('a'+i)), tracker.formatStartTimeQueryBound(now), row.model, row.cost)
+		if err != nil {
+			t.Fatalf("插入测试数据失败: %v", err)
+		}
+	}
+	start, end := tracker.CurrentBudgetWindow(BudgetWindowDaily)
+	status := BudgetStatus{Name: "official-daily", Scope: BudgetScopeChannel, Channel: "official", WindowStart: start, WindowEnd: end}
+	if model := tracker.BudgetTopModel(ctx, status); model != "claude-sonnet-4" {
+		t.Errorf("BudgetTopModel = %q, want claude-sonnet-4", model)
+	}
+	status.Channel = "relay"
+	if model := tracker.BudgetTopModel(ctx, status); model != "" {
+		t.Errorf("窗口内无记录时应返回空, got %q", model)
+	}
+}
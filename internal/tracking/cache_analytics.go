@@ -0,0 +1,512 @@
+package tracking
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 缓存分析分组维度
+const (
+	CacheGroupByModel    = "model"
+	CacheGroupByEndpoint = "endpoint"
+	CacheGroupByChannel  = "channel"
+	CacheGroupBySession  = "session"
+	CacheGroupByHour     = "hour"
+)
+
+const (
+	// cacheTTL5m 5分钟缓存有效期
+	cacheTTL5m = 5 * time.Minute
+	// cacheTTL1h 1小时缓存有效期
+	cacheTTL1h = time.Hour
+)
+
+// CacheAnalyticsOptions 缓存分析查询条件
+type CacheAnalyticsOptions struct {
+	StartDate    *time.Time
+	EndDate      *time.Time
+	GroupBy      string // model / endpoint / channel / session / hour，默认 model
+	ModelName    string
+	Channel      string
+	EndpointName string
+	SessionID    string
+	Tags         []RequestTag
+}
+
+// CacheEfficiency 提示缓存效率（按分组维度聚合）
+type CacheEfficiency struct {
+	Key string `json:"key"` // 分组值（hour 维度为 2006-01-02 15:00）
+
+	RequestCount       int `json:"request_count"`
+	CacheHitRequests   int `json:"cache_hit_requests"`   // 有缓存读取的请求数
+	CacheWriteRequests int `json:"cache_write_requests"` // 有缓存写入的请求数
+
+	InputTokens           int64 `json:"input_tokens"`
+	CacheCreationTokens   int64 `json:"cache_creation_tokens"`
+	CacheCreation5mTokens int64 `json:"cache_creation_5m_tokens"`
+	CacheCreation1hTokens int64 `json:"cache_creation_1h_tokens"`
+	CacheReadTokens       int64 `json:"cache_read_tokens"`
+
+	// HitRatio 缓存命中率 = read / (read + creation + input)
+	HitRatio float64 `json:"hit_ratio"`
+
+	CacheReadCostUSD     float64 `json:"cache_read_cost_usd"`
+	CacheCreationCostUSD float64 `json:"cache_creation_cost_usd"`
+	// UncachedCostUSD 缓存读取与写入 token 按输入价格计算的成本（即不使用缓存时的成本）
+	UncachedCostUSD float64 `json:"uncached_cost_usd"`
+	// ReadSavingsUSD 缓存读取相对输入价格节省的金额
+	ReadSavingsUSD float64 `json:"read_savings_usd"`
+	// WritePremiumUSD 缓存写入相对输入价格多付的金额
+	WritePremiumUSD float64 `json:"write_premium_usd"`
+	// NetSavingsUSD 净节省 = ReadSavingsUSD - WritePremiumUSD
+	NetSavingsUSD float64 `json:"net_savings_usd"`
+
+	// UnreadWriteTokens/UnreadWriteCostUSD 有效期内未被读取的缓存写入（同渠道/端点/模型/会话）
+	UnreadWriteTokens  int64   `json:"unread_write_tokens"`
+	UnreadWriteCostUSD float64 `json:"unread_write_cost_usd"`
+}
+
+// CacheThrashOptions 缓存抖动检测条件
+type CacheThrashOptions struct {
+	StartDate *time.Time
+	EndDate   *time.Time
+	ModelName string
+	Channel   string
+	// Window 检测窗口，默认 1 小时
+	Window time.Duration
+	// MinCreationTokens 窗口内缓存写入 token 下限（过滤低流量窗口），默认 100000
+	MinCreationTokens int64
+	// CreationReadRatio 缓存写入/读取 token 比值达到该值视为抖动，默认 1（写入多于读取）
+	CreationReadRatio float64
+}
+
+// CacheThrashPeriod 缓存抖动时段（缓存写入压过读取，通常意味着路由在端点间切换）
+type CacheThrashPeriod struct {
+	StartTime time.Time `json:"start_time"`
+	EndTime   time.Time `json:"end_time"`
+
+	RequestCount        int     `json:"request_count"`
+	CacheCreationTokens int64   `json:"cache_creation_tokens"`
+	CacheReadTokens     int64   `json:"cache_read_tokens"`
+	CreationReadRatio   float64 `json:"creation_read_ratio"` // 读取为 0 时为 0
+	CacheWriteCostUSD   float64 `json:"cache_write_cost_usd"`
+	UnreadWriteCostUSD  float64 `json:"unread_write_cost_usd"`
+
+	Endpoints        []string `json:"endpoints"`         // 时段内命中的端点（渠道/端点）
+	EndpointSwitches int      `json:"endpoint_switches"` // 同一会话/模型连续请求切换端点的次数
+}
+
+// cacheUsageRow 缓存分析使用的请求行
+type cacheUsageRow struct {
+	startTime                                time.Time
+	model, channel, endpoint, group, session string
+	isBatch                                  bool
+	input, creation, creation5m, creation1h  int64
+	read                                     int64
+	readCost, creationCost                   float64
+	unreadWriteTokens                        int64
+	unreadWriteCost                          float64
+}
+
+// endpointKey 渠道/端点标识
+func (r *cacheUsageRow) endpointKey() string {
+	if r.channel == "" {
+		return r.endpoint
+	}
+	return r.channel + "/" + r.endpoint
+}
+
+// groupKey 按分组维度取值
+func (r *cacheUsageRow) groupKey(groupBy string) string {
+	switch groupBy {
+	case CacheGroupByEndpoint:
+		return r.endpointKey()
+	case CacheGroupByChannel:
+		return r.channel
+	case CacheGroupBySession:
+		return r.session
+	case CacheGroupByHour:
+		return r.startTime.Format("2006-01-02 15:00")
+	default:
+		return r.model
+	}
+}
+
+// loadCacheUsageRows 加载时间范围内的缓存相关请求（按开始时间升序）
+func (ut *UsageTracker) loadCacheUsageRows(ctx context.Context, opts *CacheAnalyticsOptions) ([]*cacheUsageRow, error) {
+	if ut.readDB == nil {
+		return nil, fmt.Errorf("read database not initialized")
+	}
+
+	query := `SELECT start_time,
+		COALESCE(model_name, ''), COALESCE(channel, ''), COALESCE(endpoint_name, ''), COALESCE(group_name, ''),
+		COALESCE(session_id, ''), COALESCE(is_batch, 0),
+		COALESCE(input_tokens, 0), COALESCE(cache_creation_tokens, 0),
+		COALESCE(cache_creation_5m_tokens, 0), COALESCE(cache_creation_1h_tokens, 0),
+		COALESCE(cache_read_tokens, 0),
+		COALESCE(cache_read_cost_usd, 0), COALESCE(cache_creation_cost_usd, 0)
+		FROM request_logs
+		WHERE (COALESCE(input_tokens, 0) > 0 OR COALESCE(cache_creation_tokens, 0) > 0 OR COALESCE(cache_read_tokens, 0) > 0)`
+
+	var args []interface{}
+	if opts.StartDate != nil {
+		query += " AND start_time >= ?"
+		args = append(args, ut.formatStartTimeQueryBound(*opts.StartDate))
+	}
+	if opts.EndDate != nil {
+		query += " AND start_time <= ?"
+		args = append(args, ut.formatEndTimeQueryBound(*opts.EndDate))
+	}
+	if opts.ModelName != "" {
+		query += " AND model_name = ?"
+		args = append(args, opts.ModelName)
+	}
+	if opts.Channel != "" {
+		query += " AND channel = ?"
+		args = append(args, opts.Channel)
+	}
+	if opts.EndpointName != "" {
+		query += " AND endpoint_name = ?"
+		args = append(args, opts.EndpointName)
+	}
+	if opts.SessionID != "" {
+		query += " AND session_id = ?"
+		args = append(args, opts.SessionID)
+	}
+	tagClause, tagArgs := tagFilterSQL(opts.Tags)
+	query += tagClause
+	args = append(args, tagArgs...)
+
+	query += " ORDER BY start_time ASC"
+
+	rows, err := ut.readDB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query cache usage: %w", err)
+	}
+	defer rows.Close()
+
+	var result []*cacheUsageRow
+	for rows.Next() {
+		var r cacheUsageRow
+		var startTime string
+		if err := rows.Scan(&startTime, &r.model, &r.channel, &r.endpoint, &r.group, &r.session, &r.isBatch,
+			&r.input, &r.creation, &r.creation5m, &r.creation1h, &r.read,
+			&r.readCost, &r.creationCost); err != nil {
+			return nil, fmt.Errorf("failed to scan cache usage: %w", err)
+		}
+		r.startTime = ut.parseStoredTime(startTime)
+		// 旧数据未区分 5m/1h 时按 5 分钟缓存处理
+		if r.creation5m == 0 && r.creation1h == 0 {
+			r.creation5m = r.creation
+		}
+		result = append(result, &r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating cache usage rows: %w", err)
+	}
+	return result, nil
+}
+
+// markUnreadCacheWrites 标记有效期内未被读取的缓存写入
+// 缓存按 渠道/端点/模型/会话 归属，写入后在 TTL 内同归属的后续请求出现缓存读取即视为被读取；
+// 截止 horizon 仍在有效期内的写入无法判定，不计入未读取。
+func markUnreadCacheWrites(rows []*cacheUsageRow, horizon time.Time) {
+	type pendingWrite struct {
+		row     *cacheUsageRow
+		expires time.Time
+	}
+	pending := make(map[string][]pendingWrite)
+
+	for _, r := range rows {
+		lineage := r.endpointKey() + "|" + r.model + "|" + r.session
+		if r.read > 0 {
+			for _, p := range pending[lineage] {
+				if r.startTime.After(p.expires) {
+					p.row.unreadWriteTokens = p.row.creation
+					p.row.unreadWriteCost = p.row.creationCost
+				}
+			}
+			delete(pending, lineage)
+		}
+		if r.creation > 0 {
+			ttl := cacheTTL5m
+			if r.creation1h > 0 {
+				ttl = cacheTTL1h
+			}
+			pending[lineage] = append(pending[lineage], pendingWrite{row: r, expires: r.startTime.Add(ttl)})
+		}
+	}
+
+	for _, writes := range pending {
+		for _, p := range writes {
+			if horizon.After(p.expires) {
+				p.row.unreadWriteTokens = p.row.creation
+				p.row.unreadWriteCost = p.row.creationCost
+			}
+		}
+	}
+}
+
+// cacheHorizon 未读取判定的截止时间（查询结束时间与当前时间取较早者）
+// SQLite 驱动将存储的墙上时间按 UTC 返回，这里转换为相同表示以便与请求时间比较
+func (ut *UsageTracker) cacheHorizon(endDate *time.Time) time.Time {
+	horizon := ut.now()
+	if endDate != nil && endDate.Before(horizon) {
+		horizon = *endDate
+	}
+	if ut.location != nil {
+		horizon = horizon.In(ut.location)
+	}
+	return time.Date(horizon.Year(), horizon.Month(), horizon.Day(),
+		horizon.Hour(), horizon.Minute(), horizon.Second(), horizon.Nanosecond(), time.UTC)
+}
+
+// truncateToWindow 按当天零点对齐截断时间（保证存储时区下的整点/整日窗口）
+func truncateToWindow(t time.Time, window time.Duration) time.Time {
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	return midnight.Add(t.Sub(midnight).Truncate(window))
+}
+
+// uncachedCost 缓存读取/写入 tokens 若按普通输入计价的成本
+// 与存储的缓存成本使用同一套计价规则：请求时刻的定价版本、价目表、端点倍率与长上下文档位
+func (r *cacheUsageRow) uncachedCost(rules costRules, at time.Time) (read, write float64) {
+	prompt := r.input + r.read + r.creation
+	if prompt == 0 {
+		return 0, 0
+	}
+	asInput := TokenUsage{InputTokens: prompt}
+	rate := rules.cost(r.channel, r.group, r.endpoint, r.model, &asInput, at).InputCost / float64(prompt)
+	read, write = float64(r.read)*rate, float64(r.creation)*rate
+
+	// 批处理记录的存储成本已按批处理倍率折算：按存储成本与标准成本之比折算基准
+	if r.isBatch {
+		cached := TokenUsage{
+			InputTokens:           r.input,
+			CacheCreationTokens:   r.creation,
+			CacheCreation5mTokens: r.creation5m,
+			CacheCreation1hTokens: r.creation1h,
+			CacheReadTokens:       r.read,
+		}
+		standard := rules.cost(r.channel, r.group, r.endpoint, r.model, &cached, at)
+		if total := standard.CacheReadCost + standard.CacheCreationCost; total > 0 {
+			scale := (r.readCost + r.creationCost) / total
+			read, write = read*scale, write*scale
+		}
+	}
+	return read, write
+}
+
+// QueryCacheEfficiency 查询提示缓存效率：命中率、相对未缓存价格的节省、未被读取的缓存写入
+// 未缓存基准与存储成本按同一套计价规则计算（见 uncachedCost）
+func (ut *UsageTracker) QueryCacheEfficiency(ctx context.Context, opts *CacheAnalyticsOptions) ([]CacheEfficiency, error) {
+	if opts == nil {
+		opts = &CacheAnalyticsOptions{}
+	}
+	groupBy := strings.ToLower(strings.TrimSpace(opts.GroupBy))
+	switch groupBy {
+	case "":
+		groupBy = CacheGroupByModel
+	case CacheGroupByModel, CacheGroupByEndpoint, CacheGroupByChannel, CacheGroupBySession, CacheGroupByHour:
+	default:
+		return nil, fmt.Errorf("unsupported cache group by: %s", opts.GroupBy)
+	}
+
+	rows, err := ut.loadCacheUsageRows(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	markUnreadCacheWrites(rows, ut.cacheHorizon(opts.EndDate))
+
+	rules := ut.costRules()
+	groups := make(map[string]*CacheEfficiency)
+	var keys []string
+	for _, r := range rows {
+		key := r.groupKey(groupBy)
+		g, ok := groups[key]
+		if !ok {
+			g = &CacheEfficiency{Key: key}
+			groups[key] = g
+			keys = append(keys, key)
+		}
+
+		g.RequestCount++
+		if r.read > 0 {
+			g.CacheHitRequests++
+		}
+		if r.creation > 0 {
+			g.CacheWriteRequests++
+		}
+		g.InputTokens += r.input
+		g.CacheCreationTokens += r.creation
+		g.CacheCreation5mTokens += r.creation5m
+		g.CacheCreation1hTokens += r.creation1h
+		g.CacheReadTokens += r.read
+		g.CacheReadCostUSD += r.readCost
+		g.CacheCreationCostUSD += r.creationCost
+		g.UnreadWriteTokens += r.unreadWriteTokens
+		g.UnreadWriteCostUSD += r.unreadWriteCost
+
+		uncachedRead, uncachedWrite := r.uncachedCost(rules, ut.requestLocalTime(r.startTime))
+		g.UncachedCostUSD += uncachedRead + uncachedWrite
+		g.ReadSavingsUSD += uncachedRead - r.readCost
+		g.WritePremiumUSD += r.creationCost - uncachedWrite
+	}
+
+	result := make([]CacheEfficiency, 0, len(keys))
+	for _, key := range keys {
+		g := groups[key]
+		if total := g.CacheReadTokens + g.CacheCreationTokens + g.InputTokens; total > 0 {
+			g.HitRatio = float64(g.CacheReadTokens) / float64(total)
+		}
+		g.NetSavingsUSD = g.ReadSavingsUSD - g.WritePremiumUSD
+		result = append(result, *g)
+	}
+
+	if groupBy == CacheGroupByHour {
+		sort.Slice(result, func(i, j int) bool { return result[i].Key < result[j].Key })
+	} else {
+		sort.SliceStable(result, func(i, j int) bool {
+			if result[i].CacheReadTokens+result[i].CacheCreationTokens != result[j].CacheReadTokens+result[j].CacheCreationTokens {
+				return result[i].CacheReadTokens+result[i].CacheCreationTokens > result[j].CacheReadTokens+result[j].CacheCreationTokens
+			}
+			return result[i].Key < result[j].Key
+		})
+	}
+	return result, nil
+}
+
+// SummarizeCacheEfficiency 汇总各分组的缓存效率（分组结果相加即为整体）
+func SummarizeCacheEfficiency(items []CacheEfficiency) CacheEfficiency {
+	var total CacheEfficiency
+	for _, item := range items {
+		total.RequestCount += item.RequestCount
+		total.CacheHitRequests += item.CacheHitRequests
+		total.CacheWriteRequests += item.CacheWriteRequests
+		total.InputTokens += item.InputTokens
+		total.CacheCreationTokens += item.CacheCreationTokens
+		total.CacheCreation5mTokens += item.CacheCreation5mTokens
+		total.CacheCreation1hTokens += item.CacheCreation1hTokens
+		total.CacheReadTokens += item.CacheReadTokens
+		total.CacheReadCostUSD += item.CacheReadCostUSD
+		total.CacheCreationCostUSD += item.CacheCreationCostUSD
+		total.UncachedCostUSD += item.UncachedCostUSD
+		total.ReadSavingsUSD += item.ReadSavingsUSD
+		total.WritePremiumUSD += item.WritePremiumUSD
+		total.UnreadWriteTokens += item.UnreadWriteTokens
+		total.UnreadWriteCostUSD += item.UnreadWriteCostUSD
+	}
+	if sum := total.CacheReadTokens + total.CacheCreationTokens + total.InputTokens; sum > 0 {
+		total.HitRatio = float64(total.CacheReadTokens) / float64(sum)
+	}
+	total.NetSavingsUSD = total.ReadSavingsUSD - total.WritePremiumUSD
+	return total
+}
+
+// DetectCacheThrash 检测缓存抖动时段：窗口内缓存写入压过读取（相邻窗口合并为一个时段）
+func (ut *UsageTracker) DetectCacheThrash(ctx context.Context, opts *CacheThrashOptions) ([]CacheThrashPeriod, error) {
+	if opts == nil {
+		opts = &CacheThrashOptions{}
+	}
+	window := opts.Window
+	if window <= 0 {
+		window = time.Hour
+	}
+	minCreation := opts.MinCreationTokens
+	if minCreation <= 0 {
+		minCreation = 100000
+	}
+	ratio := opts.CreationReadRatio
+	if ratio <= 0 {
+		ratio = 1
+	}
+
+	rows, err := ut.loadCacheUsageRows(ctx, &CacheAnalyticsOptions{
+		StartDate: opts.StartDate,
+		EndDate:   opts.EndDate,
+		ModelName: opts.ModelName,
+		Channel:   opts.Channel,
+	})
+	if err != nil {
+		return nil, err
+	}
+	markUnreadCacheWrites(rows, ut.cacheHorizon(opts.EndDate))
+
+	type bucket struct {
+		start     time.Time
+		period    CacheThrashPeriod
+		endpoints map[string]bool
+	}
+	var buckets []*bucket
+	lastEndpoint := make(map[string]string) // 会话/模型 -> 上一次命中的端点
+	for _, r := range rows {
+		start := truncateToWindow(r.startTime, window)
+		if len(buckets) == 0 || !buckets[len(buckets)-1].start.Equal(start) {
+			buckets = append(buckets, &bucket{start: start, endpoints: make(map[string]bool)})
+		}
+		b := buckets[len(buckets)-1]
+		b.period.RequestCount++
+		b.period.CacheCreationTokens += r.creation
+		b.period.CacheReadTokens += r.read
+		b.period.CacheWriteCostUSD += r.creationCost
+		b.period.UnreadWriteCostUSD += r.unreadWriteCost
+		b.endpoints[r.endpointKey()] = true
+
+		stream := r.session + "|" + r.model
+		if prev, ok := lastEndpoint[stream]; ok && prev != r.endpointKey() {
+			b.period.EndpointSwitches++
+		}
+		lastEndpoint[stream] = r.endpointKey()
+	}
+
+	var periods []CacheThrashPeriod
+	var current *CacheThrashPeriod
+	var currentEndpoints map[string]bool
+	flush := func() {
+		if current == nil {
+			return
+		}
+		if current.CacheReadTokens > 0 {
+			current.CreationReadRatio = float64(current.CacheCreationTokens) / float64(current.CacheReadTokens)
+		}
+		for ep := range currentEndpoints {
+			current.Endpoints = append(current.Endpoints, ep)
+		}
+		sort.Strings(current.Endpoints)
+		periods = append(periods, *current)
+		current = nil
+	}
+
+	for _, b := range buckets {
+		p := b.period
+		thrash := p.CacheCreationTokens >= minCreation &&
+			float64(p.CacheCreationTokens) >= float64(p.CacheReadTokens)*ratio
+		if !thrash {
+			flush()
+			continue
+		}
+		if current != nil && current.EndTime.Equal(b.start) {
+			current.EndTime = b.start.Add(window)
+			current.RequestCount += p.RequestCount
+			current.CacheCreationTokens += p.CacheCreationTokens
+			current.CacheReadTokens += p.CacheReadTokens
+			current.CacheWriteCostUSD += p.CacheWriteCostUSD
+			current.UnreadWriteCostUSD += p.UnreadWriteCostUSD
+			current.EndpointSwitches += p.EndpointSwitches
+		} else {
+			flush()
+			p.StartTime = b.start
+			p.EndTime = b.start.Add(window)
+			current = &p
+			currentEndpoints = make(map[string]bool)
+		}
+		for ep := range b.endpoints {
+			currentEndpoints[ep] = true
+		}
+	}
+	flush()
+
+	return periods, nil
+}
@@ -0,0 +1,206 @@
+package tracking
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+)
+
+// TestCacheAnalytics 测试缓存命中率、节省金额、未读取缓存写入与缓存抖动检测
+func TestCacheAnalytics(t *testing.T) {
+	config := &Config{
+		Enabled:         true,
+		DatabasePath:    ":memory:",
+		BufferSize:      100,
+		BatchSize:       10,
+		FlushInterval:   50 * time.Millisecond,
+		MaxRetry:        3,
+		CleanupInterval: 24 * time.Hour,
+		RetentionDays:   30,
+		DefaultPricing: ModelPricing{
+			Input:         3,
+			Output:        15,
+			CacheCreation: 3.75,
+			CacheRead:     0.3,
+		},
+	}
+	tracker, err := NewUsageTracker(config)
+	if err != nil {
+		t.Fatalf("Failed to create usage tracker: %v", err)
+	}
+	defer tracker.Close()
+
+	ctx := context.Background()
+	base := truncateToWindow(tracker.now().Add(-3*time.Hour), time.Hour)
+	rows := []struct {
+		offset         time.Duration
+		endpoint       string
+		creation, read int64
+		creationCost   float64
+		readCost       float64
+	}{
+		{0, "relay-a", 10000, 0, 0.0375, 0},
+		{2 * time.Minute, "relay-a", 0, 10000, 0, 0.003},
+		// 路由切换到 relay-b 后重新写入缓存，且超过 5 分钟才再次读取
+		{10 * time.Minute, "relay-b", 10000, 0, 0.0375, 0},
+		{30 * time.Minute, "relay-b", 0, 10000, 0, 0.003},
+	}
+	for i, r := range rows {
+		_, err := tracker.writeDB.ExecContext(ctx, `INSERT INTO request_logs (
+			request_id, start_time, channel, endpoint_name, model_name, status, session_id,
+			input_tokens, cache_creation_tokens, cache_creation_5m_tokens, cache_read_tokens,
+			cache_creation_cost_usd, cache_read_cost_usd
+		) VALUES (?, ?, 'relay', ?, 'claude-sonnet-4', 'completed', 'sess-1', 100, ?, ?, ?, ?, ?)`,
+			"req-cache-"+string(rune('a'+i)), base.Add(r.offset).Format("2006-01-02 15:04:05"), r.endpoint,
+			r.creation, r.creation, r.read, r.creationCost, r.readCost)
+		if err != nil {
+			t.Fatalf("插入测试数据失败: %v", err)
+		}
+	}
+
+	byModel, err := tracker.QueryCacheEfficiency(ctx, &CacheAnalyticsOptions{})
+	if err != nil {
+		t.Fatalf("查询缓存效率失败: %v", err)
+	}
+	if len(byModel) != 1 {
+		t.Fatalf("应只有一个模型分组: %+v", byModel)
+	}
+	m := byModel[0]
+	if m.RequestCount != 4 || m.CacheHitRequests != 2 || m.CacheWriteRequests != 2 {
+		t.Errorf("请求计数不符: %+v", m)
+	}
+	if want := 20000.0 / 40400.0; math.Abs(m.HitRatio-want) > 1e-9 {
+		t.Errorf("命中率 = %f, want %f", m.HitRatio, want)
+	}
+	if math.Abs(m.ReadSavingsUSD-0.054) > 1e-9 || math.Abs(m.WritePremiumUSD-0.015) > 1e-9 || math.Abs(m.NetSavingsUSD-0.039) > 1e-9 {
+		t.Errorf("节省金额不符: read=%f premium=%f net=%f", m.ReadSavingsUSD, m.WritePremiumUSD, m.NetSavingsUSD)
+	}
+	if m.UnreadWriteTokens != 10000 || math.Abs(m.UnreadWriteCostUSD-0.0375) > 1e-9 {
+		t.Errorf("未读取缓存写入不符: tokens=%d cost=%f", m.UnreadWriteTokens, m.UnreadWriteCostUSD)
+	}
+
+	byEndpoint, err := tracker.QueryCacheEfficiency(ctx, &CacheAnalyticsOptions{GroupBy: CacheGroupByEndpoint})
+	if err != nil || len(byEndpoint) != 2 {
+		t.Fatalf("按端点分组失败: %+v, %v", byEndpoint, err)
+	}
+	for _, e := range byEndpoint {
+		if e.Key == "relay/relay-a" && e.UnreadWriteTokens != 0 {
+			t.Errorf("relay-a 的缓存写入已被读取: %+v", e)
+		}
+		if e.Key == "relay/relay-b" && e.UnreadWriteTokens != 10000 {
+			t.Errorf("relay-b 的缓存写入应视为未读取: %+v", e)
+		}
+	}
+
+	if _, err := tracker.QueryCacheEfficiency(ctx, &CacheAnalyticsOptions{GroupBy: "unknown"}); err == nil {
+		t.Error("不支持的分组维度应返回错误")
+	}
+
+	periods, err := tracker.DetectCacheThrash(ctx, &CacheThrashOptions{MinCreationTokens: 10000})
+	if err != nil {
+		t.Fatalf("缓存抖动检测失败: %v", err)
+	}
+	if len(periods) != 1 {
+		t.Fatalf("应检测到一个抖动时段: %+v", periods)
+	}
+	p := periods[0]
+	// 存储时间按配置时区的墙上时间比较
+	if p.StartTime.Format("2006-01-02 15:04") != base.Format("2006-01-02 15:04") || p.EndTime.Sub(p.StartTime) != time.Hour {
+		t.Errorf("抖动时段不符: %s - %s", p.StartTime, p.EndTime)
+	}
+	if p.EndpointSwitches != 1 || len(p.Endpoints) != 2 {
+		t.Errorf("端点切换不符: switches=%d endpoints=%v", p.EndpointSwitches, p.Endpoints)
+	}
+
+	periods, err = tracker.DetectCacheThrash(ctx, &CacheThrashOptions{MinCreationTokens: 10000, CreationReadRatio: 2})
+	if err != nil || len(periods) != 0 {
+		t.Errorf("写入未达到读取 2 倍时不应视为抖动: %+v, %v", periods, err)
+	}
+}
+
+// TestCacheEfficiency_UncachedBaselineUsesCostRules 测试未缓存基准与存储成本使用同一套计价规则（端点倍率、批处理折扣）
+func TestCacheEfficiency_UncachedBaselineUsesCostRules(t *testing.T) {
+	tracker, err := NewUsageTracker(&Config{
+		Enabled:         true,
+		DatabasePath:    ":memory:",
+		BufferSize:      100,
+		BatchSize:       10,
+		FlushInterval:   50 * time.Millisecond,
+		MaxRetry:        3,
+		CleanupInterval: 24 * time.Hour,
+		RetentionDays:   30,
+		DefaultPricing: ModelPricing{
+			Input:         3,
+			Output:        15,
+			CacheCreation: 3.75,
+			CacheRead:     0.3,
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create usage tracker: %v", err)
+	}
+	defer tracker.Close()
+
+	tracker.UpdateEndpointMultipliers(map[string]EndpointMultiplier{
+		EndpointMultiplierKey("mult", "mult-a"): {CostMultiplier: 2},
+	})
+
+	ctx := context.Background()
+	start := tracker.now().Add(-time.Hour).Format("2006-01-02 15:04:05")
+	rows := []struct {
+		channel, endpoint string
+		isBatch           bool
+		creation, read    int64
+		creationCost      float64
+		readCost          float64
+	}{
+		// 端点倍率 2x：存储成本已翻倍
+		{"mult", "mult-a", false, 10000, 0, 0.075, 0},
+		{"mult", "mult-a", false, 0, 10000, 0, 0.006},
+		// 批处理 50% 折扣
+		{"batch", "batch-a", true, 0, 10000, 0, 0.0015},
+	}
+	for i, r := range rows {
+		_, err := tracker.writeDB.ExecContext(ctx, `INSERT INTO request_logs (
+			request_id, start_time, channel, endpoint_name, model_name, status, is_batch,
+			input_tokens, cache_creation_tokens, cache_creation_5m_tokens, cache_read_tokens,
+			cache_creation_cost_usd, cache_read_cost_usd
+		) VALUES (?, ?, ?, ?, 'claude-sonnet-4', 'completed', ?, 0, ?, ?, ?, ?, ?)`,
+			"req-cache-rules-"+string(rune('a'+i)), start, r.channel, r.endpoint, r.isBatch,
+			r.creation, r.creation, r.read, r.creationCost, r.readCost)
+		if err != nil {
+			t.Fatalf("插入测试数据失败: %v", err)
+		}
+	}
+
+	byChannel, err := tracker.QueryCacheEfficiency(ctx, &CacheAnalyticsOptions{GroupBy: CacheGroupByChannel})
+	if err != nil {
+		t.Fatalf("查询缓存效率失败: %v", err)
+	}
+	for _, c := range byChannel {
+		switch c.Key {
+		case "mult":
+			if math.Abs(c.ReadSavingsUSD-0.054) > 1e-9 || math.Abs(c.WritePremiumUSD-0.015) > 1e-9 || math.Abs(c.NetSavingsUSD-0.039) > 1e-9 {
+				t.Errorf("倍率渠道节省金额不符: read=%f premium=%f net=%f", c.ReadSavingsUSD, c.WritePremiumUSD, c.NetSavingsUSD)
+			}
+		case "batch":
+			if math.Abs(c.UncachedCostUSD-0.015) > 1e-9 || math.Abs(c.ReadSavingsUSD-0.0135) > 1e-9 {
+				t.Errorf("批处理节省金额不符: uncached=%f read=%f", c.UncachedCostUSD, c.ReadSavingsUSD)
+			}
+		default:
+			t.Errorf("意外的分组: %+v", c)
+		}
+	}
+}
+
+// TestSummarizeCacheEfficiency 测试缓存效率汇总
+func TestSummarizeCacheEfficiency(t *testing.T) {
+	total := SummarizeCacheEfficiency([]CacheEfficiency{
+		{RequestCount: 2, InputTokens: 100, CacheReadTokens: 800, CacheCreationTokens: 100, ReadSavingsUSD: 1, WritePremiumUSD: 0.25},
+		{RequestCount: 1, InputTokens: 100, CacheReadTokens: 0, CacheCreationTokens: 900, WritePremiumUSD: 0.25},
+	})
+	if total.RequestCount != 3 || total.HitRatio != 0.4 || total.NetSavingsUSD != 0.5 {
+		t.Errorf("汇总不符: %+v", total)
+	}
+}
@@ -0,0 +1,247 @@
+package tracking
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"math"
+	"sort"
+	"time"
+)
+
+// CostRecalculationOptions 历史成本重算范围
+type CostRecalculationOptions struct {
+	StartTime    time.Time
+	EndTime      time.Time
+	ModelName    string // 为空表示全部模型
+	EndpointName string // 为空表示全部端点
+	Channel      string // 为空表示全部渠道
+	DryRun       bool   // 仅计算差异，不写入数据库
+}
+
+// CostRecalculationModelDiff 按模型汇总的重算差异
+type CostRecalculationModelDiff struct {
+	ModelName string  `json:"model_name"`
+	Requests  int     `json:"requests"`
+	Changed   int     `json:"changed"`
+	OldCost   float64 `json:"old_cost_usd"`
+	NewCost   float64 `json:"new_cost_usd"`
+}
+
+// CostRecalculationResult 历史成本重算结果（dry-run 时为预览差异）
+type CostRecalculationResult struct {
+	DryRun       bool                         `json:"dry_run"`
+	Scanned      int                          `json:"scanned"`
+	Changed      int                          `json:"changed"`
+	SkippedBatch int                          `json:"skipped_batch"` // Message Batches 记录按批次聚合计费，无法逐条重算
+	OldTotal     float64                      `json:"old_total_usd"`
+	NewTotal     float64                      `json:"new_total_usd"`
+	Delta        float64                      `json:"delta_usd"`
+	Models       []CostRecalculationModelDiff `json:"models"`
+}
+
+// recalculatedCost 单条请求的重算结果
+type recalculatedCost struct {
+	id      int64
+	cost    CostBreakdown
+	amounts CurrencyAmounts
+}
+
+// costRecalculationEpsilon 成本差异判定阈值（USD）
+const costRecalculationEpsilon = 1e-9
+
+// RecalculateCosts 按当前定价（请求开始时生效的定价版本、价目表、端点倍率）重算历史请求成本
+// 结算币种与报表币种沿用写入时的币种，金额按请求时汇率重新换算
+func (ut *UsageTracker) RecalculateCosts(ctx context.Context, opts CostRecalculationOptions) (*CostRecalculationResult, error) {
+	if ut.readDB == nil || ut.writeDB == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+	if opts.StartTime.IsZero() || opts.EndTime.IsZero() || opts.EndTime.Before(opts.StartTime) {
+		return nil, fmt.Errorf("invalid recalculation time range")
+	}
+
+	query := `SELECT id, start_time,
+		COALESCE(channel, ''), COALESCE(endpoint_name, ''), COALESCE(group_name, ''), COALESCE(model_name, ''),
+		COALESCE(input_tokens, 0), COALESCE(output_tokens, 0),
+		COALESCE(cache_creation_tokens, 0), COALESCE(cache_creation_5m_tokens, 0), COALESCE(cache_creation_1h_tokens, 0),
+		COALESCE(cache_read_tokens, 0), COALESCE(web_search_requests, 0), COALESCE(web_fetch_requests, 0),
+		COALESCE(is_batch, 0), COALESCE(total_cost_usd, 0),
+		billing_currency, reporting_currency
+		FROM request_logs WHERE start_time >= ? AND start_time <= ?`
+	args := []interface{}{ut.formatStartTimeQueryBound(opts.StartTime), ut.formatEndTimeQueryBound(opts.EndTime)}
+	if opts.ModelName != "" {
+		query += " AND model_name = ?"
+		args = append(args, opts.ModelName)
+	}
+	if opts.EndpointName != "" {
+		query += " AND endpoint_name = ?"
+		args = append(args, opts.EndpointName)
+	}
+	if opts.Channel != "" {
+		query += " AND channel = ?"
+		args = append(args, opts.Channel)
+	}
+
+	rows, err := ut.readDB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query requests for recalculation: %w", err)
+	}
+	defer rows.Close()
+
+	accounting := ut.currencyAccounting()
+	result := &CostRecalculationResult{DryRun: opts.DryRun}
+	models := make(map[string]*CostRecalculationModelDiff)
+	var updates []recalculatedCost
+
+	for rows.Next() {
+		var (
+			id                                      int64
+			startTime                               time.Time
+			channel, endpointName, groupName, model string
+			usage                                   TokenUsage
+			isBatch                                 bool
+			oldCost                                 float64
+			billingCurrency, reportingCurrency      sql.NullString
+		)
+		if err := rows.Scan(&id, &startTime,
+			&channel, &endpointName, &groupName, &model,
+			&usage.InputTokens, &usage.OutputTokens,
+			&usage.CacheCreationTokens, &usage.CacheCreation5mTokens, &usage.CacheCreation1hTokens,
+			&usage.CacheReadTokens, &usage.WebSearchRequests, &usage.WebFetchRequests,
+			&isBatch, &oldCost,
+			&billingCurrency, &reportingCurrency,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan request for recalculation: %w", err)
+		}
+
+		result.Scanned++
+		if isBatch {
+			result.SkippedBatch++
+			continue
+		}
+
+		at := ut.requestLocalTime(startTime)
+		cost := ut.CalculateRequestCost(channel, groupName, endpointName, model, &usage, at)
+
+		diff, ok := models[model]
+		if !ok {
+			diff = &CostRecalculationModelDiff{ModelName: model}
+			models[model] = diff
+		}
+		diff.Requests++
+		diff.OldCost += oldCost
+		diff.NewCost += cost.TotalCost
+		result.OldTotal += oldCost
+		result.NewTotal += cost.TotalCost
+
+		if math.Abs(cost.TotalCost-oldCost) <= costRecalculationEpsilon {
+			continue
+		}
+		diff.Changed++
+		result.Changed++
+
+		// 沿用写入时的结算/报表币种，按请求时汇率重新换算金额
+		amounts := accounting.Amounts(channel, cost.TotalCost, at)
+		if billingCurrency.Valid && billingCurrency.String != "" {
+			amounts.BillingCurrency = billingCurrency.String
+			amounts.BillingCost = convertOptional(accounting, cost.TotalCost, channel, billingCurrency.String, at)
+		}
+		if reportingCurrency.Valid && reportingCurrency.String != "" {
+			amounts.ReportingCurrency = reportingCurrency.String
+			amounts.ReportingCost = convertOptional(accounting, cost.TotalCost, channel, reportingCurrency.String, at)
+		}
+		updates = append(updates, recalculatedCost{id: id, cost: cost, amounts: amounts})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating requests for recalculation: %w", err)
+	}
+	rows.Close()
+
+	result.Delta = result.NewTotal - result.OldTotal
+	result.Models = make([]CostRecalculationModelDiff, 0, len(models))
+	for _, diff := range models {
+		result.Models = append(result.Models, *diff)
+	}
+	sort.Slice(result.Models, func(i, j int) bool {
+		return math.Abs(result.Models[i].NewCost-result.Models[i].OldCost) > math.Abs(result.Models[j].NewCost-result.Models[j].OldCost)
+	})
+
+	if opts.DryRun || len(updates) == 0 {
+		return result, nil
+	}
+	if err := ut.applyRecalculatedCosts(ctx, updates); err != nil {
+		return nil, err
+	}
+	if err := ut.RebuildUsageRollups(ctx, opts.StartTime, opts.EndTime); err != nil {
+		slog.Warn("⚠️ [用量汇总] 成本重算后重建汇总失败", "error", err)
+	}
+
+	slog.Info(fmt.Sprintf("💰 [成本重算] 扫描: %d, 更新: %d, 原成本: $%.6f, 新成本: $%.6f, 差额: $%.6f",
+		result.Scanned, result.Changed, result.OldTotal, result.NewTotal, result.Delta))
+	return result, nil
+}
+
+// applyRecalculatedCosts 在单个事务中写入重算后的成本
+func (ut *UsageTracker) applyRecalculatedCosts(ctx context.Context, updates []recalculatedCost) error {
+	ut.writeMu.Lock()
+	defer ut.writeMu.Unlock()
+
+	tx, err := ut.writeDB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `UPDATE request_logs SET
+		input_cost_usd = ?, output_cost_usd = ?,
+		cache_creation_cost_usd = ?, cache_creation_5m_cost_usd = ?, cache_creation_1h_cost_usd = ?,
+		cache_read_cost_usd = ?, server_tool_cost_usd = ?, total_cost_usd = ?,
+		pricing_tier = ?, price_source = ?, request_fee_usd = ?,
+		billing_currency = ?, billing_cost = ?, reporting_currency = ?, reporting_cost = ?
+		WHERE id = ?`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, u := range updates {
+		if _, err := stmt.ExecContext(ctx,
+			u.cost.InputCost, u.cost.OutputCost,
+			u.cost.CacheCreationCost, u.cost.CacheCreation5mCost, u.cost.CacheCreation1hCost,
+			u.cost.CacheReadCost, u.cost.ServerToolCost, u.cost.TotalCost,
+			u.cost.PricingTier, nullString(u.cost.PriceSource), u.cost.RequestFee,
+			u.amounts.BillingCurrency, nullableFloat(u.amounts.BillingCost),
+			u.amounts.ReportingCurrency, nullableFloat(u.amounts.ReportingCost),
+			u.id,
+		); err != nil {
+			return fmt.Errorf("failed to update request cost %d: %w", u.id, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// requestLocalTime 将数据库中的 start_time 解释为配置时区的本地时间
+// start_time 以不带时区的本地时间写入，驱动读取时会按 UTC 解析
+func (ut *UsageTracker) requestLocalTime(t time.Time) time.Time {
+	if ut.location == nil || t.Location() != time.UTC {
+		return t
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), ut.location)
+}
+
+// convertOptional 按渠道汇率换算金额（缺少汇率时返回 nil）
+func convertOptional(accounting *CurrencyAccounting, amountUSD float64, channel, currency string, at time.Time) *float64 {
+	var converter *CurrencyConverter
+	if accounting != nil {
+		converter = accounting.Converter
+	}
+	if v, ok := converter.ConvertForChannel(amountUSD, channel, currency, at); ok {
+		return &v
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package tracking
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+)
+
+// TestRecalculateCosts 测试按请求时生效的定价版本重算历史成本（dry-run 不写入）
+func TestRecalculateCosts(t *testing.T) {
+	config := &Config{
+		Enabled:         true,
+		DatabasePath:    ":memory:",
+		BufferSize:      100,
+		BatchSize:       10,
+		FlushInterval:   50 * time.Millisecond,
+		MaxRetry:        3,
+		CleanupInterval: 24 * time.Hour,
+		RetentionDays:   30,
+		ModelPricing: map[string]ModelPricing{
+			"claude-sonnet-4": {Input: 3, Output: 15},
+		},
+	}
+	tracker, err := NewUsageTracker(config)
+	if err != nil {
+		t.Fatalf("Failed to create usage tracker: %v", err)
+	}
+	defer tracker.Close()
+
+	loc := tracker.location
+	if loc == nil {
+		loc = time.Local
+	}
+	jan := time.Date(2025, 1, 10, 12, 0, 0, 0, loc)
+	feb := time.Date(2025, 2, 10, 12, 0, 0, 0, loc)
+
+	// 二月起价格翻倍
+	tracker.UpdatePricingHistory([]PricingVersion{
+		{Model: "claude-sonnet-4", EffectiveFrom: time.Unix(0, 0), Pricing: ModelPricing{Input: 3, Output: 15}},
+		{Model: "claude-sonnet-4", EffectiveFrom: time.Date(2025, 2, 1, 0, 0, 0, 0, loc), Pricing: ModelPricing{Input: 6, Output: 30}},
+	})
+
+	ctx := context.Background()
+	for i, start := range []time.Time{jan, feb, feb} {
+		isBatch := i == 2
+		_, err := tracker.writeDB.ExecContext(ctx, `INSERT INTO request_logs (
+			request_id, start_time, channel, endpoint_name, model_name, status,
+			input_tokens, output_tokens, total_cost_usd, is_batch
+		) VALUES (?, ?, 'official', 'api', 'claude-sonnet-4', 'completed', 1000000, 0, 3.0, ?)`,
+			"req-recalc-"+string(rune('a'+i)), start.Format("2006-01-02 15:04:05"), isBatch)
+		if err != nil {
+			t.Fatalf("插入测试数据失败: %v", err)
+		}
+	}
+
+	opts := CostRecalculationOptions{
+		StartTime: time.Date(2025, 1, 1, 0, 0, 0, 0, loc),
+		EndTime:   time.Date(2025, 2, 28, 0, 0, 0, 0, loc),
+		ModelName: "claude-sonnet-4",
+		DryRun:    true,
+	}
+	preview, err := tracker.RecalculateCosts(ctx, opts)
+	if err != nil {
+		t.Fatalf("预览重算失败: %v", err)
+	}
+	if preview.Scanned != 3 || preview.SkippedBatch != 1 || preview.Changed != 1 {
+		t.Fatalf("预览结果不符: %+v", preview)
+	}
+	if math.Abs(preview.OldTotal-6) > 1e-9 || math.Abs(preview.NewTotal-9) > 1e-9 || math.Abs(preview.Delta-3) > 1e-9 {
+		t.Errorf("预览差异 = old %v new %v delta %v, want 6 / 9 / 3", preview.OldTotal, preview.NewTotal, preview.Delta)
+	}
+
+	var febCost float64
+	if err := tracker.readDB.QueryRow(`SELECT total_cost_usd FROM request_logs WHERE request_id = 'req-recalc-b'`).Scan(&febCost); err != nil || febCost != 3.0 {
+		t.Fatalf("dry-run 不应写入: cost=%v err=%v", febCost, err)
+	}
+
+	opts.DryRun = false
+	applied, err := tracker.RecalculateCosts(ctx, opts)
+	if err != nil || applied.Changed != 1 {
+		t.Fatalf("执行重算失败: %+v, %v", applied, err)
+	}
+
+	var source string
+	if err := tracker.readDB.QueryRow(`SELECT total_cost_usd, price_source FROM request_logs WHERE request_id = 'req-recalc-b'`).Scan(&febCost, &source); err != nil {
+		t.Fatalf("查询重算结果失败: %v", err)
+	}
+	if math.Abs(febCost-6) > 1e-9 || source != PriceSourceModelPricing {
+		t.Errorf("重算后成本 = (%v, %s), want (6, %s)", febCost, source, PriceSourceModelPricing)
+	}
+
+	// 再次重算无差异
+	if again, err := tracker.RecalculateCosts(ctx, opts); err != nil || again.Changed != 0 {
+		t.Errorf("重复重算应无变化: %+v, %v", again, err)
+	}
+}
+
+// TestPricingHistory_At 测试按生效时间选取定价版本
+func TestPricingHistory_At(t *testing.T) {
+	jan := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	feb := time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC)
+	history := NewPricingHistory([]PricingVersion{
+		{Model: "m", EffectiveFrom: feb, Pricing: ModelPricing{Input: 2}},
+		{Model: "m", EffectiveFrom: jan, Pricing: ModelPricing{Input: 1}},
+	})
+
+	current := map[string]ModelPricing{"m": {Input: 9}, "_default": {Input: 5}}
+	tests := []struct {
+		model      string
+		at         time.Time
+		wantInput  float64
+		wantSource string
+	}{
+		{"m", jan.Add(-time.Hour), 1, PriceSourceModelPricing}, // 早于首个版本使用首个版本
+		{"m", feb.Add(-time.Second), 1, PriceSourceModelPricing},
+		{"m", feb, 2, PriceSourceModelPricing},
+		{"other", feb, 5, PriceSourceDefaultPricing}, // 无历史版本时使用当前定价
+	}
+	for _, tt := range tests {
+		pricing, source, ok := resolveModelPricing(current, history, tt.model, tt.at)
+		if !ok || pricing.Input != tt.wantInput || source != tt.wantSource {
+			t.Errorf("resolveModelPricing(%s, %v) = (%v, %s, %v), want (%v, %s)", tt.model, tt.at, pricing.Input, source, ok, tt.wantInput, tt.wantSource)
+		}
+	}
+
+	// 已从当前定价删除的模型不再使用其历史版本
+	if pricing, source, _ := resolveModelPricing(map[string]ModelPricing{"_default": {Input: 5}}, history, "m", feb); pricing.Input != 5 || source != PriceSourceDefaultPricing {
+		t.Errorf("删除模型应回退到默认定价: (%v, %s)", pricing.Input, source)
+	}
+}
@@ -0,0 +1,253 @@
+package tracking
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// maxCostScenarios 单次模拟的最大方案数
+const maxCostScenarios = 10
+
+// CostScenario 成本模拟方案（假设请求按另一渠道/端点、候选定价、价目表或端点倍率计费）
+// Pricing、PriceBook 与 Multiplier 均未设置时，按 Channel/EndpointName（为空时沿用请求自身）当前生效的计价规则重算；
+// 设置任一项时仍以 Channel/EndpointName（或请求自身）为计价上下文：PriceBook 命中的模型按候选价目表计价；
+// 未设置 PriceBook 与 Multiplier 时，上下文的价目表照常生效；其余按模型定价（Pricing 中的候选价格优先）
+// × Multiplier 计价，Multiplier 为 nil 时沿用上下文当前的端点倍率
+type CostScenario struct {
+	Name         string
+	Channel      string // 假设路由到的渠道（为空沿用请求渠道）
+	EndpointName string // 假设路由到的端点（为空沿用请求端点）
+	Pricing      map[string]ModelPricing
+	PriceBook    *PriceBook
+	Multiplier   *EndpointMultiplier
+}
+
+// custom 是否指定了候选定价、价目表或倍率
+func (s *CostScenario) custom() bool {
+	return len(s.Pricing) > 0 || s.PriceBook != nil || s.Multiplier != nil
+}
+
+// CostSimulationRow 单个分组（模型或日期）的实际成本与各方案模拟成本
+type CostSimulationRow struct {
+	Key           string    `json:"key"`
+	Requests      int       `json:"requests"`
+	ActualCost    float64   `json:"actual_cost_usd"`
+	ScenarioCosts []float64 `json:"scenario_costs_usd"` // 与 CostSimulationResult.Scenarios 一一对应
+}
+
+// CostSimulationResult 成本模拟结果（只读，不修改任何请求记录）
+type CostSimulationResult struct {
+	Scenarios      []string            `json:"scenarios"`
+	Requests       int                 `json:"requests"`
+	SkippedBatch   int                 `json:"skipped_batch"` // Message Batches 记录按批次聚合计费，不参与模拟
+	ActualTotal    float64             `json:"actual_total_usd"`
+	ScenarioTotals []float64           `json:"scenario_totals_usd"`
+	ByModel        []CostSimulationRow `json:"by_model"`
+	ByDay          []CostSimulationRow `json:"by_day"`
+}
+
+// SimulateCosts 基于已记录的 token 明细，按候选方案重放指定时间范围内的请求成本（what-if 模拟）
+// 筛选条件复用 QueryOptions（时间范围必填），日期分组按配置时区划分
+func (ut *UsageTracker) SimulateCosts(ctx context.Context, opts *QueryOptions, scenarios []CostScenario) (*CostSimulationResult, error) {
+	if ut.readDB == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+	if opts == nil || opts.StartDate == nil || opts.EndDate == nil || opts.EndDate.Before(*opts.StartDate) {
+		return nil, fmt.Errorf("invalid simulation time range")
+	}
+	if len(scenarios) == 0 || len(scenarios) > maxCostScenarios {
+		return nil, fmt.Errorf("simulation requires 1-%d scenarios, got %d", maxCostScenarios, len(scenarios))
+	}
+
+	where, args, err := ut.requestLogFilter(opts, nil)
+	if err != nil {
+		return nil, err
+	}
+	query := `SELECT start_time,
+		COALESCE(channel, ''), COALESCE(endpoint_name, ''), COALESCE(group_name, ''), COALESCE(model_name, ''),
+		COALESCE(input_tokens, 0), COALESCE(output_tokens, 0),
+		COALESCE(cache_creation_tokens, 0), COALESCE(cache_creation_5m_tokens, 0), COALESCE(cache_creation_1h_tokens, 0),
+		COALESCE(cache_read_tokens, 0), COALESCE(web_search_requests, 0), COALESCE(web_fetch_requests, 0),
+		COALESCE(is_batch, 0), COALESCE(total_cost_usd, 0)
+		FROM request_logs` + where
+
+	rows, err := ut.readDB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query requests for cost simulation: %w", err)
+	}
+	defer rows.Close()
+
+	result := &CostSimulationResult{
+		Scenarios:      make([]string, len(scenarios)),
+		ScenarioTotals: make([]float64, len(scenarios)),
+	}
+	for i, s := range scenarios {
+		result.Scenarios[i] = s.Name
+		if result.Scenarios[i] == "" {
+			result.Scenarios[i] = fmt.Sprintf("scenario_%d", i+1)
+		}
+	}
+
+	byModel := make(map[string]*CostSimulationRow)
+	byDay := make(map[string]*CostSimulationRow)
+	group := func(m map[string]*CostSimulationRow, key string) *CostSimulationRow {
+		row, ok := m[key]
+		if !ok {
+			row = &CostSimulationRow{Key: key, ScenarioCosts: make([]float64, len(scenarios))}
+			m[key] = row
+		}
+		return row
+	}
+
+	for rows.Next() {
+		var (
+			startTime                               time.Time
+			channel, endpointName, groupName, model string
+			usage                                   TokenUsage
+			isBatch                                 bool
+			actualCost                              float64
+		)
+		if err := rows.Scan(&startTime,
+			&channel, &endpointName, &groupName, &model,
+			&usage.InputTokens, &usage.OutputTokens,
+			&usage.CacheCreationTokens, &usage.CacheCreation5mTokens, &usage.CacheCreation1hTokens,
+			&usage.CacheReadTokens, &usage.WebSearchRequests, &usage.WebFetchRequests,
+			&isBatch, &actualCost,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan request for cost simulation: %w", err)
+		}
+		if isBatch {
+			result.SkippedBatch++
+			continue
+		}
+
+		at := ut.requestLocalTime(startTime)
+		modelRow := group(byModel, model)
+		dayRow := group(byDay, at.Format("2006-01-02"))
+
+		result.Requests++
+		result.ActualTotal += actualCost
+		for _, row := range []*CostSimulationRow{modelRow, dayRow} {
+			row.Requests++
+			row.ActualCost += actualCost
+		}
+		for i := range scenarios {
+			cost := ut.simulateRequestCost(&scenarios[i], channel, groupName, endpointName, model, &usage, at)
+			result.ScenarioTotals[i] += cost.TotalCost
+			modelRow.ScenarioCosts[i] += cost.TotalCost
+			dayRow.ScenarioCosts[i] += cost.TotalCost
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating requests for cost simulation: %w", err)
+	}
+
+	result.ByModel = make([]CostSimulationRow, 0, len(byModel))
+	for _, row := range byModel {
+		result.ByModel = append(result.ByModel, *row)
+	}
+	sort.Slice(result.ByModel, func(i, j int) bool {
+		if result.ByModel[i].ActualCost != result.ByModel[j].ActualCost {
+			return result.ByModel[i].ActualCost > result.ByModel[j].ActualCost
+		}
+		return result.ByModel[i].Key < result.ByModel[j].Key
+	})
+	result.ByDay = make([]CostSimulationRow, 0, len(byDay))
+	for _, row := range byDay {
+		result.ByDay = append(result.ByDay, *row)
+	}
+	sort.Slice(result.ByDay, func(i, j int) bool {
+		return result.ByDay[i].Key < result.ByDay[j].Key
+	})
+
+	return result, nil
+}
+
+// simulateRequestCost 按模拟方案计算单次请求成本
+func (ut *UsageTracker) simulateRequestCost(s *CostScenario, channel, groupName, endpointName, model string, usage *TokenUsage, at time.Time) CostBreakdown {
+	if s.Channel != "" {
+		channel = s.Channel
+	}
+	if s.EndpointName != "" {
+		endpointName = s.EndpointName
+	}
+	if !s.custom() {
+		return ut.CalculateRequestCost(channel, groupName, endpointName, model, usage, at)
+	}
+
+	rules := ut.costRules()
+	pricing, source, multiplier := rules.resolve(channel, groupName, endpointName, model, at)
+	if candidate, ok := s.Pricing[model]; ok {
+		// 候选定价只替换 token 单价，服务端工具沿用当前定价
+		candidate.WebSearch, candidate.WebFetch = pricing.WebSearch, pricing.WebFetch
+		pricing, source = candidate, PriceSourceModelPricing
+	}
+	if s.PriceBook != nil {
+		if cost, ok := s.PriceBook.Cost(model, usage, &pricing); ok {
+			return cost
+		}
+	} else if s.Multiplier == nil {
+		// 仅替换模型定价：渠道/端点价目表照常生效（相对模式以候选价格为基准）
+		if cost, ok := rules.books.Cost(channel, groupName, endpointName, model, usage, &pricing); ok {
+			return cost
+		}
+	}
+	if s.Multiplier != nil {
+		multiplier = s.Multiplier
+	}
+	cost := CalculateCostV2(usage, &pricing, multiplier)
+	cost.PriceSource = source
+	return cost
+}
+
+// CSV 导出模拟结果：按模型、按日期与合计三部分，每个方案输出模拟成本与相对实际成本的差额
+func (r *CostSimulationResult) CSV() ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+
+	header := []string{"breakdown", "key", "requests", "actual_cost_usd"}
+	for _, name := range r.Scenarios {
+		header = append(header, name+"_cost_usd", name+"_delta_usd")
+	}
+	if err := w.Write(header); err != nil {
+		return nil, fmt.Errorf("failed to write simulation CSV header: %w", err)
+	}
+
+	record := func(breakdown string, row CostSimulationRow) []string {
+		fields := []string{breakdown, row.Key, strconv.Itoa(row.Requests), formatSimulationCost(row.ActualCost)}
+		for _, cost := range row.ScenarioCosts {
+			fields = append(fields, formatSimulationCost(cost), formatSimulationCost(cost-row.ActualCost))
+		}
+		return fields
+	}
+	for _, row := range r.ByModel {
+		if err := w.Write(record("model", row)); err != nil {
+			return nil, fmt.Errorf("failed to write simulation CSV row: %w", err)
+		}
+	}
+	for _, row := range r.ByDay {
+		if err := w.Write(record("day", row)); err != nil {
+			return nil, fmt.Errorf("failed to write simulation CSV row: %w", err)
+		}
+	}
+	total := CostSimulationRow{Key: "all", Requests: r.Requests, ActualCost: r.ActualTotal, ScenarioCosts: r.ScenarioTotals}
+	if err := w.Write(record("total", total)); err != nil {
+		return nil, fmt.Errorf("failed to write simulation CSV row: %w", err)
+	}
+
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, fmt.Errorf("failed to flush simulation CSV: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// formatSimulationCost 成本保留 6 位小数（与请求导出一致）
+func formatSimulationCost(v float64) string {
+	return strconv.FormatFloat(v, 'f', 6, 64)
+}
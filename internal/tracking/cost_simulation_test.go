@@ -0,0 +1,162 @@
+package tracking
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"math"
+	"testing"
+	"time"
+)
+
+// TestSimulateCosts 测试按候选渠道/倍率/价目表重放历史请求成本（只读，不修改记录）
+func TestSimulateCosts(t *testing.T) {
+	config := &Config{
+		Enabled:         true,
+		DatabasePath:    ":memory:",
+		BufferSize:      100,
+		BatchSize:       10,
+		FlushInterval:   50 * time.Millisecond,
+		MaxRetry:        3,
+		CleanupInterval: 24 * time.Hour,
+		RetentionDays:   30,
+		ModelPricing: map[string]ModelPricing{
+			"claude-sonnet-4": {Input: 3, Output: 15},
+			"claude-haiku":    {Input: 1, Output: 5},
+		},
+	}
+	tracker, err := NewUsageTracker(config)
+	if err != nil {
+		t.Fatalf("Failed to create usage tracker: %v", err)
+	}
+	defer tracker.Close()
+
+	loc := tracker.location
+	if loc == nil {
+		loc = time.Local
+	}
+
+	// relay 渠道的 sonnet 价格为官方一半
+	tracker.UpdatePriceBooks([]PriceBook{{
+		Name:    "relay-book",
+		Channel: "relay",
+		Mode:    PriceBookModeAbsolute,
+		Entries: []PriceBookEntry{{Model: "claude-sonnet-4", Input: 1.5, Output: 7.5}},
+	}})
+
+	ctx := context.Background()
+	insert := func(requestID string, day int, channel, model string, cost float64, isBatch bool) {
+		t.Helper()
+		start := time.Date(2025, 1, day, 12, 0, 0, 0, loc)
+		_, err := tracker.writeDB.ExecContext(ctx, `INSERT INTO request_logs (
+			request_id, start_time, channel, endpoint_name, model_name, status,
+			input_tokens, output_tokens, total_cost_usd, is_batch
+		) VALUES (?, ?, ?, 'api', ?, 'completed', 1000000, 0, ?, ?)`,
+			requestID, start.Format("2006-01-02 15:04:05"), channel, model, cost, isBatch)
+		if err != nil {
+			t.Fatalf("插入测试数据失败: %v", err)
+		}
+	}
+	insert("req-sim-a", 10, "official", "claude-sonnet-4", 3, false)
+	insert("req-sim-b", 10, "official", "claude-sonnet-4", 3, false)
+	insert("req-sim-c", 11, "relay", "claude-sonnet-4", 1.5, false)
+	insert("req-sim-d", 11, "official", "claude-haiku", 1, false)
+	insert("req-sim-e", 11, "official", "claude-sonnet-4", 3, true)
+
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, loc)
+	end := time.Date(2025, 1, 31, 23, 59, 59, 0, loc)
+	opts := &QueryOptions{StartDate: &start, EndDate: &end}
+	result, err := tracker.SimulateCosts(ctx, opts, []CostScenario{
+		{Name: "all_relay", Channel: "relay"},
+		{Multiplier: &EndpointMultiplier{CostMultiplier: 0.8}},
+		{Name: "flat", PriceBook: &PriceBook{
+			Name:    "flat",
+			Mode:    PriceBookModeAbsolute,
+			Entries: []PriceBookEntry{{Model: PriceBookWildcardModel, Input: 1, PerRequestFee: 0.01}},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("成本模拟失败: %v", err)
+	}
+
+	approx := func(got, want float64) bool { return math.Abs(got-want) < 1e-9 }
+	if result.Requests != 4 || result.SkippedBatch != 1 || !approx(result.ActualTotal, 8.5) {
+		t.Fatalf("模拟汇总不符: %+v", result)
+	}
+	if result.Scenarios[1] != "scenario_2" {
+		t.Errorf("未命名方案应使用默认名称, got %v", result.Scenarios)
+	}
+	// all_relay：sonnet 按 relay 价目表 1.5，haiku 未命中价目表按模型定价 1
+	// scenario_2：模型定价 × 0.8；flat：统一 $1 + $0.01 固定费用
+	for i, want := range []float64{5.5, 8.0, 4.04} {
+		if !approx(result.ScenarioTotals[i], want) {
+			t.Errorf("方案 %s 总成本 = %v, want %v", result.Scenarios[i], result.ScenarioTotals[i], want)
+		}
+	}
+
+	if len(result.ByModel) != 2 || result.ByModel[0].Key != "claude-sonnet-4" || result.ByModel[0].Requests != 3 ||
+		!approx(result.ByModel[0].ActualCost, 7.5) || !approx(result.ByModel[0].ScenarioCosts[0], 4.5) {
+		t.Errorf("按模型结果不符: %+v", result.ByModel)
+	}
+	if len(result.ByDay) != 2 || result.ByDay[0].Key != "2025-01-10" || !approx(result.ByDay[0].ActualCost, 6) ||
+		result.ByDay[1].Key != "2025-01-11" || !approx(result.ByDay[1].ScenarioCosts[1], 3.2) {
+		t.Errorf("按日期结果不符: %+v", result.ByDay)
+	}
+
+	var storedCost float64
+	if err := tracker.readDB.QueryRow(`SELECT total_cost_usd FROM request_logs WHERE request_id = 'req-sim-a'`).Scan(&storedCost); err != nil || storedCost != 3 {
+		t.Fatalf("模拟不应修改记录: cost=%v err=%v", storedCost, err)
+	}
+
+	data, err := result.CSV()
+	if err != nil {
+		t.Fatalf("导出 CSV 失败: %v", err)
+	}
+	records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil {
+		t.Fatalf("解析 CSV 失败: %v", err)
+	}
+	// 表头 + 2 个模型 + 2 天 + 合计
+	if len(records) != 6 || len(records[0]) != 4+2*3 || records[0][4] != "all_relay_cost_usd" {
+		t.Fatalf("CSV 结构不符: %v", records)
+	}
+	total := records[5]
+	if total[0] != "total" || total[3] != "8.500000" || total[8] != "4.040000" || total[9] != "-4.460000" {
+		t.Errorf("CSV 合计行不符: %v", total)
+	}
+
+	// 候选模型定价：仅覆盖列出的模型，其余沿用当前定价，relay 的 sonnet 仍按价目表 1.5
+	candidate, err := tracker.SimulateCosts(ctx, opts, []CostScenario{
+		{Pricing: map[string]ModelPricing{"claude-haiku": {Input: 2, Output: 10}}},
+	})
+	if err != nil || !approx(candidate.ScenarioTotals[0], 9.5) {
+		t.Errorf("候选定价模拟结果不符: %+v, %v", candidate, err)
+	}
+
+	// 候选定价沿用端点倍率：official/api 倍率 0.5，仅 haiku 改价
+	tracker.UpdateEndpointMultipliers(map[string]EndpointMultiplier{
+		EndpointMultiplierKey("official", "api"): {CostMultiplier: 0.5},
+	})
+	haikuPricing := map[string]ModelPricing{"claude-haiku": {Input: 2, Output: 10}}
+	withMultiplier, err := tracker.SimulateCosts(ctx, opts, []CostScenario{
+		{Pricing: haikuPricing},
+		{Channel: "relay", Pricing: haikuPricing},
+	})
+	if err != nil {
+		t.Fatalf("成本模拟失败: %v", err)
+	}
+	// official sonnet 3 × 0.5 × 2 + relay 1.5 + haiku 2 × 0.5 = 5.5；全部路由到 relay：sonnet 1.5 × 3 + haiku 2（无倍率）= 6.5
+	for i, want := range []float64{5.5, 6.5} {
+		if !approx(withMultiplier.ScenarioTotals[i], want) {
+			t.Errorf("方案 %s 总成本 = %v, want %v", withMultiplier.Scenarios[i], withMultiplier.ScenarioTotals[i], want)
+		}
+	}
+
+	// 时间范围与方案数量校验
+	if _, err := tracker.SimulateCosts(ctx, &QueryOptions{}, []CostScenario{{}}); err == nil {
+		t.Error("缺少时间范围应返回错误")
+	}
+	if _, err := tracker.SimulateCosts(ctx, opts, nil); err == nil {
+		t.Error("缺少模拟方案应返回错误")
+	}
+}
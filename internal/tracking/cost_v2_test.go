@@ -263,6 +263,46 @@ func TestCalculateCostV2_TotalMultiplier(t *testing.T) {
 	}
 }
 
+// TestCalculateCostV2_ServerToolUse 测试服务端工具按次计费
+func TestCalculateCostV2_ServerToolUse(t *testing.T) {
+	pricing := &ModelPricing{
+		Input:     3.0,
+		Output:    15.0,
+		WebSearch: 10.0, // $10/1K searches
+		WebFetch:  2.0,
+	}
+
+	usage := &TokenUsage{
+		InputTokens:       1000000,
+		WebSearchRequests: 5,
+		WebFetchRequests:  10,
+	}
+
+	result := CalculateCostV2(usage, pricing, nil)
+
+	// Web search: 5 * $10/1K = $0.05
+	if math.Abs(result.WebSearchCost-0.05) > 0.000001 {
+		t.Errorf("Expected WebSearchCost $0.05, got $%f", result.WebSearchCost)
+	}
+	// Web fetch: 10 * $2/1K = $0.02
+	if math.Abs(result.WebFetchCost-0.02) > 0.000001 {
+		t.Errorf("Expected WebFetchCost $0.02, got $%f", result.WebFetchCost)
+	}
+	if math.Abs(result.ServerToolCost-0.07) > 0.000001 {
+		t.Errorf("Expected ServerToolCost $0.07, got $%f", result.ServerToolCost)
+	}
+	// Total: $3.00 + $0.07
+	if math.Abs(result.TotalCost-3.07) > 0.000001 {
+		t.Errorf("Expected TotalCost $3.07, got $%f", result.TotalCost)
+	}
+
+	// 总体倍率同样作用于服务端工具成本
+	scaled := CalculateCostV2(usage, pricing, &EndpointMultiplier{CostMultiplier: 2.0})
+	if math.Abs(scaled.ServerToolCost-0.14) > 0.000001 {
+		t.Errorf("Expected scaled ServerToolCost $0.14, got $%f", scaled.ServerToolCost)
+	}
+}
+
 // TestCalculateCostV2_NilInputs 测试空输入
 func TestCalculateCostV2_NilInputs(t *testing.T) {
 	pricing := &ModelPricing{
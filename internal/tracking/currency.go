@@ -0,0 +1,375 @@
+package tracking
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+)
+
+// BaseCurrency 模型定价与 total_cost_usd 使用的基准币种
+const BaseCurrency = "USD"
+
+// ExchangeRate 汇率（1 USD 可兑换的目标币种数量），自 EffectiveFrom 起生效
+// Channel 非空时仅作用于该渠道，优先于同币种的全局汇率
+type ExchangeRate struct {
+	Channel       string
+	Currency      string
+	Rate          float64
+	EffectiveFrom time.Time
+}
+
+// NormalizeCurrency 规范化币种代码（去空白、转大写，空值视为 USD）
+func NormalizeCurrency(currency string) string {
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	if currency == "" {
+		return BaseCurrency
+	}
+	return currency
+}
+
+// IsCurrencyCode 判断是否为 3 位大写字母的币种代码（ISO 4217）
+func IsCurrencyCode(code string) bool {
+	if len(code) != 3 {
+		return false
+	}
+	for _, r := range code {
+		if r < 'A' || r > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+// CurrencyConverter 按生效时间查找汇率并换算 USD 成本
+type CurrencyConverter struct {
+	rates        map[string][]ExchangeRate            // 全局汇率，按 EffectiveFrom 升序
+	channelRates map[string]map[string][]ExchangeRate // 渠道专属汇率（渠道 → 币种 → 汇率），按 EffectiveFrom 升序
+}
+
+// NewCurrencyConverter 创建汇率换算器（忽略非正汇率与 USD 自身）
+func NewCurrencyConverter(rates []ExchangeRate) *CurrencyConverter {
+	c := &CurrencyConverter{
+		rates:        make(map[string][]ExchangeRate),
+		channelRates: make(map[string]map[string][]ExchangeRate),
+	}
+	for _, r := range rates {
+		currency := NormalizeCurrency(r.Currency)
+		if currency == BaseCurrency || r.Rate <= 0 {
+			continue
+		}
+		r.Currency = currency
+		if r.Channel == "" {
+			c.rates[currency] = append(c.rates[currency], r)
+			continue
+		}
+		if c.channelRates[r.Channel] == nil {
+			c.channelRates[r.Channel] = make(map[string][]ExchangeRate)
+		}
+		c.channelRates[r.Channel][currency] = append(c.channelRates[r.Channel][currency], r)
+	}
+	sortRates := func(byCurrency map[string][]ExchangeRate) {
+		for _, list := range byCurrency {
+			sort.Slice(list, func(i, j int) bool { return list[i].EffectiveFrom.Before(list[j].EffectiveFrom) })
+		}
+	}
+	sortRates(c.rates)
+	for _, byCurrency := range c.channelRates {
+		sortRates(byCurrency)
+	}
+	return c
+}
+
+// RateAt 返回 at 时刻生效的全局汇率；USD 恒为 1，无可用汇率时返回 false
+// 若 at 早于最早一条汇率，使用最早一条（避免历史请求因缺少汇率而无法换算）
+func (c *CurrencyConverter) RateAt(currency string, at time.Time) (float64, bool) {
+	currency = NormalizeCurrency(currency)
+	if currency == BaseCurrency {
+		return 1, true
+	}
+	if c == nil {
+		return 0, false
+	}
+	return rateAt(c.rates[currency], at)
+}
+
+// ChannelRateAt 返回渠道在 at 时刻生效的汇率：优先渠道专属汇率，未配置或尚未生效时使用全局汇率
+// 全局汇率也不可用时，沿用渠道最早一条汇率（规则同 RateAt）
+func (c *CurrencyConverter) ChannelRateAt(channel, currency string, at time.Time) (float64, bool) {
+	currency = NormalizeCurrency(currency)
+	if c == nil || channel == "" || currency == BaseCurrency {
+		return c.RateAt(currency, at)
+	}
+	list := c.channelRates[channel][currency]
+	if len(list) > 0 && !list[0].EffectiveFrom.After(at) {
+		return rateAt(list, at)
+	}
+	if rate, ok := c.RateAt(currency, at); ok {
+		return rate, true
+	}
+	return rateAt(list, at)
+}
+
+// rateAt 在按生效时间升序的汇率列表中选取 at 时刻生效的汇率
+func rateAt(list []ExchangeRate, at time.Time) (float64, bool) {
+	if len(list) == 0 {
+		return 0, false
+	}
+	idx := sort.Search(len(list), func(i int) bool { return list[i].EffectiveFrom.After(at) })
+	if idx == 0 {
+		return list[0].Rate, true
+	}
+	return list[idx-1].Rate, true
+}
+
+// Convert 将 USD 金额换算为目标币种
+func (c *CurrencyConverter) Convert(amountUSD float64, currency string, at time.Time) (float64, bool) {
+	rate, ok := c.RateAt(currency, at)
+	if !ok {
+		return 0, false
+	}
+	return amountUSD * rate, true
+}
+
+// ConvertForChannel 按渠道汇率（优先渠道专属汇率）将 USD 金额换算为目标币种
+func (c *CurrencyConverter) ConvertForChannel(amountUSD float64, channel, currency string, at time.Time) (float64, bool) {
+	rate, ok := c.ChannelRateAt(channel, currency, at)
+	if !ok {
+		return 0, false
+	}
+	return amountUSD * rate, true
+}
+
+// rateSQL 返回按行（channel、start_time）选取 at 时刻生效汇率的 SQL CASE 表达式及其参数，规则同 ChannelRateAt
+// formatBound 将生效时间格式化为可与 start_time 比较的字符串；缺少全局汇率时返回 false
+func (c *CurrencyConverter) rateSQL(currency string, formatBound func(time.Time) string) (string, []interface{}, bool) {
+	currency = NormalizeCurrency(currency)
+	if c == nil || len(c.rates[currency]) == 0 {
+		return "", nil, false
+	}
+
+	var channels []string
+	for channel, byCurrency := range c.channelRates {
+		if len(byCurrency[currency]) > 0 {
+			channels = append(channels, channel)
+		}
+	}
+	sort.Strings(channels)
+
+	var b strings.Builder
+	var args []interface{}
+	b.WriteString("CASE")
+	// 渠道专属汇率生效后优先（新版本在前）
+	for _, channel := range channels {
+		list := c.channelRates[channel][currency]
+		for i := len(list) - 1; i >= 0; i-- {
+			b.WriteString(" WHEN channel = ? AND start_time >= ? THEN ?")
+			args = append(args, channel, formatBound(list[i].EffectiveFrom), list[i].Rate)
+		}
+	}
+	// 全局汇率；早于最早一条时沿用最早一条
+	global := c.rates[currency]
+	for i := len(global) - 1; i >= 1; i-- {
+		b.WriteString(" WHEN start_time >= ? THEN ?")
+		args = append(args, formatBound(global[i].EffectiveFrom), global[i].Rate)
+	}
+	b.WriteString(" ELSE ? END")
+	args = append(args, global[0].Rate)
+	return b.String(), args, true
+}
+
+// CurrencyAmounts 一条请求在结算币种与报表币种下的成本（换算失败时金额为 nil）
+type CurrencyAmounts struct {
+	BillingCurrency   string
+	BillingCost       *float64
+	ReportingCurrency string
+	ReportingCost     *float64
+}
+
+// CurrencyAccounting 多币种记账快照（渠道结算币种 + 报表币种 + 汇率）
+// 更新时整体替换，读取方无需加锁
+type CurrencyAccounting struct {
+	ReportingCurrency string
+	ChannelCurrencies map[string]string
+	Converter         *CurrencyConverter
+}
+
+// NewCurrencyAccounting 创建多币种记账快照
+func NewCurrencyAccounting(reportingCurrency string, channelCurrencies map[string]string, rates []ExchangeRate) *CurrencyAccounting {
+	normalized := make(map[string]string, len(channelCurrencies))
+	for channel, currency := range channelCurrencies {
+		normalized[channel] = NormalizeCurrency(currency)
+	}
+	return &CurrencyAccounting{
+		ReportingCurrency: NormalizeCurrency(reportingCurrency),
+		ChannelCurrencies: normalized,
+		Converter:         NewCurrencyConverter(rates),
+	}
+}
+
+// ChannelCurrency 返回渠道结算币种（未配置时为 USD）
+func (a *CurrencyAccounting) ChannelCurrency(channel string) string {
+	if a == nil {
+		return BaseCurrency
+	}
+	if currency, ok := a.ChannelCurrencies[channel]; ok {
+		return currency
+	}
+	return BaseCurrency
+}
+
+// Amounts 按请求时间的汇率（优先渠道专属汇率）计算结算币种与报表币种成本
+func (a *CurrencyAccounting) Amounts(channel string, costUSD float64, at time.Time) CurrencyAmounts {
+	amounts := CurrencyAmounts{
+		BillingCurrency:   a.ChannelCurrency(channel),
+		ReportingCurrency: BaseCurrency,
+	}
+	var converter *CurrencyConverter
+	if a != nil {
+		amounts.ReportingCurrency = a.ReportingCurrency
+		converter = a.Converter
+	}
+	if v, ok := converter.ConvertForChannel(costUSD, channel, amounts.BillingCurrency, at); ok {
+		amounts.BillingCost = &v
+	}
+	if v, ok := converter.ConvertForChannel(costUSD, channel, amounts.ReportingCurrency, at); ok {
+		amounts.ReportingCost = &v
+	}
+	return amounts
+}
+
+// nullableFloat 将可选金额转换为 SQL 参数（nil 写入 NULL）
+func nullableFloat(v *float64) interface{} {
+	if v == nil {
+		return nil
+	}
+	return *v
+}
+
+// firstString 返回可选参数的第一个值（未提供时为空）
+func firstString(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+// UpdateExchangeRates 更新汇率表（运行时动态更新）
+func (ut *UsageTracker) UpdateExchangeRates(rates []ExchangeRate) {
+	ut.mu.Lock()
+	defer ut.mu.Unlock()
+
+	ut.exchangeRates = rates
+	ut.rebuildCurrencyAccountingLocked()
+
+	slog.Info("Exchange rates updated", "rate_count", len(rates))
+}
+
+// UpdateChannelCurrencies 更新渠道结算币种（key 为渠道名）
+func (ut *UsageTracker) UpdateChannelCurrencies(currencies map[string]string) {
+	ut.mu.Lock()
+	defer ut.mu.Unlock()
+
+	ut.channelCurrencies = currencies
+	ut.rebuildCurrencyAccountingLocked()
+
+	slog.Info("Channel billing currencies updated", "channel_count", len(currencies))
+}
+
+// ReportingCurrency 返回统一报表币种
+func (ut *UsageTracker) ReportingCurrency() string {
+	if ut == nil || ut.config == nil {
+		return BaseCurrency
+	}
+	return NormalizeCurrency(ut.config.ReportingCurrency)
+}
+
+// ResolveDisplayCurrency 解析展示币种（空值使用报表币种）
+func (ut *UsageTracker) ResolveDisplayCurrency(currency string) string {
+	if strings.TrimSpace(currency) == "" {
+		return ut.ReportingCurrency()
+	}
+	return NormalizeCurrency(currency)
+}
+
+// currencyAccounting 返回当前多币种记账快照
+func (ut *UsageTracker) currencyAccounting() *CurrencyAccounting {
+	ut.mu.RLock()
+	accounting := ut.currency
+	ut.mu.RUnlock()
+	if accounting == nil {
+		return NewCurrencyAccounting(ut.ReportingCurrency(), nil, nil)
+	}
+	return accounting
+}
+
+// rebuildCurrencyAccountingLocked 重建记账快照并同步到 ArchiveManager（调用方需持有 ut.mu）
+func (ut *UsageTracker) rebuildCurrencyAccountingLocked() {
+	ut.currency = NewCurrencyAccounting(ut.ReportingCurrency(), ut.channelCurrencies, ut.exchangeRates)
+	if ut.archiveManager != nil {
+		ut.archiveManager.UpdateCurrencyAccounting(ut.currency)
+	}
+}
+
+// DisplayCostExpr 返回按展示币种取成本的 SQL 表达式及其参数（用于 SUM 聚合）
+// 结算币种或报表币种与展示币种一致时直接使用写入时换算的金额（保留当时汇率），
+// 否则按请求时刻生效的渠道汇率（优先渠道专属汇率）由 total_cost_usd 换算，同一条记录的金额不随查询日期变化
+// 展示币种缺少全局汇率时返回错误
+func (ut *UsageTracker) DisplayCostExpr(currency string) (string, []interface{}, error) {
+	currency = NormalizeCurrency(currency)
+	if currency == BaseCurrency {
+		return "total_cost_usd", nil, nil
+	}
+	rateExpr, rateArgs, ok := ut.currencyAccounting().Converter.rateSQL(currency, ut.formatStartTimeQueryBound)
+	if !ok {
+		return "", nil, fmt.Errorf("缺少币种 %s 的汇率", currency)
+	}
+	expr := `CASE
+			WHEN billing_currency = ? AND billing_cost IS NOT NULL THEN billing_cost
+			WHEN reporting_currency = ? AND reporting_cost IS NOT NULL THEN reporting_cost
+			ELSE total_cost_usd * (` + rateExpr + `)
+		END`
+	return expr, append([]interface{}{currency, currency}, rateArgs...), nil
+}
+
+// displayCost 按展示币种计算单条请求成本（规则与 DisplayCostExpr 一致）
+func (ut *UsageTracker) displayCost(detail *RequestDetail, currency string) (float64, error) {
+	currency = NormalizeCurrency(currency)
+	if currency == BaseCurrency {
+		return detail.TotalCostUSD, nil
+	}
+	if detail.BillingCurrency == currency && detail.BillingCost != nil {
+		return *detail.BillingCost, nil
+	}
+	if detail.ReportingCurrency == currency && detail.ReportingCost != nil {
+		return *detail.ReportingCost, nil
+	}
+	v, ok := ut.currencyAccounting().Converter.ConvertForChannel(detail.TotalCostUSD, detail.Channel, currency, ut.requestLocalTime(detail.StartTime))
+	if !ok {
+		return 0, fmt.Errorf("缺少币种 %s 的汇率", currency)
+	}
+	return v, nil
+}
+
+// fillDisplayCost 为导出记录填充展示币种成本
+func (ut *UsageTracker) fillDisplayCost(details []RequestDetail, currency string) error {
+	currency = ut.ResolveDisplayCurrency(currency)
+	for i := range details {
+		cost, err := ut.displayCost(&details[i], currency)
+		if err != nil {
+			return err
+		}
+		details[i].DisplayCurrency = currency
+		details[i].DisplayCost = cost
+	}
+	return nil
+}
+
+// formatOptionalCost 格式化可选金额（nil 输出空字符串）
+func formatOptionalCost(v *float64) string {
+	if v == nil {
+		return ""
+	}
+	return fmt.Sprintf("%.6f", *v)
+}
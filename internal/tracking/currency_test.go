@@ -0,0 +1,234 @@
+package tracking
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+)
+
+// TestCurrencyConverter_RateAt 测试按生效时间选取汇率
+func TestCurrencyConverter_RateAt(t *testing.T) {
+	jan := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	feb := time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC)
+	converter := NewCurrencyConverter([]ExchangeRate{
+		{Currency: "cny", Rate: 7.2, EffectiveFrom: feb},
+		{Currency: "CNY", Rate: 7.1, EffectiveFrom: jan},
+		{Currency: "EUR", Rate: 0, EffectiveFrom: jan}, // 非正汇率忽略
+	})
+
+	tests := []struct {
+		name     string
+		currency string
+		at       time.Time
+		want     float64
+		wantOK   bool
+	}{
+		{"USD 恒为 1", "usd", jan, 1, true},
+		{"早于首条汇率使用首条", "CNY", jan.AddDate(0, -1, 0), 7.1, true},
+		{"生效时刻当天", "CNY", jan, 7.1, true},
+		{"第一段区间", "CNY", feb.Add(-time.Second), 7.1, true},
+		{"切换到新汇率", "CNY", feb, 7.2, true},
+		{"无效汇率被忽略", "EUR", feb, 0, false},
+		{"未知币种", "JPY", feb, 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := converter.RateAt(tt.currency, tt.at)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("%s: RateAt(%s) = (%v, %v), want (%v, %v)", tt.name, tt.currency, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+// TestCurrencyAccounting_Amounts 测试结算币种与报表币种成本换算
+func TestCurrencyAccounting_Amounts(t *testing.T) {
+	jan := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	accounting := NewCurrencyAccounting("eur", map[string]string{"relay": "cny", "broken": "JPY"}, []ExchangeRate{
+		{Currency: "CNY", Rate: 7.0, EffectiveFrom: jan},
+		{Currency: "EUR", Rate: 0.9, EffectiveFrom: jan},
+	})
+
+	amounts := accounting.Amounts("relay", 2.0, jan.AddDate(0, 1, 0))
+	if amounts.BillingCurrency != "CNY" || amounts.BillingCost == nil || math.Abs(*amounts.BillingCost-14.0) > 1e-9 {
+		t.Errorf("结算币种成本不符: %+v", amounts)
+	}
+	if amounts.ReportingCurrency != "EUR" || amounts.ReportingCost == nil || math.Abs(*amounts.ReportingCost-1.8) > 1e-9 {
+		t.Errorf("报表币种成本不符: %+v", amounts)
+	}
+
+	// 未配置渠道：按 USD 结算
+	if amounts := accounting.Amounts("official", 2.0, jan); amounts.BillingCurrency != "USD" || *amounts.BillingCost != 2.0 {
+		t.Errorf("默认结算币种不符: %+v", amounts)
+	}
+
+	// 缺少汇率：金额为 nil（写入 NULL）
+	if amounts := accounting.Amounts("broken", 2.0, jan); amounts.BillingCurrency != "JPY" || amounts.BillingCost != nil {
+		t.Errorf("缺少汇率时结算成本应为 nil: %+v", amounts)
+	}
+
+	// nil 快照：全部按 USD
+	var empty *CurrencyAccounting
+	if amounts := empty.Amounts("relay", 2.0, jan); amounts.BillingCurrency != "USD" || amounts.ReportingCurrency != "USD" || *amounts.ReportingCost != 2.0 {
+		t.Errorf("nil 快照应按 USD 记账: %+v", amounts)
+	}
+}
+
+// TestCurrencyAccounting_ChannelRates 测试渠道专属汇率优先，未配置的渠道回退到全局汇率
+func TestCurrencyAccounting_ChannelRates(t *testing.T) {
+	jan := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	feb := jan.AddDate(0, 1, 0)
+	accounting := NewCurrencyAccounting("cny", map[string]string{"relay": "CNY", "other": "CNY"}, []ExchangeRate{
+		{Currency: "CNY", Rate: 7.2, EffectiveFrom: jan},
+		{Channel: "relay", Currency: "CNY", Rate: 7.0, EffectiveFrom: feb},
+	})
+
+	tests := []struct {
+		name    string
+		channel string
+		at      time.Time
+		want    float64
+	}{
+		{"渠道汇率生效后使用渠道汇率", "relay", feb, 14.0},
+		{"渠道汇率生效前使用全局汇率", "relay", jan, 14.4},
+		{"未配置渠道汇率时使用全局汇率", "other", feb, 14.4},
+	}
+	for _, tt := range tests {
+		amounts := accounting.Amounts(tt.channel, 2.0, tt.at)
+		if amounts.BillingCost == nil || math.Abs(*amounts.BillingCost-tt.want) > 1e-9 {
+			t.Errorf("%s: 结算成本 = %v, want %v", tt.name, amounts.BillingCost, tt.want)
+		}
+		if amounts.ReportingCost == nil || math.Abs(*amounts.ReportingCost-tt.want) > 1e-9 {
+			t.Errorf("%s: 报表成本 = %v, want %v", tt.name, amounts.ReportingCost, tt.want)
+		}
+	}
+
+	// 全局 RateAt 不受渠道汇率影响
+	if rate, ok := accounting.Converter.RateAt("CNY", feb); !ok || rate != 7.2 {
+		t.Errorf("RateAt(CNY) = (%v, %v), want (7.2, true)", rate, ok)
+	}
+
+	// 没有全局汇率时，渠道汇率生效前沿用渠道最早一条
+	channelOnly := NewCurrencyConverter([]ExchangeRate{{Channel: "relay", Currency: "JPY", Rate: 150, EffectiveFrom: feb}})
+	if rate, ok := channelOnly.ChannelRateAt("relay", "JPY", jan); !ok || rate != 150 {
+		t.Errorf("ChannelRateAt(relay, JPY) = (%v, %v), want (150, true)", rate, ok)
+	}
+	if _, ok := channelOnly.ChannelRateAt("other", "JPY", feb); ok {
+		t.Error("其他渠道不应使用 relay 的专属汇率")
+	}
+}
+
+// TestDisplayCost 测试展示币种成本优先使用写入时换算的金额
+func TestDisplayCost(t *testing.T) {
+	jan := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	ut := &UsageTracker{config: &Config{ReportingCurrency: "EUR"}}
+	ut.UpdateExchangeRates([]ExchangeRate{
+		{Currency: "CNY", Rate: 7.3, EffectiveFrom: jan},
+		{Currency: "EUR", Rate: 0.9, EffectiveFrom: jan},
+	})
+
+	billing, reporting := 14.0, 1.8
+	detail := &RequestDetail{
+		TotalCostUSD:      2.0,
+		BillingCurrency:   "CNY",
+		BillingCost:       &billing,
+		ReportingCurrency: "EUR",
+		ReportingCost:     &reporting,
+	}
+
+	tests := []struct {
+		currency string
+		want     float64
+	}{
+		{"CNY", 14.0}, // 结算币种：使用写入时金额（而非当前汇率 7.3）
+		{"EUR", 1.8},  // 报表币种：使用写入时金额
+		{"USD", 2.0},
+	}
+	for _, tt := range tests {
+		got, err := ut.displayCost(detail, tt.currency)
+		if err != nil || math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("displayCost(%s) = (%v, %v), want %v", tt.currency, got, err, tt.want)
+		}
+	}
+
+	// 旧数据（无多币种金额）：按请求时刻的汇率换算
+	legacy := &RequestDetail{TotalCostUSD: 2.0, StartTime: jan.Add(time.Hour)}
+	if got, err := ut.displayCost(legacy, "CNY"); err != nil || math.Abs(got-14.6) > 1e-9 {
+		t.Errorf("旧数据 displayCost(CNY) = (%v, %v), want 14.6", got, err)
+	}
+	if _, err := ut.displayCost(legacy, "JPY"); err == nil {
+		t.Error("缺少汇率时应返回错误")
+	}
+	if got := ut.ResolveDisplayCurrency(""); got != "EUR" {
+		t.Errorf("默认展示币种 = %s, want EUR", got)
+	}
+}
+
+// TestDisplayCost_RequestTimeChannelRate 测试展示币种按请求时刻的渠道汇率换算（SQL 与单条计算一致，不随当前汇率变化）
+func TestDisplayCost_RequestTimeChannelRate(t *testing.T) {
+	tracker, err := NewUsageTracker(&Config{
+		Enabled:         true,
+		DatabasePath:    ":memory:",
+		BufferSize:      100,
+		BatchSize:       10,
+		FlushInterval:   50 * time.Millisecond,
+		MaxRetry:        3,
+		CleanupInterval: 24 * time.Hour,
+		RetentionDays:   30,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create usage tracker: %v", err)
+	}
+	defer tracker.Close()
+
+	now := tracker.now()
+	repriced := now.Add(-24 * time.Hour)
+	tracker.UpdateExchangeRates([]ExchangeRate{
+		{Currency: "CNY", Rate: 7.0, EffectiveFrom: repriced.Add(-30 * 24 * time.Hour)},
+		{Currency: "CNY", Rate: 8.0, EffectiveFrom: repriced},
+		{Channel: "relay", Currency: "CNY", Rate: 7.5, EffectiveFrom: repriced.Add(-30 * 24 * time.Hour)},
+	})
+
+	before := repriced.Add(-time.Hour)
+	rows := []struct {
+		channel string
+		at      time.Time
+		want    float64
+	}{
+		{"official", before, 7.0},                  // 调价前的全局汇率（而非当前 8.0）
+		{"official", repriced.Add(time.Hour), 8.0}, // 调价后的全局汇率
+		{"relay", before, 7.5},                     // 渠道专属汇率优先
+	}
+	ctx := context.Background()
+	var wantTotal float64
+	for i, r := range rows {
+		_, err := tracker.writeDB.ExecContext(ctx, `INSERT INTO request_logs (
+			request_id, start_time, channel, endpoint_name, model_name, status, total_cost_usd
+		) VALUES (?, ?, ?, 'ep', 'claude-sonnet-4', 'completed', 1)`,
+			"req-display-"+string(rune('a'+i)), tracker.formatStartTimeQueryBound(r.at), r.channel)
+		if err != nil {
+			t.Fatalf("插入测试数据失败: %v", err)
+		}
+		wantTotal += r.want
+
+		got, err := tracker.displayCost(&RequestDetail{TotalCostUSD: 1, Channel: r.channel, StartTime: r.at}, "CNY")
+		if err != nil || math.Abs(got-r.want) > 1e-9 {
+			t.Errorf("displayCost(%s, %s) = (%v, %v), want %v", r.channel, r.at, got, err, r.want)
+		}
+	}
+
+	expr, args, err := tracker.DisplayCostExpr("CNY")
+	if err != nil {
+		t.Fatalf("DisplayCostExpr 失败: %v", err)
+	}
+	var total float64
+	if err := tracker.readDB.QueryRowContext(ctx, `SELECT COALESCE(SUM(`+expr+`), 0) FROM request_logs`, args...).Scan(&total); err != nil {
+		t.Fatalf("查询展示币种成本失败: %v", err)
+	}
+	if math.Abs(total-wantTotal) > 1e-9 {
+		t.Errorf("SQL 展示币种合计 = %v, want %v", total, wantTotal)
+	}
+
+	if _, _, err := tracker.DisplayCostExpr("JPY"); err == nil {
+		t.Error("缺少汇率时应返回错误")
+	}
+}
@@ -81,8 +81,10 @@ func (ut *UsageTracker) flushBatch(events []RequestEvent) {
 	}
 
 	var retryCount int
+	var lastErr error
 	for retryCount < ut.config.MaxRetry {
 		if err := ut.processBatch(events); err != nil {
+			lastErr = err
 			// 使用错误处理器处理错误
 			if ut.errorHandler != nil && ut.errorHandler.HandleDatabaseError(err, "flushBatch") {
 				// 错误已处理，重试操作
@@ -120,6 +122,7 @@ func (ut *UsageTracker) flushBatch(events []RequestEvent) {
 	slog.Error("Failed to process batch after all retries",
 		"batch_size", len(events),
 		"max_retry", ut.config.MaxRetry)
+	ut.reportDatabaseError("flushBatch", fmt.Errorf("%d 条事件写入失败（重试 %d 次）: %w", len(events), ut.config.MaxRetry, lastErr))
 }
 
 // processBatch 处理一批事件（重构为使用写队列）
@@ -127,6 +130,7 @@ func (ut *UsageTracker) processBatch(events []RequestEvent) error {
 	successCount := 0
 	failedCount := 0
 	var firstErr error
+	var rollupRequestIDs []string // 热池降级写入的请求，写入后重建汇总
 
 	for _, event := range events {
 		// 特殊处理flush事件
@@ -136,6 +140,23 @@ func (ut *UsageTracker) processBatch(events []RequestEvent) error {
 			continue
 		}
 
+		// 已入库请求的 Token 更新需要读取原记录计价，在独立事务中处理
+		if event.Type == "failed_request_tokens" || event.Type == "token_recovery" {
+			if err := ut.applyTokenUpdateEvent(ut.ctx, event); err != nil {
+				failedCount++
+				if firstErr == nil {
+					firstErr = err
+				}
+				slog.Error("Token update failed",
+					"error", err,
+					"event_type", event.Type,
+					"request_id", event.RequestID)
+				continue
+			}
+			successCount++
+			continue
+		}
+
 		// 构建写操作请求
 		query, args, err := ut.buildWriteQuery(event)
 		if err != nil {
@@ -175,6 +196,9 @@ func (ut *UsageTracker) processBatch(events []RequestEvent) error {
 					continue
 				}
 				successCount++
+				if ut.hotPoolEnabled && legacyEventAffectsRollups(event.Type) {
+					rollupRequestIDs = append(rollupRequestIDs, event.RequestID)
+				}
 			case <-ut.ctx.Done():
 				return ut.ctx.Err()
 			}
@@ -184,6 +208,10 @@ func (ut *UsageTracker) processBatch(events []RequestEvent) error {
 		}
 	}
 
+	if len(rollupRequestIDs) > 0 {
+		ut.rebuildRollupsForRequests(ut.ctx, rollupRequestIDs)
+	}
+
 	if failedCount > 0 {
 		slog.Warn("Some events failed to process",
 			"success", successCount,
@@ -210,6 +238,10 @@ func (ut *UsageTracker) buildWriteQuery(event RequestEvent) (string, []interface
 		return ut.buildSuccessQuery(event)
 	case "final_failure": // 新增：失败/取消完成
 		return ut.buildFinalFailureQuery(event)
+	case "tags": // 请求标签（写入 request_tags）
+		return ut.buildTagsQuery(event)
+	case "attempt": // 上游尝试（写入 request_attempts）
+		return ut.buildAttemptQuery(event)
 	case "complete":
 		// 对于complete事件，直接使用传入的持续时间，不需要查询数据库
 		data, ok := event.Data.(RequestCompleteData)
@@ -261,127 +293,162 @@ func (ut *UsageTracker) buildWriteQuery(event RequestEvent) (string, []interface
 		}
 
 		return query, args, nil
-	case "failed_request_tokens":
-		// 处理失败请求Token事件：只记录Token统计，不更新请求状态
-		data, ok := event.Data.(RequestCompleteData)
-		if !ok {
-			return "", nil, fmt.Errorf("invalid failed_request_tokens event data type")
-		}
-
-		// 计算成本（使用完整的 5m/1h 字段）
-		tokens := &TokenUsage{
-			InputTokens:           data.InputTokens,
-			OutputTokens:          data.OutputTokens,
-			CacheCreationTokens:   data.CacheCreationTokens,
-			CacheCreation5mTokens: data.CacheCreation5mTokens,
-			CacheCreation1hTokens: data.CacheCreation1hTokens,
-			CacheReadTokens:       data.CacheReadTokens,
-		}
+	default:
+		return "", nil, fmt.Errorf("unknown event type: %s", event.Type)
+	}
+}
 
-		pricing := ut.GetPricing(data.ModelName)
-		costBreakdown := CalculateCostV2(tokens, &pricing, nil)
+// applyTokenUpdateEvent 更新已入库请求的 Token 统计与成本（失败请求 Token / Token 恢复），不改变请求状态
+// 按请求的渠道/分组/端点与开始时间计价（价目表、端点倍率、历史定价版本），并写入结算/报表币种金额
+func (ut *UsageTracker) applyTokenUpdateEvent(ctx context.Context, event RequestEvent) error {
+	data, ok := event.Data.(RequestCompleteData)
+	if !ok {
+		return fmt.Errorf("invalid %s event data type", event.Type)
+	}
 
-		// 只更新Token相关字段和成本，不更新状态
-		query := fmt.Sprintf(`UPDATE request_logs SET
-			model_name = COALESCE(?, model_name),
-			input_tokens = ?,
-			output_tokens = ?,
-			cache_creation_tokens = ?,
-			cache_creation_5m_tokens = ?,
-			cache_creation_1h_tokens = ?,
-			cache_read_tokens = ?,
-			input_cost_usd = ?,
-			output_cost_usd = ?,
-			cache_creation_cost_usd = ?,
-			cache_creation_5m_cost_usd = ?,
-			cache_creation_1h_cost_usd = ?,
-			cache_read_cost_usd = ?,
-			total_cost_usd = ?,
-			duration_ms = COALESCE(?, duration_ms),
-			updated_at = %s
-		WHERE request_id = ?`, ut.adapter.BuildDateTimeNow())
+	ut.writeMu.Lock()
+	defer ut.writeMu.Unlock()
 
-		args := []interface{}{
-			data.ModelName,
-			data.InputTokens,
-			data.OutputTokens,
-			data.CacheCreationTokens,
-			data.CacheCreation5mTokens,
-			data.CacheCreation1hTokens,
-			data.CacheReadTokens,
-			costBreakdown.InputCost,
-			costBreakdown.OutputCost,
-			costBreakdown.CacheCreationCost,
-			costBreakdown.CacheCreation5mCost,
-			costBreakdown.CacheCreation1hCost,
-			costBreakdown.CacheReadCost,
-			costBreakdown.TotalCost,
-			data.Duration.Milliseconds(),
-			event.RequestID,
-		}
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
 
-		return query, args, nil
-	case "token_recovery":
-		// 🔧 [Fallback修复] 处理Token恢复事件：只更新Token字段和成本，不更新状态
-		data, ok := event.Data.(RequestCompleteData)
-		if !ok {
-			return "", nil, fmt.Errorf("invalid token_recovery event data type")
-		}
+	tx, err := ut.writeDB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var (
+		startTime time.Time
+		groupName string
+		old       ActiveRequest // 更新前已计入用量汇总的值
+		oldCost   float64
+	)
+	err = tx.QueryRowContext(ctx, `SELECT start_time,
+		COALESCE(channel, ''), COALESCE(group_name, ''), COALESCE(endpoint_name, ''), COALESCE(model_name, ''),
+		COALESCE(status, ''), COALESCE(duration_ms, 0),
+		COALESCE(input_tokens, 0), COALESCE(output_tokens, 0),
+		COALESCE(cache_creation_tokens, 0), COALESCE(cache_read_tokens, 0), COALESCE(total_cost_usd, 0)
+		FROM request_logs WHERE request_id = ?`, event.RequestID).Scan(
+		&startTime, &old.Channel, &groupName, &old.EndpointName, &old.ModelName,
+		&old.Status, &old.DurationMs,
+		&old.InputTokens, &old.OutputTokens,
+		&old.CacheCreationTokens, &old.CacheReadTokens, &oldCost)
+	if err == sql.ErrNoRows {
+		slog.Debug("Token update skipped, request not found", "event_type", event.Type, "request_id", event.RequestID)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load request for token update: %w", err)
+	}
+	updated := &ActiveRequest{
+		Channel:             old.Channel,
+		EndpointName:        old.EndpointName,
+		ModelName:           old.ModelName,
+		Status:              old.Status,
+		DurationMs:          old.DurationMs,
+		InputTokens:         data.InputTokens,
+		OutputTokens:        data.OutputTokens,
+		CacheCreationTokens: data.CacheCreationTokens,
+		CacheReadTokens:     data.CacheReadTokens,
+	}
+	if data.ModelName != "" && data.ModelName != "unknown" {
+		updated.ModelName = data.ModelName
+	}
 
-		// 计算成本（使用完整的 5m/1h 字段）
-		tokens := &TokenUsage{
-			InputTokens:           data.InputTokens,
-			OutputTokens:          data.OutputTokens,
-			CacheCreationTokens:   data.CacheCreationTokens,
-			CacheCreation5mTokens: data.CacheCreation5mTokens,
-			CacheCreation1hTokens: data.CacheCreation1hTokens,
-			CacheReadTokens:       data.CacheReadTokens,
-		}
+	tokens := &TokenUsage{
+		InputTokens:           data.InputTokens,
+		OutputTokens:          data.OutputTokens,
+		CacheCreationTokens:   data.CacheCreationTokens,
+		CacheCreation5mTokens: data.CacheCreation5mTokens,
+		CacheCreation1hTokens: data.CacheCreation1hTokens,
+		CacheReadTokens:       data.CacheReadTokens,
+		WebSearchRequests:     data.WebSearchRequests,
+		WebFetchRequests:      data.WebFetchRequests,
+	}
+	at := ut.requestLocalTime(startTime)
+	cost := ut.CalculateRequestCost(updated.Channel, groupName, updated.EndpointName, updated.ModelName, tokens, at)
+	amounts := ut.currencyAccounting().Amounts(updated.Channel, cost.TotalCost, at)
+
+	// 失败请求同时记录持续时间；Token 恢复不更新时间相关字段
+	var durationMs interface{}
+	if event.Type == "failed_request_tokens" {
+		durationMs = data.Duration.Milliseconds()
+		updated.DurationMs = data.Duration.Milliseconds()
+	}
 
-		pricing := ut.GetPricing(data.ModelName)
-		costBreakdown := CalculateCostV2(tokens, &pricing, nil)
+	query := fmt.Sprintf(`UPDATE request_logs SET
+		model_name = ?,
+		input_tokens = ?,
+		output_tokens = ?,
+		cache_creation_tokens = ?,
+		cache_creation_5m_tokens = ?,
+		cache_creation_1h_tokens = ?,
+		cache_read_tokens = ?,
+		input_cost_usd = ?,
+		output_cost_usd = ?,
+		cache_creation_cost_usd = ?,
+		cache_creation_5m_cost_usd = ?,
+		cache_creation_1h_cost_usd = ?,
+		cache_read_cost_usd = ?,
+		web_search_requests = ?,
+		web_fetch_requests = ?,
+		server_tool_cost_usd = ?,
+		total_cost_usd = ?,
+		pricing_tier = ?,
+		price_source = ?,
+		request_fee_usd = ?,
+		billing_currency = ?,
+		billing_cost = ?,
+		reporting_currency = ?,
+		reporting_cost = ?,
+		duration_ms = COALESCE(?, duration_ms),
+		updated_at = %s
+	WHERE request_id = ?`, ut.adapter.BuildDateTimeNow())
 
-		// 🔧 专用于恢复场景：更新任何状态的请求的Token字段，因为这是恢复不完整的数据
-		query := fmt.Sprintf(`UPDATE request_logs SET
-			model_name = COALESCE(?, model_name),
-			input_tokens = ?,
-			output_tokens = ?,
-			cache_creation_tokens = ?,
-			cache_creation_5m_tokens = ?,
-			cache_creation_1h_tokens = ?,
-			cache_read_tokens = ?,
-			input_cost_usd = ?,
-			output_cost_usd = ?,
-			cache_creation_cost_usd = ?,
-			cache_creation_5m_cost_usd = ?,
-			cache_creation_1h_cost_usd = ?,
-			cache_read_cost_usd = ?,
-			total_cost_usd = ?,
-			updated_at = %s
-		WHERE request_id = ?`, ut.adapter.BuildDateTimeNow())
+	if _, err := tx.ExecContext(ctx, query,
+		updated.ModelName,
+		data.InputTokens,
+		data.OutputTokens,
+		data.CacheCreationTokens,
+		data.CacheCreation5mTokens,
+		data.CacheCreation1hTokens,
+		data.CacheReadTokens,
+		cost.InputCost,
+		cost.OutputCost,
+		cost.CacheCreationCost,
+		cost.CacheCreation5mCost,
+		cost.CacheCreation1hCost,
+		cost.CacheReadCost,
+		data.WebSearchRequests,
+		data.WebFetchRequests,
+		cost.ServerToolCost,
+		cost.TotalCost,
+		cost.PricingTier,
+		nullString(cost.PriceSource),
+		cost.RequestFee,
+		amounts.BillingCurrency,
+		nullableFloat(amounts.BillingCost),
+		amounts.ReportingCurrency,
+		nullableFloat(amounts.ReportingCost),
+		durationMs,
+		event.RequestID,
+	); err != nil {
+		return fmt.Errorf("failed to update request tokens: %w", err)
+	}
 
-		args := []interface{}{
-			data.ModelName,
-			data.InputTokens,
-			data.OutputTokens,
-			data.CacheCreationTokens,
-			data.CacheCreation5mTokens,
-			data.CacheCreation1hTokens,
-			data.CacheReadTokens,
-			costBreakdown.InputCost,
-			costBreakdown.OutputCost,
-			costBreakdown.CacheCreationCost,
-			costBreakdown.CacheCreation5mCost,
-			costBreakdown.CacheCreation1hCost,
-			costBreakdown.CacheReadCost,
-			costBreakdown.TotalCost,
-			event.RequestID,
-		}
+	bucket := at.Format("2006-01-02 15:04:05")
+	rollups := make(usageRollupDeltas, 2)
+	rollups.remove(bucket, &old, oldCost)
+	rollups.add(bucket, updated, cost.TotalCost)
+	if err := applyUsageRollupDeltasTx(ctx, tx, rollups); err != nil {
+		return err
+	}
 
-		return query, args, nil
-	default:
-		return "", nil, fmt.Errorf("unknown event type: %s", event.Type)
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
+	return nil
 }
 
 // buildStartQuery 构建开始事件查询
@@ -511,6 +578,26 @@ func (ut *UsageTracker) buildFlexibleUpdateQuery(event RequestEvent) (string, []
 		setParts = append(setParts, "failure_reason = ?")
 		args = append(args, *opts.FailureReason)
 	}
+	if opts.SessionID != nil {
+		setParts = append(setParts, "session_id = ?")
+		args = append(args, *opts.SessionID)
+	}
+	if opts.Timings != nil {
+		setParts = append(setParts, "ttfb_ms = ?", "first_event_ms = ?", "first_token_ms = ?", "output_tokens_per_sec = ?")
+		args = append(args, nullableMs(opts.Timings.TTFBMs), nullableMs(opts.Timings.FirstEventMs),
+			nullableMs(opts.Timings.FirstTokenMs), nullableRate(opts.Timings.OutputTokensPerSec))
+	}
+	if opts.RequestParams != nil {
+		setParts = append(setParts, "max_tokens = ?", "message_count = ?", "tool_count = ?",
+			"thinking_budget = ?", "temperature = ?", "request_bytes = ?")
+		args = append(args, opts.RequestParams.MaxTokens, opts.RequestParams.MessageCount, opts.RequestParams.ToolCount,
+			opts.RequestParams.ThinkingBudget, nullableTemperature(opts.RequestParams.Temperature), opts.RequestParams.RequestBytes)
+	}
+	if opts.ResponseMeta != nil {
+		setParts = append(setParts, "stop_reason = ?", "response_message_id = ?", "tool_use_count = ?", "has_thinking = ?")
+		args = append(args, nullString(opts.ResponseMeta.StopReason), nullString(opts.ResponseMeta.MessageID),
+			opts.ResponseMeta.ToolUseCount, opts.ResponseMeta.HasThinking)
+	}
 
 	// 如果没有字段需要更新，返回错误
 	if len(setParts) == 0 {
@@ -1052,6 +1139,40 @@ func (ut *UsageTracker) cleanupOldRecords() error {
 		return ut.ctx.Err()
 	}
 
+	// 清理失去关联请求的标签（通过写队列）
+	tagsWriteReq := WriteRequest{
+		Query:     "DELETE FROM request_tags WHERE request_id NOT IN (SELECT request_id FROM request_logs)",
+		Response:  make(chan error, 1),
+		Context:   context.Background(),
+		EventType: "cleanup_request_tags",
+	}
+
+	select {
+	case ut.writeQueue <- tagsWriteReq:
+		if err := <-tagsWriteReq.Response; err != nil {
+			return fmt.Errorf("failed to delete orphaned request tags: %w", err)
+		}
+	case <-ut.ctx.Done():
+		return ut.ctx.Err()
+	}
+
+	// 清理失去关联请求的上游尝试（通过写队列）
+	attemptsWriteReq := WriteRequest{
+		Query:     "DELETE FROM request_attempts WHERE request_id NOT IN (SELECT request_id FROM request_logs)",
+		Response:  make(chan error, 1),
+		Context:   context.Background(),
+		EventType: "cleanup_request_attempts",
+	}
+
+	select {
+	case ut.writeQueue <- attemptsWriteReq:
+		if err := <-attemptsWriteReq.Response; err != nil {
+			return fmt.Errorf("failed to delete orphaned request attempts: %w", err)
+		}
+	case <-ut.ctx.Done():
+		return ut.ctx.Err()
+	}
+
 	// 清理过期的汇总数据（通过写队列）
 	summaryQuery := "DELETE FROM usage_summary WHERE date < ?"
 	summaryWriteReq := WriteRequest{
@@ -1083,6 +1204,11 @@ func (ut *UsageTracker) cleanupOldRecords() error {
 		"cutoff_date", cutoffTime.Format("2006-01-02"),
 		"retention_days", ut.config.RetentionDays)
 
+	// 删除过期的小时/日汇总并重建截止日
+	if err := ut.pruneUsageRollups(ut.ctx, cutoffTime); err != nil {
+		slog.Warn("⚠️ [用量汇总] 清理后重建汇总失败", "error", err)
+	}
+
 	// 更新汇总统计（异步）
 	go ut.updateUsageSummary()
 
@@ -0,0 +1,286 @@
+package tracking
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// 错误排行的分组范围
+const (
+	ErrorScopeEndpoint = "endpoint" // 按渠道/端点分别取 Top N
+	ErrorScopeChannel  = "channel"  // 按渠道取 Top N（同渠道各端点合并）
+)
+
+// 错误指纹参数
+const (
+	maxErrorSignatureLen   = 200 // 归一化错误摘要最大长度（字符）
+	errorFingerprintSample = 3   // 每个指纹保留的样例请求数
+)
+
+// 错误消息归一化规则（按顺序替换：先替换各类 ID，再替换剩余数字）
+var errorSignatureRules = []struct {
+	pattern     *regexp.Regexp
+	replacement string
+}{
+	{regexp.MustCompile(`[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`), "<id>"},
+	{regexp.MustCompile(`\b(?:req|msg|msgbatch|toolu|srvtoolu|file|org|user|chatcmpl|sk)[_-][A-Za-z0-9_-]+`), "<id>"},
+	{regexp.MustCompile(`\b[0-9a-fA-F]{16,}\b`), "<id>"},
+	{regexp.MustCompile(`\b(?:[A-Za-z]+[0-9]|[0-9]+[A-Za-z])[A-Za-z0-9]{6,}\b`), "<id>"},
+	{regexp.MustCompile(`\d+(\.\d+)?`), "<n>"},
+	{regexp.MustCompile(`\s+`), " "},
+}
+
+// ErrorFingerprint 归一化后的一类上游错误（按错误类型、状态码、归一化消息与端点分组）
+type ErrorFingerprint struct {
+	Fingerprint  string `json:"fingerprint"` // 错误类型 + 状态码 + 归一化消息的哈希（跨端点一致）
+	ErrorType    string `json:"error_type"`
+	HTTPStatus   int    `json:"http_status"`
+	Signature    string `json:"signature"` // 归一化后的错误消息
+	Channel      string `json:"channel"`
+	EndpointName string `json:"endpoint_name"` // 按渠道分组时为空
+
+	Count        int       `json:"count"`         // 出现次数（按上游尝试计）
+	RequestCount int       `json:"request_count"` // 涉及的请求数
+	FirstSeen    time.Time `json:"first_seen"`
+	LastSeen     time.Time `json:"last_seen"`
+
+	SampleRequestIDs []string `json:"sample_request_ids"` // 最近的样例请求
+	SampleMessage    string   `json:"sample_message"`     // 最近一次的原始错误摘要
+}
+
+// ErrorTrendPoint 错误出现次数趋势（按时间桶与错误类型）
+type ErrorTrendPoint struct {
+	Bucket    string `json:"bucket"`
+	ErrorType string `json:"error_type"`
+	Count     int    `json:"count"`
+}
+
+// NormalizeErrorMessage 归一化上游错误消息：去除请求 ID、UUID、哈希与数字，便于同类错误聚合
+func NormalizeErrorMessage(message string) string {
+	signature := message
+	for _, rule := range errorSignatureRules {
+		signature = rule.pattern.ReplaceAllString(signature, rule.replacement)
+	}
+	signature = strings.TrimSpace(signature)
+	if utf8.RuneCountInString(signature) > maxErrorSignatureLen {
+		signature = string([]rune(signature)[:maxErrorSignatureLen])
+	}
+	return signature
+}
+
+// ErrorFingerprintOf 计算错误指纹（不含端点，同一错误在不同端点指纹一致）
+func ErrorFingerprintOf(errorType string, httpStatus int, signature string) string {
+	sum := sha1.Sum([]byte(errorType + "|" + strconv.Itoa(httpStatus) + "|" + signature))
+	return hex.EncodeToString(sum[:6])
+}
+
+// fingerprintAttempt 为带错误的上游尝试填充归一化消息与指纹
+func fingerprintAttempt(attempt *RequestAttempt) {
+	if attempt.ErrorType == "" && attempt.ErrorMessage == "" {
+		return
+	}
+	attempt.ErrorSignature = NormalizeErrorMessage(attempt.ErrorMessage)
+	attempt.Fingerprint = ErrorFingerprintOf(attempt.ErrorType, attempt.HTTPStatus, attempt.ErrorSignature)
+}
+
+// errorAttemptFilter 构建上游错误尝试的筛选条件
+func (ut *UsageTracker) errorAttemptFilter(opts *QueryOptions, fingerprint string) (string, []interface{}) {
+	where := " WHERE error_fingerprint IS NOT NULL"
+	var args []interface{}
+	if fingerprint != "" {
+		where += " AND error_fingerprint = ?"
+		args = append(args, fingerprint)
+	}
+	if opts == nil {
+		return where, args
+	}
+	if opts.StartDate != nil {
+		where += " AND start_time >= ?"
+		args = append(args, ut.formatStartTimeQueryBound(*opts.StartDate))
+	}
+	if opts.EndDate != nil {
+		where += " AND start_time <= ?"
+		args = append(args, ut.formatEndTimeQueryBound(*opts.EndDate))
+	}
+	if opts.Channel != "" {
+		where += " AND channel = ?"
+		args = append(args, opts.Channel)
+	}
+	if opts.EndpointName != "" {
+		where += " AND endpoint_name = ?"
+		args = append(args, opts.EndpointName)
+	}
+	return where, args
+}
+
+// QueryTopErrors 按端点（或渠道）统计出现最多的上游错误指纹
+// limit 为每个端点/渠道返回的指纹数；结果按出现次数降序
+func (ut *UsageTracker) QueryTopErrors(ctx context.Context, opts *QueryOptions, scope string, limit int) ([]ErrorFingerprint, error) {
+	if ut.readDB == nil {
+		return nil, fmt.Errorf("read database not initialized")
+	}
+	if limit <= 0 {
+		limit = 10
+	}
+
+	var endpointExpr string
+	switch scope {
+	case ErrorScopeEndpoint, "":
+		endpointExpr = "COALESCE(endpoint_name, '')"
+	case ErrorScopeChannel:
+		endpointExpr = "''"
+	default:
+		return nil, fmt.Errorf("unsupported error scope: %s", scope)
+	}
+
+	where, args := ut.errorAttemptFilter(opts, "")
+	groupCols := "COALESCE(channel, ''), " + endpointExpr + ", error_fingerprint"
+
+	// 先按指纹聚合，再在每个端点/渠道内按出现次数排名
+	query := `SELECT channel, endpoint, fingerprint, error_type, http_status, signature,
+			cnt, request_cnt, first_seen, last_seen
+		FROM (
+			SELECT COALESCE(channel, '') AS channel, ` + endpointExpr + ` AS endpoint,
+				error_fingerprint AS fingerprint,
+				MAX(COALESCE(error_type, '')) AS error_type, MAX(COALESCE(http_status, 0)) AS http_status,
+				MAX(COALESCE(error_signature, '')) AS signature,
+				COUNT(*) AS cnt, COUNT(DISTINCT request_id) AS request_cnt,
+				MIN(start_time) AS first_seen, MAX(start_time) AS last_seen,
+				ROW_NUMBER() OVER (PARTITION BY COALESCE(channel, ''), ` + endpointExpr + ` ORDER BY COUNT(*) DESC, MAX(start_time) DESC) AS rn
+			FROM request_attempts` + where + `
+			GROUP BY ` + groupCols + `
+		) WHERE rn <= ?
+		ORDER BY cnt DESC, last_seen DESC`
+	rows, err := ut.readDB.QueryContext(ctx, query, append(args, limit)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query top errors: %w", err)
+	}
+	defer rows.Close()
+
+	var result []ErrorFingerprint
+	index := make(map[string]int)
+	for rows.Next() {
+		var e ErrorFingerprint
+		var firstSeen, lastSeen string
+		if err := rows.Scan(&e.Channel, &e.EndpointName, &e.Fingerprint, &e.ErrorType, &e.HTTPStatus, &e.Signature,
+			&e.Count, &e.RequestCount, &firstSeen, &lastSeen); err != nil {
+			return nil, fmt.Errorf("failed to scan top error: %w", err)
+		}
+		e.FirstSeen = ut.parseStoredTime(firstSeen)
+		e.LastSeen = ut.parseStoredTime(lastSeen)
+		e.SampleRequestIDs = []string{}
+		index[e.Channel+"\x00"+e.EndpointName+"\x00"+e.Fingerprint] = len(result)
+		result = append(result, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating top errors: %w", err)
+	}
+	if len(result) == 0 {
+		return result, nil
+	}
+
+	if err := ut.loadErrorSamples(ctx, where, args, endpointExpr, result, index); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// loadErrorSamples 为错误指纹填充最近的样例请求与原始错误摘要
+func (ut *UsageTracker) loadErrorSamples(ctx context.Context, where string, args []interface{}, endpointExpr string, result []ErrorFingerprint, index map[string]int) error {
+	query := `SELECT channel, endpoint, fingerprint, request_id, error_message FROM (
+			SELECT COALESCE(channel, '') AS channel, ` + endpointExpr + ` AS endpoint,
+				error_fingerprint AS fingerprint, request_id, COALESCE(error_message, '') AS error_message,
+				ROW_NUMBER() OVER (PARTITION BY COALESCE(channel, ''), ` + endpointExpr + `, error_fingerprint ORDER BY start_time DESC, id DESC) AS rn
+			FROM request_attempts` + where + `
+		) WHERE rn <= ?
+		ORDER BY rn`
+	rows, err := ut.readDB.QueryContext(ctx, query, append(append([]interface{}(nil), args...), errorFingerprintSample*2)...)
+	if err != nil {
+		return fmt.Errorf("failed to query error samples: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var channel, endpoint, fingerprint, requestID, message string
+		if err := rows.Scan(&channel, &endpoint, &fingerprint, &requestID, &message); err != nil {
+			return fmt.Errorf("failed to scan error sample: %w", err)
+		}
+		i, ok := index[channel+"\x00"+endpoint+"\x00"+fingerprint]
+		if !ok {
+			continue
+		}
+		e := &result[i]
+		if e.SampleMessage == "" {
+			e.SampleMessage = message
+		}
+		// 同一请求的多次重试只保留一个样例
+		if len(e.SampleRequestIDs) < errorFingerprintSample && !containsString(e.SampleRequestIDs, requestID) {
+			e.SampleRequestIDs = append(e.SampleRequestIDs, requestID)
+		}
+	}
+	return rows.Err()
+}
+
+// QueryErrorTrend 按小时/天统计上游错误出现次数（按错误类型拆分；fingerprint 非空时只统计该指纹）
+func (ut *UsageTracker) QueryErrorTrend(ctx context.Context, opts *QueryOptions, granularity, fingerprint string) ([]ErrorTrendPoint, error) {
+	if ut.readDB == nil {
+		return nil, fmt.Errorf("read database not initialized")
+	}
+
+	var bucketExpr string
+	switch granularity {
+	case UsageTrendHour, "":
+		bucketExpr = "substr(start_time, 1, 13) || ':00:00'"
+	case UsageTrendDay:
+		bucketExpr = "substr(start_time, 1, 10)"
+	default:
+		return nil, fmt.Errorf("unsupported trend granularity: %s", granularity)
+	}
+
+	where, args := ut.errorAttemptFilter(opts, fingerprint)
+	query := `SELECT ` + bucketExpr + ` AS bucket, COALESCE(error_type, '') AS error_type, COUNT(*)
+		FROM request_attempts` + where + `
+		GROUP BY bucket, error_type`
+	rows, err := ut.readDB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query error trend: %w", err)
+	}
+	defer rows.Close()
+
+	var points []ErrorTrendPoint
+	for rows.Next() {
+		var p ErrorTrendPoint
+		if err := rows.Scan(&p.Bucket, &p.ErrorType, &p.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan error trend: %w", err)
+		}
+		points = append(points, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating error trend: %w", err)
+	}
+	sort.Slice(points, func(i, j int) bool {
+		if points[i].Bucket != points[j].Bucket {
+			return points[i].Bucket < points[j].Bucket
+		}
+		return points[i].ErrorType < points[j].ErrorType
+	})
+	return points, nil
+}
+
+// containsString 判断切片中是否包含指定字符串
+func containsString(values []string, target string) bool {
+	for _, v := range values {
+		if v == target {
+			return true
+		}
+	}
+	return false
+}
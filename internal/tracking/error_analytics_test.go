@@ -0,0 +1,144 @@
+package tracking
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// TestNormalizeErrorMessage 测试错误消息归一化
+func TestNormalizeErrorMessage(t *testing.T) {
+	a := NormalizeErrorMessage(`HTTP 529: {"type":"error","error":{"type":"overloaded_error","message":"Overloaded"},"request_id":"req_011CSHoEeqs5C35K2UUqR7Fy"}`)
+	b := NormalizeErrorMessage(`HTTP 529: {"type":"error","error":{"type":"overloaded_error","message":"Overloaded"},"request_id":"req_022ABCdefghijkLMNOP"}`)
+	if a != b {
+		t.Errorf("不同请求 ID 的同类错误应归一化一致:\n%s\n%s", a, b)
+	}
+	want := `HTTP <n>: {"type":"error","error":{"type":"overloaded_error","message":"Overloaded"},"request_id":"<id>"}`
+	if a != want {
+		t.Errorf("归一化结果 = %s, want %s", a, want)
+	}
+
+	got := NormalizeErrorMessage("request 3f2504e0-4f89-11d3-9a0c-0305e82c3301 failed  after 30.5s (trace 9f86d081884c7d659a2feaa0c55ad015)")
+	if got != "request <id> failed after <n>s (trace <id>)" {
+		t.Errorf("UUID/哈希/数字归一化结果不符: %s", got)
+	}
+
+	if ErrorFingerprintOf("overloaded", 529, a) == ErrorFingerprintOf("auth_error", 401, a) {
+		t.Error("不同错误类型/状态码的指纹应不同")
+	}
+}
+
+// TestQueryTopErrors 测试按端点/渠道统计错误指纹、样例请求与趋势
+func TestQueryTopErrors(t *testing.T) {
+	config := &Config{
+		Enabled:         true,
+		DatabasePath:    ":memory:",
+		BufferSize:      100,
+		BatchSize:       10,
+		FlushInterval:   50 * time.Millisecond,
+		MaxRetry:        3,
+		CleanupInterval: 24 * time.Hour,
+		RetentionDays:   30,
+	}
+	tracker, err := NewUsageTracker(config)
+	if err != nil {
+		t.Fatalf("Failed to create usage tracker: %v", err)
+	}
+	defer tracker.Close()
+
+	ctx := context.Background()
+	base := tracker.now().Add(-3 * time.Hour).Truncate(time.Hour)
+	insert := func(requestID, endpoint string, at time.Time, attempt RequestAttempt) {
+		t.Helper()
+		attempt.AttemptNumber = 1
+		attempt.Channel = "relay"
+		attempt.EndpointName = endpoint
+		attempt.StartTime = at
+		fingerprintAttempt(&attempt)
+		query, args, err := tracker.buildWriteQuery(RequestEvent{Type: "attempt", RequestID: requestID, Data: attempt})
+		if err != nil {
+			t.Fatalf("构建尝试写入查询失败: %v", err)
+		}
+		if _, err := tracker.writeDB.ExecContext(ctx, query, args...); err != nil {
+			t.Fatalf("写入尝试失败: %v", err)
+		}
+	}
+
+	// ep-a：5 次过载（不同请求 ID）+ 1 次鉴权过期；ep-b：2 次过载；以及一次成功尝试
+	for i := 0; i < 5; i++ {
+		insert(fmt.Sprintf("req-overload-%d", i), "ep-a", base.Add(time.Duration(i)*30*time.Minute), RequestAttempt{
+			HTTPStatus:   529,
+			ErrorType:    "overloaded",
+			ErrorMessage: fmt.Sprintf(`HTTP 529: overloaded_error: Overloaded (request_id req_01%dABCDEFGHIJ)`, i),
+			Decision:     AttemptDecisionSwitch,
+		})
+	}
+	insert("req-auth", "ep-a", base.Add(time.Hour), RequestAttempt{
+		HTTPStatus:   401,
+		ErrorType:    "auth_error",
+		ErrorMessage: "HTTP 401: relay token expired at 1760000000",
+		Decision:     AttemptDecisionSwitch,
+	})
+	for i := 0; i < 2; i++ {
+		insert(fmt.Sprintf("req-overload-b-%d", i), "ep-b", base.Add(time.Duration(i)*time.Hour), RequestAttempt{
+			HTTPStatus:   529,
+			ErrorType:    "overloaded",
+			ErrorMessage: fmt.Sprintf(`HTTP 529: overloaded_error: Overloaded (request_id req_09%dKLMNOPQRST)`, i),
+			Decision:     AttemptDecisionFail,
+		})
+	}
+	insert("req-ok", "ep-a", base, RequestAttempt{HTTPStatus: 200, Decision: AttemptDecisionSuccess})
+
+	errors, err := tracker.QueryTopErrors(ctx, &QueryOptions{}, ErrorScopeEndpoint, 10)
+	if err != nil {
+		t.Fatalf("查询错误排行失败: %v", err)
+	}
+	if len(errors) != 3 {
+		t.Fatalf("应有 3 组错误（ep-a 过载/鉴权，ep-b 过载）, got %d: %+v", len(errors), errors)
+	}
+	top := errors[0]
+	if top.EndpointName != "ep-a" || top.ErrorType != "overloaded" || top.HTTPStatus != 529 || top.Count != 5 || top.RequestCount != 5 {
+		t.Errorf("最高频错误不符: %+v", top)
+	}
+	if len(top.SampleRequestIDs) != errorFingerprintSample || top.SampleRequestIDs[0] != "req-overload-4" {
+		t.Errorf("样例请求应为最近的 %d 个: %v", errorFingerprintSample, top.SampleRequestIDs)
+	}
+	if !top.FirstSeen.Before(top.LastSeen) || top.SampleMessage == "" {
+		t.Errorf("首末次出现时间或样例消息不符: %+v", top)
+	}
+	if errors[1].Fingerprint != top.Fingerprint || errors[1].EndpointName != "ep-b" {
+		t.Errorf("同一错误在不同端点指纹应一致: %+v", errors[1])
+	}
+
+	// 每个端点只取 Top 1
+	errors, err = tracker.QueryTopErrors(ctx, &QueryOptions{}, ErrorScopeEndpoint, 1)
+	if err != nil || len(errors) != 2 {
+		t.Fatalf("每端点 Top 1 应返回 2 组: %+v err=%v", errors, err)
+	}
+
+	// 按渠道合并端点
+	errors, err = tracker.QueryTopErrors(ctx, &QueryOptions{}, ErrorScopeChannel, 10)
+	if err != nil || len(errors) != 2 || errors[0].Count != 7 || errors[0].EndpointName != "" {
+		t.Fatalf("按渠道聚合结果不符: %+v err=%v", errors, err)
+	}
+
+	trend, err := tracker.QueryErrorTrend(ctx, &QueryOptions{EndpointName: "ep-a"}, UsageTrendHour, top.Fingerprint)
+	if err != nil {
+		t.Fatalf("查询错误趋势失败: %v", err)
+	}
+	total := 0
+	for _, p := range trend {
+		if p.ErrorType != "overloaded" {
+			t.Errorf("按指纹筛选的趋势只应包含过载错误: %+v", p)
+		}
+		total += p.Count
+	}
+	if total != 5 || len(trend) != 3 {
+		t.Errorf("ep-a 过载趋势应为 3 个小时桶共 5 次: %+v", trend)
+	}
+
+	if _, err := tracker.QueryTopErrors(ctx, nil, "model", 10); err == nil {
+		t.Error("不支持的分组范围应返回错误")
+	}
+}
@@ -24,6 +24,26 @@ func NewErrorHandler(tracker *UsageTracker, logger *slog.Logger) *ErrorHandler {
 	}
 }
 
+// SetDatabaseErrorHandler 设置数据库写入失败回调（批量写入/归档最终失败时调用，用于外部告警）
+func (ut *UsageTracker) SetDatabaseErrorHandler(handler func(operation string, err error)) {
+	ut.dbErrorMu.Lock()
+	ut.dbErrorHandler = handler
+	ut.dbErrorMu.Unlock()
+}
+
+// reportDatabaseError 通知数据库写入失败
+func (ut *UsageTracker) reportDatabaseError(operation string, err error) {
+	if err == nil {
+		return
+	}
+	ut.dbErrorMu.RLock()
+	handler := ut.dbErrorHandler
+	ut.dbErrorMu.RUnlock()
+	if handler != nil {
+		handler(operation, err)
+	}
+}
+
 // HandleDatabaseError handles database-related errors with recovery attempts
 func (eh *ErrorHandler) HandleDatabaseError(err error, operation string) bool {
 	if err == nil {
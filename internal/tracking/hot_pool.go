@@ -29,6 +29,16 @@ type ActiveRequest struct {
 	ModelName     string `json:"model_name"`     // 模型名称
 	FailureReason string `json:"failure_reason"` // 失败原因
 	CancelReason  string `json:"cancel_reason"`  // 取消原因
+	SessionID     string `json:"session_id"`     // 客户端会话标识（Claude Code metadata.user_id / 会话请求头）
+
+	Tags []RequestTag `json:"tags,omitempty"` // 请求标签（项目/成本中心归属）
+
+	Timings RequestTimings `json:"timings"` // 响应时间线（TTFB / 首 token / 输出速率）
+
+	RequestParams RequestParams `json:"request_params"` // 请求参数（来自请求体）
+	ResponseMeta  ResponseMeta  `json:"response_meta"`  // 响应元信息（停止原因 / 工具调用 / thinking）
+
+	Attempts []RequestAttempt `json:"attempts,omitempty"` // 上游尝试轨迹（重试/故障转移）
 
 	// Token 累积（流式请求实时更新）
 	InputTokens           int64 `json:"input_tokens"`
@@ -38,6 +48,10 @@ type ActiveRequest struct {
 	CacheCreation1hTokens int64 `json:"cache_creation_1h_tokens"` // 1小时缓存 (v5.0.1+)
 	CacheReadTokens       int64 `json:"cache_read_tokens"`
 
+	// 服务端工具调用次数（按次计费）
+	WebSearchRequests int64 `json:"web_search_requests"`
+	WebFetchRequests  int64 `json:"web_fetch_requests"`
+
 	// 完成信息（只在结束时填充）
 	EndTime      *time.Time `json:"end_time,omitempty"`
 	DurationMs   int64      `json:"duration_ms"`
@@ -0,0 +1,531 @@
+package tracking
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// 透视分析维度
+const (
+	PivotDimModel         = "model"
+	PivotDimChannel       = "channel"
+	PivotDimEndpoint      = "endpoint"
+	PivotDimGroup         = "group"
+	PivotDimStatus        = "status"
+	PivotDimFailureReason = "failure_reason"
+	PivotDimClientIP      = "client_ip"
+	PivotDimUserAgent     = "user_agent"
+	PivotDimStreaming     = "is_streaming"
+	PivotDimSession       = "session"
+	PivotDimStopReason    = "stop_reason"
+	PivotDimHour          = "hour"
+	PivotDimDay           = "day"
+	PivotDimWeek          = "week"
+)
+
+// 透视分析指标
+const (
+	PivotMetricCount             = "count"
+	PivotMetricSuccessCount      = "success_count"
+	PivotMetricFailedCount       = "failed_count"
+	PivotMetricSuccessRate       = "success_rate"
+	PivotMetricInputTokens       = "input_tokens"
+	PivotMetricOutputTokens      = "output_tokens"
+	PivotMetricCacheCreateTokens = "cache_creation_tokens"
+	PivotMetricCacheReadTokens   = "cache_read_tokens"
+	PivotMetricTotalTokens       = "total_tokens"
+	PivotMetricInputCost         = "input_cost"
+	PivotMetricOutputCost        = "output_cost"
+	PivotMetricCacheCreateCost   = "cache_creation_cost"
+	PivotMetricCacheReadCost     = "cache_read_cost"
+	PivotMetricServerToolCost    = "server_tool_cost"
+	PivotMetricTotalCost         = "total_cost"
+	PivotMetricAvgDuration       = "avg_duration_ms"
+	PivotMetricP50Duration       = "p50_duration_ms"
+	PivotMetricP95Duration       = "p95_duration_ms"
+)
+
+const (
+	maxPivotDimensions = 5
+	defaultPivotLimit  = 1000
+	maxPivotLimit      = 10000
+)
+
+// pivotFailedStatuses 失败状态集合（兼容 v3.5.0 之前的各类错误状态）
+const pivotFailedStatuses = "('failed', 'error', 'auth_error', 'rate_limited', 'server_error', 'network_error', 'stream_error', 'timeout')"
+
+// pivotDimensionExprs 维度白名单：维度名 -> SQL 表达式（只允许白名单内的表达式拼接进 SQL）
+// start_time 按配置时区存储，时间桶直接截取即为本地时区的小时/天/周（周一为一周开始）
+var pivotDimensionExprs = map[string]string{
+	PivotDimModel:         "COALESCE(model_name, '')",
+	PivotDimChannel:       "COALESCE(channel, '')",
+	PivotDimEndpoint:      "COALESCE(endpoint_name, '')",
+	PivotDimGroup:         "COALESCE(group_name, '')",
+	PivotDimStatus:        "COALESCE(status, '')",
+	PivotDimFailureReason: "COALESCE(failure_reason, '')",
+	PivotDimClientIP:      "COALESCE(client_ip, '')",
+	PivotDimUserAgent:     "COALESCE(user_agent, '')",
+	PivotDimStreaming:     "CASE WHEN is_streaming THEN 'true' ELSE 'false' END",
+	PivotDimSession:       "COALESCE(session_id, '')",
+	PivotDimStopReason:    "COALESCE(stop_reason, '')",
+	PivotDimHour:          "substr(start_time, 1, 13) || ':00:00'",
+	PivotDimDay:           "substr(start_time, 1, 10)",
+	PivotDimWeek:          "date(substr(start_time, 1, 10), '-6 days', 'weekday 1')",
+}
+
+// pivotMetrics 指标白名单
+var pivotMetrics = map[string]bool{
+	PivotMetricCount:             true,
+	PivotMetricSuccessCount:      true,
+	PivotMetricFailedCount:       true,
+	PivotMetricSuccessRate:       true,
+	PivotMetricInputTokens:       true,
+	PivotMetricOutputTokens:      true,
+	PivotMetricCacheCreateTokens: true,
+	PivotMetricCacheReadTokens:   true,
+	PivotMetricTotalTokens:       true,
+	PivotMetricInputCost:         true,
+	PivotMetricOutputCost:        true,
+	PivotMetricCacheCreateCost:   true,
+	PivotMetricCacheReadCost:     true,
+	PivotMetricServerToolCost:    true,
+	PivotMetricTotalCost:         true,
+	PivotMetricAvgDuration:       true,
+	PivotMetricP50Duration:       true,
+	PivotMetricP95Duration:       true,
+}
+
+// defaultPivotMetrics 未指定指标时返回的指标
+var defaultPivotMetrics = []string{PivotMetricCount, PivotMetricSuccessRate, PivotMetricTotalTokens, PivotMetricTotalCost}
+
+// PivotQuery 透视分析查询定义
+type PivotQuery struct {
+	Dimensions []string            `json:"dimensions"` // 分组维度（按顺序），为空时返回单行汇总
+	Metrics    []string            `json:"metrics"`    // 指标，为空时使用默认指标
+	Filters    map[string][]string `json:"filters"`    // 维度过滤：维度名 -> 允许的取值
+	SortBy     string              `json:"sort_by"`    // 排序字段（维度或指标名）
+	SortOrder  string              `json:"sort_order"` // asc / desc
+	Limit      int                 `json:"limit"`      // 返回的分组数上限
+}
+
+// PivotRow 透视分析结果行
+type PivotRow struct {
+	Dimensions map[string]string  `json:"dimensions"`
+	Metrics    map[string]float64 `json:"metrics"`
+}
+
+// PivotResult 透视分析结果
+type PivotResult struct {
+	Dimensions  []string   `json:"dimensions"`
+	Metrics     []string   `json:"metrics"`
+	Rows        []PivotRow `json:"rows"`
+	TotalGroups int        `json:"total_groups"` // 截断前的分组总数
+}
+
+// pivotAggregate 分组内的聚合值
+type pivotAggregate struct {
+	keys []string
+
+	count, success, failed                   int64
+	inputTokens, outputTokens                int64
+	cacheCreateTokens, cacheReadTokens       int64
+	inputCost, outputCost, cacheCreateCost   float64
+	cacheReadCost, serverToolCost, totalCost float64
+	durationSum, durationCount               int64
+	p50Duration, p95Duration                 float64
+}
+
+// QueryPivot 按任意维度组合对请求日志进行分组聚合
+// 维度、指标与排序字段均经白名单校验，过滤值全部通过参数绑定；仅在请求分位数指标时按分组计算耗时分位数
+func (ut *UsageTracker) QueryPivot(ctx context.Context, opts *QueryOptions, q PivotQuery) (*PivotResult, error) {
+	if ut.readDB == nil {
+		return nil, fmt.Errorf("read database not initialized")
+	}
+
+	dims, metrics, err := normalizePivotQuery(q)
+	if err != nil {
+		return nil, err
+	}
+	sortBy, desc, err := pivotSortOrder(q, dims, metrics)
+	if err != nil {
+		return nil, err
+	}
+	limit := q.Limit
+	if limit <= 0 {
+		limit = defaultPivotLimit
+	}
+	if limit > maxPivotLimit {
+		limit = maxPivotLimit
+	}
+
+	where, args, err := ut.requestLogFilter(opts, q.Filters)
+	if err != nil {
+		return nil, err
+	}
+
+	dimExprs := make([]string, len(dims))
+	for i, dim := range dims {
+		dimExprs[i] = pivotDimensionExprs[dim]
+	}
+	selectDims := ""
+	groupBy := ""
+	if len(dims) > 0 {
+		selectDims = strings.Join(dimExprs, ", ") + ", "
+		groupBy = " GROUP BY " + strings.Join(dimExprs, ", ")
+	}
+
+	query := `SELECT ` + selectDims + `COUNT(*),
+		COALESCE(SUM(CASE WHEN status IN ('completed', 'processing') THEN 1 ELSE 0 END), 0),
+		COALESCE(SUM(CASE WHEN status IN ` + pivotFailedStatuses + ` THEN 1 ELSE 0 END), 0),
+		COALESCE(SUM(input_tokens), 0), COALESCE(SUM(output_tokens), 0),
+		COALESCE(SUM(cache_creation_tokens), 0), COALESCE(SUM(cache_read_tokens), 0),
+		COALESCE(SUM(input_cost_usd), 0), COALESCE(SUM(output_cost_usd), 0),
+		COALESCE(SUM(cache_creation_cost_usd), 0), COALESCE(SUM(cache_read_cost_usd), 0),
+		COALESCE(SUM(server_tool_cost_usd), 0), COALESCE(SUM(total_cost_usd), 0),
+		COALESCE(SUM(CASE WHEN duration_ms > 0 THEN duration_ms ELSE 0 END), 0),
+		COALESCE(SUM(CASE WHEN duration_ms > 0 THEN 1 ELSE 0 END), 0)
+		FROM request_logs` + where + groupBy
+
+	rows, err := ut.readDB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query pivot: %w", err)
+	}
+	defer rows.Close()
+
+	groups := make(map[string]*pivotAggregate)
+	var ordered []*pivotAggregate
+	for rows.Next() {
+		agg := &pivotAggregate{keys: make([]string, len(dims))}
+		dest := make([]interface{}, 0, len(dims)+15)
+		for i := range agg.keys {
+			dest = append(dest, &agg.keys[i])
+		}
+		dest = append(dest, &agg.count, &agg.success, &agg.failed,
+			&agg.inputTokens, &agg.outputTokens, &agg.cacheCreateTokens, &agg.cacheReadTokens,
+			&agg.inputCost, &agg.outputCost, &agg.cacheCreateCost, &agg.cacheReadCost,
+			&agg.serverToolCost, &agg.totalCost, &agg.durationSum, &agg.durationCount)
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("failed to scan pivot row: %w", err)
+		}
+		if agg.count == 0 {
+			// 无分组维度且无匹配记录时 SQLite 仍返回一行
+			continue
+		}
+		groups[strings.Join(agg.keys, "\x00")] = agg
+		ordered = append(ordered, agg)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if containsString(metrics, PivotMetricP50Duration) || containsString(metrics, PivotMetricP95Duration) {
+		if err := ut.loadPivotDurations(ctx, dimExprs, where, args, groups); err != nil {
+			return nil, err
+		}
+	}
+
+	result := &PivotResult{
+		Dimensions:  dims,
+		Metrics:     metrics,
+		Rows:        make([]PivotRow, 0, len(ordered)),
+		TotalGroups: len(ordered),
+	}
+	for _, agg := range ordered {
+		row := PivotRow{
+			Dimensions: make(map[string]string, len(dims)),
+			Metrics:    make(map[string]float64, len(metrics)),
+		}
+		for i, dim := range dims {
+			row.Dimensions[dim] = agg.keys[i]
+		}
+		for _, metric := range metrics {
+			row.Metrics[metric] = agg.metric(metric)
+		}
+		result.Rows = append(result.Rows, row)
+	}
+
+	sortPivotRows(result.Rows, dims, sortBy, desc)
+	if len(result.Rows) > limit {
+		result.Rows = result.Rows[:limit]
+	}
+	return result, nil
+}
+
+// loadPivotDurations 按分组计算耗时 P50/P95
+// 在 SQL 中按分组排序编号（窗口函数），每个分组只返回分位数所在的行，内存占用与分组数成正比而非请求数
+// 排名规则与 percentileOf 一致：rank = ceil(p / 100 * n)
+func (ut *UsageTracker) loadPivotDurations(ctx context.Context, dimExprs []string, where string, args []interface{}, groups map[string]*pivotAggregate) error {
+	cond := " WHERE duration_ms > 0"
+	if where != "" {
+		cond = where + " AND duration_ms > 0"
+	}
+	partition := ""
+	innerDims := ""
+	outerDims := ""
+	if len(dimExprs) > 0 {
+		partition = "PARTITION BY " + strings.Join(dimExprs, ", ") + " "
+		aliases := make([]string, len(dimExprs))
+		for i, expr := range dimExprs {
+			aliases[i] = fmt.Sprintf("d%d", i)
+			innerDims += expr + " AS " + aliases[i] + ", "
+		}
+		outerDims = strings.Join(aliases, ", ") + ", "
+	}
+	query := `SELECT ` + outerDims + `duration_ms, rn = (50 * cnt + 99) / 100, rn = (95 * cnt + 99) / 100
+		FROM (SELECT ` + innerDims + `duration_ms,
+			ROW_NUMBER() OVER (` + partition + `ORDER BY duration_ms) AS rn,
+			COUNT(*) OVER (` + partition + `) AS cnt
+			FROM request_logs` + cond + `)
+		WHERE rn = (50 * cnt + 99) / 100 OR rn = (95 * cnt + 99) / 100`
+	rows, err := ut.readDB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to query pivot durations: %w", err)
+	}
+	defer rows.Close()
+
+	keys := make([]string, len(dimExprs))
+	for rows.Next() {
+		var duration int64
+		var isP50, isP95 bool
+		dest := make([]interface{}, 0, len(dimExprs)+3)
+		for i := range keys {
+			dest = append(dest, &keys[i])
+		}
+		dest = append(dest, &duration, &isP50, &isP95)
+		if err := rows.Scan(dest...); err != nil {
+			return fmt.Errorf("failed to scan pivot duration: %w", err)
+		}
+		agg, ok := groups[strings.Join(keys, "\x00")]
+		if !ok {
+			continue
+		}
+		if isP50 {
+			agg.p50Duration = float64(duration)
+		}
+		if isP95 {
+			agg.p95Duration = float64(duration)
+		}
+	}
+	return rows.Err()
+}
+
+// metric 计算分组的指定指标值
+func (a *pivotAggregate) metric(name string) float64 {
+	switch name {
+	case PivotMetricCount:
+		return float64(a.count)
+	case PivotMetricSuccessCount:
+		return float64(a.success)
+	case PivotMetricFailedCount:
+		return float64(a.failed)
+	case PivotMetricSuccessRate:
+		if a.count == 0 {
+			return 0
+		}
+		return math.Round(float64(a.success)/float64(a.count)*10000) / 100
+	case PivotMetricInputTokens:
+		return float64(a.inputTokens)
+	case PivotMetricOutputTokens:
+		return float64(a.outputTokens)
+	case PivotMetricCacheCreateTokens:
+		return float64(a.cacheCreateTokens)
+	case PivotMetricCacheReadTokens:
+		return float64(a.cacheReadTokens)
+	case PivotMetricTotalTokens:
+		return float64(a.inputTokens + a.outputTokens + a.cacheCreateTokens + a.cacheReadTokens)
+	case PivotMetricInputCost:
+		return a.inputCost
+	case PivotMetricOutputCost:
+		return a.outputCost
+	case PivotMetricCacheCreateCost:
+		return a.cacheCreateCost
+	case PivotMetricCacheReadCost:
+		return a.cacheReadCost
+	case PivotMetricServerToolCost:
+		return a.serverToolCost
+	case PivotMetricTotalCost:
+		return a.totalCost
+	case PivotMetricAvgDuration:
+		if a.durationCount == 0 {
+			return 0
+		}
+		return math.Round(float64(a.durationSum)/float64(a.durationCount)*100) / 100
+	case PivotMetricP50Duration:
+		return a.p50Duration
+	case PivotMetricP95Duration:
+		return a.p95Duration
+	default:
+		return 0
+	}
+}
+
+// requestLogFilter 构建 request_logs 查询的 WHERE 子句（通用过滤条件 + 维度取值过滤）
+func (ut *UsageTracker) requestLogFilter(opts *QueryOptions, filters map[string][]string) (string, []interface{}, error) {
+	var conds []string
+	var args []interface{}
+
+	if opts != nil {
+		if opts.StartDate != nil {
+			conds = append(conds, "start_time >= ?")
+			args = append(args, ut.formatStartTimeQueryBound(*opts.StartDate))
+		}
+		if opts.EndDate != nil {
+			conds = append(conds, "start_time <= ?")
+			args = append(args, ut.formatEndTimeQueryBound(*opts.EndDate))
+		}
+		if opts.ModelName != "" {
+			conds = append(conds, "model_name = ?")
+			args = append(args, opts.ModelName)
+		}
+		if opts.Channel != "" {
+			conds = append(conds, "channel = ?")
+			args = append(args, opts.Channel)
+		}
+		if opts.EndpointName != "" {
+			conds = append(conds, "endpoint_name = ?")
+			args = append(args, opts.EndpointName)
+		}
+		if opts.GroupName != "" {
+			conds = append(conds, "group_name = ?")
+			args = append(args, opts.GroupName)
+		}
+		if opts.SessionID != "" {
+			conds = append(conds, "session_id = ?")
+			args = append(args, opts.SessionID)
+		}
+		if opts.Status == "failed" {
+			conds = append(conds, "status IN "+pivotFailedStatuses)
+		} else if opts.Status != "" {
+			conds = append(conds, "status = ?")
+			args = append(args, opts.Status)
+		}
+	}
+
+	// 按维度名排序，保证生成的 SQL 稳定
+	names := make([]string, 0, len(filters))
+	for name := range filters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		expr, ok := pivotDimensionExprs[name]
+		if !ok {
+			return "", nil, fmt.Errorf("unsupported pivot filter dimension: %s", name)
+		}
+		values := filters[name]
+		if len(values) == 0 {
+			continue
+		}
+		conds = append(conds, expr+" IN ("+strings.TrimSuffix(strings.Repeat("?, ", len(values)), ", ")+")")
+		for _, v := range values {
+			args = append(args, v)
+		}
+	}
+
+	where := ""
+	if len(conds) > 0 {
+		where = " WHERE " + strings.Join(conds, " AND ")
+	}
+	if opts != nil {
+		tagClause, tagArgs := tagFilterSQL(opts.Tags)
+		metaClause, metaArgs := responseMetaFilterSQL(opts)
+		if tagClause != "" || metaClause != "" {
+			if where == "" {
+				where = " WHERE 1=1"
+			}
+			where += tagClause + metaClause
+			args = append(append(args, tagArgs...), metaArgs...)
+		}
+	}
+	return where, args, nil
+}
+
+// normalizePivotQuery 校验维度与指标（白名单、去重），未指定指标时使用默认指标
+func normalizePivotQuery(q PivotQuery) ([]string, []string, error) {
+	if len(q.Dimensions) > maxPivotDimensions {
+		return nil, nil, fmt.Errorf("too many pivot dimensions: %d (max %d)", len(q.Dimensions), maxPivotDimensions)
+	}
+	dims := make([]string, 0, len(q.Dimensions))
+	for _, dim := range q.Dimensions {
+		if _, ok := pivotDimensionExprs[dim]; !ok {
+			return nil, nil, fmt.Errorf("unsupported pivot dimension: %s", dim)
+		}
+		if containsString(dims, dim) {
+			return nil, nil, fmt.Errorf("duplicate pivot dimension: %s", dim)
+		}
+		dims = append(dims, dim)
+	}
+
+	if len(q.Metrics) == 0 {
+		return dims, append([]string(nil), defaultPivotMetrics...), nil
+	}
+	metrics := make([]string, 0, len(q.Metrics))
+	for _, metric := range q.Metrics {
+		if !pivotMetrics[metric] {
+			return nil, nil, fmt.Errorf("unsupported pivot metric: %s", metric)
+		}
+		if !containsString(metrics, metric) {
+			metrics = append(metrics, metric)
+		}
+	}
+	return dims, metrics, nil
+}
+
+// pivotSortOrder 解析排序字段与方向
+// 默认：含时间桶维度时按时间升序，否则按第一个指标降序；指定字段未指定方向时维度升序、指标降序
+func pivotSortOrder(q PivotQuery, dims, metrics []string) (string, bool, error) {
+	sortBy := q.SortBy
+	if sortBy == "" {
+		for _, dim := range dims {
+			if dim == PivotDimHour || dim == PivotDimDay || dim == PivotDimWeek {
+				sortBy = dim
+				break
+			}
+		}
+		if sortBy == "" {
+			sortBy = metrics[0]
+		}
+	} else if !containsString(dims, sortBy) && !containsString(metrics, sortBy) {
+		return "", false, fmt.Errorf("pivot sort field must be a selected dimension or metric: %s", sortBy)
+	}
+
+	isDim := containsString(dims, sortBy)
+	switch strings.ToLower(q.SortOrder) {
+	case "asc":
+		return sortBy, false, nil
+	case "desc":
+		return sortBy, true, nil
+	case "":
+		return sortBy, !isDim, nil
+	default:
+		return "", false, fmt.Errorf("unsupported pivot sort order: %s", q.SortOrder)
+	}
+}
+
+// sortPivotRows 按维度或指标排序，相同时按维度顺序比较维度值保证结果稳定
+func sortPivotRows(rows []PivotRow, dims []string, sortBy string, desc bool) {
+	byDimension := containsString(dims, sortBy)
+	sort.SliceStable(rows, func(i, j int) bool {
+		if byDimension {
+			a, b := rows[i].Dimensions[sortBy], rows[j].Dimensions[sortBy]
+			if a != b {
+				return (a < b) != desc
+			}
+		} else {
+			a, b := rows[i].Metrics[sortBy], rows[j].Metrics[sortBy]
+			if a != b {
+				return (a < b) != desc
+			}
+		}
+		for _, dim := range dims {
+			a, b := rows[i].Dimensions[dim], rows[j].Dimensions[dim]
+			if a != b {
+				return a < b
+			}
+		}
+		return false
+	})
+}
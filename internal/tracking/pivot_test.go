@@ -0,0 +1,148 @@
+package tracking
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestQueryPivot(t *testing.T) {
+	config := &Config{
+		Enabled:         true,
+		DatabasePath:    ":memory:",
+		BufferSize:      100,
+		BatchSize:       10,
+		FlushInterval:   50 * time.Millisecond,
+		MaxRetry:        3,
+		CleanupInterval: 24 * time.Hour,
+		RetentionDays:   30,
+	}
+	tracker, err := NewUsageTracker(config)
+	if err != nil {
+		t.Fatalf("Failed to create usage tracker: %v", err)
+	}
+	defer tracker.Close()
+
+	ctx := context.Background()
+	insert := func(requestID, startTime, model, status string, streaming bool, inputTokens, outputTokens int64, cost float64, durationMs int64) {
+		t.Helper()
+		_, err := tracker.writeDB.ExecContext(ctx, `INSERT INTO request_logs
+			(request_id, start_time, channel, endpoint_name, model_name, status, is_streaming,
+			input_tokens, output_tokens, total_cost_usd, duration_ms)
+			VALUES (?, ?, 'relay', 'ep-a', ?, ?, ?, ?, ?, ?, ?)`,
+			requestID, startTime, model, status, streaming, inputTokens, outputTokens, cost, durationMs)
+		if err != nil {
+			t.Fatalf("写入请求失败: %v", err)
+		}
+	}
+
+	// 2025-12-01 为周一，2025-12-07 为周日，2025-12-08 为下一周周一
+	insert("req-1", "2025-12-01 10:15:00", "claude-sonnet", "completed", true, 100, 50, 0.1, 1000)
+	insert("req-2", "2025-12-03 10:40:00", "claude-sonnet", "completed", false, 200, 100, 0.2, 3000)
+	insert("req-3", "2025-12-07 23:00:00", "claude-sonnet", "rate_limited", true, 0, 0, 0, 200)
+	insert("req-4", "2025-12-08 01:00:00", "claude-opus", "completed", true, 300, 150, 1.5, 5000)
+
+	result, err := tracker.QueryPivot(ctx, nil, PivotQuery{
+		Dimensions: []string{PivotDimModel},
+		Metrics: []string{PivotMetricCount, PivotMetricSuccessRate, PivotMetricTotalTokens,
+			PivotMetricTotalCost, PivotMetricAvgDuration, PivotMetricP50Duration, PivotMetricP95Duration},
+	})
+	if err != nil {
+		t.Fatalf("透视查询失败: %v", err)
+	}
+	if len(result.Rows) != 2 || result.TotalGroups != 2 {
+		t.Fatalf("按模型应有 2 组, got %+v", result.Rows)
+	}
+	// 默认按第一个指标（count）降序
+	sonnet := result.Rows[0]
+	if sonnet.Dimensions[PivotDimModel] != "claude-sonnet" {
+		t.Fatalf("第一行应为 claude-sonnet, got %+v", sonnet)
+	}
+	if sonnet.Metrics[PivotMetricCount] != 3 || sonnet.Metrics[PivotMetricSuccessRate] != 66.67 {
+		t.Errorf("sonnet 请求数/成功率不符: %+v", sonnet.Metrics)
+	}
+	if sonnet.Metrics[PivotMetricTotalTokens] != 450 || sonnet.Metrics[PivotMetricAvgDuration] != 1400 {
+		t.Errorf("sonnet tokens/平均耗时不符: %+v", sonnet.Metrics)
+	}
+	if sonnet.Metrics[PivotMetricP50Duration] != 1000 || sonnet.Metrics[PivotMetricP95Duration] != 3000 {
+		t.Errorf("sonnet 耗时分位数不符: %+v", sonnet.Metrics)
+	}
+
+	// 多维度 + 周时间桶（默认按时间升序）+ 维度过滤
+	result, err = tracker.QueryPivot(ctx, nil, PivotQuery{
+		Dimensions: []string{PivotDimWeek, PivotDimStreaming},
+		Metrics:    []string{PivotMetricCount},
+		Filters:    map[string][]string{PivotDimStatus: {"completed", "rate_limited"}},
+	})
+	if err != nil {
+		t.Fatalf("按周透视失败: %v", err)
+	}
+	want := []struct {
+		week, streaming string
+		count           float64
+	}{
+		{"2025-12-01", "false", 1},
+		{"2025-12-01", "true", 2},
+		{"2025-12-08", "true", 1},
+	}
+	if len(result.Rows) != len(want) {
+		t.Fatalf("按周应有 %d 组, got %+v", len(want), result.Rows)
+	}
+	for i, w := range want {
+		row := result.Rows[i]
+		if row.Dimensions[PivotDimWeek] != w.week || row.Dimensions[PivotDimStreaming] != w.streaming || row.Metrics[PivotMetricCount] != w.count {
+			t.Errorf("第 %d 行不符: want %+v, got %+v", i, w, row)
+		}
+	}
+
+	// 失败状态过滤 + 无维度汇总
+	result, err = tracker.QueryPivot(ctx, &QueryOptions{Status: "failed"}, PivotQuery{})
+	if err != nil {
+		t.Fatalf("汇总查询失败: %v", err)
+	}
+	if len(result.Rows) != 1 || result.Rows[0].Metrics[PivotMetricCount] != 1 {
+		t.Errorf("失败请求汇总应为 1 条, got %+v", result.Rows)
+	}
+
+	// 按维度降序排序并截断
+	result, err = tracker.QueryPivot(ctx, nil, PivotQuery{
+		Dimensions: []string{PivotDimDay},
+		SortBy:     PivotDimDay,
+		SortOrder:  "desc",
+		Limit:      2,
+	})
+	if err != nil {
+		t.Fatalf("排序查询失败: %v", err)
+	}
+	if len(result.Rows) != 2 || result.TotalGroups != 4 || result.Rows[0].Dimensions[PivotDimDay] != "2025-12-08" {
+		t.Errorf("按天降序截断结果不符: %+v", result)
+	}
+
+	// 进行中的请求计入成功（与汇总统计口径一致）
+	insert("req-5", "2025-12-08 02:00:00", "claude-haiku", "processing", true, 10, 5, 0.01, 0)
+	result, err = tracker.QueryPivot(ctx, nil, PivotQuery{
+		Metrics: []string{PivotMetricSuccessCount, PivotMetricSuccessRate, PivotMetricP50Duration},
+		Filters: map[string][]string{PivotDimModel: {"claude-haiku"}},
+	})
+	if err != nil {
+		t.Fatalf("成功口径查询失败: %v", err)
+	}
+	if len(result.Rows) != 1 || result.Rows[0].Metrics[PivotMetricSuccessCount] != 1 ||
+		result.Rows[0].Metrics[PivotMetricSuccessRate] != 100 || result.Rows[0].Metrics[PivotMetricP50Duration] != 0 {
+		t.Errorf("processing 请求应计入成功且无耗时样本, got %+v", result.Rows)
+	}
+
+	// 非白名单维度/指标/排序字段均应拒绝
+	invalid := []PivotQuery{
+		{Dimensions: []string{"model_name; DROP TABLE request_logs"}},
+		{Metrics: []string{"SUM(total_cost_usd)"}},
+		{Filters: map[string][]string{"1=1 OR status": {"x"}}},
+		{Dimensions: []string{PivotDimModel}, SortBy: PivotDimChannel},
+		{Dimensions: []string{PivotDimModel, PivotDimModel}},
+	}
+	for i, q := range invalid {
+		if _, err := tracker.QueryPivot(ctx, nil, q); err == nil {
+			t.Errorf("非法查询 #%d 应返回错误", i)
+		}
+	}
+}
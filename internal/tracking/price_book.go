@@ -0,0 +1,285 @@
+package tracking
+
+import (
+	"log/slog"
+	"time"
+)
+
+// 价格来源（写入 request_logs.price_source）
+const (
+	PriceSourceModelPricing    = "model_pricing"   // 模型定价 × 端点倍率
+	PriceSourceDefaultPricing  = "default_pricing" // 未知模型，使用 _default / 默认定价 × 端点倍率
+	PriceSourcePriceBookPrefix = "price_book:"     // 渠道/端点价目表，后接价目表名称
+)
+
+// 价目表计价模式
+const (
+	PriceBookModeAbsolute = "absolute" // 按模型绝对价格（USD / 1M tokens）
+	PriceBookModeRatio    = "ratio"    // 按倍率公式：倍率单价 × 模型倍率 × 分组倍率
+)
+
+// DefaultRatioUnitPrice 倍率为 1 时对应的输入单价（USD / 1M tokens，即常见中转站约定的 $0.002 / 1K tokens）
+const DefaultRatioUnitPrice = 2.0
+
+// PriceBookWildcardModel 价目表通配条目，匹配表内未单独列出的模型
+const PriceBookWildcardModel = "*"
+
+// PriceBookEntry 价目表中单个模型的价格
+type PriceBookEntry struct {
+	Model string
+
+	// absolute 模式：USD / 1M tokens
+	// 缓存价格未设置（<=0）时按官方比例推导：5m 缓存创建 1.25x、1h 缓存创建 2x、缓存读取 0.1x 输入价
+	Input           float64
+	Output          float64
+	CacheCreation   float64
+	CacheCreation1h float64
+	CacheRead       float64
+
+	// ratio 模式：输入单价 = 倍率单价 × ModelRatio × 分组倍率
+	// 其余价格 = 输入单价 × 对应倍率（<=0 时补全率为 1，缓存读取 0.1，缓存创建 1.25）
+	ModelRatio         float64
+	CompletionRatio    float64
+	CacheReadRatio     float64
+	CacheCreationRatio float64
+
+	// 每次请求固定费用（USD），与价目表级费用叠加
+	PerRequestFee float64
+}
+
+// PriceBook 渠道或端点的价目表，命中时优先于模型定价（不再叠加端点倍率）
+type PriceBook struct {
+	Name           string
+	Channel        string
+	EndpointName   string // 为空时作用于整个渠道
+	Mode           string
+	GroupRatio     float64 // 分组倍率（ratio 模式，<=0 视为 1）
+	RatioUnitPrice float64 // 倍率单价（ratio 模式，<=0 使用 DefaultRatioUnitPrice）
+	PerRequestFee  float64 // 每次请求固定费用（USD）
+	Entries        []PriceBookEntry
+}
+
+// Source 返回价目表的价格来源标识
+func (b *PriceBook) Source() string {
+	return PriceSourcePriceBookPrefix + b.Name
+}
+
+// Entry 查找模型条目（精确匹配优先，其次通配条目）
+func (b *PriceBook) Entry(model string) (*PriceBookEntry, bool) {
+	var wildcard *PriceBookEntry
+	for i := range b.Entries {
+		switch b.Entries[i].Model {
+		case model:
+			return &b.Entries[i], true
+		case PriceBookWildcardModel:
+			wildcard = &b.Entries[i]
+		}
+	}
+	return wildcard, wildcard != nil
+}
+
+// Pricing 将价目表条目换算为模型定价（服务端工具沿用 base 定价）
+func (b *PriceBook) Pricing(entry *PriceBookEntry, base *ModelPricing) ModelPricing {
+	var pricing ModelPricing
+	if base != nil {
+		pricing.WebSearch = base.WebSearch
+		pricing.WebFetch = base.WebFetch
+	}
+
+	if b.Mode == PriceBookModeRatio {
+		unit := b.RatioUnitPrice
+		if unit <= 0 {
+			unit = DefaultRatioUnitPrice
+		}
+		input := unit * entry.ModelRatio * positiveOr(b.GroupRatio, 1)
+		pricing.Input = input
+		pricing.Output = input * positiveOr(entry.CompletionRatio, 1)
+		pricing.CacheCreation = input * positiveOr(entry.CacheCreationRatio, 1.25)
+		pricing.CacheRead = input * positiveOr(entry.CacheReadRatio, 0.1)
+		return pricing
+	}
+
+	pricing.Input = entry.Input
+	pricing.Output = entry.Output
+	pricing.CacheCreation = positiveOr(entry.CacheCreation, entry.Input*1.25)
+	pricing.CacheCreation1h = entry.CacheCreation1h // <=0 时 CalculateCostV2 按 2x 输入价计算
+	pricing.CacheRead = positiveOr(entry.CacheRead, entry.Input*0.1)
+	return pricing
+}
+
+// Cost 按价目表计算成本；模型未命中任何条目时返回 false
+// 固定费用仅在请求产生用量（token 或服务端工具调用）时计入，失败请求不收取
+func (b *PriceBook) Cost(model string, usage *TokenUsage, base *ModelPricing) (CostBreakdown, bool) {
+	entry, ok := b.Entry(model)
+	if !ok || usage == nil {
+		return CostBreakdown{}, false
+	}
+
+	pricing := b.Pricing(entry, base)
+	cost := CalculateCostV2(usage, &pricing, nil)
+	if usage.hasBillableUsage() {
+		cost.RequestFee = b.PerRequestFee + entry.PerRequestFee
+		cost.TotalCost += cost.RequestFee
+	}
+	cost.PriceSource = b.Source()
+	return cost, true
+}
+
+// hasBillableUsage 是否产生了计费用量
+func (u *TokenUsage) hasBillableUsage() bool {
+	return u.InputTokens > 0 || u.OutputTokens > 0 || u.CacheCreationTokens > 0 ||
+		u.CacheCreation5mTokens > 0 || u.CacheCreation1hTokens > 0 || u.CacheReadTokens > 0 ||
+		u.WebSearchRequests > 0 || u.WebFetchRequests > 0
+}
+
+// PriceBookSet 价目表快照（更新时整体替换，读取方无需加锁）
+// 查找优先级：端点价目表 > 渠道价目表；均未命中模型时回退到模型定价
+type PriceBookSet struct {
+	endpoints map[string]*PriceBook // key: endpointMultiplierKey(channel, "", endpointName)
+	channels  map[string]*PriceBook
+}
+
+// NewPriceBookSet 创建价目表快照（同一作用域重复时保留第一个）
+func NewPriceBookSet(books []PriceBook) *PriceBookSet {
+	s := &PriceBookSet{
+		endpoints: make(map[string]*PriceBook),
+		channels:  make(map[string]*PriceBook),
+	}
+	for i := range books {
+		book := &books[i]
+		if book.EndpointName != "" {
+			key := endpointMultiplierKey(book.Channel, "", book.EndpointName)
+			if _, exists := s.endpoints[key]; exists {
+				slog.Warn("⚠️ 端点价目表重复，已忽略", "price_book", book.Name, "endpoint", key)
+				continue
+			}
+			s.endpoints[key] = book
+			continue
+		}
+		if book.Channel == "" {
+			continue
+		}
+		if _, exists := s.channels[book.Channel]; exists {
+			slog.Warn("⚠️ 渠道价目表重复，已忽略", "price_book", book.Name, "channel", book.Channel)
+			continue
+		}
+		s.channels[book.Channel] = book
+	}
+	return s
+}
+
+// Len 返回价目表数量
+func (s *PriceBookSet) Len() int {
+	if s == nil {
+		return 0
+	}
+	return len(s.endpoints) + len(s.channels)
+}
+
+// Cost 按价目表计算请求成本；未命中任何价目表条目时返回 false
+func (s *PriceBookSet) Cost(channel, groupName, endpointName, model string, usage *TokenUsage, base *ModelPricing) (CostBreakdown, bool) {
+	if s == nil {
+		return CostBreakdown{}, false
+	}
+	if channel == "" {
+		channel = groupName
+	}
+	if key := endpointMultiplierKey(channel, "", endpointName); key != "" {
+		if book, ok := s.endpoints[key]; ok {
+			if cost, ok := book.Cost(model, usage, base); ok {
+				return cost, true
+			}
+		}
+	}
+	if book, ok := s.channels[channel]; ok {
+		return book.Cost(model, usage, base)
+	}
+	return CostBreakdown{}, false
+}
+
+// positiveOr 返回正数 v，否则返回默认值
+func positiveOr(v, def float64) float64 {
+	if v > 0 {
+		return v
+	}
+	return def
+}
+
+// UpdatePriceBooks 更新渠道/端点价目表（运行时动态更新）
+func (ut *UsageTracker) UpdatePriceBooks(books []PriceBook) {
+	ut.mu.Lock()
+	defer ut.mu.Unlock()
+
+	ut.priceBooks = NewPriceBookSet(books)
+
+	// 同步到 ArchiveManager
+	if ut.archiveManager != nil {
+		ut.archiveManager.UpdatePriceBooks(ut.priceBooks)
+	}
+
+	slog.Info("Price books updated", "price_book_count", ut.priceBooks.Len())
+}
+
+// CalculateRequestCost 计算单次请求成本（模型定价按 at 时刻生效的版本）
+// 价格来源优先级：端点价目表 > 渠道价目表 > 模型定价（_default / 默认定价兜底）× 端点倍率
+func (ut *UsageTracker) CalculateRequestCost(channel, groupName, endpointName, model string, usage *TokenUsage, at time.Time) CostBreakdown {
+	if ut == nil || usage == nil {
+		return CostBreakdown{}
+	}
+	return ut.costRules().cost(channel, groupName, endpointName, model, usage, at)
+}
+
+// costRules 返回跟踪器当前的计价规则快照
+func (ut *UsageTracker) costRules() costRules {
+	ut.mu.RLock()
+	defer ut.mu.RUnlock()
+
+	rules := costRules{
+		pricing:     ut.pricing,
+		history:     ut.pricingHistory,
+		multipliers: ut.endpointMu,
+		books:       ut.priceBooks,
+	}
+	if ut.config != nil {
+		rules.defaultPricing = ut.config.DefaultPricing
+	}
+	return rules
+}
+
+// costRules 计价规则快照：模型定价、定价历史、默认定价、端点倍率与价目表
+// 实时计价、归档、热池展示、预算与成本模拟共用同一套规则，保证同一请求在各处的成本一致
+type costRules struct {
+	pricing        map[string]ModelPricing
+	history        *PricingHistory
+	defaultPricing ModelPricing
+	multipliers    map[string]EndpointMultiplier
+	books          *PriceBookSet
+}
+
+// resolve 返回 at 时刻生效的模型定价（未配置时使用默认定价）、价格来源与渠道/端点倍率
+func (r costRules) resolve(channel, groupName, endpointName, model string, at time.Time) (ModelPricing, string, *EndpointMultiplier) {
+	pricing, source, exists := resolveModelPricing(r.pricing, r.history, model, at)
+	if !exists {
+		pricing = r.defaultPricing
+	}
+
+	var multiplier *EndpointMultiplier
+	if m, ok := r.multipliers[endpointMultiplierKey(channel, groupName, endpointName)]; ok {
+		multiplier = &m
+	} else if m, ok := r.multipliers[endpointName]; ok && endpointName != "" {
+		// 兼容旧 key：endpointName 作为唯一键
+		multiplier = &m
+	}
+	return pricing, source, multiplier
+}
+
+// cost 计算单次请求成本：价目表命中时优先，否则按模型定价 × 端点倍率
+func (r costRules) cost(channel, groupName, endpointName, model string, usage *TokenUsage, at time.Time) CostBreakdown {
+	pricing, source, multiplier := r.resolve(channel, groupName, endpointName, model, at)
+	if cost, ok := r.books.Cost(channel, groupName, endpointName, model, usage, &pricing); ok {
+		return cost
+	}
+	cost := CalculateCostV2(usage, &pricing, multiplier)
+	cost.PriceSource = source
+	return cost
+}
@@ -0,0 +1,156 @@
+package tracking
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// TestPriceBookSet_Cost 测试价目表作用域优先级与计价模式
+func TestPriceBookSet_Cost(t *testing.T) {
+	books := NewPriceBookSet([]PriceBook{
+		{
+			Name:          "relay-channel",
+			Channel:       "relay",
+			Mode:          PriceBookModeAbsolute,
+			PerRequestFee: 0.001,
+			Entries: []PriceBookEntry{
+				{Model: "claude-sonnet-4", Input: 1.5, Output: 7.5},
+				{Model: PriceBookWildcardModel, Input: 1, Output: 5, PerRequestFee: 0.002},
+			},
+		},
+		{
+			Name:           "relay-hk",
+			Channel:        "relay",
+			EndpointName:   "hk",
+			Mode:           PriceBookModeRatio,
+			GroupRatio:     0.5,
+			RatioUnitPrice: 2,
+			Entries: []PriceBookEntry{
+				{Model: "claude-sonnet-4", ModelRatio: 1.5, CompletionRatio: 5},
+			},
+		},
+	})
+	base := &ModelPricing{Input: 3, Output: 15, WebSearch: 10}
+	usage := &TokenUsage{InputTokens: 1_000_000, OutputTokens: 1_000_000, CacheReadTokens: 1_000_000}
+
+	tests := []struct {
+		name       string
+		endpoint   string
+		model      string
+		wantTotal  float64
+		wantSource string
+	}{
+		// ratio：输入单价 = 2 × 1.5 × 0.5 = 1.5，输出 7.5，缓存读取 0.15
+		{"端点价目表优先", "hk", "claude-sonnet-4", 1.5 + 7.5 + 0.15, "price_book:relay-hk"},
+		// 端点价目表未列出该模型：回退到渠道价目表通配条目（缓存读取按 0.1x 推导）+ 按次费用
+		{"端点未命中回退渠道", "hk", "claude-haiku", 1 + 5 + 0.1 + 0.003, "price_book:relay-channel"},
+		{"渠道价目表精确匹配", "us", "claude-sonnet-4", 1.5 + 7.5 + 0.15 + 0.001, "price_book:relay-channel"},
+	}
+	for _, tt := range tests {
+		cost, ok := books.Cost("relay", "", tt.endpoint, tt.model, usage, base)
+		if !ok || math.Abs(cost.TotalCost-tt.wantTotal) > 1e-9 || cost.PriceSource != tt.wantSource {
+			t.Errorf("%s: Cost = (%v, %s, %v), want (%v, %s)", tt.name, cost.TotalCost, cost.PriceSource, ok, tt.wantTotal, tt.wantSource)
+		}
+	}
+
+	if _, ok := books.Cost("official", "", "api", "claude-sonnet-4", usage, base); ok {
+		t.Error("未配置价目表的渠道不应命中")
+	}
+
+	// 无用量（如失败请求）不收取按次费用
+	if cost, ok := books.Cost("relay", "", "us", "claude-haiku", &TokenUsage{}, base); !ok || cost.TotalCost != 0 {
+		t.Errorf("无用量时成本 = (%v, %v), want (0, true)", cost.TotalCost, ok)
+	}
+
+	// 服务端工具沿用模型定价
+	if cost, _ := books.Cost("relay", "", "us", "claude-sonnet-4", &TokenUsage{WebSearchRequests: 100}, base); math.Abs(cost.WebSearchCost-1.0) > 1e-9 {
+		t.Errorf("web search 成本 = %v, want 1.0", cost.WebSearchCost)
+	}
+}
+
+// TestCalculateRequestCost_PriceSource 测试价目表优先于模型定价并记录价格来源
+func TestCalculateRequestCost_PriceSource(t *testing.T) {
+	ut := &UsageTracker{
+		config: &Config{DefaultPricing: ModelPricing{Input: 1, Output: 1}},
+		pricing: map[string]ModelPricing{
+			"claude-sonnet-4": {Input: 3, Output: 15},
+		},
+		endpointMu: map[string]EndpointMultiplier{
+			EndpointMultiplierKey("relay", "us"): {CostMultiplier: 0.5},
+		},
+	}
+	usage := &TokenUsage{InputTokens: 1_000_000, OutputTokens: 1_000_000}
+
+	cost := ut.CalculateRequestCost("relay", "", "us", "claude-sonnet-4", usage, time.Now())
+	if math.Abs(cost.TotalCost-9) > 1e-9 || cost.PriceSource != PriceSourceModelPricing {
+		t.Errorf("模型定价 × 端点倍率 = (%v, %s), want (9, %s)", cost.TotalCost, cost.PriceSource, PriceSourceModelPricing)
+	}
+
+	cost = ut.CalculateRequestCost("relay", "", "us", "unknown-model", usage, time.Now())
+	if math.Abs(cost.TotalCost-1) > 1e-9 || cost.PriceSource != PriceSourceDefaultPricing {
+		t.Errorf("默认定价 = (%v, %s), want (1, %s)", cost.TotalCost, cost.PriceSource, PriceSourceDefaultPricing)
+	}
+
+	// 价目表命中后不再叠加端点倍率
+	ut.UpdatePriceBooks([]PriceBook{{
+		Name:    "relay-book",
+		Channel: "relay",
+		Mode:    PriceBookModeAbsolute,
+		Entries: []PriceBookEntry{{Model: "claude-sonnet-4", Input: 2, Output: 10}},
+	}})
+	cost = ut.CalculateRequestCost("relay", "", "us", "claude-sonnet-4", usage, time.Now())
+	if math.Abs(cost.TotalCost-12) > 1e-9 || cost.PriceSource != "price_book:relay-book" {
+		t.Errorf("价目表成本 = (%v, %s), want (12, price_book:relay-book)", cost.TotalCost, cost.PriceSource)
+	}
+}
+
+func TestArchiveCost_MatchesCalculateRequestCost(t *testing.T) {
+	pricing := map[string]ModelPricing{"claude-sonnet-4": {Input: 3, Output: 15}}
+	multipliers := map[string]EndpointMultiplier{
+		// 旧 key：仅 endpointName
+		"legacy": {CostMultiplier: 0.5},
+	}
+	defaultPricing := ModelPricing{Input: 1, Output: 2}
+
+	ut := &UsageTracker{
+		config:     &Config{DefaultPricing: defaultPricing},
+		pricing:    pricing,
+		endpointMu: multipliers,
+	}
+	am := &ArchiveManager{pricing: pricing, endpointMu: multipliers}
+	am.UpdateDefaultPricing(defaultPricing)
+
+	tests := []struct {
+		name, channel, endpoint, model string
+	}{
+		{"未配置模型使用默认定价", "relay", "us", "unknown-model"},
+		{"旧 endpointName 倍率 key", "relay", "legacy", "claude-sonnet-4"},
+		{"无渠道的旧 key", "", "legacy", "claude-sonnet-4"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &ActiveRequest{
+				Channel:      tt.channel,
+				EndpointName: tt.endpoint,
+				ModelName:    tt.model,
+				StartTime:    time.Now(),
+				InputTokens:  1_000_000,
+				OutputTokens: 1_000_000,
+			}
+			usage := &TokenUsage{InputTokens: req.InputTokens, OutputTokens: req.OutputTokens}
+
+			want := ut.CalculateRequestCost(req.Channel, req.GroupName, req.EndpointName, req.ModelName, usage, req.StartTime)
+			got := am.calculateCostV2(req)
+			if math.Abs(got.TotalCost-want.TotalCost) > 1e-9 || got.PriceSource != want.PriceSource {
+				t.Errorf("归档成本 = (%v, %s), 实时成本 = (%v, %s)", got.TotalCost, got.PriceSource, want.TotalCost, want.PriceSource)
+			}
+			if want.TotalCost == 0 {
+				t.Errorf("成本不应为 0")
+			}
+			if detail := ut.ActiveRequestToDetail(req); math.Abs(detail.TotalCostUSD-want.TotalCost) > 1e-9 {
+				t.Errorf("热池展示成本 = %v, want %v", detail.TotalCostUSD, want.TotalCost)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package tracking
+
+import (
+	"log/slog"
+	"sort"
+	"time"
+)
+
+// PricingVersion 模型定价版本，自 EffectiveFrom 起生效直到下一版本
+type PricingVersion struct {
+	Model         string
+	EffectiveFrom time.Time
+	Pricing       ModelPricing
+}
+
+// PricingHistory 按生效时间查找模型定价版本（更新时整体替换，读取方无需加锁）
+type PricingHistory struct {
+	versions map[string][]PricingVersion // 按 EffectiveFrom 升序
+}
+
+// NewPricingHistory 创建定价历史
+func NewPricingHistory(versions []PricingVersion) *PricingHistory {
+	h := &PricingHistory{versions: make(map[string][]PricingVersion)}
+	for _, v := range versions {
+		if v.Model == "" {
+			continue
+		}
+		h.versions[v.Model] = append(h.versions[v.Model], v)
+	}
+	for model := range h.versions {
+		list := h.versions[model]
+		sort.Slice(list, func(i, j int) bool { return list[i].EffectiveFrom.Before(list[j].EffectiveFrom) })
+	}
+	return h
+}
+
+// Len 返回定价版本总数
+func (h *PricingHistory) Len() int {
+	if h == nil {
+		return 0
+	}
+	n := 0
+	for _, list := range h.versions {
+		n += len(list)
+	}
+	return n
+}
+
+// At 返回 at 时刻生效的模型定价；模型无历史版本时返回 false
+// 若 at 早于最早版本，使用最早版本（与汇率规则一致）
+func (h *PricingHistory) At(model string, at time.Time) (ModelPricing, bool) {
+	if h == nil {
+		return ModelPricing{}, false
+	}
+	list := h.versions[model]
+	if len(list) == 0 {
+		return ModelPricing{}, false
+	}
+	idx := sort.Search(len(list), func(i int) bool { return list[i].EffectiveFrom.After(at) })
+	if idx == 0 {
+		return list[0].Pricing, true
+	}
+	return list[idx-1].Pricing, true
+}
+
+// resolveModelPricing 解析请求时刻生效的模型定价及价格来源
+// 模型是否已配置以当前定价表为准（删除的模型不再按其历史版本计价），有历史版本时使用请求时刻生效的版本
+func resolveModelPricing(current map[string]ModelPricing, history *PricingHistory, model string, at time.Time) (ModelPricing, string, bool) {
+	if pricing, ok := current[model]; ok {
+		if versioned, ok := history.At(model, at); ok {
+			return versioned, PriceSourceModelPricing, true
+		}
+		return pricing, PriceSourceModelPricing, true
+	}
+	if pricing, ok := current["_default"]; ok {
+		if versioned, ok := history.At("_default", at); ok {
+			return versioned, PriceSourceDefaultPricing, true
+		}
+		return pricing, PriceSourceDefaultPricing, true
+	}
+	return ModelPricing{}, PriceSourceDefaultPricing, false
+}
+
+// UpdatePricingHistory 更新模型定价历史版本（运行时动态更新）
+func (ut *UsageTracker) UpdatePricingHistory(versions []PricingVersion) {
+	ut.mu.Lock()
+	defer ut.mu.Unlock()
+
+	ut.pricingHistory = NewPricingHistory(versions)
+
+	// 同步到 ArchiveManager
+	if ut.archiveManager != nil {
+		ut.archiveManager.UpdatePricingHistory(ut.pricingHistory)
+	}
+
+	slog.Info("Pricing history updated", "version_count", ut.pricingHistory.Len())
+}
@@ -0,0 +1,73 @@
+package tracking
+
+// PricingTier 长上下文分档定价
+// 请求提示总 token（input + cache read + cache creation）超过 Threshold 时使用该档位价格
+// 价格为 0 的字段沿用基础价格；缓存价格未设置但设置了输入价格时，按输入价格的涨幅等比换算
+type PricingTier struct {
+	Threshold       int64   `yaml:"threshold" json:"threshold"`                           // 提示 token 阈值（超过即适用）
+	Input           float64 `yaml:"input" json:"input,omitempty"`                         // per 1M tokens
+	Output          float64 `yaml:"output" json:"output,omitempty"`                       // per 1M tokens
+	CacheCreation   float64 `yaml:"cache_creation" json:"cache_creation,omitempty"`       // per 1M tokens (5分钟缓存创建)
+	CacheCreation1h float64 `yaml:"cache_creation_1h" json:"cache_creation_1h,omitempty"` // per 1M tokens (1小时缓存创建)
+	CacheRead       float64 `yaml:"cache_read" json:"cache_read,omitempty"`               // per 1M tokens (缓存读取)
+}
+
+// PromptTokens 返回请求提示总 token 数（用于选择长上下文档位）
+func (u *TokenUsage) PromptTokens() int64 {
+	if u == nil {
+		return 0
+	}
+	cacheCreation := u.CacheCreationTokens
+	if cacheCreation == 0 {
+		cacheCreation = u.CacheCreation5mTokens + u.CacheCreation1hTokens
+	}
+	return u.InputTokens + u.CacheReadTokens + cacheCreation
+}
+
+// SelectTier 按提示总 token 选择适用档位，返回生效定价及档位阈值（0 表示基础价格）
+func (p ModelPricing) SelectTier(promptTokens int64) (ModelPricing, int64) {
+	var selected *PricingTier
+	for i := range p.Tiers {
+		tier := &p.Tiers[i]
+		if tier.Threshold <= 0 || promptTokens <= tier.Threshold {
+			continue
+		}
+		if selected == nil || tier.Threshold > selected.Threshold {
+			selected = tier
+		}
+	}
+	if selected == nil {
+		return p, 0
+	}
+
+	effective := p
+	effective.Tiers = nil
+
+	// 缓存价格默认按输入价格涨幅等比换算
+	ratio := 1.0
+	if selected.Input > 0 {
+		effective.Input = selected.Input
+		if p.Input > 0 {
+			ratio = selected.Input / p.Input
+		}
+	}
+	if selected.Output > 0 {
+		effective.Output = selected.Output
+	}
+	effective.CacheCreation = tierPrice(selected.CacheCreation, p.CacheCreation, ratio)
+	effective.CacheRead = tierPrice(selected.CacheRead, p.CacheRead, ratio)
+	base1h := p.CacheCreation1h
+	if base1h <= 0 {
+		base1h = p.Input * 2.0
+	}
+	effective.CacheCreation1h = tierPrice(selected.CacheCreation1h, base1h, ratio)
+
+	return effective, selected.Threshold
+}
+
+func tierPrice(tierValue, baseValue, ratio float64) float64 {
+	if tierValue > 0 {
+		return tierValue
+	}
+	return baseValue * ratio
+}
@@ -0,0 +1,124 @@
+package tracking
+
+import (
+	"math"
+	"testing"
+)
+
+func sonnetPricingWithTiers() *ModelPricing {
+	return &ModelPricing{
+		Input:           3.0,
+		Output:          15.0,
+		CacheCreation:   3.75,
+		CacheCreation1h: 6.0,
+		CacheRead:       0.30,
+		Tiers: []PricingTier{
+			{Threshold: 200_000, Input: 6.0, Output: 22.5},
+		},
+	}
+}
+
+// TestSelectTier 测试按提示总 token 选择长上下文档位
+func TestSelectTier(t *testing.T) {
+	pricing := sonnetPricingWithTiers()
+
+	tests := []struct {
+		name          string
+		promptTokens  int64
+		wantThreshold int64
+		wantInput     float64
+	}{
+		{"低于阈值", 150_000, 0, 3.0},
+		{"等于阈值仍为基础价格", 200_000, 0, 3.0},
+		{"超过阈值", 200_001, 200_000, 6.0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			effective, threshold := pricing.SelectTier(tt.promptTokens)
+			if threshold != tt.wantThreshold {
+				t.Errorf("threshold = %d, want %d", threshold, tt.wantThreshold)
+			}
+			if effective.Input != tt.wantInput {
+				t.Errorf("input price = %f, want %f", effective.Input, tt.wantInput)
+			}
+		})
+	}
+}
+
+// TestSelectTier_DerivesCachePrices 测试档位未设置缓存价格时按输入价格涨幅换算
+func TestSelectTier_DerivesCachePrices(t *testing.T) {
+	effective, _ := sonnetPricingWithTiers().SelectTier(500_000)
+
+	if math.Abs(effective.CacheCreation-7.5) > 0.0001 {
+		t.Errorf("CacheCreation = %f, want 7.5", effective.CacheCreation)
+	}
+	if math.Abs(effective.CacheCreation1h-12.0) > 0.0001 {
+		t.Errorf("CacheCreation1h = %f, want 12.0", effective.CacheCreation1h)
+	}
+	if math.Abs(effective.CacheRead-0.6) > 0.0001 {
+		t.Errorf("CacheRead = %f, want 0.6", effective.CacheRead)
+	}
+	if effective.Output != 22.5 {
+		t.Errorf("Output = %f, want 22.5", effective.Output)
+	}
+}
+
+// TestSelectTier_HighestMatchingTier 测试多档位时选择最高的已超过阈值
+func TestSelectTier_HighestMatchingTier(t *testing.T) {
+	pricing := &ModelPricing{
+		Input:  3.0,
+		Output: 15.0,
+		Tiers: []PricingTier{
+			{Threshold: 500_000, Input: 9.0},
+			{Threshold: 200_000, Input: 6.0},
+		},
+	}
+
+	if _, threshold := pricing.SelectTier(300_000); threshold != 200_000 {
+		t.Errorf("threshold = %d, want 200000", threshold)
+	}
+	effective, threshold := pricing.SelectTier(600_000)
+	if threshold != 500_000 || effective.Input != 9.0 {
+		t.Errorf("got threshold=%d input=%f, want 500000 / 9.0", threshold, effective.Input)
+	}
+	if effective.Output != 15.0 {
+		t.Errorf("Output = %f, want base 15.0", effective.Output)
+	}
+}
+
+// TestCalculateCostV2_LongContextTier 测试提示总 token（含缓存）超过阈值时按长上下文价格计费
+func TestCalculateCostV2_LongContextTier(t *testing.T) {
+	usage := &TokenUsage{
+		InputTokens:     50_000,
+		OutputTokens:    10_000,
+		CacheReadTokens: 180_000, // 50K + 180K = 230K > 200K
+	}
+
+	result := CalculateCostV2(usage, sonnetPricingWithTiers(), nil)
+
+	if result.PricingTier != 200_000 {
+		t.Fatalf("PricingTier = %d, want 200000", result.PricingTier)
+	}
+	// Input: 50K * $6/1M = $0.30
+	if math.Abs(result.InputCost-0.30) > 0.0001 {
+		t.Errorf("InputCost = %f, want 0.30", result.InputCost)
+	}
+	// Output: 10K * $22.5/1M = $0.225
+	if math.Abs(result.OutputCost-0.225) > 0.0001 {
+		t.Errorf("OutputCost = %f, want 0.225", result.OutputCost)
+	}
+	// Cache read: 180K * $0.60/1M = $0.108
+	if math.Abs(result.CacheReadCost-0.108) > 0.0001 {
+		t.Errorf("CacheReadCost = %f, want 0.108", result.CacheReadCost)
+	}
+
+	// 未超过阈值时使用基础价格
+	small := CalculateCostV2(&TokenUsage{InputTokens: 50_000}, sonnetPricingWithTiers(), nil)
+	if small.PricingTier != 0 {
+		t.Errorf("PricingTier = %d, want 0", small.PricingTier)
+	}
+	if math.Abs(small.InputCost-0.15) > 0.0001 {
+		t.Errorf("InputCost = %f, want 0.15", small.InputCost)
+	}
+}
@@ -36,8 +36,15 @@ type QueryOptions struct {
 	EndpointName string
 	GroupName    string
 	Status       string
+	SessionID    string
+	Tags         []RequestTag // 标签筛选（多个标签为 AND 关系）
 	Limit        int
 	Offset       int
+
+	StopReason      string // 停止原因（end_turn / max_tokens / tool_use 等）
+	HasToolUse      *bool  // 响应是否包含工具调用
+	HasThinking     *bool  // 响应是否包含 thinking 内容块
+	MinRequestBytes int64  // 请求体最小字节数
 }
 
 // UsageSummary represents a summary of usage data
@@ -89,6 +96,29 @@ type RequestDetail struct {
 	LastFailureReason string `json:"last_failure_reason"` // 最后一次失败的详细信息
 	CancelReason      string `json:"cancel_reason"`       // 取消原因
 
+	SessionID string       `json:"session_id"`     // 客户端会话标识
+	Tags      []RequestTag `json:"tags,omitempty"` // 请求标签
+
+	// 响应时间线（相对最后一次上游请求发出时刻，nil 表示未测量）
+	TTFBMs             *int64   `json:"ttfb_ms"`
+	FirstEventMs       *int64   `json:"first_event_ms"`
+	FirstTokenMs       *int64   `json:"first_token_ms"`
+	OutputTokensPerSec *float64 `json:"output_tokens_per_sec"`
+
+	// 响应元信息
+	StopReason        string `json:"stop_reason"`
+	ResponseMessageID string `json:"response_message_id"`
+	ToolUseCount      int    `json:"tool_use_count"`
+	HasThinking       bool   `json:"has_thinking"`
+
+	// 请求参数（来自请求体，0 表示未指定或旧数据）
+	MaxTokens      int64    `json:"max_tokens"`
+	MessageCount   int      `json:"message_count"`
+	ToolCount      int      `json:"tool_count"`
+	ThinkingBudget int64    `json:"thinking_budget"`
+	Temperature    *float64 `json:"temperature"`
+	RequestBytes   int64    `json:"request_bytes"`
+
 	InputTokens           int64 `json:"input_tokens"`
 	OutputTokens          int64 `json:"output_tokens"`
 	CacheCreationTokens   int64 `json:"cache_creation_tokens"`    // 总缓存创建（向后兼容）
@@ -96,11 +126,30 @@ type RequestDetail struct {
 	CacheCreation1hTokens int64 `json:"cache_creation_1h_tokens"` // v5.0.1: 1小时缓存
 	CacheReadTokens       int64 `json:"cache_read_tokens"`
 
+	// 服务端工具调用次数（web search / web fetch）
+	WebSearchRequests int64 `json:"web_search_requests"`
+	WebFetchRequests  int64 `json:"web_fetch_requests"`
+
 	InputCostUSD         float64 `json:"input_cost_usd"`
 	OutputCostUSD        float64 `json:"output_cost_usd"`
 	CacheCreationCostUSD float64 `json:"cache_creation_cost_usd"`
 	CacheReadCostUSD     float64 `json:"cache_read_cost_usd"`
+	ServerToolCostUSD    float64 `json:"server_tool_cost_usd"`
 	TotalCostUSD         float64 `json:"total_cost_usd"`
+	PricingTier          int64   `json:"pricing_tier"` // 生效的长上下文档位阈值（0 表示基础价格）
+	IsBatch              bool    `json:"is_batch"`     // Message Batches 结果用量（按批处理价格计费）
+	PriceSource          string  `json:"price_source"` // 价格来源（model_pricing / default_pricing / price_book:<名称>）
+	RequestFeeUSD        float64 `json:"request_fee_usd"`
+
+	// 多币种成本（按请求时的汇率换算，nil 表示写入时缺少汇率或旧数据）
+	BillingCurrency   string   `json:"billing_currency"`
+	BillingCost       *float64 `json:"billing_cost"`
+	ReportingCurrency string   `json:"reporting_currency"`
+	ReportingCost     *float64 `json:"reporting_cost"`
+
+	// 展示币种成本（仅导出时按请求的展示币种填充）
+	DisplayCurrency string  `json:"display_currency,omitempty"`
+	DisplayCost     float64 `json:"display_cost,omitempty"`
 
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
@@ -125,6 +174,9 @@ type UsageStatsTotals struct {
 	TotalCostUSD    float64
 	DurationSumMs   int64
 	DurationCount   int64
+
+	InputTokens  int64
+	OutputTokens int64
 }
 
 func (t *UsageStatsTotals) add(other UsageStatsTotals) {
@@ -135,6 +187,8 @@ func (t *UsageStatsTotals) add(other UsageStatsTotals) {
 	t.TotalCostUSD += other.TotalCostUSD
 	t.DurationSumMs += other.DurationSumMs
 	t.DurationCount += other.DurationCount
+	t.InputTokens += other.InputTokens
+	t.OutputTokens += other.OutputTokens
 }
 
 func (t UsageStatsTotals) AvgDurationMs() float64 {
@@ -162,6 +216,10 @@ type EndpointCostSummary struct {
 	OutputCostUSD        float64 `json:"output_cost_usd"`
 	CacheCreationCostUSD float64 `json:"cache_creation_cost_usd"`
 	CacheReadCostUSD     float64 `json:"cache_read_cost_usd"`
+
+	// 展示币种总成本（明细成本仍为 USD）
+	Currency  string  `json:"currency"`
+	TotalCost float64 `json:"total_cost"`
 }
 
 // GetDB returns the read database connection for external queries (读写分离：返回读连接)
@@ -279,8 +337,20 @@ func (ut *UsageTracker) QueryRequestDetails(ctx context.Context, opts *QueryOpti
 		input_tokens, output_tokens,
 		cache_creation_tokens, COALESCE(cache_creation_5m_tokens, 0) as cache_creation_5m_tokens, COALESCE(cache_creation_1h_tokens, 0) as cache_creation_1h_tokens,
 		cache_read_tokens,
+		COALESCE(web_search_requests, 0) as web_search_requests, COALESCE(web_fetch_requests, 0) as web_fetch_requests,
 		input_cost_usd, output_cost_usd, cache_creation_cost_usd,
-		cache_read_cost_usd, total_cost_usd,
+		cache_read_cost_usd, COALESCE(server_tool_cost_usd, 0) as server_tool_cost_usd, total_cost_usd,
+		COALESCE(pricing_tier, 0) as pricing_tier, COALESCE(is_batch, 0) as is_batch,
+		COALESCE(price_source, '') as price_source, COALESCE(request_fee_usd, 0) as request_fee_usd,
+		COALESCE(billing_currency, 'USD') as billing_currency, billing_cost,
+		COALESCE(reporting_currency, 'USD') as reporting_currency, reporting_cost,
+		COALESCE(session_id, '') as session_id,
+		ttfb_ms, first_event_ms, first_token_ms, output_tokens_per_sec,
+		COALESCE(stop_reason, '') as stop_reason, COALESCE(response_message_id, '') as response_message_id,
+		COALESCE(tool_use_count, 0) as tool_use_count, COALESCE(has_thinking, 0) as has_thinking,
+		COALESCE(max_tokens, 0) as max_tokens, COALESCE(message_count, 0) as message_count,
+		COALESCE(tool_count, 0) as tool_count, COALESCE(thinking_budget, 0) as thinking_budget,
+		temperature, COALESCE(request_bytes, 0) as request_bytes,
 		created_at, updated_at
 		FROM request_logs WHERE 1=1`
 
@@ -310,6 +380,16 @@ func (ut *UsageTracker) QueryRequestDetails(ctx context.Context, opts *QueryOpti
 		query += " AND group_name = ?"
 		args = append(args, opts.GroupName)
 	}
+	if opts.SessionID != "" {
+		query += " AND session_id = ?"
+		args = append(args, opts.SessionID)
+	}
+	tagClause, tagArgs := tagFilterSQL(opts.Tags)
+	query += tagClause
+	args = append(args, tagArgs...)
+	metaClause, metaArgs := responseMetaFilterSQL(opts)
+	query += metaClause
+	args = append(args, metaArgs...)
 	if opts.Status != "" {
 		// v3.5.0状态机重构 - 状态与错误分离的兼容查询
 		switch opts.Status {
@@ -371,6 +451,7 @@ func (ut *UsageTracker) QueryRequestDetails(ctx context.Context, opts *QueryOpti
 	var details []RequestDetail
 	for rows.Next() {
 		var detail RequestDetail
+		var billingCost, reportingCost sql.NullFloat64
 		err := rows.Scan(
 			&detail.ID, &detail.RequestID,
 			&detail.ClientIP, &detail.UserAgent, &detail.Method, &detail.Path,
@@ -381,13 +462,28 @@ func (ut *UsageTracker) QueryRequestDetails(ctx context.Context, opts *QueryOpti
 			&detail.FailureReason, &detail.LastFailureReason, &detail.CancelReason,
 			&detail.InputTokens, &detail.OutputTokens,
 			&detail.CacheCreationTokens, &detail.CacheCreation5mTokens, &detail.CacheCreation1hTokens, &detail.CacheReadTokens,
+			&detail.WebSearchRequests, &detail.WebFetchRequests,
 			&detail.InputCostUSD, &detail.OutputCostUSD,
-			&detail.CacheCreationCostUSD, &detail.CacheReadCostUSD, &detail.TotalCostUSD,
+			&detail.CacheCreationCostUSD, &detail.CacheReadCostUSD, &detail.ServerToolCostUSD, &detail.TotalCostUSD,
+			&detail.PricingTier, &detail.IsBatch,
+			&detail.PriceSource, &detail.RequestFeeUSD,
+			&detail.BillingCurrency, &billingCost, &detail.ReportingCurrency, &reportingCost,
+			&detail.SessionID,
+			&detail.TTFBMs, &detail.FirstEventMs, &detail.FirstTokenMs, &detail.OutputTokensPerSec,
+			&detail.StopReason, &detail.ResponseMessageID, &detail.ToolUseCount, &detail.HasThinking,
+			&detail.MaxTokens, &detail.MessageCount, &detail.ToolCount, &detail.ThinkingBudget,
+			&detail.Temperature, &detail.RequestBytes,
 			&detail.CreatedAt, &detail.UpdatedAt,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan request detail: %w", err)
 		}
+		if billingCost.Valid {
+			detail.BillingCost = &billingCost.Float64
+		}
+		if reportingCost.Valid {
+			detail.ReportingCost = &reportingCost.Float64
+		}
 		details = append(details, detail)
 	}
 
@@ -395,6 +491,10 @@ func (ut *UsageTracker) QueryRequestDetails(ctx context.Context, opts *QueryOpti
 		return nil, fmt.Errorf("error iterating request detail rows: %w", err)
 	}
 
+	if err := ut.loadRequestTags(ctx, details); err != nil {
+		return nil, err
+	}
+
 	return details, nil
 }
 
@@ -453,9 +553,8 @@ func (ut *UsageTracker) QueryUsageStats(ctx context.Context, period string) (*Us
 	return &stats, nil
 }
 
-// QueryUsageStatsTotals 查询聚合统计（数据库部分）。
-// 该方法只做聚合，不返回明细，适用于前端统计卡片/图表的快速刷新。
-func (ut *UsageTracker) QueryUsageStatsTotals(ctx context.Context, opts *QueryOptions) (*UsageStatsTotals, error) {
+// queryRawUsageStatsTotals 直接从 request_logs 查询聚合统计（支持全部筛选条件）
+func (ut *UsageTracker) queryRawUsageStatsTotals(ctx context.Context, opts *QueryOptions) (*UsageStatsTotals, error) {
 	if ut.readDB == nil {
 		return nil, fmt.Errorf("read database not initialized")
 	}
@@ -467,7 +566,9 @@ func (ut *UsageTracker) QueryUsageStatsTotals(ctx context.Context, opts *QueryOp
 		COALESCE(SUM(input_tokens + output_tokens + cache_creation_tokens + cache_read_tokens), 0) as total_tokens,
 		COALESCE(SUM(total_cost_usd), 0.0) as total_cost_usd,
 		COALESCE(SUM(CASE WHEN duration_ms IS NOT NULL AND duration_ms > 0 THEN duration_ms ELSE 0 END), 0) as duration_sum_ms,
-		COALESCE(SUM(CASE WHEN duration_ms IS NOT NULL AND duration_ms > 0 THEN 1 ELSE 0 END), 0) as duration_count
+		COALESCE(SUM(CASE WHEN duration_ms IS NOT NULL AND duration_ms > 0 THEN 1 ELSE 0 END), 0) as duration_count,
+		COALESCE(SUM(input_tokens), 0) as input_tokens,
+		COALESCE(SUM(output_tokens), 0) as output_tokens
 		FROM request_logs WHERE 1=1`
 
 	var args []interface{}
@@ -497,6 +598,16 @@ func (ut *UsageTracker) QueryUsageStatsTotals(ctx context.Context, opts *QueryOp
 			query += " AND group_name = ?"
 			args = append(args, opts.GroupName)
 		}
+		if opts.SessionID != "" {
+			query += " AND session_id = ?"
+			args = append(args, opts.SessionID)
+		}
+		tagClause, tagArgs := tagFilterSQL(opts.Tags)
+		query += tagClause
+		args = append(args, tagArgs...)
+		metaClause, metaArgs := responseMetaFilterSQL(opts)
+		query += metaClause
+		args = append(args, metaArgs...)
 		if opts.Status != "" {
 			// 与 QueryRequestDetails 一致：failed 为兼容集合查询，其余精确匹配。
 			switch opts.Status {
@@ -518,6 +629,8 @@ func (ut *UsageTracker) QueryUsageStatsTotals(ctx context.Context, opts *QueryOp
 		&totals.TotalCostUSD,
 		&totals.DurationSumMs,
 		&totals.DurationCount,
+		&totals.InputTokens,
+		&totals.OutputTokens,
 	); err != nil {
 		return nil, fmt.Errorf("failed to query usage stats totals: %w", err)
 	}
@@ -550,6 +663,8 @@ func (ut *UsageTracker) QueryUsageStatsTotalsWithHotPool(ctx context.Context, op
 		}
 
 		hotTotals.TotalTokens += req.InputTokens + req.OutputTokens + req.CacheCreationTokens + req.CacheReadTokens
+		hotTotals.InputTokens += req.InputTokens
+		hotTotals.OutputTokens += req.OutputTokens
 		hotTotals.TotalCostUSD += req.TotalCostUSD
 
 		if req.DurationMs != nil && *req.DurationMs > 0 {
@@ -595,6 +710,16 @@ func (ut *UsageTracker) CountRequestDetails(ctx context.Context, opts *QueryOpti
 		query += " AND group_name = ?"
 		args = append(args, opts.GroupName)
 	}
+	if opts.SessionID != "" {
+		query += " AND session_id = ?"
+		args = append(args, opts.SessionID)
+	}
+	tagClause, tagArgs := tagFilterSQL(opts.Tags)
+	query += tagClause
+	args = append(args, tagArgs...)
+	metaClause, metaArgs := responseMetaFilterSQL(opts)
+	query += metaClause
+	args = append(args, metaArgs...)
 	if opts.Status != "" {
 		// 与 QueryRequestDetails 保持一致：failed 代表一组失败/错误状态
 		switch opts.Status {
@@ -616,11 +741,18 @@ func (ut *UsageTracker) CountRequestDetails(ctx context.Context, opts *QueryOpti
 }
 
 // GetEndpointCostsForDate queries endpoint cost summary data for a specific date
-func (ut *UsageTracker) GetEndpointCostsForDate(ctx context.Context, date string) ([]EndpointCostSummary, error) {
+// displayCurrency 可选：总成本换算到的展示币种（默认使用报表币种）
+func (ut *UsageTracker) GetEndpointCostsForDate(ctx context.Context, date string, displayCurrency ...string) ([]EndpointCostSummary, error) {
 	if ut.readDB == nil {
 		return nil, fmt.Errorf("read database not initialized")
 	}
 
+	currency := ut.ResolveDisplayCurrency(firstString(displayCurrency))
+	costExpr, costArgs, err := ut.DisplayCostExpr(currency)
+	if err != nil {
+		return nil, err
+	}
+
 	// Validate date format
 	if _, err := time.Parse("2006-01-02", date); err != nil {
 		return nil, fmt.Errorf("invalid date format, expected YYYY-MM-DD: %w", err)
@@ -657,13 +789,15 @@ func (ut *UsageTracker) GetEndpointCostsForDate(ctx context.Context, date string
 		COALESCE(SUM(input_cost_usd), 0.0) as input_cost_usd,
 		COALESCE(SUM(output_cost_usd), 0.0) as output_cost_usd,
 		COALESCE(SUM(cache_creation_cost_usd), 0.0) as cache_creation_cost_usd,
-		COALESCE(SUM(cache_read_cost_usd), 0.0) as cache_read_cost_usd
+		COALESCE(SUM(cache_read_cost_usd), 0.0) as cache_read_cost_usd,
+		COALESCE(SUM(` + costExpr + `), 0.0) as total_cost
 		FROM request_logs
 		WHERE start_time >= ? AND start_time <= ?
 		GROUP BY endpoint_name, group_name
 		ORDER BY total_cost_usd DESC`
 
-	rows, err := ut.readDB.QueryContext(ctx, query, ut.formatStartTimeQueryBound(startOfDay), ut.formatEndTimeQueryBound(endOfDay))
+	args := append(costArgs, ut.formatStartTimeQueryBound(startOfDay), ut.formatEndTimeQueryBound(endOfDay))
+	rows, err := ut.readDB.QueryContext(ctx, query, args...)
 	if err != nil {
 		slog.Error("Failed to query endpoint costs", "error", err, "date", date, "start_time", startOfDay, "end_time", endOfDay)
 		return nil, fmt.Errorf("failed to query endpoint costs for date %s: %w", date, err)
@@ -680,11 +814,13 @@ func (ut *UsageTracker) GetEndpointCostsForDate(ctx context.Context, date string
 			&cost.CacheCreationTokens, &cost.CacheReadTokens,
 			&cost.InputCostUSD, &cost.OutputCostUSD,
 			&cost.CacheCreationCostUSD, &cost.CacheReadCostUSD,
+			&cost.TotalCost,
 		)
 		if err != nil {
 			slog.Error("Failed to scan endpoint cost row", "error", err)
 			return nil, fmt.Errorf("failed to scan endpoint cost: %w", err)
 		}
+		cost.Currency = currency
 		costs = append(costs, cost)
 	}
 
@@ -0,0 +1,169 @@
+package tracking
+
+import (
+	"encoding/json"
+)
+
+// RequestParams 请求体中的生成参数与请求规模（0 表示请求体未指定）
+type RequestParams struct {
+	MaxTokens      int64    `json:"max_tokens"`
+	MessageCount   int      `json:"message_count"`
+	ToolCount      int      `json:"tool_count"`
+	ThinkingBudget int64    `json:"thinking_budget"`       // 扩展思考预算（budget_tokens）
+	Temperature    *float64 `json:"temperature,omitempty"` // nil 表示使用上游默认值
+	RequestBytes   int64    `json:"request_bytes"`
+}
+
+// IsZero 是否未采集到任何请求参数
+func (p RequestParams) IsZero() bool {
+	return p == RequestParams{}
+}
+
+// applyTo 将请求参数填充到请求详情
+func (p RequestParams) applyTo(detail *RequestDetail) {
+	detail.MaxTokens = p.MaxTokens
+	detail.MessageCount = p.MessageCount
+	detail.ToolCount = p.ToolCount
+	detail.ThinkingBudget = p.ThinkingBudget
+	if p.Temperature != nil {
+		v := *p.Temperature
+		detail.Temperature = &v
+	}
+	detail.RequestBytes = p.RequestBytes
+}
+
+// ResponseMeta 上游响应元信息（停止原因、消息 ID 与生成的内容块类型）
+type ResponseMeta struct {
+	StopReason   string `json:"stop_reason"` // end_turn / max_tokens / tool_use / stop_sequence / pause_turn / refusal
+	MessageID    string `json:"message_id"`
+	ToolUseCount int    `json:"tool_use_count"` // 客户端工具调用（tool_use 内容块）数量
+	HasThinking  bool   `json:"has_thinking"`   // 是否包含 thinking / redacted_thinking 内容块
+}
+
+// IsZero 是否未采集到任何响应元信息
+func (m ResponseMeta) IsZero() bool {
+	return m == ResponseMeta{}
+}
+
+// applyTo 将响应元信息填充到请求详情
+func (m ResponseMeta) applyTo(detail *RequestDetail) {
+	detail.StopReason = m.StopReason
+	detail.ResponseMessageID = m.MessageID
+	detail.ToolUseCount = m.ToolUseCount
+	detail.HasThinking = m.HasThinking
+}
+
+// responseMetaPayload 响应载荷中与元信息相关的字段
+// 同时覆盖 SSE 事件（message_start / content_block_start / message_delta）与非流式 JSON 消息
+type responseMetaPayload struct {
+	Type       string `json:"type"`
+	ID         string `json:"id"`
+	StopReason string `json:"stop_reason"`
+	Content    []struct {
+		Type string `json:"type"`
+	} `json:"content"`
+	Message *struct {
+		ID string `json:"id"`
+	} `json:"message"`
+	ContentBlock *struct {
+		Type string `json:"type"`
+	} `json:"content_block"`
+	Delta *struct {
+		StopReason string `json:"stop_reason"`
+	} `json:"delta"`
+}
+
+// Observe 从单个响应载荷中累积元信息（无法解析的载荷直接忽略）
+func (m *ResponseMeta) Observe(payload []byte) {
+	var p responseMetaPayload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return
+	}
+
+	switch p.Type {
+	case "message":
+		// 非流式完整消息
+		if p.ID != "" {
+			m.MessageID = p.ID
+		}
+		if p.StopReason != "" {
+			m.StopReason = p.StopReason
+		}
+		for _, block := range p.Content {
+			m.observeBlock(block.Type)
+		}
+	case "message_start":
+		if p.Message != nil && p.Message.ID != "" {
+			m.MessageID = p.Message.ID
+		}
+	case "content_block_start":
+		if p.ContentBlock != nil {
+			m.observeBlock(p.ContentBlock.Type)
+		}
+	case "message_delta":
+		if p.Delta != nil && p.Delta.StopReason != "" {
+			m.StopReason = p.Delta.StopReason
+		}
+	}
+}
+
+// observeBlock 统计内容块类型
+func (m *ResponseMeta) observeBlock(blockType string) {
+	switch blockType {
+	case "tool_use":
+		m.ToolUseCount++
+	case "thinking", "redacted_thinking":
+		m.HasThinking = true
+	}
+}
+
+// responseMetaFilterSQL 构建停止原因、工具调用、thinking 与请求大小的筛选条件
+func responseMetaFilterSQL(opts *QueryOptions) (string, []interface{}) {
+	var clause string
+	var args []interface{}
+	if opts.StopReason != "" {
+		clause += " AND stop_reason = ?"
+		args = append(args, opts.StopReason)
+	}
+	if opts.HasToolUse != nil {
+		if *opts.HasToolUse {
+			clause += " AND COALESCE(tool_use_count, 0) > 0"
+		} else {
+			clause += " AND COALESCE(tool_use_count, 0) = 0"
+		}
+	}
+	if opts.HasThinking != nil {
+		clause += " AND COALESCE(has_thinking, 0) = ?"
+		args = append(args, *opts.HasThinking)
+	}
+	if opts.MinRequestBytes > 0 {
+		clause += " AND request_bytes >= ?"
+		args = append(args, opts.MinRequestBytes)
+	}
+	return clause, args
+}
+
+// matchesResponseMeta 热池请求是否满足响应元信息与请求大小筛选条件
+func (opts *QueryOptions) matchesResponseMeta(req *ActiveRequest) bool {
+	if opts.StopReason != "" && req.ResponseMeta.StopReason != opts.StopReason {
+		return false
+	}
+	if opts.HasToolUse != nil && (req.ResponseMeta.ToolUseCount > 0) != *opts.HasToolUse {
+		return false
+	}
+	if opts.HasThinking != nil && req.ResponseMeta.HasThinking != *opts.HasThinking {
+		return false
+	}
+	if opts.MinRequestBytes > 0 && req.RequestParams.RequestBytes < opts.MinRequestBytes {
+		return false
+	}
+	return true
+}
+
+// nullableTemperature nil 表示请求未指定温度，写入 NULL
+func nullableTemperature(v *float64) interface{} {
+	if v == nil {
+		return nil
+	}
+	return *v
+}
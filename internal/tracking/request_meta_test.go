@@ -0,0 +1,96 @@
+package tracking
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestResponseMetaObserve(t *testing.T) {
+	var meta ResponseMeta
+	meta.Observe([]byte(`{"id":"msg_01XYZ","type":"message","role":"assistant","stop_reason":"max_tokens",
+		"content":[{"type":"redacted_thinking","data":"..."},{"type":"tool_use","id":"toolu_1"},{"type":"tool_use","id":"toolu_2"},{"type":"text","text":"hi"}]}`))
+	want := ResponseMeta{StopReason: "max_tokens", MessageID: "msg_01XYZ", ToolUseCount: 2, HasThinking: true}
+	if meta != want {
+		t.Errorf("非流式响应元信息不符: want %+v, got %+v", want, meta)
+	}
+
+	// 无法解析或无关的载荷不影响已有结果
+	meta.Observe([]byte(`not json`))
+	meta.Observe([]byte(`{"type":"content_block_delta","delta":{"type":"text_delta","text":"x"}}`))
+	if meta != want {
+		t.Errorf("无关载荷不应修改元信息: %+v", meta)
+	}
+}
+
+func TestRequestMetaPersistenceAndFilters(t *testing.T) {
+	config := &Config{
+		Enabled:         true,
+		DatabasePath:    ":memory:",
+		BufferSize:      100,
+		BatchSize:       10,
+		FlushInterval:   50 * time.Millisecond,
+		MaxRetry:        3,
+		CleanupInterval: 24 * time.Hour,
+		RetentionDays:   30,
+	}
+	tracker, err := NewUsageTracker(config)
+	if err != nil {
+		t.Fatalf("Failed to create usage tracker: %v", err)
+	}
+	defer tracker.Close()
+
+	ctx := context.Background()
+	now := tracker.now()
+	temperature := 0.7
+	events := []*ArchiveEvent{
+		{Request: &ActiveRequest{
+			RequestID: "req-truncated", StartTime: now, EndpointName: "ep-a", Status: "completed",
+			RequestParams: RequestParams{MaxTokens: 1024, MessageCount: 12, ToolCount: 3, ThinkingBudget: 512, Temperature: &temperature, RequestBytes: 50000},
+			ResponseMeta:  ResponseMeta{StopReason: "max_tokens", MessageID: "msg_1", HasThinking: true},
+		}},
+		{Request: &ActiveRequest{
+			RequestID: "req-tool", StartTime: now.Add(-time.Minute), EndpointName: "ep-a", Status: "completed",
+			RequestParams: RequestParams{MaxTokens: 8192, MessageCount: 2, RequestBytes: 800},
+			ResponseMeta:  ResponseMeta{StopReason: "tool_use", MessageID: "msg_2", ToolUseCount: 2},
+		}},
+	}
+	if err := tracker.archiveManager.batchInsert(events); err != nil {
+		t.Fatalf("归档失败: %v", err)
+	}
+
+	details, err := tracker.QueryRequestDetails(ctx, &QueryOptions{StopReason: "max_tokens"})
+	if err != nil {
+		t.Fatalf("按停止原因查询失败: %v", err)
+	}
+	if len(details) != 1 {
+		t.Fatalf("max_tokens 应只有 1 条, got %d", len(details))
+	}
+	d := details[0]
+	if d.RequestID != "req-truncated" || d.ResponseMessageID != "msg_1" || !d.HasThinking || d.ToolUseCount != 0 {
+		t.Errorf("响应元信息不符: %+v", d)
+	}
+	if d.MaxTokens != 1024 || d.MessageCount != 12 || d.ToolCount != 3 || d.ThinkingBudget != 512 || d.RequestBytes != 50000 {
+		t.Errorf("请求参数不符: %+v", d)
+	}
+	if d.Temperature == nil || *d.Temperature != 0.7 {
+		t.Errorf("温度应为 0.7, got %v", d.Temperature)
+	}
+
+	hasToolUse := true
+	count, err := tracker.CountRequestDetails(ctx, &QueryOptions{HasToolUse: &hasToolUse})
+	if err != nil || count != 1 {
+		t.Errorf("包含工具调用的请求应为 1 条, got %d (err=%v)", count, err)
+	}
+	noThinking := false
+	count, err = tracker.CountRequestDetails(ctx, &QueryOptions{HasThinking: &noThinking, MinRequestBytes: 500})
+	if err != nil || count != 1 {
+		t.Errorf("无 thinking 且请求大于 500 字节的请求应为 1 条, got %d (err=%v)", count, err)
+	}
+
+	// 未指定温度的请求写入 NULL
+	details, err = tracker.QueryRequestDetails(ctx, &QueryOptions{StopReason: "tool_use"})
+	if err != nil || len(details) != 1 || details[0].Temperature != nil {
+		t.Errorf("未指定温度应为 nil: %+v (err=%v)", details, err)
+	}
+}
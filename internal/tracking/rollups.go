@@ -0,0 +1,738 @@
+package tracking
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"math"
+	"strings"
+	"time"
+)
+
+// 用量汇总表（usage_rollup_hourly / usage_rollup_daily）
+// - 归档批次写入 request_logs 时在同一事务内增量累加
+// - 成本重算、保留期清理、批处理用量及热池降级的事件队列写入后按范围从 request_logs 重建
+// - 统计查询按时间范围拆分：整日走日表、整小时走小时表、零碎边界仍查 request_logs
+
+const (
+	rollupHourLayout = "2006-01-02 15:00:00"
+	rollupDayLayout  = "2006-01-02"
+
+	// rollupMinRange 短于该范围的统计直接查询 request_logs（汇总表收益有限）
+	rollupMinRange = 24 * time.Hour
+)
+
+// 用量趋势粒度
+const (
+	UsageTrendHour = "hour"
+	UsageTrendDay  = "day"
+)
+
+// UsageTrendPoint 用量趋势中的一个时间桶
+type UsageTrendPoint struct {
+	Bucket          string  `json:"bucket"` // hour: YYYY-MM-DD HH:00:00，day: YYYY-MM-DD（配置时区）
+	RequestCount    int64   `json:"request_count"`
+	SuccessRequests int64   `json:"success_requests"`
+	FailedRequests  int64   `json:"failed_requests"`
+	TotalCostUSD    float64 `json:"total_cost_usd"`
+
+	InputTokens         int64 `json:"input_tokens"`
+	OutputTokens        int64 `json:"output_tokens"`
+	CacheCreationTokens int64 `json:"cache_creation_tokens"`
+	CacheReadTokens     int64 `json:"cache_read_tokens"`
+	TotalTokens         int64 `json:"total_tokens"`
+}
+
+// rollupKey 汇总维度
+type rollupKey struct {
+	bucket   string // 小时桶
+	model    string
+	endpoint string
+	channel  string
+	status   string
+}
+
+// rollupDelta 一个维度组合的增量
+type rollupDelta struct {
+	requests      int64
+	input         int64
+	output        int64
+	cacheCreation int64
+	cacheRead     int64
+	cost          float64
+	durationSum   int64
+	durationCount int64
+}
+
+// usageRollupDeltas 归档批次内按维度累积的增量
+type usageRollupDeltas map[rollupKey]*rollupDelta
+
+// add 累加一条归档请求（startTime 为已按配置时区格式化的开始时间）
+func (d usageRollupDeltas) add(startTime string, req *ActiveRequest, cost float64) {
+	d.accumulate(startTime, req, cost, 1)
+}
+
+// remove 扣减一条已计入汇总的请求（请求用量被更新时先扣减旧值，再累加新值）
+func (d usageRollupDeltas) remove(startTime string, req *ActiveRequest, cost float64) {
+	d.accumulate(startTime, req, cost, -1)
+}
+
+// accumulate 按 sign（1 累加 / -1 扣减）记录一条请求的增量
+func (d usageRollupDeltas) accumulate(startTime string, req *ActiveRequest, cost float64, sign int64) {
+	if len(startTime) < 13 {
+		return
+	}
+	key := rollupKey{
+		bucket:   startTime[:13] + ":00:00",
+		model:    req.ModelName,
+		endpoint: req.EndpointName,
+		channel:  req.Channel,
+		status:   req.Status,
+	}
+	delta, ok := d[key]
+	if !ok {
+		delta = &rollupDelta{}
+		d[key] = delta
+	}
+	delta.requests += sign
+	delta.input += sign * req.InputTokens
+	delta.output += sign * req.OutputTokens
+	delta.cacheCreation += sign * req.CacheCreationTokens
+	delta.cacheRead += sign * req.CacheReadTokens
+	delta.cost += float64(sign) * cost
+	if req.DurationMs > 0 {
+		delta.durationSum += sign * req.DurationMs
+		delta.durationCount += sign
+	}
+}
+
+// rollupUpsertSQL 累加写入汇总表的 SQL
+func rollupUpsertSQL(table string) string {
+	return `INSERT INTO ` + table + ` (
+		bucket, model_name, endpoint_name, channel, status,
+		request_count, input_tokens, output_tokens, cache_creation_tokens, cache_read_tokens,
+		total_cost_usd, duration_sum_ms, duration_count
+	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	ON CONFLICT(bucket, model_name, endpoint_name, channel, status) DO UPDATE SET
+		request_count = request_count + excluded.request_count,
+		input_tokens = input_tokens + excluded.input_tokens,
+		output_tokens = output_tokens + excluded.output_tokens,
+		cache_creation_tokens = cache_creation_tokens + excluded.cache_creation_tokens,
+		cache_read_tokens = cache_read_tokens + excluded.cache_read_tokens,
+		total_cost_usd = total_cost_usd + excluded.total_cost_usd,
+		duration_sum_ms = duration_sum_ms + excluded.duration_sum_ms,
+		duration_count = duration_count + excluded.duration_count`
+}
+
+// applyUsageRollupDeltasTx 在归档事务内把增量累加到小时表和日表
+func applyUsageRollupDeltasTx(ctx context.Context, tx *sql.Tx, deltas usageRollupDeltas) error {
+	if len(deltas) == 0 {
+		return nil
+	}
+
+	daily := make(usageRollupDeltas, len(deltas))
+	for key, delta := range deltas {
+		dayKey := key
+		dayKey.bucket = key.bucket[:10]
+		d, ok := daily[dayKey]
+		if !ok {
+			d = &rollupDelta{}
+			daily[dayKey] = d
+		}
+		d.requests += delta.requests
+		d.input += delta.input
+		d.output += delta.output
+		d.cacheCreation += delta.cacheCreation
+		d.cacheRead += delta.cacheRead
+		d.cost += delta.cost
+		d.durationSum += delta.durationSum
+		d.durationCount += delta.durationCount
+	}
+
+	for table, set := range map[string]usageRollupDeltas{"usage_rollup_hourly": deltas, "usage_rollup_daily": daily} {
+		stmt, err := tx.PrepareContext(ctx, rollupUpsertSQL(table))
+		if err != nil {
+			return fmt.Errorf("failed to prepare rollup statement: %w", err)
+		}
+		for key, d := range set {
+			if _, err := stmt.ExecContext(ctx,
+				key.bucket, key.model, key.endpoint, key.channel, key.status,
+				d.requests, d.input, d.output, d.cacheCreation, d.cacheRead,
+				d.cost, d.durationSum, d.durationCount,
+			); err != nil {
+				stmt.Close()
+				return fmt.Errorf("failed to update %s: %w", table, err)
+			}
+		}
+		stmt.Close()
+	}
+	return nil
+}
+
+// RebuildUsageRollups 按 request_logs 重建 [start, end) 范围内的小时/日汇总
+// 范围向外对齐到整天；start/end 为零值表示不限（两者都为零即全量重建）
+func (ut *UsageTracker) RebuildUsageRollups(ctx context.Context, start, end time.Time) error {
+	if ut.writeDB == nil {
+		return fmt.Errorf("database not initialized")
+	}
+
+	ut.writeMu.Lock()
+	defer ut.writeMu.Unlock()
+
+	tx, err := ut.writeDB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := ut.rebuildUsageRollupsTx(ctx, tx, start, end); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// rebuildUsageRollupsTx 在事务内重建汇总（先按整天删除，再从 request_logs 聚合小时表，由小时表聚合日表）
+func (ut *UsageTracker) rebuildUsageRollupsTx(ctx context.Context, tx *sql.Tx, start, end time.Time) error {
+	var fromDay, toDay string
+	if !start.IsZero() {
+		fromDay = ut.rollupDayStart(start).Format(rollupDayLayout)
+	}
+	if !end.IsZero() {
+		toDay = ut.rollupDayCeil(end).Format(rollupDayLayout)
+	}
+	if fromDay != "" && toDay != "" && fromDay >= toDay {
+		return nil
+	}
+
+	// 日桶字符串（YYYY-MM-DD）按字典序同样可以作为小时桶与 start_time 的边界
+	rangeClause := func(column string) (string, []interface{}) {
+		clause := " WHERE 1=1"
+		var args []interface{}
+		if fromDay != "" {
+			clause += " AND " + column + " >= ?"
+			args = append(args, fromDay)
+		}
+		if toDay != "" {
+			clause += " AND " + column + " < ?"
+			args = append(args, toDay)
+		}
+		return clause, args
+	}
+
+	for _, table := range []string{"usage_rollup_hourly", "usage_rollup_daily"} {
+		clause, args := rangeClause("bucket")
+		if _, err := tx.ExecContext(ctx, "DELETE FROM "+table+clause, args...); err != nil {
+			return fmt.Errorf("failed to clear %s: %w", table, err)
+		}
+	}
+
+	clause, args := rangeClause("start_time")
+	if _, err := tx.ExecContext(ctx, `INSERT INTO usage_rollup_hourly (
+			bucket, model_name, endpoint_name, channel, status,
+			request_count, input_tokens, output_tokens, cache_creation_tokens, cache_read_tokens,
+			total_cost_usd, duration_sum_ms, duration_count
+		) SELECT
+			substr(start_time, 1, 13) || ':00:00',
+			COALESCE(model_name, ''), COALESCE(endpoint_name, ''), COALESCE(channel, ''), COALESCE(status, ''),
+			COUNT(*),
+			COALESCE(SUM(input_tokens), 0), COALESCE(SUM(output_tokens), 0),
+			COALESCE(SUM(cache_creation_tokens), 0), COALESCE(SUM(cache_read_tokens), 0),
+			COALESCE(SUM(total_cost_usd), 0),
+			COALESCE(SUM(CASE WHEN duration_ms > 0 THEN duration_ms ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN duration_ms > 0 THEN 1 ELSE 0 END), 0)
+		FROM request_logs`+clause+`
+		GROUP BY 1, 2, 3, 4, 5`, args...); err != nil {
+		return fmt.Errorf("failed to rebuild hourly rollups: %w", err)
+	}
+
+	clause, args = rangeClause("bucket")
+	if _, err := tx.ExecContext(ctx, `INSERT INTO usage_rollup_daily (
+			bucket, model_name, endpoint_name, channel, status,
+			request_count, input_tokens, output_tokens, cache_creation_tokens, cache_read_tokens,
+			total_cost_usd, duration_sum_ms, duration_count
+		) SELECT
+			substr(bucket, 1, 10), model_name, endpoint_name, channel, status,
+			SUM(request_count), SUM(input_tokens), SUM(output_tokens),
+			SUM(cache_creation_tokens), SUM(cache_read_tokens),
+			SUM(total_cost_usd), SUM(duration_sum_ms), SUM(duration_count)
+		FROM usage_rollup_hourly`+clause+`
+		GROUP BY 1, 2, 3, 4, 5`, args...); err != nil {
+		return fmt.Errorf("failed to rebuild daily rollups: %w", err)
+	}
+	return nil
+}
+
+// legacyEventAffectsRollups 事件队列写入是否改变汇总维度或用量（热池模式下事件队列写入不经过归档增量）
+func legacyEventAffectsRollups(eventType string) bool {
+	switch eventType {
+	case "start", "update", "flexible_update", "success", "final_failure", "complete":
+		return true
+	}
+	return false
+}
+
+// rebuildRollupsForRequests 热池降级到事件队列写入后，按这些请求的开始日期重建汇总
+// 重建失败时停止使用汇总表，统计查询回退到明细表（下次启动校验后恢复）
+func (ut *UsageTracker) rebuildRollupsForRequests(ctx context.Context, requestIDs []string) {
+	if ut.readDB == nil || len(requestIDs) == 0 {
+		return
+	}
+
+	placeholders := make([]string, len(requestIDs))
+	args := make([]interface{}, len(requestIDs))
+	for i, id := range requestIDs {
+		placeholders[i] = "?"
+		args[i] = id
+	}
+	var minStart, maxStart sql.NullString
+	err := ut.readDB.QueryRowContext(ctx, `SELECT MIN(start_time), MAX(start_time) FROM request_logs WHERE request_id IN (`+
+		strings.Join(placeholders, ", ")+`)`, args...).Scan(&minStart, &maxStart)
+	if err == nil && (!minStart.Valid || !maxStart.Valid || len(minStart.String) < 10 || len(maxStart.String) < 10) {
+		return
+	}
+
+	var from, to time.Time
+	if err == nil {
+		from, err = time.ParseInLocation(rollupDayLayout, minStart.String[:10], ut.rollupLocation())
+	}
+	if err == nil {
+		to, err = time.ParseInLocation(rollupDayLayout, maxStart.String[:10], ut.rollupLocation())
+	}
+	if err == nil {
+		err = ut.RebuildUsageRollups(ctx, from, to.AddDate(0, 0, 1))
+	}
+	if err != nil {
+		ut.rollupsReady.Store(false)
+		slog.Warn("⚠️ [用量汇总] 事件队列写入后重建汇总失败，统计查询改用明细表", "error", err)
+	}
+}
+
+// pruneUsageRollups 保留期清理后删除过期汇总，并重建截止日（该日只删除了部分请求）
+func (ut *UsageTracker) pruneUsageRollups(ctx context.Context, cutoff time.Time) error {
+	dayStart := ut.rollupDayStart(cutoff)
+	return ut.RebuildUsageRollups(ctx, time.Time{}, dayStart.AddDate(0, 0, 1))
+}
+
+// initUsageRollups 启动时校验汇总表与 request_logs 是否一致（不一致则全量重建），通过后查询才使用汇总表
+// 旧版事件队列写入路径不增量维护汇总表，热池降级写入后按日期重建
+func (ut *UsageTracker) initUsageRollups() {
+	defer ut.wg.Done()
+
+	ctx, cancel := context.WithTimeout(ut.ctx, 10*time.Minute)
+	defer cancel()
+
+	rebuilt, err := ut.ensureUsageRollups(ctx)
+	if err != nil {
+		slog.Warn("⚠️ [用量汇总] 校验汇总表失败，统计查询继续使用明细表", "error", err)
+		return
+	}
+	ut.rollupsReady.Store(true)
+	if rebuilt {
+		slog.Info("📊 [用量汇总] 汇总表与明细不一致，已全量重建")
+	}
+}
+
+// ensureUsageRollups 在单个写事务内比对请求数与成本合计，不一致时全量重建
+func (ut *UsageTracker) ensureUsageRollups(ctx context.Context) (bool, error) {
+	if ut.writeDB == nil {
+		return false, fmt.Errorf("database not initialized")
+	}
+
+	ut.writeMu.Lock()
+	defer ut.writeMu.Unlock()
+
+	tx, err := ut.writeDB.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var rawCount int64
+	var rawCost float64
+	if err := tx.QueryRowContext(ctx, "SELECT COUNT(*), COALESCE(SUM(total_cost_usd), 0) FROM request_logs").Scan(&rawCount, &rawCost); err != nil {
+		return false, fmt.Errorf("failed to count request logs: %w", err)
+	}
+
+	consistent := true
+	for _, table := range []string{"usage_rollup_hourly", "usage_rollup_daily"} {
+		var count int64
+		var cost float64
+		if err := tx.QueryRowContext(ctx, "SELECT COALESCE(SUM(request_count), 0), COALESCE(SUM(total_cost_usd), 0) FROM "+table).Scan(&count, &cost); err != nil {
+			return false, fmt.Errorf("failed to sum %s: %w", table, err)
+		}
+		if count != rawCount || math.Abs(cost-rawCost) > 1e-6*math.Max(1, math.Abs(rawCost)) {
+			consistent = false
+		}
+	}
+	if consistent {
+		return false, nil
+	}
+
+	if err := ut.rebuildUsageRollupsTx(ctx, tx, time.Time{}, time.Time{}); err != nil {
+		return false, err
+	}
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return true, nil
+}
+
+// rollupEligible 查询条件是否可以由汇总表回答
+// 汇总表不含组、会话、标签维度，也不含停止原因、工具调用、thinking 与请求大小
+func (ut *UsageTracker) rollupEligible(opts *QueryOptions) bool {
+	if !ut.rollupsReady.Load() {
+		return false
+	}
+	if opts == nil {
+		return true
+	}
+	return opts.GroupName == "" && opts.SessionID == "" && len(opts.Tags) == 0 &&
+		opts.StopReason == "" && opts.HasToolUse == nil && opts.HasThinking == nil && opts.MinRequestBytes <= 0
+}
+
+// rollupSegment 统计查询的一个时间片段：source 为 raw / hourly / daily，from/to 为零值表示不限
+type rollupSegment struct {
+	source string
+	from   time.Time
+	to     time.Time
+}
+
+// planRollupSegments 将 [from, to) 拆分为：首尾不足一小时的部分查明细，不足一天的整小时查小时表，中间整天查日表
+func (ut *UsageTracker) planRollupSegments(from, to time.Time) []rollupSegment {
+	var segments []rollupSegment
+	appendSegment := func(source string, a, b time.Time) {
+		if !a.IsZero() && !b.IsZero() && !a.Before(b) {
+			return
+		}
+		segments = append(segments, rollupSegment{source: source, from: a, to: b})
+	}
+
+	hourFrom, hourTo := from, to
+	if !from.IsZero() {
+		hourFrom = ut.rollupHourCeil(from)
+	}
+	if !to.IsZero() {
+		hourTo = ut.rollupHourStart(to)
+	}
+	if !hourFrom.IsZero() && !hourTo.IsZero() && !hourFrom.Before(hourTo) {
+		return []rollupSegment{{source: "raw", from: from, to: to}}
+	}
+
+	dayFrom, dayTo := hourFrom, hourTo
+	if !hourFrom.IsZero() {
+		dayFrom = ut.rollupDayCeil(hourFrom)
+	}
+	if !hourTo.IsZero() {
+		dayTo = ut.rollupDayStart(hourTo)
+	}
+
+	if !from.IsZero() {
+		appendSegment("raw", from, hourFrom)
+	}
+	if !dayFrom.IsZero() && !dayTo.IsZero() && !dayFrom.Before(dayTo) {
+		appendSegment("hourly", hourFrom, hourTo)
+	} else {
+		if !hourFrom.IsZero() {
+			appendSegment("hourly", hourFrom, dayFrom)
+		}
+		appendSegment("daily", dayFrom, dayTo)
+		if !hourTo.IsZero() {
+			appendSegment("hourly", dayTo, hourTo)
+		}
+	}
+	if !to.IsZero() {
+		appendSegment("raw", hourTo, to)
+	}
+	return segments
+}
+
+// QueryUsageStatsTotals 查询聚合统计（数据库部分）。
+// 该方法只做聚合，不返回明细，适用于前端统计卡片/图表的快速刷新。
+// 范围不短于一天且筛选维度在汇总表内时，整日/整小时部分改查汇总表。
+func (ut *UsageTracker) QueryUsageStatsTotals(ctx context.Context, opts *QueryOptions) (*UsageStatsTotals, error) {
+	if ut.readDB == nil {
+		return nil, fmt.Errorf("read database not initialized")
+	}
+	if !ut.rollupEligible(opts) {
+		return ut.queryRawUsageStatsTotals(ctx, opts)
+	}
+
+	var from, to time.Time
+	if opts != nil && opts.StartDate != nil {
+		from = opts.StartDate.In(ut.rollupLocation())
+	}
+	if opts != nil && opts.EndDate != nil {
+		// EndDate 为闭区间，转为半开区间
+		to = opts.EndDate.In(ut.rollupLocation()).Add(time.Nanosecond)
+	}
+	if !from.IsZero() && !to.IsZero() && to.Sub(from) < rollupMinRange {
+		return ut.queryRawUsageStatsTotals(ctx, opts)
+	}
+
+	var base QueryOptions
+	if opts != nil {
+		base = *opts
+	}
+
+	var totals UsageStatsTotals
+	for _, seg := range ut.planRollupSegments(from, to) {
+		var part *UsageStatsTotals
+		var err error
+		if seg.source == "raw" {
+			segOpts := base
+			segOpts.StartDate, segOpts.EndDate = nil, nil
+			if !seg.from.IsZero() {
+				f := seg.from
+				segOpts.StartDate = &f
+			}
+			if !seg.to.IsZero() {
+				t := seg.to.Add(-time.Nanosecond)
+				segOpts.EndDate = &t
+			}
+			part, err = ut.queryRawUsageStatsTotals(ctx, &segOpts)
+		} else {
+			part, err = ut.queryRollupUsageStatsTotals(ctx, seg, &base)
+		}
+		if err != nil {
+			return nil, err
+		}
+		totals.add(*part)
+	}
+	return &totals, nil
+}
+
+// queryRollupUsageStatsTotals 从小时表/日表查询一个片段的聚合统计
+func (ut *UsageTracker) queryRollupUsageStatsTotals(ctx context.Context, seg rollupSegment, opts *QueryOptions) (*UsageStatsTotals, error) {
+	table, layout := "usage_rollup_hourly", rollupHourLayout
+	if seg.source == "daily" {
+		table, layout = "usage_rollup_daily", rollupDayLayout
+	}
+
+	query := `SELECT
+		COALESCE(SUM(request_count), 0),
+		COALESCE(SUM(CASE WHEN status IN ('completed', 'processing') THEN request_count ELSE 0 END), 0),
+		COALESCE(SUM(CASE WHEN status IN ('failed', 'error', 'auth_error', 'rate_limited', 'server_error', 'network_error', 'stream_error', 'timeout') THEN request_count ELSE 0 END), 0),
+		COALESCE(SUM(input_tokens + output_tokens + cache_creation_tokens + cache_read_tokens), 0),
+		COALESCE(SUM(input_tokens), 0),
+		COALESCE(SUM(output_tokens), 0),
+		COALESCE(SUM(total_cost_usd), 0.0),
+		COALESCE(SUM(duration_sum_ms), 0),
+		COALESCE(SUM(duration_count), 0)
+		FROM ` + table + ` WHERE 1=1`
+	var args []interface{}
+	if !seg.from.IsZero() {
+		query += " AND bucket >= ?"
+		args = append(args, seg.from.Format(layout))
+	}
+	if !seg.to.IsZero() {
+		query += " AND bucket < ?"
+		args = append(args, seg.to.Format(layout))
+	}
+	dimClause, dimArgs := rollupDimensionFilter(opts)
+	query += dimClause
+	args = append(args, dimArgs...)
+
+	var totals UsageStatsTotals
+	if err := ut.readDB.QueryRowContext(ctx, query, args...).Scan(
+		&totals.TotalRequests,
+		&totals.SuccessRequests,
+		&totals.FailedRequests,
+		&totals.TotalTokens,
+		&totals.InputTokens,
+		&totals.OutputTokens,
+		&totals.TotalCostUSD,
+		&totals.DurationSumMs,
+		&totals.DurationCount,
+	); err != nil {
+		return nil, fmt.Errorf("failed to query %s: %w", table, err)
+	}
+	return &totals, nil
+}
+
+// rollupDimensionFilter 汇总表可用的筛选条件（与 request_logs 查询的状态语义一致）
+func rollupDimensionFilter(opts *QueryOptions) (string, []interface{}) {
+	if opts == nil {
+		return "", nil
+	}
+	var clause string
+	var args []interface{}
+	if opts.ModelName != "" {
+		clause += " AND model_name = ?"
+		args = append(args, opts.ModelName)
+	}
+	if opts.Channel != "" {
+		clause += " AND channel = ?"
+		args = append(args, opts.Channel)
+	}
+	if opts.EndpointName != "" {
+		clause += " AND endpoint_name = ?"
+		args = append(args, opts.EndpointName)
+	}
+	if opts.Status != "" {
+		switch opts.Status {
+		case "failed":
+			clause += " AND status IN ('failed', 'error', 'auth_error', 'rate_limited', 'server_error', 'network_error', 'stream_error', 'timeout')"
+		default:
+			clause += " AND status = ?"
+			args = append(args, opts.Status)
+		}
+	}
+	return clause, args
+}
+
+// QueryUsageTrend 按小时或天统计用量趋势（起止时间向外对齐到整桶）
+// 汇总表可用时直接读取小时表/日表，否则从 request_logs 分组聚合
+func (ut *UsageTracker) QueryUsageTrend(ctx context.Context, opts *QueryOptions, granularity string) ([]UsageTrendPoint, error) {
+	if ut.readDB == nil {
+		return nil, fmt.Errorf("read database not initialized")
+	}
+
+	var table, layout, bucketExpr string
+	switch granularity {
+	case UsageTrendHour, "":
+		granularity = UsageTrendHour
+		table, layout, bucketExpr = "usage_rollup_hourly", rollupHourLayout, "substr(start_time, 1, 13) || ':00:00'"
+	case UsageTrendDay:
+		table, layout, bucketExpr = "usage_rollup_daily", rollupDayLayout, "substr(start_time, 1, 10)"
+	default:
+		return nil, fmt.Errorf("unsupported trend granularity: %s", granularity)
+	}
+
+	var from, to string
+	if opts != nil && opts.StartDate != nil {
+		if granularity == UsageTrendDay {
+			from = ut.rollupDayStart(*opts.StartDate).Format(layout)
+		} else {
+			from = ut.rollupHourStart(*opts.StartDate).Format(layout)
+		}
+	}
+	if opts != nil && opts.EndDate != nil {
+		if granularity == UsageTrendDay {
+			to = ut.rollupDayStart(*opts.EndDate).AddDate(0, 0, 1).Format(layout)
+		} else {
+			to = ut.rollupHourStart(*opts.EndDate).Add(time.Hour).Format(layout)
+		}
+	}
+
+	var query string
+	var args []interface{}
+	if ut.rollupEligible(opts) {
+		query = `SELECT bucket,
+			COALESCE(SUM(request_count), 0),
+			COALESCE(SUM(CASE WHEN status IN ('completed', 'processing') THEN request_count ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN status IN ('failed', 'error', 'auth_error', 'rate_limited', 'server_error', 'network_error', 'stream_error', 'timeout') THEN request_count ELSE 0 END), 0),
+			COALESCE(SUM(total_cost_usd), 0.0),
+			COALESCE(SUM(input_tokens), 0), COALESCE(SUM(output_tokens), 0),
+			COALESCE(SUM(cache_creation_tokens), 0), COALESCE(SUM(cache_read_tokens), 0)
+			FROM ` + table + ` WHERE 1=1`
+		if from != "" {
+			query += " AND bucket >= ?"
+			args = append(args, from)
+		}
+		if to != "" {
+			query += " AND bucket < ?"
+			args = append(args, to)
+		}
+		dimClause, dimArgs := rollupDimensionFilter(opts)
+		query += dimClause
+		args = append(args, dimArgs...)
+		query += " GROUP BY bucket ORDER BY bucket"
+	} else {
+		query = `SELECT ` + bucketExpr + ` AS bucket,
+			COUNT(*),
+			COALESCE(SUM(CASE WHEN status IN ('completed', 'processing') THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN status IN ('failed', 'error', 'auth_error', 'rate_limited', 'server_error', 'network_error', 'stream_error', 'timeout') THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(total_cost_usd), 0.0),
+			COALESCE(SUM(input_tokens), 0), COALESCE(SUM(output_tokens), 0),
+			COALESCE(SUM(cache_creation_tokens), 0), COALESCE(SUM(cache_read_tokens), 0)
+			FROM request_logs WHERE 1=1`
+		if from != "" {
+			query += " AND start_time >= ?"
+			args = append(args, from)
+		}
+		if to != "" {
+			query += " AND start_time < ?"
+			args = append(args, to)
+		}
+		if opts != nil {
+			dimClause, dimArgs := rollupDimensionFilter(opts)
+			query += dimClause
+			args = append(args, dimArgs...)
+			if opts.GroupName != "" {
+				query += " AND group_name = ?"
+				args = append(args, opts.GroupName)
+			}
+			if opts.SessionID != "" {
+				query += " AND session_id = ?"
+				args = append(args, opts.SessionID)
+			}
+			tagClause, tagArgs := tagFilterSQL(opts.Tags)
+			query += tagClause
+			args = append(args, tagArgs...)
+			metaClause, metaArgs := responseMetaFilterSQL(opts)
+			query += metaClause
+			args = append(args, metaArgs...)
+		}
+		query += " GROUP BY bucket ORDER BY bucket"
+	}
+
+	rows, err := ut.readDB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query usage trend: %w", err)
+	}
+	defer rows.Close()
+
+	var points []UsageTrendPoint
+	for rows.Next() {
+		var p UsageTrendPoint
+		if err := rows.Scan(&p.Bucket, &p.RequestCount, &p.SuccessRequests, &p.FailedRequests, &p.TotalCostUSD,
+			&p.InputTokens, &p.OutputTokens, &p.CacheCreationTokens, &p.CacheReadTokens); err != nil {
+			return nil, fmt.Errorf("failed to scan usage trend: %w", err)
+		}
+		p.TotalTokens = p.InputTokens + p.OutputTokens + p.CacheCreationTokens + p.CacheReadTokens
+		points = append(points, p)
+	}
+	return points, rows.Err()
+}
+
+// rollupLocation 汇总桶所在时区（与 start_time 写入时区一致）
+func (ut *UsageTracker) rollupLocation() *time.Location {
+	if ut.location != nil {
+		return ut.location
+	}
+	return time.Local
+}
+
+// rollupHourStart 所在小时的起点（配置时区）
+func (ut *UsageTracker) rollupHourStart(t time.Time) time.Time {
+	t = t.In(ut.rollupLocation())
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, t.Location())
+}
+
+// rollupHourCeil 不早于 t 的最近整点
+func (ut *UsageTracker) rollupHourCeil(t time.Time) time.Time {
+	start := ut.rollupHourStart(t)
+	if start.Equal(t) {
+		return start
+	}
+	return start.Add(time.Hour)
+}
+
+// rollupDayStart 所在日期的零点（配置时区）
+func (ut *UsageTracker) rollupDayStart(t time.Time) time.Time {
+	t = t.In(ut.rollupLocation())
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// rollupDayCeil 不早于 t 的最近零点
+func (ut *UsageTracker) rollupDayCeil(t time.Time) time.Time {
+	start := ut.rollupDayStart(t)
+	if start.Equal(t) {
+		return start
+	}
+	return start.AddDate(0, 0, 1)
+}
@@ -0,0 +1,309 @@
+package tracking
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+)
+
+// waitRollupsReady 等待启动校验完成
+func waitRollupsReady(t *testing.T, tracker *UsageTracker) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !tracker.rollupsReady.Load() {
+		if time.Now().After(deadline) {
+			t.Fatal("用量汇总表未就绪")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// assertTotalsEqual 比对汇总路由结果与明细查询结果
+func assertTotalsEqual(t *testing.T, name string, got, want *UsageStatsTotals) {
+	t.Helper()
+	if got.TotalRequests != want.TotalRequests || got.SuccessRequests != want.SuccessRequests ||
+		got.FailedRequests != want.FailedRequests || got.TotalTokens != want.TotalTokens ||
+		got.InputTokens != want.InputTokens || got.OutputTokens != want.OutputTokens ||
+		got.DurationSumMs != want.DurationSumMs || got.DurationCount != want.DurationCount ||
+		math.Abs(got.TotalCostUSD-want.TotalCostUSD) > 1e-9 {
+		t.Errorf("%s: 汇总结果 %+v 与明细结果 %+v 不一致", name, *got, *want)
+	}
+}
+
+// TestUsageRollups 测试归档增量汇总、按范围路由查询、重建与启动校验
+func TestUsageRollups(t *testing.T) {
+	config := &Config{
+		Enabled:         true,
+		DatabasePath:    ":memory:",
+		BufferSize:      100,
+		BatchSize:       10,
+		FlushInterval:   50 * time.Millisecond,
+		MaxRetry:        3,
+		CleanupInterval: 24 * time.Hour,
+		RetentionDays:   30,
+		DefaultPricing: ModelPricing{
+			Input:  3,
+			Output: 15,
+		},
+	}
+	tracker, err := NewUsageTracker(config)
+	if err != nil {
+		t.Fatalf("Failed to create usage tracker: %v", err)
+	}
+	defer tracker.Close()
+	waitRollupsReady(t, tracker)
+
+	ctx := context.Background()
+	today := tracker.rollupDayStart(tracker.now())
+	var events []*ArchiveEvent
+	for i := 0; i < 40; i++ {
+		status := "completed"
+		if i%7 == 0 {
+			status = "rate_limited"
+		}
+		model := "claude-sonnet-4"
+		if i%3 == 0 {
+			model = "claude-opus-4"
+		}
+		// 分布在过去 4 天的不同小时与分钟
+		start := today.AddDate(0, 0, -(i % 4)).Add(time.Duration(i%24)*time.Hour + time.Duration(i*7%60)*time.Minute)
+		events = append(events, &ArchiveEvent{Request: &ActiveRequest{
+			RequestID:    fmt.Sprintf("req-rollup-%d", i),
+			StartTime:    start,
+			Channel:      "relay",
+			EndpointName: fmt.Sprintf("ep-%d", i%2),
+			ModelName:    model,
+			Status:       status,
+			InputTokens:  int64(1000 + i),
+			OutputTokens: int64(100 + i),
+			DurationMs:   int64(i * 10),
+		}})
+	}
+	if err := tracker.archiveManager.batchInsert(events); err != nil {
+		t.Fatalf("归档失败: %v", err)
+	}
+
+	var hourlyCount, dailyCount int64
+	if err := tracker.readDB.QueryRowContext(ctx, "SELECT SUM(request_count) FROM usage_rollup_hourly").Scan(&hourlyCount); err != nil {
+		t.Fatalf("查询小时汇总失败: %v", err)
+	}
+	if err := tracker.readDB.QueryRowContext(ctx, "SELECT SUM(request_count) FROM usage_rollup_daily").Scan(&dailyCount); err != nil {
+		t.Fatalf("查询日汇总失败: %v", err)
+	}
+	if hourlyCount != 40 || dailyCount != 40 {
+		t.Fatalf("汇总请求数不符: hourly=%d daily=%d", hourlyCount, dailyCount)
+	}
+
+	ptr := func(t time.Time) *time.Time { return &t }
+	cases := []struct {
+		name string
+		opts *QueryOptions
+	}{
+		{"全部", &QueryOptions{}},
+		{"跨天零碎边界", &QueryOptions{StartDate: ptr(today.AddDate(0, 0, -3).Add(5*time.Hour + 30*time.Minute)), EndDate: ptr(today.Add(17*time.Hour + 20*time.Minute))}},
+		{"整天", &QueryOptions{StartDate: ptr(today.AddDate(0, 0, -2)), EndDate: ptr(today.Add(-time.Nanosecond))}},
+		{"按模型与失败状态", &QueryOptions{StartDate: ptr(today.AddDate(0, 0, -3)), EndDate: ptr(today.Add(23 * time.Hour)), ModelName: "claude-opus-4", Status: "failed"}},
+		{"短范围", &QueryOptions{StartDate: ptr(today.Add(2 * time.Hour)), EndDate: ptr(today.Add(20 * time.Hour))}},
+	}
+	for _, c := range cases {
+		got, err := tracker.QueryUsageStatsTotals(ctx, c.opts)
+		if err != nil {
+			t.Fatalf("%s: 查询失败: %v", c.name, err)
+		}
+		want, err := tracker.queryRawUsageStatsTotals(ctx, c.opts)
+		if err != nil {
+			t.Fatalf("%s: 明细查询失败: %v", c.name, err)
+		}
+		assertTotalsEqual(t, c.name, got, want)
+	}
+
+	// 成本重算后重建：直接修改明细成本，重建后应与明细一致
+	if _, err := tracker.writeDB.ExecContext(ctx, "UPDATE request_logs SET total_cost_usd = total_cost_usd * 2"); err != nil {
+		t.Fatalf("修改成本失败: %v", err)
+	}
+	if err := tracker.RebuildUsageRollups(ctx, today.AddDate(0, 0, -3), today.AddDate(0, 0, 1)); err != nil {
+		t.Fatalf("重建汇总失败: %v", err)
+	}
+	all := &QueryOptions{StartDate: ptr(today.AddDate(0, 0, -3)), EndDate: ptr(today.AddDate(0, 0, 1).Add(-time.Nanosecond))}
+	got, _ := tracker.QueryUsageStatsTotals(ctx, all)
+	want, _ := tracker.queryRawUsageStatsTotals(ctx, all)
+	assertTotalsEqual(t, "重建后", got, want)
+
+	// 趋势：汇总表与明细分组结果一致
+	trend, err := tracker.QueryUsageTrend(ctx, all, UsageTrendDay)
+	if err != nil {
+		t.Fatalf("查询趋势失败: %v", err)
+	}
+	tracker.rollupsReady.Store(false)
+	rawTrend, err := tracker.QueryUsageTrend(ctx, all, UsageTrendDay)
+	tracker.rollupsReady.Store(true)
+	if err != nil {
+		t.Fatalf("查询明细趋势失败: %v", err)
+	}
+	if len(trend) != 4 || len(rawTrend) != 4 {
+		t.Fatalf("日趋势应有 4 个桶: rollup=%d raw=%d", len(trend), len(rawTrend))
+	}
+	for i := range trend {
+		if trend[i].Bucket != rawTrend[i].Bucket || trend[i].RequestCount != rawTrend[i].RequestCount ||
+			trend[i].TotalTokens != rawTrend[i].TotalTokens || math.Abs(trend[i].TotalCostUSD-rawTrend[i].TotalCostUSD) > 1e-9 {
+			t.Errorf("趋势桶不一致: %+v vs %+v", trend[i], rawTrend[i])
+		}
+	}
+	if _, err := tracker.QueryUsageTrend(ctx, all, "week"); err == nil {
+		t.Error("不支持的粒度应返回错误")
+	}
+
+	// 响应元信息筛选：汇总表不含这些维度，应回退到明细查询
+	if _, err := tracker.writeDB.ExecContext(ctx, "UPDATE request_logs SET stop_reason = 'max_tokens', tool_use_count = 1 WHERE endpoint_name = 'ep-0'"); err != nil {
+		t.Fatalf("写入响应元信息失败: %v", err)
+	}
+	noToolUse := false
+	metaCases := []struct {
+		name string
+		opts *QueryOptions
+	}{
+		{"按停止原因", &QueryOptions{StartDate: all.StartDate, EndDate: all.EndDate, StopReason: "max_tokens"}},
+		{"无工具调用", &QueryOptions{StartDate: all.StartDate, EndDate: all.EndDate, HasToolUse: &noToolUse}},
+	}
+	for _, c := range metaCases {
+		if tracker.rollupEligible(c.opts) {
+			t.Errorf("%s: 不应由汇总表回答", c.name)
+		}
+		totals, err := tracker.QueryUsageStatsTotals(ctx, c.opts)
+		if err != nil {
+			t.Fatalf("%s: 查询失败: %v", c.name, err)
+		}
+		if totals.TotalRequests != 20 {
+			t.Errorf("%s: 请求数 = %d, want 20", c.name, totals.TotalRequests)
+		}
+		points, err := tracker.QueryUsageTrend(ctx, c.opts, UsageTrendDay)
+		if err != nil {
+			t.Fatalf("%s: 查询趋势失败: %v", c.name, err)
+		}
+		var trendCount int64
+		for _, p := range points {
+			trendCount += p.RequestCount
+		}
+		if trendCount != 20 {
+			t.Errorf("%s: 趋势请求数 = %d, want 20", c.name, trendCount)
+		}
+	}
+
+	// 启动校验：汇总表与明细不一致时全量重建
+	if _, err := tracker.writeDB.ExecContext(ctx, "DELETE FROM usage_rollup_daily"); err != nil {
+		t.Fatalf("清空日汇总失败: %v", err)
+	}
+	rebuilt, err := tracker.ensureUsageRollups(ctx)
+	if err != nil || !rebuilt {
+		t.Fatalf("应检测到不一致并重建: rebuilt=%v err=%v", rebuilt, err)
+	}
+	if rebuilt, err = tracker.ensureUsageRollups(ctx); err != nil || rebuilt {
+		t.Errorf("一致时不应重建: rebuilt=%v err=%v", rebuilt, err)
+	}
+}
+
+// TestPlanRollupSegments 测试统计范围拆分
+func TestPlanRollupSegments(t *testing.T) {
+	loc := time.FixedZone("CST", 8*3600)
+	ut := &UsageTracker{location: loc}
+	at := func(day, hour, minute int) time.Time { return time.Date(2026, 10, day, hour, minute, 0, 0, loc) }
+
+	segments := ut.planRollupSegments(at(10, 5, 30), at(13, 17, 20))
+	want := []rollupSegment{
+		{"raw", at(10, 5, 30), at(10, 6, 0)},
+		{"hourly", at(10, 6, 0), at(11, 0, 0)},
+		{"daily", at(11, 0, 0), at(13, 0, 0)},
+		{"hourly", at(13, 0, 0), at(13, 17, 0)},
+		{"raw", at(13, 17, 0), at(13, 17, 20)},
+	}
+	if len(segments) != len(want) {
+		t.Fatalf("片段数 = %d, want %d: %+v", len(segments), len(want), segments)
+	}
+	for i := range want {
+		if segments[i].source != want[i].source || !segments[i].from.Equal(want[i].from) || !segments[i].to.Equal(want[i].to) {
+			t.Errorf("片段 %d = %+v, want %+v", i, segments[i], want[i])
+		}
+	}
+
+	// 不足一小时的范围全部查明细
+	segments = ut.planRollupSegments(at(10, 5, 10), at(10, 5, 50))
+	if len(segments) != 1 || segments[0].source != "raw" {
+		t.Errorf("短范围应只查明细: %+v", segments)
+	}
+
+	// 不限开始时间：日表无下界
+	segments = ut.planRollupSegments(time.Time{}, at(13, 0, 0))
+	if len(segments) != 1 || segments[0].source != "daily" || !segments[0].from.IsZero() {
+		t.Errorf("不限开始时间应只查日表: %+v", segments)
+	}
+}
+
+// TestUsageRollups_HotPoolFallback 测试热池已满降级到事件队列写入后，汇总结果仍与明细一致
+func TestUsageRollups_HotPoolFallback(t *testing.T) {
+	tracker, err := NewUsageTracker(&Config{
+		Enabled:         true,
+		DatabasePath:    ":memory:",
+		BufferSize:      100,
+		BatchSize:       10,
+		FlushInterval:   50 * time.Millisecond,
+		MaxRetry:        3,
+		CleanupInterval: 24 * time.Hour,
+		RetentionDays:   30,
+		DefaultPricing:  ModelPricing{Input: 3, Output: 15},
+		HotPool: &HotPoolSettings{
+			Enabled:          true,
+			MaxAge:           time.Hour,
+			MaxSize:          1,
+			CleanupInterval:  time.Hour,
+			ArchiveOnCleanup: true,
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create usage tracker: %v", err)
+	}
+	defer tracker.Close()
+	waitRollupsReady(t, tracker)
+
+	// 第一个请求占满热池，后续请求降级到事件队列
+	tracker.RecordRequestStart("req-in-pool", "127.0.0.1", "test", "POST", "/v1/messages", false)
+	channel, endpointName := "relay", "ep-0"
+	for i := 0; i < 3; i++ {
+		requestID := fmt.Sprintf("req-fallback-%d", i)
+		tracker.RecordRequestStart(requestID, "127.0.0.1", "test", "POST", "/v1/messages", false)
+		tracker.RecordRequestUpdate(requestID, UpdateOptions{Channel: &channel, EndpointName: &endpointName})
+		tracker.RecordRequestSuccess(requestID, "claude-sonnet-4", &TokenUsage{InputTokens: 1000, OutputTokens: 100}, time.Second)
+	}
+
+	ctx := context.Background()
+	today := tracker.rollupDayStart(tracker.now())
+	all := &QueryOptions{StartDate: &today}
+	end := today.AddDate(0, 0, 1).Add(-time.Nanosecond)
+	all.EndDate = &end
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		got, err := tracker.QueryUsageStatsTotals(ctx, all)
+		if err != nil {
+			t.Fatalf("查询失败: %v", err)
+		}
+		want, err := tracker.queryRawUsageStatsTotals(ctx, all)
+		if err != nil {
+			t.Fatalf("明细查询失败: %v", err)
+		}
+		if want.SuccessRequests == 3 && want.InputTokens == 3000 {
+			if !tracker.rollupEligible(all) {
+				t.Fatal("降级写入后汇总表应保持可用")
+			}
+			if got.TotalRequests == want.TotalRequests && got.InputTokens == want.InputTokens &&
+				math.Abs(got.TotalCostUSD-want.TotalCostUSD) < 1e-9 {
+				return
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("汇总结果 %+v 与明细结果 %+v 不一致", *got, *want)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
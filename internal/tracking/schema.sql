@@ -44,6 +44,8 @@ CREATE TABLE IF NOT EXISTS request_logs (
     cache_creation_5m_tokens INTEGER DEFAULT 0, -- 5分钟缓存创建token数 (v5.0.1+)
     cache_creation_1h_tokens INTEGER DEFAULT 0, -- 1小时缓存创建token数 (v5.0.1+)
     cache_read_tokens INTEGER DEFAULT 0,   -- 缓存读取token数
+    web_search_requests INTEGER DEFAULT 0, -- 服务端 web search 调用次数
+    web_fetch_requests INTEGER DEFAULT 0,  -- 服务端 web fetch 调用次数
 
     -- 成本计算（包含缓存）
     input_cost_usd REAL DEFAULT 0,         -- 输入token成本
@@ -52,7 +54,43 @@ CREATE TABLE IF NOT EXISTS request_logs (
     cache_creation_5m_cost_usd REAL DEFAULT 0, -- 5分钟缓存创建成本 (v5.0.1+)
     cache_creation_1h_cost_usd REAL DEFAULT 0, -- 1小时缓存创建成本 (v5.0.1+)
     cache_read_cost_usd REAL DEFAULT 0,    -- 缓存读取成本
+    server_tool_cost_usd REAL DEFAULT 0,   -- 服务端工具成本（按次计费）
     total_cost_usd REAL DEFAULT 0,         -- 总成本
+    pricing_tier INTEGER DEFAULT 0,        -- 生效的长上下文定价档位阈值（0 表示基础价格）
+    is_batch INTEGER DEFAULT 0,            -- 是否为 Message Batches 结果用量（按批处理折扣计费）: 1=是, 0=否
+
+    -- 多币种成本（按请求时的汇率换算，NULL 表示写入时缺少汇率或旧数据）
+    billing_currency TEXT,                 -- 渠道结算币种
+    billing_cost REAL,                     -- 结算币种成本
+    reporting_currency TEXT,               -- 统一报表币种
+    reporting_cost REAL,                   -- 报表币种成本
+
+    -- 价格来源
+    price_source TEXT,                     -- model_pricing / default_pricing / price_book:<名称>
+    request_fee_usd REAL DEFAULT 0,        -- 价目表按次固定费用（已计入 total_cost_usd）
+
+    -- 会话归属
+    session_id TEXT,                       -- 客户端会话标识（Claude Code metadata.user_id 中的 session / 会话请求头）
+
+    -- 响应时间线（相对最后一次上游请求发出时刻，NULL 表示未测量）
+    ttfb_ms INTEGER,                       -- 上游响应头到达耗时
+    first_event_ms INTEGER,                -- 首个 SSE 事件到达耗时（仅流式）
+    first_token_ms INTEGER,                -- 首个 content_block_delta 到达耗时（仅流式）
+    output_tokens_per_sec REAL,            -- 输出速率（流式按首个内容增量至结束计算）
+
+    -- 响应元信息
+    stop_reason TEXT,                      -- 停止原因: end_turn/max_tokens/tool_use/stop_sequence/pause_turn/refusal
+    response_message_id TEXT,              -- 上游响应消息ID（msg_xxx）
+    tool_use_count INTEGER DEFAULT 0,      -- 响应中的 tool_use 内容块数量
+    has_thinking INTEGER DEFAULT 0,        -- 响应是否包含 thinking 内容块: 1=是, 0=否
+
+    -- 请求参数（来自请求体）
+    max_tokens INTEGER DEFAULT 0,          -- max_tokens
+    message_count INTEGER DEFAULT 0,       -- messages 数量
+    tool_count INTEGER DEFAULT 0,          -- tools 定义数量
+    thinking_budget INTEGER DEFAULT 0,     -- 扩展思考预算 budget_tokens
+    temperature REAL,                      -- 温度（NULL 表示未指定）
+    request_bytes INTEGER DEFAULT 0,       -- 请求体大小（字节）
     
     -- 审计字段（统一使用带时区格式，微秒精度）
     created_at DATETIME DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now', 'localtime') || '+08:00'),
@@ -68,6 +106,8 @@ CREATE INDEX IF NOT EXISTS idx_request_logs_channel ON request_logs(channel);
 CREATE INDEX IF NOT EXISTS idx_request_logs_endpoint ON request_logs(endpoint_name);
 CREATE INDEX IF NOT EXISTS idx_request_logs_group ON request_logs(group_name);
 CREATE INDEX IF NOT EXISTS idx_request_logs_failure_reason ON request_logs(failure_reason);
+CREATE INDEX IF NOT EXISTS idx_request_logs_session ON request_logs(session_id, start_time);
+CREATE INDEX IF NOT EXISTS idx_request_logs_stop_reason ON request_logs(stop_reason);
 
 -- 使用统计汇总表 (可选，用于快速查询)
 CREATE TABLE IF NOT EXISTS usage_summary (
@@ -144,6 +184,7 @@ CREATE TABLE IF NOT EXISTS endpoints (
 
     -- ========== 功能支持 ==========
     supports_count_tokens INTEGER DEFAULT 0,        -- 是否支持 count_tokens 端点
+    capabilities TEXT,                              -- 能力声明 JSON（如 {"thinking":true,"batches":false}），未声明的能力自动探测
 
     -- ========== 成本倍率 ==========
     cost_multiplier REAL DEFAULT 1.0,               -- 总成本倍率
@@ -190,6 +231,7 @@ CREATE TABLE IF NOT EXISTS channels (
     website TEXT,                                   -- 渠道官网（可选）
     priority INTEGER DEFAULT 1,                     -- 渠道优先级（数字越小越高，用于渠道间故障转移顺序）
     failover_enabled INTEGER DEFAULT 1,             -- 是否参与渠道间故障转移 (1=是, 0=否)
+    billing_currency TEXT DEFAULT 'USD',            -- 渠道结算币种（ISO 4217，如 USD / CNY）
 
     created_at DATETIME DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now', 'localtime') || '+08:00'),
     updated_at DATETIME DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now', 'localtime') || '+08:00')
@@ -222,6 +264,13 @@ CREATE TABLE IF NOT EXISTS model_pricing (
     cache_creation_price_1h REAL DEFAULT 6.0,       -- 1小时缓存创建价格 (通常为 input * 2.0)
     cache_read_price REAL DEFAULT 0.30,             -- 缓存读取价格 (通常为 input * 0.1)
 
+    -- ========== 服务端工具定价 (USD per 1K requests) ==========
+    web_search_price REAL DEFAULT 10.0,             -- web search 每千次价格
+    web_fetch_price REAL DEFAULT 0,                 -- web fetch 每千次价格（官方仅收取 token 费用）
+
+    -- ========== 长上下文分档定价 ==========
+    pricing_tiers TEXT,                             -- JSON 数组：[{threshold, input, output, cache_creation, cache_creation_1h, cache_read}]
+
     -- ========== 模型元信息 ==========
     display_name TEXT,                              -- 显示名称（用于前端展示）
     description TEXT,                               -- 模型描述
@@ -245,6 +294,29 @@ BEGIN
     UPDATE model_pricing SET updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now', 'localtime') || '+08:00' WHERE id = NEW.id;
 END;
 
+-- 模型定价历史版本（成本按请求开始时生效的版本计算，支持历史成本重算）
+CREATE TABLE IF NOT EXISTS model_pricing_history (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    model_name TEXT NOT NULL,                       -- 模型名称
+    input_price REAL NOT NULL DEFAULT 0,
+    output_price REAL NOT NULL DEFAULT 0,
+    cache_creation_price_5m REAL DEFAULT 0,
+    cache_creation_price_1h REAL DEFAULT 0,
+    cache_read_price REAL DEFAULT 0,
+    web_search_price REAL DEFAULT 0,
+    web_fetch_price REAL DEFAULT 0,
+    pricing_tiers TEXT,                             -- JSON 数组（同 model_pricing.pricing_tiers）
+    effective_from DATETIME NOT NULL,               -- 生效时间（此后的请求使用该版本，直到下一版本生效）
+    note TEXT,                                      -- 变更说明
+
+    created_at DATETIME DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now', 'localtime') || '+08:00'),
+
+    UNIQUE(model_name, effective_from)
+);
+
+-- 模型定价历史索引
+CREATE INDEX IF NOT EXISTS idx_model_pricing_history_model ON model_pricing_history(model_name, effective_from);
+
 -- ============================================================================
 -- 系统设置表 (v5.1.0 新增 - 2025-12-08)
 -- 将运行时可调配置从 config.yaml 迁移到 SQLite，支持动态管理和热更新
@@ -287,3 +359,367 @@ CREATE TRIGGER IF NOT EXISTS update_settings_timestamp
 BEGIN
     UPDATE settings SET updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now', 'localtime') || '+08:00' WHERE id = NEW.id;
 END;
+
+-- ============================================================================
+-- 端点模型表
+-- 记录各端点 /v1/models 发现的模型列表及手动覆盖（允许/禁止），用于按模型筛选端点
+-- ============================================================================
+CREATE TABLE IF NOT EXISTS endpoint_models (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+
+    -- ========== 端点标识 ==========
+    channel TEXT NOT NULL DEFAULT '',               -- 渠道名称（YAML 模式为空）
+    endpoint_name TEXT NOT NULL,                    -- 端点名称
+
+    -- ========== 模型信息 ==========
+    model_id TEXT NOT NULL,                         -- 模型 ID（如 claude-sonnet-4-5-20250929）
+    display_name TEXT,                              -- 显示名称（来自 /v1/models）
+    source TEXT NOT NULL DEFAULT 'discovered',      -- 来源: discovered(自动发现), manual(手动覆盖)
+    allowed INTEGER NOT NULL DEFAULT 1,             -- 手动覆盖: 1=允许, 0=禁止（discovered 恒为 1）
+
+    -- ========== 审计字段 ==========
+    discovered_at DATETIME,                         -- 最近一次发现时间
+    created_at DATETIME DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now', 'localtime') || '+08:00'),
+    updated_at DATETIME DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now', 'localtime') || '+08:00'),
+
+    UNIQUE(channel, endpoint_name, model_id, source)
+);
+
+-- 端点模型表索引
+CREATE INDEX IF NOT EXISTS idx_endpoint_models_endpoint ON endpoint_models(channel, endpoint_name);
+CREATE INDEX IF NOT EXISTS idx_endpoint_models_model_id ON endpoint_models(model_id);
+
+-- 端点模型表触发器：自动更新 updated_at
+CREATE TRIGGER IF NOT EXISTS update_endpoint_models_timestamp
+    AFTER UPDATE ON endpoint_models
+    FOR EACH ROW
+    WHEN NEW.updated_at = OLD.updated_at
+BEGIN
+    UPDATE endpoint_models SET updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now', 'localtime') || '+08:00' WHERE id = NEW.id;
+END;
+
+-- ============================================================================
+-- Message Batches 表
+-- 记录经代理创建的批处理任务及其所属端点，后续查询/结果/取消请求固定转发到该端点
+-- ============================================================================
+CREATE TABLE IF NOT EXISTS message_batches (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    batch_id TEXT UNIQUE NOT NULL,                  -- 上游批处理 ID（如 msgbatch_xxx）
+
+    -- ========== 端点标识 ==========
+    channel TEXT NOT NULL DEFAULT '',               -- 渠道名称（YAML 模式为空）
+    endpoint_name TEXT NOT NULL,                    -- 创建批处理的端点名称
+
+    -- ========== 批处理状态 ==========
+    processing_status TEXT NOT NULL DEFAULT 'in_progress', -- in_progress / canceling / ended
+    processing_count INTEGER DEFAULT 0,             -- request_counts.processing
+    succeeded_count INTEGER DEFAULT 0,              -- request_counts.succeeded
+    errored_count INTEGER DEFAULT 0,                -- request_counts.errored
+    canceled_count INTEGER DEFAULT 0,               -- request_counts.canceled
+    expired_count INTEGER DEFAULT 0,                -- request_counts.expired
+    expires_at DATETIME,                            -- 上游过期时间
+    ended_at DATETIME,                              -- 上游结束时间
+    tags TEXT,                                      -- 创建请求的标签（JSON 数组），结果用量写入 request_tags 时沿用
+
+    -- ========== 用量记录 ==========
+    usage_recorded INTEGER NOT NULL DEFAULT 0,      -- 结果用量是否已计入 request_logs: 1=是, 0=否
+    usage_recorded_at DATETIME,                     -- 用量记录时间
+
+    -- ========== 审计字段 ==========
+    created_at DATETIME DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now', 'localtime') || '+08:00'),
+    updated_at DATETIME DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now', 'localtime') || '+08:00')
+);
+
+-- Message Batches 表索引
+CREATE INDEX IF NOT EXISTS idx_message_batches_endpoint ON message_batches(channel, endpoint_name);
+CREATE INDEX IF NOT EXISTS idx_message_batches_status ON message_batches(processing_status);
+
+-- Message Batches 表触发器：自动更新 updated_at
+CREATE TRIGGER IF NOT EXISTS update_message_batches_timestamp
+    AFTER UPDATE ON message_batches
+    FOR EACH ROW
+    WHEN NEW.updated_at = OLD.updated_at
+BEGIN
+    UPDATE message_batches SET updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now', 'localtime') || '+08:00' WHERE id = NEW.id;
+END;
+
+-- ============================================================================
+-- 汇率表
+-- 记录 1 USD 可兑换的目标币种数量，按生效时间选取（用于多币种成本换算）
+-- channel 非空的汇率仅作用于该渠道，优先于同币种的全局汇率（channel 为 NULL）
+-- ============================================================================
+CREATE TABLE IF NOT EXISTS exchange_rates (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    channel TEXT,                                   -- 渠道名（NULL 表示全局汇率）
+    currency TEXT NOT NULL,                         -- 币种代码（ISO 4217，如 CNY）
+    rate REAL NOT NULL,                             -- 1 USD 可兑换的该币种数量
+    effective_from DATETIME NOT NULL,               -- 生效时间（此后的请求使用该汇率，直到下一条生效）
+    note TEXT,                                      -- 备注（如汇率来源）
+
+    -- ========== 审计字段 ==========
+    created_at DATETIME DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now', 'localtime') || '+08:00'),
+    updated_at DATETIME DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now', 'localtime') || '+08:00')
+);
+
+-- 汇率表索引（同一渠道/全局、同一币种、同一生效时间唯一）
+CREATE INDEX IF NOT EXISTS idx_exchange_rates_currency ON exchange_rates(currency, effective_from);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_exchange_rates_channel_unique ON exchange_rates(COALESCE(channel, ''), currency, effective_from);
+
+-- 汇率表触发器：自动更新 updated_at
+CREATE TRIGGER IF NOT EXISTS update_exchange_rates_timestamp
+    AFTER UPDATE ON exchange_rates
+    FOR EACH ROW
+    WHEN NEW.updated_at = OLD.updated_at
+BEGIN
+    UPDATE exchange_rates SET updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now', 'localtime') || '+08:00' WHERE id = NEW.id;
+END;
+
+-- ============================================================================
+-- 价目表（渠道/端点级别的模型价格，优先于 model_pricing）
+-- ============================================================================
+
+CREATE TABLE IF NOT EXISTS price_books (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL UNIQUE,                      -- 价目表名称（写入 request_logs.price_source）
+    channel TEXT NOT NULL,                          -- 所属渠道
+    endpoint_name TEXT NOT NULL DEFAULT '',         -- 端点名称（为空表示作用于整个渠道）
+    mode TEXT NOT NULL DEFAULT 'absolute',          -- 计价模式：absolute（绝对价格）/ ratio（倍率公式）
+    group_ratio REAL DEFAULT 1,                     -- 分组倍率（ratio 模式）
+    ratio_unit_price REAL DEFAULT 2,                -- 倍率为 1 时的输入单价 USD / 1M tokens（ratio 模式）
+    per_request_fee REAL DEFAULT 0,                 -- 每次请求固定费用（USD）
+    entries TEXT,                                   -- JSON 数组：[{model, input, output, cache_creation, cache_creation_1h, cache_read, model_ratio, completion_ratio, cache_read_ratio, cache_creation_ratio, per_request_fee}]
+    enabled INTEGER DEFAULT 1,                      -- 是否启用: 1=启用, 0=停用
+    description TEXT,
+
+    -- ========== 审计字段 ==========
+    created_at DATETIME DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now', 'localtime') || '+08:00'),
+    updated_at DATETIME DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now', 'localtime') || '+08:00')
+);
+
+-- 价目表索引
+CREATE INDEX IF NOT EXISTS idx_price_books_scope ON price_books(channel, endpoint_name);
+
+-- 价目表触发器：自动更新 updated_at
+CREATE TRIGGER IF NOT EXISTS update_price_books_timestamp
+    AFTER UPDATE ON price_books
+    FOR EACH ROW
+    WHEN NEW.updated_at = OLD.updated_at
+BEGIN
+    UPDATE price_books SET updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now', 'localtime') || '+08:00' WHERE id = NEW.id;
+END;
+
+-- ============================================================================
+-- 花费预算（全局/渠道/端点/模型，按日/周/月窗口，超限告警与硬限制）
+-- ============================================================================
+
+CREATE TABLE IF NOT EXISTS budgets (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL UNIQUE,                      -- 预算名称
+    scope TEXT NOT NULL DEFAULT 'global',           -- 作用范围：global / channel / endpoint / model
+    channel TEXT NOT NULL DEFAULT '',               -- 渠道（channel / endpoint 作用范围）
+    endpoint_name TEXT NOT NULL DEFAULT '',         -- 端点名称（endpoint 作用范围）
+    model_name TEXT NOT NULL DEFAULT '',            -- 模型名称（model 作用范围）
+    period TEXT NOT NULL DEFAULT 'daily',           -- 统计窗口：daily / weekly / monthly
+    limit_usd REAL NOT NULL,                        -- 预算上限（USD）
+    alert_thresholds TEXT,                          -- JSON 数组：告警阈值百分比，如 [50, 80, 100]
+    action TEXT NOT NULL DEFAULT 'alert',           -- 超限动作：alert / reject / divert / manual
+    divert_channel TEXT NOT NULL DEFAULT '',        -- divert 动作的目标渠道（为空时按故障转移顺序选择）
+    tripped_at DATETIME,                            -- manual 动作：超出预算时间（手动放行前保持阻断）
+    override_until DATETIME,                        -- 手动放行截止时间
+    enabled INTEGER DEFAULT 1,                      -- 是否启用: 1=启用, 0=停用
+    description TEXT,
+
+    -- ========== 审计字段 ==========
+    created_at DATETIME DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now', 'localtime') || '+08:00'),
+    updated_at DATETIME DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now', 'localtime') || '+08:00')
+);
+
+-- 预算触发器：自动更新 updated_at
+CREATE TRIGGER IF NOT EXISTS update_budgets_timestamp
+    AFTER UPDATE ON budgets
+    FOR EACH ROW
+    WHEN NEW.updated_at = OLD.updated_at
+BEGIN
+    UPDATE budgets SET updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now', 'localtime') || '+08:00' WHERE id = NEW.id;
+END;
+
+-- ============================================================================
+-- Webhook 通知（故障转移、端点宕机/恢复、挂起超时、数据库错误、预算告警推送到团队群）
+-- ============================================================================
+
+CREATE TABLE IF NOT EXISTS webhooks (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL UNIQUE,                      -- 目标名称
+    kind TEXT NOT NULL DEFAULT 'generic',           -- 消息格式：generic / slack / feishu / dingtalk / wecom
+    url TEXT NOT NULL,                              -- Webhook 地址
+    secret TEXT NOT NULL DEFAULT '',                -- 签名密钥（飞书/钉钉加签，generic 的 HMAC 签名头）
+    event_types TEXT,                               -- JSON 数组：订阅的事件类型（为空时订阅默认运维事件）
+    dedup_seconds INTEGER NOT NULL DEFAULT 300,     -- 去重窗口：同一事件对象在窗口内只发送一次（0 不去重）
+    rate_limit_per_minute INTEGER NOT NULL DEFAULT 20, -- 每分钟最多发送次数（0 不限）
+    max_retries INTEGER NOT NULL DEFAULT 3,         -- 失败重试次数（指数退避）
+    enabled INTEGER DEFAULT 1,                      -- 是否启用: 1=启用, 0=停用
+    description TEXT,
+
+    -- ========== 审计字段 ==========
+    created_at DATETIME DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now', 'localtime') || '+08:00'),
+    updated_at DATETIME DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now', 'localtime') || '+08:00')
+);
+
+-- Webhook 触发器：自动更新 updated_at
+CREATE TRIGGER IF NOT EXISTS update_webhooks_timestamp
+    AFTER UPDATE ON webhooks
+    FOR EACH ROW
+    WHEN NEW.updated_at = OLD.updated_at
+BEGIN
+    UPDATE webhooks SET updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now', 'localtime') || '+08:00' WHERE id = NEW.id;
+END;
+
+-- Webhook 投递记录
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    webhook_name TEXT NOT NULL,                     -- 目标名称
+    event_type TEXT NOT NULL,                       -- 事件类型
+    dedup_key TEXT NOT NULL DEFAULT '',             -- 去重键（事件类型 + 对象）
+    status TEXT NOT NULL,                           -- success / failed
+    attempts INTEGER NOT NULL DEFAULT 0,            -- 尝试次数（含重试）
+    http_status INTEGER NOT NULL DEFAULT 0,         -- 最后一次响应状态码
+    error TEXT,                                     -- 最后一次错误
+    payload TEXT,                                   -- 发送的消息体
+    created_at DATETIME DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now', 'localtime') || '+08:00'),
+    delivered_at DATETIME                           -- 投递成功时间
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook ON webhook_deliveries(webhook_name, created_at);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_created ON webhook_deliveries(created_at);
+
+-- ============================================================================
+-- 渠道余额探测（new-api / one-api 风格中转的余额/额度接口）
+-- ============================================================================
+
+CREATE TABLE IF NOT EXISTS channel_balance_probes (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    channel TEXT NOT NULL UNIQUE,                   -- 渠道名称
+    url TEXT NOT NULL,                              -- 余额/额度接口地址（GET）
+    auth_header TEXT NOT NULL DEFAULT 'Authorization', -- 认证头名称
+    auth_token TEXT NOT NULL DEFAULT '',            -- 认证头值（Authorization 缺少认证方案时自动补 Bearer）
+    balance_path TEXT NOT NULL,                     -- 余额在响应 JSON 中的路径（如 data.quota）
+    unit TEXT NOT NULL DEFAULT 'USD',               -- 余额单位（仅展示）
+    scale REAL NOT NULL DEFAULT 1,                  -- 换算系数：余额 = 原始值 / scale
+    low_threshold REAL NOT NULL DEFAULT 0,          -- 低余额阈值（0 不告警）
+    exclude_when_low INTEGER NOT NULL DEFAULT 0,    -- 低余额时排除出故障转移候选: 1=是, 0=否
+    interval_seconds INTEGER NOT NULL DEFAULT 600,  -- 轮询间隔（秒）
+    enabled INTEGER DEFAULT 1,                      -- 是否启用: 1=启用, 0=停用
+
+    -- ========== 审计字段 ==========
+    created_at DATETIME DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now', 'localtime') || '+08:00'),
+    updated_at DATETIME DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now', 'localtime') || '+08:00')
+);
+
+-- 余额探测触发器：自动更新 updated_at
+CREATE TRIGGER IF NOT EXISTS update_channel_balance_probes_timestamp
+    AFTER UPDATE ON channel_balance_probes
+    FOR EACH ROW
+    WHEN NEW.updated_at = OLD.updated_at
+BEGIN
+    UPDATE channel_balance_probes SET updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now', 'localtime') || '+08:00' WHERE id = NEW.id;
+END;
+
+-- 渠道余额时间序列
+CREATE TABLE IF NOT EXISTS channel_balances (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    channel TEXT NOT NULL,                          -- 渠道名称
+    balance REAL NOT NULL DEFAULT 0,                -- 换算后的余额
+    raw_value REAL NOT NULL DEFAULT 0,              -- 接口返回的原始值
+    unit TEXT NOT NULL DEFAULT '',                  -- 余额单位
+    low INTEGER NOT NULL DEFAULT 0,                 -- 是否低于阈值
+    error TEXT,                                     -- 探测失败原因（失败时余额字段无意义）
+    checked_at DATETIME NOT NULL                    -- 探测时间
+);
+
+CREATE INDEX IF NOT EXISTS idx_channel_balances_channel ON channel_balances(channel, checked_at);
+CREATE INDEX IF NOT EXISTS idx_channel_balances_checked ON channel_balances(checked_at);
+
+-- ============================================================================
+-- 请求标签（项目/成本中心归属：标签请求头或 /p/<project>/ 路径前缀）
+-- ============================================================================
+
+CREATE TABLE IF NOT EXISTS request_tags (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    request_id TEXT NOT NULL,                       -- 关联 request_logs.request_id
+    tag_key TEXT NOT NULL,                          -- 标签键（如 project、tag）
+    tag_value TEXT NOT NULL,                        -- 标签值
+    created_at DATETIME DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now', 'localtime') || '+08:00'),
+
+    UNIQUE(request_id, tag_key, tag_value)
+);
+
+CREATE INDEX IF NOT EXISTS idx_request_tags_key_value ON request_tags(tag_key, tag_value);
+
+-- ============================================================================
+-- 用量汇总（小时/日，按模型、端点、渠道、状态；归档时增量累加，成本重算/保留期清理后重建）
+-- ============================================================================
+
+CREATE TABLE IF NOT EXISTS usage_rollup_hourly (
+    bucket TEXT NOT NULL,                           -- 小时桶：YYYY-MM-DD HH:00:00（配置时区）
+    model_name TEXT NOT NULL DEFAULT '',
+    endpoint_name TEXT NOT NULL DEFAULT '',
+    channel TEXT NOT NULL DEFAULT '',
+    status TEXT NOT NULL DEFAULT '',
+
+    request_count INTEGER NOT NULL DEFAULT 0,
+    input_tokens INTEGER NOT NULL DEFAULT 0,
+    output_tokens INTEGER NOT NULL DEFAULT 0,
+    cache_creation_tokens INTEGER NOT NULL DEFAULT 0,
+    cache_read_tokens INTEGER NOT NULL DEFAULT 0,
+    total_cost_usd REAL NOT NULL DEFAULT 0,
+    duration_sum_ms INTEGER NOT NULL DEFAULT 0,     -- 有效耗时（>0）合计
+    duration_count INTEGER NOT NULL DEFAULT 0,      -- 有效耗时请求数
+
+    UNIQUE(bucket, model_name, endpoint_name, channel, status)
+);
+
+CREATE TABLE IF NOT EXISTS usage_rollup_daily (
+    bucket TEXT NOT NULL,                           -- 日桶：YYYY-MM-DD（配置时区）
+    model_name TEXT NOT NULL DEFAULT '',
+    endpoint_name TEXT NOT NULL DEFAULT '',
+    channel TEXT NOT NULL DEFAULT '',
+    status TEXT NOT NULL DEFAULT '',
+
+    request_count INTEGER NOT NULL DEFAULT 0,
+    input_tokens INTEGER NOT NULL DEFAULT 0,
+    output_tokens INTEGER NOT NULL DEFAULT 0,
+    cache_creation_tokens INTEGER NOT NULL DEFAULT 0,
+    cache_read_tokens INTEGER NOT NULL DEFAULT 0,
+    total_cost_usd REAL NOT NULL DEFAULT 0,
+    duration_sum_ms INTEGER NOT NULL DEFAULT 0,
+    duration_count INTEGER NOT NULL DEFAULT 0,
+
+    UNIQUE(bucket, model_name, endpoint_name, channel, status)
+);
+
+-- ============================================================================
+-- 上游尝试追踪（每次重试/故障转移各一行，关联 request_logs.request_id）
+-- ============================================================================
+
+CREATE TABLE IF NOT EXISTS request_attempts (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    request_id TEXT NOT NULL,                       -- 关联 request_logs.request_id
+    attempt_number INTEGER NOT NULL,                -- 全局尝试序号（从 1 开始）
+    channel TEXT,                                   -- 渠道标签
+    endpoint_name TEXT,                             -- 端点名称
+    start_time DATETIME NOT NULL,                   -- 上游请求发出时间（配置时区）
+    end_time DATETIME,                              -- 本次尝试结束时间
+    duration_ms INTEGER,                            -- 本次尝试耗时
+    http_status INTEGER,                            -- 上游 HTTP 状态码（未收到响应为空）
+    error_type TEXT,                                -- 错误分类（取值同 failure_reason）
+    error_message TEXT,                             -- 上游错误摘要（截断）
+    bytes_received INTEGER DEFAULT 0,               -- 收到的响应字节数
+    decision TEXT NOT NULL,                         -- success/retry/switch_endpoint/suspend/fail/cancelled
+    decision_reason TEXT,                           -- 重试决策原因
+    error_signature TEXT,                           -- 归一化错误消息（去除 ID 与数字）
+    error_fingerprint TEXT,                         -- 错误指纹（错误类型 + 状态码 + 归一化消息）
+    created_at DATETIME DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now', 'localtime') || '+08:00')
+);
+
+CREATE INDEX IF NOT EXISTS idx_request_attempts_request ON request_attempts(request_id, attempt_number);
+CREATE INDEX IF NOT EXISTS idx_request_attempts_fingerprint ON request_attempts(error_fingerprint, start_time);
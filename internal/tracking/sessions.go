@@ -0,0 +1,205 @@
+package tracking
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SessionQueryOptions 会话查询选项
+type SessionQueryOptions struct {
+	StartDate *time.Time
+	EndDate   *time.Time
+	SessionID string // 为空表示全部会话
+	Limit     int
+	Offset    int
+}
+
+// SessionSummary 会话级用量汇总（一次 Claude Code 编码会话的实际成本）
+type SessionSummary struct {
+	SessionID string    `json:"session_id"`
+	StartTime time.Time `json:"start_time"` // 会话首个请求开始时间
+	EndTime   time.Time `json:"end_time"`   // 会话最后一个请求结束时间
+
+	RequestCount int `json:"request_count"`
+	SuccessCount int `json:"success_count"`
+	FailedCount  int `json:"failed_count"`
+
+	InputTokens         int64   `json:"input_tokens"`
+	OutputTokens        int64   `json:"output_tokens"`
+	CacheCreationTokens int64   `json:"cache_creation_tokens"`
+	CacheReadTokens     int64   `json:"cache_read_tokens"`
+	TotalTokens         int64   `json:"total_tokens"`
+	TotalCostUSD        float64 `json:"total_cost_usd"`
+
+	WallClockMs     int64 `json:"wall_clock_ms"`     // 会话跨度（首个请求开始到最后一个请求结束）
+	TotalDurationMs int64 `json:"total_duration_ms"` // 各请求耗时合计
+
+	Models    []string `json:"models"`    // 使用过的模型
+	Endpoints []string `json:"endpoints"` // 命中过的端点（渠道/端点）
+}
+
+// QuerySessions 按会话聚合请求记录（按最近活动时间倒序）
+func (ut *UsageTracker) QuerySessions(ctx context.Context, opts *SessionQueryOptions) ([]SessionSummary, error) {
+	if ut.readDB == nil {
+		return nil, fmt.Errorf("read database not initialized")
+	}
+	if opts == nil {
+		opts = &SessionQueryOptions{}
+	}
+
+	query := `SELECT session_id,
+		MIN(start_time) as first_start,
+		MAX(COALESCE(end_time, start_time)) as last_end,
+		COUNT(*) as request_count,
+		COALESCE(SUM(CASE WHEN status = 'completed' THEN 1 ELSE 0 END), 0) as success_count,
+		COALESCE(SUM(CASE WHEN status IN ('failed', 'error', 'auth_error', 'rate_limited', 'server_error', 'network_error', 'stream_error', 'timeout') THEN 1 ELSE 0 END), 0) as failed_count,
+		COALESCE(SUM(input_tokens), 0), COALESCE(SUM(output_tokens), 0),
+		COALESCE(SUM(cache_creation_tokens), 0), COALESCE(SUM(cache_read_tokens), 0),
+		COALESCE(SUM(total_cost_usd), 0.0),
+		COALESCE(SUM(CASE WHEN duration_ms > 0 THEN duration_ms ELSE 0 END), 0),
+		COALESCE(GROUP_CONCAT(DISTINCT NULLIF(model_name, '')), ''),
+		COALESCE(GROUP_CONCAT(DISTINCT NULLIF(CASE WHEN COALESCE(channel, '') != '' THEN channel || '/' || COALESCE(endpoint_name, '') ELSE COALESCE(endpoint_name, '') END, '')), '')
+		FROM request_logs WHERE session_id IS NOT NULL AND session_id != ''`
+
+	var args []interface{}
+	if opts.StartDate != nil {
+		query += " AND start_time >= ?"
+		args = append(args, ut.formatStartTimeQueryBound(*opts.StartDate))
+	}
+	if opts.EndDate != nil {
+		query += " AND start_time <= ?"
+		args = append(args, ut.formatEndTimeQueryBound(*opts.EndDate))
+	}
+	if opts.SessionID != "" {
+		query += " AND session_id = ?"
+		args = append(args, opts.SessionID)
+	}
+
+	query += " GROUP BY session_id ORDER BY last_end DESC"
+
+	if opts.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, opts.Limit)
+		if opts.Offset > 0 {
+			query += " OFFSET ?"
+			args = append(args, opts.Offset)
+		}
+	}
+
+	rows, err := ut.readDB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sessions: %w", err)
+	}
+	defer rows.Close()
+
+	var sessions []SessionSummary
+	for rows.Next() {
+		var s SessionSummary
+		var firstStart, lastEnd sql.NullString
+		var models, endpoints string
+		if err := rows.Scan(
+			&s.SessionID, &firstStart, &lastEnd,
+			&s.RequestCount, &s.SuccessCount, &s.FailedCount,
+			&s.InputTokens, &s.OutputTokens, &s.CacheCreationTokens, &s.CacheReadTokens,
+			&s.TotalCostUSD, &s.TotalDurationMs,
+			&models, &endpoints,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan session: %w", err)
+		}
+
+		s.StartTime = ut.parseStoredTime(firstStart.String)
+		s.EndTime = ut.parseStoredTime(lastEnd.String)
+		if !s.StartTime.IsZero() && s.EndTime.After(s.StartTime) {
+			s.WallClockMs = s.EndTime.Sub(s.StartTime).Milliseconds()
+		}
+		s.TotalTokens = s.InputTokens + s.OutputTokens + s.CacheCreationTokens + s.CacheReadTokens
+		s.Models = splitDistinct(models)
+		s.Endpoints = splitDistinct(endpoints)
+		sessions = append(sessions, s)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating session rows: %w", err)
+	}
+	return sessions, nil
+}
+
+// CountSessions 统计符合条件的会话数量
+func (ut *UsageTracker) CountSessions(ctx context.Context, opts *SessionQueryOptions) (int, error) {
+	if ut.readDB == nil {
+		return 0, fmt.Errorf("read database not initialized")
+	}
+	if opts == nil {
+		opts = &SessionQueryOptions{}
+	}
+
+	query := "SELECT COUNT(DISTINCT session_id) FROM request_logs WHERE session_id IS NOT NULL AND session_id != ''"
+	var args []interface{}
+	if opts.StartDate != nil {
+		query += " AND start_time >= ?"
+		args = append(args, ut.formatStartTimeQueryBound(*opts.StartDate))
+	}
+	if opts.EndDate != nil {
+		query += " AND start_time <= ?"
+		args = append(args, ut.formatEndTimeQueryBound(*opts.EndDate))
+	}
+	if opts.SessionID != "" {
+		query += " AND session_id = ?"
+		args = append(args, opts.SessionID)
+	}
+
+	var count int
+	if err := ut.readDB.QueryRowContext(ctx, query, args...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count sessions: %w", err)
+	}
+	return count, nil
+}
+
+// GetSessionSummary 获取单个会话的用量汇总（会话不存在时返回 nil）
+func (ut *UsageTracker) GetSessionSummary(ctx context.Context, sessionID string) (*SessionSummary, error) {
+	if sessionID == "" {
+		return nil, fmt.Errorf("session id is required")
+	}
+	sessions, err := ut.QuerySessions(ctx, &SessionQueryOptions{SessionID: sessionID})
+	if err != nil {
+		return nil, err
+	}
+	if len(sessions) == 0 {
+		return nil, nil
+	}
+	return &sessions[0], nil
+}
+
+// parseStoredTime 解析 request_logs 中按配置时区存储的时间字符串
+func (ut *UsageTracker) parseStoredTime(value string) time.Time {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}
+	}
+	loc := time.Local
+	if ut != nil && ut.location != nil {
+		loc = ut.location
+	}
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02 15:04:05.999999999-07:00", "2006-01-02 15:04:05.999999999", "2006-01-02T15:04:05.999999999"} {
+		if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// splitDistinct 拆分 GROUP_CONCAT 结果并排序
+func splitDistinct(value string) []string {
+	result := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
@@ -0,0 +1,102 @@
+package tracking
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// TestQuerySessions 测试会话级聚合：成本、token、耗时、请求数、模型与端点
+func TestQuerySessions(t *testing.T) {
+	config := &Config{
+		Enabled:         true,
+		DatabasePath:    ":memory:",
+		BufferSize:      100,
+		BatchSize:       10,
+		FlushInterval:   50 * time.Millisecond,
+		MaxRetry:        3,
+		CleanupInterval: 24 * time.Hour,
+		RetentionDays:   30,
+	}
+	tracker, err := NewUsageTracker(config)
+	if err != nil {
+		t.Fatalf("Failed to create usage tracker: %v", err)
+	}
+	defer tracker.Close()
+
+	ctx := context.Background()
+	base := tracker.now().Add(-time.Hour).Truncate(time.Second)
+	rows := []struct {
+		id, session, channel, endpoint, model, status string
+		offset                                        time.Duration
+		durationMs                                    int64
+		input, output                                 int64
+		cost                                          float64
+	}{
+		{"req-s1-a", "sess-1", "relay", "relay-a", "claude-sonnet-4", "completed", 0, 2000, 100, 50, 0.5},
+		{"req-s1-b", "sess-1", "relay", "relay-b", "claude-haiku-4", "completed", 10 * time.Minute, 1000, 20, 10, 0.1},
+		{"req-s1-c", "sess-1", "relay", "relay-a", "claude-sonnet-4", "failed", 20 * time.Minute, 500, 0, 0, 0},
+		{"req-s2-a", "sess-2", "official", "anthropic", "claude-opus-4", "completed", 30 * time.Minute, 3000, 10, 10, 1},
+		{"req-none", "", "relay", "relay-a", "claude-sonnet-4", "completed", 40 * time.Minute, 100, 1, 1, 0.01},
+	}
+	for _, r := range rows {
+		start := base.Add(r.offset)
+		end := start.Add(time.Duration(r.durationMs) * time.Millisecond)
+		_, err := tracker.writeDB.ExecContext(ctx, `INSERT INTO request_logs (
+			request_id, start_time, end_time, duration_ms, channel, endpoint_name, model_name, status,
+			input_tokens, output_tokens, total_cost_usd, session_id
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			r.id, start.Format("2006-01-02 15:04:05"), end.Format("2006-01-02 15:04:05"), r.durationMs,
+			r.channel, r.endpoint, r.model, r.status, r.input, r.output, r.cost, nullString(r.session))
+		if err != nil {
+			t.Fatalf("插入测试数据失败: %v", err)
+		}
+	}
+
+	sessions, err := tracker.QuerySessions(ctx, &SessionQueryOptions{})
+	if err != nil {
+		t.Fatalf("查询会话失败: %v", err)
+	}
+	if len(sessions) != 2 || sessions[0].SessionID != "sess-2" {
+		t.Fatalf("会话应按最近活动倒序且忽略无会话请求: %+v", sessions)
+	}
+	if count, _ := tracker.CountSessions(ctx, nil); count != 2 {
+		t.Errorf("会话数 = %d, want 2", count)
+	}
+
+	s1, err := tracker.GetSessionSummary(ctx, "sess-1")
+	if err != nil || s1 == nil {
+		t.Fatalf("获取会话汇总失败: %v", err)
+	}
+	if s1.RequestCount != 3 || s1.SuccessCount != 2 || s1.FailedCount != 1 {
+		t.Errorf("请求数不符: %+v", s1)
+	}
+	if s1.TotalTokens != 180 || s1.TotalCostUSD < 0.599 || s1.TotalCostUSD > 0.601 {
+		t.Errorf("token/成本不符: tokens=%d cost=%f", s1.TotalTokens, s1.TotalCostUSD)
+	}
+	if s1.TotalDurationMs != 3500 {
+		t.Errorf("请求耗时合计 = %d, want 3500", s1.TotalDurationMs)
+	}
+	// 首个请求开始到最后一个请求结束：20 分钟 + 500ms（存储为秒级精度，截断为 20 分钟）
+	if s1.WallClockMs != (20 * time.Minute).Milliseconds() {
+		t.Errorf("会话跨度 = %d ms", s1.WallClockMs)
+	}
+	if len(s1.Models) != 2 || s1.Models[0] != "claude-haiku-4" || s1.Models[1] != "claude-sonnet-4" {
+		t.Errorf("模型不符: %v", s1.Models)
+	}
+	if len(s1.Endpoints) != 2 || s1.Endpoints[0] != "relay/relay-a" || s1.Endpoints[1] != "relay/relay-b" {
+		t.Errorf("端点不符: %v", s1.Endpoints)
+	}
+
+	if missing, err := tracker.GetSessionSummary(ctx, "none"); err != nil || missing != nil {
+		t.Errorf("不存在的会话应返回 nil: %+v, %v", missing, err)
+	}
+
+	details, err := tracker.QueryRequestDetails(ctx, &QueryOptions{SessionID: "sess-1"})
+	if err != nil {
+		t.Fatalf("按会话查询请求失败: %v", err)
+	}
+	if len(details) != 3 || details[0].SessionID != "sess-1" {
+		t.Errorf("按会话筛选请求不符: %d", len(details))
+	}
+}
@@ -221,6 +221,136 @@ func (s *SQLiteAdapter) migrateSchema(ctx context.Context) error {
 			alterSQL:    "ALTER TABLE request_logs ADD COLUMN cache_creation_1h_cost_usd REAL DEFAULT 0",
 			description: "1小时缓存创建成本字段",
 		},
+		{
+			checkColumn: "web_search_requests",
+			alterSQL:    "ALTER TABLE request_logs ADD COLUMN web_search_requests INTEGER DEFAULT 0",
+			description: "web search 调用次数字段",
+		},
+		{
+			checkColumn: "web_fetch_requests",
+			alterSQL:    "ALTER TABLE request_logs ADD COLUMN web_fetch_requests INTEGER DEFAULT 0",
+			description: "web fetch 调用次数字段",
+		},
+		{
+			checkColumn: "server_tool_cost_usd",
+			alterSQL:    "ALTER TABLE request_logs ADD COLUMN server_tool_cost_usd REAL DEFAULT 0",
+			description: "服务端工具成本字段",
+		},
+		{
+			checkColumn: "pricing_tier",
+			alterSQL:    "ALTER TABLE request_logs ADD COLUMN pricing_tier INTEGER DEFAULT 0",
+			description: "长上下文定价档位字段",
+		},
+		{
+			checkColumn: "is_batch",
+			alterSQL:    "ALTER TABLE request_logs ADD COLUMN is_batch INTEGER DEFAULT 0",
+			description: "批处理用量标记字段",
+		},
+		{
+			checkColumn: "billing_currency",
+			alterSQL:    "ALTER TABLE request_logs ADD COLUMN billing_currency TEXT",
+			description: "结算币种字段",
+		},
+		{
+			checkColumn: "billing_cost",
+			alterSQL:    "ALTER TABLE request_logs ADD COLUMN billing_cost REAL",
+			description: "结算币种成本字段",
+		},
+		{
+			checkColumn: "reporting_currency",
+			alterSQL:    "ALTER TABLE request_logs ADD COLUMN reporting_currency TEXT",
+			description: "报表币种字段",
+		},
+		{
+			checkColumn: "reporting_cost",
+			alterSQL:    "ALTER TABLE request_logs ADD COLUMN reporting_cost REAL",
+			description: "报表币种成本字段",
+		},
+		{
+			checkColumn: "price_source",
+			alterSQL:    "ALTER TABLE request_logs ADD COLUMN price_source TEXT",
+			description: "价格来源字段",
+		},
+		{
+			checkColumn: "request_fee_usd",
+			alterSQL:    "ALTER TABLE request_logs ADD COLUMN request_fee_usd REAL DEFAULT 0",
+			description: "按次固定费用字段",
+		},
+		{
+			checkColumn: "session_id",
+			alterSQL:    "ALTER TABLE request_logs ADD COLUMN session_id TEXT",
+			description: "会话标识字段",
+		},
+		{
+			checkColumn: "ttfb_ms",
+			alterSQL:    "ALTER TABLE request_logs ADD COLUMN ttfb_ms INTEGER",
+			description: "上游首字节耗时字段",
+		},
+		{
+			checkColumn: "first_event_ms",
+			alterSQL:    "ALTER TABLE request_logs ADD COLUMN first_event_ms INTEGER",
+			description: "首个 SSE 事件耗时字段",
+		},
+		{
+			checkColumn: "first_token_ms",
+			alterSQL:    "ALTER TABLE request_logs ADD COLUMN first_token_ms INTEGER",
+			description: "首个内容增量耗时字段",
+		},
+		{
+			checkColumn: "output_tokens_per_sec",
+			alterSQL:    "ALTER TABLE request_logs ADD COLUMN output_tokens_per_sec REAL",
+			description: "输出速率字段",
+		},
+		{
+			checkColumn: "stop_reason",
+			alterSQL:    "ALTER TABLE request_logs ADD COLUMN stop_reason TEXT",
+			description: "停止原因字段",
+		},
+		{
+			checkColumn: "response_message_id",
+			alterSQL:    "ALTER TABLE request_logs ADD COLUMN response_message_id TEXT",
+			description: "响应消息ID字段",
+		},
+		{
+			checkColumn: "tool_use_count",
+			alterSQL:    "ALTER TABLE request_logs ADD COLUMN tool_use_count INTEGER DEFAULT 0",
+			description: "工具调用数量字段",
+		},
+		{
+			checkColumn: "has_thinking",
+			alterSQL:    "ALTER TABLE request_logs ADD COLUMN has_thinking INTEGER DEFAULT 0",
+			description: "thinking 标记字段",
+		},
+		{
+			checkColumn: "max_tokens",
+			alterSQL:    "ALTER TABLE request_logs ADD COLUMN max_tokens INTEGER DEFAULT 0",
+			description: "max_tokens 字段",
+		},
+		{
+			checkColumn: "message_count",
+			alterSQL:    "ALTER TABLE request_logs ADD COLUMN message_count INTEGER DEFAULT 0",
+			description: "消息数量字段",
+		},
+		{
+			checkColumn: "tool_count",
+			alterSQL:    "ALTER TABLE request_logs ADD COLUMN tool_count INTEGER DEFAULT 0",
+			description: "工具定义数量字段",
+		},
+		{
+			checkColumn: "thinking_budget",
+			alterSQL:    "ALTER TABLE request_logs ADD COLUMN thinking_budget INTEGER DEFAULT 0",
+			description: "思考预算字段",
+		},
+		{
+			checkColumn: "temperature",
+			alterSQL:    "ALTER TABLE request_logs ADD COLUMN temperature REAL",
+			description: "温度字段",
+		},
+		{
+			checkColumn: "request_bytes",
+			alterSQL:    "ALTER TABLE request_logs ADD COLUMN request_bytes INTEGER DEFAULT 0",
+			description: "请求体大小字段",
+		},
 	}
 
 	// endpoints 迁移：端点存储表迭代新增字段时，需要兼容旧 db（CREATE TABLE IF NOT EXISTS 不会补列）
@@ -239,6 +369,11 @@ func (s *SQLiteAdapter) migrateSchema(ctx context.Context) error {
 			alterSQL:    "ALTER TABLE endpoints ADD COLUMN supports_count_tokens INTEGER DEFAULT 0",
 			description: "端点 supports_count_tokens 字段",
 		},
+		{
+			checkColumn: "capabilities",
+			alterSQL:    "ALTER TABLE endpoints ADD COLUMN capabilities TEXT",
+			description: "端点能力声明字段",
+		},
 		{
 			checkColumn: "cost_multiplier",
 			alterSQL:    "ALTER TABLE endpoints ADD COLUMN cost_multiplier REAL DEFAULT 1.0",
@@ -292,6 +427,65 @@ func (s *SQLiteAdapter) migrateSchema(ctx context.Context) error {
 			alterSQL:    "ALTER TABLE channels ADD COLUMN failover_enabled INTEGER DEFAULT 1",
 			description: "渠道故障转移开关字段",
 		},
+		{
+			checkColumn: "billing_currency",
+			alterSQL:    "ALTER TABLE channels ADD COLUMN billing_currency TEXT DEFAULT 'USD'",
+			description: "渠道结算币种字段",
+		},
+	}
+
+	// model_pricing 迁移：服务端工具按次定价
+	modelPricingMigrations := []struct {
+		checkColumn string
+		alterSQL    string
+		description string
+	}{
+		{
+			checkColumn: "web_search_price",
+			alterSQL:    "ALTER TABLE model_pricing ADD COLUMN web_search_price REAL DEFAULT 10.0",
+			description: "web search 每千次价格字段",
+		},
+		{
+			checkColumn: "web_fetch_price",
+			alterSQL:    "ALTER TABLE model_pricing ADD COLUMN web_fetch_price REAL DEFAULT 0",
+			description: "web fetch 每千次价格字段",
+		},
+		{
+			checkColumn: "pricing_tiers",
+			alterSQL:    "ALTER TABLE model_pricing ADD COLUMN pricing_tiers TEXT",
+			description: "长上下文分档定价字段",
+		},
+	}
+
+	// request_attempts 迁移：错误指纹（错误分析按指纹聚合）
+	requestAttemptMigrations := []struct {
+		checkColumn string
+		alterSQL    string
+		description string
+	}{
+		{
+			checkColumn: "error_signature",
+			alterSQL:    "ALTER TABLE request_attempts ADD COLUMN error_signature TEXT",
+			description: "归一化错误消息字段",
+		},
+		{
+			checkColumn: "error_fingerprint",
+			alterSQL:    "ALTER TABLE request_attempts ADD COLUMN error_fingerprint TEXT",
+			description: "错误指纹字段",
+		},
+	}
+
+	// message_batches 迁移：批处理创建请求的标签
+	messageBatchMigrations := []struct {
+		checkColumn string
+		alterSQL    string
+		description string
+	}{
+		{
+			checkColumn: "tags",
+			alterSQL:    "ALTER TABLE message_batches ADD COLUMN tags TEXT",
+			description: "批处理标签字段",
+		},
 	}
 
 	runMigrations := func(table string, migrations []struct {
@@ -339,7 +533,113 @@ func (s *SQLiteAdapter) migrateSchema(ctx context.Context) error {
 	if err := runMigrations("channels", channelMigrations); err != nil {
 		return err
 	}
+	if err := runMigrations("model_pricing", modelPricingMigrations); err != nil {
+		return err
+	}
+	if err := runMigrations("request_attempts", requestAttemptMigrations); err != nil {
+		return err
+	}
+	if err := runMigrations("message_batches", messageBatchMigrations); err != nil {
+		return err
+	}
+	// 汇率支持按渠道覆盖：旧表的 UNIQUE(currency,effective_from) 会拦住同一时间的渠道汇率，需要重建表
+	if err := s.ensureExchangeRatesChannelScoped(ctx); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ensureExchangeRatesChannelScoped 为旧 exchange_rates 表补充 channel 列
+// 唯一约束由 (currency,effective_from) 调整为 (channel,currency,effective_from)，SQLite 无法移除表约束，需重建表
+func (s *SQLiteAdapter) ensureExchangeRatesChannelScoped(ctx context.Context) error {
+	tableExists, err := s.tableExists(ctx, "exchange_rates")
+	if err != nil {
+		return fmt.Errorf("failed to check table exchange_rates: %w", err)
+	}
+	if !tableExists {
+		return nil
+	}
+	hasChannel, err := s.columnExists(ctx, "exchange_rates", "channel")
+	if err != nil {
+		return fmt.Errorf("failed to check column exchange_rates.channel: %w", err)
+	}
+	if hasChannel {
+		return nil
+	}
+
+	s.logger.Info("🔧 [数据库迁移] exchange_rates：添加渠道字段，唯一约束调整为 (channel,currency,effective_from)")
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin exchange_rates rebuild tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// 清理旧触发器与索引（表重建前先删，避免名字冲突）
+	_, _ = tx.ExecContext(ctx, "DROP TRIGGER IF EXISTS update_exchange_rates_timestamp")
+	_, _ = tx.ExecContext(ctx, "DROP INDEX IF EXISTS idx_exchange_rates_currency")
+
+	if _, err := tx.ExecContext(ctx, "ALTER TABLE exchange_rates RENAME TO exchange_rates_old"); err != nil {
+		return fmt.Errorf("failed to rename exchange_rates to exchange_rates_old: %w", err)
+	}
 
+	// 创建新表（与 schema.sql 保持一致）
+	createSQL := `
+CREATE TABLE exchange_rates (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    channel TEXT,
+    currency TEXT NOT NULL,
+    rate REAL NOT NULL,
+    effective_from DATETIME NOT NULL,
+    note TEXT,
+
+    created_at DATETIME DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now', 'localtime') || '+08:00'),
+    updated_at DATETIME DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now', 'localtime') || '+08:00')
+);`
+	if _, err := tx.ExecContext(ctx, createSQL); err != nil {
+		return fmt.Errorf("failed to create new exchange_rates table: %w", err)
+	}
+
+	// 复制数据（保留原 id，旧汇率均为全局汇率）
+	copySQL := `
+INSERT INTO exchange_rates (id, currency, rate, effective_from, note, created_at, updated_at)
+SELECT id, currency, rate, effective_from, note, created_at, updated_at
+FROM exchange_rates_old;`
+	if _, err := tx.ExecContext(ctx, copySQL); err != nil {
+		return fmt.Errorf("failed to copy exchange_rates data: %w", err)
+	}
+
+	indexSQL := []string{
+		"CREATE INDEX IF NOT EXISTS idx_exchange_rates_currency ON exchange_rates(currency, effective_from)",
+		"CREATE UNIQUE INDEX IF NOT EXISTS idx_exchange_rates_channel_unique ON exchange_rates(COALESCE(channel, ''), currency, effective_from)",
+	}
+	for _, stmt := range indexSQL {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("failed to create exchange_rates index: %w", err)
+		}
+	}
+
+	triggerSQL := `
+CREATE TRIGGER IF NOT EXISTS update_exchange_rates_timestamp
+    AFTER UPDATE ON exchange_rates
+    FOR EACH ROW
+    WHEN NEW.updated_at = OLD.updated_at
+BEGIN
+    UPDATE exchange_rates SET updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now', 'localtime') || '+08:00' WHERE id = NEW.id;
+END;`
+	if _, err := tx.ExecContext(ctx, triggerSQL); err != nil {
+		return fmt.Errorf("failed to recreate exchange_rates trigger: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "DROP TABLE exchange_rates_old"); err != nil {
+		return fmt.Errorf("failed to drop exchange_rates_old: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit exchange_rates rebuild: %w", err)
+	}
+	s.logger.Info("✅ [数据库迁移] exchange_rates：已重建为按渠道区分的汇率表")
 	return nil
 }
 
@@ -477,6 +777,7 @@ CREATE TABLE endpoints (
     timeout_seconds INTEGER DEFAULT 300,
 
     supports_count_tokens INTEGER DEFAULT 0,
+    capabilities TEXT,
 
     cost_multiplier REAL DEFAULT 1.0,
     input_cost_multiplier REAL DEFAULT 1.0,
@@ -126,6 +126,68 @@ CREATE TABLE IF NOT EXISTS channels (
 	}
 }
 
+// 回归测试：旧 exchange_rates 表（UNIQUE(currency,effective_from)、无 channel 列）应重建为按渠道区分的汇率表并保留数据
+func TestSQLiteAdapter_InitSchema_LegacyExchangeRates(t *testing.T) {
+	t.Parallel()
+
+	dbPath := filepath.Join(t.TempDir(), "legacy_rates.db")
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	_, err = db.Exec(`
+CREATE TABLE exchange_rates (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    currency TEXT NOT NULL,
+    rate REAL NOT NULL,
+    effective_from DATETIME NOT NULL,
+    note TEXT,
+    created_at DATETIME DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now', 'localtime') || '+08:00'),
+    updated_at DATETIME DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now', 'localtime') || '+08:00'),
+    UNIQUE(currency, effective_from)
+);
+INSERT INTO exchange_rates (currency, rate, effective_from) VALUES ('CNY', 7.2, '2025-01-01 00:00:00');
+`)
+	if err != nil {
+		t.Fatalf("create legacy exchange_rates: %v", err)
+	}
+
+	adapter, err := NewSQLiteAdapter(DatabaseConfig{
+		Type:         "sqlite",
+		DatabasePath: dbPath,
+		Timezone:     "Asia/Shanghai",
+	})
+	if err != nil {
+		t.Fatalf("NewSQLiteAdapter: %v", err)
+	}
+	if err := adapter.Open(); err != nil {
+		t.Fatalf("adapter.Open: %v", err)
+	}
+	t.Cleanup(func() { _ = adapter.Close() })
+
+	if err := adapter.InitSchema(); err != nil {
+		t.Fatalf("adapter.InitSchema: %v", err)
+	}
+	if !sqliteColumnExists(t, adapter.db, "exchange_rates", "channel") {
+		t.Fatal("expected exchange_rates.channel to exist after InitSchema")
+	}
+
+	// 同一币种同一生效时间：渠道汇率可与全局汇率并存，同一渠道重复则冲突
+	if _, err := adapter.db.Exec(`INSERT INTO exchange_rates (channel, currency, rate, effective_from) VALUES ('relay', 'CNY', 7.0, '2025-01-01 00:00:00')`); err != nil {
+		t.Fatalf("insert channel rate: %v", err)
+	}
+	if _, err := adapter.db.Exec(`INSERT INTO exchange_rates (currency, rate, effective_from) VALUES ('CNY', 7.1, '2025-01-01 00:00:00')`); err == nil {
+		t.Error("duplicate global rate should violate the unique index")
+	}
+
+	var global float64
+	if err := adapter.db.QueryRow(`SELECT rate FROM exchange_rates WHERE channel IS NULL AND currency = 'CNY'`).Scan(&global); err != nil || global != 7.2 {
+		t.Errorf("legacy rate = (%v, %v), want 7.2 kept as global rate", global, err)
+	}
+}
+
 func sqliteColumnExists(t *testing.T, db *sql.DB, table, column string) bool {
 	t.Helper()
 	rows, err := db.Query("PRAGMA table_info(" + table + ")")
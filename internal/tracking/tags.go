@@ -0,0 +1,313 @@
+package tracking
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+)
+
+// RequestTag 请求标签（项目/成本中心归属，来自标签请求头或 /p/<project>/ 路径前缀）
+type RequestTag struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// String 返回 key=value 形式
+func (t RequestTag) String() string {
+	return t.Key + "=" + t.Value
+}
+
+// TagUsageSummary 按标签值聚合的用量
+type TagUsageSummary struct {
+	TagKey   string `json:"tag_key"`
+	TagValue string `json:"tag_value"` // 空字符串表示未打该标签的请求
+
+	RequestCount int `json:"request_count"`
+	SuccessCount int `json:"success_count"`
+	FailedCount  int `json:"failed_count"`
+
+	InputTokens         int64   `json:"input_tokens"`
+	OutputTokens        int64   `json:"output_tokens"`
+	CacheCreationTokens int64   `json:"cache_creation_tokens"`
+	CacheReadTokens     int64   `json:"cache_read_tokens"`
+	TotalTokens         int64   `json:"total_tokens"`
+	TotalCostUSD        float64 `json:"total_cost_usd"`
+}
+
+// RecordRequestTags 记录请求标签（需在 RecordRequestStart 之后调用）
+// 热池模式下随请求归档写入 request_tags，传统模式通过事件队列写入
+func (ut *UsageTracker) RecordRequestTags(requestID string, tags []RequestTag) {
+	if ut.config == nil || !ut.config.Enabled || len(tags) == 0 {
+		return
+	}
+
+	if ut.hotPoolEnabled && ut.hotPool != nil {
+		err := ut.hotPool.Update(requestID, func(req *ActiveRequest) {
+			// 复制后再合并，避免与热池快照共享底层数组
+			req.Tags = mergeRequestTags(append([]RequestTag(nil), req.Tags...), tags)
+		})
+		if err == nil {
+			return
+		}
+		slog.Debug("🔥 热池记录请求标签失败，降级到事件队列模式",
+			"request_id", requestID,
+			"error", err)
+	}
+
+	event := RequestEvent{
+		Type:      "tags",
+		RequestID: requestID,
+		Timestamp: ut.now(),
+		Data:      tags,
+	}
+
+	select {
+	case ut.eventChan <- event:
+	default:
+		slog.Warn("Usage tracking event buffer full, dropping tags event",
+			"request_id", requestID)
+	}
+}
+
+// buildTagsQuery 构建请求标签写入查询（传统模式）
+func (ut *UsageTracker) buildTagsQuery(event RequestEvent) (string, []interface{}, error) {
+	tags, ok := event.Data.([]RequestTag)
+	if !ok || len(tags) == 0 {
+		return "", nil, fmt.Errorf("invalid tags event data type")
+	}
+
+	placeholders := make([]string, 0, len(tags))
+	args := make([]interface{}, 0, len(tags)*3)
+	for _, tag := range tags {
+		placeholders = append(placeholders, "(?, ?, ?)")
+		args = append(args, event.RequestID, tag.Key, tag.Value)
+	}
+
+	query := "INSERT OR IGNORE INTO request_tags (request_id, tag_key, tag_value) VALUES " + strings.Join(placeholders, ", ")
+	return query, args, nil
+}
+
+// tagFilterSQL 构建标签筛选条件（多个标签为 AND 关系）
+func tagFilterSQL(tags []RequestTag) (string, []interface{}) {
+	var clause strings.Builder
+	var args []interface{}
+	for _, tag := range tags {
+		if tag.Key == "" {
+			continue
+		}
+		clause.WriteString(" AND EXISTS (SELECT 1 FROM request_tags rt WHERE rt.request_id = request_logs.request_id AND rt.tag_key = ? AND rt.tag_value = ?)")
+		args = append(args, tag.Key, tag.Value)
+	}
+	return clause.String(), args
+}
+
+// hasAllTags 判断请求是否包含所有筛选标签（热池筛选）
+func hasAllTags(have, want []RequestTag) bool {
+	for _, w := range want {
+		if w.Key == "" {
+			continue
+		}
+		found := false
+		for _, h := range have {
+			if h == w {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// mergeRequestTags 合并标签并去重
+func mergeRequestTags(existing, tags []RequestTag) []RequestTag {
+	for _, tag := range tags {
+		duplicate := false
+		for _, e := range existing {
+			if e == tag {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			existing = append(existing, tag)
+		}
+	}
+	return existing
+}
+
+// loadRequestTags 为查询结果填充请求标签
+func (ut *UsageTracker) loadRequestTags(ctx context.Context, details []RequestDetail) error {
+	if len(details) == 0 {
+		return nil
+	}
+
+	index := make(map[string][]int, len(details))
+	placeholders := make([]string, 0, len(details))
+	args := make([]interface{}, 0, len(details))
+	for i, d := range details {
+		if _, ok := index[d.RequestID]; !ok {
+			placeholders = append(placeholders, "?")
+			args = append(args, d.RequestID)
+		}
+		index[d.RequestID] = append(index[d.RequestID], i)
+	}
+
+	query := `SELECT request_id, tag_key, tag_value FROM request_tags
+		WHERE request_id IN (` + strings.Join(placeholders, ", ") + `)
+		ORDER BY tag_key, tag_value`
+	rows, err := ut.readDB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to query request tags: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var requestID string
+		var tag RequestTag
+		if err := rows.Scan(&requestID, &tag.Key, &tag.Value); err != nil {
+			return fmt.Errorf("failed to scan request tag: %w", err)
+		}
+		for _, i := range index[requestID] {
+			details[i].Tags = append(details[i].Tags, tag)
+		}
+	}
+	return rows.Err()
+}
+
+// QueryTagUsage 按标签值聚合用量（tagKey 如 project；未打该标签的请求归入空值）
+func (ut *UsageTracker) QueryTagUsage(ctx context.Context, opts *QueryOptions, tagKey string) ([]TagUsageSummary, error) {
+	if ut.readDB == nil {
+		return nil, fmt.Errorf("read database not initialized")
+	}
+	tagKey = strings.TrimSpace(tagKey)
+	if tagKey == "" {
+		return nil, fmt.Errorf("tag key is required")
+	}
+
+	query := `SELECT COALESCE(t.tag_value, '') as tag_value,
+		COUNT(*) as request_count,
+		COALESCE(SUM(CASE WHEN status = 'completed' THEN 1 ELSE 0 END), 0) as success_count,
+		COALESCE(SUM(CASE WHEN status IN ('failed', 'error', 'auth_error', 'rate_limited', 'server_error', 'network_error', 'stream_error', 'timeout') THEN 1 ELSE 0 END), 0) as failed_count,
+		COALESCE(SUM(input_tokens), 0), COALESCE(SUM(output_tokens), 0),
+		COALESCE(SUM(cache_creation_tokens), 0), COALESCE(SUM(cache_read_tokens), 0),
+		COALESCE(SUM(total_cost_usd), 0.0)
+		FROM request_logs
+		LEFT JOIN request_tags t ON t.request_id = request_logs.request_id AND t.tag_key = ?
+		WHERE 1=1`
+	args := []interface{}{tagKey}
+
+	if opts != nil {
+		if opts.StartDate != nil {
+			query += " AND start_time >= ?"
+			args = append(args, ut.formatStartTimeQueryBound(*opts.StartDate))
+		}
+		if opts.EndDate != nil {
+			query += " AND start_time <= ?"
+			args = append(args, ut.formatEndTimeQueryBound(*opts.EndDate))
+		}
+		if opts.ModelName != "" {
+			query += " AND model_name = ?"
+			args = append(args, opts.ModelName)
+		}
+		if opts.Channel != "" {
+			query += " AND channel = ?"
+			args = append(args, opts.Channel)
+		}
+		if opts.EndpointName != "" {
+			query += " AND endpoint_name = ?"
+			args = append(args, opts.EndpointName)
+		}
+		if opts.SessionID != "" {
+			query += " AND session_id = ?"
+			args = append(args, opts.SessionID)
+		}
+		tagClause, tagArgs := tagFilterSQL(opts.Tags)
+		query += tagClause
+		args = append(args, tagArgs...)
+	}
+
+	query += " GROUP BY tag_value ORDER BY SUM(total_cost_usd) DESC, tag_value ASC"
+
+	rows, err := ut.readDB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tag usage: %w", err)
+	}
+	defer rows.Close()
+
+	var result []TagUsageSummary
+	for rows.Next() {
+		s := TagUsageSummary{TagKey: tagKey}
+		if err := rows.Scan(&s.TagValue, &s.RequestCount, &s.SuccessCount, &s.FailedCount,
+			&s.InputTokens, &s.OutputTokens, &s.CacheCreationTokens, &s.CacheReadTokens, &s.TotalCostUSD); err != nil {
+			return nil, fmt.Errorf("failed to scan tag usage: %w", err)
+		}
+		s.TotalTokens = s.InputTokens + s.OutputTokens + s.CacheCreationTokens + s.CacheReadTokens
+		result = append(result, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating tag usage rows: %w", err)
+	}
+	return result, nil
+}
+
+// ListRequestTags 列出已记录的标签键及其取值（since 为零值时不限时间）
+func (ut *UsageTracker) ListRequestTags(ctx context.Context, since time.Time) (map[string][]string, error) {
+	if ut.readDB == nil {
+		return nil, fmt.Errorf("read database not initialized")
+	}
+
+	query := "SELECT DISTINCT t.tag_key, t.tag_value FROM request_tags t"
+	var args []interface{}
+	if !since.IsZero() {
+		query += " JOIN request_logs r ON r.request_id = t.request_id WHERE r.start_time >= ?"
+		args = append(args, ut.formatStartTimeQueryBound(since))
+	}
+
+	rows, err := ut.readDB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list request tags: %w", err)
+	}
+	defer rows.Close()
+
+	result := make(map[string][]string)
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			return nil, fmt.Errorf("failed to scan request tag: %w", err)
+		}
+		result[key] = append(result[key], value)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for key := range result {
+		sort.Strings(result[key])
+	}
+	return result, nil
+}
+
+// formatTags 格式化标签为 key=value;key=value（CSV 导出用）
+func formatTags(tags []RequestTag) string {
+	parts := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		parts = append(parts, tag.String())
+	}
+	return strings.Join(parts, ";")
+}
+
+// insertRequestTagsTx 在归档事务中写入请求标签
+func insertRequestTagsTx(ctx context.Context, stmt *sql.Stmt, requestID string, tags []RequestTag) error {
+	for _, tag := range tags {
+		if _, err := stmt.ExecContext(ctx, requestID, tag.Key, tag.Value); err != nil {
+			return fmt.Errorf("failed to insert tag %s for request %s: %w", tag.String(), requestID, err)
+		}
+	}
+	return nil
+}
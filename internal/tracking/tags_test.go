@@ -0,0 +1,189 @@
+package tracking
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestRequestTags 测试请求标签的写入、筛选、按标签聚合与导出
+func TestRequestTags(t *testing.T) {
+	config := &Config{
+		Enabled:         true,
+		DatabasePath:    ":memory:",
+		BufferSize:      100,
+		BatchSize:       10,
+		FlushInterval:   50 * time.Millisecond,
+		MaxRetry:        3,
+		CleanupInterval: 24 * time.Hour,
+		RetentionDays:   30,
+	}
+	tracker, err := NewUsageTracker(config)
+	if err != nil {
+		t.Fatalf("Failed to create usage tracker: %v", err)
+	}
+	defer tracker.Close()
+
+	ctx := context.Background()
+	base := tracker.now().Add(-time.Hour).Truncate(time.Second)
+	rows := []struct {
+		id   string
+		cost float64
+		tags []RequestTag
+	}{
+		{"req-alpha-1", 1.0, []RequestTag{{"project", "alpha"}, {"tag", "ci"}}},
+		{"req-alpha-2", 0.5, []RequestTag{{"project", "alpha"}}},
+		{"req-beta-1", 0.25, []RequestTag{{"project", "beta"}, {"tag", "ci"}}},
+		{"req-untagged", 0.1, nil},
+	}
+	for i, r := range rows {
+		_, err := tracker.writeDB.ExecContext(ctx, `INSERT INTO request_logs (
+			request_id, start_time, channel, endpoint_name, model_name, status, input_tokens, output_tokens, total_cost_usd
+		) VALUES (?, ?, 'relay', 'relay-a', 'claude-sonnet-4', 'completed', 10, 5, ?)`,
+			r.id, base.Add(time.Duration(i)*time.Minute).Format("2006-01-02 15:04:05"), r.cost)
+		if err != nil {
+			t.Fatalf("插入测试数据失败: %v", err)
+		}
+		if len(r.tags) == 0 {
+			continue
+		}
+		// 走传统模式的标签写入查询
+		query, args, err := tracker.buildWriteQuery(RequestEvent{Type: "tags", RequestID: r.id, Data: r.tags})
+		if err != nil {
+			t.Fatalf("构建标签写入查询失败: %v", err)
+		}
+		if _, err := tracker.writeDB.ExecContext(ctx, query, args...); err != nil {
+			t.Fatalf("写入标签失败: %v", err)
+		}
+		// 重复写入应被忽略
+		if _, err := tracker.writeDB.ExecContext(ctx, query, args...); err != nil {
+			t.Fatalf("重复写入标签失败: %v", err)
+		}
+	}
+
+	details, err := tracker.QueryRequestDetails(ctx, &QueryOptions{Tags: []RequestTag{{"tag", "ci"}}})
+	if err != nil {
+		t.Fatalf("按标签查询失败: %v", err)
+	}
+	if len(details) != 2 {
+		t.Fatalf("tag=ci 应命中 2 条, got %d", len(details))
+	}
+	if details[1].RequestID != "req-alpha-1" || len(details[1].Tags) != 2 {
+		t.Errorf("查询结果应填充标签: %+v", details[1].Tags)
+	}
+
+	count, err := tracker.CountRequestDetails(ctx, &QueryOptions{Tags: []RequestTag{{"project", "alpha"}, {"tag", "ci"}}})
+	if err != nil || count != 1 {
+		t.Errorf("多个标签应为 AND 关系: count=%d err=%v", count, err)
+	}
+
+	usage, err := tracker.QueryTagUsage(ctx, nil, "project")
+	if err != nil {
+		t.Fatalf("按标签聚合失败: %v", err)
+	}
+	if len(usage) != 3 {
+		t.Fatalf("应有 alpha/beta/未打标签 三组, got %+v", usage)
+	}
+	if usage[0].TagValue != "alpha" || usage[0].RequestCount != 2 || usage[0].TotalCostUSD < 1.499 || usage[0].TotalCostUSD > 1.501 {
+		t.Errorf("alpha 聚合不符: %+v", usage[0])
+	}
+	if usage[2].TagValue != "" || usage[2].RequestCount != 1 {
+		t.Errorf("未打标签的请求应归入空值: %+v", usage[2])
+	}
+
+	filtered, err := tracker.QueryTagUsage(ctx, &QueryOptions{Tags: []RequestTag{{"tag", "ci"}}}, "project")
+	if err != nil || len(filtered) != 2 {
+		t.Errorf("标签聚合应支持标签筛选: %+v, %v", filtered, err)
+	}
+
+	tags, err := tracker.ListRequestTags(ctx, time.Time{})
+	if err != nil {
+		t.Fatalf("列出标签失败: %v", err)
+	}
+	if len(tags["project"]) != 2 || tags["project"][0] != "alpha" || len(tags["tag"]) != 1 {
+		t.Errorf("标签列表不符: %v", tags)
+	}
+
+	csv, err := tracker.ExportToCSVWithOptions(ctx, &QueryOptions{Tags: []RequestTag{{"project", "beta"}}}, "")
+	if err != nil {
+		t.Fatalf("按标签导出失败: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(csv)), "\n")
+	if len(lines) != 2 || !strings.HasSuffix(lines[0], ",tags") || !strings.HasSuffix(lines[1], "project=beta;tag=ci") {
+		t.Errorf("导出结果不符: %s", csv)
+	}
+}
+
+// TestExportCSV_EscapesClientFields 测试导出 CSV 时转义会话 ID 与标签中的逗号、引号
+func TestExportCSV_EscapesClientFields(t *testing.T) {
+	config := &Config{
+		Enabled:         true,
+		DatabasePath:    ":memory:",
+		BufferSize:      100,
+		BatchSize:       10,
+		FlushInterval:   50 * time.Millisecond,
+		MaxRetry:        3,
+		CleanupInterval: 24 * time.Hour,
+		RetentionDays:   30,
+	}
+	tracker, err := NewUsageTracker(config)
+	if err != nil {
+		t.Fatalf("Failed to create usage tracker: %v", err)
+	}
+	defer tracker.Close()
+
+	ctx := context.Background()
+	start := tracker.now().Add(-time.Hour).Truncate(time.Second).Format("2006-01-02 15:04:05")
+	if _, err := tracker.writeDB.ExecContext(ctx, `INSERT INTO request_logs (
+		request_id, start_time, channel, endpoint_name, model_name, status, session_id, total_cost_usd
+	) VALUES ('req-comma', ?, 'relay', 'relay-a', 'claude-sonnet-4', 'completed', 'sess,"x"', 0.5)`, start); err != nil {
+		t.Fatalf("插入测试数据失败: %v", err)
+	}
+	query, args, err := tracker.buildWriteQuery(RequestEvent{Type: "tags", RequestID: "req-comma", Data: []RequestTag{{"project", "a,b"}}})
+	if err != nil {
+		t.Fatalf("构建标签写入查询失败: %v", err)
+	}
+	if _, err := tracker.writeDB.ExecContext(ctx, query, args...); err != nil {
+		t.Fatalf("写入标签失败: %v", err)
+	}
+
+	data, err := tracker.ExportToCSVWithOptions(ctx, nil, "")
+	if err != nil {
+		t.Fatalf("导出失败: %v", err)
+	}
+	records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil {
+		t.Fatalf("导出结果不是合法 CSV: %v\n%s", err, data)
+	}
+	if len(records) != 2 || len(records[1]) != len(records[0]) {
+		t.Fatalf("列数应与表头一致: %q", records)
+	}
+	row := records[1]
+	if got := row[len(row)-2]; got != `sess,"x"` {
+		t.Errorf("session_id = %q, want %q", got, `sess,"x"`)
+	}
+	if got := row[len(row)-1]; got != "project=a,b" {
+		t.Errorf("tags = %q, want %q", got, "project=a,b")
+	}
+}
+
+// TestHasAllTags 测试热池标签筛选
+func TestHasAllTags(t *testing.T) {
+	have := []RequestTag{{"project", "alpha"}, {"tag", "ci"}}
+	if !hasAllTags(have, nil) {
+		t.Error("无筛选条件应匹配")
+	}
+	if !hasAllTags(have, []RequestTag{{"tag", "ci"}, {"project", "alpha"}}) {
+		t.Error("包含全部标签应匹配")
+	}
+	if hasAllTags(have, []RequestTag{{"project", "beta"}}) {
+		t.Error("标签值不同不应匹配")
+	}
+	merged := mergeRequestTags([]RequestTag{{"project", "alpha"}}, have)
+	if len(merged) != 2 {
+		t.Errorf("合并标签应去重: %v", merged)
+	}
+}
@@ -0,0 +1,276 @@
+package tracking
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// 延迟分位数分组维度
+const (
+	LatencyGroupByEndpoint = "endpoint"
+	LatencyGroupByModel    = "model"
+)
+
+// RequestTimings 请求响应时间线（毫秒，相对最后一次上游请求发出时刻；0 表示未测量）
+type RequestTimings struct {
+	TTFBMs             int64   `json:"ttfb_ms"`               // 上游响应头到达
+	FirstEventMs       int64   `json:"first_event_ms"`        // 首个 SSE 事件到达（仅流式）
+	FirstTokenMs       int64   `json:"first_token_ms"`        // 首个 content_block_delta 到达（仅流式）
+	OutputTokensPerSec float64 `json:"output_tokens_per_sec"` // 输出速率
+}
+
+// NewRequestTimings 根据各时间点计算响应时间线
+// 流式请求的输出速率按首个内容增量到结束的时间计算，非流式按整个上游请求耗时计算
+func NewRequestTimings(sentAt, headerAt, firstEventAt, firstTokenAt, endAt time.Time, outputTokens int64) RequestTimings {
+	timings := RequestTimings{
+		TTFBMs:       elapsedMs(sentAt, headerAt),
+		FirstEventMs: elapsedMs(sentAt, firstEventAt),
+		FirstTokenMs: elapsedMs(sentAt, firstTokenAt),
+	}
+
+	from := sentAt
+	if !firstTokenAt.IsZero() {
+		from = firstTokenAt
+	}
+	if outputTokens > 0 && !from.IsZero() && !endAt.IsZero() {
+		if window := endAt.Sub(from); window >= time.Millisecond {
+			timings.OutputTokensPerSec = math.Round(float64(outputTokens)/window.Seconds()*100) / 100
+		}
+	}
+	return timings
+}
+
+// IsZero 是否未测量到任何时间点
+func (t RequestTimings) IsZero() bool {
+	return t == RequestTimings{}
+}
+
+// applyTo 将已测量的时间点填充到请求详情
+func (t RequestTimings) applyTo(detail *RequestDetail) {
+	if t.TTFBMs > 0 {
+		v := t.TTFBMs
+		detail.TTFBMs = &v
+	}
+	if t.FirstEventMs > 0 {
+		v := t.FirstEventMs
+		detail.FirstEventMs = &v
+	}
+	if t.FirstTokenMs > 0 {
+		v := t.FirstTokenMs
+		detail.FirstTokenMs = &v
+	}
+	if t.OutputTokensPerSec > 0 {
+		v := t.OutputTokensPerSec
+		detail.OutputTokensPerSec = &v
+	}
+}
+
+// elapsedMs 计算两个时间点间隔的毫秒数（任一未测量时返回 0，不足 1ms 记为 1ms）
+func elapsedMs(from, to time.Time) int64 {
+	if from.IsZero() || to.IsZero() || to.Before(from) {
+		return 0
+	}
+	ms := to.Sub(from).Milliseconds()
+	if ms < 1 {
+		ms = 1
+	}
+	return ms
+}
+
+// nullableMs 0 表示未测量，写入 NULL
+func nullableMs(v int64) interface{} {
+	if v <= 0 {
+		return nil
+	}
+	return v
+}
+
+// nullableRate 0 表示未测量，写入 NULL
+func nullableRate(v float64) interface{} {
+	if v <= 0 {
+		return nil
+	}
+	return v
+}
+
+// LatencyStats 一组延迟样本的分位数
+type LatencyStats struct {
+	Count int     `json:"count"`
+	Avg   float64 `json:"avg"`
+	P50   float64 `json:"p50"`
+	P90   float64 `json:"p90"`
+	P95   float64 `json:"p95"`
+	P99   float64 `json:"p99"`
+}
+
+// LatencyPercentiles 按端点或模型分组的响应时间分位数
+type LatencyPercentiles struct {
+	Key          string `json:"key"` // 端点（channel/endpoint）或模型名称
+	RequestCount int    `json:"request_count"`
+
+	TTFBMs             LatencyStats `json:"ttfb_ms"`
+	FirstTokenMs       LatencyStats `json:"first_token_ms"`
+	DurationMs         LatencyStats `json:"duration_ms"`
+	OutputTokensPerSec LatencyStats `json:"output_tokens_per_sec"`
+}
+
+// latencySamples 分组内的原始样本
+type latencySamples struct {
+	count      int
+	ttfb       []float64
+	firstToken []float64
+	duration   []float64
+	throughput []float64
+}
+
+// QueryLatencyPercentiles 按端点或模型统计 TTFB、首 token、总耗时与输出速率的分位数（仅统计成功请求）
+func (ut *UsageTracker) QueryLatencyPercentiles(ctx context.Context, opts *QueryOptions, groupBy string) ([]LatencyPercentiles, error) {
+	if ut.readDB == nil {
+		return nil, fmt.Errorf("read database not initialized")
+	}
+
+	var keyExpr string
+	switch groupBy {
+	case LatencyGroupByEndpoint, "":
+		keyExpr = "CASE WHEN COALESCE(channel, '') = '' THEN COALESCE(endpoint_name, '') ELSE channel || '/' || COALESCE(endpoint_name, '') END"
+	case LatencyGroupByModel:
+		keyExpr = "COALESCE(model_name, '')"
+	default:
+		return nil, fmt.Errorf("unsupported latency group: %s", groupBy)
+	}
+
+	query := `SELECT ` + keyExpr + `,
+		COALESCE(ttfb_ms, 0), COALESCE(first_token_ms, 0),
+		COALESCE(duration_ms, 0), COALESCE(output_tokens_per_sec, 0)
+		FROM request_logs WHERE status = 'completed'`
+
+	var args []interface{}
+	if opts != nil {
+		if opts.StartDate != nil {
+			query += " AND start_time >= ?"
+			args = append(args, ut.formatStartTimeQueryBound(*opts.StartDate))
+		}
+		if opts.EndDate != nil {
+			query += " AND start_time <= ?"
+			args = append(args, ut.formatEndTimeQueryBound(*opts.EndDate))
+		}
+		if opts.ModelName != "" {
+			query += " AND model_name = ?"
+			args = append(args, opts.ModelName)
+		}
+		if opts.Channel != "" {
+			query += " AND channel = ?"
+			args = append(args, opts.Channel)
+		}
+		if opts.EndpointName != "" {
+			query += " AND endpoint_name = ?"
+			args = append(args, opts.EndpointName)
+		}
+		if opts.GroupName != "" {
+			query += " AND group_name = ?"
+			args = append(args, opts.GroupName)
+		}
+		if opts.SessionID != "" {
+			query += " AND session_id = ?"
+			args = append(args, opts.SessionID)
+		}
+		tagClause, tagArgs := tagFilterSQL(opts.Tags)
+		query += tagClause
+		args = append(args, tagArgs...)
+	}
+
+	rows, err := ut.readDB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query request timings: %w", err)
+	}
+	defer rows.Close()
+
+	groups := make(map[string]*latencySamples)
+	for rows.Next() {
+		var key string
+		var ttfb, firstToken, duration int64
+		var throughput float64
+		if err := rows.Scan(&key, &ttfb, &firstToken, &duration, &throughput); err != nil {
+			return nil, fmt.Errorf("failed to scan request timings: %w", err)
+		}
+		s, ok := groups[key]
+		if !ok {
+			s = &latencySamples{}
+			groups[key] = s
+		}
+		s.count++
+		if ttfb > 0 {
+			s.ttfb = append(s.ttfb, float64(ttfb))
+		}
+		if firstToken > 0 {
+			s.firstToken = append(s.firstToken, float64(firstToken))
+		}
+		if duration > 0 {
+			s.duration = append(s.duration, float64(duration))
+		}
+		if throughput > 0 {
+			s.throughput = append(s.throughput, throughput)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	result := make([]LatencyPercentiles, 0, len(groups))
+	for key, s := range groups {
+		result = append(result, LatencyPercentiles{
+			Key:                key,
+			RequestCount:       s.count,
+			TTFBMs:             computeLatencyStats(s.ttfb),
+			FirstTokenMs:       computeLatencyStats(s.firstToken),
+			DurationMs:         computeLatencyStats(s.duration),
+			OutputTokensPerSec: computeLatencyStats(s.throughput),
+		})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].RequestCount != result[j].RequestCount {
+			return result[i].RequestCount > result[j].RequestCount
+		}
+		return result[i].Key < result[j].Key
+	})
+	return result, nil
+}
+
+// computeLatencyStats 计算样本的平均值与分位数（最近秩法）
+func computeLatencyStats(values []float64) LatencyStats {
+	if len(values) == 0 {
+		return LatencyStats{}
+	}
+	sort.Float64s(values)
+
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	return LatencyStats{
+		Count: len(values),
+		Avg:   math.Round(sum/float64(len(values))*100) / 100,
+		P50:   percentileOf(values, 50),
+		P90:   percentileOf(values, 90),
+		P95:   percentileOf(values, 95),
+		P99:   percentileOf(values, 99),
+	}
+}
+
+// percentileOf 已排序样本的 p 分位数（最近秩法）
+func percentileOf(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	if rank > len(sorted) {
+		rank = len(sorted)
+	}
+	return sorted[rank-1]
+}
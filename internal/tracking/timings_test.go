@@ -0,0 +1,152 @@
+package tracking
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// TestNewRequestTimings 测试响应时间线计算
+func TestNewRequestTimings(t *testing.T) {
+	sent := time.Date(2026, 10, 18, 10, 0, 0, 0, time.UTC)
+
+	// 流式：输出速率按首个内容增量到结束计算
+	timings := NewRequestTimings(sent, sent.Add(300*time.Millisecond), sent.Add(320*time.Millisecond),
+		sent.Add(800*time.Millisecond), sent.Add(2800*time.Millisecond), 100)
+	want := RequestTimings{TTFBMs: 300, FirstEventMs: 320, FirstTokenMs: 800, OutputTokensPerSec: 50}
+	if timings != want {
+		t.Errorf("流式时间线 = %+v, want %+v", timings, want)
+	}
+
+	// 非流式：无事件时间点，输出速率按整个上游请求计算
+	timings = NewRequestTimings(sent, sent.Add(time.Second), time.Time{}, time.Time{}, sent.Add(4*time.Second), 200)
+	want = RequestTimings{TTFBMs: 1000, OutputTokensPerSec: 50}
+	if timings != want {
+		t.Errorf("非流式时间线 = %+v, want %+v", timings, want)
+	}
+
+	// 未发出请求或无输出
+	if !NewRequestTimings(time.Time{}, sent, sent, sent, sent, 10).IsZero() {
+		t.Error("未记录发出时间时不应产生时间线")
+	}
+	if NewRequestTimings(sent, sent.Add(time.Second), time.Time{}, time.Time{}, sent.Add(2*time.Second), 0).OutputTokensPerSec != 0 {
+		t.Error("无输出 token 时不应计算输出速率")
+	}
+}
+
+// TestComputeLatencyStats 测试分位数计算（最近秩法）
+func TestComputeLatencyStats(t *testing.T) {
+	values := make([]float64, 0, 100)
+	for i := 100; i >= 1; i-- {
+		values = append(values, float64(i))
+	}
+	stats := computeLatencyStats(values)
+	want := LatencyStats{Count: 100, Avg: 50.5, P50: 50, P90: 90, P95: 95, P99: 99}
+	if stats != want {
+		t.Errorf("分位数 = %+v, want %+v", stats, want)
+	}
+	if computeLatencyStats(nil) != (LatencyStats{}) {
+		t.Error("空样本应返回零值")
+	}
+}
+
+// TestQueryLatencyPercentiles 测试时间线归档与按端点/模型分组的分位数查询
+func TestQueryLatencyPercentiles(t *testing.T) {
+	config := &Config{
+		Enabled:         true,
+		DatabasePath:    ":memory:",
+		BufferSize:      100,
+		BatchSize:       10,
+		FlushInterval:   50 * time.Millisecond,
+		MaxRetry:        3,
+		CleanupInterval: 24 * time.Hour,
+		RetentionDays:   30,
+	}
+	tracker, err := NewUsageTracker(config)
+	if err != nil {
+		t.Fatalf("Failed to create usage tracker: %v", err)
+	}
+	defer tracker.Close()
+
+	ctx := context.Background()
+	now := tracker.now()
+	var events []*ArchiveEvent
+	for i := 1; i <= 10; i++ {
+		model := "claude-sonnet-4"
+		if i > 6 {
+			model = "claude-opus-4"
+		}
+		req := &ActiveRequest{
+			RequestID:    fmt.Sprintf("req-latency-%d", i),
+			StartTime:    now.Add(-time.Duration(i) * time.Minute),
+			Channel:      "relay",
+			EndpointName: "ep-a",
+			ModelName:    model,
+			Status:       "completed",
+			OutputTokens: 100,
+			DurationMs:   int64(i * 1000),
+			IsStreaming:  true,
+			Timings: RequestTimings{
+				TTFBMs:             int64(i * 100),
+				FirstEventMs:       int64(i*100 + 10),
+				FirstTokenMs:       int64(i * 200),
+				OutputTokensPerSec: float64(i * 10),
+			},
+		}
+		events = append(events, &ArchiveEvent{Request: req})
+	}
+	// 失败请求不计入分位数
+	events = append(events, &ArchiveEvent{Request: &ActiveRequest{
+		RequestID: "req-latency-failed", StartTime: now, Channel: "relay", EndpointName: "ep-a",
+		ModelName: "claude-sonnet-4", Status: "error", Timings: RequestTimings{TTFBMs: 99999},
+	}})
+	if err := tracker.archiveManager.batchInsert(events); err != nil {
+		t.Fatalf("归档失败: %v", err)
+	}
+
+	details, err := tracker.QueryRequestDetails(ctx, &QueryOptions{EndpointName: "ep-a", Limit: 20})
+	if err != nil {
+		t.Fatalf("查询明细失败: %v", err)
+	}
+	var found bool
+	for _, d := range details {
+		if d.RequestID != "req-latency-3" {
+			continue
+		}
+		found = true
+		if d.TTFBMs == nil || *d.TTFBMs != 300 || d.FirstEventMs == nil || *d.FirstEventMs != 310 ||
+			d.FirstTokenMs == nil || *d.FirstTokenMs != 600 || d.OutputTokensPerSec == nil || *d.OutputTokensPerSec != 30 {
+			t.Errorf("明细时间线不符: %+v", d)
+		}
+	}
+	if !found {
+		t.Fatal("未查询到归档的请求明细")
+	}
+
+	byEndpoint, err := tracker.QueryLatencyPercentiles(ctx, &QueryOptions{}, LatencyGroupByEndpoint)
+	if err != nil {
+		t.Fatalf("按端点查询分位数失败: %v", err)
+	}
+	if len(byEndpoint) != 1 || byEndpoint[0].Key != "relay/ep-a" || byEndpoint[0].RequestCount != 10 {
+		t.Fatalf("按端点分组结果不符: %+v", byEndpoint)
+	}
+	if ttfb := byEndpoint[0].TTFBMs; ttfb.P50 != 500 || ttfb.P90 != 900 || ttfb.P99 != 1000 {
+		t.Errorf("TTFB 分位数不符: %+v", ttfb)
+	}
+
+	byModel, err := tracker.QueryLatencyPercentiles(ctx, &QueryOptions{}, LatencyGroupByModel)
+	if err != nil {
+		t.Fatalf("按模型查询分位数失败: %v", err)
+	}
+	if len(byModel) != 2 || byModel[0].Key != "claude-sonnet-4" || byModel[0].RequestCount != 6 {
+		t.Fatalf("按模型分组结果不符: %+v", byModel)
+	}
+	if tp := byModel[1].OutputTokensPerSec; tp.Count != 4 || tp.P50 != 80 {
+		t.Errorf("输出速率分位数不符: %+v", tp)
+	}
+
+	if _, err := tracker.QueryLatencyPercentiles(ctx, nil, "group"); err == nil {
+		t.Error("不支持的分组应返回错误")
+	}
+}
@@ -0,0 +1,206 @@
+package tracking
+
+import (
+	"context"
+	"database/sql"
+	"math"
+	"testing"
+	"time"
+)
+
+// newTokenUpdateTracker 创建用于 Token 更新测试的跟踪器
+func newTokenUpdateTracker(t *testing.T) *UsageTracker {
+	t.Helper()
+	tracker, err := NewUsageTracker(&Config{
+		Enabled:           true,
+		DatabasePath:      ":memory:",
+		BufferSize:        100,
+		BatchSize:         10,
+		FlushInterval:     50 * time.Millisecond,
+		MaxRetry:          3,
+		CleanupInterval:   24 * time.Hour,
+		RetentionDays:     30,
+		ReportingCurrency: "EUR",
+		ModelPricing: map[string]ModelPricing{
+			"claude-sonnet-4": {Input: 3, Output: 15},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create usage tracker: %v", err)
+	}
+	t.Cleanup(func() { tracker.Close() })
+	return tracker
+}
+
+// insertTokenUpdateRequest 写入一条已归档（无 Token）的请求记录
+func insertTokenUpdateRequest(t *testing.T, tracker *UsageTracker, requestID, channel string, start time.Time) {
+	t.Helper()
+	_, err := tracker.writeDB.ExecContext(context.Background(), `INSERT INTO request_logs (
+		request_id, start_time, channel, endpoint_name, model_name, status, duration_ms
+	) VALUES (?, ?, ?, 'api', 'claude-sonnet-4', 'error', 100)`,
+		requestID, start.Format("2006-01-02 15:04:05"), channel)
+	if err != nil {
+		t.Fatalf("插入测试数据失败: %v", err)
+	}
+}
+
+// TestFailedRequestTokens_EndpointMultiplierAndCurrency 测试失败请求 Token 按端点倍率计价并写入报表币种金额
+func TestFailedRequestTokens_EndpointMultiplierAndCurrency(t *testing.T) {
+	tracker := newTokenUpdateTracker(t)
+	loc := tracker.location
+	if loc == nil {
+		loc = time.Local
+	}
+	start := time.Date(2025, 3, 1, 10, 0, 0, 0, loc)
+
+	tracker.UpdateEndpointMultipliers(map[string]EndpointMultiplier{
+		EndpointMultiplierKey("relay", "api"): {CostMultiplier: 0.5},
+	})
+	tracker.UpdateExchangeRates([]ExchangeRate{{Currency: "EUR", Rate: 0.9, EffectiveFrom: time.Unix(0, 0)}})
+	insertTokenUpdateRequest(t, tracker, "req-failed-tokens", "relay", start)
+
+	err := tracker.processBatch([]RequestEvent{{
+		Type:      "failed_request_tokens",
+		RequestID: "req-failed-tokens",
+		Timestamp: start,
+		Data: RequestCompleteData{
+			ModelName:   "claude-sonnet-4",
+			InputTokens: 1000000,
+			Duration:    2 * time.Second,
+		},
+	}})
+	if err != nil {
+		t.Fatalf("处理失败请求 Token 事件失败: %v", err)
+	}
+
+	var (
+		status, reportingCurrency string
+		totalCost                 float64
+		reportingCost             sql.NullFloat64
+		durationMs                int64
+	)
+	if err := tracker.readDB.QueryRow(`SELECT status, total_cost_usd, reporting_currency, reporting_cost, duration_ms
+		FROM request_logs WHERE request_id = 'req-failed-tokens'`).Scan(
+		&status, &totalCost, &reportingCurrency, &reportingCost, &durationMs); err != nil {
+		t.Fatalf("查询更新结果失败: %v", err)
+	}
+	if status != "error" || durationMs != 2000 {
+		t.Errorf("状态/耗时 = (%s, %d), want (error, 2000)", status, durationMs)
+	}
+	// $3 × 0.5 倍率 = $1.5，报表币种 EUR × 0.9 = 1.35
+	if math.Abs(totalCost-1.5) > 1e-9 {
+		t.Errorf("总成本 = %v, want 1.5（应用端点倍率）", totalCost)
+	}
+	if reportingCurrency != "EUR" || !reportingCost.Valid || math.Abs(reportingCost.Float64-1.35) > 1e-9 {
+		t.Errorf("报表币种成本 = (%s, %+v), want (EUR, 1.35)", reportingCurrency, reportingCost)
+	}
+}
+
+// TestTokenRecovery_PriceBook 测试已归档请求的 Token 恢复按渠道价目表计价
+func TestTokenRecovery_PriceBook(t *testing.T) {
+	tracker := newTokenUpdateTracker(t)
+	loc := tracker.location
+	if loc == nil {
+		loc = time.Local
+	}
+	start := time.Date(2025, 3, 1, 10, 0, 0, 0, loc)
+
+	tracker.UpdatePriceBooks([]PriceBook{{
+		Name:    "relay-book",
+		Channel: "relay",
+		Mode:    PriceBookModeAbsolute,
+		Entries: []PriceBookEntry{{Model: "claude-sonnet-4", Input: 1, Output: 5, PerRequestFee: 0.01}},
+	}})
+	insertTokenUpdateRequest(t, tracker, "req-recovery-book", "relay", start)
+	insertTokenUpdateRequest(t, tracker, "req-recovery-official", "official", start)
+
+	var events []RequestEvent
+	for _, id := range []string{"req-recovery-book", "req-recovery-official"} {
+		events = append(events, RequestEvent{
+			Type:      "token_recovery",
+			RequestID: id,
+			Timestamp: start,
+			Data:      RequestCompleteData{ModelName: "claude-sonnet-4", InputTokens: 1000000, OutputTokens: 100000},
+		})
+	}
+	if err := tracker.processBatch(events); err != nil {
+		t.Fatalf("处理 Token 恢复事件失败: %v", err)
+	}
+
+	tests := []struct {
+		requestID string
+		cost      float64
+		source    string
+	}{
+		{"req-recovery-book", 1 + 0.5 + 0.01, PriceSourcePriceBookPrefix + "relay-book"},
+		{"req-recovery-official", 3 + 1.5, PriceSourceModelPricing},
+	}
+	for _, tt := range tests {
+		var (
+			totalCost  float64
+			source     string
+			durationMs int64
+		)
+		if err := tracker.readDB.QueryRow(`SELECT total_cost_usd, price_source, duration_ms FROM request_logs WHERE request_id = ?`,
+			tt.requestID).Scan(&totalCost, &source, &durationMs); err != nil {
+			t.Fatalf("查询 %s 失败: %v", tt.requestID, err)
+		}
+		if math.Abs(totalCost-tt.cost) > 1e-9 || source != tt.source {
+			t.Errorf("%s 成本 = (%v, %s), want (%v, %s)", tt.requestID, totalCost, source, tt.cost, tt.source)
+		}
+		if durationMs != 100 {
+			t.Errorf("%s Token 恢复不应修改耗时, got %d", tt.requestID, durationMs)
+		}
+	}
+}
+
+// TestTokenRecovery_PricingHistoryAndRollups 测试 Token 恢复按请求时生效的定价版本计价，并同步更新用量汇总
+func TestTokenRecovery_PricingHistoryAndRollups(t *testing.T) {
+	tracker := newTokenUpdateTracker(t)
+	loc := tracker.location
+	if loc == nil {
+		loc = time.Local
+	}
+	start := time.Date(2025, 3, 1, 10, 0, 0, 0, loc)
+
+	// 请求之后价格翻倍：迟到的 Token 仍按请求时的价格计价
+	tracker.UpdatePricingHistory([]PricingVersion{
+		{Model: "claude-sonnet-4", EffectiveFrom: time.Unix(0, 0), Pricing: ModelPricing{Input: 3, Output: 15}},
+		{Model: "claude-sonnet-4", EffectiveFrom: time.Date(2025, 4, 1, 0, 0, 0, 0, loc), Pricing: ModelPricing{Input: 6, Output: 30}},
+	})
+	insertTokenUpdateRequest(t, tracker, "req-recovery-history", "official", start)
+
+	ctx := context.Background()
+	if err := tracker.RebuildUsageRollups(ctx, time.Time{}, time.Time{}); err != nil {
+		t.Fatalf("重建用量汇总失败: %v", err)
+	}
+
+	if err := tracker.processBatch([]RequestEvent{{
+		Type:      "token_recovery",
+		RequestID: "req-recovery-history",
+		Timestamp: start,
+		Data:      RequestCompleteData{ModelName: "claude-sonnet-4", InputTokens: 1000000},
+	}}); err != nil {
+		t.Fatalf("处理 Token 恢复事件失败: %v", err)
+	}
+
+	var totalCost float64
+	if err := tracker.readDB.QueryRow(`SELECT total_cost_usd FROM request_logs WHERE request_id = 'req-recovery-history'`).Scan(&totalCost); err != nil {
+		t.Fatalf("查询恢复结果失败: %v", err)
+	}
+	if math.Abs(totalCost-3) > 1e-9 {
+		t.Errorf("总成本 = %v, want 3（请求时生效的定价版本）", totalCost)
+	}
+
+	for _, table := range []string{"usage_rollup_hourly", "usage_rollup_daily"} {
+		var requests, inputTokens int64
+		var cost float64
+		if err := tracker.readDB.QueryRow(`SELECT COALESCE(SUM(request_count), 0), COALESCE(SUM(input_tokens), 0), COALESCE(SUM(total_cost_usd), 0) FROM `+table).Scan(
+			&requests, &inputTokens, &cost); err != nil {
+			t.Fatalf("查询 %s 失败: %v", table, err)
+		}
+		if requests != 1 || inputTokens != 1000000 || math.Abs(cost-3) > 1e-9 {
+			t.Errorf("%s = (%d, %d, %v), want (1, 1000000, 3)", table, requests, inputTokens, cost)
+		}
+	}
+}
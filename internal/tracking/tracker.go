@@ -1,9 +1,11 @@
 package tracking
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
 	"embed"
+	"encoding/csv"
 	"encoding/json"
 	"fmt"
 	"log/slog"
@@ -12,6 +14,7 @@ import (
 	"runtime"
 	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"cc-forwarder/config"
@@ -53,7 +56,7 @@ type GroupStat struct {
 
 // RequestEvent 表示请求事件
 type RequestEvent struct {
-	Type      string      `json:"type"` // "start", "flexible_update", "success", "final_failure", "complete", "failed_request_tokens", "token_recovery"
+	Type      string      `json:"type"` // "start", "flexible_update", "success", "final_failure", "complete", "failed_request_tokens", "token_recovery", "tags", "attempt"
 	RequestID string      `json:"request_id"`
 	Timestamp time.Time   `json:"timestamp"`
 	Data      interface{} `json:"data"` // 根据Type不同而变化
@@ -90,6 +93,8 @@ type RequestCompleteData struct {
 	CacheCreation5mTokens int64         `json:"cache_creation_5m_tokens"` // v5.0.1+
 	CacheCreation1hTokens int64         `json:"cache_creation_1h_tokens"` // v5.0.1+
 	CacheReadTokens       int64         `json:"cache_read_tokens"`
+	WebSearchRequests     int64         `json:"web_search_requests"`
+	WebFetchRequests      int64         `json:"web_fetch_requests"`
 	Duration              time.Duration `json:"duration"`
 	FailureReason         string        `json:"failure_reason,omitempty"` // 可选：失败原因
 }
@@ -102,6 +107,10 @@ type TokenUsage struct {
 	CacheReadTokens       int64
 	CacheCreation5mTokens int64 // 5分钟缓存创建 tokens (1.25x 定价)
 	CacheCreation1hTokens int64 // 1小时缓存创建 tokens (2x 定价)
+
+	// 服务端工具调用次数（usage.server_tool_use，按次计费）
+	WebSearchRequests int64
+	WebFetchRequests  int64
 }
 
 // ModelPricing 模型定价配置
@@ -111,6 +120,11 @@ type ModelPricing struct {
 	CacheCreation   float64 `yaml:"cache_creation"`    // per 1M tokens (5分钟缓存创建，1.25x input)
 	CacheCreation1h float64 `yaml:"cache_creation_1h"` // per 1M tokens (1小时缓存创建，2x input)
 	CacheRead       float64 `yaml:"cache_read"`        // per 1M tokens (缓存读取)
+	WebSearch       float64 `yaml:"web_search"`        // per 1K requests (服务端 web search)
+	WebFetch        float64 `yaml:"web_fetch"`         // per 1K requests (服务端 web fetch)
+
+	// 长上下文分档定价（按提示总 token 选择，未命中任何档位时使用上述基础价格）
+	Tiers []PricingTier `yaml:"tiers"`
 }
 
 // EndpointMultiplier 端点成本倍率（v5.0+ 支持端点级别的成本调整）
@@ -144,7 +158,13 @@ type CostBreakdown struct {
 	CacheCreation5mCost float64 // 5分钟缓存创建成本
 	CacheCreation1hCost float64 // 1小时缓存创建成本
 	CacheReadCost       float64
+	WebSearchCost       float64 // 服务端 web search 按次成本
+	WebFetchCost        float64 // 服务端 web fetch 按次成本
+	ServerToolCost      float64 // 服务端工具总成本 (web search + web fetch)
 	TotalCost           float64
+	PricingTier         int64   // 生效的长上下文档位阈值（0 表示基础价格）
+	RequestFee          float64 // 价目表按次固定费用（已计入 TotalCost）
+	PriceSource         string  // 价格来源（model_pricing / default_pricing / price_book:<名称>）
 }
 
 // CalculateCostV2 统一的成本计算函数（v5.0+ 支持分开的缓存定价）
@@ -152,13 +172,17 @@ type CostBreakdown struct {
 //
 // 参数:
 //   - usage: Token 使用量（包含分开的 5m/1h 缓存 tokens）
-//   - pricing: 模型定价，nil 时返回零成本
+//   - pricing: 模型定价（含长上下文档位，按提示总 token 选择），nil 时返回零成本
 //   - multiplier: 端点倍率，nil 时使用默认倍率 1.0
 func CalculateCostV2(usage *TokenUsage, pricing *ModelPricing, multiplier *EndpointMultiplier) CostBreakdown {
 	if pricing == nil || usage == nil {
 		return CostBreakdown{}
 	}
 
+	// 长上下文分档：按提示总 token 选择生效价格
+	effective, pricingTier := pricing.SelectTier(usage.PromptTokens())
+	pricing = &effective
+
 	// 使用默认倍率
 	var m EndpointMultiplier
 	if multiplier != nil {
@@ -199,6 +223,10 @@ func CalculateCostV2(usage *TokenUsage, pricing *ModelPricing, multiplier *Endpo
 	}
 	cacheCreationCost := cache5mCost + cache1hCost
 
+	// 服务端工具按次计费（每千次）
+	webSearchCost := float64(usage.WebSearchRequests) * pricing.WebSearch / 1_000
+	webFetchCost := float64(usage.WebFetchRequests) * pricing.WebFetch / 1_000
+
 	// 应用端点倍率
 	if m.CostMultiplier > 0 {
 		// 总体倍率模式：所有成本统一乘以总体倍率
@@ -209,6 +237,8 @@ func CalculateCostV2(usage *TokenUsage, pricing *ModelPricing, multiplier *Endpo
 		cache1hCost *= totalMultiplier
 		cacheCreationCost *= totalMultiplier
 		cacheReadCost *= totalMultiplier
+		webSearchCost *= totalMultiplier
+		webFetchCost *= totalMultiplier
 	} else {
 		// 分项倍率模式：各项成本分别乘以对应倍率
 		inputCost *= ensureMultiplier(m.InputCostMultiplier)
@@ -217,7 +247,9 @@ func CalculateCostV2(usage *TokenUsage, pricing *ModelPricing, multiplier *Endpo
 		cache5mCost *= ensureMultiplier(m.CacheCreationCostMultiplier)
 		cache1hCost *= ensureMultiplier(m.CacheCreationCostMultiplier1h)
 		cacheCreationCost = cache5mCost + cache1hCost
+		// 服务端工具没有分项倍率，分项模式下按原价计算
 	}
+	serverToolCost := webSearchCost + webFetchCost
 
 	return CostBreakdown{
 		InputCost:           inputCost,
@@ -226,7 +258,11 @@ func CalculateCostV2(usage *TokenUsage, pricing *ModelPricing, multiplier *Endpo
 		CacheCreation5mCost: cache5mCost,
 		CacheCreation1hCost: cache1hCost,
 		CacheReadCost:       cacheReadCost,
-		TotalCost:           inputCost + outputCost + cacheCreationCost + cacheReadCost,
+		WebSearchCost:       webSearchCost,
+		WebFetchCost:        webFetchCost,
+		ServerToolCost:      serverToolCost,
+		TotalCost:           inputCost + outputCost + cacheCreationCost + cacheReadCost + serverToolCost,
+		PricingTier:         pricingTier,
 	}
 }
 
@@ -276,6 +312,9 @@ type Config struct {
 	ModelPricing    map[string]ModelPricing `yaml:"model_pricing"`
 	DefaultPricing  ModelPricing            `yaml:"default_pricing"`
 
+	// 统一报表币种（成本额外按该币种换算存储，默认 USD）
+	ReportingCurrency string `yaml:"reporting_currency"`
+
 	// 🔥 v4.1 新增：热池配置
 	HotPool *HotPoolSettings `yaml:"hot_pool,omitempty"`
 }
@@ -311,6 +350,12 @@ type UpdateOptions struct {
 	EndTime       *time.Time     // 结束时间
 	Duration      *time.Duration // 持续时间
 	FailureReason *string        // 失败原因（用于中间过程记录）
+	SessionID     *string        // 客户端会话标识
+
+	Timings *RequestTimings // 响应时间线（TTFB / 首 token / 输出速率）
+
+	RequestParams *RequestParams // 请求参数（max_tokens / 消息数 / 工具数 / 思考预算 / 温度 / 请求大小）
+	ResponseMeta  *ResponseMeta  // 响应元信息（停止原因 / 消息 ID / 工具调用 / thinking）
 }
 
 // UsageTracker 使用跟踪器
@@ -344,6 +389,28 @@ type UsageTracker struct {
 	hotPool        *HotPool        // 内存热池（活跃请求）
 	archiveManager *ArchiveManager // 归档管理器（批量写入）
 	hotPoolEnabled bool            // 是否启用热池模式
+
+	// 多币种记账：渠道结算币种 + 汇率（更新时重建 currency 快照）
+	channelCurrencies map[string]string
+	exchangeRates     []ExchangeRate
+	currency          *CurrencyAccounting
+
+	// 渠道/端点价目表（优先于模型定价）
+	priceBooks *PriceBookSet
+
+	// 模型定价历史版本（按请求开始时间选取生效版本）
+	pricingHistory *PricingHistory
+
+	// 花费预算（定期评估，超限时告警/阻断）
+	budgets     *budgetState
+	budgetsOnce sync.Once
+
+	// 小时/日用量汇总表是否可用于查询（热池模式下启动校验通过后置位）
+	rollupsReady atomic.Bool
+
+	// 数据库写入失败回调（用于外部告警）
+	dbErrorHandler func(operation string, err error)
+	dbErrorMu      sync.RWMutex
 }
 
 // NewUsageTracker 创建新的使用跟踪器
@@ -459,9 +526,19 @@ func NewUsageTracker(config *Config, globalTimezone ...string) (*UsageTracker, e
 	ut.wg.Add(1)
 	go ut.periodicBackup()
 
+	// 启动预算定期评估
+	ut.wg.Add(1)
+	go ut.periodicBudgetCheck()
+
 	// 🔥 v4.1 初始化热池架构
 	ut.initHotPool()
 
+	// 校验用量汇总表（仅热池归档路径维护汇总表）
+	if ut.hotPoolEnabled {
+		ut.wg.Add(1)
+		go ut.initUsageRollups()
+	}
+
 	slog.Info("✅ 使用跟踪器初始化完成",
 		"database_type", adapter.GetDatabaseType(),
 		"buffer_size", config.BufferSize,
@@ -510,9 +587,14 @@ func (ut *UsageTracker) initHotPool() {
 		MaxRetry:      ut.config.MaxRetry,
 	}
 	ut.archiveManager = NewArchiveManager(ut.adapter, archiveConfig, ut.pricing, ut.location)
+	ut.archiveManager.UpdateCurrencyAccounting(ut.currencyAccounting())
+	ut.archiveManager.UpdatePriceBooks(ut.priceBooks)
+	ut.archiveManager.UpdatePricingHistory(ut.pricingHistory)
+	ut.archiveManager.UpdateDefaultPricing(ut.config.DefaultPricing)
 
 	// 设置双向引用：ArchiveManager 需要访问 HotPool 来清理归档缓存
 	ut.archiveManager.SetHotPool(ut.hotPool)
+	ut.archiveManager.SetErrorHandler(ut.reportDatabaseError)
 
 	// 设置热池归档回调
 	ut.hotPool.SetArchiveCallback(func(req *ActiveRequest) {
@@ -794,6 +876,18 @@ func (ut *UsageTracker) RecordRequestUpdate(requestID string, opts UpdateOptions
 			if opts.FailureReason != nil {
 				req.FailureReason = *opts.FailureReason
 			}
+			if opts.SessionID != nil {
+				req.SessionID = *opts.SessionID
+			}
+			if opts.Timings != nil {
+				req.Timings = *opts.Timings
+			}
+			if opts.RequestParams != nil {
+				req.RequestParams = *opts.RequestParams
+			}
+			if opts.ResponseMeta != nil {
+				req.ResponseMeta = *opts.ResponseMeta
+			}
 		})
 		if err != nil {
 			// 请求可能不在热池中（已归档或从未记录），降级到传统模式
@@ -875,6 +969,10 @@ func (ut *UsageTracker) RecordRequestSuccessWithQuality(requestID, modelName str
 			req.CacheCreation5mTokens = cacheCreation5mTokens // v5.0.1+
 			req.CacheCreation1hTokens = cacheCreation1hTokens // v5.0.1+
 			req.CacheReadTokens = cacheReadTokens
+			if tokens != nil {
+				req.WebSearchRequests = tokens.WebSearchRequests
+				req.WebFetchRequests = tokens.WebFetchRequests
+			}
 			req.EndTime = &now
 			req.DurationMs = duration.Milliseconds()
 			// 🔧 [方案A实现] 2025-12-20: 显式覆盖 failureReason（无论是否为空）
@@ -968,6 +1066,8 @@ func (ut *UsageTracker) RecordRequestFinalFailure(requestID, modelName, status,
 				req.CacheCreation5mTokens = cacheCreation5mTokens
 				req.CacheCreation1hTokens = cacheCreation1hTokens
 				req.CacheReadTokens = cacheReadTokens
+				req.WebSearchRequests = tokens.WebSearchRequests
+				req.WebFetchRequests = tokens.WebFetchRequests
 			}
 			req.EndTime = &now
 			req.DurationMs = duration.Milliseconds()
@@ -1045,6 +1145,8 @@ func (ut *UsageTracker) RecordFailedRequestTokens(requestID, modelName string, t
 			req.CacheCreation5mTokens = tokens.CacheCreation5mTokens
 			req.CacheCreation1hTokens = tokens.CacheCreation1hTokens
 			req.CacheReadTokens = tokens.CacheReadTokens
+			req.WebSearchRequests = tokens.WebSearchRequests
+			req.WebFetchRequests = tokens.WebFetchRequests
 			// 更新模型名（如果有）
 			if modelName != "" && modelName != "unknown" {
 				req.ModelName = modelName
@@ -1081,6 +1183,8 @@ func (ut *UsageTracker) RecordFailedRequestTokens(requestID, modelName string, t
 			CacheCreation5mTokens: tokens.CacheCreation5mTokens,
 			CacheCreation1hTokens: tokens.CacheCreation1hTokens,
 			CacheReadTokens:       tokens.CacheReadTokens,
+			WebSearchRequests:     tokens.WebSearchRequests,
+			WebFetchRequests:      tokens.WebFetchRequests,
 			Duration:              duration,
 			FailureReason:         failureReason,
 		},
@@ -1112,6 +1216,8 @@ func (ut *UsageTracker) RecoverRequestTokens(requestID, modelName string, tokens
 			req.CacheCreation5mTokens = tokens.CacheCreation5mTokens
 			req.CacheCreation1hTokens = tokens.CacheCreation1hTokens
 			req.CacheReadTokens = tokens.CacheReadTokens
+			req.WebSearchRequests = tokens.WebSearchRequests
+			req.WebFetchRequests = tokens.WebFetchRequests
 			// 更新模型名（如果有）
 			if modelName != "" && modelName != "unknown" {
 				req.ModelName = modelName
@@ -1140,6 +1246,8 @@ func (ut *UsageTracker) RecoverRequestTokens(requestID, modelName string, tokens
 			CacheCreation5mTokens: tokens.CacheCreation5mTokens,
 			CacheCreation1hTokens: tokens.CacheCreation1hTokens,
 			CacheReadTokens:       tokens.CacheReadTokens,
+			WebSearchRequests:     tokens.WebSearchRequests,
+			WebFetchRequests:      tokens.WebFetchRequests,
 			Duration:              0, // 不更新时间相关字段
 		},
 	}
@@ -1468,16 +1576,36 @@ func (ut *UsageTracker) GetUsageStats(ctx context.Context, startTime, endTime ti
 }
 
 // ExportToCSV 导出为CSV格式
-func (ut *UsageTracker) ExportToCSV(ctx context.Context, startTime, endTime time.Time, modelName, endpointName, groupName string) ([]byte, error) {
-	logs, err := ut.GetRequestLogs(ctx, startTime, endTime, modelName, endpointName, groupName, 10000, 0) // Export up to 10k records
+// displayCurrency 可选：额外输出的展示币种成本（默认使用报表币种）
+func (ut *UsageTracker) ExportToCSV(ctx context.Context, startTime, endTime time.Time, modelName, endpointName, groupName string, displayCurrency ...string) ([]byte, error) {
+	return ut.ExportToCSVWithOptions(ctx, exportQueryOptions(startTime, endTime, modelName, endpointName, groupName), firstString(displayCurrency))
+}
+
+// ExportToCSVWithOptions 按查询条件导出为CSV格式（支持会话、标签等筛选，未指定 Limit 时最多导出 10k 条）
+func (ut *UsageTracker) ExportToCSVWithOptions(ctx context.Context, opts *QueryOptions, displayCurrency string) ([]byte, error) {
+	logs, err := ut.QueryRequestDetails(ctx, withExportLimit(opts))
 	if err != nil {
 		return nil, fmt.Errorf("failed to get request logs for CSV export: %w", err)
 	}
+	if err := ut.fillDisplayCost(logs, displayCurrency); err != nil {
+		return nil, err
+	}
 
-	// CSV header
-	csv := "request_id,client_ip,user_agent,method,path,start_time,end_time,duration_ms,channel,endpoint_name,group_name,model_name,status,http_status_code,retry_count,input_tokens,output_tokens,cache_creation_tokens,cache_read_tokens,input_cost_usd,output_cost_usd,cache_creation_cost_usd,cache_read_cost_usd,total_cost_usd,created_at,updated_at\n"
+	// 使用 encoding/csv 写出，会话 ID、标签等客户端可控字段中的逗号、引号与换行会被正确转义
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	header := []string{
+		"request_id", "client_ip", "user_agent", "method", "path", "start_time", "end_time", "duration_ms",
+		"channel", "endpoint_name", "group_name", "model_name", "status", "http_status_code", "retry_count",
+		"input_tokens", "output_tokens", "cache_creation_tokens", "cache_read_tokens",
+		"input_cost_usd", "output_cost_usd", "cache_creation_cost_usd", "cache_read_cost_usd", "total_cost_usd",
+		"billing_currency", "billing_cost", "reporting_currency", "reporting_cost", "display_currency", "display_cost",
+		"created_at", "updated_at", "session_id", "tags",
+	}
+	if err := w.Write(header); err != nil {
+		return nil, fmt.Errorf("failed to write CSV header: %w", err)
+	}
 
-	// CSV rows
 	for _, log := range logs {
 		endTime := ""
 		if log.EndTime != nil {
@@ -1494,26 +1622,48 @@ func (ut *UsageTracker) ExportToCSV(ctx context.Context, startTime, endTime time
 			httpStatus = fmt.Sprintf("%d", *log.HTTPStatusCode)
 		}
 
-		csv += fmt.Sprintf("%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%d,%d,%d,%d,%d,%.6f,%.6f,%.6f,%.6f,%.6f,%s,%s\n",
+		record := []string{
 			log.RequestID, log.ClientIP, log.UserAgent, log.Method, log.Path,
 			log.StartTime.Format(time.RFC3339), endTime, durationMs,
 			log.Channel, log.EndpointName, log.GroupName, log.ModelName, log.Status,
-			httpStatus, log.RetryCount,
-			log.InputTokens, log.OutputTokens, log.CacheCreationTokens, log.CacheReadTokens,
-			log.InputCostUSD, log.OutputCostUSD, log.CacheCreationCostUSD, log.CacheReadCostUSD, log.TotalCostUSD,
+			httpStatus, fmt.Sprintf("%d", log.RetryCount),
+			fmt.Sprintf("%d", log.InputTokens), fmt.Sprintf("%d", log.OutputTokens),
+			fmt.Sprintf("%d", log.CacheCreationTokens), fmt.Sprintf("%d", log.CacheReadTokens),
+			fmt.Sprintf("%.6f", log.InputCostUSD), fmt.Sprintf("%.6f", log.OutputCostUSD),
+			fmt.Sprintf("%.6f", log.CacheCreationCostUSD), fmt.Sprintf("%.6f", log.CacheReadCostUSD),
+			fmt.Sprintf("%.6f", log.TotalCostUSD),
+			log.BillingCurrency, formatOptionalCost(log.BillingCost), log.ReportingCurrency, formatOptionalCost(log.ReportingCost),
+			log.DisplayCurrency, fmt.Sprintf("%.6f", log.DisplayCost),
 			log.CreatedAt.Format(time.RFC3339), log.UpdatedAt.Format(time.RFC3339),
-		)
+			log.SessionID, formatTags(log.Tags),
+		}
+		if err := w.Write(record); err != nil {
+			return nil, fmt.Errorf("failed to write CSV row: %w", err)
+		}
 	}
 
-	return []byte(csv), nil
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, fmt.Errorf("failed to flush CSV: %w", err)
+	}
+	return buf.Bytes(), nil
 }
 
 // ExportToJSON 导出为JSON格式
-func (ut *UsageTracker) ExportToJSON(ctx context.Context, startTime, endTime time.Time, modelName, endpointName, groupName string) ([]byte, error) {
-	logs, err := ut.GetRequestLogs(ctx, startTime, endTime, modelName, endpointName, groupName, 10000, 0) // Export up to 10k records
+// displayCurrency 可选：额外输出的展示币种成本（默认使用报表币种）
+func (ut *UsageTracker) ExportToJSON(ctx context.Context, startTime, endTime time.Time, modelName, endpointName, groupName string, displayCurrency ...string) ([]byte, error) {
+	return ut.ExportToJSONWithOptions(ctx, exportQueryOptions(startTime, endTime, modelName, endpointName, groupName), firstString(displayCurrency))
+}
+
+// ExportToJSONWithOptions 按查询条件导出为JSON格式（支持会话、标签等筛选，未指定 Limit 时最多导出 10k 条）
+func (ut *UsageTracker) ExportToJSONWithOptions(ctx context.Context, opts *QueryOptions, displayCurrency string) ([]byte, error) {
+	logs, err := ut.QueryRequestDetails(ctx, withExportLimit(opts))
 	if err != nil {
 		return nil, fmt.Errorf("failed to get request logs for JSON export: %w", err)
 	}
+	if err := ut.fillDisplayCost(logs, displayCurrency); err != nil {
+		return nil, err
+	}
 
 	// 使用标准库的json包序列化
 	jsonBytes, err := json.Marshal(logs)
@@ -1524,6 +1674,32 @@ func (ut *UsageTracker) ExportToJSON(ctx context.Context, startTime, endTime tim
 	return jsonBytes, nil
 }
 
+// exportQueryOptions 构建导出查询条件
+func exportQueryOptions(startTime, endTime time.Time, modelName, endpointName, groupName string) *QueryOptions {
+	return &QueryOptions{
+		StartDate:    &startTime,
+		EndDate:      &endTime,
+		ModelName:    modelName,
+		EndpointName: endpointName,
+		GroupName:    groupName,
+	}
+}
+
+// maxExportRecords 单次导出的最大记录数
+const maxExportRecords = 10000
+
+// withExportLimit 未指定 Limit 时限制导出条数（返回副本，不修改调用方的查询条件）
+func withExportLimit(opts *QueryOptions) *QueryOptions {
+	limited := QueryOptions{}
+	if opts != nil {
+		limited = *opts
+	}
+	if limited.Limit <= 0 || limited.Limit > maxExportRecords {
+		limited.Limit = maxExportRecords
+	}
+	return &limited
+}
+
 // processWriteQueue 启动写操作队列处理器（简化版，确保稳定性）
 func (ut *UsageTracker) processWriteQueue() {
 	ut.writeWg.Add(1)
@@ -1793,37 +1969,20 @@ func (ut *UsageTracker) ActiveRequestToDetail(req *ActiveRequest) RequestDetail
 		httpStatus = &req.HTTPStatus
 	}
 
-	// 计算成本（使用公共函数消除重复代码）
-	var cost CostBreakdown
-	if ut.pricing != nil {
-		pricing, exists := ut.pricing[req.ModelName]
-		if !exists {
-			pricing, exists = ut.pricing["_default"]
-		}
-		if exists {
-			// 获取端点倍率
-			var multiplier *EndpointMultiplier
-			if ut.endpointMu != nil {
-				if key := endpointMultiplierKey(req.Channel, req.GroupName, req.EndpointName); key != "" {
-					if m, ok := ut.endpointMu[key]; ok {
-						multiplier = &m
-					}
-				} else if m, ok := ut.endpointMu[req.EndpointName]; ok {
-					// 兼容旧 key：endpointName 作为唯一键
-					multiplier = &m
-				}
-			}
-
-			// 调用公共成本计算函数
-			// TODO: 需要从请求中识别缓存类型（5分钟 vs 1小时），暂时默认使用 5 分钟倍率
-			cost = CalculateCost(
-				req.InputTokens, req.OutputTokens, req.CacheCreationTokens, req.CacheReadTokens,
-				&pricing, multiplier, false,
-			)
-		}
+	// 计算成本（与归档共用计价规则，热池展示成本与落库成本一致）
+	usage := &TokenUsage{
+		InputTokens:           req.InputTokens,
+		OutputTokens:          req.OutputTokens,
+		CacheCreationTokens:   req.CacheCreationTokens,
+		CacheCreation5mTokens: req.CacheCreation5mTokens,
+		CacheCreation1hTokens: req.CacheCreation1hTokens,
+		CacheReadTokens:       req.CacheReadTokens,
+		WebSearchRequests:     req.WebSearchRequests,
+		WebFetchRequests:      req.WebFetchRequests,
 	}
+	cost := ut.CalculateRequestCost(req.Channel, req.GroupName, req.EndpointName, req.ModelName, usage, req.StartTime)
 
-	return RequestDetail{
+	detail := RequestDetail{
 		ID:                    0, // 热池中的请求还没有数据库ID
 		RequestID:             req.RequestID,
 		ClientIP:              req.ClientIP,
@@ -1844,6 +2003,8 @@ func (ut *UsageTracker) ActiveRequestToDetail(req *ActiveRequest) RequestDetail
 		FailureReason:         req.FailureReason,
 		LastFailureReason:     "",
 		CancelReason:          req.CancelReason,
+		SessionID:             req.SessionID,
+		Tags:                  append([]RequestTag(nil), req.Tags...),
 		InputTokens:           req.InputTokens,
 		OutputTokens:          req.OutputTokens,
 		CacheCreationTokens:   req.CacheCreationTokens,
@@ -1854,10 +2015,20 @@ func (ut *UsageTracker) ActiveRequestToDetail(req *ActiveRequest) RequestDetail
 		OutputCostUSD:         cost.OutputCost,
 		CacheCreationCostUSD:  cost.CacheCreationCost,
 		CacheReadCostUSD:      cost.CacheReadCost,
+		WebSearchRequests:     req.WebSearchRequests,
+		WebFetchRequests:      req.WebFetchRequests,
+		ServerToolCostUSD:     cost.ServerToolCost,
 		TotalCostUSD:          cost.TotalCost,
+		PricingTier:           cost.PricingTier,
+		PriceSource:           cost.PriceSource,
+		RequestFeeUSD:         cost.RequestFee,
 		CreatedAt:             req.StartTime,
 		UpdatedAt:             ut.now(),
 	}
+	req.Timings.applyTo(&detail)
+	req.RequestParams.applyTo(&detail)
+	req.ResponseMeta.applyTo(&detail)
+	return detail
 }
 
 // QueryRequestDetailsWithHotPool 双源查询：热池 + 数据库
@@ -1987,6 +2158,18 @@ func (ut *UsageTracker) getFilteredHotPoolRequests(opts *QueryOptions) []Request
 			if opts.GroupName != "" && req.GroupName != opts.GroupName {
 				continue
 			}
+			// 会话过滤
+			if opts.SessionID != "" && req.SessionID != opts.SessionID {
+				continue
+			}
+			// 标签过滤
+			if !hasAllTags(req.Tags, opts.Tags) {
+				continue
+			}
+			// 停止原因 / 工具调用 / thinking / 请求大小过滤
+			if !opts.matchesResponseMeta(req) {
+				continue
+			}
 			// 时间范围过滤
 			if opts.StartDate != nil && req.StartTime.Before(*opts.StartDate) {
 				continue
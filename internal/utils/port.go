@@ -64,16 +64,16 @@ func IsPortAvailable(port int) bool {
 	return true
 }
 
-// FindAndBind 查找可用端口并绑定
+// FindAndBind 查找可用端口并绑定到指定地址（host 为空时监听所有地址）
 // 返回 listener 和实际端口号
-func FindAndBind(preferred int, maxAttempts int) (net.Listener, int, error) {
+func FindAndBind(host string, preferred int, maxAttempts int) (net.Listener, int, error) {
 	if maxAttempts <= 0 {
 		maxAttempts = 10
 	}
 
 	for i := 0; i < maxAttempts; i++ {
 		port := preferred + i
-		addr := fmt.Sprintf(":%d", port)
+		addr := net.JoinHostPort(host, fmt.Sprintf("%d", port))
 		listener, err := net.Listen("tcp", addr)
 		if err == nil {
 			if i > 0 {
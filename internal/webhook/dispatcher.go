@@ -0,0 +1,446 @@
+// Package webhook 运维事件的出站 Webhook 通知
+// 订阅 EventBus 中的故障转移、端点宕机/恢复、挂起超时、数据库错误、预算与渠道余额事件，
+// 按目标配置的事件过滤、去重与限流后异步投递（失败按指数退避重试，最终失败不占用去重/限流额度），投递结果交由记录器落库。
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"cc-forwarder/internal/events"
+)
+
+// Webhook 目标类型（决定消息体格式）
+const (
+	KindGeneric  = "generic"  // 通用 JSON（可选 HMAC-SHA256 签名头）
+	KindSlack    = "slack"    // Slack Incoming Webhook
+	KindFeishu   = "feishu"   // 飞书自定义机器人（可选签名校验）
+	KindDingTalk = "dingtalk" // 钉钉自定义机器人（可选加签）
+	KindWeCom    = "wecom"    // 企业微信群机器人
+)
+
+// 投递状态
+const (
+	DeliverySuccess = "success"
+	DeliveryFailed  = "failed"
+)
+
+// EventTest 测试消息事件类型（仅用于手动测试投递）
+const EventTest events.EventType = "webhook_test"
+
+// DefaultEventTypes 未配置事件过滤时订阅的运维事件
+var DefaultEventTypes = []events.EventType{
+	events.EventEndpointDown,
+	events.EventEndpointUp,
+	events.EventFailoverTriggered,
+	events.EventRequestSuspendTimeout,
+	events.EventDatabaseError,
+	events.EventSystemError,
+	events.EventBudgetAlert,
+	events.EventBudgetExceeded,
+	events.EventBalanceLow,
+	events.EventBalanceRecovered,
+}
+
+const (
+	defaultQueueSize   = 256
+	defaultWorkers     = 2
+	defaultBackoff     = 2 * time.Second
+	maxBackoff         = time.Minute
+	requestTimeout     = 10 * time.Second
+	maxResponseBodyLen = 4096
+)
+
+// Target Webhook 投递目标
+type Target struct {
+	Name       string
+	Kind       string
+	URL        string
+	Secret     string   // 签名密钥（飞书/钉钉加签，通用 JSON 的 HMAC 签名头）
+	EventTypes []string // 订阅的事件类型（为空时使用 DefaultEventTypes）
+
+	DedupWindow time.Duration // 同一事件（类型 + 对象）在窗口内只投递一次（0 不去重）
+	RateLimit   int           // 每分钟最多投递次数（0 不限）
+	MaxRetries  int           // 失败重试次数（网络错误、429、5xx）
+}
+
+// Delivery 一次事件投递的结果
+type Delivery struct {
+	Webhook     string
+	EventType   string
+	DedupKey    string
+	Status      string // success / failed
+	Attempts    int
+	HTTPStatus  int
+	Error       string
+	Payload     string
+	CreatedAt   time.Time
+	DeliveredAt time.Time
+}
+
+// job 待投递任务
+type job struct {
+	target     Target
+	event      events.Event
+	key        string
+	reservedAt time.Time // allow 预占去重/限流额度的时间（投递失败时据此回滚）
+}
+
+// targetState 单个目标的限流与去重状态
+type targetState struct {
+	sent     []time.Time          // 最近一分钟的投递时间
+	lastSent map[string]time.Time // 去重键 → 最近投递时间
+}
+
+// Dispatcher Webhook 投递器
+type Dispatcher struct {
+	client  *http.Client
+	backoff time.Duration
+
+	mu       sync.RWMutex
+	targets  []Target
+	recorder func(Delivery)
+
+	stateMu sync.Mutex
+	states  map[string]*targetState
+
+	queue  chan job
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	once   sync.Once
+}
+
+// NewDispatcher 创建 Webhook 投递器（client 为 nil 时使用默认客户端）
+func NewDispatcher(client *http.Client) *Dispatcher {
+	if client == nil {
+		client = &http.Client{}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Dispatcher{
+		client:  client,
+		backoff: defaultBackoff,
+		states:  make(map[string]*targetState),
+		queue:   make(chan job, defaultQueueSize),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+}
+
+// Start 启动投递协程
+func (d *Dispatcher) Start() {
+	d.once.Do(func() {
+		for i := 0; i < defaultWorkers; i++ {
+			d.wg.Add(1)
+			go d.worker()
+		}
+	})
+}
+
+// Stop 停止投递（丢弃未投递的任务）
+func (d *Dispatcher) Stop() {
+	d.cancel()
+	d.wg.Wait()
+}
+
+// UpdateTargets 更新投递目标（运行时动态更新）
+func (d *Dispatcher) UpdateTargets(targets []Target) {
+	d.mu.Lock()
+	d.targets = targets
+	d.mu.Unlock()
+
+	// 清理已删除目标的限流状态
+	names := make(map[string]bool, len(targets))
+	for _, t := range targets {
+		names[t.Name] = true
+	}
+	d.stateMu.Lock()
+	for name := range d.states {
+		if !names[name] {
+			delete(d.states, name)
+		}
+	}
+	d.stateMu.Unlock()
+
+	slog.Info(fmt.Sprintf("🔔 [Webhook] 已更新投递目标: %d 个", len(targets)))
+}
+
+// SetDeliveryRecorder 设置投递结果记录器（在投递协程中调用）
+func (d *Dispatcher) SetDeliveryRecorder(recorder func(Delivery)) {
+	d.mu.Lock()
+	d.recorder = recorder
+	d.mu.Unlock()
+}
+
+// HandleEvent 处理 EventBus 事件：匹配目标、去重限流后入队（不阻塞）
+func (d *Dispatcher) HandleEvent(event events.Event) {
+	d.mu.RLock()
+	targets := d.targets
+	d.mu.RUnlock()
+	if len(targets) == 0 {
+		return
+	}
+
+	key := DedupKey(event)
+	now := time.Now()
+	for _, target := range targets {
+		if !target.Subscribes(event.Type) {
+			continue
+		}
+		if !d.allow(target, key, now) {
+			slog.Debug(fmt.Sprintf("🔕 [Webhook] %s 事件被去重/限流: %s", target.Name, key))
+			continue
+		}
+		select {
+		case d.queue <- job{target: target, event: event, key: key, reservedAt: now}:
+		default:
+			d.release(target, key, now)
+			slog.Warn(fmt.Sprintf("⚠️ [Webhook] 投递队列已满，丢弃事件: %s → %s", event.Type, target.Name))
+		}
+	}
+}
+
+// Send 同步投递事件到目标（含重试），返回投递结果
+func (d *Dispatcher) Send(ctx context.Context, target Target, event events.Event) Delivery {
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now()
+	}
+	delivery := Delivery{
+		Webhook:   target.Name,
+		EventType: string(event.Type),
+		DedupKey:  DedupKey(event),
+		CreatedAt: time.Now(),
+	}
+
+	body, err := BuildPayload(target.Kind, target.Secret, event)
+	if err != nil {
+		delivery.Status = DeliveryFailed
+		delivery.Error = err.Error()
+		return delivery
+	}
+	delivery.Payload = string(body)
+
+	for attempt := 0; attempt <= target.MaxRetries; attempt++ {
+		if attempt > 0 {
+			wait := d.backoff << (attempt - 1)
+			if wait > maxBackoff {
+				wait = maxBackoff
+			}
+			select {
+			case <-ctx.Done():
+				delivery.Status = DeliveryFailed
+				delivery.Error = ctx.Err().Error()
+				return delivery
+			case <-time.After(wait):
+			}
+		}
+
+		delivery.Attempts = attempt + 1
+		status, retryable, err := d.post(ctx, target, body)
+		delivery.HTTPStatus = status
+		if err == nil {
+			delivery.Status = DeliverySuccess
+			delivery.Error = ""
+			delivery.DeliveredAt = time.Now()
+			return delivery
+		}
+		delivery.Error = err.Error()
+		if !retryable {
+			break
+		}
+	}
+
+	delivery.Status = DeliveryFailed
+	return delivery
+}
+
+// Subscribes 目标是否订阅了指定事件类型
+func (t Target) Subscribes(eventType events.EventType) bool {
+	if eventType == EventTest {
+		return true
+	}
+	if len(t.EventTypes) == 0 {
+		for _, et := range DefaultEventTypes {
+			if et == eventType {
+				return true
+			}
+		}
+		return false
+	}
+	for _, et := range t.EventTypes {
+		if et == string(eventType) || et == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// allow 检查去重窗口与每分钟限流，通过时预占本次投递的额度（投递最终失败时由 release 回滚）
+// 预占保证同一事件在投递进行中不会被重复入队
+func (d *Dispatcher) allow(target Target, key string, now time.Time) bool {
+	d.stateMu.Lock()
+	defer d.stateMu.Unlock()
+
+	state := d.states[target.Name]
+	if state == nil {
+		state = &targetState{lastSent: make(map[string]time.Time)}
+		d.states[target.Name] = state
+	}
+
+	if target.DedupWindow > 0 {
+		if last, ok := state.lastSent[key]; ok && now.Sub(last) < target.DedupWindow {
+			return false
+		}
+	}
+
+	if target.RateLimit > 0 {
+		cutoff := now.Add(-time.Minute)
+		kept := state.sent[:0]
+		for _, t := range state.sent {
+			if t.After(cutoff) {
+				kept = append(kept, t)
+			}
+		}
+		state.sent = kept
+		if len(state.sent) >= target.RateLimit {
+			return false
+		}
+	}
+
+	if target.RateLimit > 0 {
+		state.sent = append(state.sent, now)
+	}
+	if target.DedupWindow > 0 {
+		for k, t := range state.lastSent {
+			if now.Sub(t) >= target.DedupWindow {
+				delete(state.lastSent, k)
+			}
+		}
+		state.lastSent[key] = now
+	}
+	return true
+}
+
+// release 回滚 allow 在 at 时刻预占的去重记录与限流额度（未投递成功的事件不应抑制后续同类事件）
+func (d *Dispatcher) release(target Target, key string, at time.Time) {
+	d.stateMu.Lock()
+	defer d.stateMu.Unlock()
+
+	state := d.states[target.Name]
+	if state == nil {
+		return
+	}
+	if last, ok := state.lastSent[key]; ok && last.Equal(at) {
+		delete(state.lastSent, key)
+	}
+	for i, t := range state.sent {
+		if t.Equal(at) {
+			state.sent = append(state.sent[:i], state.sent[i+1:]...)
+			break
+		}
+	}
+}
+
+// worker 投递协程
+func (d *Dispatcher) worker() {
+	defer d.wg.Done()
+
+	for {
+		select {
+		case <-d.ctx.Done():
+			return
+		case j := <-d.queue:
+			delivery := d.Send(d.ctx, j.target, j.event)
+			delivery.DedupKey = j.key
+			if delivery.Status == DeliverySuccess {
+				slog.Info(fmt.Sprintf("🔔 [Webhook] 已投递 %s → %s", j.event.Type, j.target.Name))
+			} else {
+				d.release(j.target, j.key, j.reservedAt)
+				slog.Warn(fmt.Sprintf("⚠️ [Webhook] 投递失败 %s → %s (尝试 %d 次): %s",
+					j.event.Type, j.target.Name, delivery.Attempts, delivery.Error))
+			}
+
+			d.mu.RLock()
+			recorder := d.recorder
+			d.mu.RUnlock()
+			if recorder != nil {
+				recorder(delivery)
+			}
+		}
+	}
+}
+
+// post 发送一次请求，返回 HTTP 状态码、是否可重试与错误
+func (d *Dispatcher) post(ctx context.Context, target Target, body []byte) (int, bool, error) {
+	targetURL, err := signedURL(target)
+	if err != nil {
+		return 0, false, err
+	}
+
+	reqCtx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(reqCtx, http.MethodPost, targetURL, bytes.NewReader(body))
+	if err != nil {
+		return 0, false, fmt.Errorf("创建请求失败: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "cc-forwarder-webhook")
+	if target.Kind == KindGeneric && target.Secret != "" {
+		ts := fmt.Sprintf("%d", time.Now().Unix())
+		req.Header.Set("X-Webhook-Timestamp", ts)
+		req.Header.Set("X-Webhook-Signature", "sha256="+hmacHex(target.Secret, ts+"."+string(body)))
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, true, fmt.Errorf("请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseBodyLen))
+
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+		return resp.StatusCode, true, fmt.Errorf("HTTP %d: %s", resp.StatusCode, truncate(string(respBody), 200))
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, false, fmt.Errorf("HTTP %d: %s", resp.StatusCode, truncate(string(respBody), 200))
+	}
+
+	// 飞书/钉钉/企业微信出错时仍返回 200，需要检查业务错误码
+	if err := checkBotResponse(respBody); err != nil {
+		return resp.StatusCode, false, err
+	}
+	return resp.StatusCode, false, nil
+}
+
+// checkBotResponse 检查机器人接口的业务错误码（code / errcode / StatusCode 非 0 视为失败）
+func checkBotResponse(body []byte) error {
+	var result map[string]interface{}
+	if len(body) == 0 || json.Unmarshal(body, &result) != nil {
+		return nil
+	}
+	for _, key := range []string{"code", "errcode", "StatusCode"} {
+		if v, ok := result[key].(float64); ok && v != 0 {
+			msg := result["msg"]
+			if msg == nil {
+				msg = result["errmsg"]
+			}
+			return fmt.Errorf("%s=%v: %v", key, v, msg)
+		}
+	}
+	return nil
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
@@ -0,0 +1,181 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"cc-forwarder/internal/events"
+)
+
+func failoverEvent() events.Event {
+	return events.Event{
+		Type:      events.EventFailoverTriggered,
+		Source:    "endpoint_manager",
+		Timestamp: time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC),
+		Data: map[string]interface{}{
+			"failed_channel":   "relay",
+			"new_channel":      "official",
+			"reason":           "HTTP 503",
+			"failed_endpoints": []string{"relay-a"},
+		},
+	}
+}
+
+// TestBuildPayload 测试各类型目标的消息体格式
+func TestBuildPayload(t *testing.T) {
+	evt := failoverEvent()
+
+	tests := []struct {
+		kind  string
+		check func(t *testing.T, body map[string]interface{})
+	}{
+		{KindGeneric, func(t *testing.T, body map[string]interface{}) {
+			if body["event"] != "failover_triggered" || body["data"].(map[string]interface{})["new_channel"] != "official" {
+				t.Errorf("generic 消息体不符: %v", body)
+			}
+		}},
+		{KindSlack, func(t *testing.T, body map[string]interface{}) {
+			if !strings.Contains(body["text"].(string), "relay → official") {
+				t.Errorf("slack 消息体不符: %v", body)
+			}
+		}},
+		{KindFeishu, func(t *testing.T, body map[string]interface{}) {
+			if body["msg_type"] != "text" || body["sign"] == nil || body["timestamp"] == nil {
+				t.Errorf("feishu 消息体不符: %v", body)
+			}
+		}},
+		{KindDingTalk, func(t *testing.T, body map[string]interface{}) {
+			md := body["markdown"].(map[string]interface{})
+			if body["msgtype"] != "markdown" || !strings.HasPrefix(md["text"].(string), "### ") {
+				t.Errorf("dingtalk 消息体不符: %v", body)
+			}
+		}},
+		{KindWeCom, func(t *testing.T, body map[string]interface{}) {
+			md := body["markdown"].(map[string]interface{})
+			if !strings.Contains(md["content"].(string), "HTTP 503") {
+				t.Errorf("wecom 消息体不符: %v", body)
+			}
+		}},
+	}
+
+	for _, tt := range tests {
+		data, err := BuildPayload(tt.kind, "secret", evt)
+		if err != nil {
+			t.Fatalf("%s: 生成消息体失败: %v", tt.kind, err)
+		}
+		var body map[string]interface{}
+		if err := json.Unmarshal(data, &body); err != nil {
+			t.Fatalf("%s: 消息体不是合法 JSON: %v", tt.kind, err)
+		}
+		tt.check(t, body)
+	}
+
+	if _, err := BuildPayload("unknown", "", evt); err == nil {
+		t.Error("未知类型应返回错误")
+	}
+}
+
+// TestDispatcherDedupAndRateLimit 测试去重窗口与每分钟限流
+func TestDispatcherDedupAndRateLimit(t *testing.T) {
+	d := NewDispatcher(nil)
+	now := time.Now()
+
+	dedup := Target{Name: "dedup", DedupWindow: time.Minute}
+	if !d.allow(dedup, "k1", now) || d.allow(dedup, "k1", now.Add(30*time.Second)) {
+		t.Error("去重窗口内同一事件只应投递一次")
+	}
+	if !d.allow(dedup, "k2", now) || !d.allow(dedup, "k1", now.Add(61*time.Second)) {
+		t.Error("不同事件或窗口过期后应允许投递")
+	}
+
+	limited := Target{Name: "limited", RateLimit: 2}
+	if !d.allow(limited, "a", now) || !d.allow(limited, "b", now) || d.allow(limited, "c", now) {
+		t.Error("每分钟超过限流次数应被丢弃")
+	}
+	if !d.allow(limited, "d", now.Add(61*time.Second)) {
+		t.Error("一分钟后应恢复投递")
+	}
+
+	target := Target{Name: "filter", EventTypes: []string{"budget_alert"}}
+	if target.Subscribes(events.EventFailoverTriggered) || !target.Subscribes(events.EventBudgetAlert) {
+		t.Error("事件过滤不符")
+	}
+	if !(Target{}).Subscribes(events.EventEndpointDown) || (Target{}).Subscribes(events.EventRequestStarted) {
+		t.Error("未配置过滤时应只订阅默认运维事件")
+	}
+}
+
+// TestDispatcherReleasesFailedDelivery 测试投递最终失败时回滚去重与限流额度，后续同一事件仍可投递
+func TestDispatcherReleasesFailedDelivery(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	d := NewDispatcher(server.Client())
+	deliveries := make(chan Delivery, 2)
+	d.SetDeliveryRecorder(func(delivery Delivery) { deliveries <- delivery })
+	d.UpdateTargets([]Target{{Name: "ops", Kind: KindGeneric, URL: server.URL, DedupWindow: time.Hour, RateLimit: 1}})
+	d.Start()
+	defer d.Stop()
+
+	for i, want := range []string{DeliveryFailed, DeliverySuccess} {
+		d.HandleEvent(failoverEvent())
+		select {
+		case delivery := <-deliveries:
+			if delivery.Status != want {
+				t.Fatalf("第 %d 次投递状态 = %s, want %s: %+v", i+1, delivery.Status, want, delivery)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("第 %d 次投递超时（失败的投递不应占用去重/限流额度）", i+1)
+		}
+	}
+
+	// 成功投递后去重与限流生效
+	if d.allow(Target{Name: "ops", DedupWindow: time.Hour, RateLimit: 1}, DedupKey(failoverEvent()), time.Now()) {
+		t.Error("成功投递后同一事件应被去重")
+	}
+}
+
+// TestDispatcherSendRetry 测试 5xx 重试、业务错误码不重试
+func TestDispatcherSendRetry(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch atomic.AddInt32(&calls, 1) {
+		case 1:
+			w.WriteHeader(http.StatusBadGateway)
+		default:
+			w.Write([]byte(`{"errcode":0,"errmsg":"ok"}`))
+		}
+	}))
+	defer server.Close()
+
+	d := NewDispatcher(server.Client())
+	d.backoff = time.Millisecond
+
+	delivery := d.Send(context.Background(), Target{Name: "wecom", Kind: KindWeCom, URL: server.URL, MaxRetries: 2}, failoverEvent())
+	if delivery.Status != DeliverySuccess || delivery.Attempts != 2 || delivery.HTTPStatus != http.StatusOK {
+		t.Errorf("重试后应投递成功: %+v", delivery)
+	}
+
+	botErr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"code":19021,"msg":"sign match fail"}`))
+	}))
+	defer botErr.Close()
+
+	delivery = d.Send(context.Background(), Target{Name: "feishu", Kind: KindFeishu, URL: botErr.URL, MaxRetries: 3}, failoverEvent())
+	if delivery.Status != DeliveryFailed || delivery.Attempts != 1 || !strings.Contains(delivery.Error, "sign match fail") {
+		t.Errorf("业务错误码应直接失败且不重试: %+v", delivery)
+	}
+}
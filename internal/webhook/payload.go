@@ -0,0 +1,233 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"cc-forwarder/internal/events"
+)
+
+// BuildPayload 按目标类型生成消息体
+func BuildPayload(kind, secret string, event events.Event) ([]byte, error) {
+	title, message := Describe(event)
+	text := title
+	if message != "" {
+		text += "\n" + message
+	}
+
+	var payload interface{}
+	switch kind {
+	case KindGeneric, "":
+		payload = map[string]interface{}{
+			"event":     string(event.Type),
+			"title":     title,
+			"message":   message,
+			"source":    event.Source,
+			"priority":  int(event.Priority),
+			"timestamp": event.Timestamp.Format(time.RFC3339),
+			"data":      event.Data,
+		}
+	case KindSlack:
+		payload = map[string]interface{}{
+			"text": "*" + title + "*\n" + message,
+		}
+	case KindFeishu:
+		body := map[string]interface{}{
+			"msg_type": "text",
+			"content":  map[string]string{"text": text},
+		}
+		if secret != "" {
+			ts := time.Now().Unix()
+			body["timestamp"] = fmt.Sprintf("%d", ts)
+			body["sign"] = feishuSign(secret, ts)
+		}
+		payload = body
+	case KindDingTalk:
+		payload = map[string]interface{}{
+			"msgtype": "markdown",
+			"markdown": map[string]string{
+				"title": title,
+				"text":  "### " + title + "\n\n" + strings.ReplaceAll(message, "\n", "\n\n"),
+			},
+		}
+	case KindWeCom:
+		payload = map[string]interface{}{
+			"msgtype": "markdown",
+			"markdown": map[string]string{
+				"content": "**" + title + "**\n" + message,
+			},
+		}
+	default:
+		return nil, fmt.Errorf("不支持的 Webhook 类型: %s", kind)
+	}
+
+	return json.Marshal(payload)
+}
+
+// Describe 生成事件的标题与正文（多行文本）
+func Describe(event events.Event) (string, string) {
+	d := event.Data
+	ts := event.Timestamp.Format("2006-01-02 15:04:05")
+
+	switch event.Type {
+	case events.EventEndpointDown:
+		return fmt.Sprintf("🔴 端点不可用: %s", field(d, "endpoint")),
+			lines("渠道", field(d, "channel"), "地址", field(d, "url"), "连续失败", field(d, "consecutive_fails"), "时间", ts)
+	case events.EventEndpointUp:
+		return fmt.Sprintf("🟢 端点已恢复: %s", field(d, "endpoint")),
+			lines("渠道", field(d, "channel"), "响应时间", field(d, "response_time"), "时间", ts)
+	case events.EventFailoverTriggered:
+		if field(d, "new_channel") == "" {
+			return fmt.Sprintf("🚨 故障转移失败: 渠道 %s 没有可用的备用渠道", field(d, "failed_channel")),
+				lines("原因", field(d, "reason"), "失败端点", field(d, "failed_endpoints"), "时间", ts)
+		}
+		return fmt.Sprintf("🔀 渠道故障转移: %s → %s", field(d, "failed_channel"), field(d, "new_channel")),
+			lines("原因", field(d, "reason"), "失败端点", field(d, "failed_endpoints"), "时间", ts)
+	case events.EventRequestSuspendTimeout:
+		return "⏰ 挂起请求等待超时",
+			lines("请求", field(d, "request_id"), "失败端点", field(d, "failed_endpoint"),
+				"超时(秒)", field(d, "timeout_seconds"), "当前挂起数", field(d, "suspended_requests"), "时间", ts)
+	case events.EventDatabaseError:
+		return "💾 使用记录写入数据库失败",
+			lines("操作", field(d, "operation"), "错误", field(d, "error"), "时间", ts)
+	case events.EventBudgetAlert:
+		return fmt.Sprintf("💸 预算告警: %s 已达 %s%%", field(d, "name"), field(d, "threshold")),
+			lines("花费", fmt.Sprintf("$%s / $%s", field(d, "spent_usd"), field(d, "limit_usd")),
+				"窗口", field(d, "window"), "窗口结束", field(d, "window_end"), "时间", ts)
+	case events.EventBudgetExceeded:
+		return fmt.Sprintf("🚫 超出预算: %s", field(d, "name")),
+			lines("花费", fmt.Sprintf("$%s / $%s", field(d, "spent_usd"), field(d, "limit_usd")),
+				"动作", field(d, "action"), "分流渠道", field(d, "divert_channel"), "窗口结束", field(d, "window_end"), "时间", ts)
+	case events.EventBalanceLow:
+		return fmt.Sprintf("🪫 渠道余额不足: %s", field(d, "channel")),
+			lines("余额", field(d, "balance")+" "+field(d, "unit"), "阈值", field(d, "threshold"),
+				"排除故障转移", field(d, "exclude_when_low"), "切换到", field(d, "diverted_to"), "时间", ts)
+	case events.EventBalanceRecovered:
+		return fmt.Sprintf("🔋 渠道余额已恢复: %s", field(d, "channel")),
+			lines("余额", field(d, "balance")+" "+field(d, "unit"), "阈值", field(d, "threshold"), "时间", ts)
+	case EventTest:
+		return "✅ CC-Forwarder Webhook 测试消息", lines("目标", field(d, "webhook"), "时间", ts)
+	}
+
+	// 其他事件：按键名排序输出数据
+	keys := make([]string, 0, len(d))
+	for k := range d {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	kv := make([]string, 0, len(keys)*2)
+	for _, k := range keys {
+		kv = append(kv, k, field(d, k))
+	}
+	return fmt.Sprintf("📢 %s", event.Type), lines(kv...)
+}
+
+// DedupKey 事件去重键：事件类型 + 事件对象（端点/渠道/预算/操作）
+func DedupKey(event events.Event) string {
+	d := event.Data
+	var subject string
+	switch event.Type {
+	case events.EventEndpointDown, events.EventEndpointUp:
+		subject = field(d, "endpoint")
+	case events.EventFailoverTriggered:
+		subject = field(d, "failed_channel") + "→" + field(d, "new_channel")
+	case events.EventRequestSuspendTimeout:
+		subject = field(d, "failed_endpoint")
+	case events.EventDatabaseError:
+		subject = field(d, "operation")
+	case events.EventBudgetAlert:
+		subject = field(d, "name") + "@" + field(d, "threshold")
+	case events.EventBudgetExceeded:
+		subject = field(d, "name")
+	case events.EventBalanceLow, events.EventBalanceRecovered:
+		subject = field(d, "channel")
+	}
+	if subject == "" {
+		return string(event.Type)
+	}
+	return string(event.Type) + ":" + subject
+}
+
+// signedURL 钉钉加签：在 URL 上附加 timestamp 与 sign 参数
+func signedURL(target Target) (string, error) {
+	if target.Kind != KindDingTalk || target.Secret == "" {
+		return target.URL, nil
+	}
+	u, err := url.Parse(target.URL)
+	if err != nil {
+		return "", fmt.Errorf("Webhook 地址无效: %w", err)
+	}
+	ts := time.Now().UnixMilli()
+	sign := base64.StdEncoding.EncodeToString(hmacSum(target.Secret, fmt.Sprintf("%d\n%s", ts, target.Secret)))
+	q := u.Query()
+	q.Set("timestamp", fmt.Sprintf("%d", ts))
+	q.Set("sign", sign)
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// feishuSign 飞书签名：以 "timestamp\nsecret" 为密钥对空串做 HMAC-SHA256
+func feishuSign(secret string, ts int64) string {
+	mac := hmac.New(sha256.New, []byte(fmt.Sprintf("%d\n%s", ts, secret)))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func hmacSum(secret, message string) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(message))
+	return mac.Sum(nil)
+}
+
+func hmacHex(secret, message string) string {
+	return hex.EncodeToString(hmacSum(secret, message))
+}
+
+// field 读取事件数据字段为字符串（缺失时返回空串）
+func field(data map[string]interface{}, key string) string {
+	v, ok := data[key]
+	if !ok || v == nil {
+		return ""
+	}
+	switch val := v.(type) {
+	case string:
+		return val
+	case float64:
+		return trimFloat(val)
+	case float32:
+		return trimFloat(float64(val))
+	case []string:
+		return strings.Join(val, ", ")
+	}
+	return fmt.Sprint(v)
+}
+
+func trimFloat(v float64) string {
+	s := fmt.Sprintf("%.4f", v)
+	s = strings.TrimRight(s, "0")
+	return strings.TrimSuffix(s, ".")
+}
+
+// lines 将 label/value 对拼接为多行文本（跳过空值）
+func lines(kv ...string) string {
+	var b strings.Builder
+	for i := 0; i+1 < len(kv); i += 2 {
+		if kv[i+1] == "" {
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString(kv[i])
+		b.WriteString(": ")
+		b.WriteString(kv[i+1])
+	}
+	return b.String()
+}